@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"time"
 
 	// static assets
@@ -14,6 +15,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/negroni"
+	"github.com/elazarl/goproxy"
 	"github.com/go-zoo/bone"
 	"github.com/gorilla/websocket"
 	"github.com/meatballhat/negroni-logrus"
@@ -21,16 +23,31 @@ import (
 
 // recordedRequests struct encapsulates payload data
 type recordedRequests struct {
-	Data []Payload `json:"data"`
+	Meta *simulationMeta `json:"meta,omitempty"`
+	Data []Payload       `json:"data"`
 }
 
+// recordsPage struct encapsulates one page of payload data, it can be imported just like recordedRequests
+type recordsPage struct {
+	Meta   *simulationMeta `json:"meta"`
+	Data   []Payload       `json:"data"`
+	Offset int             `json:"offset"`
+	Limit  int             `json:"limit"`
+	Total  int             `json:"total"`
+}
+
+// DefaultPageLimit - default number of records returned per page
+const DefaultPageLimit = 100
+
 type recordsCount struct {
-	Count int `json:"count"`
+	Count        int            `json:"count"`
+	Destinations map[string]int `json:"destinations,omitempty"`
 }
 
 type statsResponse struct {
-	Stats        Stats `json:"stats"`
-	RecordsCount int   `json:"recordsCount"`
+	Stats        Stats      `json:"stats"`
+	RecordsCount int        `json:"recordsCount"`
+	CacheStats   CacheStats `json:"cacheStats"`
 }
 
 type stateRequest struct {
@@ -56,14 +73,28 @@ func (d *DBClient) StartAdminInterface() {
 		}
 
 		n.Use(negronilogrus.NewCustomMiddleware(logLevel, &log.JSONFormatter{}, "admin"))
-		n.UseHandler(mux)
+		n.UseHandler(d.AdminAuthHandler(mux))
+
+		tlsConfig, err := NewAdminTLSConfig(d.Cfg)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Fatal("Failed to configure TLS for admin interface")
+		}
 
 		// admin interface starting message
 		log.WithFields(log.Fields{
-			"AdminPort": d.Cfg.AdminPort,
+			"AdminAddress": d.Cfg.AdminListenAddress(),
+			"TLS":          tlsConfig != nil,
+			"ClientCA":     d.Cfg.AdminClientCA,
 		}).Info("Admin interface is starting...")
 
-		n.Run(fmt.Sprintf(":%s", d.Cfg.AdminPort))
+		server := &http.Server{Addr: d.Cfg.AdminListenAddress(), Handler: n, TLSConfig: tlsConfig}
+		if tlsConfig != nil {
+			// certificate and key are already loaded into TLS configuration
+			log.Fatal(server.ListenAndServeTLS("", ""))
+		}
+		log.Fatal(server.ListenAndServe())
 	}()
 }
 
@@ -71,13 +102,98 @@ func (d *DBClient) StartAdminInterface() {
 func getBoneRouter(d DBClient) *bone.Mux {
 	mux := bone.New()
 
-	mux.Get("/records", http.HandlerFunc(d.AllRecordsHandler))
-	mux.Delete("/records", http.HandlerFunc(d.DeleteAllRecordsHandler))
-	mux.Post("/records", http.HandlerFunc(d.ImportRecordsHandler))
+	// records API is available under /api prefix as well, so tooling can manage records without clashing with
+	// admin UI paths
+	for _, prefix := range []string{"", "/api"} {
+		mux.Get(prefix+"/records", http.HandlerFunc(d.AllRecordsHandler))
+		mux.Delete(prefix+"/records", http.HandlerFunc(d.DeleteAllRecordsHandler))
+		mux.Post(prefix+"/records", http.HandlerFunc(d.ImportRecordsHandler))
+		mux.Post(prefix+"/records/merge", http.HandlerFunc(d.MergeRecordsHandler))
+		mux.Post(prefix+"/records/diff", http.HandlerFunc(d.DiffRecordsHandler))
+		mux.Get(prefix+"/records/har", http.HandlerFunc(d.ExportHARHandler))
+		mux.Post(prefix+"/records/har", http.HandlerFunc(d.ImportHARHandler))
+		mux.Get(prefix+"/records/openapi", http.HandlerFunc(d.ExportOpenAPIHandler))
+		mux.Post(prefix+"/records/openapi", http.HandlerFunc(d.ImportOpenAPIHandler))
+		mux.Post(prefix+"/records/wiremock", http.HandlerFunc(d.ImportWireMockHandler))
+		mux.Post(prefix+"/records/pcap", http.HandlerFunc(d.ImportPcapHandler))
+		mux.Post(prefix+"/records/curl", http.HandlerFunc(d.ImportCurlHandler))
+		mux.Get(prefix+"/records/search", http.HandlerFunc(d.SearchRecordsHandler))
+		mux.Get(prefix+"/records/:id", http.HandlerFunc(d.RecordHandler))
+		mux.Delete(prefix+"/records/:id", http.HandlerFunc(d.DeleteRecordHandler))
+	}
+
+	mux.Post(TokenAuthPath, http.HandlerFunc(d.TokenAuthHandler))
+	mux.Get("/api/users", http.HandlerFunc(d.UsersHandler))
+	mux.Post("/api/users", http.HandlerFunc(d.AddUserHandler))
+	mux.Delete("/api/users/:username", http.HandlerFunc(d.DeleteUserHandler))
+
+	mux.Get("/backup", http.HandlerFunc(d.BackupHandler))
+	mux.Post("/compact", http.HandlerFunc(d.CompactHandler))
+
+	mux.Get("/namespaces", http.HandlerFunc(d.NamespacesHandler))
+	mux.Post("/namespaces", http.HandlerFunc(d.CreateNamespaceHandler))
+	mux.Put("/namespaces/current", http.HandlerFunc(d.SwitchNamespaceHandler))
+	mux.Delete("/namespaces/:name", http.HandlerFunc(d.DeleteNamespaceHandler))
 
 	mux.Get("/count", http.HandlerFunc(d.RecordsCount))
 	mux.Get("/stats", http.HandlerFunc(d.StatsHandler))
 	mux.Get("/statsws", http.HandlerFunc(d.StatsWSHandler))
+	mux.Get("/recordsws", http.HandlerFunc(d.RecordsWSHandler))
+	mux.Get("/api/ws", http.HandlerFunc(d.TrafficWSHandler))
+	mux.Get("/api/journal", http.HandlerFunc(d.JournalHandler))
+	mux.Delete("/api/journal", http.HandlerFunc(d.DeleteJournalHandler))
+	mux.Post("/api/journal/verify", http.HandlerFunc(d.VerifyJournalHandler))
+	mux.Post("/api/debug/match", http.HandlerFunc(d.DebugMatchHandler))
+
+	mux.Get("/diff", http.HandlerFunc(d.DiffHandler))
+	mux.Delete("/diff", http.HandlerFunc(d.DeleteDiffHandler))
+
+	mux.Get("/destination", http.HandlerFunc(d.CurrentDestinationHandler))
+	mux.Put("/destination", http.HandlerFunc(d.DestinationHandler))
+
+	mux.Get("/delays", http.HandlerFunc(d.DelaysHandler))
+	mux.Put("/delays", http.HandlerFunc(d.SetDelaysHandler))
+	mux.Delete("/delays", http.HandlerFunc(d.DeleteDelaysHandler))
+	mux.Get("/faults", http.HandlerFunc(d.FaultsHandler))
+	mux.Put("/faults", http.HandlerFunc(d.SetFaultsHandler))
+	mux.Delete("/faults", http.HandlerFunc(d.DeleteFaultsHandler))
+	mux.Get("/webhooks", http.HandlerFunc(d.WebhooksHandler))
+	mux.Put("/webhooks", http.HandlerFunc(d.SetWebhooksHandler))
+	mux.Delete("/webhooks", http.HandlerFunc(d.DeleteWebhooksHandler))
+	mux.Get("/middleware", http.HandlerFunc(d.MiddlewareChainHandler))
+	mux.Put("/middleware", http.HandlerFunc(d.SetMiddlewareChainHandler))
+	mux.Delete("/middleware", http.HandlerFunc(d.DeleteMiddlewareChainHandler))
+	mux.Get("/error-responses", http.HandlerFunc(d.ErrorResponsesHandler))
+	mux.Put("/error-responses", http.HandlerFunc(d.SetErrorResponsesHandler))
+	mux.Delete("/error-responses", http.HandlerFunc(d.DeleteErrorResponsesHandler))
+	mux.Get("/rate-limits", http.HandlerFunc(d.RateLimitsHandler))
+	mux.Put("/rate-limits", http.HandlerFunc(d.SetRateLimitsHandler))
+	mux.Delete("/rate-limits", http.HandlerFunc(d.DeleteRateLimitsHandler))
+	mux.Get("/sequences", http.HandlerFunc(d.SequencesHandler))
+	mux.Delete("/sequences", http.HandlerFunc(d.ResetSequencesHandler))
+	mux.Get("/api/state", http.HandlerFunc(d.ScenarioStateHandler))
+	mux.Put("/api/state", http.HandlerFunc(d.SetScenarioStateHandler))
+	mux.Delete("/api/state", http.HandlerFunc(d.ResetScenarioStateHandler))
+	mux.Get("/header-rules", http.HandlerFunc(d.HeaderRulesHandler))
+	mux.Put("/header-rules", http.HandlerFunc(d.SetHeaderRulesHandler))
+	mux.Delete("/header-rules", http.HandlerFunc(d.DeleteHeaderRulesHandler))
+
+	mux.Get("/header-matches", http.HandlerFunc(d.HeaderMatchesHandler))
+	mux.Put("/header-matches", http.HandlerFunc(d.SetHeaderMatchesHandler))
+	mux.Delete("/header-matches", http.HandlerFunc(d.DeleteHeaderMatchesHandler))
+
+	mux.Get("/body-matchers", http.HandlerFunc(d.BodyMatchersHandler))
+	mux.Put("/body-matchers", http.HandlerFunc(d.SetBodyMatchersHandler))
+	mux.Delete("/body-matchers", http.HandlerFunc(d.DeleteBodyMatchersHandler))
+
+	mux.Get("/passthrough", http.HandlerFunc(d.PassthroughHandler))
+	mux.Post("/passthrough", http.HandlerFunc(d.AddPassthroughHandler))
+	mux.Delete("/passthrough/:host", http.HandlerFunc(d.DeletePassthroughHandler))
+
+	mux.Get("/logging", http.HandlerFunc(d.LoggingHandler))
+	mux.Put("/logging", http.HandlerFunc(d.SetLoggingHandler))
+
+	mux.Get("/cert", http.HandlerFunc(d.CertHandler))
 
 	mux.Get("/state", http.HandlerFunc(d.CurrentStateHandler))
 	mux.Post("/state", http.HandlerFunc(d.StateHandler))
@@ -103,26 +219,60 @@ func getBoneRouter(d DBClient) *bone.Mux {
 	return mux
 }
 
-// AllRecordsHandler returns JSON content type http response
+// AllRecordsHandler returns JSON content type http response. Records are streamed one by one
+// so big caches are never loaded into memory at once. When "offset" or "limit" query parameters are
+// supplied - only requested page of records is returned. "destination", "path", "method", "from" and "to" query
+// parameters export only matching records (see PayloadFilter)
 func (d *DBClient) AllRecordsHandler(w http.ResponseWriter, req *http.Request) {
-	records, err := d.Cache.GetAllRequests()
+	query := req.URL.Query()
+	if query.Get("offset") != "" || query.Get("limit") != "" {
+		d.RecordsPageHandler(w, req)
+		return
+	}
 
-	if err == nil {
+	filter, err := NewPayloadFilter(query)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
+	if wantsYAML(req) {
+		records, _, err := d.recordsWhere(filter, 0, 0)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"Error": err.Error(),
+			}).Error("Failed to get data from cache!")
+			writeMessage(w, http.StatusInternalServerError, fmt.Sprintf("Failed to export records: %s", err.Error()))
+			return
+		}
+		writeYAML(w, recordedRequests{Meta: currentSimulationMeta(), Data: records})
+		return
+	}
 
-		var response recordedRequests
-		response.Data = records
-		b, err := json.Marshal(response)
+	written := 0
 
+	err = d.Cache.ForEachRequest(func(pl Payload) error {
+		if !filter.Match(&pl) {
+			return nil
+		}
+		b, err := json.Marshal(pl)
 		if err != nil {
-			log.Error(err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+
+		if written == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(simulationPrefix()))
 		} else {
-			w.Write(b)
-			return
+			w.Write([]byte(","))
 		}
-	} else {
+		written++
+
+		_, err = w.Write(b)
+		return err
+	})
+
+	if err != nil && written == 0 {
 		log.WithFields(log.Fields{
 			"Error": err.Error(),
 		}).Error("Failed to get data from cache!")
@@ -131,11 +281,158 @@ func (d *DBClient) AllRecordsHandler(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(500) // can't process this entity
 		return
 	}
+
+	if err != nil {
+		// response is already on its way, nothing left to do but to terminate it
+		log.WithFields(log.Fields{
+			"Error":   err.Error(),
+			"written": written,
+		}).Error("Failed to stream records, export is incomplete!")
+		return
+	}
+
+	if written == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(simulationPrefix()))
+	}
+	w.Write([]byte("]}"))
+}
+
+// RecordsPageHandler returns one page of captured requests, page is selected with "offset"
+// and "limit" query parameters. Records can be filtered just like in AllRecordsHandler, total is then
+// the number of matching records
+func (d *DBClient) RecordsPageHandler(w http.ResponseWriter, req *http.Request) {
+	var response recordsPage
+	response.Meta = currentSimulationMeta()
+	response.Limit = DefaultPageLimit
+
+	query := req.URL.Query()
+	filter, err := NewPayloadFilter(query)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if query.Get("offset") != "" {
+		response.Offset, err = strconv.Atoi(query.Get("offset"))
+	}
+	if err == nil && query.Get("limit") != "" {
+		response.Limit, err = strconv.Atoi(query.Get("limit"))
+	}
+	if err != nil || response.Offset < 0 || response.Limit <= 0 {
+		http.Error(w, "Bad page supplied, offset must be a non-negative and limit a positive number.", 400)
+		return
+	}
+
+	if filter.IsEmpty() {
+		response.Data, err = d.Cache.GetRequestsPage(response.Offset, response.Limit)
+		if err == nil {
+			response.Total, err = d.Cache.RecordsCount()
+		}
+	} else {
+		response.Data, response.Total, err = d.recordsWhere(filter, response.Offset, response.Limit)
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Error":  err.Error(),
+			"offset": response.Offset,
+			"limit":  response.Limit,
+		}).Error("Failed to get data from cache!")
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(500) // can't process this entity
+		return
+	}
+
+	if wantsYAML(req) {
+		writeYAML(w, response)
+		return
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SearchRecordsHandler - returns records matching "destination", "path", "method", "from", "to" and
+// "body-contains" query parameters (see PayloadFilter) with their total count. "offset" and "limit" select
+// one page of them, all of them are returned by default
+func (d *DBClient) SearchRecordsHandler(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	filter, err := NewPayloadFilter(query)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := recordsPage{Meta: currentSimulationMeta()}
+	if query.Get("offset") != "" {
+		response.Offset, err = strconv.Atoi(query.Get("offset"))
+	}
+	if err == nil && query.Get("limit") != "" {
+		response.Limit, err = strconv.Atoi(query.Get("limit"))
+	}
+	if err != nil || response.Offset < 0 || response.Limit < 0 {
+		writeMessage(w, http.StatusBadRequest, "Bad page supplied, offset and limit must be non-negative numbers.")
+		return
+	}
+
+	response.Data, response.Total, err = d.recordsWhere(filter, response.Offset, response.Limit)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get data from cache!")
+		writeMessage(w, http.StatusInternalServerError, fmt.Sprintf("Failed to search records: %s", err.Error()))
+		return
+	}
+
+	if wantsYAML(req) {
+		writeYAML(w, response)
+		return
+	}
+	b, err := json.Marshal(response)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// recordsWhere - returns records matching given filter, offset and limit select one page of them (zero limit
+// selects all of them). Total count of matching records is returned as well
+func (d *DBClient) recordsWhere(filter PayloadFilter, offset, limit int) (records []Payload, total int, err error) {
+	records = []Payload{}
+	err = d.Cache.ForEachRequest(func(pl Payload) error {
+		if !filter.Match(&pl) {
+			return nil
+		}
+		if total >= offset && (limit == 0 || len(records) < limit) {
+			records = append(records, pl)
+		}
+		total++
+		return nil
+	})
+	return records, total, err
 }
 
-// RecordsCount returns number of captured requests as a JSON payload
+// RecordsCount returns number of captured requests as a JSON payload, breakdown by destination host
+// is included when "by=destination" query parameter is supplied
 func (d *DBClient) RecordsCount(w http.ResponseWriter, req *http.Request) {
+	var destinations map[string]int
+
 	count, err := d.Cache.RecordsCount()
+	if err == nil && req.URL.Query().Get("by") == "destination" {
+		destinations, err = d.Cache.RecordsCountByDestination()
+	}
 
 	if err == nil {
 
@@ -143,6 +440,7 @@ func (d *DBClient) RecordsCount(w http.ResponseWriter, req *http.Request) {
 
 		var response recordsCount
 		response.Count = count
+		response.Destinations = destinations
 		b, err := json.Marshal(response)
 
 		if err != nil {
@@ -177,6 +475,7 @@ func (d *DBClient) StatsHandler(w http.ResponseWriter, req *http.Request) {
 	var sr statsResponse
 	sr.Stats = stats
 	sr.RecordsCount = count
+	sr.CacheStats = d.Cache.Stats()
 
 	w.Header().Set("Content-Type", "application/json")
 
@@ -234,6 +533,7 @@ func (d *DBClient) StatsWSHandler(w http.ResponseWriter, r *http.Request) {
 			var sr statsResponse
 			sr.Stats = stats
 			sr.RecordsCount = count
+			sr.CacheStats = d.Cache.Stats()
 
 			b, err := json.Marshal(sr)
 
@@ -250,11 +550,87 @@ func (d *DBClient) StatsWSHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// RecordsWSHandler - sends an event through the websocket whenever a record is captured, imported or deleted
+func (d *DBClient) RecordsWSHandler(w http.ResponseWriter, r *http.Request) {
+	if d.Events == nil {
+		http.Error(w, "Cache events are not enabled.", http.StatusNotImplemented)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe := d.Events.Subscribe()
+	defer unsubscribe()
+
+	// client messages are not expected, reading only detects closed connection
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				unsubscribe()
+				return
+			}
+		}
+	}()
+
+	for ev := range events {
+		if err := conn.WriteJSON(ev); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Debug("Got error when writing cache event...")
+			return
+		}
+	}
+}
+
+// TrafficWSHandler - sends summary of every request handled by the proxy through the websocket as it happens.
+// "bodies=true" query parameter adds headers and bodies, "destination" limits traffic to one destination
+func (d *DBClient) TrafficWSHandler(w http.ResponseWriter, r *http.Request) {
+	if d.Traffic == nil {
+		http.Error(w, "Traffic streaming is not enabled.", http.StatusNotImplemented)
+		return
+	}
+	query := r.URL.Query()
+	bodies := query.Get("bodies") == "true"
+	destination := query.Get("destination")
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe := d.Traffic.Subscribe(bodies, destination)
+	defer unsubscribe()
+
+	// client messages are not expected, reading only detects closed connection
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				unsubscribe()
+				return
+			}
+		}
+	}()
+
+	for ev := range events {
+		if err := conn.WriteJSON(ev); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Debug("Got error when writing traffic event...")
+			return
+		}
+	}
+}
+
 // ImportRecordsHandler - accepts JSON payload and saves it to cache
 func (d *DBClient) ImportRecordsHandler(w http.ResponseWriter, req *http.Request) {
 
-	var requests recordedRequests
-
 	defer req.Body.Close()
 	body, err := ioutil.ReadAll(req.Body)
 
@@ -271,20 +647,23 @@ func (d *DBClient) ImportRecordsHandler(w http.ResponseWriter, req *http.Request
 		return
 	}
 
-	err = json.Unmarshal(body, &requests)
+	payloads, err := decodeRequestSimulation(req, body)
 
 	if err != nil {
+		response.Message = err.Error()
 		w.WriteHeader(422) // can't process this entity
+		b, _ := json.Marshal(response)
+		w.Write(b)
 		return
 	}
 
-	err = d.ImportPayloads(requests.Data)
+	err = d.ImportPayloads(payloads)
 
 	if err != nil {
 		response.Message = err.Error()
 		w.WriteHeader(400)
 	} else {
-		response.Message = fmt.Sprintf("%d payloads import complete.", len(requests.Data))
+		response.Message = fmt.Sprintf("%d payloads import complete.", len(payloads))
 	}
 
 	b, err := json.Marshal(response)
@@ -292,96 +671,1616 @@ func (d *DBClient) ImportRecordsHandler(w http.ResponseWriter, req *http.Request
 
 }
 
-// DeleteAllRecordsHandler - deletes all captured requests
-func (d *DBClient) DeleteAllRecordsHandler(w http.ResponseWriter, req *http.Request) {
-	err := d.Cache.DeleteData()
+// decodeRequestSimulation - decodes simulation sent in request body, it's read as YAML when it's sent with YAML
+// content type or with "format=yaml" query parameter
+func decodeRequestSimulation(req *http.Request, body []byte) ([]Payload, error) {
+	if isYAMLContentType(req.Header.Get("Content-Type")) || req.URL.Query().Get("format") == "yaml" {
+		return decodeYAMLSimulation(body)
+	}
+	return decodeSimulation(body)
+}
 
-	var en Entry
-	en.ActionType = ActionTypeWipeDB
-	en.Message = "wipe"
-	en.Time = time.Now()
+// mergeResponse - outcome of merged import
+type mergeResponse struct {
+	Message string `json:"message"`
+	MergeResult
+}
 
-	if err := d.Hooks.Fire(ActionTypeWipeDB, &en); err != nil {
-		log.WithFields(log.Fields{
-			"error":      err.Error(),
-			"message":    en.Message,
-			"actionType": ActionTypeWipeDB,
-		}).Error("failed to fire hook")
+// MergeRecordsHandler - merges simulation into records that are already captured, "conflict" query parameter
+// decides what happens to records with the same ID as existing ones: skip, overwrite or error (default), which
+// imports nothing when there are conflicts
+func (d *DBClient) MergeRecordsHandler(w http.ResponseWriter, req *http.Request) {
+	resolution := req.URL.Query().Get("conflict")
+	if resolution == "" {
+		resolution = MergeError
+	}
+	if !IsMergeResolution(resolution) {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Unknown conflict resolution '%s', use skip, overwrite or error", resolution))
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	defer req.Body.Close()
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, "Failed to read request body.")
+		return
+	}
 
-	var response messageResponse
+	payloads, err := decodeRequestSimulation(req, body)
 	if err != nil {
-		if err.Error() == "bucket not found" {
-			response.Message = fmt.Sprintf("No records found")
-			w.WriteHeader(200)
-		} else {
-			response.Message = fmt.Sprintf("Something went wrong: %s", err.Error())
-			w.WriteHeader(500)
-		}
-	} else {
-		response.Message = "Proxy cache deleted successfuly"
-		w.WriteHeader(200)
+		writeMessage(w, 422, err.Error())
+		return
 	}
-	b, err := json.Marshal(response)
 
-	w.Write(b)
-	return
-}
+	var response mergeResponse
+	response.MergeResult, err = d.MergePayloads(payloads, resolution)
 
-// CurrentStateHandler returns current state
-func (d *DBClient) CurrentStateHandler(w http.ResponseWriter, req *http.Request) {
-	var resp stateRequest
-	resp.Mode = d.Cfg.GetMode()
-	resp.Destination = d.Cfg.Destination
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case err == ErrMergeConflict:
+		response.Message = fmt.Sprintf("%d payloads conflict with existing records, nothing imported.", len(response.Conflicts))
+		w.WriteHeader(http.StatusConflict)
+	case err != nil:
+		response.Message = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		response.Message = fmt.Sprintf("%d payloads merged: %d added, %d overwritten, %d skipped.",
+			len(payloads), response.Added, response.Overwritten, response.Skipped)
+	}
 
-	b, _ := json.Marshal(resp)
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	b, _ := json.Marshal(response)
 	w.Write(b)
 }
 
-// StateHandler handles current proxy state
-func (d *DBClient) StateHandler(w http.ResponseWriter, r *http.Request) {
-	var sr stateRequest
-
-	// this is mainly for testing, since when you create
-	if r.Body == nil {
-		r.Body = ioutil.NopCloser(bytes.NewBuffer([]byte("")))
+// DiffRecordsHandler - compares records in the cache with simulation sent in request body and reports added,
+// removed and changed records, nothing is imported
+func (d *DBClient) DiffRecordsHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, "Failed to read request body.")
+		return
 	}
 
-	defer r.Body.Close()
-	body, err := ioutil.ReadAll(r.Body)
+	payloads, err := decodeRequestSimulation(req, body)
+	if err != nil {
+		writeMessage(w, 422, err.Error())
+		return
+	}
 
+	diff, err := d.DiffCachedSimulation(payloads)
 	if err != nil {
-		// failed to read response body
 		log.WithFields(log.Fields{
 			"error": err.Error(),
-		}).Error("Could not read response body!")
-		http.Error(w, "Failed to read request body.", 400)
+		}).Error("Failed to get data from cache!")
+		writeMessage(w, http.StatusInternalServerError, fmt.Sprintf("Failed to compare records: %s", err.Error()))
 		return
 	}
 
-	err = json.Unmarshal(body, &sr)
+	b, err := json.Marshal(diff)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
 
+// ExportHARHandler - returns all records as HTTP Archive, so captured traffic can be inspected in browser devtools
+func (d *DBClient) ExportHARHandler(w http.ResponseWriter, req *http.Request) {
+	har, err := d.ExportHAR()
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-		w.WriteHeader(400) // can't process this entity
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get data from cache!")
+		writeMessage(w, http.StatusInternalServerError, fmt.Sprintf("Failed to export records: %s", err.Error()))
 		return
 	}
 
-	availableModes := map[string]bool{
-		"virtualize": true,
-		"capture":    true,
-		"modify":     true,
-		"synthesize": true,
+	b, err := json.Marshal(har)
+	if err != nil {
+		writeMessage(w, http.StatusInternalServerError, fmt.Sprintf("Failed to export records: %s", err.Error()))
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
 
-	if !availableModes[sr.Mode] {
+// ImportHARHandler - accepts HTTP Archive and imports its entries as records
+func (d *DBClient) ImportHARHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if err := d.ImportHAR(req.Body); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeMessage(w, http.StatusOK, "HAR import complete.")
+}
+
+// ExportOpenAPIHandler - returns OpenAPI 3 document inferred from records, "destination" query parameter limits it
+// to one API
+func (d *DBClient) ExportOpenAPIHandler(w http.ResponseWriter, req *http.Request) {
+	doc, err := d.ExportOpenAPI(req.URL.Query().Get("destination"))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get data from cache!")
+		writeMessage(w, http.StatusInternalServerError, fmt.Sprintf("Failed to export records: %s", err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		writeMessage(w, http.StatusInternalServerError, fmt.Sprintf("Failed to export records: %s", err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// ImportOpenAPIHandler - accepts OpenAPI document and imports stub record for every operation, "destination" query
+// parameter overrides server of the document
+func (d *DBClient) ImportOpenAPIHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if err := d.ImportOpenAPI(req.Body, req.URL.Query().Get("destination")); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeMessage(w, http.StatusOK, "OpenAPI import complete.")
+}
+
+// ImportWireMockHandler - accepts WireMock mapping file and imports its mappings, "destination" query parameter sets
+// host of the records. Mappings with body files are skipped, import the WireMock root directory on startup instead
+func (d *DBClient) ImportWireMockHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if err := d.ImportWireMock(req.Body, "", req.URL.Query().Get("destination")); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeMessage(w, http.StatusOK, "WireMock import complete.")
+}
+
+// ImportPcapHandler - accepts pcap or pcapng capture and imports HTTP exchanges reassembled from it
+func (d *DBClient) ImportPcapHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if err := d.ImportPcap(req.Body); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeMessage(w, http.StatusOK, "Capture import complete.")
+}
+
+// ImportCurlHandler - accepts curl commands and imports them as stub records, "response" query parameter selects
+// their responses: empty (default) or template
+func (d *DBClient) ImportCurlHandler(w http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	if err := d.ImportCurl(req.Body, req.URL.Query().Get("response")); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeMessage(w, http.StatusOK, "curl import complete.")
+}
+
+// DeleteAllRecordsHandler - deletes all captured requests, when "destination", "path", "method", "from" or "to"
+// query parameters are supplied - only matching requests are deleted
+func (d *DBClient) DeleteAllRecordsHandler(w http.ResponseWriter, req *http.Request) {
+	filter, err := NewPayloadFilter(req.URL.Query())
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !filter.IsEmpty() {
+		d.deleteRecordsWhere(w, filter)
+		return
+	}
+
+	err = d.Cache.DeleteData()
+	d.recordsChanged()
+
+	var en Entry
+	en.ActionType = ActionTypeWipeDB
+	en.Message = "wipe"
+	en.Time = time.Now()
+
+	if err := d.Hooks.Fire(ActionTypeWipeDB, &en); err != nil {
+		log.WithFields(log.Fields{
+			"error":      err.Error(),
+			"message":    en.Message,
+			"actionType": ActionTypeWipeDB,
+		}).Error("failed to fire hook")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var response messageResponse
+	if err != nil {
+		if err.Error() == "bucket not found" {
+			response.Message = fmt.Sprintf("No records found")
+			w.WriteHeader(200)
+		} else if err == ErrReadOnly {
+			response.Message = "Cache is read-only, records can't be deleted"
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			response.Message = fmt.Sprintf("Something went wrong: %s", err.Error())
+			w.WriteHeader(500)
+		}
+	} else {
+		response.Message = "Proxy cache deleted successfuly"
+		w.WriteHeader(200)
+	}
+	b, err := json.Marshal(response)
+
+	w.Write(b)
+	return
+}
+
+// deleteRecordsWhere - deletes captured requests that match given filter
+func (d *DBClient) deleteRecordsWhere(w http.ResponseWriter, filter PayloadFilter) {
+	deleted, err := d.Cache.DeleteDataWhere(filter)
+	d.recordsChanged()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var response messageResponse
+	if err == ErrReadOnly {
+		response.Message = "Cache is read-only, records can't be deleted"
+		w.WriteHeader(http.StatusForbidden)
+	} else if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"destination": filter.Destination,
+			"path":        filter.Path,
+			"method":      filter.Method,
+		}).Error("Failed to delete records")
+
+		response.Message = fmt.Sprintf("Something went wrong: %s", err.Error())
+		w.WriteHeader(500)
+	} else {
+		response.Message = fmt.Sprintf("%d records deleted successfuly", deleted)
+		w.WriteHeader(200)
+
+		var en Entry
+		en.ActionType = ActionTypeRecordDeleted
+		en.Message = fmt.Sprintf("%d records deleted, destination: '%s', path: '%s', method: '%s'",
+			deleted, filter.Destination, filter.Path, filter.Method)
+		en.Time = time.Now()
+
+		if err := d.Hooks.Fire(ActionTypeRecordDeleted, &en); err != nil {
+			log.WithFields(log.Fields{
+				"error":      err.Error(),
+				"message":    en.Message,
+				"actionType": ActionTypeRecordDeleted,
+			}).Error("failed to fire hook")
+		}
+	}
+
+	b, err := json.Marshal(response)
+	w.Write(b)
+}
+
+// RecordHandler - returns single captured request, record is identified by its ID (request hash)
+func (d *DBClient) RecordHandler(w http.ResponseWriter, req *http.Request) {
+	id := bone.GetValue(req, "id")
+
+	bts, err := d.Cache.Get([]byte(id))
+	if err != nil {
+		writeMessage(w, http.StatusNotFound, fmt.Sprintf("Record %s not found", id))
+		return
+	}
+	pl, err := decodePayload(bts)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"id":    id,
+		}).Error("Failed to decode payload")
+		writeMessage(w, http.StatusInternalServerError, fmt.Sprintf("Failed to decode record %s: %s", id, err.Error()))
+		return
+	}
+
+	if wantsYAML(req) {
+		writeYAML(w, pl)
+		return
+	}
+	b, err := json.Marshal(pl)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// DeleteRecordHandler - deletes single captured request, record is identified by its ID (request hash)
+func (d *DBClient) DeleteRecordHandler(w http.ResponseWriter, req *http.Request) {
+	id := bone.GetValue(req, "id")
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var response messageResponse
+
+	err := d.Cache.DeleteKey([]byte(id))
+	d.recordsChanged()
+	if err == ErrReadOnly {
+		response.Message = "Cache is read-only, records can't be deleted"
+		w.WriteHeader(http.StatusForbidden)
+	} else if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"id":    id,
+		}).Warn("Failed to delete record")
+
+		response.Message = fmt.Sprintf("Record %s not found", id)
+		w.WriteHeader(404)
+	} else {
+		response.Message = fmt.Sprintf("Record %s deleted successfuly", id)
+		w.WriteHeader(200)
+
+		var en Entry
+		en.ActionType = ActionTypeRecordDeleted
+		en.Message = "deleted"
+		en.Time = time.Now()
+		en.Data = []byte(id)
+
+		if err := d.Hooks.Fire(ActionTypeRecordDeleted, &en); err != nil {
+			log.WithFields(log.Fields{
+				"error":      err.Error(),
+				"message":    en.Message,
+				"actionType": ActionTypeRecordDeleted,
+			}).Error("failed to fire hook")
+		}
+	}
+
+	b, err := json.Marshal(response)
+	w.Write(b)
+}
+
+// BackupHandler - streams consistent snapshot of the database, Hoverfly keeps serving traffic while
+// backup is being downloaded
+func (d *DBClient) BackupHandler(w http.ResponseWriter, req *http.Request) {
+	backuper, ok := d.Cache.(BackupWriter)
+	if !ok {
+		http.Error(w, "Current cache backend does not support backups.", http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, DefaultDatabaseName))
+
+	n, err := backuper.WriteBackup(w)
+	if err != nil {
+		// response is already on its way so error can only be logged
+		log.WithFields(log.Fields{
+			"error":   err.Error(),
+			"written": n,
+		}).Error("Failed to write database backup")
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"size": n,
+	}).Info("Database backup written")
+}
+
+// CompactHandler - compacts database so disk space freed by deleted records is returned, cache
+// operations wait until compaction is finished
+func (d *DBClient) CompactHandler(w http.ResponseWriter, req *http.Request) {
+	compacter, ok := d.Cache.(Compacter)
+	if !ok {
+		http.Error(w, "Current cache backend does not support compaction.", http.StatusNotImplemented)
+		return
+	}
+
+	result, err := compacter.Compact()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to compact database")
+		writeMessage(w, 500, fmt.Sprintf("Failed to compact database: %s", err.Error()))
+		return
+	}
+
+	b, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+type diffResponse struct {
+	Data []ResponseDiff `json:"data"`
+}
+
+// DiffHandler - returns discrepancies between live and captured responses found in diff mode
+func (d *DBClient) DiffHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(diffResponse{Data: d.Diffs.Entries()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal diff report")
+		http.Error(w, "Failed to marshal diff report.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// DeleteDiffHandler - clears diff report
+func (d *DBClient) DeleteDiffHandler(w http.ResponseWriter, req *http.Request) {
+	d.Diffs.Clear()
+	writeMessage(w, http.StatusOK, "Diff report cleared")
+}
+
+type journalResponse struct {
+	Data   []JournalEntry `json:"data"`
+	Offset int            `json:"offset"`
+	Limit  int            `json:"limit"`
+	Total  int            `json:"total"`
+}
+
+// journal - returns request journal or writes error when it's disabled
+func (d *DBClient) journal(w http.ResponseWriter) (*Journal, bool) {
+	if d.Journal == nil {
+		writeMessage(w, http.StatusNotImplemented, "Request journal is disabled.")
+		return nil, false
+	}
+	return d.Journal, true
+}
+
+// JournalHandler - returns page of handled requests satisfying filter given in query, the oldest first
+func (d *DBClient) JournalHandler(w http.ResponseWriter, req *http.Request) {
+	journal, ok := d.journal(w)
+	if !ok {
+		return
+	}
+	query := req.URL.Query()
+	filter, err := NewJournalFilter(query)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var response journalResponse
+	if query.Get("offset") != "" {
+		response.Offset, err = strconv.Atoi(query.Get("offset"))
+	}
+	if err == nil && query.Get("limit") != "" {
+		response.Limit, err = strconv.Atoi(query.Get("limit"))
+	}
+	if err != nil || response.Offset < 0 || response.Limit < 0 {
+		writeMessage(w, http.StatusBadRequest, "Bad page supplied, offset and limit must be non-negative numbers.")
+		return
+	}
+
+	response.Data, response.Total = journal.Entries(filter, response.Offset, response.Limit)
+	b, err := json.Marshal(response)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal request journal")
+		http.Error(w, "Failed to marshal request journal.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// DeleteJournalHandler - clears request journal
+func (d *DBClient) DeleteJournalHandler(w http.ResponseWriter, req *http.Request) {
+	journal, ok := d.journal(w)
+	if !ok {
+		return
+	}
+	journal.Clear()
+	writeMessage(w, http.StatusOK, "Request journal cleared")
+}
+
+// VerifyJournalHandler - counts journaled requests matching verification sent in request body and checks the count
+// against expected one, unmet expectation is reported in the result rather than by status code
+func (d *DBClient) VerifyJournalHandler(w http.ResponseWriter, req *http.Request) {
+	journal, ok := d.journal(w)
+	if !ok {
+		return
+	}
+
+	var verification RequestVerification
+	body, err := ioutil.ReadAll(req.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &verification)
+	}
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Bad verification supplied: %s", err.Error()))
+		return
+	}
+
+	result, err := journal.Verify(verification)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Bad verification supplied: %s", err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal verification result")
+		http.Error(w, "Failed to marshal verification result.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// DebugMatchHandler - explains how request described in body would be matched and how the nearest records differ
+// from it, no response is served. Number of nearest records can be given in 'nearest' query parameter
+func (d *DBClient) DebugMatchHandler(w http.ResponseWriter, req *http.Request) {
+	nearest := DefaultNearestRecords
+	if n := req.URL.Query().Get("nearest"); n != "" {
+		var err error
+		if nearest, err = strconv.Atoi(n); err != nil || nearest < 0 {
+			writeMessage(w, http.StatusBadRequest, "Bad 'nearest' supplied, it must be a non-negative number.")
+			return
+		}
+	}
+
+	var request RequestDetails
+	body, err := ioutil.ReadAll(req.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &request)
+	}
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Bad request description supplied: %s", err.Error()))
+		return
+	}
+	trace, err := d.traceMatch(request, nearest)
+	if err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Bad request description supplied: %s", err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(trace)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal match trace")
+		http.Error(w, "Failed to marshal match trace.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+type delaysRequest struct {
+	Data []ResponseDelay `json:"data"`
+}
+
+// DelaysHandler - returns delays applied to simulated responses
+func (d *DBClient) DelaysHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(delaysRequest{Data: d.Delays.Get()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal delays")
+		http.Error(w, "Failed to marshal delays.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SetDelaysHandler - replaces delays applied to simulated responses, current delays are returned
+func (d *DBClient) SetDelaysHandler(w http.ResponseWriter, req *http.Request) {
+	var dr delaysRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&dr); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+
+	if err := d.Delays.Set(dr.Data); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"delays": len(dr.Data),
+	}).Info("Response delays set")
+
+	d.DelaysHandler(w, req)
+}
+
+// DeleteDelaysHandler - removes all delays
+func (d *DBClient) DeleteDelaysHandler(w http.ResponseWriter, req *http.Request) {
+	d.Delays.Clear()
+	writeMessage(w, http.StatusOK, "Delays removed")
+}
+
+type webhooksRequest struct {
+	Data []Webhook `json:"data"`
+}
+
+// WebhooksHandler - returns callbacks fired after simulated responses
+func (d *DBClient) WebhooksHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(webhooksRequest{Data: d.Webhooks.Get()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal webhooks")
+		http.Error(w, "Failed to marshal webhooks.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SetWebhooksHandler - replaces callbacks fired after simulated responses, current webhooks are returned
+func (d *DBClient) SetWebhooksHandler(w http.ResponseWriter, req *http.Request) {
+	var wr webhooksRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&wr); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+
+	if err := d.Webhooks.Set(wr.Data); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"webhooks": len(wr.Data),
+	}).Info("Webhooks set")
+
+	d.WebhooksHandler(w, req)
+}
+
+// DeleteWebhooksHandler - removes all webhooks
+func (d *DBClient) DeleteWebhooksHandler(w http.ResponseWriter, req *http.Request) {
+	d.Webhooks.Clear()
+	writeMessage(w, http.StatusOK, "Webhooks removed")
+}
+
+type middlewareChainRequest struct {
+	Data []MiddlewareStage `json:"data"`
+}
+
+// MiddlewareChainHandler - returns middleware stages set through admin API
+func (d *DBClient) MiddlewareChainHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(middlewareChainRequest{Data: d.MiddlewareChain.Get()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal middleware chain")
+		http.Error(w, "Failed to marshal middleware chain.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SetMiddlewareChainHandler - replaces middleware stages payloads go through, current stages are returned
+func (d *DBClient) SetMiddlewareChainHandler(w http.ResponseWriter, req *http.Request) {
+	var mr middlewareChainRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&mr); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+
+	if err := d.MiddlewareChain.Set(mr.Data); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"stages": len(mr.Data),
+	}).Info("Middleware chain set")
+
+	d.MiddlewareChainHandler(w, req)
+}
+
+// DeleteMiddlewareChainHandler - removes all middleware stages, -middleware is used again
+func (d *DBClient) DeleteMiddlewareChainHandler(w http.ResponseWriter, req *http.Request) {
+	d.MiddlewareChain.Clear()
+	writeMessage(w, http.StatusOK, "Middleware chain removed")
+}
+
+type loggingRequest struct {
+	Levels map[string]string `json:"levels"`
+	Sinks  []LogSinkConfig   `json:"sinks,omitempty"`
+}
+
+// LoggingHandler - returns log levels of subsystems and sinks logs are written to
+func (d *DBClient) LoggingHandler(w http.ResponseWriter, req *http.Request) {
+	if d.Logging == nil {
+		writeMessage(w, http.StatusNotImplemented, "Log sinks are not configured.")
+		return
+	}
+
+	b, err := json.Marshal(loggingRequest{Levels: d.Logging.SubsystemLevels(), Sinks: d.Logging.Sinks()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal logging configuration")
+		http.Error(w, "Failed to marshal logging configuration.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SetLoggingHandler - changes log levels of given subsystems, the other ones keep their levels. Sinks can only be
+// configured at startup
+func (d *DBClient) SetLoggingHandler(w http.ResponseWriter, req *http.Request) {
+	if d.Logging == nil {
+		writeMessage(w, http.StatusNotImplemented, "Log sinks are not configured.")
+		return
+	}
+
+	var lr loggingRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&lr); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+	if len(lr.Sinks) > 0 {
+		writeMessage(w, http.StatusBadRequest, "Log sinks can only be configured at startup.")
+		return
+	}
+
+	if err := d.Logging.SetLevels(lr.Levels); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"levels": lr.Levels,
+	}).Info("Log levels changed")
+
+	d.LoggingHandler(w, req)
+}
+
+type faultsRequest struct {
+	Data []Fault `json:"data"`
+}
+
+// FaultsHandler - returns faults injected instead of responses
+func (d *DBClient) FaultsHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(faultsRequest{Data: d.Faults.Get()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal faults")
+		http.Error(w, "Failed to marshal faults.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SetFaultsHandler - replaces faults injected instead of responses, current faults are returned
+func (d *DBClient) SetFaultsHandler(w http.ResponseWriter, req *http.Request) {
+	var fr faultsRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&fr); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+
+	if err := d.Faults.Set(fr.Data); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"faults": len(fr.Data),
+	}).Info("Faults set")
+
+	d.FaultsHandler(w, req)
+}
+
+// DeleteFaultsHandler - removes all faults
+func (d *DBClient) DeleteFaultsHandler(w http.ResponseWriter, req *http.Request) {
+	d.Faults.Clear()
+	writeMessage(w, http.StatusOK, "Faults removed")
+}
+
+type errorResponsesRequest struct {
+	// Seed - makes requests getting error responses the same on every run, random when not set
+	Seed int64           `json:"seed,omitempty"`
+	Data []ErrorResponse `json:"data"`
+}
+
+// ErrorResponsesHandler - returns error responses returned to some requests
+func (d *DBClient) ErrorResponsesHandler(w http.ResponseWriter, req *http.Request) {
+	responses, seed := d.ErrorResponses.Get()
+	b, err := json.Marshal(errorResponsesRequest{Seed: seed, Data: responses})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal error responses")
+		http.Error(w, "Failed to marshal error responses.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SetErrorResponsesHandler - replaces error responses, current error responses are returned
+func (d *DBClient) SetErrorResponsesHandler(w http.ResponseWriter, req *http.Request) {
+	var er errorResponsesRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&er); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+
+	if err := d.ErrorResponses.Set(er.Data, er.Seed); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"errorResponses": len(er.Data),
+		"seed":           er.Seed,
+	}).Info("Error responses set")
+
+	d.ErrorResponsesHandler(w, req)
+}
+
+// DeleteErrorResponsesHandler - removes all error responses
+func (d *DBClient) DeleteErrorResponsesHandler(w http.ResponseWriter, req *http.Request) {
+	d.ErrorResponses.Clear()
+	writeMessage(w, http.StatusOK, "Error responses removed")
+}
+
+type rateLimitsRequest struct {
+	Data []RateLimit `json:"data"`
+}
+
+// RateLimitsHandler - returns rate limits of destinations
+func (d *DBClient) RateLimitsHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(rateLimitsRequest{Data: d.RateLimits.Get()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal rate limits")
+		http.Error(w, "Failed to marshal rate limits.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SetRateLimitsHandler - replaces rate limits of destinations, current rate limits are returned
+func (d *DBClient) SetRateLimitsHandler(w http.ResponseWriter, req *http.Request) {
+	var rr rateLimitsRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&rr); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+
+	if err := d.RateLimits.Set(rr.Data); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"rateLimits": len(rr.Data),
+	}).Info("Rate limits set")
+
+	d.RateLimitsHandler(w, req)
+}
+
+// DeleteRateLimitsHandler - removes all rate limits
+func (d *DBClient) DeleteRateLimitsHandler(w http.ResponseWriter, req *http.Request) {
+	d.RateLimits.Clear()
+	writeMessage(w, http.StatusOK, "Rate limits removed")
+}
+
+type sequencesResponse struct {
+	// Calls - number of calls made to requests with response sequences, by request key
+	Calls map[string]int `json:"calls"`
+}
+
+// SequencesHandler - returns number of calls made to requests with response sequences
+func (d *DBClient) SequencesHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(sequencesResponse{Calls: d.Sequences.Get()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal sequences")
+		http.Error(w, "Failed to marshal sequences.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// ResetSequencesHandler - starts all response sequences from their first response again
+func (d *DBClient) ResetSequencesHandler(w http.ResponseWriter, req *http.Request) {
+	d.Sequences.Reset()
+	log.Info("Response sequences reset")
+	writeMessage(w, http.StatusOK, "Sequences reset")
+}
+
+type scenarioStateRequest struct {
+	State map[string]string `json:"state"`
+}
+
+// ScenarioStateHandler - returns current scenario state
+func (d *DBClient) ScenarioStateHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(scenarioStateRequest{State: d.ScenarioState.Get()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal scenario state")
+		http.Error(w, "Failed to marshal scenario state.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SetScenarioStateHandler - replaces scenario state, i.e. to start simulation in the middle of a flow
+func (d *DBClient) SetScenarioStateHandler(w http.ResponseWriter, req *http.Request) {
+	var sr scenarioStateRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&sr); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+
+	d.ScenarioState.Set(sr.State)
+	log.WithFields(log.Fields{
+		"state": sr.State,
+	}).Info("Scenario state set")
+
+	d.ScenarioStateHandler(w, req)
+}
+
+// ResetScenarioStateHandler - removes all scenario state
+func (d *DBClient) ResetScenarioStateHandler(w http.ResponseWriter, req *http.Request) {
+	d.ScenarioState.Reset()
+	log.Info("Scenario state reset")
+	writeMessage(w, http.StatusOK, "Scenario state reset")
+}
+
+type headerRulesRequest struct {
+	Data []HeaderRule `json:"data"`
+}
+
+// HeaderRulesHandler - returns rules changing captured and replayed headers
+func (d *DBClient) HeaderRulesHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(headerRulesRequest{Data: d.HeaderRules.Get()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal header rules")
+		http.Error(w, "Failed to marshal header rules.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SetHeaderRulesHandler - replaces header rules, current rules are returned
+func (d *DBClient) SetHeaderRulesHandler(w http.ResponseWriter, req *http.Request) {
+	var hr headerRulesRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&hr); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+
+	if err := d.HeaderRules.Set(hr.Data); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"headerRules": len(hr.Data),
+	}).Info("Header rules set")
+
+	d.HeaderRulesHandler(w, req)
+}
+
+// DeleteHeaderRulesHandler - removes all header rules
+func (d *DBClient) DeleteHeaderRulesHandler(w http.ResponseWriter, req *http.Request) {
+	d.HeaderRules.Clear()
+	writeMessage(w, http.StatusOK, "Header rules removed")
+}
+
+type headerMatchesRequest struct {
+	Data []HeaderMatch `json:"data"`
+}
+
+// HeaderMatchesHandler - returns request headers that are part of request fingerprint
+func (d *DBClient) HeaderMatchesHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(headerMatchesRequest{Data: d.HeaderMatches.Get()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal header matches")
+		http.Error(w, "Failed to marshal header matches.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SetHeaderMatchesHandler - replaces matched request headers, current header matches are returned. Records have to
+// be captured or imported again to be found with new headers
+func (d *DBClient) SetHeaderMatchesHandler(w http.ResponseWriter, req *http.Request) {
+	var hm headerMatchesRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&hm); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+
+	if err := d.HeaderMatches.Set(hm.Data); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"headerMatches": len(hm.Data),
+	}).Info("Header matches set")
+
+	d.HeaderMatchesHandler(w, req)
+}
+
+// DeleteHeaderMatchesHandler - removes all header matches, only method, destination, path, query and body are
+// matched again
+func (d *DBClient) DeleteHeaderMatchesHandler(w http.ResponseWriter, req *http.Request) {
+	d.HeaderMatches.Clear()
+	writeMessage(w, http.StatusOK, "Header matches removed")
+}
+
+type bodyMatchersRequest struct {
+	Data []BodyMatcher `json:"data"`
+}
+
+// BodyMatchersHandler - returns body matching modes of requests
+func (d *DBClient) BodyMatchersHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := json.Marshal(bodyMatchersRequest{Data: d.BodyMatchers.Get()})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal body matchers")
+		http.Error(w, "Failed to marshal body matchers.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// SetBodyMatchersHandler - replaces body matchers, current matchers are returned. Records have to be captured or
+// imported again to be found with new modes
+func (d *DBClient) SetBodyMatchersHandler(w http.ResponseWriter, req *http.Request) {
+	var bm bodyMatchersRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&bm); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+
+	if err := d.BodyMatchers.Set(bm.Data); err != nil {
+		writeMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"bodyMatchers": len(bm.Data),
+	}).Info("Body matchers set")
+
+	d.BodyMatchersHandler(w, req)
+}
+
+// DeleteBodyMatchersHandler - removes all body matchers, bodies are matched in global mode again
+func (d *DBClient) DeleteBodyMatchersHandler(w http.ResponseWriter, req *http.Request) {
+	d.BodyMatchers.Clear()
+	writeMessage(w, http.StatusOK, "Body matchers removed")
+}
+
+type namespacesResponse struct {
+	Current    string   `json:"current"`
+	Namespaces []string `json:"namespaces"`
+}
+
+type namespaceRequest struct {
+	Name string `json:"name"`
+}
+
+// namespacer - returns namespace capable cache or writes "not implemented" response
+func (d *DBClient) namespacer(w http.ResponseWriter) (Namespacer, bool) {
+	ns, ok := d.Cache.(Namespacer)
+	if !ok {
+		http.Error(w, "Current cache backend does not support namespaces.", http.StatusNotImplemented)
+	}
+	return ns, ok
+}
+
+// readNamespaceRequest - reads namespace name from request body, writes bad request response on failure
+func readNamespaceRequest(w http.ResponseWriter, req *http.Request) (string, bool) {
+	var nr namespaceRequest
+
+	if req.Body == nil {
+		http.Error(w, "Namespace name not supplied.", 400)
+		return "", false
+	}
+	defer req.Body.Close()
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil || json.Unmarshal(body, &nr) != nil || nr.Name == "" {
+		http.Error(w, "Namespace name not supplied.", 400)
+		return "", false
+	}
+	return nr.Name, true
+}
+
+// writeMessage - writes JSON message response with given status code
+func writeMessage(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	b, _ := json.Marshal(messageResponse{Message: message})
+	w.Write(b)
+}
+
+// NamespacesHandler - returns current namespace and names of all namespaces
+func (d *DBClient) NamespacesHandler(w http.ResponseWriter, req *http.Request) {
+	ns, ok := d.namespacer(w)
+	if !ok {
+		return
+	}
+
+	names, err := ns.ListNamespaces()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to list namespaces")
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	b, _ := json.Marshal(namespacesResponse{Current: ns.CurrentNamespace(), Namespaces: names})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// CreateNamespaceHandler - creates new empty namespace, name is supplied in JSON body: {"name": "..."}
+func (d *DBClient) CreateNamespaceHandler(w http.ResponseWriter, req *http.Request) {
+	ns, ok := d.namespacer(w)
+	if !ok {
+		return
+	}
+	name, ok := readNamespaceRequest(w, req)
+	if !ok {
+		return
+	}
+
+	if err := ns.CreateNamespace(name); err != nil {
+		log.WithFields(log.Fields{
+			"error":     err.Error(),
+			"namespace": name,
+		}).Warn("Failed to create namespace")
+		writeMessage(w, 409, err.Error())
+		return
+	}
+
+	writeMessage(w, 201, fmt.Sprintf("Namespace %s created", name))
+}
+
+// SwitchNamespaceHandler - makes namespace supplied in JSON body current
+func (d *DBClient) SwitchNamespaceHandler(w http.ResponseWriter, req *http.Request) {
+	ns, ok := d.namespacer(w)
+	if !ok {
+		return
+	}
+	name, ok := readNamespaceRequest(w, req)
+	if !ok {
+		return
+	}
+
+	if err := ns.SwitchNamespace(name); err != nil {
+		writeMessage(w, 404, err.Error())
+		return
+	}
+	d.recordsChanged()
+
+	writeMessage(w, 200, fmt.Sprintf("Switched to namespace %s", name))
+}
+
+// DeleteNamespaceHandler - deletes namespace with all its records, current namespace can't be deleted
+func (d *DBClient) DeleteNamespaceHandler(w http.ResponseWriter, req *http.Request) {
+	ns, ok := d.namespacer(w)
+	if !ok {
+		return
+	}
+	name := bone.GetValue(req, "name")
+
+	if err := ns.DeleteNamespace(name); err != nil {
+		log.WithFields(log.Fields{
+			"error":     err.Error(),
+			"namespace": name,
+		}).Warn("Failed to delete namespace")
+		writeMessage(w, 409, err.Error())
+		return
+	}
+
+	writeMessage(w, 200, fmt.Sprintf("Namespace %s deleted", name))
+}
+
+type credentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type tokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Role      string    `json:"role"`
+}
+
+type userResponse struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type usersResponse struct {
+	Users []userResponse `json:"users"`
+}
+
+// authStore - returns users store, writes not implemented response when admin authentication isn't configured
+func (d *DBClient) authStore(w http.ResponseWriter) (*AuthStore, bool) {
+	if d.Auth == nil {
+		http.Error(w, "Admin authentication is not configured.", http.StatusNotImplemented)
+		return nil, false
+	}
+	return d.Auth, true
+}
+
+// readCredentialsRequest - reads username and password from request body, writes bad request response on failure
+func readCredentialsRequest(w http.ResponseWriter, req *http.Request) (credentialsRequest, bool) {
+	var cr credentialsRequest
+
+	if req.Body == nil {
+		http.Error(w, "Username and password not supplied.", 400)
+		return cr, false
+	}
+	defer req.Body.Close()
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil || json.Unmarshal(body, &cr) != nil || cr.Username == "" || cr.Password == "" {
+		http.Error(w, "Username and password not supplied.", 400)
+		return cr, false
+	}
+	return cr, true
+}
+
+// TokenAuthHandler - issues token for username and password supplied in JSON body, the token is sent in
+// "Authorization: Bearer <token>" header of admin requests
+func (d *DBClient) TokenAuthHandler(w http.ResponseWriter, req *http.Request) {
+	auth, ok := d.authStore(w)
+	if !ok {
+		return
+	}
+	cr, ok := readCredentialsRequest(w, req)
+	if !ok {
+		return
+	}
+
+	user, err := auth.Authenticate(cr.Username, cr.Password)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"remoteAddr": req.RemoteAddr,
+			"username":   cr.Username,
+		}).Warn("Admin login failed")
+		writeMessage(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	token, expiresAt, err := auth.IssueToken(cr.Username)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to issue token")
+		writeMessage(w, 500, err.Error())
+		return
+	}
+
+	b, _ := json.Marshal(tokenResponse{Token: token, ExpiresAt: expiresAt.UTC(), Role: userRole(user)})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// userRole - returns role of the user, users without role are admins
+func userRole(user *User) string {
+	if user.IsAdmin() {
+		return RoleAdmin
+	}
+	return user.Role
+}
+
+// UsersHandler - returns usernames and roles of admin interface users
+func (d *DBClient) UsersHandler(w http.ResponseWriter, req *http.Request) {
+	auth, ok := d.authStore(w)
+	if !ok {
+		return
+	}
+
+	users, err := auth.GetAllUsers()
+	if err != nil {
+		writeMessage(w, 500, err.Error())
+		return
+	}
+
+	resp := usersResponse{Users: []userResponse{}}
+	for i := range users {
+		resp.Users = append(resp.Users, userResponse{Username: users[i].Username, Role: userRole(&users[i])})
+	}
+	b, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// AddUserHandler - adds user supplied in JSON body, password and role of existing user are changed. Users are
+// admins unless "read-only" role is supplied, the last admin can't become read-only
+func (d *DBClient) AddUserHandler(w http.ResponseWriter, req *http.Request) {
+	auth, ok := d.authStore(w)
+	if !ok {
+		return
+	}
+	cr, ok := readCredentialsRequest(w, req)
+	if !ok {
+		return
+	}
+	if cr.Role == "" {
+		cr.Role = RoleAdmin
+	}
+
+	if cr.Role != RoleAdmin && d.lastAdmin(w, auth, cr.Username) {
+		return
+	}
+
+	if err := auth.AddUser(cr.Username, cr.Password, cr.Role); err != nil {
+		writeMessage(w, 400, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"username": cr.Username,
+		"role":     cr.Role,
+	}).Info("Admin user saved")
+	writeMessage(w, 201, fmt.Sprintf("User %s saved", cr.Username))
+}
+
+// lastAdmin - checks whether user is the last admin, who can't be deleted or become read-only so admin interface
+// doesn't get locked, writes conflict response when it is
+func (d *DBClient) lastAdmin(w http.ResponseWriter, auth *AuthStore, username string) bool {
+	user, err := auth.GetUser(username)
+	if err == ErrUserNotFound || (err == nil && !user.IsAdmin()) {
+		return false
+	}
+	count, err := auth.AdminsCount()
+	if err != nil {
+		writeMessage(w, 500, err.Error())
+		return true
+	}
+	if count <= 1 {
+		writeMessage(w, 409, "The last admin can't be deleted or become read-only")
+		return true
+	}
+	return false
+}
+
+// DeleteUserHandler - deletes user, the last admin can't be deleted
+func (d *DBClient) DeleteUserHandler(w http.ResponseWriter, req *http.Request) {
+	auth, ok := d.authStore(w)
+	if !ok {
+		return
+	}
+	username := bone.GetValue(req, "username")
+
+	if d.lastAdmin(w, auth, username) {
+		return
+	}
+
+	if err := auth.DeleteUser(username); err != nil {
+		writeMessage(w, 404, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"username": username,
+	}).Info("Admin user deleted")
+	writeMessage(w, 200, fmt.Sprintf("User %s deleted", username))
+}
+
+// CurrentStateHandler returns current state
+func (d *DBClient) CurrentStateHandler(w http.ResponseWriter, req *http.Request) {
+	var resp stateRequest
+	resp.Mode = d.Cfg.GetMode()
+	resp.Destination = d.Cfg.GetDestination()
+
+	b, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Write(b)
+}
+
+type destinationRequest struct {
+	Destination string `json:"destination"`
+}
+
+// CurrentDestinationHandler returns current destination filter
+func (d *DBClient) CurrentDestinationHandler(w http.ResponseWriter, req *http.Request) {
+	b, _ := json.Marshal(destinationRequest{Destination: d.Cfg.GetDestination()})
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Write(b)
+}
+
+// DestinationHandler changes destination filter, requests to hosts that don't match it are passed through untouched
+func (d *DBClient) DestinationHandler(w http.ResponseWriter, req *http.Request) {
+	var dr destinationRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&dr); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+
+	if err := d.Cfg.SetDestination(dr.Destination); err != nil {
+		log.WithFields(log.Fields{
+			"destination": dr.Destination,
+			"error":       err.Error(),
+		}).Error("Bad destination supplied, can't change it")
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Bad destination supplied, regular expression is expected: %s", err.Error()))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"destination": dr.Destination,
+	}).Info("Destination changed")
+
+	d.CurrentDestinationHandler(w, req)
+}
+
+// CertHandler returns PEM encoded certificate authority used to intercept HTTPS traffic, clients have to trust it
+func (d *DBClient) CertHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	if err := WriteCertificate(w, goproxy.GoproxyCa); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to write certificate")
+	}
+}
+
+type passthroughRequest struct {
+	Hosts []string `json:"hosts"`
+}
+
+// PassthroughHandler returns hosts that are always proxied to the real network
+func (d *DBClient) PassthroughHandler(w http.ResponseWriter, req *http.Request) {
+	b, _ := json.Marshal(passthroughRequest{Hosts: d.Cfg.GetPassthrough()})
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Write(b)
+}
+
+// AddPassthroughHandler adds hosts to passthrough list, current list is returned
+func (d *DBClient) AddPassthroughHandler(w http.ResponseWriter, req *http.Request) {
+	var pr passthroughRequest
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&pr); err != nil {
+		writeMessage(w, http.StatusBadRequest, fmt.Sprintf("Failed to decode request body: %s", err.Error()))
+		return
+	}
+	if len(pr.Hosts) == 0 {
+		writeMessage(w, http.StatusBadRequest, "No hosts supplied")
+		return
+	}
+
+	d.Cfg.AddPassthrough(pr.Hosts...)
+
+	log.WithFields(log.Fields{
+		"hosts": pr.Hosts,
+	}).Info("Passthrough hosts added")
+
+	d.PassthroughHandler(w, req)
+}
+
+// DeletePassthroughHandler removes host from passthrough list
+func (d *DBClient) DeletePassthroughHandler(w http.ResponseWriter, req *http.Request) {
+	host := bone.GetValue(req, "host")
+
+	if !d.Cfg.RemovePassthrough(host) {
+		writeMessage(w, http.StatusNotFound, fmt.Sprintf("Host %s is not in passthrough list", host))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"host": host,
+	}).Info("Passthrough host removed")
+
+	writeMessage(w, http.StatusOK, fmt.Sprintf("Host %s removed from passthrough list", host))
+}
+
+// StateHandler handles current proxy state
+func (d *DBClient) StateHandler(w http.ResponseWriter, r *http.Request) {
+	var sr stateRequest
+
+	// this is mainly for testing, since when you create
+	if r.Body == nil {
+		r.Body = ioutil.NopCloser(bytes.NewBuffer([]byte("")))
+	}
+
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		// failed to read response body
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not read response body!")
+		http.Error(w, "Failed to read request body.", 400)
+		return
+	}
+
+	err = json.Unmarshal(body, &sr)
+
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(400) // can't process this entity
+		return
+	}
+
+	availableModes := map[string]bool{
+		"virtualize": true,
+		"capture":    true,
+		"modify":     true,
+		"synthesize": true,
+		"spy":        true,
+		"diff":       true,
+	}
+
+	if !availableModes[sr.Mode] {
 		log.WithFields(log.Fields{
 			"suppliedMode": sr.Mode,
 		}).Error("Wrong mode found, can't change state")
-		http.Error(w, "Bad mode supplied, available modes: virtualize, capture, modify, synthesize.", 400)
+		http.Error(w, "Bad mode supplied, available modes: virtualize, capture, modify, synthesize, spy, diff.", 400)
+		return
+	}
+
+	if d.Cfg.Webserver && sr.Mode != VirtualizeMode {
+		http.Error(w, "Only virtualize mode is available when Hoverfly runs as a webserver.", 400)
 		return
 	}
 
@@ -409,7 +2308,7 @@ func (d *DBClient) StateHandler(w http.ResponseWriter, r *http.Request) {
 
 	var resp stateRequest
 	resp.Mode = d.Cfg.GetMode()
-	resp.Destination = d.Cfg.Destination
+	resp.Destination = d.Cfg.GetDestination()
 	b, _ := json.Marshal(resp)
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.Write(b)
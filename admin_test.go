@@ -2,12 +2,18 @@ package hoverfly
 
 import (
 	"bytes"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
 )
 
 func TestGetAllRecords(t *testing.T) {
@@ -66,6 +72,59 @@ func TestGetAllRecordsWRecords(t *testing.T) {
 	expect(t, len(rr.Data), 5)
 }
 
+func TestGetRecordsPage(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	// inserting some payloads
+	for i := 0; i < 5; i++ {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://example.com/q=%d", i), nil)
+		expect(t, err, nil)
+		dbClient.captureRequest(req)
+	}
+	// performing query
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/records?offset=3&limit=10", nil)
+	expect(t, err, nil)
+
+	//The response recorder used to record HTTP responses
+	respRec := httptest.NewRecorder()
+
+	m.ServeHTTP(respRec, req)
+
+	expect(t, respRec.Code, http.StatusOK)
+
+	body, err := ioutil.ReadAll(respRec.Body)
+
+	rp := recordsPage{}
+	err = json.Unmarshal(body, &rp)
+	expect(t, err, nil)
+
+	expect(t, len(rp.Data), 2)
+	expect(t, rp.Offset, 3)
+	expect(t, rp.Limit, 10)
+	expect(t, rp.Total, 5)
+}
+
+func TestGetRecordsPageBadLimit(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/records?limit=zero", nil)
+	expect(t, err, nil)
+
+	//The response recorder used to record HTTP responses
+	respRec := httptest.NewRecorder()
+
+	m.ServeHTTP(respRec, req)
+
+	expect(t, respRec.Code, http.StatusBadRequest)
+}
+
 func TestGetRecordsCount(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
@@ -122,6 +181,38 @@ func TestGetRecordsCountWRecords(t *testing.T) {
 	expect(t, rc.Count, 5)
 }
 
+func TestGetRecordsCountByDestination(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://example.com/q=%d", i), nil)
+		expect(t, err, nil)
+		dbClient.captureRequest(req)
+	}
+	req, err := http.NewRequest("GET", "http://other.com/", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	m := getBoneRouter(*dbClient)
+
+	req, err = http.NewRequest("GET", "/count?by=destination", nil)
+	expect(t, err, nil)
+
+	respRec := httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+	expect(t, respRec.Code, http.StatusOK)
+
+	rc := recordsCount{}
+	err = json.Unmarshal(respRec.Body.Bytes(), &rc)
+	expect(t, err, nil)
+
+	expect(t, rc.Count, 4)
+	expect(t, rc.Destinations["example.com"], 3)
+	expect(t, rc.Destinations["other.com"], 1)
+}
+
 func TestExportImportRecords(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
@@ -193,6 +284,80 @@ func TestDeleteHandler(t *testing.T) {
 	expect(t, rec.Code, http.StatusOK)
 }
 
+func TestDeleteRecordHandler(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	req, err := http.NewRequest("GET", "http://example.com/q=1", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+
+	m := getBoneRouter(*dbClient)
+
+	req, err = http.NewRequest("DELETE", "/records/"+payloads[0].ID, nil)
+	expect(t, err, nil)
+
+	//The response recorder used to record HTTP responses
+	respRec := httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+
+	expect(t, respRec.Code, http.StatusOK)
+
+	count, err := dbClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 0)
+}
+
+func TestDeleteRecordHandlerNotFound(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("DELETE", "/records/nothere", nil)
+	expect(t, err, nil)
+
+	//The response recorder used to record HTTP responses
+	respRec := httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+
+	expect(t, respRec.Code, http.StatusNotFound)
+}
+
+func TestDeleteHandlerWithFilter(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	for _, u := range []string{"http://example.com/users/1", "http://example.com/users/2", "http://example.com/orders/1"} {
+		req, err := http.NewRequest("GET", u, nil)
+		expect(t, err, nil)
+		dbClient.captureRequest(req)
+	}
+
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("DELETE", "/records?path=/users", nil)
+	expect(t, err, nil)
+
+	//The response recorder used to record HTTP responses
+	respRec := httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+
+	expect(t, respRec.Code, http.StatusOK)
+
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Path, "/orders/1")
+}
+
 func TestDeleteHandlerNoBucket(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
@@ -209,6 +374,92 @@ func TestDeleteHandlerNoBucket(t *testing.T) {
 	expect(t, importRec.Code, http.StatusOK)
 }
 
+func TestBackupHandler(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	req, err := http.NewRequest("GET", "http://example.com/q=1", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	m := getBoneRouter(*dbClient)
+
+	req, err = http.NewRequest("GET", "/backup", nil)
+	expect(t, err, nil)
+
+	//The response recorder used to record HTTP responses
+	respRec := httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+
+	expect(t, respRec.Code, http.StatusOK)
+	expect(t, respRec.Header().Get("Content-Type"), "application/octet-stream")
+
+	// backup should be a valid database with captured records
+	backupName := "backup_test.db"
+	defer os.Remove(backupName)
+	err = ioutil.WriteFile(backupName, respRec.Body.Bytes(), 0600)
+	expect(t, err, nil)
+
+	db := GetDB(backupName)
+	defer db.Close()
+	cache := NewBoltDBCache(db, dbClient.Cache.(*BoltCache).RequestsBucket)
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 1)
+}
+
+func TestCompactHandler(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	name := "compact_handler_test.db"
+	defer os.Remove(name)
+	cache := NewBoltDBCache(GetDB(name), []byte(RequestsBucketName))
+	defer cache.CloseDB()
+	dbClient.Cache = cache
+
+	req, err := http.NewRequest("GET", "http://example.com/q=1", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	m := getBoneRouter(*dbClient)
+
+	req, err = http.NewRequest("POST", "/compact", nil)
+	expect(t, err, nil)
+
+	respRec := httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+	expect(t, respRec.Code, http.StatusOK)
+
+	var result CompactionResult
+	err = json.Unmarshal(respRec.Body.Bytes(), &result)
+	expect(t, err, nil)
+	refute(t, result.SizeAfter, int64(0))
+
+	count, err := dbClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 1)
+}
+
+func TestBackupHandlerNotSupported(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cache = NewMemoryCache()
+
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/backup", nil)
+	expect(t, err, nil)
+
+	//The response recorder used to record HTTP responses
+	respRec := httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+
+	expect(t, respRec.Code, http.StatusNotImplemented)
+}
+
 func TestGetState(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
@@ -351,154 +602,327 @@ func TestSetSynthesizeState(t *testing.T) {
 	expect(t, dbClient.Cfg.GetMode(), "synthesize")
 }
 
-func TestSetRandomState(t *testing.T) {
+func TestSetSpyState(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
 	defer dbClient.Cache.DeleteData()
 	m := getBoneRouter(*dbClient)
 
-	// setting mode to virtualize
 	dbClient.Cfg.SetMode("virtualize")
 
-	// preparing to set mode through rest api
 	var resp stateRequest
-	resp.Mode = "shouldnotwork"
+	resp.Mode = "spy"
 
 	bts, err := json.Marshal(&resp)
 	expect(t, err, nil)
 
-	// deleting through handler
 	req, err := http.NewRequest("POST", "/state", ioutil.NopCloser(bytes.NewBuffer(bts)))
 	expect(t, err, nil)
-	//The response recorder used to record HTTP responses
 	rec := httptest.NewRecorder()
 
 	m.ServeHTTP(rec, req)
-	expect(t, rec.Code, http.StatusBadRequest)
+	expect(t, rec.Code, http.StatusOK)
 
-	// checking mode, should not have changed
-	expect(t, dbClient.Cfg.GetMode(), "virtualize")
+	expect(t, dbClient.Cfg.GetMode(), "spy")
 }
 
-func TestSetNoBody(t *testing.T) {
+func TestDiffHandler(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
 	defer dbClient.Cache.DeleteData()
 	m := getBoneRouter(*dbClient)
 
-	// setting mode to virtualize
-	dbClient.Cfg.SetMode("virtualize")
+	dbClient.Diffs.Add(ResponseDiff{
+		Key:         "key",
+		Path:        "/path",
+		Differences: []FieldDiff{{Field: "status", Expected: "200", Actual: "500"}},
+	})
 
-	// setting state
-	req, err := http.NewRequest("POST", "/state", nil)
+	req, err := http.NewRequest("GET", "/diff", nil)
 	expect(t, err, nil)
-	//The response recorder used to record HTTP responses
 	rec := httptest.NewRecorder()
 
 	m.ServeHTTP(rec, req)
-	expect(t, rec.Code, http.StatusBadRequest)
-
-	// checking mode, should not have changed
-	expect(t, dbClient.Cfg.GetMode(), "virtualize")
-}
-
-func TestStatsHandler(t *testing.T) {
-	server, dbClient := testTools(200, `{'message': 'here'}`)
-	defer server.Close()
-	defer dbClient.Cache.DeleteData()
-	m := getBoneRouter(*dbClient)
+	expect(t, rec.Code, http.StatusOK)
 
-	// deleting through handler
-	req, err := http.NewRequest("GET", "/stats", nil)
+	var dr diffResponse
+	err = json.Unmarshal(rec.Body.Bytes(), &dr)
+	expect(t, err, nil)
+	expect(t, len(dr.Data), 1)
+	expect(t, dr.Data[0].Path, "/path")
+	expect(t, dr.Data[0].Differences[0].Actual, "500")
 
+	// clearing report
+	req, err = http.NewRequest("DELETE", "/diff", nil)
 	expect(t, err, nil)
-	//The response recorder used to record HTTP responses
-	rec := httptest.NewRecorder()
+	rec = httptest.NewRecorder()
 
 	m.ServeHTTP(rec, req)
 	expect(t, rec.Code, http.StatusOK)
+	expect(t, len(dbClient.Diffs.Entries()), 0)
 }
 
-func TestStatsHandlerVirtualizeMetrics(t *testing.T) {
-	// test metrics, increases virtualize count by 1 and then checks through stats
-	// handler whether it is visible through /stats handler
+func TestDestinationHandlers(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
 	defer dbClient.Cache.DeleteData()
 	m := getBoneRouter(*dbClient)
 
-	dbClient.Counter.counterVirtualize.Inc(1)
-
-	req, err := http.NewRequest("GET", "/stats", nil)
+	dbClient.Cfg.SetDestination(".")
 
+	req, err := http.NewRequest("PUT", "/destination", ioutil.NopCloser(bytes.NewBufferString(`{"destination": "api\\.example\\.com"}`)))
 	expect(t, err, nil)
-	//The response recorder used to record HTTP responses
 	rec := httptest.NewRecorder()
 
 	m.ServeHTTP(rec, req)
 	expect(t, rec.Code, http.StatusOK)
+	expect(t, dbClient.Cfg.GetDestination(), `api\.example\.com`)
 
-	body, err := ioutil.ReadAll(rec.Body)
+	req, err = http.NewRequest("GET", "/destination", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
 
-	sr := statsResponse{}
-	err = json.Unmarshal(body, &sr)
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
 
-	expect(t, int(sr.Stats.Counters[VirtualizeMode]), 1)
+	var dr destinationRequest
+	err = json.Unmarshal(rec.Body.Bytes(), &dr)
+	expect(t, err, nil)
+	expect(t, dr.Destination, `api\.example\.com`)
 }
 
-func TestStatsHandlerCaptureMetrics(t *testing.T) {
-	// test metrics, increases capture count by 1 and then checks through stats
-	// handler whether it is visible through /stats handler
+func TestSetBadDestination(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
 	defer dbClient.Cache.DeleteData()
 	m := getBoneRouter(*dbClient)
 
-	dbClient.Counter.counterCapture.Inc(1)
-
-	req, err := http.NewRequest("GET", "/stats", nil)
+	dbClient.Cfg.SetDestination(".")
 
+	req, err := http.NewRequest("PUT", "/destination", ioutil.NopCloser(bytes.NewBufferString(`{"destination": "[invalid"}`)))
 	expect(t, err, nil)
-	//The response recorder used to record HTTP responses
 	rec := httptest.NewRecorder()
 
 	m.ServeHTTP(rec, req)
-	expect(t, rec.Code, http.StatusOK)
-
-	body, err := ioutil.ReadAll(rec.Body)
-
-	sr := statsResponse{}
-	err = json.Unmarshal(body, &sr)
-
-	expect(t, int(sr.Stats.Counters[CaptureMode]), 1)
+	expect(t, rec.Code, http.StatusBadRequest)
+	expect(t, dbClient.Cfg.GetDestination(), ".")
 }
 
-func TestStatsHandlerModifyMetrics(t *testing.T) {
-	// test metrics, increases modify count by 1 and then checks through stats
-	// handler whether it is visible through /stats handler
+func TestPassthroughHandlers(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
 	defer dbClient.Cache.DeleteData()
 	m := getBoneRouter(*dbClient)
 
-	dbClient.Counter.counterModify.Inc(1)
+	req, err := http.NewRequest("POST", "/passthrough", ioutil.NopCloser(bytes.NewBufferString(`{"hosts": ["auth.example.com", "cdn.example.com"]}`)))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
 
-	req, err := http.NewRequest("GET", "/stats", nil)
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, dbClient.Cfg.IsPassthrough("auth.example.com"), true)
 
+	req, err = http.NewRequest("DELETE", "/passthrough/cdn.example.com", nil)
 	expect(t, err, nil)
-	//The response recorder used to record HTTP responses
-	rec := httptest.NewRecorder()
+	rec = httptest.NewRecorder()
 
 	m.ServeHTTP(rec, req)
 	expect(t, rec.Code, http.StatusOK)
 
-	body, err := ioutil.ReadAll(rec.Body)
+	req, err = http.NewRequest("GET", "/passthrough", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
 
-	sr := statsResponse{}
-	err = json.Unmarshal(body, &sr)
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
 
-	expect(t, int(sr.Stats.Counters[ModifyMode]), 1)
-}
+	var pr passthroughRequest
+	err = json.Unmarshal(rec.Body.Bytes(), &pr)
+	expect(t, err, nil)
+	expect(t, len(pr.Hosts), 1)
+	expect(t, pr.Hosts[0], "auth.example.com")
+
+	// removing host that is not there
+	req, err = http.NewRequest("DELETE", "/passthrough/cdn.example.com", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusNotFound)
+}
+
+func TestCertHandler(t *testing.T) {
+	defer restoreGoproxyCA()()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	ca, err := GenerateCA("Test Authority", time.Hour)
+	expect(t, err, nil)
+	SetCA(ca)
+
+	req, err := http.NewRequest("GET", "/cert", nil)
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	pool := x509.NewCertPool()
+	expect(t, pool.AppendCertsFromPEM(rec.Body.Bytes()), true)
+	expect(t, len(pool.Subjects()), 1)
+}
+
+func TestSetRandomState(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	// setting mode to virtualize
+	dbClient.Cfg.SetMode("virtualize")
+
+	// preparing to set mode through rest api
+	var resp stateRequest
+	resp.Mode = "shouldnotwork"
+
+	bts, err := json.Marshal(&resp)
+	expect(t, err, nil)
+
+	// deleting through handler
+	req, err := http.NewRequest("POST", "/state", ioutil.NopCloser(bytes.NewBuffer(bts)))
+	expect(t, err, nil)
+	//The response recorder used to record HTTP responses
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+
+	// checking mode, should not have changed
+	expect(t, dbClient.Cfg.GetMode(), "virtualize")
+}
+
+func TestSetNoBody(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	// setting mode to virtualize
+	dbClient.Cfg.SetMode("virtualize")
+
+	// setting state
+	req, err := http.NewRequest("POST", "/state", nil)
+	expect(t, err, nil)
+	//The response recorder used to record HTTP responses
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+
+	// checking mode, should not have changed
+	expect(t, dbClient.Cfg.GetMode(), "virtualize")
+}
+
+func TestStatsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	// deleting through handler
+	req, err := http.NewRequest("GET", "/stats", nil)
+
+	expect(t, err, nil)
+	//The response recorder used to record HTTP responses
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+}
+
+func TestStatsHandlerVirtualizeMetrics(t *testing.T) {
+	// test metrics, increases virtualize count by 1 and then checks through stats
+	// handler whether it is visible through /stats handler
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	dbClient.Counter.counterVirtualize.Inc(1)
+
+	req, err := http.NewRequest("GET", "/stats", nil)
+
+	expect(t, err, nil)
+	//The response recorder used to record HTTP responses
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	body, err := ioutil.ReadAll(rec.Body)
+
+	sr := statsResponse{}
+	err = json.Unmarshal(body, &sr)
+
+	expect(t, int(sr.Stats.Counters[VirtualizeMode]), 1)
+}
+
+func TestStatsHandlerCaptureMetrics(t *testing.T) {
+	// test metrics, increases capture count by 1 and then checks through stats
+	// handler whether it is visible through /stats handler
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	dbClient.Counter.counterCapture.Inc(1)
+
+	req, err := http.NewRequest("GET", "/stats", nil)
+
+	expect(t, err, nil)
+	//The response recorder used to record HTTP responses
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	body, err := ioutil.ReadAll(rec.Body)
+
+	sr := statsResponse{}
+	err = json.Unmarshal(body, &sr)
+
+	expect(t, int(sr.Stats.Counters[CaptureMode]), 1)
+}
+
+func TestStatsHandlerModifyMetrics(t *testing.T) {
+	// test metrics, increases modify count by 1 and then checks through stats
+	// handler whether it is visible through /stats handler
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	dbClient.Counter.counterModify.Inc(1)
+
+	req, err := http.NewRequest("GET", "/stats", nil)
+
+	expect(t, err, nil)
+	//The response recorder used to record HTTP responses
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	body, err := ioutil.ReadAll(rec.Body)
+
+	sr := statsResponse{}
+	err = json.Unmarshal(body, &sr)
+
+	expect(t, int(sr.Stats.Counters[ModifyMode]), 1)
+}
 
 func TestStatsHandlerSynthesizeMetrics(t *testing.T) {
 	// test metrics, increases synthesize count by 1 and then checks through stats
@@ -558,3 +982,1224 @@ func TestStatsHandlerRecordCountMetrics(t *testing.T) {
 
 	expect(t, int(sr.RecordsCount), 5)
 }
+
+func TestNamespacesHandlers(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/namespaces", ioutil.NopCloser(bytes.NewBufferString(`{"name": "TestNamespacesHandlers"}`)))
+	expect(t, err, nil)
+	respRec := httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+	expect(t, respRec.Code, http.StatusCreated)
+
+	req, err = http.NewRequest("PUT", "/namespaces/current", ioutil.NopCloser(bytes.NewBufferString(`{"name": "TestNamespacesHandlers"}`)))
+	expect(t, err, nil)
+	respRec = httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+	expect(t, respRec.Code, http.StatusOK)
+
+	req, err = http.NewRequest("GET", "/namespaces", nil)
+	expect(t, err, nil)
+	respRec = httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+	expect(t, respRec.Code, http.StatusOK)
+
+	var resp namespacesResponse
+	err = json.Unmarshal(respRec.Body.Bytes(), &resp)
+	expect(t, err, nil)
+	expect(t, resp.Current, "TestNamespacesHandlers")
+
+	// switching back so namespace can be deleted
+	dbClient.Cache.(*BoltCache).SwitchNamespace(dbClient.Cache.(*BoltCache).defaultNamespace)
+
+	req, err = http.NewRequest("DELETE", "/namespaces/TestNamespacesHandlers", nil)
+	expect(t, err, nil)
+	respRec = httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+	expect(t, respRec.Code, http.StatusOK)
+}
+
+func TestSwitchNamespaceHandlerNotFound(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/namespaces/current", ioutil.NopCloser(bytes.NewBufferString(`{"name": "nothere"}`)))
+	expect(t, err, nil)
+	respRec := httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+	expect(t, respRec.Code, http.StatusNotFound)
+}
+
+func TestDelaysHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/delays", strings.NewReader(`{"data": [{"urlPattern": "api\\.example\\.com", "httpMethod": "GET", "delay": 100, "maxDelay": 200}]}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	req, err = http.NewRequest("GET", "/delays", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var dr delaysRequest
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &dr), nil)
+	expect(t, len(dr.Data), 1)
+	expect(t, dr.Data[0].URLPattern, `api\.example\.com`)
+	expect(t, dr.Data[0].MaxDelay, 200)
+
+	// invalid pattern is rejected
+	req, err = http.NewRequest("PUT", "/delays", strings.NewReader(`{"data": [{"urlPattern": "(", "delay": 100}]}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+	expect(t, len(dbClient.Delays.Get()), 1)
+
+	req, err = http.NewRequest("DELETE", "/delays", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, len(dbClient.Delays.Get()), 0)
+}
+
+func TestFaultsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/faults", strings.NewReader(`{"data": [{"urlPattern": "/orders", "type": "reset", "percentage": 10}]}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	req, err = http.NewRequest("GET", "/faults", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var fr faultsRequest
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &fr), nil)
+	expect(t, len(fr.Data), 1)
+	expect(t, fr.Data[0].Type, FaultReset)
+	expect(t, fr.Data[0].Percentage, 10.0)
+
+	// unknown fault is rejected
+	req, err = http.NewRequest("PUT", "/faults", strings.NewReader(`{"data": [{"urlPattern": ".", "type": "explode", "percentage": 10}]}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+	expect(t, len(dbClient.Faults.Get()), 1)
+
+	req, err = http.NewRequest("DELETE", "/faults", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, len(dbClient.Faults.Get()), 0)
+}
+
+func TestErrorResponsesHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/error-responses", strings.NewReader(`{"seed": 7, "data": [{"urlPattern": "/orders", "percentage": 30, "headers": {"Retry-After": ["10"]}}]}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var er errorResponsesRequest
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &er), nil)
+	expect(t, er.Seed, int64(7))
+	expect(t, len(er.Data), 1)
+	expect(t, er.Data[0].Status, http.StatusServiceUnavailable)
+
+	// invalid percentage is rejected
+	req, err = http.NewRequest("PUT", "/error-responses", strings.NewReader(`{"data": [{"urlPattern": ".", "percentage": 130}]}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+
+	req, err = http.NewRequest("DELETE", "/error-responses", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	req, err = http.NewRequest("GET", "/error-responses", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	er = errorResponsesRequest{}
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &er), nil)
+	expect(t, len(er.Data), 0)
+}
+
+func TestRateLimitsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/rate-limits", strings.NewReader(`{"data": [{"destination": "api.example.com", "requests": 100, "window": 60}]}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var rr rateLimitsRequest
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &rr), nil)
+	expect(t, len(rr.Data), 1)
+	expect(t, rr.Data[0].Requests, 100)
+
+	// missing window is rejected
+	req, err = http.NewRequest("PUT", "/rate-limits", strings.NewReader(`{"data": [{"destination": ".", "requests": 100}]}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+	expect(t, len(dbClient.RateLimits.Get()), 1)
+
+	req, err = http.NewRequest("DELETE", "/rate-limits", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, len(dbClient.RateLimits.Get()), 0)
+}
+
+func TestSequencesHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+	dbClient.Sequences.Next("abc")
+
+	req, err := http.NewRequest("GET", "/sequences", nil)
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var sr sequencesResponse
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &sr), nil)
+	expect(t, sr.Calls["abc"], 1)
+
+	req, err = http.NewRequest("DELETE", "/sequences", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, len(dbClient.Sequences.Get()), 0)
+}
+
+func TestScenarioStateHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/api/state", strings.NewReader(`{"state": {"order": "paid"}}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, dbClient.ScenarioState.Get()["order"], "paid")
+
+	req, err = http.NewRequest("GET", "/api/state", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var sr scenarioStateRequest
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &sr), nil)
+	expect(t, sr.State["order"], "paid")
+
+	req, err = http.NewRequest("DELETE", "/api/state", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, len(dbClient.ScenarioState.Get()), 0)
+}
+
+func TestHeaderRulesHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/header-rules", strings.NewReader(`{"data": [{"stage": "replay", "action": "add", "header": "X-Simulated", "value": "true"}]}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var hr headerRulesRequest
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &hr), nil)
+	expect(t, len(hr.Data), 1)
+	expect(t, hr.Data[0].Header, "X-Simulated")
+
+	// unknown stage is rejected
+	req, err = http.NewRequest("PUT", "/header-rules", strings.NewReader(`{"data": [{"stage": "forward", "action": "add", "header": "X-Simulated"}]}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+	expect(t, len(dbClient.HeaderRules.Get()), 1)
+
+	req, err = http.NewRequest("DELETE", "/header-rules", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, len(dbClient.HeaderRules.Get()), 0)
+}
+
+func TestHeaderMatchesHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/header-matches", strings.NewReader(`{"data": [{"destination": "api\\.example\\.com", "headers": ["Authorization"]}]}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var hm headerMatchesRequest
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &hm), nil)
+	expect(t, len(hm.Data), 1)
+	expect(t, hm.Data[0].Headers[0], "Authorization")
+
+	// header match without headers is rejected
+	req, err = http.NewRequest("PUT", "/header-matches", strings.NewReader(`{"data": [{"destination": "."}]}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+	expect(t, len(dbClient.HeaderMatches.Get()), 1)
+
+	req, err = http.NewRequest("DELETE", "/header-matches", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, len(dbClient.HeaderMatches.Get()), 0)
+}
+
+func TestBodyMatchersHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/body-matchers", strings.NewReader(`{"data": [{"urlPattern": "/users", "mode": "json"}]}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var bm bodyMatchersRequest
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &bm), nil)
+	expect(t, len(bm.Data), 1)
+	expect(t, bm.Data[0].Mode, BodyMatchJSON)
+
+	// unknown mode is rejected
+	req, err = http.NewRequest("PUT", "/body-matchers", strings.NewReader(`{"data": [{"mode": "fuzzy"}]}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+	expect(t, len(dbClient.BodyMatchers.Get()), 1)
+
+	req, err = http.NewRequest("DELETE", "/body-matchers", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, len(dbClient.BodyMatchers.Get()), 0)
+}
+
+func TestImportHARHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	har := `{"log": {"version": "1.2", "entries": [{"request": {"method": "GET", "url": "http://api.example.com/status"},
+		"response": {"status": 200, "content": {"text": "ok"}}}]}}`
+	req, err := http.NewRequest("POST", "/records/har", strings.NewReader(har))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 1)
+
+	req, err = http.NewRequest("POST", "/records/har", strings.NewReader("not a HAR file"))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestExportHARHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+
+	req, err := http.NewRequest("GET", "/records/har", nil)
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var har HAR
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &har), nil)
+	expect(t, har.Log.Version, "1.2")
+	expect(t, len(har.Log.Entries), 1)
+	expect(t, har.Log.Entries[0].Request.URL, "http://api.example.com/users")
+	expect(t, har.Log.Entries[0].Response.Content.Text, "users")
+	// cookie lists are required by the spec
+	expect(t, strings.Contains(rec.Body.String(), `"cookies":null`), false)
+}
+
+func TestImportOpenAPIHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	doc := `{"openapi": "3.0.0", "servers": [{"url": "/v1"}], "paths": {"/status": {"get": {"responses": {"200": {"description": "ok"}}}}}}`
+	req, err := http.NewRequest("POST", "/records/openapi?destination=status.example.com", strings.NewReader(doc))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	payloads, _ := dbClient.Cache.GetAllRequests()
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Destination, "status.example.com")
+	expect(t, payloads[0].Request.Path, "/v1/status")
+
+	req, err = http.NewRequest("POST", "/records/openapi", strings.NewReader(`{"data": []}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestExportOpenAPIHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users/12", "", 200, "user")
+
+	req, err := http.NewRequest("GET", "/records/openapi?destination=api.example.com", nil)
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, rec.Header().Get("Content-Type"), "application/json")
+
+	var doc map[string]interface{}
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &doc), nil)
+	expect(t, object(doc["info"])["title"], "api.example.com")
+	_, ok := object(doc["paths"])["/users/{userId}"]
+	expect(t, ok, true)
+}
+
+func TestImportWireMockHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	mapping := `{"request": {"method": "GET", "urlPath": "/status"}, "response": {"status": 200, "body": "up"}}`
+	req, err := http.NewRequest("POST", "/records/wiremock?destination=status.example.com", strings.NewReader(mapping))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	payloads, _ := dbClient.Cache.GetAllRequests()
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Destination, "status.example.com")
+	expect(t, payloads[0].Response.Body, "up")
+
+	req, err = http.NewRequest("POST", "/records/wiremock", strings.NewReader(`{"data": []}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestImportPcapHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/records/pcap", bytes.NewReader(testPcapng(testHTTPConversation()...)))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	payloads, _ := dbClient.Cache.GetAllRequests()
+	expect(t, len(payloads), 2)
+
+	req, err = http.NewRequest("POST", "/records/pcap", strings.NewReader("not a capture file"))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestExportedRecordsHaveSchemaVersion(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+
+	for _, path := range []string{"/records", "/records?limit=10"} {
+		req, err := http.NewRequest("GET", path, nil)
+		expect(t, err, nil)
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		expect(t, rec.Code, http.StatusOK)
+
+		var rr recordedRequests
+		expect(t, json.Unmarshal(rec.Body.Bytes(), &rr), nil)
+		refute(t, rr.Meta, nil)
+		expect(t, rr.Meta.SchemaVersion, SimulationSchemaVersion)
+		expect(t, len(rr.Data), 1)
+	}
+}
+
+func TestImportRecordsOfNewerSchemaVersion(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/records", strings.NewReader(`{"meta": {"schemaVersion": 99}, "data": []}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, 422)
+	expect(t, strings.Contains(rec.Body.String(), "upgrade Hoverfly"), true)
+
+	// unversioned exports are migrated
+	req, err = http.NewRequest("POST", "/records", strings.NewReader(
+		`{"data": [{"request": {"method": "GET", "destination": "api.example.com", "path": "/users"}, "response": {"status": "200", "headers": {"Content-Type": "text/plain"}}}]}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	payloads, _ := dbClient.Cache.GetAllRequests()
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Response.Headers["Content-Type"][0], "text/plain")
+}
+
+func TestExportAndImportRecordsAsYAML(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "[\n  \"alice\"\n]\n")
+
+	req, err := http.NewRequest("GET", "/records?format=yaml", nil)
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, rec.Header().Get("Content-Type"), "application/x-yaml")
+	exported := rec.Body.String()
+	expect(t, strings.Contains(exported, "schemaVersion: 2\n"), true)
+	expect(t, strings.Contains(exported, "body: |\n"), true)
+
+	// pages can be exported as YAML too
+	req, err = http.NewRequest("GET", "/records?limit=1", nil)
+	expect(t, err, nil)
+	req.Header.Set("Accept", "application/x-yaml")
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Header().Get("Content-Type"), "application/x-yaml")
+	expect(t, strings.Contains(rec.Body.String(), "total: 1\n"), true)
+
+	dbClient.Cache.DeleteData()
+
+	req, err = http.NewRequest("POST", "/records", strings.NewReader(exported))
+	expect(t, err, nil)
+	req.Header.Set("Content-Type", "application/x-yaml")
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	payloads, _ := dbClient.Cache.GetAllRequests()
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Response.Body, "[\n  \"alice\"\n]\n")
+
+	req, err = http.NewRequest("POST", "/records?format=yaml", strings.NewReader("data:\n\t- broken\n"))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, 422)
+}
+
+func TestExportFilteredRecords(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users/1", "", 200, "alice")
+	storeTestPayload(dbClient, "GET", "http://api.example.com/orders/1", "", 200, "order")
+	storeTestPayload(dbClient, "GET", "http://other.example.com/users/2", "", 200, "bob")
+
+	exported := func(path string) recordsPage {
+		req, err := http.NewRequest("GET", path, nil)
+		expect(t, err, nil)
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		expect(t, rec.Code, http.StatusOK)
+
+		var page recordsPage
+		expect(t, json.Unmarshal(rec.Body.Bytes(), &page), nil)
+		return page
+	}
+
+	expect(t, len(exported("/records?destination=api.example.com").Data), 2)
+	expect(t, len(exported("/records?path=/users").Data), 2)
+	expect(t, len(exported("/records?destination=api.example.com&path=/users").Data), 1)
+
+	// captured records have capture time
+	hourAgo := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	hourLater := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	expect(t, len(exported("/records?from="+hourAgo+"&to="+hourLater).Data), 3)
+	expect(t, len(exported("/records?from="+hourLater).Data), 0)
+	expect(t, len(exported("/records?to="+hourAgo).Data), 0)
+
+	page := exported("/records?path=/users&limit=1")
+	expect(t, len(page.Data), 1)
+	expect(t, page.Total, 2)
+	page = exported("/records?path=/users&offset=1&limit=5")
+	expect(t, len(page.Data), 1)
+	expect(t, page.Total, 2)
+
+	req, err := http.NewRequest("GET", "/records?from=yesterday", nil)
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestMergeRecordsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+
+	simulation := `{"data": [
+		{"request": {"method": "GET", "destination": "api.example.com", "path": "/users"}, "response": {"status": 200, "body": "other users"}},
+		{"request": {"method": "GET", "destination": "api.example.com", "path": "/orders"}, "response": {"status": 200, "body": "orders"}}
+	]}`
+	merge := func(query string) (*httptest.ResponseRecorder, mergeResponse) {
+		req, err := http.NewRequest("POST", "/records/merge"+query, strings.NewReader(simulation))
+		expect(t, err, nil)
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+
+		var response mergeResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response
+	}
+
+	// conflicts are errors by default
+	rec, response := merge("")
+	expect(t, rec.Code, http.StatusConflict)
+	expect(t, len(response.Conflicts), 1)
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 1)
+
+	rec, response = merge("?conflict=skip")
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, response.Added, 1)
+	expect(t, response.Skipped, 1)
+	count, _ = dbClient.Cache.RecordsCount()
+	expect(t, count, 2)
+
+	rec, response = merge("?conflict=overwrite")
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, response.Overwritten, 2)
+
+	rec, _ = merge("?conflict=replace")
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestExportAndImportBinaryBodies(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	pdf := "%PDF-1.4\n\xe2\xe3\xcf\xd3\n"
+	storeTestPayload(dbClient, "GET", "http://api.example.com/report.pdf", "", 200, pdf)
+
+	req, err := http.NewRequest("GET", "/records", nil)
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	exported := rec.Body.String()
+	expect(t, strings.Contains(exported, `"bodyEncoding":"base64"`), true)
+
+	dbClient.Cache.DeleteData()
+	req, err = http.NewRequest("POST", "/records", strings.NewReader(exported))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	req, _ = http.NewRequest("GET", "http://api.example.com/report.pdf", nil)
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, string(body), pdf)
+}
+
+func TestDiffRecordsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+	storeTestPayload(dbClient, "GET", "http://api.example.com/orders", "", 200, "orders")
+
+	simulation := "data:\n" +
+		"  - request: {method: GET, destination: api.example.com, path: /users, scheme: http}\n" +
+		"    response: {status: 200, body: other users}\n" +
+		"  - request: {method: GET, destination: api.example.com, path: /items, scheme: http}\n" +
+		"    response: {status: 200, body: items}\n"
+	req, err := http.NewRequest("POST", "/records/diff?format=yaml", strings.NewReader(simulation))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var diff SimulationDiff
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &diff), nil)
+	expect(t, len(diff.Added), 1)
+	expect(t, len(diff.Removed), 1)
+	expect(t, len(diff.Changed), 1)
+	expect(t, diff.Changed[0].Path, "/users")
+
+	// nothing is imported
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 2)
+}
+
+func TestImportCurlHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	commands := "curl 'http://api.example.com/users' -H 'Accept: application/json'\ncurl 'http://api.example.com/orders'"
+	req, err := http.NewRequest("POST", "/records/curl", strings.NewReader(commands))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 2)
+
+	req, err = http.NewRequest("POST", "/records/curl", strings.NewReader("curl 'http://api.example.com/"))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestRecordsAPI(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		expect(t, err, nil)
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("POST", "/api/records", `{"data": [
+		{"request": {"method": "GET", "destination": "api.example.com", "path": "/users", "scheme": "http"}, "response": {"status": 200, "body": "users"}},
+		{"request": {"method": "GET", "destination": "api.example.com", "path": "/orders", "scheme": "http"}, "response": {"status": 200, "body": "orders"}}
+	]}`)
+	expect(t, rec.Code, http.StatusOK)
+
+	rec = call("GET", "/api/records", "")
+	expect(t, rec.Code, http.StatusOK)
+	var rr recordedRequests
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &rr), nil)
+	expect(t, len(rr.Data), 2)
+
+	id := rr.Data[0].ID
+	rec = call("GET", "/api/records/"+id, "")
+	expect(t, rec.Code, http.StatusOK)
+	var pl Payload
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &pl), nil)
+	expect(t, pl.ID, id)
+	expect(t, pl.Response.Body, rr.Data[0].Response.Body)
+
+	// named routes still win over record IDs
+	rec = call("GET", "/api/records/har", "")
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, strings.Contains(rec.Body.String(), `"log"`), true)
+
+	rec = call("DELETE", "/api/records/"+id, "")
+	expect(t, rec.Code, http.StatusOK)
+	rec = call("GET", "/api/records/"+id, "")
+	expect(t, rec.Code, http.StatusNotFound)
+	rec = call("GET", "/records/"+rr.Data[1].ID, "")
+	expect(t, rec.Code, http.StatusOK)
+
+	rec = call("DELETE", "/api/records", "")
+	expect(t, rec.Code, http.StatusOK)
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 0)
+}
+
+func TestSearchRecordsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users/1", "", 200, `{"name": "alice"}`)
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users/2", "", 200, `{"name": "bob"}`)
+	storeTestPayload(dbClient, "POST", "http://other.example.com/users", `{"name": "alice"}`, 201, "created")
+
+	search := func(query string) recordsPage {
+		req, err := http.NewRequest("GET", "/api/records/search"+query, nil)
+		expect(t, err, nil)
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		expect(t, rec.Code, http.StatusOK)
+
+		var page recordsPage
+		expect(t, json.Unmarshal(rec.Body.Bytes(), &page), nil)
+		return page
+	}
+
+	expect(t, search("?body-contains=alice").Total, 2)
+	expect(t, search("?body-contains=alice&method=post").Total, 1)
+	expect(t, search("?destination=api.example.com&path=/users").Total, 2)
+	expect(t, search("?body-contains=carol").Total, 0)
+	expect(t, len(search("?body-contains=carol").Data), 0)
+	expect(t, search("").Total, 3)
+
+	page := search("?path=/users&limit=2&offset=1")
+	expect(t, page.Total, 3)
+	expect(t, len(page.Data), 2)
+
+	req, err := http.NewRequest("GET", "/records/search?limit=-1", nil)
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestTokenAuthHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	// authentication isn't configured
+	req, _ := http.NewRequest("POST", "/api/token-auth", strings.NewReader(`{"username": "alice", "password": "wonderland"}`))
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusNotImplemented)
+
+	dbClient.Auth, _ = NewAuthStore(TestDB, GetRandomName(10), "", time.Hour)
+	dbClient.Auth.AddUser("bob", "builder", RoleReadOnly)
+	m = getBoneRouter(*dbClient)
+
+	req, _ = http.NewRequest("POST", "/api/token-auth", strings.NewReader(`{"username": "bob", "password": "builder"}`))
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var tr tokenResponse
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &tr), nil)
+	expect(t, tr.Role, RoleReadOnly)
+	user, err := dbClient.Auth.VerifyToken(tr.Token)
+	expect(t, err, nil)
+	expect(t, user.Username, "bob")
+
+	req, _ = http.NewRequest("POST", "/api/token-auth", strings.NewReader(`{"username": "bob", "password": "wonderland"}`))
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusUnauthorized)
+
+	req, _ = http.NewRequest("POST", "/api/token-auth", strings.NewReader(`{"username": "bob"}`))
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestUsersHandlers(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	dbClient.Auth, _ = NewAuthStore(TestDB, GetRandomName(10), "", time.Hour)
+	m := getBoneRouter(*dbClient)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		return rec
+	}
+
+	expect(t, serve("POST", "/api/users", `{"username": "alice", "password": "wonderland"}`).Code, http.StatusCreated)
+	expect(t, serve("POST", "/api/users", `{"username": "bob", "password": "builder", "role": "read-only"}`).Code, http.StatusCreated)
+	expect(t, serve("POST", "/api/users", `{"username": "carol", "password": "x", "role": "root"}`).Code, http.StatusBadRequest)
+	_, err := dbClient.Auth.Authenticate("bob", "builder")
+	expect(t, err, nil)
+
+	rec := serve("GET", "/api/users", "")
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, strings.Contains(rec.Body.String(), "wonderland"), false)
+
+	var ur usersResponse
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &ur), nil)
+	expect(t, len(ur.Users), 2)
+	expect(t, ur.Users[0], userResponse{Username: "alice", Role: RoleAdmin})
+	expect(t, ur.Users[1], userResponse{Username: "bob", Role: RoleReadOnly})
+
+	// admin interface can't be locked
+	expect(t, serve("POST", "/api/users", `{"username": "alice", "password": "wonderland", "role": "read-only"}`).Code, http.StatusConflict)
+	expect(t, serve("DELETE", "/api/users/alice", "").Code, http.StatusConflict)
+
+	expect(t, serve("DELETE", "/api/users/bob", "").Code, http.StatusOK)
+	expect(t, serve("DELETE", "/api/users/bob", "").Code, http.StatusNotFound)
+}
+
+func TestJournalHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	dbClient.Journal.Add(JournalEntry{Mode: VirtualizeMode, Method: "GET", Path: "/orders", Status: 200})
+	dbClient.Journal.Add(JournalEntry{Mode: VirtualizeMode, Method: "GET", Path: "/users", Status: 412})
+
+	req, err := http.NewRequest("GET", "/api/journal?status=412", nil)
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var jr journalResponse
+	err = json.Unmarshal(rec.Body.Bytes(), &jr)
+	expect(t, err, nil)
+	expect(t, len(jr.Data), 1)
+	expect(t, jr.Data[0].Path, "/users")
+	expect(t, jr.Total, 1)
+
+	// pagination
+	req, err = http.NewRequest("GET", "/api/journal?offset=1&limit=1", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	jr = journalResponse{}
+	err = json.Unmarshal(rec.Body.Bytes(), &jr)
+	expect(t, err, nil)
+	expect(t, len(jr.Data), 1)
+	expect(t, jr.Data[0].Path, "/users")
+	expect(t, jr.Offset, 1)
+	expect(t, jr.Limit, 1)
+	expect(t, jr.Total, 2)
+
+	req, err = http.NewRequest("GET", "/api/journal?offset=-1", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+
+	// bad filter
+	req, err = http.NewRequest("GET", "/api/journal?matched=maybe", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+
+	// clearing journal
+	req, err = http.NewRequest("DELETE", "/api/journal", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, dbClient.Journal.Len(), 0)
+}
+
+func TestJournalHandlerDisabled(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Journal = nil
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/api/journal", nil)
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusNotImplemented)
+}
+
+func TestVerifyJournalHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	dbClient.Journal.Add(JournalEntry{Method: "POST", Destination: "payments.example.com", Path: "/v1/charges"})
+
+	req, err := http.NewRequest("POST", "/api/journal/verify", ioutil.NopCloser(bytes.NewBufferString(
+		`{"method": "POST", "destination": "payments.example.com", "matchers": {"path": {"glob": "/v1/*"}}, "count": 1}`)))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var result VerificationResult
+	err = json.Unmarshal(rec.Body.Bytes(), &result)
+	expect(t, err, nil)
+	expect(t, result.Count, 1)
+	expect(t, result.Verified, true)
+	expect(t, result.Entries[0].Path, "/v1/charges")
+
+	// unmet expectation
+	req, err = http.NewRequest("POST", "/api/journal/verify", ioutil.NopCloser(bytes.NewBufferString(
+		`{"destination": "payments.example.com", "atLeast": 2}`)))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	result = VerificationResult{}
+	err = json.Unmarshal(rec.Body.Bytes(), &result)
+	expect(t, err, nil)
+	expect(t, result.Verified, false)
+	expect(t, result.Message, "expected at least 2 matching requests, found 1")
+
+	// bad verification
+	req, err = http.NewRequest("POST", "/api/journal/verify", ioutil.NopCloser(bytes.NewBufferString(
+		`{"matchers": {"body": {"glob": "*"}}}`)))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestWebhooksHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/webhooks", strings.NewReader(`{"data": [{"urlPattern": "payments\\.example\\.com/v1/charges", "httpMethod": "POST", "url": "http://localhost:8080/events", "delay": 500}]}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	req, err = http.NewRequest("GET", "/webhooks", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var wr webhooksRequest
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &wr), nil)
+	expect(t, len(wr.Data), 1)
+	expect(t, wr.Data[0].URL, "http://localhost:8080/events")
+	expect(t, wr.Data[0].Delay, 500)
+
+	// relative callback URL is rejected
+	req, err = http.NewRequest("PUT", "/webhooks", strings.NewReader(`{"data": [{"urlPattern": "/orders", "url": "/events"}]}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+	expect(t, len(dbClient.Webhooks.Get()), 1)
+
+	req, err = http.NewRequest("DELETE", "/webhooks", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, len(dbClient.Webhooks.Get()), 0)
+}
+
+func TestLoggingHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	// log sinks not configured
+	req, err := http.NewRequest("GET", "/logging", nil)
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusNotImplemented)
+
+	logging, err := NewLogging(nil, log.InfoLevel)
+	expect(t, err, nil)
+	dbClient.Logging = logging
+	m = getBoneRouter(*dbClient)
+
+	req, err = http.NewRequest("PUT", "/logging", strings.NewReader(`{"levels": {"cache": "debug"}}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var lr loggingRequest
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &lr), nil)
+	expect(t, lr.Levels[LogSubsystemCache], "debug")
+	expect(t, lr.Levels[LogSubsystemAdmin], "info")
+
+	// unknown subsystem is rejected and levels stay as they were
+	req, err = http.NewRequest("PUT", "/logging", strings.NewReader(`{"levels": {"proxy": "warning", "database": "debug"}}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+	expect(t, logging.SubsystemLevels()[LogSubsystemProxy], "info")
+
+	// sinks can't be changed at runtime
+	req, err = http.NewRequest("PUT", "/logging", strings.NewReader(`{"sinks": [{"type": "file", "path": "/tmp/hoverfly.log"}]}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestDebugMatchHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users?page=1", "", 200, "users")
+	storeTestPayload(dbClient, "GET", "http://api.example.com/orders", "", 200, "orders")
+
+	req, err := http.NewRequest("POST", "/api/debug/match?nearest=1", strings.NewReader(`{"method": "GET", "destination": "api.example.com", "path": "/users", "query": "page=2"}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var trace MatchTrace
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &trace), nil)
+	expect(t, trace.Matched, false)
+	expect(t, len(trace.Candidates), 1)
+	expect(t, len(trace.Nearest), 1)
+	expect(t, trace.Nearest[0].Path, "/users")
+	expect(t, trace.Nearest[0].Mismatches[0].Field, "query parameter page")
+	expect(t, trace.Nearest[0].Mismatches[0].Expected, "1")
+	expect(t, trace.Nearest[0].Mismatches[0].Actual, "2")
+
+	req, err = http.NewRequest("POST", "/api/debug/match?nearest=-1", strings.NewReader(`{}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+
+	req, err = http.NewRequest("POST", "/api/debug/match", strings.NewReader(`{"path": "/users"}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestMiddlewareChainHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/middleware", strings.NewReader(`{"data": [{"binary": "./examples/middleware/modify_response/modify_response.py", "timeout": 2000}, {"remote": "http://localhost:8090/process", "failure": "open"}]}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	req, err = http.NewRequest("GET", "/middleware", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+
+	var mr middlewareChainRequest
+	expect(t, json.Unmarshal(rec.Body.Bytes(), &mr), nil)
+	expect(t, len(mr.Data), 2)
+	expect(t, mr.Data[0].Timeout, 2000)
+	expect(t, mr.Data[1].Failure, MiddlewareFailOpen)
+
+	// unknown failure policy is rejected
+	req, err = http.NewRequest("PUT", "/middleware", strings.NewReader(`{"data": [{"binary": "cat", "failure": "maybe"}]}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+	expect(t, len(dbClient.MiddlewareChain.Get()), 2)
+
+	req, err = http.NewRequest("DELETE", "/middleware", nil)
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+	expect(t, len(dbClient.MiddlewareChain.Get()), 0)
+}
@@ -0,0 +1,52 @@
+package hoverfly
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// AdminTLSEnabled - checks whether admin interface is served over HTTPS
+func (c *Configuration) AdminTLSEnabled() bool {
+	return c.AdminCert != "" || c.AdminKey != ""
+}
+
+// NewAdminTLSConfig - returns TLS configuration of admin interface listener, nil when it's served over plain HTTP.
+// When client CA bundle is supplied, clients have to present certificate signed by one of its authorities
+func NewAdminTLSConfig(cfg *Configuration) (*tls.Config, error) {
+	if !cfg.AdminTLSEnabled() {
+		if cfg.AdminClientCA != "" {
+			return nil, errors.New("client certificates can be verified only when admin certificate and key are supplied")
+		}
+		return nil, nil
+	}
+	if cfg.AdminCert == "" || cfg.AdminKey == "" {
+		return nil, errors.New("both admin certificate and private key have to be supplied")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.AdminCert, cfg.AdminKey)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.AdminClientCA != "" {
+		bundle, err := ioutil.ReadFile(cfg.AdminClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no PEM encoded certificates found in %s", cfg.AdminClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,81 @@
+package hoverfly
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdminTLSConfigDisabled(t *testing.T) {
+	tlsConfig, err := NewAdminTLSConfig(InitSettings())
+	expect(t, err, nil)
+	expect(t, tlsConfig == nil, true)
+}
+
+func TestAdminTLSConfigErrors(t *testing.T) {
+	cfg := InitSettings()
+	cfg.AdminCert = "admin.pem"
+	_, err := NewAdminTLSConfig(cfg)
+	refute(t, err, nil)
+
+	cfg = InitSettings()
+	cfg.AdminClientCA = "clients.pem"
+	_, err = NewAdminTLSConfig(cfg)
+	refute(t, err, nil)
+
+	dir, err := ioutil.TempDir("", "hoverfly-admin")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	_, certFile, keyFile := writeTestCA(t, dir, "admin")
+	bundle := filepath.Join(dir, "bundle.pem")
+	expect(t, ioutil.WriteFile(bundle, []byte("not a certificate"), 0644), nil)
+
+	cfg = InitSettings()
+	cfg.AdminCert, cfg.AdminKey, cfg.AdminClientCA = certFile, keyFile, bundle
+	_, err = NewAdminTLSConfig(cfg)
+	refute(t, err, nil)
+}
+
+func TestAdminTLSWithClientCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly-admin")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	_, certFile, keyFile := writeTestCA(t, dir, "admin")
+	client, clientCAFile, _ := writeTestCA(t, dir, "client")
+	other, _, _ := writeTestCA(t, dir, "other")
+
+	cfg := InitSettings()
+	cfg.AdminCert, cfg.AdminKey, cfg.AdminClientCA = certFile, keyFile, clientCAFile
+	tlsConfig, err := NewAdminTLSConfig(cfg)
+	expect(t, err, nil)
+	expect(t, tlsConfig.ClientAuth, tls.RequireAndVerifyClientCert)
+
+	admin := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	admin.TLS = tlsConfig
+	admin.StartTLS()
+	defer admin.Close()
+
+	get := func(certs ...tls.Certificate) error {
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       certs,
+		}}}
+		resp, err := c.Get(admin.URL + "/state")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	expect(t, get(client), nil)
+	refute(t, get(), nil)
+	refute(t, get(other), nil)
+}
@@ -0,0 +1,418 @@
+package hoverfly
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/boltdb/bolt"
+)
+
+// AuthBucketName - BoltDB bucket users of admin interface are stored in
+const AuthBucketName = "authbucket"
+
+// DefaultTokenExpiry - how long issued admin tokens are valid
+const DefaultTokenExpiry = 24 * time.Hour
+
+// TokenAuthPath - admin interface endpoint issuing tokens, it's the only one available without authentication
+const TokenAuthPath = "/api/token-auth"
+
+// adminAuthRealm - realm announced to admin interface clients that have to authenticate
+const adminAuthRealm = "Hoverfly Admin"
+
+// Roles of admin interface users
+const (
+	// RoleAdmin - user can use whole admin interface
+	RoleAdmin = "admin"
+	// RoleReadOnly - user can view records, stats and settings but can't change anything
+	RoleReadOnly = "read-only"
+)
+
+// password hashing parameters, passwords are hashed with PBKDF2 (HMAC-SHA256) and random salt
+const (
+	passwordHashScheme = "pbkdf2-sha256"
+	passwordIterations = 10000
+	passwordSaltSize   = 16
+	passwordKeySize    = 32
+)
+
+// ErrUserNotFound - returned when user doesn't exist
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrInvalidCredentials - returned when username and password don't match any user
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// ErrInvalidToken - returned when token is malformed, has wrong signature, expired or its user was deleted
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// User - user of admin interface, only password hash is stored. Users without role (added before roles were
+// introduced) are admins
+type User struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"passwordHash"`
+	Role         string `json:"role,omitempty"`
+}
+
+// IsRole - checks whether role is known
+func IsRole(role string) bool {
+	return role == RoleAdmin || role == RoleReadOnly
+}
+
+// IsAdmin - checks whether user can use whole admin interface
+func (u *User) IsAdmin() bool {
+	return u.Role == "" || u.Role == RoleAdmin
+}
+
+// BoltStore - runs transactions against BoltDB database, *bolt.DB is one. BoltCache is another, it reopens its
+// database when it's compacted, so users have to be read through it rather than through the handle it had before
+type BoltStore interface {
+	View(fn func(*bolt.Tx) error) error
+	Update(fn func(*bolt.Tx) error) error
+}
+
+// AuthStore - users of admin interface kept in their own BoltDB bucket, issues and verifies tokens signed with
+// Secret (HMAC-SHA256 JWT)
+type AuthStore struct {
+	DS     BoltStore
+	Bucket []byte
+	// Secret - key tokens are signed with, tokens signed with other key are rejected
+	Secret []byte
+	// TokenExpiry - how long issued tokens are valid
+	TokenExpiry time.Duration
+}
+
+// NewAuthStore - returns new AuthStore, random secret is generated when none is given so tokens don't outlive
+// the process
+func NewAuthStore(db BoltStore, bucket []byte, secret string, expiry time.Duration) (*AuthStore, error) {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	if expiry <= 0 {
+		expiry = DefaultTokenExpiry
+	}
+	return &AuthStore{DS: db, Bucket: bucket, Secret: key, TokenExpiry: expiry}, nil
+}
+
+// AddUser - adds user with given password and role, password and role of existing user are replaced
+func (a *AuthStore) AddUser(username, password, role string) error {
+	if username == "" || password == "" {
+		return fmt.Errorf("username and password have to be supplied")
+	}
+	if !IsRole(role) {
+		return fmt.Errorf("unknown role '%s', available roles: %s, %s", role, RoleAdmin, RoleReadOnly)
+	}
+	if strings.ContainsAny(username, ":/") {
+		return fmt.Errorf("username can't contain ':' or '/'")
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	bts, err := json.Marshal(User{Username: username, PasswordHash: hash, Role: role})
+	if err != nil {
+		return err
+	}
+	return a.DS.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(a.Bucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(username), bts)
+	})
+}
+
+// GetUser - returns user with given username, ErrUserNotFound when there is none
+func (a *AuthStore) GetUser(username string) (*User, error) {
+	var user *User
+	err := a.DS.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(a.Bucket)
+		if bucket == nil {
+			return ErrUserNotFound
+		}
+		bts := bucket.Get([]byte(username))
+		if bts == nil {
+			return ErrUserNotFound
+		}
+		user = &User{}
+		return json.Unmarshal(bts, user)
+	})
+	return user, err
+}
+
+// GetAllUsers - returns all users sorted by username
+func (a *AuthStore) GetAllUsers() ([]User, error) {
+	users := []User{}
+	err := a.DS.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(a.Bucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var user User
+			if err := json.Unmarshal(v, &user); err != nil {
+				return err
+			}
+			users = append(users, user)
+			return nil
+		})
+	})
+	return users, err
+}
+
+// AdminsCount - returns number of users with admin role
+func (a *AuthStore) AdminsCount() (int, error) {
+	users, err := a.GetAllUsers()
+	count := 0
+	for _, user := range users {
+		if user.IsAdmin() {
+			count++
+		}
+	}
+	return count, err
+}
+
+// DeleteUser - deletes user, ErrUserNotFound when there is none. Tokens issued to the user are rejected from now on
+func (a *AuthStore) DeleteUser(username string) error {
+	return a.DS.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(a.Bucket)
+		if bucket == nil || bucket.Get([]byte(username)) == nil {
+			return ErrUserNotFound
+		}
+		return bucket.Delete([]byte(username))
+	})
+}
+
+// Authenticate - checks username and password and returns the user, ErrInvalidCredentials when they don't match
+func (a *AuthStore) Authenticate(username, password string) (*User, error) {
+	user, err := a.GetUser(username)
+	if err == ErrUserNotFound {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !checkPassword(user.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// tokenClaims - claims of issued tokens
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// tokenHeader - header of issued tokens, tokens with other algorithm are rejected
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// IssueToken - returns token (HS256 signed JWT) for given user and its expiry time
+func (a *AuthStore) IssueToken(username string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(a.TokenExpiry)
+	claims, err := json.Marshal(tokenClaims{Subject: username, IssuedAt: now.Unix(), ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return "", expiresAt, err
+	}
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return unsigned + "." + a.sign(unsigned), expiresAt, nil
+}
+
+// VerifyToken - checks token signature and expiry and returns its user, ErrInvalidToken when token isn't valid.
+// User is read from the store so role changes apply to issued tokens
+func (a *AuthStore) VerifyToken(token string) (*User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, ErrInvalidToken
+	}
+	unsigned := parts[0] + "." + parts[1]
+	if subtle.ConstantTimeCompare([]byte(parts[2]), []byte(a.sign(unsigned))) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	bts, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(bts, &claims); err != nil || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+
+	// deleted users lose access straight away
+	user, err := a.GetUser(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return user, nil
+}
+
+// sign - returns signature of unsigned token
+func (a *AuthStore) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, a.Secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// hashPassword - returns password hash in "pbkdf2-sha256$iterations$salt$key" format
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2SHA256([]byte(password), salt, passwordIterations, passwordKeySize)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordHashScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkPassword - checks password against hash returned by hashPassword
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordHashScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, pbkdf2SHA256([]byte(password), salt, iterations, len(key))) == 1
+}
+
+// pbkdf2SHA256 - derives key from password (PBKDF2 with HMAC-SHA256, RFC 2898)
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLen)
+	block := make([]byte, 4)
+	for i := uint32(1); len(key) < keyLen; i++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(block, i)
+		prf.Write(block)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// authenticatedUser - returns user authenticated by Authorization header, either bearer token or basic credentials
+func (a *AuthStore) authenticatedUser(header string) (*User, bool) {
+	scheme, credentials := header, ""
+	if i := strings.IndexByte(header, ' '); i >= 0 {
+		scheme, credentials = header[:i], strings.TrimSpace(header[i+1:])
+	}
+
+	switch {
+	case strings.EqualFold(scheme, "Bearer"):
+		user, err := a.VerifyToken(credentials)
+		return user, err == nil
+	case strings.EqualFold(scheme, "Basic"):
+		decoded, err := base64.StdEncoding.DecodeString(credentials)
+		if err != nil {
+			return nil, false
+		}
+		i := strings.IndexByte(string(decoded), ':')
+		if i < 0 {
+			return nil, false
+		}
+		user, err := a.Authenticate(string(decoded[:i]), string(decoded[i+1:]))
+		return user, err == nil
+	}
+	return nil, false
+}
+
+// readOnlyAllowed - checks whether read-only users can make the request, they can view records, stats and settings,
+// compare simulations and verify requests but can't change anything, download database backups or manage users
+func readOnlyAllowed(req *http.Request) bool {
+	path := strings.TrimPrefix(req.URL.Path, "/api")
+	if path == "/backup" || path == "/users" || strings.HasPrefix(path, "/users/") {
+		return false
+	}
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	case "POST":
+		return path == "/records/diff" || path == "/journal/verify" || path == "/debug/match"
+	}
+	return false
+}
+
+// AdminAuthHandler - wraps admin interface so that only users with valid bearer token or basic credentials can use
+// it when authentication is enabled, tokens are issued by TokenAuthPath endpoint which stays open. Read-only users
+// get forbidden response to requests that would change anything
+func (d *DBClient) AdminAuthHandler(admin http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !d.Cfg.AuthEnabled || req.URL.Path == TokenAuthPath {
+			admin.ServeHTTP(w, req)
+			return
+		}
+
+		var user *User
+		authorized := false
+		if d.Auth != nil {
+			user, authorized = d.Auth.authenticatedUser(req.Header.Get("Authorization"))
+		}
+		if !authorized {
+			log.WithFields(log.Fields{
+				"remoteAddr": req.RemoteAddr,
+				"method":     req.Method,
+				"path":       req.URL.Path,
+			}).Warn("Admin authentication failed")
+
+			w.Header().Add("WWW-Authenticate", `Basic realm="`+adminAuthRealm+`"`)
+			w.Header().Add("WWW-Authenticate", `Bearer realm="`+adminAuthRealm+`"`)
+			writeMessage(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		if !user.IsAdmin() && !readOnlyAllowed(req) {
+			log.WithFields(log.Fields{
+				"remoteAddr": req.RemoteAddr,
+				"method":     req.Method,
+				"path":       req.URL.Path,
+				"username":   user.Username,
+			}).Warn("Read-only user denied")
+
+			writeMessage(w, http.StatusForbidden, fmt.Sprintf("User %s has read-only access", user.Username))
+			return
+		}
+
+		admin.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,215 @@
+package hoverfly
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAuthStore(t *testing.T) *AuthStore {
+	auth, err := NewAuthStore(TestDB, GetRandomName(10), "secret", time.Hour)
+	expect(t, err, nil)
+	return auth
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914 test vectors
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	expect(t, hex.EncodeToString(key), "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"+
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783")
+
+	key = pbkdf2SHA256([]byte("password"), []byte("salt"), 4096, 32)
+	expect(t, hex.EncodeToString(key), "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a")
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := hashPassword("s3cret")
+	expect(t, err, nil)
+	expect(t, strings.HasPrefix(hash, "pbkdf2-sha256$10000$"), true)
+	refute(t, strings.Contains(hash, "s3cret"), true)
+
+	expect(t, checkPassword(hash, "s3cret"), true)
+	expect(t, checkPassword(hash, "S3cret"), false)
+	expect(t, checkPassword("plain", "plain"), false)
+
+	// every hash has its own salt
+	other, _ := hashPassword("s3cret")
+	refute(t, other, hash)
+}
+
+func TestAuthStoreUsers(t *testing.T) {
+	auth := testAuthStore(t)
+
+	users, err := auth.GetAllUsers()
+	expect(t, err, nil)
+	expect(t, len(users), 0)
+
+	expect(t, auth.AddUser("bob", "builder", RoleReadOnly), nil)
+	expect(t, auth.AddUser("alice", "wonderland", RoleAdmin), nil)
+	refute(t, auth.AddUser("carol", "", RoleAdmin), nil)
+	refute(t, auth.AddUser("carol:x", "password", RoleAdmin), nil)
+	refute(t, auth.AddUser("carol", "password", "superuser"), nil)
+
+	users, _ = auth.GetAllUsers()
+	expect(t, len(users), 2)
+	expect(t, users[0].Username, "alice")
+	expect(t, users[0].IsAdmin(), true)
+	expect(t, users[1].Username, "bob")
+	expect(t, users[1].IsAdmin(), false)
+
+	admins, err := auth.AdminsCount()
+	expect(t, err, nil)
+	expect(t, admins, 1)
+
+	user, err := auth.Authenticate("alice", "wonderland")
+	expect(t, err, nil)
+	expect(t, user.Username, "alice")
+	_, err = auth.Authenticate("alice", "builder")
+	expect(t, err, ErrInvalidCredentials)
+	_, err = auth.Authenticate("carol", "wonderland")
+	expect(t, err, ErrInvalidCredentials)
+
+	// password and role are changed
+	expect(t, auth.AddUser("bob", "the-builder", RoleAdmin), nil)
+	_, err = auth.Authenticate("bob", "builder")
+	expect(t, err, ErrInvalidCredentials)
+	user, err = auth.Authenticate("bob", "the-builder")
+	expect(t, err, nil)
+	expect(t, user.IsAdmin(), true)
+
+	expect(t, auth.DeleteUser("alice"), nil)
+	expect(t, auth.DeleteUser("alice"), ErrUserNotFound)
+	_, err = auth.GetUser("alice")
+	expect(t, err, ErrUserNotFound)
+}
+
+func TestUserWithoutRoleIsAdmin(t *testing.T) {
+	user := User{Username: "alice"}
+	expect(t, user.IsAdmin(), true)
+	user.Role = RoleReadOnly
+	expect(t, user.IsAdmin(), false)
+}
+
+func TestAuthStoreTokens(t *testing.T) {
+	auth := testAuthStore(t)
+	auth.AddUser("alice", "wonderland", RoleAdmin)
+
+	token, expiresAt, err := auth.IssueToken("alice")
+	expect(t, err, nil)
+	expect(t, expiresAt.After(time.Now().Add(59*time.Minute)), true)
+
+	user, err := auth.VerifyToken(token)
+	expect(t, err, nil)
+	expect(t, user.Username, "alice")
+
+	// signed with other secret
+	other := testAuthStore(t)
+	other.DS, other.Bucket, other.Secret = auth.DS, auth.Bucket, []byte("other")
+	_, err = other.VerifyToken(token)
+	expect(t, err, ErrInvalidToken)
+
+	// tampered claims
+	parts := strings.Split(token, ".")
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"alice","iat":0,"exp":99999999999}`))
+	_, err = auth.VerifyToken(parts[0] + "." + claims + "." + parts[2])
+	expect(t, err, ErrInvalidToken)
+
+	// unsigned token
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	_, err = auth.VerifyToken(none + "." + claims + ".")
+	expect(t, err, ErrInvalidToken)
+
+	// expired
+	auth.TokenExpiry = -time.Minute
+	expired, _, _ := auth.IssueToken("alice")
+	_, err = auth.VerifyToken(expired)
+	expect(t, err, ErrInvalidToken)
+
+	// deleted user
+	auth.DeleteUser("alice")
+	_, err = auth.VerifyToken(token)
+	expect(t, err, ErrInvalidToken)
+}
+
+func TestAdminAuthHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	dbClient.Cfg.AuthEnabled = true
+	dbClient.Auth = testAuthStore(t)
+	dbClient.Auth.AddUser("alice", "wonderland", RoleAdmin)
+	token, _, _ := dbClient.Auth.IssueToken("alice")
+
+	admin := dbClient.AdminAuthHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(method, path, authorization string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("DELETE", "/records", "")
+	expect(t, rec.Code, http.StatusUnauthorized)
+	expect(t, len(rec.Header()["Www-Authenticate"]), 2)
+
+	expect(t, serve("DELETE", "/records", "Bearer "+token).Code, http.StatusOK)
+	expect(t, serve("DELETE", "/records", "Bearer "+token+"x").Code, http.StatusUnauthorized)
+
+	basic := base64.StdEncoding.EncodeToString([]byte("alice:wonderland"))
+	expect(t, serve("GET", "/api/records", "Basic "+basic).Code, http.StatusOK)
+	basic = base64.StdEncoding.EncodeToString([]byte("alice:builder"))
+	expect(t, serve("GET", "/api/records", "Basic "+basic).Code, http.StatusUnauthorized)
+
+	// tokens are issued without authentication
+	expect(t, serve("POST", TokenAuthPath, "").Code, http.StatusOK)
+
+	dbClient.Cfg.AuthEnabled = false
+	expect(t, serve("DELETE", "/records", "").Code, http.StatusOK)
+}
+
+func TestAdminAuthHandlerReadOnly(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	dbClient.Cfg.AuthEnabled = true
+	dbClient.Auth = testAuthStore(t)
+	dbClient.Auth.AddUser("bob", "builder", RoleReadOnly)
+	token, _, _ := dbClient.Auth.IssueToken("bob")
+
+	admin := dbClient.AdminAuthHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(method, path string) int {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, path := range []string{"/records", "/api/records/search", "/stats", "/count", "/state", "/delays"} {
+		expect(t, serve("GET", path), http.StatusOK)
+	}
+	expect(t, serve("POST", "/api/records/diff"), http.StatusOK)
+	expect(t, serve("POST", "/api/journal/verify"), http.StatusOK)
+	expect(t, serve("POST", "/api/debug/match"), http.StatusOK)
+	expect(t, serve("DELETE", "/api/journal"), http.StatusForbidden)
+
+	expect(t, serve("POST", "/state"), http.StatusForbidden)
+	expect(t, serve("POST", "/records"), http.StatusForbidden)
+	expect(t, serve("DELETE", "/api/records"), http.StatusForbidden)
+	expect(t, serve("PUT", "/delays"), http.StatusForbidden)
+	expect(t, serve("GET", "/backup"), http.StatusForbidden)
+	expect(t, serve("GET", "/api/users"), http.StatusForbidden)
+
+	// role changes apply to issued tokens
+	dbClient.Auth.AddUser("bob", "builder", RoleAdmin)
+	expect(t, serve("DELETE", "/api/records"), http.StatusOK)
+}
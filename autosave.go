@@ -0,0 +1,158 @@
+package hoverfly
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ExportToFile - writes all captured requests to given file in the same format as records export, as YAML when
+// file has .yaml or .yml extension. File is replaced atomically so it's never left half written
+func (d *DBClient) ExportToFile(path string) (written int, err error) {
+	tmp, err := os.Create(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp"))
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	buf := bufio.NewWriter(tmp)
+	if isYAMLPath(path) {
+		written, err = writeYAMLSimulation(buf, d.Cache)
+	} else {
+		written, err = writeJSONSimulation(buf, d.Cache)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err = buf.Flush(); err != nil {
+		return 0, err
+	}
+	if err = tmp.Close(); err != nil {
+		return 0, err
+	}
+	err = os.Rename(tmp.Name(), path)
+	return written, err
+}
+
+// writeJSONSimulation - streams captured requests as JSON simulation
+func writeJSONSimulation(buf *bufio.Writer, cache Cache) (written int, err error) {
+	buf.WriteString(simulationPrefix())
+	err = cache.ForEachRequest(func(pl Payload) error {
+		b, err := json.Marshal(pl)
+		if err != nil {
+			return err
+		}
+		if written > 0 {
+			buf.WriteString(",")
+		}
+		written++
+		_, err = buf.Write(b)
+		return err
+	})
+	buf.WriteString("]}")
+	return written, err
+}
+
+// writeYAMLSimulation - writes captured requests as YAML simulation, unlike JSON it can't be streamed
+func writeYAMLSimulation(buf *bufio.Writer, cache Cache) (int, error) {
+	records, err := cache.GetAllRequests()
+	if err != nil {
+		return 0, err
+	}
+	b, err := marshalYAML(recordedRequests{Meta: currentSimulationMeta(), Data: records})
+	if err != nil {
+		return 0, err
+	}
+	_, err = buf.Write(b)
+	return len(records), err
+}
+
+// LoadAutosave - imports simulation from autosave file, missing or empty file is not an error so that
+// the first run can start with an empty cache
+func (d *DBClient) LoadAutosave(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("Got error while reading autosave file, error %s", err.Error())
+	}
+	var payloads []Payload
+	if isYAMLPath(path) {
+		payloads, err = decodeYAMLSimulation(data)
+	} else {
+		payloads, err = decodeSimulation(data)
+	}
+	if err != nil {
+		return fmt.Errorf("Got error while parsing autosave file, error %s", err.Error())
+	}
+	if len(payloads) == 0 {
+		return nil
+	}
+	return d.ImportPayloads(payloads)
+}
+
+// Autosave - exports captured requests to given file and logs the outcome
+func (d *DBClient) Autosave(path string) {
+	written, err := d.ExportToFile(path)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"file":  path,
+		}).Error("Failed to autosave simulation")
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"file":    path,
+		"records": written,
+	}).Debug("Simulation autosaved")
+}
+
+// StartAutosave - periodically exports captured requests to given file until stop channel is closed
+func (d *DBClient) StartAutosave(path string, interval time.Duration, stop <-chan struct{}) {
+	runEvery(interval, stop, func() {
+		d.Autosave(path)
+	})
+}
+
+// runEvery - calls given function in the background every interval until stop channel is closed, nothing is run
+// when interval isn't positive
+func runEvery(interval time.Duration, stop <-chan struct{}, fn func()) {
+	if interval <= 0 {
+		log.WithFields(log.Fields{
+			"interval": interval,
+		}).Error("Interval has to be positive, periodic task not started")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
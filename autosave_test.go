@@ -0,0 +1,117 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestExportToFileAndLoadAutosave(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	for _, path := range []string{"/first", "/second"} {
+		req, err := http.NewRequest("GET", "http://example.com"+path, nil)
+		expect(t, err, nil)
+		dbClient.captureRequest(req)
+	}
+
+	name := "autosave_test.json"
+	defer os.Remove(name)
+
+	written, err := dbClient.ExportToFile(name)
+	expect(t, err, nil)
+	expect(t, written, 2)
+
+	body, err := ioutil.ReadFile(name)
+	expect(t, err, nil)
+	var requests recordedRequests
+	err = json.Unmarshal(body, &requests)
+	expect(t, err, nil)
+	expect(t, len(requests.Data), 2)
+
+	// restoring into an empty cache
+	otherServer, otherClient := testTools(201, `{'message': 'here'}`)
+	defer otherServer.Close()
+	defer otherClient.Cache.DeleteData()
+
+	err = otherClient.LoadAutosave(name)
+	expect(t, err, nil)
+
+	count, err := otherClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 2)
+}
+
+func TestLoadAutosaveIntoReadOnlyCache(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	req, err := http.NewRequest("GET", "http://example.com/first", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	name := "autosave_readonly_test.json"
+	defer os.Remove(name)
+	_, err = dbClient.ExportToFile(name)
+	expect(t, err, nil)
+
+	otherServer, otherClient := testTools(201, `{'message': 'here'}`)
+	defer otherServer.Close()
+	defer otherClient.Cache.DeleteData()
+	otherClient.Cache = NewReadOnlyCache(otherClient.Cache)
+
+	expect(t, otherClient.Writable().LoadAutosave(name), nil)
+	count, err := otherClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 1)
+
+	// autosave keeps running, it only reads the cache
+	_, err = otherClient.ExportToFile(name)
+	expect(t, err, nil)
+	expect(t, otherClient.Cache.DeleteData(), ErrReadOnly)
+}
+
+func TestRunEveryIgnoresIntervalThatIsNotPositive(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	called := make(chan struct{}, 1)
+	runEvery(0, stop, func() { called <- struct{}{} })
+	runEvery(-time.Second, stop, func() { called <- struct{}{} })
+
+	select {
+	case <-called:
+		t.Error("function shouldn't be called")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestExportToFileEmptyCache(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	name := "autosave_empty_test.json"
+	defer os.Remove(name)
+
+	written, err := dbClient.ExportToFile(name)
+	expect(t, err, nil)
+	expect(t, written, 0)
+
+	// empty simulation is loaded without errors
+	err = dbClient.LoadAutosave(name)
+	expect(t, err, nil)
+}
+
+func TestLoadAutosaveMissingFile(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	err := dbClient.LoadAutosave("does_not_exist.json")
+	expect(t, err, nil)
+}
@@ -0,0 +1,85 @@
+package hoverfly
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// BodyEncodingBase64 - body is base64 encoded. JSON strings can't hold arbitrary bytes, so bodies that aren't valid
+// UTF-8 (images, PDFs, protobuf messages...) are exported base64 encoded, other bodies are exported as they are.
+// Bodies are always kept decoded in the cache, encoding only applies to JSON (and YAML) representation of records
+const BodyEncodingBase64 = "base64"
+
+// encodeBody - returns body and its encoding as it should be exported
+func encodeBody(body string) (string, string) {
+	if utf8.ValidString(body) {
+		return body, ""
+	}
+	return base64.StdEncoding.EncodeToString([]byte(body)), BodyEncodingBase64
+}
+
+// decodeBody - returns body of given encoding as it was captured
+func decodeBody(body, encoding string) (string, error) {
+	switch encoding {
+	case "", "plain":
+		return body, nil
+	case BodyEncodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return "", fmt.Errorf("invalid base64 body: %s", err.Error())
+		}
+		return string(decoded), nil
+	}
+	return "", fmt.Errorf("unknown body encoding '%s', use plain or base64", encoding)
+}
+
+// MarshalJSON - encodes request, body which isn't valid UTF-8 is base64 encoded
+func (r RequestDetails) MarshalJSON() ([]byte, error) {
+	// alias type doesn't have the methods so it's encoded as usual
+	type requestDetails RequestDetails
+	encoded := requestDetails(r)
+	encoded.Body, encoded.BodyEncoding = encodeBody(r.Body)
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON - decodes request, body is decoded according to its encoding
+func (r *RequestDetails) UnmarshalJSON(data []byte) error {
+	type requestDetails RequestDetails
+	var decoded requestDetails
+	err := json.Unmarshal(data, &decoded)
+	if err == nil {
+		decoded.Body, err = decodeBody(decoded.Body, decoded.BodyEncoding)
+	}
+	if err != nil {
+		return err
+	}
+	decoded.BodyEncoding = ""
+	*r = RequestDetails(decoded)
+	return nil
+}
+
+// MarshalJSON - encodes response, body which isn't valid UTF-8 is base64 encoded
+func (r ResponseDetails) MarshalJSON() ([]byte, error) {
+	type responseDetails ResponseDetails
+	encoded := responseDetails(r)
+	encoded.Body, encoded.BodyEncoding = encodeBody(r.Body)
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON - decodes response, body is decoded according to its encoding
+func (r *ResponseDetails) UnmarshalJSON(data []byte) error {
+	type responseDetails ResponseDetails
+	var decoded responseDetails
+	err := json.Unmarshal(data, &decoded)
+	if err == nil {
+		decoded.Body, err = decodeBody(decoded.Body, decoded.BodyEncoding)
+	}
+	if err != nil {
+		return err
+	}
+	decoded.BodyEncoding = ""
+	*r = ResponseDetails(decoded)
+	return nil
+}
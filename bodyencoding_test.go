@@ -0,0 +1,53 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBinaryBodiesRoundTrip(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\xff"
+	payload := Payload{
+		Request:  RequestDetails{Method: "POST", Path: "/upload", Body: "\x08\x96\x01"},
+		Response: ResponseDetails{Status: 200, Body: png},
+		Sequence: []ResponseDetails{{Status: 200, Body: "text"}},
+	}
+
+	data, err := json.Marshal(payload)
+	expect(t, err, nil)
+	expect(t, strings.Contains(string(data), `"body":"iVBORw0KGgoAAAANSUhEUv8="`), true)
+	expect(t, strings.Count(string(data), `"bodyEncoding":"base64"`), 2)
+	// text bodies are exported as they are
+	expect(t, strings.Contains(string(data), `"body":"text","headers":null}`), true)
+
+	var decoded Payload
+	expect(t, json.Unmarshal(data, &decoded), nil)
+	expect(t, decoded.Request.Body, "\x08\x96\x01")
+	expect(t, decoded.Response.Body, png)
+	expect(t, decoded.Response.BodyEncoding, "")
+	expect(t, decoded.Sequence[0].Body, "text")
+
+	// YAML is encoded through JSON
+	yaml, err := marshalYAML(recordedRequests{Meta: currentSimulationMeta(), Data: []Payload{payload}})
+	expect(t, err, nil)
+	payloads, err := decodeYAMLSimulation(yaml)
+	expect(t, err, nil)
+	expect(t, payloads[0].Response.Body, png)
+}
+
+func TestDecodeBodyEncodings(t *testing.T) {
+	var response ResponseDetails
+	expect(t, json.Unmarshal([]byte(`{"status": 200, "body": "aGVsbG8=", "bodyEncoding": "base64"}`), &response), nil)
+	expect(t, response.Body, "hello")
+
+	expect(t, json.Unmarshal([]byte(`{"status": 200, "body": "aGVsbG8=", "bodyEncoding": "plain"}`), &response), nil)
+	expect(t, response.Body, "aGVsbG8=")
+
+	refute(t, json.Unmarshal([]byte(`{"body": "not base64!", "bodyEncoding": "base64"}`), &response), nil)
+	refute(t, json.Unmarshal([]byte(`{"body": "x", "bodyEncoding": "gzip"}`), &response), nil)
+
+	var request RequestDetails
+	expect(t, json.Unmarshal([]byte(`{"path": "/", "body": "AAE=", "bodyEncoding": "base64"}`), &request), nil)
+	expect(t, request.Body, "\x00\x01")
+}
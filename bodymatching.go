@@ -0,0 +1,265 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Body matching modes, they decide which request bodies are considered equal
+const (
+	// BodyMatchExact - bodies have to be byte for byte equal
+	BodyMatchExact = "exact"
+	// BodyMatchJSON - JSON bodies are equal when they have the same values, key order and whitespace are ignored
+	BodyMatchJSON = "json"
+	// BodyMatchXML - XML bodies are equal when their canonical forms are, attribute order, whitespace between
+	// elements, comments and namespace prefixes are ignored
+	BodyMatchXML = "xml"
+	// BodyMatchForm - form encoded bodies are equal when they have the same fields, field order is ignored and so
+	// are ignored fields
+	BodyMatchForm = "form"
+	// BodyMatchMultipart - multipart/form-data bodies are equal when they have the same parts with the same content,
+	// boundary and part order are ignored
+	BodyMatchMultipart = "multipart"
+)
+
+// IsBodyMatching - checks whether body matching mode is known
+func IsBodyMatching(mode string) bool {
+	switch mode {
+	case BodyMatchExact, BodyMatchJSON, BodyMatchXML, BodyMatchForm, BodyMatchMultipart:
+		return true
+	}
+	return false
+}
+
+// normalizeBody - returns form of body that is equal for all bodies equal in given mode, bodies that can't be parsed
+// are matched exactly. Ignored fields only apply to form and multipart bodies
+func normalizeBody(mode, body string, ignoredFields ...string) string {
+	var normalized string
+	var err error
+	switch mode {
+	case BodyMatchJSON:
+		normalized, err = canonicalJSON(body)
+	case BodyMatchXML:
+		normalized, err = canonicalXML(body)
+	case BodyMatchForm:
+		normalized, err = canonicalForm(body, ignoredFields)
+	case BodyMatchMultipart:
+		normalized, err = canonicalMultipart(body, ignoredFields, false)
+	default:
+		return body
+	}
+	if err != nil {
+		return body
+	}
+	return normalized
+}
+
+// canonicalJSON - returns compact JSON with sorted object keys, numbers keep their precision
+func canonicalJSON(body string) (string, error) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return "", fmt.Errorf("unexpected data after JSON value")
+	}
+
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(canonical), nil
+}
+
+// xmlAttrs - attributes sorted by namespace and name
+type xmlAttrs []xml.Attr
+
+func (a xmlAttrs) Len() int      { return len(a) }
+func (a xmlAttrs) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a xmlAttrs) Less(i, j int) bool {
+	if a[i].Name.Space != a[j].Name.Space {
+		return a[i].Name.Space < a[j].Name.Space
+	}
+	return a[i].Name.Local < a[j].Name.Local
+}
+
+// canonicalXML - returns XML with resolved namespaces and sorted attributes, without declarations, comments and
+// whitespace between elements
+func canonicalXML(body string) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(body))
+
+	var buf bytes.Buffer
+	encoder := xml.NewEncoder(&buf)
+	elements := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			elements++
+			attrs := make(xmlAttrs, 0, len(t.Attr))
+			for _, attr := range t.Attr {
+				// namespaces are already resolved, prefixes don't matter
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				attrs = append(attrs, attr)
+			}
+			sort.Sort(attrs)
+			t.Attr = attrs
+			err = encoder.EncodeToken(t)
+		case xml.EndElement:
+			err = encoder.EncodeToken(t)
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				err = encoder.EncodeToken(t.Copy())
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if elements == 0 {
+		return "", fmt.Errorf("body has no XML elements")
+	}
+
+	if err := encoder.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// BodyMatcher - body matching mode of requests with matching URL and method, i.e. JSON equality for REST API and
+// exact match for everything else
+type BodyMatcher struct {
+	// URLPattern - regular expression matched against request host and path, all requests match when empty
+	URLPattern string `json:"urlPattern,omitempty"`
+	// HTTPMethod - method of matching requests, all methods match when empty
+	HTTPMethod string `json:"httpMethod,omitempty"`
+	// Mode - BodyMatchExact, BodyMatchJSON, BodyMatchXML, BodyMatchForm or BodyMatchMultipart
+	Mode string `json:"mode"`
+	// IgnoreFields - form fields left out when bodies are matched in form or multipart mode, added to globally
+	// ignored ones
+	IgnoreFields []string `json:"ignoreFields,omitempty"`
+	// IgnoreFileContents - file parts of multipart bodies are matched only by field and file name
+	IgnoreFileContents bool `json:"ignoreFileContents,omitempty"`
+
+	urlRe *regexp.Regexp
+}
+
+// compile - validates matcher and compiles its URL pattern
+func (m *BodyMatcher) compile() error {
+	re, err := regexp.Compile(m.URLPattern)
+	if err != nil {
+		return fmt.Errorf("invalid URL pattern '%s': %s", m.URLPattern, err.Error())
+	}
+	if !IsBodyMatching(m.Mode) {
+		return fmt.Errorf("unknown body matching mode '%s', use %s, %s, %s, %s or %s", m.Mode, BodyMatchExact,
+			BodyMatchJSON, BodyMatchXML, BodyMatchForm, BodyMatchMultipart)
+	}
+	m.urlRe = re
+	return nil
+}
+
+// matches - checks whether matcher applies to request with given method, host and path
+func (m *BodyMatcher) matches(method, host, path string) bool {
+	if m.HTTPMethod != "" && !strings.EqualFold(m.HTTPMethod, method) {
+		return false
+	}
+	return m.urlRe.MatchString(host + path)
+}
+
+// BodyMatchers - ordered body matchers, only the first matching one is used
+type BodyMatchers struct {
+	mu       sync.RWMutex
+	matchers []BodyMatcher
+}
+
+// NewBodyMatchers - returns empty body matcher list, bodies are matched in globally configured mode
+func NewBodyMatchers() *BodyMatchers {
+	return &BodyMatchers{}
+}
+
+// Set - validates and replaces all matchers, current ones are kept when any of them is invalid
+func (b *BodyMatchers) Set(matchers []BodyMatcher) error {
+	compiled := make([]BodyMatcher, len(matchers))
+	for i, matcher := range matchers {
+		if err := matcher.compile(); err != nil {
+			return err
+		}
+		compiled[i] = matcher
+	}
+
+	b.mu.Lock()
+	b.matchers = compiled
+	b.mu.Unlock()
+	return nil
+}
+
+// Get - returns current matchers
+func (b *BodyMatchers) Get() []BodyMatcher {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]BodyMatcher{}, b.matchers...)
+}
+
+// Clear - removes all matchers
+func (b *BodyMatchers) Clear() {
+	b.mu.Lock()
+	b.matchers = nil
+	b.mu.Unlock()
+}
+
+// For - returns matcher of request with given method, host and path, false when there is none
+func (b *BodyMatchers) For(method, host, path string) (BodyMatcher, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for i := range b.matchers {
+		if b.matchers[i].matches(method, host, path) {
+			return b.matchers[i], true
+		}
+	}
+	return BodyMatcher{}, false
+}
+
+// bodyMatching - returns body matching settings of request, matcher of the request is used first, then global
+// configuration. Globally ignored form fields are always included
+func (d *DBClient) bodyMatching(request RequestDetails) BodyMatcher {
+	matching := BodyMatcher{Mode: d.Cfg.GetBodyMatching()}
+	if d.BodyMatchers != nil {
+		if matcher, ok := d.BodyMatchers.For(request.Method, request.Destination, request.Path); ok {
+			matching = matcher
+		}
+	}
+	matching.IgnoreFields = append(append([]string{}, d.Cfg.IgnoreFormFields...), matching.IgnoreFields...)
+	return matching
+}
+
+// matchedBody - returns request body in the form it's matched in
+func (d *DBClient) matchedBody(request RequestDetails) string {
+	matching := d.bodyMatching(request)
+	if matching.Mode == BodyMatchMultipart && matching.IgnoreFileContents {
+		if normalized, err := canonicalMultipart(request.Body, matching.IgnoreFields, true); err == nil {
+			return normalized
+		}
+		return request.Body
+	}
+	return normalizeBody(matching.Mode, request.Body, matching.IgnoreFields...)
+}
@@ -0,0 +1,100 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeJSONBody(t *testing.T) {
+	a := normalizeBody(BodyMatchJSON, `{"name": "alice", "tags": ["a", "b"], "id": 12345678901234567890}`)
+	b := normalizeBody(BodyMatchJSON, "{\n  \"id\": 12345678901234567890,\n  \"tags\": [\"a\",\"b\"],\n  \"name\":\"alice\"\n}")
+	expect(t, a, b)
+
+	// array order matters
+	refute(t, normalizeBody(BodyMatchJSON, `{"tags": ["b", "a"]}`), normalizeBody(BodyMatchJSON, `{"tags": ["a", "b"]}`))
+
+	// invalid JSON is matched exactly
+	expect(t, normalizeBody(BodyMatchJSON, `{"name": `), `{"name": `)
+	expect(t, normalizeBody(BodyMatchJSON, `{} {}`), `{} {}`)
+}
+
+func TestNormalizeXMLBody(t *testing.T) {
+	a := normalizeBody(BodyMatchXML, `<?xml version="1.0"?>
+<order id="1" currency="EUR">
+  <!-- comment -->
+  <item sku="A">2</item>
+</order>`)
+	b := normalizeBody(BodyMatchXML, `<order currency="EUR" id="1"><item sku="A">2</item></order>`)
+	expect(t, a, b)
+
+	// namespace prefixes don't matter
+	a = normalizeBody(BodyMatchXML, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body/></s:Envelope>`)
+	b = normalizeBody(BodyMatchXML, `<Envelope xmlns="http://schemas.xmlsoap.org/soap/envelope/"><Body></Body></Envelope>`)
+	expect(t, a, b)
+
+	refute(t, normalizeBody(BodyMatchXML, `<item>2</item>`), normalizeBody(BodyMatchXML, `<item>3</item>`))
+
+	// bodies that aren't XML are matched exactly
+	expect(t, normalizeBody(BodyMatchXML, "plain text"), "plain text")
+	expect(t, normalizeBody(BodyMatchXML, "<open>"), "<open>")
+}
+
+func TestBodyMatchersFor(t *testing.T) {
+	matchers := NewBodyMatchers()
+	expect(t, matchers.Set([]BodyMatcher{
+		{URLPattern: `soap\.example\.com`, Mode: BodyMatchXML},
+		{HTTPMethod: "POST", Mode: BodyMatchJSON},
+	}), nil)
+
+	matcher, ok := matchers.For("POST", "soap.example.com", "/orders")
+	expect(t, ok, true)
+	expect(t, matcher.Mode, BodyMatchXML)
+
+	matcher, ok = matchers.For("post", "api.example.com", "/orders")
+	expect(t, ok, true)
+	expect(t, matcher.Mode, BodyMatchJSON)
+
+	_, ok = matchers.For("PUT", "api.example.com", "/orders")
+	expect(t, ok, false)
+
+	// invalid matchers keep current ones
+	refute(t, matchers.Set([]BodyMatcher{{Mode: "fuzzy"}}), nil)
+	expect(t, len(matchers.Get()), 2)
+}
+
+func TestJSONBodyMatching(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.BodyMatching = BodyMatchJSON
+
+	storeTestPayload(dbClient, "POST", "http://api.example.com/users", `{"name": "alice", "age": 30}`, 201, "created")
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("POST", "http://api.example.com/users", strings.NewReader(`{"age":30,"name":"alice"}`))
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, resp.StatusCode, 201)
+	expect(t, string(body), "created")
+
+	// matcher overrides global mode
+	expect(t, dbClient.BodyMatchers.Set([]BodyMatcher{{URLPattern: "/users", Mode: BodyMatchExact}}), nil)
+	req, _ = http.NewRequest("POST", "http://api.example.com/users", strings.NewReader(`{"age": 30, "name": "alice"}`))
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+}
+
+func TestSettingsBodyMatchingEnv(t *testing.T) {
+	defer os.Setenv("HoverflyBodyMatching", "")
+
+	os.Setenv("HoverflyBodyMatching", "xml")
+	cfg := InitSettings()
+	expect(t, cfg.GetBodyMatching(), BodyMatchXML)
+
+	os.Setenv("HoverflyBodyMatching", "fuzzy")
+	cfg = InitSettings()
+	expect(t, cfg.GetBodyMatching(), BodyMatchExact)
+}
@@ -2,7 +2,14 @@ package hoverfly
 
 import (
 	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/boltdb/bolt"
@@ -11,19 +18,62 @@ import (
 // Cache - cache interface used to store and retrieve request/response payloads or anything else
 type Cache interface {
 	Set(key, value []byte) error
+	SetWithExpiry(key, value []byte, ttl time.Duration) error
 	Get(key []byte) ([]byte, error)
 	GetAllRequests() ([]Payload, error)
+	ForEachRequest(fn func(Payload) error) error
+	GetRequestsPage(offset, limit int) ([]Payload, error)
 	RecordsCount() (int, error)
+	RecordsCountByDestination() (map[string]int, error)
 	DeleteData() error
+	DeleteKey(key []byte) error
+	DeleteDataWhere(filter PayloadFilter) (int, error)
 	GetAllKeys() (map[string]bool, error)
+	GetKeysWithPrefix(prefix []byte) ([][]byte, error)
+	SetMulti(pairs []KeyValue) error
+	Stats() CacheStats
 	CloseDB()
 }
 
+// KeyValue - key and value pair, used to save several records at once
+type KeyValue struct {
+	Key   []byte
+	Value []byte
+}
+
+// BackupWriter - implemented by caches that can write a consistent snapshot of the whole database
+// while still serving requests
+type BackupWriter interface {
+	WriteBackup(w io.Writer) (int64, error)
+}
+
+// Namespacer - implemented by caches that can hold several independent simulations, only one of
+// them (current namespace) is used by Hoverfly at a time
+type Namespacer interface {
+	CurrentNamespace() string
+	ListNamespaces() ([]string, error)
+	CreateNamespace(name string) error
+	SwitchNamespace(name string) error
+	DeleteNamespace(name string) error
+}
+
+// countByDestination - counts records of given cache for each destination host, every record has to be
+// decoded since destination is not part of the key
+func countByDestination(c Cache) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := c.ForEachRequest(func(pl Payload) error {
+		counts[pl.Request.Destination]++
+		return nil
+	})
+	return counts, err
+}
+
 // NewBoltDBCache - returns new BoltCache instance
 func NewBoltDBCache(db *bolt.DB, bucket []byte) *BoltCache {
 	return &BoltCache{
-		DS:             db,
-		RequestsBucket: []byte(bucket),
+		DS:               db,
+		RequestsBucket:   []byte(bucket),
+		defaultNamespace: string(bucket),
 	}
 }
 
@@ -34,6 +84,21 @@ const RequestsBucketName = "rqbucket"
 type BoltCache struct {
 	DS             *bolt.DB
 	RequestsBucket []byte
+	// MaxRecords - when set, the oldest records are evicted once there are more records in the bucket
+	MaxRecords int
+	// Compress - when set, values are compressed before they are written, compressed and uncompressed
+	// values can be read regardless of this setting
+	Compress bool
+	// Cipher - when set, values are encrypted before they are written
+	Cipher cipher.AEAD
+
+	// mu - guards RequestsBucket and DS, read lock is held during every operation so that namespace
+	// can't be switched and database can't be compacted in the middle of it
+	mu sync.RWMutex
+	// defaultNamespace - bucket given when cache was created, it always exists
+	defaultNamespace string
+
+	counters cacheCounters
 }
 
 // GetDB - returns open BoltDB database with read/write permissions or goes down in flames if
@@ -52,28 +117,302 @@ func GetDB(name string) *bolt.DB {
 
 // CloseDB - closes database
 func (c *BoltCache) CloseDB() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.DS.Close()
 }
 
+// View - runs read-only transaction against current database, it's reopened when cache is compacted
+func (c *BoltCache) View(fn func(*bolt.Tx) error) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.DS.View(fn)
+}
+
+// Update - runs read-write transaction against current database, it's reopened when cache is compacted
+func (c *BoltCache) Update(fn func(*bolt.Tx) error) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.DS.Update(fn)
+}
+
+// expiryBucket - returns name of the bucket that holds expiry timestamps of the requests bucket records
+func (c *BoltCache) expiryBucket() []byte {
+	return []byte(string(c.RequestsBucket) + "_expiry")
+}
+
+// encodeExpiry - encodes expiry time to bytes
+func encodeExpiry(t time.Time) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(t.UnixNano()))
+	return b
+}
+
+// isExpired - checks whether encoded expiry time is in the past, records without expiry never expire
+func isExpired(expiry []byte, now time.Time) bool {
+	if len(expiry) != 8 {
+		return false
+	}
+	return int64(binary.BigEndian.Uint64(expiry)) <= now.UnixNano()
+}
+
+// orderBucket - returns name of the bucket that holds record keys in insertion order
+func (c *BoltCache) orderBucket() []byte {
+	return []byte(string(c.RequestsBucket) + "_order")
+}
+
+// orderIndexBucket - returns name of the bucket that holds current insertion sequence of each record key
+func (c *BoltCache) orderIndexBucket() []byte {
+	return []byte(string(c.RequestsBucket) + "_order_index")
+}
+
+// metaBuckets - returns names of all the buckets that hold information about requests bucket records
+func (c *BoltCache) metaBuckets() [][]byte {
+	return metaBucketsOf(c.RequestsBucket)
+}
+
+// metaBucketsOf - returns names of all the buckets that hold information about records of given bucket
+func metaBucketsOf(bucket []byte) [][]byte {
+	name := string(bucket)
+	return [][]byte{[]byte(name + "_expiry"), []byte(name + "_order"), []byte(name + "_order_index")}
+}
+
 // Set - saves given key and value pair to cache
 func (c *BoltCache) Set(key, value []byte) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	err := c.DS.Update(func(tx *bolt.Tx) error {
-		bucket, err := tx.CreateBucketIfNotExists(c.RequestsBucket)
+		count := unknownCount
+		err := c.put(tx, key, value, &count)
 		if err != nil {
 			return err
 		}
-		err = bucket.Put(key, value)
+		// overwritten record should not inherit previous expiry
+		if eb := tx.Bucket(c.expiryBucket()); eb != nil {
+			return eb.Delete(key)
+		}
+		return nil
+	})
+
+	if err == nil {
+		c.counters.set(1)
+	}
+	return err
+}
+
+// SetMulti - saves given key and value pairs to cache within a single transaction
+func (c *BoltCache) SetMulti(pairs []KeyValue) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err := c.DS.Update(func(tx *bolt.Tx) error {
+		count := unknownCount
+		eb := tx.Bucket(c.expiryBucket())
+		for _, kv := range pairs {
+			if err := c.put(tx, kv.Key, kv.Value, &count); err != nil {
+				return err
+			}
+			// overwritten record should not inherit previous expiry
+			if eb != nil {
+				if err := eb.Delete(kv.Key); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+
+	if err == nil {
+		c.counters.set(len(pairs))
+	}
+	return err
+}
+
+// SetWithExpiry - saves given key and value pair to cache, record is removed once ttl passes
+func (c *BoltCache) SetWithExpiry(key, value []byte, ttl time.Duration) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err := c.DS.Update(func(tx *bolt.Tx) error {
+		eb, err := tx.CreateBucketIfNotExists(c.expiryBucket())
 		if err != nil {
 			return err
 		}
-		return nil
+		count := unknownCount
+		err = c.put(tx, key, value, &count)
+		if err != nil {
+			return err
+		}
+		return eb.Put(key, encodeExpiry(time.Now().Add(ttl)))
 	})
 
+	if err == nil {
+		c.counters.set(1)
+	}
 	return err
 }
 
+// unknownCount - records count that wasn't calculated yet in current transaction
+const unknownCount = -1
+
+// put - saves given key and value pair within transaction, when MaxRecords is set insertion order
+// is tracked and the oldest records are evicted. Count holds records count within the transaction,
+// it is calculated on first put and kept up to date afterwards.
+func (c *BoltCache) put(tx *bolt.Tx, key, value []byte, count *int) error {
+	bucket, err := tx.CreateBucketIfNotExists(c.RequestsBucket)
+	if err != nil {
+		return err
+	}
+
+	if c.Compress {
+		value, err = compressValue(value)
+		if err != nil {
+			return err
+		}
+	}
+	if c.Cipher != nil {
+		value, err = encryptValue(value, c.Cipher)
+		if err != nil {
+			return err
+		}
+	}
+
+	// stats only reflect committed records so counting has to be done before changing the bucket
+	if c.MaxRecords > 0 {
+		if *count == unknownCount {
+			*count = bucket.Stats().KeyN
+			if err := c.seedOrder(tx, bucket, *count); err != nil {
+				return err
+			}
+		}
+		if bucket.Get(key) == nil {
+			*count++
+		}
+	}
+
+	err = bucket.Put(key, value)
+	if err != nil {
+		return err
+	}
+
+	if c.MaxRecords <= 0 {
+		return nil
+	}
+
+	ob, err := tx.CreateBucketIfNotExists(c.orderBucket())
+	if err != nil {
+		return err
+	}
+	ib, err := tx.CreateBucketIfNotExists(c.orderIndexBucket())
+	if err != nil {
+		return err
+	}
+
+	seq, err := ob.NextSequence()
+	if err != nil {
+		return err
+	}
+	// previous order entry of this key (if any) becomes stale and is skipped during eviction
+	if err = ob.Put(encodeSequence(seq), key); err != nil {
+		return err
+	}
+	if err = ib.Put(key, encodeSequence(seq)); err != nil {
+		return err
+	}
+
+	return c.evict(tx, count, ob, ib)
+}
+
+// encodeSequence - encodes bucket sequence to bytes so that cursor iterates in insertion order
+func encodeSequence(seq uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, seq)
+	return b
+}
+
+// seedOrder - adds records saved while MaxRecords wasn't set (i.e. in previous runs) to insertion order. Their
+// real order isn't known, so they are treated as older than all tracked records and evicted first, in key order
+func (c *BoltCache) seedOrder(tx *bolt.Tx, bucket *bolt.Bucket, count int) error {
+	ob, err := tx.CreateBucketIfNotExists(c.orderBucket())
+	if err != nil {
+		return err
+	}
+	ib, err := tx.CreateBucketIfNotExists(c.orderIndexBucket())
+	if err != nil {
+		return err
+	}
+	if ib.Stats().KeyN >= count {
+		return nil
+	}
+
+	return bucket.ForEach(func(key, _ []byte) error {
+		if ib.Get(key) != nil {
+			return nil
+		}
+		// zero sequence followed by the key sorts before every sequence given by NextSequence
+		seq := append(make([]byte, 8), key...)
+		if err := ob.Put(seq, key); err != nil {
+			return err
+		}
+		return ib.Put(key, seq)
+	})
+}
+
+// evict - removes the oldest records until there are no more than MaxRecords left
+func (c *BoltCache) evict(tx *bolt.Tx, count *int, ob, ib *bolt.Bucket) error {
+	cur := ob.Cursor()
+	for seq, key := cur.First(); seq != nil && *count > c.MaxRecords; seq, key = cur.First() {
+		// copying key since it's only valid until order entry is deleted
+		key = append([]byte{}, key...)
+
+		if err := cur.Delete(); err != nil {
+			return err
+		}
+		if !bytes.Equal(ib.Get(key), seq) {
+			// stale entry, record was updated or deleted since
+			continue
+		}
+
+		if err := c.deleteRecord(tx, key); err != nil {
+			return err
+		}
+		*count--
+
+		log.WithFields(log.Fields{
+			"key":        string(key),
+			"maxRecords": c.MaxRecords,
+		}).Debug("record evicted")
+	}
+	return nil
+}
+
+// deleteRecord - removes record and all information about it within transaction
+func (c *BoltCache) deleteRecord(tx *bolt.Tx, key []byte) error {
+	if bucket := tx.Bucket(c.RequestsBucket); bucket != nil {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	for _, name := range [][]byte{c.expiryBucket(), c.orderIndexBucket()} {
+		if b := tx.Bucket(name); b != nil {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Get - searches for given key in the cache and returns value if found
 func (c *BoltCache) Get(key []byte) (value []byte, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	expired := false
 
 	err = c.DS.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(c.RequestsBucket)
@@ -89,34 +428,168 @@ func (c *BoltCache) Get(key []byte) (value []byte, err error) {
 			return fmt.Errorf("key %q not found \n", key)
 		}
 
+		if eb := tx.Bucket(c.expiryBucket()); eb != nil && isExpired(eb.Get(key), time.Now()) {
+			expired = true
+			return fmt.Errorf("key %q not found \n", key)
+		}
+
 		buffer.Write(val)
-		value = buffer.Bytes()
-		return nil
+		value, err = openEnvelope(buffer.Bytes(), c.Cipher)
+		return err
 	})
 
+	if expired {
+		c.purge([][]byte{key})
+	}
+
+	c.counters.get(err == nil)
+	return
+}
+
+// ExpiresAt - returns when record expires, false is returned for records without expiry
+func (c *BoltCache) ExpiresAt(key []byte) (expiresAt time.Time, expires bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	c.DS.View(func(tx *bolt.Tx) error {
+		eb := tx.Bucket(c.expiryBucket())
+		if eb == nil {
+			return nil
+		}
+		if expiry := eb.Get(key); len(expiry) == 8 {
+			expiresAt, expires = time.Unix(0, int64(binary.BigEndian.Uint64(expiry))), true
+		}
+		return nil
+	})
 	return
 }
 
+// Stats - returns cache usage statistics collected since Hoverfly started
+func (c *BoltCache) Stats() CacheStats {
+	return c.counters.snapshot()
+}
+
+// purge - removes given expired keys together with their expiry timestamps
+func (c *BoltCache) purge(keys [][]byte) {
+	err := c.DS.Update(func(tx *bolt.Tx) error {
+		eb := tx.Bucket(c.expiryBucket())
+		if eb == nil {
+			return nil
+		}
+		now := time.Now()
+		for _, k := range keys {
+			// record might have been overwritten since it was found expired
+			if !isExpired(eb.Get(k), now) {
+				continue
+			}
+			if err := c.deleteRecord(tx, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"count": len(keys),
+		}).Warning("Failed to purge expired records")
+	}
+}
+
 // GetAllRequests - returns all captured requests/responses
 func (c *BoltCache) GetAllRequests() (payloads []Payload, err error) {
+	err = c.ForEachRequest(func(pl Payload) error {
+		payloads = append(payloads, pl)
+		return nil
+	})
+	return
+}
+
+// ForEachRequest - decodes captured requests/responses one by one and passes them to given function,
+// iteration stops when function returns an error
+func (c *BoltCache) ForEachRequest(fn func(Payload) error) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var expired [][]byte
+
+	err := c.DS.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(c.RequestsBucket)
+		if b == nil {
+			// bucket doesn't exist
+			return nil
+		}
+		eb := tx.Bucket(c.expiryBucket())
+		now := time.Now()
+
+		cur := b.Cursor()
+
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			if eb != nil && isExpired(eb.Get(k), now) {
+				expired = append(expired, append([]byte{}, k...))
+				continue
+			}
+			pl, err := decodeRecord(v, c.Cipher)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error": err.Error(),
+					"json":  v,
+				}).Warning("Failed to deserialize bytes to payload.")
+				continue
+			}
+			if err := fn(*pl); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if len(expired) > 0 {
+		c.purge(expired)
+	}
+	return err
+}
+
+// GetRequestsPage - returns up to limit captured requests/responses ordered by key, starting at given offset.
+// Records that fail to decode are counted in offset but not returned
+func (c *BoltCache) GetRequestsPage(offset, limit int) (payloads []Payload, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	payloads = []Payload{}
+	if offset < 0 || limit <= 0 {
+		return payloads, fmt.Errorf("invalid page, offset must not be negative and limit must be positive")
+	}
+
 	err = c.DS.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(c.RequestsBucket)
 		if b == nil {
 			// bucket doesn't exist
 			return nil
 		}
-		c := b.Cursor()
+		eb := tx.Bucket(c.expiryBucket())
+		now := time.Now()
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			pl, err := decodePayload(v)
+		position := 0
+		cur := b.Cursor()
+		for k, v := cur.First(); k != nil && position < offset+limit; k, v = cur.Next() {
+			if eb != nil && isExpired(eb.Get(k), now) {
+				continue
+			}
+			position++
+			if position <= offset {
+				continue
+			}
+			pl, err := decodeRecord(v, c.Cipher)
 			if err != nil {
 				log.WithFields(log.Fields{
 					"error": err.Error(),
 					"json":  v,
 				}).Warning("Failed to deserialize bytes to payload.")
-			} else {
-				payloads = append(payloads, *pl)
+				continue
 			}
+			payloads = append(payloads, *pl)
 		}
 		return nil
 	})
@@ -125,6 +598,9 @@ func (c *BoltCache) GetAllRequests() (payloads []Payload, err error) {
 
 // RecordsCount - returns records count
 func (c *BoltCache) RecordsCount() (count int, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	err = c.DS.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(c.RequestsBucket)
 		if b == nil {
@@ -134,17 +610,93 @@ func (c *BoltCache) RecordsCount() (count int, err error) {
 
 		count = b.Stats().KeyN
 
+		// expired records that weren't purged yet are missing for Get, so they aren't counted either. There are
+		// usually far fewer records with expiry than records, so only expiry timestamps are visited
+		if eb := tx.Bucket(c.expiryBucket()); eb != nil {
+			now := time.Now()
+			ec := eb.Cursor()
+			for k, v := ec.First(); k != nil; k, v = ec.Next() {
+				if isExpired(v, now) && b.Get(k) != nil {
+					count--
+				}
+			}
+		}
 		return nil
 	})
 	return
 }
 
+// RecordsCountByDestination - returns records count for each destination host
+func (c *BoltCache) RecordsCountByDestination() (map[string]int, error) {
+	return countByDestination(c)
+}
+
 // DeleteData - deletes bucket with all saved data
 func (c *BoltCache) DeleteData() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	err := c.DeleteBucket(c.RequestsBucket)
+	if err == nil {
+		// meta buckets only exist when records were saved with expiry or eviction enabled
+		c.DS.Update(func(tx *bolt.Tx) error {
+			for _, name := range c.metaBuckets() {
+				tx.DeleteBucket(name)
+			}
+			return nil
+		})
+	}
 	return err
 }
 
+// DeleteKey - deletes single record
+func (c *BoltCache) DeleteKey(key []byte) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.DS.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(c.RequestsBucket)
+		if bucket == nil || bucket.Get(key) == nil {
+			return fmt.Errorf("key %q not found \n", key)
+		}
+		return c.deleteRecord(tx, key)
+	})
+}
+
+// DeleteDataWhere - deletes records whose payloads match given filter, returns deleted records count
+func (c *BoltCache) DeleteDataWhere(filter PayloadFilter) (deleted int, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.DS.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(c.RequestsBucket)
+		if b == nil {
+			// bucket doesn't exist
+			return nil
+		}
+
+		var keys [][]byte
+		cur := b.Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			pl, err := decodeRecord(v, c.Cipher)
+			if err != nil || !filter.Match(pl) {
+				continue
+			}
+			keys = append(keys, append([]byte{}, k...))
+		}
+
+		// bucket can't be modified while iterating over it
+		for _, k := range keys {
+			if err := c.deleteRecord(tx, k); err != nil {
+				return err
+			}
+		}
+		deleted = len(keys)
+		return nil
+	})
+	return
+}
+
 // DeleteBucket - deletes bucket with all saved data
 func (c *BoltCache) DeleteBucket(name []byte) (err error) {
 	err = c.DS.Update(func(tx *bolt.Tx) error {
@@ -161,8 +713,164 @@ func (c *BoltCache) DeleteBucket(name []byte) (err error) {
 	return
 }
 
+// WriteBackup - writes consistent snapshot of the whole database to given writer, other transactions
+// are not blocked while backup is being written
+func (c *BoltCache) WriteBackup(w io.Writer) (n int64, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.DS.View(func(tx *bolt.Tx) error {
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return
+}
+
+// namespacesBucket - bucket that holds names of created namespaces
+var namespacesBucket = []byte("_namespaces")
+
+// validateNamespace - checks whether given name can be used as a namespace, names can't clash with
+// buckets used internally
+func validateNamespace(name string) error {
+	if name == "" {
+		return fmt.Errorf("namespace name can't be empty")
+	}
+	if strings.HasPrefix(name, "_") {
+		return fmt.Errorf("namespace name %q is reserved", name)
+	}
+	// names ending like buckets of other namespace records (i.e. "users_order") would clash with them
+	for _, suffix := range []string{"_expiry", "_order", "_order_index"} {
+		if strings.HasSuffix(name, suffix) {
+			return fmt.Errorf("namespace name %q is reserved", name)
+		}
+	}
+	return nil
+}
+
+// CurrentNamespace - returns name of the namespace that is currently used
+func (c *BoltCache) CurrentNamespace() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return string(c.RequestsBucket)
+}
+
+// ListNamespaces - returns sorted names of all namespaces, default namespace is always included
+func (c *BoltCache) ListNamespaces() (names []string, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	found := map[string]bool{c.defaultNamespace: true, string(c.RequestsBucket): true}
+	err = c.DS.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(namespacesBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			found[string(k)] = true
+			return nil
+		})
+	})
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// namespaceExists - checks whether namespace was created, must be called with lock held
+func (c *BoltCache) namespaceExists(name string) (exists bool, err error) {
+	if name == c.defaultNamespace {
+		return true, nil
+	}
+	err = c.DS.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(namespacesBucket)
+		exists = b != nil && b.Get([]byte(name)) != nil
+		return nil
+	})
+	return
+}
+
+// CreateNamespace - creates new empty namespace
+func (c *BoltCache) CreateNamespace(name string) error {
+	if err := validateNamespace(name); err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	exists, err := c.namespaceExists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("namespace %q already exists", name)
+	}
+
+	return c.DS.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(namespacesBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(name), []byte(time.Now().Format(time.RFC3339)))
+	})
+}
+
+// SwitchNamespace - makes given namespace current, all following cache operations use it
+func (c *BoltCache) SwitchNamespace(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exists, err := c.namespaceExists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("namespace %q not found", name)
+	}
+
+	c.RequestsBucket = []byte(name)
+
+	log.WithFields(log.Fields{
+		"namespace": name,
+	}).Info("Switched namespace")
+
+	return nil
+}
+
+// DeleteNamespace - deletes namespace together with all its records, current and default namespaces
+// can't be deleted
+func (c *BoltCache) DeleteNamespace(name string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if name == string(c.RequestsBucket) {
+		return fmt.Errorf("namespace %q is currently used", name)
+	}
+	if name == c.defaultNamespace {
+		return fmt.Errorf("default namespace %q can't be deleted", name)
+	}
+
+	return c.DS.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(namespacesBucket)
+		if b == nil || b.Get([]byte(name)) == nil {
+			return fmt.Errorf("namespace %q not found", name)
+		}
+		// records and meta buckets only exist when something was saved
+		tx.DeleteBucket([]byte(name))
+		for _, meta := range metaBucketsOf([]byte(name)) {
+			tx.DeleteBucket(meta)
+		}
+		return b.Delete([]byte(name))
+	})
+}
+
 // GetAllKeys - gets all current keys
 func (c *BoltCache) GetAllKeys() (keys map[string]bool, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	err = c.DS.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(c.RequestsBucket)
 
@@ -172,12 +880,45 @@ func (c *BoltCache) GetAllKeys() (keys map[string]bool, err error) {
 			// bucket doesn't exist
 			return nil
 		}
-		c := b.Cursor()
+		eb := tx.Bucket(c.expiryBucket())
+		now := time.Now()
 
-		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		cur := b.Cursor()
+		for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+			if eb != nil && isExpired(eb.Get(k), now) {
+				continue
+			}
 			keys[string(k)] = true
 		}
 		return nil
 	})
 	return
 }
+
+// GetKeysWithPrefix - returns sorted keys that start with given prefix, cursor seeks straight to the
+// first matching key so the rest of the bucket is not visited
+func (c *BoltCache) GetKeysWithPrefix(prefix []byte) (keys [][]byte, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.DS.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(c.RequestsBucket)
+		if b == nil {
+			// bucket doesn't exist
+			return nil
+		}
+		eb := tx.Bucket(c.expiryBucket())
+		now := time.Now()
+
+		cur := b.Cursor()
+		for k, _ := cur.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			if eb != nil && isExpired(eb.Get(k), now) {
+				continue
+			}
+			// keys are only valid during transaction
+			keys = append(keys, append([]byte{}, k...))
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,157 @@
+package hoverfly
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/boltdb/bolt"
+)
+
+// Compacter - implemented by caches whose storage doesn't shrink on its own after records are deleted
+type Compacter interface {
+	Compact() (CompactionResult, error)
+}
+
+// CompactionResult - database file sizes before and after compaction
+type CompactionResult struct {
+	SizeBefore int64         `json:"sizeBefore"`
+	SizeAfter  int64         `json:"sizeAfter"`
+	Took       time.Duration `json:"took"`
+}
+
+// Compact - copies live records into a fresh database file and atomically replaces current file with it,
+// other cache operations wait until compaction is finished
+func (c *BoltCache) Compact() (result CompactionResult, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	start := time.Now()
+	path := c.DS.Path()
+	tmpPath := path + ".compact"
+
+	if result.SizeBefore, err = fileSize(path); err != nil {
+		return
+	}
+
+	dst, err := bolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return
+	}
+
+	err = c.DS.View(func(src *bolt.Tx) error {
+		return dst.Update(func(tx *bolt.Tx) error {
+			return copyBuckets(src, tx)
+		})
+	})
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+
+	if err = c.DS.Close(); err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+
+	// rename is atomic so database file is either old or compacted one
+	renameErr := os.Rename(tmpPath, path)
+
+	// database has to be reopened even when rename failed
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return
+	}
+	c.DS = db
+
+	if renameErr != nil {
+		os.Remove(tmpPath)
+		err = renameErr
+		return
+	}
+
+	if result.SizeAfter, err = fileSize(path); err != nil {
+		return
+	}
+	result.Took = time.Since(start)
+
+	log.WithFields(log.Fields{
+		"database":   path,
+		"sizeBefore": result.SizeBefore,
+		"sizeAfter":  result.SizeAfter,
+		"took":       result.Took,
+	}).Info("Database compacted")
+
+	return
+}
+
+// copyBuckets - copies all buckets from source to destination transaction. Bucket sequences can't be
+// copied, so insertion order buckets are rebuilt with new sequences and stale entries are dropped
+func copyBuckets(src, dst *bolt.Tx) error {
+	return src.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if bytes.HasSuffix(name, []byte("_order_index")) {
+			// rebuilt together with order bucket
+			return nil
+		}
+		if bytes.HasSuffix(name, []byte("_order")) {
+			return copyOrderBuckets(src, dst, name)
+		}
+
+		nb, err := dst.CreateBucket(name)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return nb.Put(k, v)
+		})
+	})
+}
+
+// copyOrderBuckets - rebuilds insertion order and its index, only entries that are still current are kept
+func copyOrderBuckets(src, dst *bolt.Tx, name []byte) error {
+	indexName := []byte(string(name) + "_index")
+
+	ob := src.Bucket(name)
+	ib := src.Bucket(indexName)
+
+	nob, err := dst.CreateBucket(name)
+	if err != nil {
+		return err
+	}
+	nib, err := dst.CreateBucket(indexName)
+	if err != nil {
+		return err
+	}
+	if ib == nil {
+		return nil
+	}
+
+	return ob.ForEach(func(seq, key []byte) error {
+		if !bytes.Equal(ib.Get(key), seq) {
+			// stale entry
+			return nil
+		}
+		newSeq, err := nob.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := nob.Put(encodeSequence(newSeq), key); err != nil {
+			return err
+		}
+		return nib.Put(key, encodeSequence(newSeq))
+	})
+}
+
+// fileSize - returns size of given file
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat %s: %s", path, err.Error())
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,92 @@
+package hoverfly
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompactShrinksDatabase(t *testing.T) {
+	name := "compact_test.db"
+	defer os.Remove(name)
+
+	cache := NewBoltDBCache(GetDB(name), []byte(RequestsBucketName))
+	defer cache.CloseDB()
+
+	value := []byte(strings.Repeat("x", 4096))
+	for i := 0; i < 500; i++ {
+		cache.Set([]byte(fmt.Sprintf("key%d", i)), value)
+	}
+	for i := 0; i < 490; i++ {
+		cache.DeleteKey([]byte(fmt.Sprintf("key%d", i)))
+	}
+
+	result, err := cache.Compact()
+	expect(t, err, nil)
+	expect(t, result.SizeAfter < result.SizeBefore, true)
+
+	// records are still there and database is usable
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 10)
+
+	stored, err := cache.Get([]byte("key499"))
+	expect(t, err, nil)
+	expect(t, string(stored), string(value))
+
+	err = cache.Set([]byte("new"), []byte("value"))
+	expect(t, err, nil)
+
+	_, err = os.Stat(name + ".compact")
+	expect(t, os.IsNotExist(err), true)
+}
+
+func TestCompactKeepsEvictionOrder(t *testing.T) {
+	name := "compact_order_test.db"
+	defer os.Remove(name)
+
+	cache := NewBoltDBCache(GetDB(name), []byte(RequestsBucketName))
+	cache.MaxRecords = 3
+	defer cache.CloseDB()
+
+	cache.Set([]byte("first"), []byte("value"))
+	cache.Set([]byte("second"), []byte("value"))
+	cache.Set([]byte("third"), []byte("value"))
+	// overwriting leaves stale order entry behind
+	cache.Set([]byte("first"), []byte("new value"))
+
+	_, err := cache.Compact()
+	expect(t, err, nil)
+
+	cache.Set([]byte("fourth"), []byte("value"))
+
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, len(keys), 3)
+	expect(t, keys["second"], false)
+	expect(t, keys["first"], true)
+	expect(t, keys["fourth"], true)
+}
+
+func TestCompactKeepsAdminUsers(t *testing.T) {
+	name := "compact_auth_test.db"
+	defer os.Remove(name)
+
+	cache := NewBoltDBCache(GetDB(name), []byte(RequestsBucketName))
+	defer cache.CloseDB()
+
+	auth, err := NewAuthStore(cache, []byte(AuthBucketName), "secret", time.Hour)
+	expect(t, err, nil)
+	expect(t, auth.AddUser("benjy", "pass", RoleAdmin), nil)
+
+	_, err = cache.Compact()
+	expect(t, err, nil)
+
+	// store reads users from reopened database
+	user, err := auth.Authenticate("benjy", "pass")
+	expect(t, err, nil)
+	expect(t, user.Username, "benjy")
+	expect(t, auth.AddUser("second", "pass", RoleReadOnly), nil)
+}
@@ -0,0 +1,350 @@
+package hoverfly
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// MemoryCache - container to implement Cache instance with in-memory storage, nothing is written to disk
+// so all captured requests are lost when Hoverfly stops
+type MemoryCache struct {
+	// MaxRecords - when set, the least recently used records are evicted once there are more records
+	MaxRecords int
+
+	elements map[string][]byte
+	expiry   map[string]time.Time
+	// recently used keys are at the front of the list
+	lru       *list.List
+	positions map[string]*list.Element
+	mu        sync.RWMutex
+
+	counters cacheCounters
+}
+
+// NewMemoryCache - returns new MemoryCache instance
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		elements:  make(map[string][]byte),
+		expiry:    make(map[string]time.Time),
+		lru:       list.New(),
+		positions: make(map[string]*list.Element),
+	}
+}
+
+// CloseDB - nothing to close for in-memory cache
+func (c *MemoryCache) CloseDB() {}
+
+// Set - saves given key and value pair to cache
+func (c *MemoryCache) Set(key, value []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(string(key), value)
+	delete(c.expiry, string(key))
+	c.counters.set(1)
+	return nil
+}
+
+// SetMulti - saves given key and value pairs to cache
+func (c *MemoryCache) SetMulti(pairs []KeyValue) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, kv := range pairs {
+		c.set(string(kv.Key), kv.Value)
+		delete(c.expiry, string(kv.Key))
+	}
+	c.counters.set(len(pairs))
+	return nil
+}
+
+// SetWithExpiry - saves given key and value pair to cache, record is removed once ttl passes
+func (c *MemoryCache) SetWithExpiry(key, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(string(key), value)
+	c.expiry[string(key)] = time.Now().Add(ttl)
+	c.counters.set(1)
+	return nil
+}
+
+// set - saves value and evicts least recently used records, has to be called with lock held
+func (c *MemoryCache) set(key string, value []byte) {
+	// copying value since caller might reuse given slice
+	v := make([]byte, len(value))
+	copy(v, value)
+
+	c.elements[key] = v
+	c.touch(key)
+
+	if c.MaxRecords <= 0 {
+		return
+	}
+
+	for len(c.elements) > c.MaxRecords {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			return
+		}
+		k := oldest.Value.(string)
+		c.remove(k)
+
+		log.WithFields(log.Fields{
+			"key":        k,
+			"maxRecords": c.MaxRecords,
+		}).Debug("record evicted")
+	}
+}
+
+// touch - marks key as the most recently used one, has to be called with lock held
+func (c *MemoryCache) touch(key string) {
+	if e, ok := c.positions[key]; ok {
+		c.lru.MoveToFront(e)
+	} else {
+		c.positions[key] = c.lru.PushFront(key)
+	}
+}
+
+// remove - removes record and all information about it, has to be called with lock held
+func (c *MemoryCache) remove(key string) {
+	delete(c.elements, key)
+	delete(c.expiry, key)
+	if e, ok := c.positions[key]; ok {
+		c.lru.Remove(e)
+		delete(c.positions, key)
+	}
+}
+
+// expired - checks whether record has expired, has to be called with lock held
+func (c *MemoryCache) expired(key string, now time.Time) bool {
+	t, ok := c.expiry[key]
+	return ok && !t.After(now)
+}
+
+// purge - removes given keys if they are still expired
+func (c *MemoryCache) purge(keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, k := range keys {
+		if c.expired(k, now) {
+			c.remove(k)
+		}
+	}
+}
+
+// Get - searches for given key in the cache and returns value if found
+func (c *MemoryCache) Get(key []byte) ([]byte, error) {
+	c.mu.RLock()
+	value, ok := c.elements[string(key)]
+	expired := ok && c.expired(string(key), time.Now())
+	c.mu.RUnlock()
+
+	if expired {
+		c.purge([]string{string(key)})
+	}
+
+	if !ok || expired {
+		c.counters.get(false)
+		return nil, fmt.Errorf("key %q not found \n", key)
+	}
+	c.counters.get(true)
+
+	if c.MaxRecords > 0 {
+		c.mu.Lock()
+		if _, ok := c.elements[string(key)]; ok {
+			c.touch(string(key))
+		}
+		c.mu.Unlock()
+	}
+	return value, nil
+}
+
+// Stats - returns cache usage statistics collected since cache was created
+func (c *MemoryCache) Stats() CacheStats {
+	return c.counters.snapshot()
+}
+
+// GetAllRequests - returns all captured requests/responses
+func (c *MemoryCache) GetAllRequests() (payloads []Payload, err error) {
+	err = c.ForEachRequest(func(pl Payload) error {
+		payloads = append(payloads, pl)
+		return nil
+	})
+	return
+}
+
+// ForEachRequest - decodes captured requests/responses one by one and passes them to given function,
+// iteration stops when function returns an error
+func (c *MemoryCache) ForEachRequest(fn func(Payload) error) error {
+	var expired []string
+	var values [][]byte
+
+	// stored values are never modified in place so it's safe to decode them without holding the lock
+	c.mu.RLock()
+	now := time.Now()
+	for k, v := range c.elements {
+		if c.expired(k, now) {
+			expired = append(expired, k)
+			continue
+		}
+		values = append(values, v)
+	}
+	c.mu.RUnlock()
+
+	if len(expired) > 0 {
+		c.purge(expired)
+	}
+
+	for _, v := range values {
+		pl, err := decodePayload(v)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"json":  v,
+			}).Warning("Failed to deserialize bytes to payload.")
+			continue
+		}
+		if err := fn(*pl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRequestsPage - returns up to limit captured requests/responses ordered by key, starting at given offset.
+// Records that fail to decode are counted in offset but not returned
+func (c *MemoryCache) GetRequestsPage(offset, limit int) ([]Payload, error) {
+	payloads := []Payload{}
+	if offset < 0 || limit <= 0 {
+		return payloads, fmt.Errorf("invalid page, offset must not be negative and limit must be positive")
+	}
+
+	c.mu.RLock()
+	now := time.Now()
+	keys := make([]string, 0, len(c.elements))
+	for k := range c.elements {
+		if !c.expired(k, now) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var values [][]byte
+	for i := offset; i < len(keys) && i < offset+limit; i++ {
+		values = append(values, c.elements[keys[i]])
+	}
+	c.mu.RUnlock()
+
+	for _, v := range values {
+		pl, err := decodePayload(v)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"json":  v,
+			}).Warning("Failed to deserialize bytes to payload.")
+			continue
+		}
+		payloads = append(payloads, *pl)
+	}
+	return payloads, nil
+}
+
+// RecordsCount - returns records count
+func (c *MemoryCache) RecordsCount() (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.elements), nil
+}
+
+// RecordsCountByDestination - returns records count for each destination host
+func (c *MemoryCache) RecordsCountByDestination() (map[string]int, error) {
+	return countByDestination(c)
+}
+
+// DeleteData - removes all saved data
+func (c *MemoryCache) DeleteData() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// keeping the same behaviour as BoltCache when there is nothing to delete
+	if len(c.elements) == 0 {
+		return fmt.Errorf("bucket not found")
+	}
+	c.elements = make(map[string][]byte)
+	c.expiry = make(map[string]time.Time)
+	c.lru = list.New()
+	c.positions = make(map[string]*list.Element)
+	return nil
+}
+
+// DeleteKey - deletes single record
+func (c *MemoryCache) DeleteKey(key []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.elements[string(key)]; !ok {
+		return fmt.Errorf("key %q not found \n", key)
+	}
+	c.remove(string(key))
+	return nil
+}
+
+// DeleteDataWhere - deletes records whose payloads match given filter, returns deleted records count
+func (c *MemoryCache) DeleteDataWhere(filter PayloadFilter) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deleted := 0
+	for k, v := range c.elements {
+		pl, err := decodePayload(v)
+		if err != nil || !filter.Match(pl) {
+			continue
+		}
+		c.remove(k)
+		deleted++
+	}
+	return deleted, nil
+}
+
+// GetAllKeys - gets all current keys
+func (c *MemoryCache) GetAllKeys() (map[string]bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make(map[string]bool)
+	for k := range c.elements {
+		keys[k] = true
+	}
+	return keys, nil
+}
+
+// GetKeysWithPrefix - returns sorted keys that start with given prefix
+func (c *MemoryCache) GetKeysWithPrefix(prefix []byte) ([][]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	var found []string
+	for k := range c.elements {
+		if strings.HasPrefix(k, string(prefix)) && !c.expired(k, now) {
+			found = append(found, k)
+		}
+	}
+	sort.Strings(found)
+
+	keys := make([][]byte, len(found))
+	for i, k := range found {
+		keys[i] = []byte(k)
+	}
+	return keys, nil
+}
@@ -0,0 +1,269 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheSetGet(t *testing.T) {
+	cache := NewMemoryCache()
+
+	err := cache.Set([]byte("key"), []byte("value"))
+	expect(t, err, nil)
+
+	value, err := cache.Get([]byte("key"))
+	expect(t, err, nil)
+	expect(t, string(value), "value")
+}
+
+func TestMemoryCacheGetNonExistingKey(t *testing.T) {
+	cache := NewMemoryCache()
+
+	_, err := cache.Get([]byte("should not be here"))
+	refute(t, err, nil)
+}
+
+func TestMemoryCacheSetCopiesValue(t *testing.T) {
+	cache := NewMemoryCache()
+
+	v := []byte("value")
+	cache.Set([]byte("key"), v)
+	v[0] = 'X'
+
+	value, err := cache.Get([]byte("key"))
+	expect(t, err, nil)
+	expect(t, string(value), "value")
+}
+
+func TestMemoryCacheGetAllRequests(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cache = NewMemoryCache()
+
+	for i := 0; i < 5; i++ {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://example.com/q=%d", i), nil)
+		expect(t, err, nil)
+		dbClient.captureRequest(req)
+	}
+	// corrupted payloads should be just skipped
+	dbClient.Cache.Set([]byte("corrupted"), []byte("value"))
+
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 5)
+
+	for _, payload := range payloads {
+		expect(t, payload.Request.Method, "GET")
+		expect(t, payload.Response.Status, 201)
+	}
+}
+
+func TestMemoryCacheCountKeysDelete(t *testing.T) {
+	cache := NewMemoryCache()
+
+	for i := 0; i < 5; i++ {
+		cache.Set([]byte(fmt.Sprintf("key%d", i)), []byte("value"))
+	}
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 5)
+
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, len(keys), 5)
+	expect(t, keys["key4"], true)
+
+	err = cache.DeleteData()
+	expect(t, err, nil)
+
+	count, err = cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 0)
+
+	// nothing to delete, like BoltCache
+	err = cache.DeleteData()
+	refute(t, err, nil)
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	cache := NewMemoryCache()
+
+	cache.SetWithExpiry([]byte("fresh"), []byte("value"), time.Hour)
+	cache.SetWithExpiry([]byte("stale"), []byte("value"), -time.Second)
+
+	_, err := cache.Get([]byte("fresh"))
+	expect(t, err, nil)
+
+	_, err = cache.Get([]byte("stale"))
+	refute(t, err, nil)
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 1)
+}
+
+func TestMemoryCacheGetAllRequestsSkipsExpired(t *testing.T) {
+	cache := NewMemoryCache()
+
+	payload := Payload{Response: ResponseDetails{Status: 200, Body: "body here"}}
+	bts, err := payload.Encode()
+	expect(t, err, nil)
+
+	cache.Set([]byte("fresh"), bts)
+	cache.SetWithExpiry([]byte("stale"), bts, -time.Second)
+
+	payloads, err := cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 1)
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryCache()
+	cache.MaxRecords = 2
+
+	cache.Set([]byte("first"), []byte("value"))
+	cache.Set([]byte("second"), []byte("value"))
+	// reading first record makes second the least recently used one
+	_, err := cache.Get([]byte("first"))
+	expect(t, err, nil)
+	cache.Set([]byte("third"), []byte("value"))
+
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, len(keys), 2)
+	expect(t, keys["first"], true)
+	expect(t, keys["second"], false)
+	expect(t, keys["third"], true)
+}
+
+func TestMemoryCacheForEachRequestStopsOnError(t *testing.T) {
+	cache := NewMemoryCache()
+
+	payload := Payload{Response: ResponseDetails{Status: 200, Body: "body here"}}
+	bts, err := payload.Encode()
+	expect(t, err, nil)
+
+	cache.Set([]byte("first"), bts)
+	cache.Set([]byte("second"), bts)
+
+	visited := 0
+	stop := fmt.Errorf("stop")
+	err = cache.ForEachRequest(func(pl Payload) error {
+		expect(t, pl.Response.Body, "body here")
+		visited++
+		return stop
+	})
+	expect(t, err, stop)
+	expect(t, visited, 1)
+}
+
+func TestMemoryCacheGetRequestsPage(t *testing.T) {
+	cache := NewMemoryCache()
+
+	for i := 0; i < 5; i++ {
+		payload := Payload{ID: fmt.Sprintf("key%d", i)}
+		bts, err := payload.Encode()
+		expect(t, err, nil)
+		cache.Set([]byte(payload.ID), bts)
+	}
+
+	page, err := cache.GetRequestsPage(1, 2)
+	expect(t, err, nil)
+	expect(t, len(page), 2)
+	expect(t, page[0].ID, "key1")
+	expect(t, page[1].ID, "key2")
+}
+
+func TestMemoryCacheDeleteKey(t *testing.T) {
+	cache := NewMemoryCache()
+	cache.MaxRecords = 2
+
+	cache.Set([]byte("first"), []byte("value"))
+	cache.Set([]byte("second"), []byte("value"))
+
+	err := cache.DeleteKey([]byte("first"))
+	expect(t, err, nil)
+
+	err = cache.DeleteKey([]byte("first"))
+	refute(t, err, nil)
+
+	// deleted key should not take space in LRU list
+	cache.Set([]byte("third"), []byte("value"))
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, len(keys), 2)
+	expect(t, keys["second"], true)
+}
+
+func TestMemoryCacheDeleteDataWhere(t *testing.T) {
+	cache := NewMemoryCache()
+
+	for i, method := range []string{"GET", "POST", "GET"} {
+		payload := Payload{Request: RequestDetails{Method: method}}
+		bts, err := payload.Encode()
+		expect(t, err, nil)
+		cache.Set([]byte(fmt.Sprintf("key%d", i)), bts)
+	}
+
+	deleted, err := cache.DeleteDataWhere(PayloadFilter{Method: "GET"})
+	expect(t, err, nil)
+	expect(t, deleted, 2)
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 1)
+}
+
+func TestMemoryCacheSetMulti(t *testing.T) {
+	cache := NewMemoryCache()
+
+	err := cache.SetMulti([]KeyValue{
+		{Key: []byte("first"), Value: []byte("value1")},
+		{Key: []byte("second"), Value: []byte("value2")},
+	})
+	expect(t, err, nil)
+
+	value, err := cache.Get([]byte("first"))
+	expect(t, err, nil)
+	expect(t, string(value), "value1")
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 2)
+}
+
+func TestMemoryCacheGetKeysWithPrefix(t *testing.T) {
+	cache := NewMemoryCache()
+
+	for _, key := range []string{"api.example.com/b", "api.example.com/a", "other.com/a"} {
+		cache.Set([]byte(key), []byte("value"))
+	}
+
+	keys, err := cache.GetKeysWithPrefix([]byte("api.example.com"))
+	expect(t, err, nil)
+	expect(t, len(keys), 2)
+	expect(t, string(keys[0]), "api.example.com/a")
+}
+
+func TestMemoryCacheRecordsCountByDestination(t *testing.T) {
+	cache := NewMemoryCache()
+
+	for i, destination := range []string{"example.com", "example.com", "other.com"} {
+		pl := Payload{Request: RequestDetails{Destination: destination, Path: fmt.Sprintf("/%d", i)}}
+		bts, err := pl.Encode()
+		expect(t, err, nil)
+		cache.Set([]byte(fmt.Sprintf("key%d", i)), bts)
+	}
+
+	counts, err := cache.RecordsCountByDestination()
+	expect(t, err, nil)
+	expect(t, counts["example.com"], 2)
+	expect(t, counts["other.com"], 1)
+}
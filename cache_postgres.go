@@ -0,0 +1,365 @@
+package hoverfly
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/lib/pq"
+)
+
+// PostgresTableName - default name of the table that holds captured requests
+const PostgresTableName = "payloads"
+
+// pgLive - condition that skips expired records
+const pgLive = "(expires_at IS NULL OR expires_at > now())"
+
+// PostgresCache - container to implement Cache instance with PostgreSQL backend for storage, records
+// are kept in a table with key, value, destination, created_at and expires_at columns so captured
+// traffic can be queried with SQL and shared between Hoverfly instances
+type PostgresCache struct {
+	DB    *sql.DB
+	Table string
+	// MaxRecords - when set, the oldest records are evicted once there are more records in the table
+	MaxRecords int
+
+	counters cacheCounters
+}
+
+// NewPostgresCache - returns new PostgresCache instance, table is created when it doesn't exist
+func NewPostgresCache(db *sql.DB, table string) (*PostgresCache, error) {
+	c := &PostgresCache{
+		DB:    db,
+		Table: table,
+	}
+
+	_, err := db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		key TEXT PRIMARY KEY,
+		value BYTEA NOT NULL,
+		destination TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp(),
+		expires_at TIMESTAMPTZ
+	)`, c.table()))
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (destination)",
+		pq.QuoteIdentifier(table+"_destination_idx"), c.table()))
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (created_at)",
+		pq.QuoteIdentifier(table+"_created_at_idx"), c.table()))
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// GetPostgresDB - returns connection pool for given data source name or goes down in flames if
+// database is not reachable
+func GetPostgresDB(dsn string) *sql.DB {
+	log.Info("Initiating PostgreSQL connection pool")
+
+	db, err := sql.Open("postgres", dsn)
+	if err == nil {
+		err = db.Ping()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return db
+}
+
+// table - returns quoted table name
+func (c *PostgresCache) table() string {
+	return pq.QuoteIdentifier(c.Table)
+}
+
+// CloseDB - closes connection pool
+func (c *PostgresCache) CloseDB() {
+	c.DB.Close()
+}
+
+// pgExecer - implemented by both database and transaction
+type pgExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// destinationOf - returns destination of encoded payload, values that are not payloads have none
+func destinationOf(value []byte) string {
+	pl, err := decodePayload(value)
+	if err != nil || pl == nil {
+		return ""
+	}
+	return pl.Request.Destination
+}
+
+// put - inserts or replaces record, overwritten record becomes the newest one
+func (c *PostgresCache) put(db pgExecer, key, value []byte, ttl time.Duration) error {
+	var expiry interface{}
+	if ttl > 0 {
+		expiry = ttl.Nanoseconds() / int64(time.Microsecond)
+	}
+
+	_, err := db.Exec(fmt.Sprintf(`INSERT INTO %s (key, value, destination, created_at, expires_at)
+		VALUES ($1, $2, $3, clock_timestamp(), now() + $4::double precision * interval '1 microsecond')
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, destination = EXCLUDED.destination,
+		created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at`, c.table()),
+		string(key), value, destinationOf(value), expiry)
+	return err
+}
+
+// evict - removes the oldest records until there are no more than MaxRecords left
+func (c *PostgresCache) evict(db pgExecer) error {
+	if c.MaxRecords <= 0 {
+		return nil
+	}
+	_, err := db.Exec(fmt.Sprintf(`DELETE FROM %[1]s WHERE key IN
+		(SELECT key FROM %[1]s ORDER BY created_at DESC, key OFFSET $1)`, c.table()), c.MaxRecords)
+	return err
+}
+
+// Set - saves given key and value pair to cache
+func (c *PostgresCache) Set(key, value []byte) error {
+	if err := c.put(c.DB, key, value, 0); err != nil {
+		return err
+	}
+	c.counters.set(1)
+	return c.evict(c.DB)
+}
+
+// SetWithExpiry - saves given key and value pair to cache, record is removed once ttl passes
+func (c *PostgresCache) SetWithExpiry(key, value []byte, ttl time.Duration) error {
+	if err := c.put(c.DB, key, value, ttl); err != nil {
+		return err
+	}
+	c.counters.set(1)
+	return c.evict(c.DB)
+}
+
+// SetMulti - saves given key and value pairs to cache within a single transaction
+func (c *PostgresCache) SetMulti(pairs []KeyValue) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	for _, kv := range pairs {
+		if err := c.put(tx, kv.Key, kv.Value, 0); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := c.evict(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	c.counters.set(len(pairs))
+	return nil
+}
+
+// Get - searches for given key in the cache and returns value if found
+func (c *PostgresCache) Get(key []byte) (value []byte, err error) {
+	defer func() { c.counters.get(err == nil) }()
+
+	err = c.DB.QueryRow(fmt.Sprintf("SELECT value FROM %s WHERE key = $1 AND %s", c.table(), pgLive),
+		string(key)).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("key %q not found \n", key)
+	}
+	return
+}
+
+// purge - deletes expired records
+func (c *PostgresCache) purge() error {
+	_, err := c.DB.Exec(fmt.Sprintf("DELETE FROM %s WHERE expires_at <= now()", c.table()))
+	return err
+}
+
+// forEach - decodes payloads returned by given query and passes them to given function
+func (c *PostgresCache) forEach(fn func(key string, pl *Payload) error, query string, args ...interface{}) error {
+	rows, err := c.DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		pl, err := decodePayload(value)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"json":  value,
+			}).Warning("Failed to deserialize bytes to payload.")
+			continue
+		}
+		if err := fn(key, pl); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetAllRequests - returns all captured requests/responses
+func (c *PostgresCache) GetAllRequests() (payloads []Payload, err error) {
+	err = c.ForEachRequest(func(pl Payload) error {
+		payloads = append(payloads, pl)
+		return nil
+	})
+	return
+}
+
+// ForEachRequest - decodes captured requests/responses one by one and passes them to given function,
+// iteration stops when function returns an error
+func (c *PostgresCache) ForEachRequest(fn func(Payload) error) error {
+	if err := c.purge(); err != nil {
+		return err
+	}
+	return c.forEach(func(key string, pl *Payload) error {
+		return fn(*pl)
+	}, fmt.Sprintf("SELECT key, value FROM %s WHERE %s ORDER BY key", c.table(), pgLive))
+}
+
+// GetRequestsPage - returns captured requests/responses ordered by key, skipping offset records
+func (c *PostgresCache) GetRequestsPage(offset, limit int) (payloads []Payload, err error) {
+	if offset < 0 || limit <= 0 {
+		return payloads, fmt.Errorf("invalid page, offset must not be negative and limit must be positive")
+	}
+	err = c.forEach(func(key string, pl *Payload) error {
+		payloads = append(payloads, *pl)
+		return nil
+	}, fmt.Sprintf("SELECT key, value FROM %s WHERE %s ORDER BY key OFFSET $1 LIMIT $2", c.table(), pgLive),
+		offset, limit)
+	return
+}
+
+// RecordsCount - returns records count
+func (c *PostgresCache) RecordsCount() (count int, err error) {
+	err = c.DB.QueryRow(fmt.Sprintf("SELECT count(*) FROM %s WHERE %s", c.table(), pgLive)).Scan(&count)
+	return
+}
+
+// RecordsCountByDestination - returns records count for each destination host, counted by the database
+func (c *PostgresCache) RecordsCountByDestination() (map[string]int, error) {
+	rows, err := c.DB.Query(fmt.Sprintf("SELECT destination, count(*) FROM %s WHERE %s GROUP BY destination",
+		c.table(), pgLive))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var destination string
+		var count int
+		if err := rows.Scan(&destination, &count); err != nil {
+			return nil, err
+		}
+		counts[destination] = count
+	}
+	return counts, rows.Err()
+}
+
+// DeleteData - deletes all saved data
+func (c *PostgresCache) DeleteData() error {
+	res, err := c.DB.Exec(fmt.Sprintf("DELETE FROM %s", c.table()))
+	if err != nil {
+		return err
+	}
+	// keeping the same behaviour as BoltCache when there is nothing to delete
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("bucket not found")
+	}
+	return nil
+}
+
+// DeleteKey - deletes single record
+func (c *PostgresCache) DeleteKey(key []byte) error {
+	res, err := c.DB.Exec(fmt.Sprintf("DELETE FROM %s WHERE key = $1", c.table()), string(key))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("key %q not found \n", key)
+	}
+	return nil
+}
+
+// DeleteDataWhere - deletes records whose payloads match given filter, returns deleted records count.
+// Destination is matched by the database, path and method require payloads to be decoded
+func (c *PostgresCache) DeleteDataWhere(filter PayloadFilter) (int, error) {
+	query := fmt.Sprintf("SELECT key, value FROM %s", c.table())
+	var args []interface{}
+	if filter.Destination != "" {
+		query += " WHERE destination = $1"
+		args = append(args, filter.Destination)
+	}
+
+	var keys []string
+	err := c.forEach(func(key string, pl *Payload) error {
+		if filter.Match(pl) {
+			keys = append(keys, key)
+		}
+		return nil
+	}, query, args...)
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+
+	res, err := c.DB.Exec(fmt.Sprintf("DELETE FROM %s WHERE key = ANY($1)", c.table()), pq.Array(keys))
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := res.RowsAffected()
+	return int(deleted), err
+}
+
+// GetAllKeys - gets all current keys
+func (c *PostgresCache) GetAllKeys() (map[string]bool, error) {
+	keys := make(map[string]bool)
+	found, err := c.keys(fmt.Sprintf("SELECT key FROM %s WHERE %s", c.table(), pgLive))
+	for _, k := range found {
+		keys[string(k)] = true
+	}
+	return keys, err
+}
+
+// GetKeysWithPrefix - returns sorted keys that start with given prefix
+func (c *PostgresCache) GetKeysWithPrefix(prefix []byte) ([][]byte, error) {
+	return c.keys(fmt.Sprintf("SELECT key FROM %s WHERE left(key, char_length($1)) = $1 AND %s ORDER BY key",
+		c.table(), pgLive), string(prefix))
+}
+
+// keys - returns keys selected by given query
+func (c *PostgresCache) keys(query string, args ...interface{}) ([][]byte, error) {
+	rows, err := c.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys [][]byte
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, []byte(key))
+	}
+	return keys, rows.Err()
+}
+
+// Stats - returns cache usage statistics collected by this Hoverfly instance
+func (c *PostgresCache) Stats() CacheStats {
+	return c.counters.snapshot()
+}
@@ -0,0 +1,147 @@
+package hoverfly
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// testPostgresCache - returns PostgresCache with random table name, tests are skipped when
+// HoverflyPostgresDSN is not set or database is not reachable
+func testPostgresCache(t *testing.T) *PostgresCache {
+	dsn := os.Getenv("HoverflyPostgresDSN")
+	if dsn == "" {
+		t.Skip("HoverflyPostgresDSN is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err == nil {
+		err = db.Ping()
+	}
+	if err != nil {
+		t.Skipf("PostgreSQL is not available: %s", err.Error())
+	}
+
+	cache, err := NewPostgresCache(db, "test_"+string(GetRandomName(10)))
+	expect(t, err, nil)
+	return cache
+}
+
+// dropPostgresTable - removes table created for test and closes connection pool
+func dropPostgresTable(c *PostgresCache) {
+	c.DB.Exec(fmt.Sprintf("DROP TABLE %s", c.table()))
+	c.CloseDB()
+}
+
+func TestPostgresSetGet(t *testing.T) {
+	cache := testPostgresCache(t)
+	defer dropPostgresTable(cache)
+
+	err := cache.Set([]byte("key"), []byte("value"))
+	expect(t, err, nil)
+
+	value, err := cache.Get([]byte("key"))
+	expect(t, err, nil)
+	expect(t, string(value), "value")
+
+	_, err = cache.Get([]byte("nothere"))
+	refute(t, err, nil)
+}
+
+func TestPostgresExpiry(t *testing.T) {
+	cache := testPostgresCache(t)
+	defer dropPostgresTable(cache)
+
+	cache.SetWithExpiry([]byte("key"), []byte("value"), time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := cache.Get([]byte("key"))
+	refute(t, err, nil)
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 0)
+}
+
+func TestPostgresMaxRecords(t *testing.T) {
+	cache := testPostgresCache(t)
+	defer dropPostgresTable(cache)
+	cache.MaxRecords = 2
+
+	for i := 0; i < 4; i++ {
+		cache.Set([]byte(fmt.Sprintf("key%d", i)), []byte("value"))
+	}
+
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, len(keys), 2)
+	expect(t, keys["key3"], true)
+}
+
+func TestPostgresRecordsCountByDestination(t *testing.T) {
+	cache := testPostgresCache(t)
+	defer dropPostgresTable(cache)
+
+	var pairs []KeyValue
+	for i, destination := range []string{"example.com", "example.com", "other.com"} {
+		pl := Payload{Request: RequestDetails{Destination: destination, Path: fmt.Sprintf("/%d", i)}}
+		bts, err := pl.Encode()
+		expect(t, err, nil)
+		pairs = append(pairs, KeyValue{Key: []byte(fmt.Sprintf("key%d", i)), Value: bts})
+	}
+	err := cache.SetMulti(pairs)
+	expect(t, err, nil)
+
+	counts, err := cache.RecordsCountByDestination()
+	expect(t, err, nil)
+	expect(t, counts["example.com"], 2)
+	expect(t, counts["other.com"], 1)
+
+	deleted, err := cache.DeleteDataWhere(PayloadFilter{Destination: "example.com"})
+	expect(t, err, nil)
+	expect(t, deleted, 2)
+}
+
+func TestPostgresDeleteKey(t *testing.T) {
+	cache := testPostgresCache(t)
+	defer dropPostgresTable(cache)
+
+	cache.Set([]byte("key"), []byte("value"))
+
+	expect(t, cache.DeleteKey([]byte("key")), nil)
+	refute(t, cache.DeleteKey([]byte("key")), nil)
+}
+
+func TestPostgresGetKeysWithPrefix(t *testing.T) {
+	cache := testPostgresCache(t)
+	defer dropPostgresTable(cache)
+
+	for _, key := range []string{"api.example.com/b", "api.example.com/a", "api%example.com/a", "other.com/a"} {
+		cache.Set([]byte(key), []byte("value"))
+	}
+	cache.SetWithExpiry([]byte("api.example.com/expired"), []byte("value"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	keys, err := cache.GetKeysWithPrefix([]byte("api.example.com"))
+	expect(t, err, nil)
+	expect(t, len(keys), 2)
+	expect(t, string(keys[0]), "api.example.com/a")
+	expect(t, string(keys[1]), "api.example.com/b")
+
+	// prefix isn't a LIKE pattern
+	keys, err = cache.GetKeysWithPrefix([]byte("api%"))
+	expect(t, err, nil)
+	expect(t, len(keys), 1)
+}
+
+func TestPostgresDeleteDataWhenEmpty(t *testing.T) {
+	cache := testPostgresCache(t)
+	defer dropPostgresTable(cache)
+
+	cache.Set([]byte("key"), []byte("value"))
+	expect(t, cache.DeleteData(), nil)
+
+	// nothing to delete, like BoltCache
+	refute(t, cache.DeleteData(), nil)
+}
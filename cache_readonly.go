@@ -0,0 +1,70 @@
+package hoverfly
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrReadOnly - returned by read-only cache when captured requests would be added or deleted
+var ErrReadOnly = errors.New("cache is read-only")
+
+// ReadOnlyCache - wraps cache and refuses all changes, protects curated simulation from being
+// polluted when Hoverfly runs on shared infrastructure
+type ReadOnlyCache struct {
+	Cache
+}
+
+// NewReadOnlyCache - returns read-only view of given cache
+func NewReadOnlyCache(cache Cache) *ReadOnlyCache {
+	return &ReadOnlyCache{Cache: cache}
+}
+
+// Writable - returns client writing to the cache under read-only view. Simulation loaded on startup (-import, S3,
+// autosave file) goes through it, read-only mode protects the cache from changes made at runtime only
+func (d *DBClient) Writable() *DBClient {
+	writable := *d
+	if readOnly, ok := d.Cache.(*ReadOnlyCache); ok {
+		writable.Cache = readOnly.Cache
+	}
+	return &writable
+}
+
+// Set - always fails
+func (c *ReadOnlyCache) Set(key, value []byte) error {
+	return ErrReadOnly
+}
+
+// SetWithExpiry - always fails
+func (c *ReadOnlyCache) SetWithExpiry(key, value []byte, ttl time.Duration) error {
+	return ErrReadOnly
+}
+
+// SetMulti - always fails
+func (c *ReadOnlyCache) SetMulti(pairs []KeyValue) error {
+	return ErrReadOnly
+}
+
+// DeleteData - always fails
+func (c *ReadOnlyCache) DeleteData() error {
+	return ErrReadOnly
+}
+
+// DeleteKey - always fails
+func (c *ReadOnlyCache) DeleteKey(key []byte) error {
+	return ErrReadOnly
+}
+
+// DeleteDataWhere - always fails
+func (c *ReadOnlyCache) DeleteDataWhere(filter PayloadFilter) (int, error) {
+	return 0, ErrReadOnly
+}
+
+// WriteBackup - backups don't change the cache so they are still allowed
+func (c *ReadOnlyCache) WriteBackup(w io.Writer) (int64, error) {
+	backuper, ok := c.Cache.(BackupWriter)
+	if !ok {
+		return 0, errors.New("cache does not support backups")
+	}
+	return backuper.WriteBackup(w)
+}
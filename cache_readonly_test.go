@@ -0,0 +1,66 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadOnlyCacheRefusesChanges(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	dbClient.Cache.Set([]byte("key"), []byte("value"))
+
+	cache := NewReadOnlyCache(dbClient.Cache)
+
+	expect(t, cache.Set([]byte("other"), []byte("value")), ErrReadOnly)
+	expect(t, cache.SetMulti([]KeyValue{{Key: []byte("other"), Value: []byte("value")}}), ErrReadOnly)
+	expect(t, cache.DeleteKey([]byte("key")), ErrReadOnly)
+	expect(t, cache.DeleteData(), ErrReadOnly)
+
+	// reads still work
+	value, err := cache.Get([]byte("key"))
+	expect(t, err, nil)
+	expect(t, string(value), "value")
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 1)
+}
+
+func TestReadOnlyCacheWritableClientImports(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	dbClient.Cache = NewReadOnlyCache(dbClient.Cache)
+	refute(t, dbClient.Import("examples/exports/readthedocs.json"), nil)
+
+	expect(t, dbClient.Writable().Import("examples/exports/readthedocs.json"), nil)
+	count, err := dbClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count > 0, true)
+
+	// runtime changes are still refused
+	expect(t, dbClient.Cache.DeleteData(), ErrReadOnly)
+	expect(t, dbClient.Writable().Cache.DeleteData(), nil)
+}
+
+func TestReadOnlyCacheDeleteHandler(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	readOnly := *dbClient
+	readOnly.Cache = NewReadOnlyCache(dbClient.Cache)
+	m := getBoneRouter(readOnly)
+
+	req, err := http.NewRequest("DELETE", "/records", nil)
+	expect(t, err, nil)
+
+	respRec := httptest.NewRecorder()
+	m.ServeHTTP(respRec, req)
+	expect(t, respRec.Code, http.StatusForbidden)
+}
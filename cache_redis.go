@@ -0,0 +1,555 @@
+package hoverfly
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/garyburd/redigo/redis"
+)
+
+// RedisCache - container to implement Cache instance with Redis backend for storage. All payloads are
+// stored in a single Redis hash so several Hoverfly instances can share captured requests
+type RedisCache struct {
+	Pool         *redis.Pool
+	RequestsHash []byte
+	// MaxRecords - when set, the oldest records are evicted once there are more records in the hash
+	MaxRecords int
+
+	counters cacheCounters
+}
+
+// NewRedisCache - returns new RedisCache instance
+func NewRedisCache(pool *redis.Pool, hash []byte) *RedisCache {
+	return &RedisCache{
+		Pool:         pool,
+		RequestsHash: hash,
+	}
+}
+
+// GetRedisPool - returns connection pool for given Redis server address, password can be left empty
+func GetRedisPool(address, password string) *redis.Pool {
+	log.WithFields(log.Fields{
+		"address": address,
+	}).Info("Initiating Redis connection pool")
+
+	return &redis.Pool{
+		MaxIdle:     3,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			c, err := redis.Dial("tcp", address)
+			if err != nil {
+				return nil, err
+			}
+			if password != "" {
+				if _, err := c.Do("AUTH", password); err != nil {
+					c.Close()
+					return nil, err
+				}
+			}
+			return c, nil
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+}
+
+// CloseDB - closes connection pool
+func (c *RedisCache) CloseDB() {
+	c.Pool.Close()
+}
+
+// expiryHash - returns name of the hash that holds expiry timestamps of the requests hash fields,
+// Redis can only expire whole keys so hash fields have to be expired by Hoverfly
+func (c *RedisCache) expiryHash() []byte {
+	return []byte(string(c.RequestsHash) + "_expiry")
+}
+
+// orderSet - returns name of the sorted set that holds record keys scored by insertion time
+func (c *RedisCache) orderSet() []byte {
+	return []byte(string(c.RequestsHash) + "_order")
+}
+
+// redisExpired - checks whether expiry timestamp (unix nanoseconds) is in the past
+func redisExpired(expiry []byte, now time.Time) bool {
+	if expiry == nil {
+		return false
+	}
+	t, err := strconv.ParseInt(string(expiry), 10, 64)
+	return err == nil && t <= now.UnixNano()
+}
+
+// Set - saves given key and value pair to cache
+func (c *RedisCache) Set(key, value []byte) error {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("HSET", c.RequestsHash, key, value)
+	conn.Send("HDEL", c.expiryHash(), key)
+	if _, err := conn.Do("EXEC"); err != nil {
+		return err
+	}
+	c.counters.set(1)
+	return c.evict(conn, key)
+}
+
+// SetMulti - saves given key and value pairs to cache within a single MULTI/EXEC transaction
+func (c *RedisCache) SetMulti(pairs []KeyValue) error {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	keys := make([][]byte, len(pairs))
+	conn.Send("MULTI")
+	for i, kv := range pairs {
+		conn.Send("HSET", c.RequestsHash, kv.Key, kv.Value)
+		conn.Send("HDEL", c.expiryHash(), kv.Key)
+		keys[i] = kv.Key
+	}
+	if _, err := conn.Do("EXEC"); err != nil {
+		return err
+	}
+	c.counters.set(len(pairs))
+	return c.evict(conn, keys...)
+}
+
+// SetWithExpiry - saves given key and value pair to cache, record is removed once ttl passes
+func (c *RedisCache) SetWithExpiry(key, value []byte, ttl time.Duration) error {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	expiry := strconv.FormatInt(time.Now().Add(ttl).UnixNano(), 10)
+
+	conn.Send("MULTI")
+	conn.Send("HSET", c.RequestsHash, key, value)
+	conn.Send("HSET", c.expiryHash(), key, expiry)
+	if _, err := conn.Do("EXEC"); err != nil {
+		return err
+	}
+	c.counters.set(1)
+	return c.evict(conn, key)
+}
+
+// evict - tracks insertion order of given keys and removes the oldest records until there are
+// no more than MaxRecords left
+func (c *RedisCache) evict(conn redis.Conn, keys ...[]byte) error {
+	if c.MaxRecords <= 0 {
+		return nil
+	}
+
+	// keys saved together keep their order
+	now := time.Now().UnixNano()
+	for i, key := range keys {
+		conn.Send("ZADD", c.orderSet(), now+int64(i), key)
+	}
+	if _, err := conn.Do(""); err != nil {
+		return err
+	}
+
+	count, err := redis.Int(conn.Do("HLEN", c.RequestsHash))
+	if err != nil || count <= c.MaxRecords {
+		return err
+	}
+	if err := c.seedOrder(conn, count); err != nil {
+		return err
+	}
+
+	oldest, err := redis.ByteSlices(conn.Do("ZRANGE", c.orderSet(), 0, count-c.MaxRecords-1))
+	if err != nil {
+		return err
+	}
+
+	for _, k := range oldest {
+		conn.Send("HDEL", c.RequestsHash, k)
+		conn.Send("HDEL", c.expiryHash(), k)
+		conn.Send("ZREM", c.orderSet(), k)
+	}
+	_, err = conn.Do("")
+
+	log.WithFields(log.Fields{
+		"count":      len(oldest),
+		"maxRecords": c.MaxRecords,
+	}).Debug("records evicted")
+	return err
+}
+
+// seedOrder - adds records saved while MaxRecords wasn't set (i.e. by previous runs) to insertion order. Their
+// real order isn't known, so they get the lowest score and are evicted before all tracked records
+func (c *RedisCache) seedOrder(conn redis.Conn, count int) error {
+	tracked, err := redis.Int(conn.Do("ZCARD", c.orderSet()))
+	if err != nil || tracked >= count {
+		return err
+	}
+
+	keys, err := redis.ByteSlices(conn.Do("HKEYS", c.RequestsHash))
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		conn.Send("ZADD", c.orderSet(), "NX", 0, k)
+	}
+	_, err = conn.Do("")
+	return err
+}
+
+// purge - removes given expired keys together with their expiry timestamps
+func (c *RedisCache) purge(conn redis.Conn, keys [][]byte) {
+	for _, k := range keys {
+		conn.Send("HDEL", c.RequestsHash, k)
+		conn.Send("HDEL", c.expiryHash(), k)
+		conn.Send("ZREM", c.orderSet(), k)
+	}
+	// flushing pipeline and receiving all pending replies
+	if _, err := conn.Do(""); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"count": len(keys),
+		}).Warning("Failed to purge expired records")
+	}
+}
+
+// Get - searches for given key in the cache and returns value if found
+func (c *RedisCache) Get(key []byte) (value []byte, err error) {
+	conn := c.Pool.Get()
+	defer conn.Close()
+	defer func() { c.counters.get(err == nil) }()
+
+	value, err = redis.Bytes(conn.Do("HGET", c.RequestsHash, key))
+	if err == redis.ErrNil {
+		return nil, fmt.Errorf("key %q not found \n", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	expiry, err := redis.Bytes(conn.Do("HGET", c.expiryHash(), key))
+	if err == nil && redisExpired(expiry, time.Now()) {
+		c.purge(conn, [][]byte{key})
+		return nil, fmt.Errorf("key %q not found \n", key)
+	}
+	return value, nil
+}
+
+// Stats - returns cache usage statistics collected by this Hoverfly instance
+func (c *RedisCache) Stats() CacheStats {
+	return c.counters.snapshot()
+}
+
+// GetAllRequests - returns all captured requests/responses
+func (c *RedisCache) GetAllRequests() (payloads []Payload, err error) {
+	err = c.ForEachRequest(func(pl Payload) error {
+		payloads = append(payloads, pl)
+		return nil
+	})
+	return
+}
+
+// redisScanCount - number of hash fields fetched from Redis per iteration
+const redisScanCount = 100
+
+// ForEachRequest - decodes captured requests/responses one by one and passes them to given function,
+// iteration stops when function returns an error
+func (c *RedisCache) ForEachRequest(fn func(Payload) error) error {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	return c.scan(conn, func(key []byte, pl *Payload) error {
+		return fn(*pl)
+	})
+}
+
+// scan - decodes records one by one and passes them together with their keys to given function. Hash is
+// scanned in batches so the whole hash is never loaded into memory
+func (c *RedisCache) scan(conn redis.Conn, fn func(key []byte, pl *Payload) error) error {
+	var expired [][]byte
+	defer func() {
+		if len(expired) > 0 {
+			c.purge(conn, expired)
+		}
+	}()
+
+	cursor := 0
+	for {
+		reply, err := redis.Values(conn.Do("HSCAN", c.RequestsHash, cursor, "COUNT", redisScanCount))
+		if err != nil {
+			return err
+		}
+		if len(reply) != 2 {
+			return fmt.Errorf("unexpected HSCAN reply")
+		}
+		cursor, err = redis.Int(reply[0], nil)
+		if err != nil {
+			return err
+		}
+		values, err := redis.ByteSlices(reply[1], nil)
+		if err != nil {
+			return err
+		}
+
+		if len(values) > 0 {
+			args := redis.Args{}.Add(c.expiryHash())
+			for i := 0; i < len(values); i += 2 {
+				args = args.Add(values[i])
+			}
+			expiries, err := redis.ByteSlices(conn.Do("HMGET", args...))
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			for i := 0; i+1 < len(values); i += 2 {
+				k, v := values[i], values[i+1]
+				if redisExpired(expiries[i/2], now) {
+					expired = append(expired, k)
+					continue
+				}
+				pl, err := decodePayload(v)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"error": err.Error(),
+						"json":  v,
+					}).Warning("Failed to deserialize bytes to payload.")
+					continue
+				}
+				if err := fn(k, pl); err != nil {
+					return err
+				}
+			}
+		}
+
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// GetRequestsPage - returns up to limit captured requests/responses ordered by key, starting at given offset.
+// Records that fail to decode are counted in offset but not returned
+func (c *RedisCache) GetRequestsPage(offset, limit int) ([]Payload, error) {
+	payloads := []Payload{}
+	if offset < 0 || limit <= 0 {
+		return payloads, fmt.Errorf("invalid page, offset must not be negative and limit must be positive")
+	}
+
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	// Redis hashes are unordered, only keys are sorted to keep pages stable
+	keys, err := redis.Strings(conn.Do("HKEYS", c.RequestsHash))
+	if err != nil {
+		return payloads, err
+	}
+	expiries, err := redis.StringMap(conn.Do("HGETALL", c.expiryHash()))
+	if err != nil {
+		return payloads, err
+	}
+
+	now := time.Now()
+	live := keys[:0]
+	for _, k := range keys {
+		if e, ok := expiries[k]; ok && redisExpired([]byte(e), now) {
+			continue
+		}
+		live = append(live, k)
+	}
+	sort.Strings(live)
+
+	if offset >= len(live) {
+		return payloads, nil
+	}
+	end := offset + limit
+	if end > len(live) {
+		end = len(live)
+	}
+
+	values, err := redis.ByteSlices(conn.Do("HMGET", redis.Args{}.Add(c.RequestsHash).AddFlat(live[offset:end])...))
+	if err != nil {
+		return payloads, err
+	}
+
+	for _, v := range values {
+		if v == nil {
+			// record was removed in the meantime
+			continue
+		}
+		pl, err := decodePayload(v)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"json":  v,
+			}).Warning("Failed to deserialize bytes to payload.")
+			continue
+		}
+		payloads = append(payloads, *pl)
+	}
+	return payloads, nil
+}
+
+// RecordsCount - returns records count
+func (c *RedisCache) RecordsCount() (int, error) {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	return redis.Int(conn.Do("HLEN", c.RequestsHash))
+}
+
+// RecordsCountByDestination - returns records count for each destination host
+func (c *RedisCache) RecordsCountByDestination() (map[string]int, error) {
+	return countByDestination(c)
+}
+
+// DeleteData - deletes hash with all saved data
+func (c *RedisCache) DeleteData() error {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	deleted, err := redis.Int(conn.Do("DEL", c.RequestsHash, c.expiryHash(), c.orderSet()))
+	if err != nil {
+		return err
+	}
+	// keeping the same behaviour as BoltCache when there is nothing to delete
+	if deleted == 0 {
+		return fmt.Errorf("bucket not found")
+	}
+	return nil
+}
+
+// DeleteKey - deletes single record
+func (c *RedisCache) DeleteKey(key []byte) error {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("HDEL", c.RequestsHash, key)
+	conn.Send("HDEL", c.expiryHash(), key)
+	conn.Send("ZREM", c.orderSet(), key)
+	reply, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return err
+	}
+
+	deleted, err := redis.Int(reply[0], nil)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("key %q not found \n", key)
+	}
+	return nil
+}
+
+// DeleteDataWhere - deletes records whose payloads match given filter, returns deleted records count
+func (c *RedisCache) DeleteDataWhere(filter PayloadFilter) (int, error) {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	var keys [][]byte
+	err := c.scan(conn, func(key []byte, pl *Payload) error {
+		if filter.Match(pl) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+
+	for _, k := range keys {
+		conn.Send("HDEL", c.RequestsHash, k)
+		conn.Send("HDEL", c.expiryHash(), k)
+		conn.Send("ZREM", c.orderSet(), k)
+	}
+	if _, err := conn.Do(""); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// GetAllKeys - gets all current keys
+func (c *RedisCache) GetAllKeys() (map[string]bool, error) {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	keys := make(map[string]bool)
+
+	values, err := redis.Strings(conn.Do("HKEYS", c.RequestsHash))
+	if err != nil {
+		return keys, err
+	}
+
+	for _, k := range values {
+		keys[k] = true
+	}
+	return keys, nil
+}
+
+// redisGlobEscaper - escapes characters that have special meaning in MATCH patterns
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// GetKeysWithPrefix - returns sorted keys that start with given prefix, hash is scanned with MATCH
+// pattern so values are not transferred
+func (c *RedisCache) GetKeysWithPrefix(prefix []byte) ([][]byte, error) {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	pattern := redisGlobEscaper.Replace(string(prefix)) + "*"
+
+	var found []string
+	cursor := 0
+	for {
+		reply, err := redis.Values(conn.Do("HSCAN", c.RequestsHash, cursor, "MATCH", pattern, "COUNT", redisScanCount))
+		if err != nil {
+			return nil, err
+		}
+		if len(reply) != 2 {
+			return nil, fmt.Errorf("unexpected HSCAN reply")
+		}
+		cursor, err = redis.Int(reply[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		values, err := redis.Strings(reply[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(values); i += 2 {
+			found = append(found, values[i])
+		}
+
+		if cursor == 0 {
+			break
+		}
+	}
+	sort.Strings(found)
+
+	keys := make([][]byte, 0, len(found))
+	if len(found) == 0 {
+		return keys, nil
+	}
+
+	// expired records are skipped, they are purged on next read
+	args := redis.Args{}.Add(c.expiryHash())
+	for _, k := range found {
+		args = args.Add(k)
+	}
+	expiries, err := redis.ByteSlices(conn.Do("HMGET", args...))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i, k := range found {
+		if !redisExpired(expiries[i], now) {
+			keys = append(keys, []byte(k))
+		}
+	}
+	return keys, nil
+}
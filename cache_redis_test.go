@@ -0,0 +1,194 @@
+package hoverfly
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// testRedisCache - returns RedisCache with random hash name, tests are skipped
+// when Redis server is not reachable
+func testRedisCache(t *testing.T) *RedisCache {
+	address := os.Getenv("HoverflyRedisAddress")
+	if address == "" {
+		address = DefaultRedisAddress
+	}
+	cache := NewRedisCache(GetRedisPool(address, ""), GetRandomName(10))
+
+	conn := cache.Pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PING"); err != nil {
+		t.Skipf("Redis is not available at %s: %s", address, err.Error())
+	}
+	return cache
+}
+
+func TestRedisSetGet(t *testing.T) {
+	cache := testRedisCache(t)
+	defer cache.CloseDB()
+	defer cache.DeleteData()
+
+	err := cache.Set([]byte("key"), []byte("value"))
+	expect(t, err, nil)
+
+	value, err := cache.Get([]byte("key"))
+	expect(t, err, nil)
+	expect(t, string(value), "value")
+}
+
+func TestRedisGetNonExistingKey(t *testing.T) {
+	cache := testRedisCache(t)
+	defer cache.CloseDB()
+
+	_, err := cache.Get([]byte("should not be here"))
+	refute(t, err, nil)
+}
+
+func TestRedisRecordsCountAndKeys(t *testing.T) {
+	cache := testRedisCache(t)
+	defer cache.CloseDB()
+	defer cache.DeleteData()
+
+	for i := 0; i < 5; i++ {
+		cache.Set([]byte(fmt.Sprintf("key%d", i)), []byte("value"))
+	}
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 5)
+
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, len(keys), 5)
+	expect(t, keys["key0"], true)
+}
+
+func TestRedisGetAllRequestsSkipsCorrupted(t *testing.T) {
+	cache := testRedisCache(t)
+	defer cache.CloseDB()
+	defer cache.DeleteData()
+
+	payload := Payload{Response: ResponseDetails{Status: 200, Body: "body here"}}
+	bts, err := payload.Encode()
+	expect(t, err, nil)
+
+	cache.Set([]byte("good"), bts)
+	cache.Set([]byte("bad"), []byte("value"))
+
+	payloads, err := cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Response.Body, "body here")
+}
+
+func TestRedisDeleteData(t *testing.T) {
+	cache := testRedisCache(t)
+	defer cache.CloseDB()
+
+	cache.Set([]byte("key"), []byte("value"))
+
+	err := cache.DeleteData()
+	expect(t, err, nil)
+
+	// deleting it again
+	err = cache.DeleteData()
+	refute(t, err, nil)
+}
+
+func TestRedisExpiry(t *testing.T) {
+	cache := testRedisCache(t)
+	defer cache.CloseDB()
+	defer cache.DeleteData()
+
+	cache.SetWithExpiry([]byte("fresh"), []byte("value"), time.Hour)
+	cache.SetWithExpiry([]byte("stale"), []byte("value"), -time.Second)
+
+	_, err := cache.Get([]byte("fresh"))
+	expect(t, err, nil)
+
+	_, err = cache.Get([]byte("stale"))
+	refute(t, err, nil)
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 1)
+}
+
+func TestRedisMaxRecordsEvictsOldest(t *testing.T) {
+	cache := testRedisCache(t)
+	cache.MaxRecords = 3
+	defer cache.CloseDB()
+	defer cache.DeleteData()
+
+	for i := 0; i < 5; i++ {
+		err := cache.Set([]byte(fmt.Sprintf("key%d", i)), []byte("value"))
+		expect(t, err, nil)
+	}
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 3)
+}
+
+func TestRedisMaxRecordsEvictsRecordsSavedWithoutLimit(t *testing.T) {
+	cache := testRedisCache(t)
+	defer cache.CloseDB()
+	defer cache.DeleteData()
+
+	// records of a previous run without MaxRecords aren't tracked in insertion order
+	for i := 0; i < 3; i++ {
+		err := cache.Set([]byte(fmt.Sprintf("old%d", i)), []byte("value"))
+		expect(t, err, nil)
+	}
+
+	cache.MaxRecords = 3
+	for i := 0; i < 2; i++ {
+		err := cache.Set([]byte(fmt.Sprintf("new%d", i)), []byte("value"))
+		expect(t, err, nil)
+	}
+
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, len(keys), 3)
+	expect(t, keys["new0"], true)
+	expect(t, keys["new1"], true)
+}
+
+func TestRedisDeleteKey(t *testing.T) {
+	cache := testRedisCache(t)
+	defer cache.CloseDB()
+	defer cache.DeleteData()
+
+	cache.Set([]byte("first"), []byte("value"))
+
+	err := cache.DeleteKey([]byte("first"))
+	expect(t, err, nil)
+
+	err = cache.DeleteKey([]byte("first"))
+	refute(t, err, nil)
+}
+
+func TestRedisGetKeysWithPrefix(t *testing.T) {
+	cache := testRedisCache(t)
+	defer cache.CloseDB()
+	defer cache.DeleteData()
+
+	for _, key := range []string{"api.example.com/b", "api.example.com/a", "api*example.com/a", "other.com/a"} {
+		cache.Set([]byte(key), []byte("value"))
+	}
+	cache.SetWithExpiry([]byte("api.example.com/expired"), []byte("value"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	keys, err := cache.GetKeysWithPrefix([]byte("api.example.com"))
+	expect(t, err, nil)
+	expect(t, len(keys), 2)
+	expect(t, string(keys[0]), "api.example.com/a")
+	expect(t, string(keys[1]), "api.example.com/b")
+
+	// pattern characters in prefix are matched literally
+	keys, err = cache.GetKeysWithPrefix([]byte("api*"))
+	expect(t, err, nil)
+	expect(t, len(keys), 1)
+	expect(t, string(keys[0]), "api*example.com/a")
+}
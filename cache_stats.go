@@ -0,0 +1,61 @@
+package hoverfly
+
+import (
+	"sync"
+	"time"
+)
+
+// CacheStats - cache usage statistics, they show how well stored simulation covers incoming traffic
+type CacheStats struct {
+	Sets     int64     `json:"sets"`
+	Gets     int64     `json:"gets"`
+	Hits     int64     `json:"hits"`
+	Misses   int64     `json:"misses"`
+	HitRatio float64   `json:"hitRatio"`
+	LastSet  time.Time `json:"lastSet"`
+	LastGet  time.Time `json:"lastGet"`
+	LastHit  time.Time `json:"lastHit"`
+}
+
+// cacheCounters - collects cache statistics, zero value is ready to use
+type cacheCounters struct {
+	mu    sync.Mutex
+	stats CacheStats
+}
+
+// set - records given number of successful writes
+func (cc *cacheCounters) set(n int) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.stats.Sets += int64(n)
+	cc.stats.LastSet = time.Now()
+}
+
+// get - records lookup, hit tells whether value was found
+func (cc *cacheCounters) get(hit bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	now := time.Now()
+	cc.stats.Gets++
+	cc.stats.LastGet = now
+	if hit {
+		cc.stats.Hits++
+		cc.stats.LastHit = now
+	} else {
+		cc.stats.Misses++
+	}
+}
+
+// snapshot - returns copy of collected statistics with hit ratio calculated
+func (cc *cacheCounters) snapshot() CacheStats {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	stats := cc.stats
+	if stats.Gets > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(stats.Gets)
+	}
+	return stats
+}
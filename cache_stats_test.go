@@ -0,0 +1,55 @@
+package hoverfly
+
+import (
+	"testing"
+)
+
+func TestCacheCountersHitRatio(t *testing.T) {
+	var cc cacheCounters
+
+	expect(t, cc.snapshot().HitRatio, 0.0)
+
+	cc.set(1)
+	cc.get(true)
+	cc.get(true)
+	cc.get(true)
+	cc.get(false)
+
+	stats := cc.snapshot()
+	expect(t, stats.Sets, int64(1))
+	expect(t, stats.Gets, int64(4))
+	expect(t, stats.Hits, int64(3))
+	expect(t, stats.Misses, int64(1))
+	expect(t, stats.HitRatio, 0.75)
+	refute(t, stats.LastSet.IsZero(), true)
+	refute(t, stats.LastHit.IsZero(), true)
+}
+
+func TestBoltCacheStats(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	dbClient.Cache.Set([]byte("key"), []byte("value"))
+	dbClient.Cache.Get([]byte("key"))
+	dbClient.Cache.Get([]byte("nothere"))
+
+	stats := dbClient.Cache.Stats()
+	expect(t, stats.Sets, int64(1))
+	expect(t, stats.Hits, int64(1))
+	expect(t, stats.Misses, int64(1))
+	expect(t, stats.HitRatio, 0.5)
+}
+
+func TestMemoryCacheStats(t *testing.T) {
+	cache := NewMemoryCache()
+
+	cache.Set([]byte("key"), []byte("value"))
+	cache.Get([]byte("key"))
+	cache.Get([]byte("nothere"))
+
+	stats := cache.Stats()
+	expect(t, stats.Sets, int64(1))
+	expect(t, stats.Hits, int64(1))
+	expect(t, stats.Misses, int64(1))
+}
@@ -6,6 +6,9 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
 )
 
 func TestSetKey(t *testing.T) {
@@ -179,3 +182,501 @@ func TestGetAllKeysEmpty(t *testing.T) {
 	expect(t, err, nil)
 	expect(t, len(keys), 0)
 }
+
+func TestSetWithExpiry(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.Cache.SetWithExpiry([]byte("fresh"), []byte("value"), time.Hour)
+	expect(t, err, nil)
+
+	value, err := dbClient.Cache.Get([]byte("fresh"))
+	expect(t, err, nil)
+	expect(t, string(value), "value")
+}
+
+func TestGetExpiredKey(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.Cache.SetWithExpiry([]byte("stale"), []byte("value"), -time.Second)
+	expect(t, err, nil)
+
+	_, err = dbClient.Cache.Get([]byte("stale"))
+	refute(t, err, nil)
+
+	// expired record should be purged
+	count, err := dbClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 0)
+}
+
+func TestSetRemovesExpiry(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	dbClient.Cache.SetWithExpiry([]byte("key"), []byte("old"), -time.Second)
+	dbClient.Cache.Set([]byte("key"), []byte("new"))
+
+	value, err := dbClient.Cache.Get([]byte("key"))
+	expect(t, err, nil)
+	expect(t, string(value), "new")
+}
+
+func TestGetAllRequestsSkipsExpired(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	dbClient.Cfg.RecordTTL = time.Hour
+	req, err := http.NewRequest("GET", "http://example.com/fresh", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	dbClient.Cfg.RecordTTL = time.Nanosecond
+	req, err = http.NewRequest("GET", "http://example.com/stale", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+	time.Sleep(time.Millisecond)
+
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Path, "/fresh")
+
+	count, err := dbClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 1)
+}
+
+func TestRecordsCountAndKeysSkipExpired(t *testing.T) {
+	cache := NewBoltDBCache(TestDB, GetRandomName(10))
+	defer cache.DeleteData()
+
+	cache.Set([]byte("live"), []byte("value"))
+	cache.SetWithExpiry([]byte("fresh"), []byte("value"), time.Hour)
+	cache.SetWithExpiry([]byte("expired"), []byte("value"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	// expired record isn't purged until it's read, it's skipped anyway
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 2)
+
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, len(keys), 2)
+	expect(t, keys["expired"], false)
+}
+
+func TestMaxRecordsEvictsOldest(t *testing.T) {
+	cache := NewBoltDBCache(TestDB, GetRandomName(10))
+	cache.MaxRecords = 3
+	defer cache.DeleteData()
+
+	for i := 0; i < 5; i++ {
+		err := cache.Set([]byte(fmt.Sprintf("key%d", i)), []byte("value"))
+		expect(t, err, nil)
+	}
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 3)
+
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, keys["key0"], false)
+	expect(t, keys["key1"], false)
+	expect(t, keys["key4"], true)
+}
+
+func TestMaxRecordsEvictsRecordsSavedWithoutLimit(t *testing.T) {
+	cache := NewBoltDBCache(TestDB, GetRandomName(10))
+	defer cache.DeleteData()
+
+	// records of a previous run without MaxRecords aren't tracked in insertion order
+	for i := 0; i < 3; i++ {
+		err := cache.Set([]byte(fmt.Sprintf("old%d", i)), []byte("value"))
+		expect(t, err, nil)
+	}
+
+	cache.MaxRecords = 3
+	for i := 0; i < 2; i++ {
+		err := cache.Set([]byte(fmt.Sprintf("new%d", i)), []byte("value"))
+		expect(t, err, nil)
+	}
+
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, len(keys), 3)
+	expect(t, keys["new0"], true)
+	expect(t, keys["new1"], true)
+	expect(t, keys["old0"] || keys["old1"] || keys["old2"], true)
+}
+
+func TestSetMulti(t *testing.T) {
+	cache := NewBoltDBCache(TestDB, GetRandomName(10))
+	defer cache.DeleteData()
+
+	err := cache.SetMulti([]KeyValue{
+		{Key: []byte("first"), Value: []byte("value1")},
+		{Key: []byte("second"), Value: []byte("value2")},
+	})
+	expect(t, err, nil)
+
+	value, err := cache.Get([]byte("second"))
+	expect(t, err, nil)
+	expect(t, string(value), "value2")
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 2)
+}
+
+func TestSetMultiEvictsOldest(t *testing.T) {
+	cache := NewBoltDBCache(TestDB, GetRandomName(10))
+	cache.MaxRecords = 3
+	defer cache.DeleteData()
+
+	cache.Set([]byte("existing"), []byte("value"))
+
+	var pairs []KeyValue
+	for i := 0; i < 5; i++ {
+		pairs = append(pairs, KeyValue{Key: []byte(fmt.Sprintf("key%d", i)), Value: []byte("value")})
+	}
+	err := cache.SetMulti(pairs)
+	expect(t, err, nil)
+
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, len(keys), 3)
+	expect(t, keys["existing"], false)
+	expect(t, keys["key1"], false)
+	expect(t, keys["key4"], true)
+}
+
+func TestMaxRecordsOverwriteRefreshesOrder(t *testing.T) {
+	cache := NewBoltDBCache(TestDB, GetRandomName(10))
+	cache.MaxRecords = 2
+	defer cache.DeleteData()
+
+	cache.Set([]byte("first"), []byte("value"))
+	cache.Set([]byte("second"), []byte("value"))
+	// overwriting first record makes second the oldest one
+	cache.Set([]byte("first"), []byte("new value"))
+	cache.Set([]byte("third"), []byte("value"))
+
+	keys, err := cache.GetAllKeys()
+	expect(t, err, nil)
+	expect(t, len(keys), 2)
+	expect(t, keys["first"], true)
+	expect(t, keys["second"], false)
+	expect(t, keys["third"], true)
+}
+
+func TestForEachRequest(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	for i := 0; i < 5; i++ {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://example.com/q=%d", i), nil)
+		expect(t, err, nil)
+		dbClient.captureRequest(req)
+	}
+
+	visited := 0
+	err := dbClient.Cache.ForEachRequest(func(pl Payload) error {
+		expect(t, pl.Request.Method, "GET")
+		visited++
+		return nil
+	})
+	expect(t, err, nil)
+	expect(t, visited, 5)
+}
+
+func TestForEachRequestStopsOnError(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	for i := 0; i < 5; i++ {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://example.com/q=%d", i), nil)
+		expect(t, err, nil)
+		dbClient.captureRequest(req)
+	}
+
+	visited := 0
+	stop := fmt.Errorf("stop")
+	err := dbClient.Cache.ForEachRequest(func(pl Payload) error {
+		visited++
+		return stop
+	})
+	expect(t, err, stop)
+	expect(t, visited, 1)
+}
+
+func TestGetRequestsPage(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	for i := 0; i < 5; i++ {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://example.com/q=%d", i), nil)
+		expect(t, err, nil)
+		dbClient.captureRequest(req)
+	}
+
+	first, err := dbClient.Cache.GetRequestsPage(0, 3)
+	expect(t, err, nil)
+	expect(t, len(first), 3)
+
+	second, err := dbClient.Cache.GetRequestsPage(3, 3)
+	expect(t, err, nil)
+	expect(t, len(second), 2)
+
+	// pages should not overlap
+	for _, pl := range second {
+		for _, other := range first {
+			refute(t, pl.ID, other.ID)
+		}
+	}
+
+	empty, err := dbClient.Cache.GetRequestsPage(10, 3)
+	expect(t, err, nil)
+	expect(t, len(empty), 0)
+}
+
+func TestGetRequestsPageInvalid(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	_, err := dbClient.Cache.GetRequestsPage(-1, 3)
+	refute(t, err, nil)
+
+	_, err = dbClient.Cache.GetRequestsPage(0, 0)
+	refute(t, err, nil)
+}
+
+func TestDeleteKey(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	dbClient.Cache.Set([]byte("first"), []byte("value"))
+	dbClient.Cache.Set([]byte("second"), []byte("value"))
+
+	err := dbClient.Cache.DeleteKey([]byte("first"))
+	expect(t, err, nil)
+
+	_, err = dbClient.Cache.Get([]byte("first"))
+	refute(t, err, nil)
+
+	_, err = dbClient.Cache.Get([]byte("second"))
+	expect(t, err, nil)
+
+	// deleting it again
+	err = dbClient.Cache.DeleteKey([]byte("first"))
+	refute(t, err, nil)
+}
+
+func TestDeleteDataWhere(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	for _, u := range []string{"http://example.com/a", "http://example.com/b", "http://other.com/a"} {
+		req, err := http.NewRequest("GET", u, nil)
+		expect(t, err, nil)
+		dbClient.captureRequest(req)
+	}
+
+	deleted, err := dbClient.Cache.DeleteDataWhere(PayloadFilter{Destination: "example.com"})
+	expect(t, err, nil)
+	expect(t, deleted, 2)
+
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Destination, "other.com")
+}
+
+func TestCompressedSetGet(t *testing.T) {
+	cache := NewBoltDBCache(TestDB, GetRandomName(10))
+	cache.Compress = true
+	defer cache.DeleteData()
+
+	value := []byte(strings.Repeat("<html>big body</html>", 100))
+	err := cache.Set([]byte("key"), value)
+	expect(t, err, nil)
+
+	got, err := cache.Get([]byte("key"))
+	expect(t, err, nil)
+	expect(t, string(got), string(value))
+
+	// checking that value is actually compressed on disk
+	raw := NewBoltDBCache(TestDB, cache.RequestsBucket)
+	raw.DS.View(func(tx *bolt.Tx) error {
+		stored := tx.Bucket(raw.RequestsBucket).Get([]byte("key"))
+		expect(t, isEnvelope(stored), true)
+		expect(t, len(stored) < len(value), true)
+		return nil
+	})
+}
+
+func TestCompressedReadsUncompressedRecords(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	// capturing without compression
+	req, err := http.NewRequest("GET", "http://example.com/old", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	// capturing with compression
+	dbClient.Cache.(*BoltCache).Compress = true
+	req, err = http.NewRequest("GET", "http://example.com/new", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 2)
+
+	for _, payload := range payloads {
+		expect(t, payload.Response.Status, 201)
+	}
+}
+
+func TestEncryptedSetGet(t *testing.T) {
+	aead, err := NewEncryptionCipher(testEncryptionKey)
+	expect(t, err, nil)
+
+	cache := NewBoltDBCache(TestDB, GetRandomName(10))
+	cache.Cipher = aead
+	defer cache.DeleteData()
+
+	err = cache.Set([]byte("key"), []byte("secret value"))
+	expect(t, err, nil)
+
+	got, err := cache.Get([]byte("key"))
+	expect(t, err, nil)
+	expect(t, string(got), "secret value")
+
+	// cache without key can't read encrypted records
+	plain := NewBoltDBCache(TestDB, cache.RequestsBucket)
+	_, err = plain.Get([]byte("key"))
+	refute(t, err, nil)
+}
+
+func TestNamespaces(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	cache := dbClient.Cache.(*BoltCache)
+	defaultNs := cache.CurrentNamespace()
+
+	dbClient.Cache.Set([]byte("default_key"), []byte("value"))
+
+	err := cache.CreateNamespace("TestNamespaces")
+	expect(t, err, nil)
+
+	// creating it again
+	err = cache.CreateNamespace("TestNamespaces")
+	refute(t, err, nil)
+
+	names, err := cache.ListNamespaces()
+	expect(t, err, nil)
+	found := map[string]bool{}
+	for _, name := range names {
+		found[name] = true
+	}
+	expect(t, found[defaultNs], true)
+	expect(t, found["TestNamespaces"], true)
+
+	err = cache.SwitchNamespace("TestNamespaces")
+	expect(t, err, nil)
+	expect(t, cache.CurrentNamespace(), "TestNamespaces")
+
+	// new namespace is empty
+	count, err := dbClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 0)
+
+	dbClient.Cache.Set([]byte("namespaced_key"), []byte("value"))
+
+	// current namespace can't be deleted
+	err = cache.DeleteNamespace("TestNamespaces")
+	refute(t, err, nil)
+
+	err = cache.SwitchNamespace(defaultNs)
+	expect(t, err, nil)
+
+	_, err = dbClient.Cache.Get([]byte("namespaced_key"))
+	refute(t, err, nil)
+	_, err = dbClient.Cache.Get([]byte("default_key"))
+	expect(t, err, nil)
+
+	err = cache.DeleteNamespace("TestNamespaces")
+	expect(t, err, nil)
+
+	err = cache.SwitchNamespace("TestNamespaces")
+	refute(t, err, nil)
+}
+
+func TestCreateNamespaceReservedName(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	cache := dbClient.Cache.(*BoltCache)
+
+	refute(t, cache.CreateNamespace(""), nil)
+	refute(t, cache.CreateNamespace("_namespaces"), nil)
+	refute(t, cache.CreateNamespace("bucket_expiry"), nil)
+	refute(t, cache.CreateNamespace("bucket_order"), nil)
+	refute(t, cache.CreateNamespace("bucket_order_index"), nil)
+
+	// reserved suffixes only clash at the end of the name
+	expect(t, cache.CreateNamespace("my_orders"), nil)
+	defer cache.DeleteNamespace("my_orders")
+	expect(t, cache.CreateNamespace("my_expiry_dates"), nil)
+	defer cache.DeleteNamespace("my_expiry_dates")
+}
+
+func TestGetKeysWithPrefix(t *testing.T) {
+	cache := NewBoltDBCache(TestDB, GetRandomName(10))
+	defer cache.DeleteData()
+
+	for _, key := range []string{"api.example.com/b", "api.example.com/a", "apiary.com/a", "other.com/a"} {
+		cache.Set([]byte(key), []byte("value"))
+	}
+
+	keys, err := cache.GetKeysWithPrefix([]byte("api.example.com"))
+	expect(t, err, nil)
+	expect(t, len(keys), 2)
+	expect(t, string(keys[0]), "api.example.com/a")
+	expect(t, string(keys[1]), "api.example.com/b")
+
+	keys, err = cache.GetKeysWithPrefix([]byte("nothere"))
+	expect(t, err, nil)
+	expect(t, len(keys), 0)
+}
+
+func TestGetKeysWithPrefixSkipsExpired(t *testing.T) {
+	cache := NewBoltDBCache(TestDB, GetRandomName(10))
+	defer cache.DeleteData()
+
+	cache.Set([]byte("prefix_live"), []byte("value"))
+	cache.SetWithExpiry([]byte("prefix_expired"), []byte("value"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	keys, err := cache.GetKeysWithPrefix([]byte("prefix_"))
+	expect(t, err, nil)
+	expect(t, len(keys), 1)
+	expect(t, string(keys[0]), "prefix_live")
+}
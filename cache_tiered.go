@@ -0,0 +1,289 @@
+package hoverfly
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ErrCacheClosed - returned by tiered cache when records are saved after it was closed
+var ErrCacheClosed = errors.New("cache is closed")
+
+// tieredWriteQueueSize - how many writes can wait for persistence before Set starts blocking
+const tieredWriteQueueSize = 1024
+
+// tieredWrite - single write waiting to be persisted, batch holds records saved together and
+// when done is set it's a flush marker instead
+type tieredWrite struct {
+	key   []byte
+	value []byte
+	ttl   time.Duration
+	batch []KeyValue
+	done  chan struct{}
+}
+
+// newTieredWrite - copies key and value since caller might reuse given slices before they are persisted
+func newTieredWrite(key, value []byte, ttl time.Duration) tieredWrite {
+	return tieredWrite{
+		key:   append([]byte{}, key...),
+		value: append([]byte{}, value...),
+		ttl:   ttl,
+	}
+}
+
+// expiringCache - implemented by backing caches that can tell when their records expire
+type expiringCache interface {
+	ExpiresAt(key []byte) (time.Time, bool)
+}
+
+// TieredCache - serves lookups from memory and persists writes to backing cache (usually BoltDB)
+// in the background. Records that are not in memory yet are loaded from backing cache on first
+// lookup. Operations that list or delete records wait until pending writes are persisted.
+type TieredCache struct {
+	front  *MemoryCache
+	back   Cache
+	writes chan tieredWrite
+	closed chan struct{}
+	// mu - guards shut, writes are queued under read lock so the queue isn't closed while they are being sent
+	mu   sync.RWMutex
+	shut bool
+
+	counters cacheCounters
+}
+
+// NewTieredCache - returns new TieredCache that persists records to given cache, maxRecords limits
+// how many records are kept in memory (0 means no limit)
+func NewTieredCache(back Cache, maxRecords int) *TieredCache {
+	front := NewMemoryCache()
+	front.MaxRecords = maxRecords
+
+	c := &TieredCache{
+		front:  front,
+		back:   back,
+		writes: make(chan tieredWrite, tieredWriteQueueSize),
+		closed: make(chan struct{}),
+	}
+	go c.persist()
+
+	return c
+}
+
+// persist - writes queued records to backing cache until queue is closed
+func (c *TieredCache) persist() {
+	defer close(c.closed)
+
+	for w := range c.writes {
+		if w.done != nil {
+			close(w.done)
+			continue
+		}
+
+		var err error
+		if w.batch != nil {
+			err = c.back.SetMulti(w.batch)
+		} else if w.ttl > 0 {
+			err = c.back.SetWithExpiry(w.key, w.value, w.ttl)
+		} else {
+			err = c.back.Set(w.key, w.value)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"key":   string(w.key),
+			}).Error("Failed to persist record")
+		}
+	}
+}
+
+// queue - queues write for persistence, ErrCacheClosed is returned once the cache is closed
+func (c *TieredCache) queue(w tieredWrite) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.shut {
+		return ErrCacheClosed
+	}
+	c.writes <- w
+	return nil
+}
+
+// Flush - blocks until all writes queued so far are persisted, closed cache has persisted all of them already
+func (c *TieredCache) Flush() {
+	done := make(chan struct{})
+	if c.queue(tieredWrite{done: done}) != nil {
+		return
+	}
+	<-done
+}
+
+// CloseDB - persists pending writes and closes backing cache, records saved afterwards are rejected
+func (c *TieredCache) CloseDB() {
+	c.mu.Lock()
+	if c.shut {
+		c.mu.Unlock()
+		return
+	}
+	c.shut = true
+	close(c.writes)
+	c.mu.Unlock()
+
+	<-c.closed
+	c.back.CloseDB()
+}
+
+// Set - saves given key and value pair to memory and queues it for persistence
+func (c *TieredCache) Set(key, value []byte) error {
+	if err := c.queue(newTieredWrite(key, value, 0)); err != nil {
+		return err
+	}
+	c.front.Set(key, value)
+	c.counters.set(1)
+	return nil
+}
+
+// SetMulti - saves given key and value pairs to memory and queues them for persistence as one batch
+func (c *TieredCache) SetMulti(pairs []KeyValue) error {
+	batch := make([]KeyValue, len(pairs))
+	for i, kv := range pairs {
+		batch[i] = KeyValue{Key: append([]byte{}, kv.Key...), Value: append([]byte{}, kv.Value...)}
+	}
+	if err := c.queue(tieredWrite{batch: batch}); err != nil {
+		return err
+	}
+	for _, kv := range pairs {
+		c.front.Set(kv.Key, kv.Value)
+	}
+	c.counters.set(len(pairs))
+	return nil
+}
+
+// SetWithExpiry - saves given key and value pair to memory and queues it for persistence, record is
+// removed once ttl passes
+func (c *TieredCache) SetWithExpiry(key, value []byte, ttl time.Duration) error {
+	if err := c.queue(newTieredWrite(key, value, ttl)); err != nil {
+		return err
+	}
+	c.front.SetWithExpiry(key, value, ttl)
+	c.counters.set(1)
+	return nil
+}
+
+// Get - searches for given key in memory first and then in backing cache
+func (c *TieredCache) Get(key []byte) (value []byte, err error) {
+	defer func() { c.counters.get(err == nil) }()
+
+	value, err = c.front.Get(key)
+	if err == nil {
+		return
+	}
+
+	value, err = c.back.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("key %q not found \n", key)
+	}
+	// record is kept in memory only as long as it's valid in backing cache, records of caches that can't tell when
+	// they expire are always read from backing cache
+	expiring, ok := c.back.(expiringCache)
+	if !ok {
+		return
+	}
+	expiresAt, expires := expiring.ExpiresAt(key)
+	if !expires {
+		c.front.Set(key, value)
+	} else if ttl := expiresAt.Sub(time.Now()); ttl > 0 {
+		c.front.SetWithExpiry(key, value, ttl)
+	}
+	return
+}
+
+// GetAllRequests - returns all captured requests/responses
+func (c *TieredCache) GetAllRequests() ([]Payload, error) {
+	c.Flush()
+	return c.back.GetAllRequests()
+}
+
+// ForEachRequest - decodes captured requests/responses one by one and passes them to given function
+func (c *TieredCache) ForEachRequest(fn func(Payload) error) error {
+	c.Flush()
+	return c.back.ForEachRequest(fn)
+}
+
+// GetRequestsPage - returns captured requests/responses ordered by key, skipping offset records
+func (c *TieredCache) GetRequestsPage(offset, limit int) ([]Payload, error) {
+	c.Flush()
+	return c.back.GetRequestsPage(offset, limit)
+}
+
+// RecordsCount - returns records count
+func (c *TieredCache) RecordsCount() (int, error) {
+	c.Flush()
+	return c.back.RecordsCount()
+}
+
+// RecordsCountByDestination - returns records count for each destination host
+func (c *TieredCache) RecordsCountByDestination() (map[string]int, error) {
+	c.Flush()
+	return c.back.RecordsCountByDestination()
+}
+
+// DeleteData - deletes all saved data
+func (c *TieredCache) DeleteData() error {
+	c.Flush()
+	c.front.DeleteData()
+	return c.back.DeleteData()
+}
+
+// DeleteKey - deletes single record
+func (c *TieredCache) DeleteKey(key []byte) error {
+	c.Flush()
+	c.front.DeleteKey(key)
+	return c.back.DeleteKey(key)
+}
+
+// DeleteDataWhere - deletes records whose payloads match given filter, returns deleted records count
+func (c *TieredCache) DeleteDataWhere(filter PayloadFilter) (int, error) {
+	c.Flush()
+	c.front.DeleteDataWhere(filter)
+	return c.back.DeleteDataWhere(filter)
+}
+
+// GetAllKeys - gets all current keys
+func (c *TieredCache) GetAllKeys() (map[string]bool, error) {
+	c.Flush()
+	return c.back.GetAllKeys()
+}
+
+// GetKeysWithPrefix - returns sorted keys that start with given prefix
+func (c *TieredCache) GetKeysWithPrefix(prefix []byte) ([][]byte, error) {
+	c.Flush()
+	return c.back.GetKeysWithPrefix(prefix)
+}
+
+// Stats - returns cache usage statistics, hits include records loaded from backing cache
+func (c *TieredCache) Stats() CacheStats {
+	return c.counters.snapshot()
+}
+
+// Compact - persists pending writes and compacts backing cache storage
+func (c *TieredCache) Compact() (CompactionResult, error) {
+	compacter, ok := c.back.(Compacter)
+	if !ok {
+		return CompactionResult{}, fmt.Errorf("backing cache does not support compaction")
+	}
+	c.Flush()
+	return compacter.Compact()
+}
+
+// WriteBackup - persists pending writes and writes backing cache snapshot to given writer
+func (c *TieredCache) WriteBackup(w io.Writer) (int64, error) {
+	backuper, ok := c.back.(BackupWriter)
+	if !ok {
+		return 0, fmt.Errorf("backing cache does not support backups")
+	}
+	c.Flush()
+	return backuper.WriteBackup(w)
+}
@@ -0,0 +1,135 @@
+package hoverfly
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTieredCacheSetGet(t *testing.T) {
+	back := NewBoltDBCache(TestDB, GetRandomName(10))
+	cache := NewTieredCache(back, 0)
+	defer cache.DeleteData()
+
+	err := cache.Set([]byte("key"), []byte("value"))
+	expect(t, err, nil)
+
+	value, err := cache.Get([]byte("key"))
+	expect(t, err, nil)
+	expect(t, string(value), "value")
+
+	// record is persisted once pending writes are flushed
+	cache.Flush()
+	value, err = back.Get([]byte("key"))
+	expect(t, err, nil)
+	expect(t, string(value), "value")
+}
+
+func TestTieredCacheLoadsFromBackingCache(t *testing.T) {
+	back := NewBoltDBCache(TestDB, GetRandomName(10))
+	defer back.DeleteData()
+
+	back.Set([]byte("key"), []byte("value"))
+
+	cache := NewTieredCache(back, 0)
+
+	value, err := cache.Get([]byte("key"))
+	expect(t, err, nil)
+	expect(t, string(value), "value")
+
+	_, err = cache.Get([]byte("nothere"))
+	refute(t, err, nil)
+
+	stats := cache.Stats()
+	expect(t, stats.Hits, int64(1))
+	expect(t, stats.Misses, int64(1))
+}
+
+func TestTieredCacheLoadedRecordKeepsExpiry(t *testing.T) {
+	back := NewBoltDBCache(TestDB, GetRandomName(10))
+	defer back.DeleteData()
+
+	back.SetWithExpiry([]byte("expiring"), []byte("value"), 50*time.Millisecond)
+	back.Set([]byte("key"), []byte("value"))
+
+	cache := NewTieredCache(back, 0)
+
+	_, err := cache.Get([]byte("expiring"))
+	expect(t, err, nil)
+	_, err = cache.Get([]byte("key"))
+	expect(t, err, nil)
+
+	// record loaded into memory expires together with the one in backing cache
+	time.Sleep(100 * time.Millisecond)
+	_, err = cache.Get([]byte("expiring"))
+	refute(t, err, nil)
+	_, err = cache.Get([]byte("key"))
+	expect(t, err, nil)
+}
+
+func TestTieredCacheRecordsCountFlushes(t *testing.T) {
+	back := NewBoltDBCache(TestDB, GetRandomName(10))
+	cache := NewTieredCache(back, 0)
+	defer cache.DeleteData()
+
+	for _, key := range []string{"first", "second", "third"} {
+		cache.Set([]byte(key), []byte("value"))
+	}
+
+	count, err := cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 3)
+}
+
+func TestTieredCacheDeleteKey(t *testing.T) {
+	back := NewBoltDBCache(TestDB, GetRandomName(10))
+	cache := NewTieredCache(back, 0)
+	defer cache.DeleteData()
+
+	cache.Set([]byte("key"), []byte("value"))
+
+	err := cache.DeleteKey([]byte("key"))
+	expect(t, err, nil)
+
+	_, err = cache.Get([]byte("key"))
+	refute(t, err, nil)
+}
+
+func TestTieredCacheGetKeysWithPrefix(t *testing.T) {
+	back := NewBoltDBCache(TestDB, GetRandomName(10))
+	cache := NewTieredCache(back, 0)
+	defer cache.DeleteData()
+
+	for _, key := range []string{"api.example.com/b", "api.example.com/a", "other.com/a"} {
+		cache.Set([]byte(key), []byte("value"))
+	}
+
+	// pending writes are flushed before backing cache is scanned
+	keys, err := cache.GetKeysWithPrefix([]byte("api.example.com"))
+	expect(t, err, nil)
+	expect(t, len(keys), 2)
+	expect(t, string(keys[0]), "api.example.com/a")
+	expect(t, string(keys[1]), "api.example.com/b")
+}
+
+func TestTieredCacheRejectsWritesAfterClose(t *testing.T) {
+	cache := NewTieredCache(NewMemoryCache(), 0)
+
+	// captures still in flight when the cache is closed must not panic
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			cache.Set([]byte("key"), []byte("value"))
+		}
+	}()
+	cache.CloseDB()
+	<-done
+
+	expect(t, cache.Set([]byte("key"), []byte("value")), ErrCacheClosed)
+	expect(t, cache.SetWithExpiry([]byte("key"), []byte("value"), time.Minute), ErrCacheClosed)
+	expect(t, cache.SetMulti([]KeyValue{{Key: []byte("key"), Value: []byte("value")}}), ErrCacheClosed)
+
+	// closing again and flushing closed cache are no-ops
+	cache.CloseDB()
+	cache.Flush()
+}
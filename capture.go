@@ -0,0 +1,59 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// capturedBody - response body read during capture, it's only the beginning of the body when it was truncated
+type capturedBody struct {
+	data      []byte
+	events    []ServerSentEvent
+	truncated bool
+}
+
+// streamingBody - body whose beginning was already read, the rest is read from the destination as client
+// consumes it
+type streamingBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+// Close - closes connection to the destination
+func (b *streamingBody) Close() error {
+	return b.closer.Close()
+}
+
+// extractCapturedBody - reads response body to be captured, at most maxSize bytes are kept (0 means no limit).
+// Bigger bodies are truncated and the response keeps streaming the rest of the body, so it's never held in memory
+// as a whole
+func extractCapturedBody(resp *http.Response, maxSize int64) (capturedBody, error) {
+	if isEventStream(resp.Header) {
+		return extractEventStream(resp, maxSize)
+	}
+
+	if maxSize <= 0 {
+		data, err := extractBody(resp)
+		return capturedBody{data: data}, err
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		resp.Body.Close()
+		return capturedBody{}, err
+	}
+
+	if int64(len(data)) <= maxSize {
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+		return capturedBody{data: data}, nil
+	}
+
+	resp.Body = &streamingBody{
+		Reader: io.MultiReader(bytes.NewReader(data), resp.Body),
+		closer: resp.Body,
+	}
+	return capturedBody{data: data[:maxSize], truncated: true}, nil
+}
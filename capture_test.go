@@ -0,0 +1,98 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractCapturedBodyUnderLimit(t *testing.T) {
+	resp := &http.Response{Header: make(http.Header), Body: ioutil.NopCloser(strings.NewReader("small body"))}
+
+	captured, err := extractCapturedBody(resp, 100)
+	expect(t, err, nil)
+	expect(t, captured.truncated, false)
+	expect(t, string(captured.data), "small body")
+
+	body, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	expect(t, string(body), "small body")
+}
+
+func TestExtractCapturedBodyTruncated(t *testing.T) {
+	original := bytes.Repeat([]byte("0123456789"), 100)
+	resp := &http.Response{Header: make(http.Header), Body: ioutil.NopCloser(bytes.NewReader(original))}
+
+	captured, err := extractCapturedBody(resp, 25)
+	expect(t, err, nil)
+	expect(t, captured.truncated, true)
+	expect(t, string(captured.data), "0123456789012345678901234")
+
+	// client still gets the whole body
+	body, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	expect(t, bytes.Equal(body, original), true)
+	expect(t, resp.Body.Close(), nil)
+}
+
+func TestExtractEventStreamTruncated(t *testing.T) {
+	upstream := eventStreamServer(3, 10*time.Millisecond)
+	defer upstream.Close()
+
+	resp, err := http.Get(upstream.URL)
+	expect(t, err, nil)
+
+	captured, err := extractEventStream(resp, 21)
+	expect(t, err, nil)
+	expect(t, captured.truncated, true)
+	expect(t, len(captured.events), 1)
+	expect(t, captured.events[0].Data, "id: 0\ndata: event 0\n\n")
+
+	body, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	expect(t, string(body), "id: 0\ndata: event 0\n\nid: 1\ndata: event 1\n\nid: 2\ndata: event 2\n\n")
+}
+
+func TestCaptureTruncatesBigResponses(t *testing.T) {
+	original := bytes.Repeat([]byte("x"), 64*1024)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(original)
+	}))
+	defer upstream.Close()
+
+	cache := NewMemoryCache()
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	cfg.MaxCaptureSize = 1024
+	_, dbClient := GetNewHoverfly(cfg, cache)
+
+	req, err := http.NewRequest("GET", upstream.URL+"/download", nil)
+	expect(t, err, nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusOK)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	resp.Body.Close()
+	expect(t, len(body), len(original))
+
+	payloads, err := cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Response.Truncated, true)
+	expect(t, len(payloads[0].Response.Body), 1024)
+	_, ok := payloads[0].Response.Headers["Content-Length"]
+	expect(t, ok, false)
+}
+
+func TestSettingsMaxCaptureSizeEnv(t *testing.T) {
+	defer os.Setenv("HoverflyMaxCaptureSize", "")
+	os.Setenv("HoverflyMaxCaptureSize", "1048576")
+
+	expect(t, InitSettings().MaxCaptureSize, int64(1048576))
+}
@@ -0,0 +1,65 @@
+package hoverfly
+
+import (
+	"strings"
+)
+
+// lowerHeaderValues - returns copy of headers with lower case values
+func lowerHeaderValues(headers map[string][]string) map[string][]string {
+	lowered := make(map[string][]string, len(headers))
+	for name, values := range headers {
+		for _, value := range values {
+			lowered[name] = append(lowered[name], strings.ToLower(value))
+		}
+	}
+	return lowered
+}
+
+// foldCase - returns matcher that ignores case, compiled when the matcher is
+func (m FieldMatcher) foldCase() FieldMatcher {
+	folded := FieldMatcher{Regex: "(?i)" + m.Regex, re: m.foldedRe, foldedRe: m.foldedRe}
+	if m.Glob != "" {
+		folded.Regex = "(?i)" + globToRegex(m.Glob)
+	}
+	return folded
+}
+
+// foldCase - returns copy of matchers where path or header patterns ignore case
+func (m *RequestMatchers) foldCase(paths, headers bool) *RequestMatchers {
+	folded := *m
+	if paths && m.Path != nil {
+		path := m.Path.foldCase()
+		folded.Path = &path
+	}
+	if headers && len(m.Headers) > 0 {
+		folded.Headers = make(map[string]FieldMatcher, len(m.Headers))
+		for name, matcher := range m.Headers {
+			folded.Headers[name] = matcher.foldCase()
+		}
+	}
+	return &folded
+}
+
+// samePath - compares recorded and live path, case is ignored when configured
+func (d *DBClient) samePath(recorded, live string) bool {
+	if d.Cfg.CaseInsensitivePaths {
+		return strings.EqualFold(recorded, live)
+	}
+	return recorded == live
+}
+
+// sameHeaderValue - compares recorded and live header values, case is ignored when configured
+func (d *DBClient) sameHeaderValue(recorded, live string) bool {
+	if d.Cfg.CaseInsensitiveHeaders {
+		return strings.EqualFold(recorded, live)
+	}
+	return recorded == live
+}
+
+// requestMatchers - returns matchers of record as they are applied with current configuration
+func (d *DBClient) requestMatchers(pl Payload) *RequestMatchers {
+	if pl.Matchers == nil || (!d.Cfg.CaseInsensitivePaths && !d.Cfg.CaseInsensitiveHeaders) {
+		return pl.Matchers
+	}
+	return pl.Matchers.foldCase(d.Cfg.CaseInsensitivePaths, d.Cfg.CaseInsensitiveHeaders)
+}
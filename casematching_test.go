@@ -0,0 +1,98 @@
+package hoverfly
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.CaseInsensitivePaths = true
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/Users/Alice", "", 200, "alice")
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users/ALICE", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 200)
+
+	// destination and query are still case sensitive
+	req, _ = http.NewRequest("GET", "http://api.example.com/users/alice?Page=1", nil)
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+}
+
+func TestCaseSensitivePathsByDefault(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/Users", "", 200, "users")
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+}
+
+func TestCaseInsensitiveHeaderValues(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.CaseInsensitiveHeaders = true
+	expect(t, dbClient.HeaderMatches.Set([]HeaderMatch{{Destination: "api.example.com", Headers: []string{"Accept"}}}), nil)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	req.Header.Set("Accept", "Application/JSON")
+	dbClient.save(req, []byte(""), &http.Response{StatusCode: 200, Header: http.Header{}}, []byte("users"))
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ = http.NewRequest("GET", "http://api.example.com/users", nil)
+	req.Header.Set("accept", "application/json")
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 200)
+
+	req, _ = http.NewRequest("GET", "http://api.example.com/users", nil)
+	req.Header.Set("Accept", "text/html")
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+}
+
+func TestCaseInsensitiveRequestMatchers(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.ImportPayloads([]Payload{
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com"},
+			Response: ResponseDetails{Status: 200, Body: "orders"},
+			Matchers: &RequestMatchers{Path: &FieldMatcher{Glob: "/users/*/orders"}},
+		},
+	})
+	expect(t, err, nil)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/Users/42/Orders", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+
+	dbClient.Cfg.CaseInsensitivePaths = true
+	req, _ = http.NewRequest("GET", "http://api.example.com/Users/42/Orders", nil)
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 200)
+}
+
+func TestSettingsCaseInsensitiveEnv(t *testing.T) {
+	defer os.Setenv("HoverflyCaseInsensitivePaths", "")
+	defer os.Setenv("HoverflyCaseInsensitiveHeaders", "")
+
+	os.Setenv("HoverflyCaseInsensitivePaths", "true")
+	os.Setenv("HoverflyCaseInsensitiveHeaders", "true")
+	cfg := InitSettings()
+	expect(t, cfg.CaseInsensitivePaths, true)
+	expect(t, cfg.CaseInsensitiveHeaders, true)
+}
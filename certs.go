@@ -0,0 +1,248 @@
+package hoverfly
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/elazarl/goproxy"
+)
+
+// DefaultCAValidity - how long generated certificate authority is valid
+const DefaultCAValidity = 365 * 24 * time.Hour
+
+// leafValidity - how long certificates minted for intercepted hosts are valid, never longer than CA itself
+const leafValidity = 30 * 24 * time.Hour
+
+// rsaKeySize - size of generated CA and leaf keys
+const rsaKeySize = 2048
+
+// errUnsupportedKey - returned when certificate authority with non RSA key is saved
+var errUnsupportedKey = errors.New("only certificate authorities with RSA keys can be saved")
+
+// GenerateCA - creates new self signed certificate authority used to intercept HTTPS traffic
+func GenerateCA(commonName string, validity time.Duration) (tls.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"Hoverfly"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return certificateFromDER(der, key)
+}
+
+// LoadCA - loads PEM encoded certificate authority and its private key from given files
+func LoadCA(certFile, keyFile string) (tls.Certificate, error) {
+	ca, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return ca, err
+	}
+	ca.Leaf, err = x509.ParseCertificate(ca.Certificate[0])
+	return ca, err
+}
+
+// SaveCA - writes PEM encoded certificate authority and its private key to given files, private key is
+// readable by the owner only
+func SaveCA(ca tls.Certificate, certFile, keyFile string) error {
+	key, ok := ca.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return errUnsupportedKey
+	}
+
+	cf, err := os.Create(certFile)
+	if err != nil {
+		return err
+	}
+	defer cf.Close()
+	if err := WriteCertificate(cf, ca); err != nil {
+		return err
+	}
+
+	kf, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer kf.Close()
+	return pem.Encode(kf, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// WriteCertificate - writes PEM encoded certificate, clients have to trust it to accept intercepted connections
+func WriteCertificate(w io.Writer, cert tls.Certificate) error {
+	return pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+}
+
+// CertificateAuthority - mints certificates for intercepted hosts, certificates are cached so every host
+// is signed only once
+type CertificateAuthority struct {
+	CA tls.Certificate
+
+	mu     sync.Mutex
+	leaves map[string]*tls.Certificate
+}
+
+// NewCertificateAuthority - returns CertificateAuthority that signs certificates with given CA
+func NewCertificateAuthority(ca tls.Certificate) *CertificateAuthority {
+	return &CertificateAuthority{
+		CA:     ca,
+		leaves: make(map[string]*tls.Certificate),
+	}
+}
+
+// Certificate - returns certificate for given host, port is ignored
+func (c *CertificateAuthority) Certificate(host string) (*tls.Certificate, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if leaf, ok := c.leaves[host]; ok && time.Now().Before(leaf.Leaf.NotAfter) {
+		return leaf, nil
+	}
+
+	leaf, err := c.sign(host)
+	if err != nil {
+		return nil, err
+	}
+	c.leaves[host] = leaf
+
+	log.WithFields(log.Fields{
+		"host": host,
+	}).Debug("Certificate minted for intercepted host")
+
+	return leaf, nil
+}
+
+// sign - creates certificate for given host signed by CA
+func (c *CertificateAuthority) sign(host string) (*tls.Certificate, error) {
+	ca, err := c.caCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	notAfter := now.Add(leafValidity)
+	if notAfter.After(ca.NotAfter) {
+		notAfter = ca.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host, Organization: []string{"Hoverfly"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, c.CA.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := certificateFromDER(der, key)
+	if err != nil {
+		return nil, err
+	}
+	leaf.Certificate = append(leaf.Certificate, c.CA.Certificate[0])
+	return &leaf, nil
+}
+
+// caCertificate - returns parsed CA certificate
+func (c *CertificateAuthority) caCertificate() (*x509.Certificate, error) {
+	if c.CA.Leaf != nil {
+		return c.CA.Leaf, nil
+	}
+	return x509.ParseCertificate(c.CA.Certificate[0])
+}
+
+// TLSConfig - returns TLS configuration presented to clients connecting to given host
+func (c *CertificateAuthority) TLSConfig(host string, ctx *goproxy.ProxyCtx) (*tls.Config, error) {
+	leaf, err := c.Certificate(host)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"host":  host,
+			"error": err.Error(),
+		}).Error("Failed to sign certificate for intercepted host")
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{*leaf}}, nil
+}
+
+// SetCA - makes proxy intercept HTTPS traffic with given certificate authority instead of the one
+// bundled with goproxy
+func SetCA(ca tls.Certificate) {
+	authority := NewCertificateAuthority(ca)
+
+	goproxy.GoproxyCa = ca
+	goproxy.OkConnect = &goproxy.ConnectAction{Action: goproxy.ConnectAccept, TLSConfig: authority.TLSConfig}
+	goproxy.MitmConnect = &goproxy.ConnectAction{Action: goproxy.ConnectMitm, TLSConfig: authority.TLSConfig}
+	goproxy.HTTPMitmConnect = &goproxy.ConnectAction{Action: goproxy.ConnectHTTPMitm, TLSConfig: authority.TLSConfig}
+	goproxy.RejectConnect = &goproxy.ConnectAction{Action: goproxy.ConnectReject, TLSConfig: authority.TLSConfig}
+}
+
+// certificateFromDER - returns TLS certificate with parsed leaf
+func certificateFromDER(der []byte, key *rsa.PrivateKey) (tls.Certificate, error) {
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+// randomSerial - returns random 128 bit certificate serial number
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
@@ -0,0 +1,134 @@
+package hoverfly
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elazarl/goproxy"
+)
+
+// restoreGoproxyCA - returns function that brings back goproxy's certificate authority changed by SetCA
+func restoreGoproxyCA() func() {
+	ca := goproxy.GoproxyCa
+	ok, mitm, httpMitm, reject := goproxy.OkConnect, goproxy.MitmConnect, goproxy.HTTPMitmConnect, goproxy.RejectConnect
+	return func() {
+		goproxy.GoproxyCa = ca
+		goproxy.OkConnect, goproxy.MitmConnect, goproxy.HTTPMitmConnect, goproxy.RejectConnect = ok, mitm, httpMitm, reject
+	}
+}
+
+func TestCertificateSignedByCA(t *testing.T) {
+	ca, err := GenerateCA("Test Authority", time.Hour)
+	expect(t, err, nil)
+	expect(t, ca.Leaf.IsCA, true)
+
+	authority := NewCertificateAuthority(ca)
+	leaf, err := authority.Certificate("example.com:443")
+	expect(t, err, nil)
+	expect(t, leaf.Leaf.Subject.CommonName, "example.com")
+
+	// leaf can't outlive its CA
+	expect(t, leaf.Leaf.NotAfter.After(ca.Leaf.NotAfter), false)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	_, err = leaf.Leaf.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots})
+	expect(t, err, nil)
+
+	// certificates are minted once per host
+	again, err := authority.Certificate("EXAMPLE.com")
+	expect(t, err, nil)
+	expect(t, again, leaf)
+}
+
+func TestCertificateForIP(t *testing.T) {
+	ca, err := GenerateCA("Test Authority", time.Hour)
+	expect(t, err, nil)
+
+	leaf, err := NewCertificateAuthority(ca).Certificate("127.0.0.1:8443")
+	expect(t, err, nil)
+	expect(t, len(leaf.Leaf.IPAddresses), 1)
+	expect(t, leaf.Leaf.IPAddresses[0].String(), "127.0.0.1")
+}
+
+func TestSaveAndLoadCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly-ca")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	ca, err := GenerateCA("Test Authority", time.Hour)
+	expect(t, err, nil)
+	expect(t, SaveCA(ca, certFile, keyFile), nil)
+
+	info, err := os.Stat(keyFile)
+	expect(t, err, nil)
+	expect(t, info.Mode().Perm(), os.FileMode(0600))
+
+	loaded, err := LoadCA(certFile, keyFile)
+	expect(t, err, nil)
+	expect(t, loaded.Leaf.Equal(ca.Leaf), true)
+}
+
+func TestLoadMissingCA(t *testing.T) {
+	_, err := LoadCA("/this/cert/is/not/there.pem", "/this/key/is/not/there.pem")
+	refute(t, err, nil)
+}
+
+func TestCaptureHTTPSWithGeneratedCA(t *testing.T) {
+	defer restoreGoproxyCA()()
+
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+		w.Write([]byte("secret"))
+	}))
+	defer upstream.Close()
+
+	ca, err := GenerateCA("Test Authority", time.Hour)
+	expect(t, err, nil)
+	SetCA(ca)
+
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	// test server uses self signed certificate
+	cfg.UpstreamInsecure = true
+	proxy, dbClient := GetNewHoverfly(cfg, NewMemoryCache())
+
+	proxyServer := httptest.NewServer(proxy)
+	defer proxyServer.Close()
+
+	proxyURL, err := url.Parse(proxyServer.URL)
+	expect(t, err, nil)
+
+	// client trusts only generated CA, so the connection has to be intercepted by Hoverfly
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+	}}
+
+	resp, err := client.Get(upstream.URL + "/path")
+	expect(t, err, nil)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	expect(t, err, nil)
+	expect(t, resp.StatusCode, 201)
+	expect(t, string(body), "secret")
+
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Path, "/path")
+	expect(t, payloads[0].Response.Body, "secret")
+}
@@ -0,0 +1,371 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/elazarl/goproxy"
+)
+
+// maxBodyMismatches - body fields listed in miss diagnostics, the rest are only counted
+const maxBodyMismatches = 10
+
+// maxMismatchValue - length of values shown in miss diagnostics
+const maxMismatchValue = 100
+
+// matchMismatch - request field that differs from the closest record
+type matchMismatch struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	// weight - how much the field counts when records are compared, destination and method count the most
+	weight int
+}
+
+// truncateValue - shortens long values shown in diagnostics
+func truncateValue(value string) string {
+	if len(value) > maxMismatchValue {
+		return value[:maxMismatchValue] + "..."
+	}
+	return value
+}
+
+// compareQuery - returns parameters that differ, query without ignored parameters is compared parameter by
+// parameter so diagnostics point at the one that's wrong
+func compareQuery(expected, actual string) []matchMismatch {
+	if expected == actual {
+		return nil
+	}
+	expectedParams, err1 := url.ParseQuery(expected)
+	actualParams, err2 := url.ParseQuery(actual)
+	if err1 != nil || err2 != nil {
+		return []matchMismatch{{Field: "query", Expected: expected, Actual: actual, weight: 1}}
+	}
+
+	names := make(map[string]bool)
+	for name := range expectedParams {
+		names[name] = true
+	}
+	for name := range actualParams {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var mismatches []matchMismatch
+	for _, name := range sorted {
+		e, a := strings.Join(expectedParams[name], ","), strings.Join(actualParams[name], ",")
+		if !reflect.DeepEqual(expectedParams[name], actualParams[name]) {
+			mismatches = append(mismatches, matchMismatch{Field: "query parameter " + name, Expected: e, Actual: a, weight: 1})
+		}
+	}
+	if len(mismatches) == 0 {
+		// same parameters in different order
+		mismatches = append(mismatches, matchMismatch{Field: "query", Expected: expected, Actual: actual, weight: 1})
+	}
+	return mismatches
+}
+
+// compareJSONValues - appends fields of JSON documents that differ, path is dot separated
+func compareJSONValues(path string, expected, actual interface{}, mismatches []matchMismatch) []matchMismatch {
+	expectedObject, ok1 := expected.(map[string]interface{})
+	actualObject, ok2 := actual.(map[string]interface{})
+	if ok1 && ok2 {
+		keys := make(map[string]bool)
+		for key := range expectedObject {
+			keys[key] = true
+		}
+		for key := range actualObject {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			mismatches = compareJSONValues(path+"."+key, expectedObject[key], actualObject[key], mismatches)
+		}
+		return mismatches
+	}
+
+	expectedArray, ok1 := expected.([]interface{})
+	actualArray, ok2 := actual.([]interface{})
+	if ok1 && ok2 && len(expectedArray) == len(actualArray) {
+		for i := range expectedArray {
+			mismatches = compareJSONValues(fmt.Sprintf("%s.%d", path, i), expectedArray[i], actualArray[i], mismatches)
+		}
+		return mismatches
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		mismatches = append(mismatches, matchMismatch{Field: path, Expected: truncateValue(jsonText(expected)),
+			Actual: truncateValue(jsonText(actual)), weight: 1})
+	}
+	return mismatches
+}
+
+// jsonText - returns JSON of value, missing values are empty
+func jsonText(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	text, _ := json.Marshal(value)
+	return string(text)
+}
+
+// compareBody - returns body mismatches, JSON bodies are compared field by field
+func compareBody(expected, actual string) []matchMismatch {
+	if expected == actual {
+		return nil
+	}
+
+	var expectedJSON, actualJSON interface{}
+	if json.Unmarshal([]byte(expected), &expectedJSON) == nil && json.Unmarshal([]byte(actual), &actualJSON) == nil {
+		mismatches := compareJSONValues("body", expectedJSON, actualJSON, nil)
+		if len(mismatches) > maxBodyMismatches {
+			more := len(mismatches) - maxBodyMismatches
+			mismatches = append(mismatches[:maxBodyMismatches], matchMismatch{Field: "body",
+				Expected: fmt.Sprintf("%d more fields", more), Actual: "differ"})
+		}
+		if len(mismatches) > 0 {
+			// a body counts as one field, however many of its fields differ
+			mismatches[0].weight = 1
+			for i := 1; i < len(mismatches); i++ {
+				mismatches[i].weight = 0
+			}
+			return mismatches
+		}
+	}
+	return []matchMismatch{{Field: "body", Expected: truncateValue(expected), Actual: truncateValue(actual), weight: 1}}
+}
+
+// requestMismatches - returns fields of live request that don't match the record, destination isn't compared when
+// any destination is fine (i.e. webserver requests)
+func (d *DBClient) requestMismatches(pl Payload, live RequestDetails, anyDestination bool) []matchMismatch {
+	var mismatches []matchMismatch
+	recorded := pl.Request
+
+	if !anyDestination && recorded.Destination != live.Destination {
+		mismatches = append(mismatches, matchMismatch{Field: "destination", Expected: recorded.Destination,
+			Actual: live.Destination, weight: 4})
+	}
+	if recorded.Method != live.Method {
+		mismatches = append(mismatches, matchMismatch{Field: "method", Expected: recorded.Method, Actual: live.Method, weight: 2})
+	}
+
+	matchers := d.requestMatchers(pl)
+	if matchers == nil {
+		matchers = &RequestMatchers{}
+	}
+
+	if matchers.Path != nil {
+		if !matchers.Path.matchesAny([]string{live.Path}) {
+			mismatches = append(mismatches, matchMismatch{Field: "path", Expected: matchers.Path.Glob + matchers.Path.Regex,
+				Actual: live.Path, weight: 1})
+		}
+	} else if !d.samePath(recorded.Path, live.Path) {
+		mismatches = append(mismatches, matchMismatch{Field: "path", Expected: recorded.Path, Actual: live.Path, weight: 1})
+	}
+
+	if matchers.RawQuery != nil && !matchers.RawQuery.matchesAny([]string{live.Query}) {
+		mismatches = append(mismatches, matchMismatch{Field: "query", Expected: matchers.RawQuery.Glob +
+			matchers.RawQuery.Regex, Actual: live.Query, weight: 1})
+	}
+	if matchers.replacesQuery() {
+		query, _ := url.ParseQuery(live.Query)
+		for name, matcher := range matchers.Query {
+			if !matcher.matchesAny(query[name]) {
+				mismatches = append(mismatches, matchMismatch{Field: "query parameter " + name,
+					Expected: matcher.Glob + matcher.Regex, Actual: strings.Join(query[name], ","), weight: 1})
+			}
+		}
+	} else {
+		mismatches = append(mismatches, compareQuery(stripQueryParams(recorded.Query, d.Cfg.IgnoreQueryParams),
+			stripQueryParams(live.Query, d.Cfg.IgnoreQueryParams))...)
+	}
+
+	for _, name := range d.matchHeaders(recorded.Destination) {
+		e, a := strings.Join(headerValues(recorded.Headers, name), ","), strings.Join(headerValues(live.Headers, name), ",")
+		if !d.sameHeaderValue(e, a) {
+			mismatches = append(mismatches, matchMismatch{Field: "header " + name, Expected: e, Actual: a, weight: 1})
+		}
+	}
+	for name, matcher := range matchers.Headers {
+		if values := headerValues(live.Headers, name); !matcher.matchesAny(values) {
+			mismatches = append(mismatches, matchMismatch{Field: "header " + http.CanonicalHeaderKey(name),
+				Expected: matcher.Glob + matcher.Regex, Actual: strings.Join(values, ","), weight: 1})
+		}
+	}
+
+	for _, name := range d.failedCustomMatchers(pl, live) {
+		mismatches = append(mismatches, matchMismatch{Field: "custom matcher " + name,
+			Expected: truncateValue(string(pl.CustomMatchers[name])), Actual: "not matched", weight: 1})
+	}
+
+	switch mode := d.bodyMatching(recorded).Mode; {
+	case pl.hasBodyMatchers():
+		// every expression is checked on its own, so diagnostics list only the unsatisfied ones
+		for _, expression := range pl.JSONPathMatchers {
+			if single := (Payload{JSONPathMatchers: []string{expression}}); !single.matchesBody([]byte(live.Body)) {
+				mismatches = append(mismatches, matchMismatch{Field: "body", Expected: expression,
+					Actual: truncateValue(live.Body), weight: 1})
+			}
+		}
+		for _, expression := range pl.XPathMatchers {
+			if single := (Payload{XPathMatchers: []string{expression}}); !single.matchesBody([]byte(live.Body)) {
+				mismatches = append(mismatches, matchMismatch{Field: "body", Expected: expression,
+					Actual: truncateValue(live.Body), weight: 1})
+			}
+		}
+	case matchers.replacesBody():
+		if !matchers.Body.matchesAny([]string{live.Body}) {
+			mismatches = append(mismatches, matchMismatch{Field: "body", Expected: matchers.Body.Glob + matchers.Body.Regex,
+				Actual: truncateValue(live.Body), weight: 1})
+		}
+	case len(pl.CustomMatchers) > 0:
+		// body is left to custom matchers
+	case mode == BodyMatchForm || mode == BodyMatchMultipart:
+		mismatches = append(mismatches, compareForm(d.matchedBody(recorded), d.matchedBody(live))...)
+	default:
+		mismatches = append(mismatches, compareBody(d.matchedBody(recorded), d.matchedBody(live))...)
+	}
+	return mismatches
+}
+
+// mismatchScore - the lower the score, the closer the record
+func mismatchScore(mismatches []matchMismatch) int {
+	score := 0
+	for _, m := range mismatches {
+		score += m.weight
+	}
+	return score
+}
+
+// scoredRecord - record compared to live request
+type scoredRecord struct {
+	payload    Payload
+	mismatches []matchMismatch
+	score      int
+}
+
+// closerThan - checks whether record is closer to the request than other one, ties are broken by record ID so
+// results are stable
+func (r scoredRecord) closerThan(other scoredRecord) bool {
+	if r.score != other.score {
+		return r.score < other.score
+	}
+	return r.payload.ID < other.payload.ID
+}
+
+// closestRecords - returns at most n records most similar to live request, the closest first. Only the n closest
+// records seen so far are kept while the cache is scanned
+func (d *DBClient) closestRecords(live RequestDetails, anyDestination bool, n int) []scoredRecord {
+	if n <= 0 {
+		return nil
+	}
+	var records []scoredRecord
+	err := d.Cache.ForEachRequest(func(pl Payload) error {
+		mismatches := d.requestMismatches(pl, live, anyDestination)
+		record := scoredRecord{payload: pl, mismatches: mismatches, score: mismatchScore(mismatches)}
+		if len(records) == n && !record.closerThan(records[n-1]) {
+			return nil
+		}
+		// insertion into sorted slice, the farthest record drops out once there are more than n
+		i := sort.Search(len(records), func(i int) bool {
+			return record.closerThan(records[i])
+		})
+		records = append(records, scoredRecord{})
+		copy(records[i+1:], records[i:])
+		records[i] = record
+		if len(records) > n {
+			records = records[:n]
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to look for closest record")
+		return nil
+	}
+	return records
+}
+
+// closestMatch - returns record most similar to live request and fields that differ, false when there are no records
+func (d *DBClient) closestMatch(live RequestDetails, anyDestination bool) (Payload, []matchMismatch, bool) {
+	records := d.closestRecords(live, anyDestination, 1)
+	if len(records) == 0 {
+		return Payload{}, nil, false
+	}
+	return records[0].payload, records[0].mismatches, true
+}
+
+// redactExpected - hides recorded values (i.e. Authorization token or password form field) from mismatches shown to
+// proxy clients, only the field name is kept. Full detail is available on the admin match debug endpoint
+func redactExpected(mismatches []matchMismatch) []matchMismatch {
+	redacted := make([]matchMismatch, len(mismatches))
+	for i, m := range mismatches {
+		m.Expected = "[redacted]"
+		redacted[i] = m
+	}
+	return redacted
+}
+
+// missResponse - builds response to request that wasn't recorded, with -miss-debug it explains how the request
+// differs from the closest record
+func (d *DBClient) missResponse(req *http.Request, body []byte, err error, anyDestination bool) *http.Response {
+	markJournalMatch(req, false, "")
+
+	live := RequestDetails{
+		Path:        req.URL.Path,
+		Method:      req.Method,
+		Destination: req.Host,
+		Query:       req.URL.RawQuery,
+		Body:        string(body),
+		Headers:     req.Header,
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "Hoverfly Error! Could not find recorded request, please record it first!. Got error: %s \n",
+		err.Error())
+	fields := log.Fields{
+		"error":       err.Error(),
+		"query":       req.URL.RawQuery,
+		"path":        req.URL.Path,
+		"destination": req.Host,
+		"method":      req.Method,
+	}
+
+	// closest record is looked for only on demand, it's a scan of all records
+	if d.Cfg.MissDebug {
+		if closest, mismatches, ok := d.closestMatch(live, anyDestination); ok {
+			mismatches = redactExpected(mismatches)
+			fields["closestMatch"] = closest.ID
+			fields["mismatches"] = mismatches
+
+			// recorded query can hold secrets too, it's left out like other recorded values
+			fmt.Fprintf(&message, "Closest recorded request: %s %s%s\n", closest.Request.Method,
+				closest.Request.Destination, closest.Request.Path)
+			for _, m := range mismatches {
+				fmt.Fprintf(&message, "  %s: expected %q, got %q\n", m.Field, m.Expected, m.Actual)
+			}
+		}
+	}
+	log.WithFields(fields).Warn("Failed to retrieve response from cache")
+
+	resp := goproxy.NewResponse(req, goproxy.ContentTypeText, d.Cfg.GetMissStatus(), message.String())
+	// browser clients can see why the request failed
+	d.addCORSHeaders(req, resp)
+	return resp
+}
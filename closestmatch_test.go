@@ -0,0 +1,143 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareBodyJSONFields(t *testing.T) {
+	mismatches := compareBody(`{"order": {"type": "express", "id": 1}, "items": [1, 2]}`,
+		`{"order": {"type": "standard", "id": 1}, "items": [1, 3], "coupon": "X"}`)
+	expect(t, len(mismatches), 3)
+	expect(t, mismatches[0].Field, "body.coupon")
+	expect(t, mismatches[0].Expected, "")
+	expect(t, mismatches[0].Actual, `"X"`)
+	expect(t, mismatches[1].Field, "body.items.1")
+	expect(t, mismatches[2].Field, "body.order.type")
+	expect(t, mismatchScore(mismatches), 1)
+
+	mismatches = compareBody("a=1", "a=2")
+	expect(t, len(mismatches), 1)
+	expect(t, mismatches[0].Field, "body")
+}
+
+func TestCompareQueryParameters(t *testing.T) {
+	mismatches := compareQuery("page=1&sort=name", "page=2&sort=name")
+	expect(t, len(mismatches), 1)
+	expect(t, mismatches[0].Field, "query parameter page")
+	expect(t, mismatches[0].Expected, "1")
+	expect(t, mismatches[0].Actual, "2")
+
+	mismatches = compareQuery("a=1&b=2", "b=2&a=1")
+	expect(t, len(mismatches), 1)
+	expect(t, mismatches[0].Field, "query")
+}
+
+func TestMissResponseExplainsClosestMatch(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "POST", "http://api.example.com/orders?page=1", `{"type": "express"}`, 201, "created")
+	storeTestPayload(dbClient, "GET", "http://billing.example.com/invoices", "", 200, "invoices")
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	dbClient.Cfg.MissDebug = true
+
+	req, _ := http.NewRequest("POST", "http://api.example.com/orders?page=2", strings.NewReader(`{"type": "standard"}`))
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+	expect(t, strings.Contains(string(body), "Closest recorded request: POST api.example.com/orders\n"), true)
+	expect(t, strings.Contains(string(body), `query parameter page: expected "[redacted]", got "2"`), true)
+	expect(t, strings.Contains(string(body), `body.type: expected "[redacted]", got "\"standard\""`), true)
+	expect(t, strings.Contains(string(body), "billing"), false)
+
+	// explanation is opt-in
+	dbClient.Cfg.MissDebug = false
+	req, _ = http.NewRequest("POST", "http://api.example.com/orders?page=2", strings.NewReader(`{"type": "standard"}`))
+	_, resp = dbClient.processRequest(req)
+	body, _ = ioutil.ReadAll(resp.Body)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+	expect(t, strings.Contains(string(body), "Could not find recorded request"), true)
+	expect(t, strings.Contains(string(body), "Closest"), false)
+}
+
+func TestMissResponseRedactsRecordedHeaders(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	expect(t, dbClient.HeaderMatches.Set([]HeaderMatch{{Destination: `api\.example\.com`, Headers: []string{"Authorization"}}}), nil)
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	req.Header.Set("Authorization", "Bearer recorded-secret")
+	dbClient.save(req, []byte(""), &http.Response{StatusCode: 200, Header: make(http.Header)}, []byte("users"))
+
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	dbClient.Cfg.MissDebug = true
+	req, _ = http.NewRequest("GET", "http://api.example.com/users", nil)
+	req.Header.Set("Authorization", "Bearer other")
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+	expect(t, strings.Contains(string(body), `header Authorization: expected "[redacted]", got "Bearer other"`), true)
+	expect(t, strings.Contains(string(body), "recorded-secret"), false)
+}
+
+func TestMissResponseWithoutRecords(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+	expect(t, strings.Contains(string(body), "Could not find recorded request"), true)
+	expect(t, strings.Contains(string(body), "Closest"), false)
+}
+
+func TestWebserverMissIgnoresDestination(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+	dbClient.Cfg.MissDebug = true
+
+	webserver := httptest.NewServer(dbClient.WebserverHandler())
+	defer webserver.Close()
+
+	resp, err := http.Post(webserver.URL+"/users", "text/plain", nil)
+	expect(t, err, nil)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	expect(t, resp.StatusCode, DefaultMissStatus)
+	expect(t, strings.Contains(string(body), `method: expected "[redacted]", got "POST"`), true)
+	expect(t, strings.Contains(string(body), "destination:"), false)
+}
+
+func TestClosestRecordsKeepsOnlyClosest(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://billing.example.com/invoices", "", 200, "invoices")
+	storeTestPayload(dbClient, "POST", "http://api.example.com/users", "", 201, "created")
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users?page=1", "", 200, "page")
+	storeTestPayload(dbClient, "GET", "http://api.example.com/orders", "", 200, "orders")
+	storeTestPayload(dbClient, "DELETE", "http://billing.example.com/invoices", "", 204, "")
+
+	live := RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/users"}
+	records := dbClient.closestRecords(live, false, 2)
+	expect(t, len(records), 2)
+	// query and path differ by one field each, method counts more
+	expect(t, records[0].score, 1)
+	expect(t, records[1].score, 1)
+	expect(t, records[0].payload.ID < records[1].payload.ID, true)
+
+	expect(t, len(dbClient.closestRecords(live, false, 0)), 0)
+	expect(t, len(dbClient.closestRecords(live, false, 10)), 5)
+}
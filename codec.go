@@ -0,0 +1,114 @@
+package hoverfly
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Stored values can be wrapped into an envelope: envelopeMarker byte followed by codec byte and encoded
+// data. Gob encoded payloads never start with a zero byte (it holds message length) so records written
+// before envelopes were introduced are still decoded as they are.
+const envelopeMarker byte = 0x00
+
+// codecGzip - envelope codec for gzip compressed values
+const codecGzip byte = 'g'
+
+// codecAESGCM - envelope codec for AES-GCM encrypted values, encoded data starts with nonce
+const codecAESGCM byte = 'e'
+
+// isEnvelope - checks whether value is wrapped into an envelope
+func isEnvelope(value []byte) bool {
+	return len(value) >= 2 && value[0] == envelopeMarker
+}
+
+// compressValue - compresses given value with gzip and wraps it into an envelope
+func compressValue(value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write([]byte{envelopeMarker, codecGzip})
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(value); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewEncryptionCipher - returns AES-GCM cipher for given hex encoded key, key has to be 16, 24 or 32 bytes
+// long to select AES-128, AES-192 or AES-256
+func NewEncryptionCipher(hexKey string) (cipher.AEAD, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(hexKey))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key should be hex encoded: %s", err.Error())
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptValue - encrypts given value and wraps it into an envelope
+func encryptValue(value []byte, aead cipher.AEAD) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	envelope := append([]byte{envelopeMarker, codecAESGCM}, nonce...)
+	return aead.Seal(envelope, nonce, value, nil), nil
+}
+
+// openEnvelope - unwraps value, nested envelopes are opened as well. Values that are not wrapped
+// are returned unchanged. Cipher is only required for encrypted values
+func openEnvelope(value []byte, aead cipher.AEAD) ([]byte, error) {
+	for isEnvelope(value) {
+		switch value[1] {
+		case codecAESGCM:
+			if aead == nil {
+				return nil, fmt.Errorf("value is encrypted but encryption key is not configured")
+			}
+			data := value[2:]
+			if len(data) < aead.NonceSize() {
+				return nil, fmt.Errorf("encrypted value is too short")
+			}
+			var err error
+			value, err = aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+			if err != nil {
+				return nil, err
+			}
+		case codecGzip:
+			zr, err := gzip.NewReader(bytes.NewReader(value[2:]))
+			if err != nil {
+				return nil, err
+			}
+			value, err = ioutil.ReadAll(zr)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown value codec %q", value[1])
+		}
+	}
+	return value, nil
+}
+
+// decodeRecord - opens stored value envelope and decodes it into Payload structure
+func decodeRecord(value []byte, aead cipher.AEAD) (*Payload, error) {
+	value, err := openEnvelope(value, aead)
+	if err != nil {
+		return nil, err
+	}
+	return decodePayload(value)
+}
@@ -0,0 +1,91 @@
+package hoverfly
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressValue(t *testing.T) {
+	value := bytes.Repeat([]byte("compress me "), 100)
+
+	compressed, err := compressValue(value)
+	expect(t, err, nil)
+	expect(t, isEnvelope(compressed), true)
+	expect(t, len(compressed) < len(value), true)
+
+	opened, err := openEnvelope(compressed, nil)
+	expect(t, err, nil)
+	expect(t, string(opened), string(value))
+}
+
+func TestOpenEnvelopeNotWrapped(t *testing.T) {
+	payload := Payload{Response: ResponseDetails{Status: 200, Body: "body here"}}
+	bts, err := payload.Encode()
+	expect(t, err, nil)
+
+	// gob encoded payloads are never mistaken for envelopes
+	expect(t, isEnvelope(bts), false)
+
+	opened, err := openEnvelope(bts, nil)
+	expect(t, err, nil)
+	expect(t, string(opened), string(bts))
+}
+
+func TestOpenEnvelopeUnknownCodec(t *testing.T) {
+	_, err := openEnvelope([]byte{envelopeMarker, '?', 1, 2, 3}, nil)
+	refute(t, err, nil)
+}
+
+const testEncryptionKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestEncryptValue(t *testing.T) {
+	aead, err := NewEncryptionCipher(testEncryptionKey)
+	expect(t, err, nil)
+
+	value := []byte("secret token")
+
+	encrypted, err := encryptValue(value, aead)
+	expect(t, err, nil)
+	expect(t, isEnvelope(encrypted), true)
+	expect(t, bytes.Contains(encrypted, value), false)
+
+	opened, err := openEnvelope(encrypted, aead)
+	expect(t, err, nil)
+	expect(t, string(opened), string(value))
+}
+
+func TestEncryptCompressedValue(t *testing.T) {
+	aead, err := NewEncryptionCipher(testEncryptionKey)
+	expect(t, err, nil)
+
+	value := bytes.Repeat([]byte("compress me "), 100)
+
+	compressed, err := compressValue(value)
+	expect(t, err, nil)
+	encrypted, err := encryptValue(compressed, aead)
+	expect(t, err, nil)
+
+	opened, err := openEnvelope(encrypted, aead)
+	expect(t, err, nil)
+	expect(t, string(opened), string(value))
+}
+
+func TestOpenEncryptedWithoutKey(t *testing.T) {
+	aead, err := NewEncryptionCipher(testEncryptionKey)
+	expect(t, err, nil)
+
+	encrypted, err := encryptValue([]byte("secret"), aead)
+	expect(t, err, nil)
+
+	_, err = openEnvelope(encrypted, nil)
+	refute(t, err, nil)
+}
+
+func TestNewEncryptionCipherBadKey(t *testing.T) {
+	_, err := NewEncryptionCipher("not hex")
+	refute(t, err, nil)
+
+	// 5 bytes is not a valid AES key size
+	_, err = NewEncryptionCipher("0102030405")
+	refute(t, err, nil)
+}
@@ -0,0 +1,76 @@
+package hoverfly
+
+import (
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/elazarl/goproxy"
+)
+
+// DefaultCORSAllowMethods - methods allowed in answers to CORS preflights unless configured otherwise
+const DefaultCORSAllowMethods = "GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS"
+
+// isCORSPreflight - checks whether request is browser's CORS preflight
+func isCORSPreflight(req *http.Request) bool {
+	return req.Method == "OPTIONS" && req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// corsAllowOrigin - returns allowed origin, request origin is allowed unless it's configured
+func (c *Configuration) corsAllowOrigin(req *http.Request) string {
+	if c.CORSAllowOrigin != "" {
+		return c.CORSAllowOrigin
+	}
+	return req.Header.Get("Origin")
+}
+
+// corsPreflightResponse - answers CORS preflight when CORS handling is enabled, false otherwise
+func (d *DBClient) corsPreflightResponse(req *http.Request) (*http.Response, bool) {
+	if !d.Cfg.CORS || !isCORSPreflight(req) {
+		return nil, false
+	}
+
+	resp := goproxy.NewResponse(req, goproxy.ContentTypeText, http.StatusNoContent, "")
+	resp.Header.Del("Content-Type")
+	d.addCORSHeaders(req, resp)
+
+	methods := d.Cfg.CORSAllowMethods
+	if methods == "" {
+		methods = DefaultCORSAllowMethods
+	}
+	resp.Header.Set("Access-Control-Allow-Methods", methods)
+
+	headers := d.Cfg.CORSAllowHeaders
+	if headers == "" {
+		headers = req.Header.Get("Access-Control-Request-Headers")
+	}
+	if headers != "" {
+		resp.Header.Set("Access-Control-Allow-Headers", headers)
+	}
+	resp.Header.Set("Access-Control-Max-Age", "1800")
+
+	log.WithFields(log.Fields{
+		"path":        req.URL.Path,
+		"origin":      req.Header.Get("Origin"),
+		"destination": req.Host,
+	}).Debug("Answering CORS preflight")
+	return resp, true
+}
+
+// addCORSHeaders - allows browsers to read simulated response when CORS handling is enabled, recorded CORS headers
+// are replaced as they were meant for the real origin
+func (d *DBClient) addCORSHeaders(req *http.Request, resp *http.Response) {
+	if !d.Cfg.CORS || req.Header.Get("Origin") == "" {
+		return
+	}
+
+	origin := d.Cfg.corsAllowOrigin(req)
+	resp.Header.Set("Access-Control-Allow-Origin", origin)
+	if origin != "*" {
+		// wildcard can't be used with credentials
+		resp.Header.Set("Access-Control-Allow-Credentials", "true")
+		resp.Header.Add("Vary", "Origin")
+	} else {
+		resp.Header.Del("Access-Control-Allow-Credentials")
+	}
+}
@@ -0,0 +1,84 @@
+package hoverfly
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestCORSPreflightAnswered(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	dbClient.Cfg.CORS = true
+
+	req, _ := http.NewRequest("OPTIONS", "http://api.example.com/users", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type, X-Token")
+
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusNoContent)
+	expect(t, resp.Header.Get("Access-Control-Allow-Origin"), "http://localhost:3000")
+	expect(t, resp.Header.Get("Access-Control-Allow-Credentials"), "true")
+	expect(t, resp.Header.Get("Access-Control-Allow-Methods"), DefaultCORSAllowMethods)
+	expect(t, resp.Header.Get("Access-Control-Allow-Headers"), "Content-Type, X-Token")
+
+	// configured values
+	dbClient.Cfg.CORSAllowOrigin = "*"
+	dbClient.Cfg.CORSAllowMethods = "GET"
+	dbClient.Cfg.CORSAllowHeaders = "X-Token"
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.Header.Get("Access-Control-Allow-Origin"), "*")
+	expect(t, resp.Header.Get("Access-Control-Allow-Credentials"), "")
+	expect(t, resp.Header.Get("Access-Control-Allow-Methods"), "GET")
+	expect(t, resp.Header.Get("Access-Control-Allow-Headers"), "X-Token")
+}
+
+func TestCORSHeadersOnSimulatedResponses(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	dbClient.Cfg.CORS = true
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 200)
+	expect(t, resp.Header.Get("Access-Control-Allow-Origin"), "http://localhost:3000")
+	expect(t, resp.Header.Get("Vary"), "Origin")
+
+	// misses can be read by browsers too
+	req, _ = http.NewRequest("GET", "http://api.example.com/orders", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+	expect(t, resp.Header.Get("Access-Control-Allow-Origin"), "http://localhost:3000")
+}
+
+func TestCORSDisabled(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("OPTIONS", "http://api.example.com/users", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+	expect(t, resp.Header.Get("Access-Control-Allow-Origin"), "")
+}
+
+func TestSettingsCORSEnv(t *testing.T) {
+	defer os.Setenv("HoverflyCORS", "")
+	defer os.Setenv("HoverflyCORSAllowOrigin", "")
+
+	os.Setenv("HoverflyCORS", "true")
+	os.Setenv("HoverflyCORSAllowOrigin", "http://localhost:3000")
+	cfg := InitSettings()
+	expect(t, cfg.CORS, true)
+	expect(t, cfg.CORSAllowOrigin, "http://localhost:3000")
+}
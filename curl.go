@@ -0,0 +1,510 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Responses of stubs made of curl commands
+const (
+	// CurlResponseEmpty - 200 response without body
+	CurlResponseEmpty = "empty"
+	// CurlResponseTemplate - templated 200 response, request body is echoed back or request method and path are
+	// returned as JSON when there is no body
+	CurlResponseTemplate = "template"
+)
+
+// curlValueOptions - curl options followed by value by their canonical name, options without effect on the request
+// have empty name
+var curlValueOptions = map[string]string{
+	"-X": "request", "--request": "request", "--url": "url",
+	"-H": "header", "--header": "header",
+	"-d": "data", "--data": "data", "--data-ascii": "data",
+	"--data-raw": "data-raw", "--data-binary": "data-binary", "--data-urlencode": "data-urlencode",
+	"-b": "cookie", "--cookie": "cookie",
+	"-u": "user", "--user": "user",
+	"-A": "user-agent", "--user-agent": "user-agent",
+	"-e": "referer", "--referer": "referer",
+	"-r": "range", "--range": "range",
+	"-F": "form", "--form": "form", "--form-string": "form",
+	"-T": "upload-file", "--upload-file": "upload-file",
+	"-o": "", "--output": "", "-D": "", "--dump-header": "", "-w": "", "--write-out": "",
+	"-m": "", "--max-time": "", "--connect-timeout": "", "--retry": "", "--max-redirs": "", "--limit-rate": "",
+	"-x": "", "--proxy": "", "-U": "", "--proxy-user": "", "--resolve": "", "--interface": "",
+	"-E": "", "--cert": "", "--key": "", "--cacert": "", "--capath": "", "-c": "", "--cookie-jar": "",
+	"--trace": "", "--trace-ascii": "",
+}
+
+// curlFlagOptions - curl options without value by their canonical name, options without effect on the request have
+// empty name
+var curlFlagOptions = map[string]string{
+	"-G": "get", "--get": "get",
+	"-I": "head", "--head": "head",
+	"--compressed": "", "-k": "", "--insecure": "", "-L": "", "--location": "", "--location-trusted": "",
+	"-s": "", "--silent": "", "-S": "", "--show-error": "", "-v": "", "--verbose": "", "-i": "", "--include": "",
+	"-f": "", "--fail": "", "-N": "", "--no-buffer": "", "-#": "", "--progress-bar": "", "-g": "", "--globoff": "",
+	"-0": "", "--http1.0": "", "--http1.1": "", "--http2": "", "--http2-prior-knowledge": "", "-O": "",
+	"--remote-name": "", "-J": "", "--remote-header-name": "", "-q": "", "--no-keepalive": "", "--tr-encoding": "",
+}
+
+// splitCurlCommands - splits text into commands and their words like bash does, commands are separated by line
+// breaks, ";" or "&&". Single, double and ANSI-C ($'...') quotes, escapes, line continuations and comments are
+// supported, variables and other expansions are not
+func splitCurlCommands(text string) ([][]string, error) {
+	var commands [][]string
+	var words []string
+	var word bytes.Buffer
+	inWord := false
+
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, words)
+			words = nil
+		}
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(text) {
+				continue
+			}
+			i++
+			if text[i] == '\r' && i+1 < len(text) && text[i+1] == '\n' {
+				i++
+			}
+			if text[i] != '\n' {
+				// line continuation joins lines, other characters are taken literally
+				word.WriteByte(text[i])
+				inWord = true
+			}
+		case c == ' ' || c == '\t' || c == '\r':
+			endWord()
+		case c == '\n' || c == ';':
+			endCommand()
+		case c == '&' && i+1 < len(text) && text[i+1] == '&':
+			i++
+			endCommand()
+		case c == '#' && !inWord:
+			for i+1 < len(text) && text[i+1] != '\n' {
+				i++
+			}
+		case c == '\'':
+			end := strings.IndexByte(text[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(text[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+		case c == '"':
+			n, err := readDoubleQuoted(&word, text[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			inWord = true
+			i += n
+		case c == '$' && i+1 < len(text) && text[i+1] == '\'':
+			n, err := readANSIQuoted(&word, text[i+2:])
+			if err != nil {
+				return nil, err
+			}
+			inWord = true
+			i += n + 1
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	endCommand()
+	return commands, nil
+}
+
+// readDoubleQuoted - writes content of double quoted string that follows the opening quote, returns length of the
+// string including the closing quote
+func readDoubleQuoted(buf *bytes.Buffer, text string) (int, error) {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '"':
+			return i + 1, nil
+		case '\\':
+			if i+1 < len(text) && strings.IndexByte("$`\"\\\n", text[i+1]) >= 0 {
+				i++
+				if text[i] != '\n' {
+					buf.WriteByte(text[i])
+				}
+				continue
+			}
+			buf.WriteByte('\\')
+		default:
+			buf.WriteByte(text[i])
+		}
+	}
+	return 0, fmt.Errorf("unterminated double quote")
+}
+
+// readANSIQuoted - writes content of $'...' string that follows the opening quote with escape sequences replaced,
+// returns length of the string including the closing quote
+func readANSIQuoted(buf *bytes.Buffer, text string) (int, error) {
+	simple := map[byte]byte{
+		'a': '\a', 'b': '\b', 'e': 0x1b, 'E': 0x1b, 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+		'\\': '\\', '\'': '\'', '"': '"', '?': '?',
+	}
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c == '\'' {
+			return i + 1, nil
+		}
+		if c != '\\' || i+1 >= len(text) {
+			buf.WriteByte(c)
+			continue
+		}
+
+		i++
+		if escaped, ok := simple[text[i]]; ok {
+			buf.WriteByte(escaped)
+			continue
+		}
+		digits, base, max := "", 16, 0
+		switch text[i] {
+		case 'x':
+			max = 2
+		case 'u':
+			max = 4
+		case 'U':
+			max = 8
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// octal escape, its first digit is part of the number
+			i--
+			base, max = 8, 3
+		default:
+			buf.WriteByte('\\')
+			buf.WriteByte(text[i])
+			continue
+		}
+		for len(digits) < max && i+1 < len(text) && isDigitOfBase(text[i+1], base) {
+			i++
+			digits += string(text[i])
+		}
+		if digits == "" {
+			buf.WriteByte('\\')
+			buf.WriteByte(text[i])
+			continue
+		}
+		code, _ := strconv.ParseUint(digits, base, 32)
+		if max == 2 || base == 8 {
+			// \x and octal escapes are bytes, \u and \U are unicode characters
+			buf.WriteByte(byte(code))
+		} else {
+			buf.WriteRune(rune(code))
+		}
+	}
+	return 0, fmt.Errorf("unterminated $' quote")
+}
+
+// isDigitOfBase - checks whether character is digit of base 8 or 16
+func isDigitOfBase(c byte, base int) bool {
+	if base == 8 {
+		return c >= '0' && c <= '7'
+	}
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// curlOption - option of curl command with value attached to it (i.e. -XPOST)
+type curlOption struct {
+	name     string
+	value    string
+	attached bool
+}
+
+// expandCurlOption - splits word into options, short options can be grouped (i.e. -sSL)
+func expandCurlOption(word string) []curlOption {
+	if strings.HasPrefix(word, "--") {
+		return []curlOption{{name: word}}
+	}
+	var options []curlOption
+	for i := 1; i < len(word); i++ {
+		name := "-" + string(word[i])
+		if _, ok := curlValueOptions[name]; ok && i+1 < len(word) {
+			return append(options, curlOption{name: name, value: word[i+1:], attached: true})
+		}
+		options = append(options, curlOption{name: name})
+	}
+	return options
+}
+
+// curlPayload - converts words of curl command into stub record, response is one of CurlResponseEmpty and
+// CurlResponseTemplate
+func curlPayload(words []string, response string) (Payload, error) {
+	if len(words) == 0 || path.Base(words[0]) != "curl" {
+		return Payload{}, fmt.Errorf("not a curl command")
+	}
+
+	var method, rawURL string
+	var data []string
+	var get, head bool
+	headers := make(http.Header)
+
+	for i := 1; i < len(words); i++ {
+		word := words[i]
+		if len(word) < 2 || word[0] != '-' {
+			if rawURL != "" {
+				return Payload{}, fmt.Errorf("more than one URL ('%s' and '%s')", rawURL, word)
+			}
+			rawURL = word
+			continue
+		}
+
+		for _, option := range expandCurlOption(word) {
+			if name, ok := curlFlagOptions[option.name]; ok {
+				get = get || name == "get"
+				head = head || name == "head"
+				continue
+			}
+			name, ok := curlValueOptions[option.name]
+			if !ok {
+				return Payload{}, fmt.Errorf("unsupported option '%s'", option.name)
+			}
+			value := option.value
+			if !option.attached {
+				if i+1 >= len(words) {
+					return Payload{}, fmt.Errorf("option '%s' needs a value", option.name)
+				}
+				i++
+				value = words[i]
+			}
+
+			switch name {
+			case "request":
+				method = value
+			case "url":
+				if rawURL != "" {
+					return Payload{}, fmt.Errorf("more than one URL ('%s' and '%s')", rawURL, value)
+				}
+				rawURL = value
+			case "header":
+				addCurlHeader(headers, value)
+			case "data", "data-binary":
+				if strings.HasPrefix(value, "@") {
+					return Payload{}, fmt.Errorf("data read from files (%s) isn't supported", value)
+				}
+				if name == "data" {
+					// like curl, only --data-binary keeps line breaks
+					value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+				}
+				data = append(data, value)
+			case "data-raw":
+				data = append(data, value)
+			case "data-urlencode":
+				encoded, err := curlURLEncode(value)
+				if err != nil {
+					return Payload{}, err
+				}
+				data = append(data, encoded)
+			case "cookie":
+				if !strings.Contains(value, "=") {
+					return Payload{}, fmt.Errorf("cookies read from files (%s) aren't supported", value)
+				}
+				headers.Add("Cookie", value)
+			case "user":
+				if headers.Get("Authorization") == "" {
+					if !strings.Contains(value, ":") {
+						value += ":"
+					}
+					headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(value)))
+				}
+			case "user-agent":
+				if headers.Get("User-Agent") == "" {
+					headers.Set("User-Agent", value)
+				}
+			case "referer":
+				if headers.Get("Referer") == "" {
+					headers.Set("Referer", value)
+				}
+			case "range":
+				headers.Set("Range", "bytes="+value)
+			case "form":
+				return Payload{}, fmt.Errorf("multipart forms (%s) aren't supported", option.name)
+			case "upload-file":
+				return Payload{}, fmt.Errorf("file uploads (%s) aren't supported", option.name)
+			}
+		}
+	}
+
+	if rawURL == "" {
+		return Payload{}, fmt.Errorf("URL is missing")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Payload{}, fmt.Errorf("invalid URL '%s': %s", rawURL, err.Error())
+	}
+	if u.Host == "" {
+		return Payload{}, fmt.Errorf("URL '%s' has no host", rawURL)
+	}
+
+	body := strings.Join(data, "&")
+	if get && body != "" {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += body
+		body = ""
+	}
+	if method == "" {
+		switch {
+		case head:
+			method = "HEAD"
+		case body != "":
+			method = "POST"
+		default:
+			method = "GET"
+		}
+	}
+	if body != "" && headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	requestPath := u.Path
+	if requestPath == "" {
+		requestPath = "/"
+	}
+
+	pl := Payload{
+		Request: RequestDetails{
+			Method:      method,
+			Destination: u.Host,
+			Scheme:      u.Scheme,
+			Path:        requestPath,
+			Query:       u.RawQuery,
+			Body:        body,
+			Headers:     headers,
+		},
+	}
+	pl.Response, err = curlResponse(pl.Request, response)
+	return pl, err
+}
+
+// addCurlHeader - adds header given as "Name: value", "Name;" adds header without value and "Name:" is ignored
+// like curl does
+func addCurlHeader(headers http.Header, header string) {
+	colon := strings.IndexByte(header, ':')
+	if colon < 0 {
+		if strings.HasSuffix(header, ";") {
+			if name := strings.TrimSpace(header[:len(header)-1]); name != "" {
+				headers.Add(name, "")
+			}
+		}
+		return
+	}
+	name, value := strings.TrimSpace(header[:colon]), strings.TrimSpace(header[colon+1:])
+	if name != "" && value != "" {
+		headers.Add(name, value)
+	}
+}
+
+// curlURLEncode - encodes --data-urlencode value, which is "content", "=content" or "name=content"
+func curlURLEncode(value string) (string, error) {
+	if at := strings.IndexByte(value, '@'); at >= 0 && !strings.Contains(value[:at], "=") {
+		return "", fmt.Errorf("data read from files (%s) isn't supported", value)
+	}
+	equals := strings.IndexByte(value, '=')
+	if equals < 0 {
+		return url.QueryEscape(value), nil
+	}
+	if equals == 0 {
+		return url.QueryEscape(value[1:]), nil
+	}
+	return value[:equals] + "=" + url.QueryEscape(value[equals+1:]), nil
+}
+
+// curlResponse - returns response of stub made of curl command
+func curlResponse(request RequestDetails, response string) (ResponseDetails, error) {
+	switch response {
+	case "", CurlResponseEmpty:
+		return ResponseDetails{Status: http.StatusOK, Headers: map[string][]string{}}, nil
+	case CurlResponseTemplate:
+		if request.Body != "" {
+			contentType := headerValues(request.Headers, "Content-Type")
+			return ResponseDetails{
+				Status:    http.StatusOK,
+				Body:      "{{ .Request.Body }}",
+				Headers:   map[string][]string{"Content-Type": contentType},
+				Templated: true,
+			}, nil
+		}
+		return ResponseDetails{
+			Status:    http.StatusOK,
+			Body:      `{"method": "{{ .Request.Method }}", "path": "{{ .Request.Path }}"}`,
+			Headers:   map[string][]string{"Content-Type": {"application/json"}},
+			Templated: true,
+		}, nil
+	}
+	return ResponseDetails{}, fmt.Errorf("unknown response '%s', use empty or template", response)
+}
+
+// curlPayloads - converts curl commands into stub records, the whole text is refused when any of the commands
+// can't be converted so that no stub goes missing unnoticed
+func curlPayloads(text, response string) ([]Payload, error) {
+	commands, err := splitCurlCommands(text)
+	if err != nil {
+		return nil, err
+	}
+	payloads := make([]Payload, 0, len(commands))
+	for i, words := range commands {
+		pl, err := curlPayload(words, response)
+		if err != nil {
+			return nil, fmt.Errorf("command %d (%s): %s", i+1, strings.Join(words, " "), err.Error())
+		}
+		payloads = append(payloads, pl)
+	}
+	return payloads, nil
+}
+
+// ImportCurl - imports curl commands (i.e. copied from browser devtools with "Copy as cURL") as stub records,
+// response is one of CurlResponseEmpty and CurlResponseTemplate
+func (d *DBClient) ImportCurl(r io.Reader, response string) error {
+	text, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("Got error while reading curl commands, error %s", err.Error())
+	}
+	payloads, err := curlPayloads(string(text), response)
+	if err != nil {
+		return fmt.Errorf("Got error while parsing curl commands, error %s", err.Error())
+	}
+	log.WithFields(log.Fields{
+		"commands": len(payloads),
+	}).Info("Converted curl commands into stubs")
+	return d.ImportPayloads(payloads)
+}
+
+// ImportCurlFromDisk - imports curl commands of given file as stub records with empty responses
+func (d *DBClient) ImportCurlFromDisk(path string) error {
+	commandsFile, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Got error while opening curl commands file, error %s", err.Error())
+	}
+	defer commandsFile.Close()
+	return d.ImportCurl(commandsFile, CurlResponseEmpty)
+}
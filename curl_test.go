@@ -0,0 +1,120 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSplitCurlCommands(t *testing.T) {
+	text := `# copied from devtools
+curl 'https://api.example.com/users?page=1' \
+  -H 'accept: application/json' \
+  -H "X-Quote: \"quoted\" \$HOME" \
+  --data-raw $'{"name":"it\'s","emoji":"☺","byte":"\x41\101"}' \
+  --compressed ;
+curl http://api.example.com/health && curl example.com/a\ b
+`
+	commands, err := splitCurlCommands(text)
+	expect(t, err, nil)
+	expect(t, len(commands), 3)
+	expect(t, strings.Join(commands[0], "|"), `curl|https://api.example.com/users?page=1|-H|accept: application/json|-H|X-Quote: "quoted" $HOME|--data-raw|{"name":"it's","emoji":"☺","byte":"AA"}|--compressed`)
+	expect(t, strings.Join(commands[1], "|"), "curl|http://api.example.com/health")
+	expect(t, strings.Join(commands[2], "|"), "curl|example.com/a b")
+
+	for _, broken := range []string{`curl 'open`, `curl "open`, `curl $'open`} {
+		_, err = splitCurlCommands(broken)
+		refute(t, err, nil)
+	}
+}
+
+func TestCurlPayload(t *testing.T) {
+	commands, _ := splitCurlCommands(`curl -sSL -XPUT 'https://api.example.com/users/1?v=2#top' -H 'content-type: application/json' ` +
+		`-H 'X-Removed:' -H 'X-Empty;' -u alice:secret -A agent -b 'session=1' --data-binary $'{"name":\n"alice"}'`)
+	pl, err := curlPayload(commands[0], CurlResponseEmpty)
+	expect(t, err, nil)
+	expect(t, pl.Request.Method, "PUT")
+	expect(t, pl.Request.Scheme, "https")
+	expect(t, pl.Request.Destination, "api.example.com")
+	expect(t, pl.Request.Path, "/users/1")
+	expect(t, pl.Request.Query, "v=2")
+	expect(t, pl.Request.Body, "{\"name\":\n\"alice\"}")
+	expect(t, pl.Request.Headers["Content-Type"][0], "application/json")
+	expect(t, pl.Request.Headers["Authorization"][0], "Basic YWxpY2U6c2VjcmV0")
+	expect(t, pl.Request.Headers["User-Agent"][0], "agent")
+	expect(t, pl.Request.Headers["Cookie"][0], "session=1")
+	expect(t, len(pl.Request.Headers["X-Removed"]), 0)
+	expect(t, len(pl.Request.Headers["X-Empty"]), 1)
+	expect(t, pl.Response.Status, 200)
+	expect(t, pl.Response.Body, "")
+
+	// data makes POST form requests, -G moves it to the query
+	commands, _ = splitCurlCommands("curl example.com -d 'a=1' -d $'b=2\\n' --data-urlencode 'q=x y'\n" +
+		"curl example.com/search -G -d 'a=1' --data-urlencode 'q=x y'\n" +
+		"curl -I --url http://example.com")
+	pl, err = curlPayload(commands[0], CurlResponseEmpty)
+	expect(t, err, nil)
+	expect(t, pl.Request.Method, "POST")
+	expect(t, pl.Request.Scheme, "http")
+	expect(t, pl.Request.Path, "/")
+	expect(t, pl.Request.Body, "a=1&b=2&q=x+y")
+	expect(t, pl.Request.Headers["Content-Type"][0], "application/x-www-form-urlencoded")
+
+	pl, err = curlPayload(commands[1], CurlResponseEmpty)
+	expect(t, err, nil)
+	expect(t, pl.Request.Method, "GET")
+	expect(t, pl.Request.Query, "a=1&q=x+y")
+	expect(t, pl.Request.Body, "")
+
+	pl, err = curlPayload(commands[2], CurlResponseEmpty)
+	expect(t, err, nil)
+	expect(t, pl.Request.Method, "HEAD")
+	expect(t, pl.Request.Destination, "example.com")
+
+	for _, broken := range []string{
+		"wget http://example.com",
+		"curl",
+		"curl http://example.com http://example.org",
+		"curl http://example.com --unknown",
+		"curl http://example.com -H",
+		"curl http://example.com -d @file.json",
+		"curl http://example.com -F file=@photo.jpg",
+		"curl http://example.com -b cookies.txt",
+	} {
+		commands, _ = splitCurlCommands(broken)
+		_, err = curlPayload(commands[0], CurlResponseEmpty)
+		if err == nil {
+			t.Errorf("expected error for %s", broken)
+		}
+	}
+}
+
+func TestCurlTemplatedResponses(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	commands := `curl http://api.example.com/users -H 'Content-Type: application/json' -d '{"name": "alice"}'
+curl http://api.example.com/users/1`
+	expect(t, dbClient.ImportCurl(strings.NewReader(commands), CurlResponseTemplate), nil)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("POST", "http://api.example.com/users", strings.NewReader(`{"name": "alice"}`))
+	req.Header.Set("Content-Type", "application/json")
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, resp.StatusCode, 200)
+	expect(t, resp.Header.Get("Content-Type"), "application/json")
+	expect(t, string(body), `{"name": "alice"}`)
+
+	req, _ = http.NewRequest("GET", "http://api.example.com/users/1", nil)
+	_, resp = dbClient.processRequest(req)
+	body, _ = ioutil.ReadAll(resp.Body)
+	expect(t, string(body), `{"method": "GET", "path": "/users/1"}`)
+
+	err := dbClient.ImportCurl(strings.NewReader("curl http://api.example.com/\ncurl -F a=b http://api.example.com/"), CurlResponseEmpty)
+	refute(t, err, nil)
+	expect(t, strings.Contains(err.Error(), "command 2"), true)
+	refute(t, dbClient.ImportCurl(strings.NewReader("curl http://api.example.com/"), "random"), nil)
+}
@@ -0,0 +1,117 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// RequestMatcher - custom matching logic registered by applications embedding Hoverfly, i.e. matching fields of
+// protobuf bodies. Records refer to registered matchers by name in "customMatchers" and give each its own config
+type RequestMatcher interface {
+	// Match - checks whether live request matches, config is the raw JSON value from the record
+	Match(request RequestDetails, config json.RawMessage) (bool, error)
+}
+
+// RequestMatcherFunc - adapter that allows using ordinary functions as request matchers
+type RequestMatcherFunc func(request RequestDetails, config json.RawMessage) (bool, error)
+
+// Match - calls f(request, config)
+func (f RequestMatcherFunc) Match(request RequestDetails, config json.RawMessage) (bool, error) {
+	return f(request, config)
+}
+
+// RequestMatcherValidator - optional interface of request matchers that check their config when records are
+// imported, records with invalid config are skipped
+type RequestMatcherValidator interface {
+	Validate(config json.RawMessage) error
+}
+
+// CustomMatchers - request matchers registered by name
+type CustomMatchers struct {
+	mu       sync.RWMutex
+	matchers map[string]RequestMatcher
+}
+
+// NewCustomMatchers - returns empty matcher registry
+func NewCustomMatchers() *CustomMatchers {
+	return &CustomMatchers{matchers: make(map[string]RequestMatcher)}
+}
+
+// Register - adds matcher under given name, names can't be registered twice
+func (c *CustomMatchers) Register(name string, matcher RequestMatcher) error {
+	if name == "" || matcher == nil {
+		return fmt.Errorf("custom matcher needs name and implementation")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.matchers[name]; ok {
+		return fmt.Errorf("custom matcher '%s' is already registered", name)
+	}
+	c.matchers[name] = matcher
+	return nil
+}
+
+// Get - returns matcher registered under given name
+func (c *CustomMatchers) Get(name string) (RequestMatcher, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	matcher, ok := c.matchers[name]
+	return matcher, ok
+}
+
+// Names - returns sorted names of registered matchers
+func (c *CustomMatchers) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, 0, len(c.matchers))
+	for name := range c.matchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// customMatcher - returns matcher registered under given name, false when there is none
+func (d *DBClient) customMatcher(name string) (RequestMatcher, bool) {
+	if d.CustomMatchers == nil {
+		return nil, false
+	}
+	return d.CustomMatchers.Get(name)
+}
+
+// validateCustomMatchers - checks that all custom matchers of record are registered and accept their config
+func (d *DBClient) validateCustomMatchers(pl Payload) error {
+	for name, config := range pl.CustomMatchers {
+		matcher, ok := d.customMatcher(name)
+		if !ok {
+			return fmt.Errorf("custom matcher '%s' is not registered", name)
+		}
+		if validator, ok := matcher.(RequestMatcherValidator); ok {
+			if err := validator.Validate(config); err != nil {
+				return fmt.Errorf("custom matcher '%s': %s", name, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// failedCustomMatchers - returns sorted names of custom matchers of record that live request doesn't satisfy,
+// matchers that fail with an error aren't satisfied
+func (d *DBClient) failedCustomMatchers(pl Payload, live RequestDetails) []string {
+	var failed []string
+	for name, config := range pl.CustomMatchers {
+		matcher, ok := d.customMatcher(name)
+		if !ok {
+			failed = append(failed, name)
+			continue
+		}
+		if matched, err := matcher.Match(live, config); err != nil || !matched {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
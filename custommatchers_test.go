@@ -0,0 +1,107 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// bodyLengthMatcher - matches requests with body of at least given length
+type bodyLengthMatcher struct{}
+
+func (bodyLengthMatcher) Match(request RequestDetails, config json.RawMessage) (bool, error) {
+	var min int
+	if err := json.Unmarshal(config, &min); err != nil {
+		return false, err
+	}
+	return len(request.Body) >= min, nil
+}
+
+func (bodyLengthMatcher) Validate(config json.RawMessage) error {
+	var min int
+	if err := json.Unmarshal(config, &min); err != nil {
+		return fmt.Errorf("config has to be a number")
+	}
+	return nil
+}
+
+func TestCustomMatchersRegister(t *testing.T) {
+	matchers := NewCustomMatchers()
+	expect(t, matchers.Register("length", bodyLengthMatcher{}), nil)
+	refute(t, matchers.Register("length", bodyLengthMatcher{}), nil)
+	refute(t, matchers.Register("", bodyLengthMatcher{}), nil)
+
+	_, ok := matchers.Get("length")
+	expect(t, ok, true)
+	_, ok = matchers.Get("protobuf")
+	expect(t, ok, false)
+	expect(t, len(matchers.Names()), 1)
+}
+
+func TestRecordsWithCustomMatchers(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	expect(t, dbClient.CustomMatchers.Register("length", bodyLengthMatcher{}), nil)
+	expect(t, dbClient.CustomMatchers.Register("tenant", RequestMatcherFunc(func(request RequestDetails, config json.RawMessage) (bool, error) {
+		var tenant string
+		json.Unmarshal(config, &tenant)
+		return strings.HasPrefix(request.Body, tenant+":"), nil
+	})), nil)
+
+	request := RequestDetails{Method: "POST", Destination: "api.example.com", Path: "/upload"}
+	err := dbClient.ImportPayloads([]Payload{
+		{
+			Request:        request,
+			Response:       ResponseDetails{Status: 413, Body: "too large"},
+			CustomMatchers: map[string]json.RawMessage{"length": json.RawMessage(`20`)},
+		},
+		{
+			Request:  request,
+			Response: ResponseDetails{Status: 201, Body: "acme"},
+			CustomMatchers: map[string]json.RawMessage{
+				"length": json.RawMessage(`20`),
+				"tenant": json.RawMessage(`"acme"`),
+			},
+		},
+		{
+			Request:        request,
+			Response:       ResponseDetails{Status: 200},
+			CustomMatchers: map[string]json.RawMessage{"protobuf": json.RawMessage(`{}`)},
+		},
+		{
+			Request:        request,
+			Response:       ResponseDetails{Status: 200},
+			CustomMatchers: map[string]json.RawMessage{"length": json.RawMessage(`"long"`)},
+		},
+	})
+	expect(t, err, nil)
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 2)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	upload := func(body string) (int, string) {
+		req, _ := http.NewRequest("POST", "http://api.example.com/upload", strings.NewReader(body))
+		_, resp := dbClient.processRequest(req)
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(respBody)
+	}
+
+	status, body := upload("initech:" + strings.Repeat("x", 20))
+	expect(t, status, 413)
+	expect(t, body, "too large")
+
+	// record with more matchers is more specific
+	status, body = upload("acme:" + strings.Repeat("x", 20))
+	expect(t, status, 201)
+	expect(t, body, "acme")
+
+	dbClient.Cfg.MissDebug = true
+	status, body = upload("acme:x")
+	expect(t, status, dbClient.Cfg.GetMissStatus())
+	expect(t, strings.Contains(body, `custom matcher length: expected "[redacted]", got "not matched"`), true)
+}
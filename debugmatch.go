@@ -0,0 +1,130 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// DefaultNearestRecords - how many of the closest records are compared with the request in match trace
+const DefaultNearestRecords = 3
+
+// matchCandidate - lookup done for the request, webserver looks the request up for every recorded destination
+type matchCandidate struct {
+	Destination string `json:"destination"`
+	// Key - request fingerprint, record stored under it answers the request
+	Key   string `json:"key"`
+	Found bool   `json:"found"`
+	// MatcherRecord - record with matchers (i.e. path glob) matching the request when none is stored under Key
+	MatcherRecord string `json:"matcherRecord,omitempty"`
+}
+
+// nearestRecord - record compared field by field with the request
+type nearestRecord struct {
+	ID          string          `json:"id"`
+	Method      string          `json:"method"`
+	Destination string          `json:"destination"`
+	Path        string          `json:"path"`
+	Query       string          `json:"query,omitempty"`
+	Score       int             `json:"score"`
+	Mismatches  []matchMismatch `json:"mismatches"`
+}
+
+// MatchTrace - explains how request would be matched: every lookup that was tried, record that would answer it and
+// how the nearest records differ from it
+type MatchTrace struct {
+	Matched    bool             `json:"matched"`
+	RecordKey  string           `json:"recordKey,omitempty"`
+	MatchedBy  string           `json:"matchedBy,omitempty"`
+	Candidates []matchCandidate `json:"candidates"`
+	// StateMismatches - scenario state the matched record requires but which isn't current, such request is
+	// answered with miss status
+	StateMismatches []matchMismatch `json:"stateMismatches,omitempty"`
+	Nearest         []nearestRecord `json:"nearest"`
+}
+
+// traceMatch - looks request up the way proxy (or webserver) would, but nothing is served: sequences don't advance,
+// scenario state doesn't change and request isn't journaled
+func (d *DBClient) traceMatch(request RequestDetails, nearest int) (MatchTrace, error) {
+	if request.Method == "" {
+		request.Method = "GET"
+	}
+	if request.Destination == "" && !d.Cfg.Webserver {
+		return MatchTrace{}, fmt.Errorf("request destination not specified")
+	}
+
+	req := &http.Request{
+		Method: request.Method,
+		Host:   request.Destination,
+		URL:    &url.URL{Path: request.Path, RawQuery: request.Query},
+		Header: http.Header(request.Headers),
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	body := []byte(request.Body)
+
+	destinations := []string{request.Destination}
+	if d.Cfg.Webserver {
+		destinations = d.webserverDestinations(request.Destination)
+	}
+
+	trace := MatchTrace{Candidates: []matchCandidate{}, Nearest: []nearestRecord{}}
+	var payloadBts []byte
+	for _, destination := range destinations {
+		req.Host = destination
+		candidate := matchCandidate{Destination: destination, Key: d.requestFingerprint(req, body)}
+		trace.Candidates = append(trace.Candidates, candidate)
+
+		bts, err := d.Cache.Get([]byte(candidate.Key))
+		if err == nil {
+			trace.Candidates[len(trace.Candidates)-1].Found = true
+			trace.Matched, trace.RecordKey, trace.MatchedBy = true, candidate.Key, matchedBy(req, candidate.Key)
+			payloadBts = bts
+			break
+		}
+		if matchedReq, matchedKey, bts, ok := d.matcherRecord(req, body); ok {
+			trace.Candidates[len(trace.Candidates)-1].MatcherRecord = matchedKey
+			trace.Matched, trace.RecordKey, trace.MatchedBy = true, matchedKey, matchedBy(matchedReq, matchedKey)
+			payloadBts = bts
+			break
+		}
+	}
+
+	if trace.Matched && d.ScenarioState != nil {
+		if payload, err := decodePayload(payloadBts); err == nil {
+			state := d.ScenarioState.Get()
+			for key, value := range payload.RequiresState {
+				if state[key] != value {
+					trace.StateMismatches = append(trace.StateMismatches, matchMismatch{Field: "state " + key,
+						Expected: value, Actual: state[key]})
+				}
+			}
+		}
+	}
+
+	live := RequestDetails{
+		Path:        request.Path,
+		Method:      request.Method,
+		Destination: request.Destination,
+		Query:       request.Query,
+		Body:        request.Body,
+		Headers:     req.Header,
+	}
+	for _, record := range d.closestRecords(live, d.Cfg.Webserver, nearest) {
+		mismatches := record.mismatches
+		if mismatches == nil {
+			mismatches = []matchMismatch{}
+		}
+		trace.Nearest = append(trace.Nearest, nearestRecord{
+			ID:          record.payload.ID,
+			Method:      record.payload.Request.Method,
+			Destination: record.payload.Request.Destination,
+			Path:        record.payload.Request.Path,
+			Query:       record.payload.Request.Query,
+			Score:       record.score,
+			Mismatches:  mismatches,
+		})
+	}
+	return trace, nil
+}
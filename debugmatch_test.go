@@ -0,0 +1,138 @@
+package hoverfly
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTraceMatchFingerprint(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users?page=1", "", 200, "users")
+
+	trace, err := dbClient.traceMatch(RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/users",
+		Query: "page=1"}, DefaultNearestRecords)
+	expect(t, err, nil)
+	expect(t, trace.Matched, true)
+	expect(t, len(trace.Candidates), 1)
+	expect(t, trace.Candidates[0].Found, true)
+	expect(t, trace.RecordKey, trace.Candidates[0].Key)
+	expect(t, strings.HasSuffix(trace.MatchedBy, "fingerprint"), true)
+	expect(t, len(trace.Nearest), 1)
+	expect(t, trace.Nearest[0].Score, 0)
+	expect(t, len(trace.Nearest[0].Mismatches), 0)
+}
+
+func TestTraceMatchExplainsMiss(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "POST", "http://api.example.com/orders?page=1", `{"type": "express"}`, 201, "created")
+	storeTestPayload(dbClient, "GET", "http://api.example.com/orders", "", 200, "orders")
+	storeTestPayload(dbClient, "GET", "http://billing.example.com/invoices", "", 200, "invoices")
+
+	trace, err := dbClient.traceMatch(RequestDetails{Method: "POST", Destination: "api.example.com", Path: "/orders",
+		Query: "page=2", Body: `{"type": "standard"}`}, 2)
+	expect(t, err, nil)
+	expect(t, trace.Matched, false)
+	expect(t, trace.RecordKey, "")
+	expect(t, len(trace.Candidates), 1)
+	expect(t, trace.Candidates[0].Found, false)
+
+	// the closest records first, only as many as asked for
+	expect(t, len(trace.Nearest), 2)
+	expect(t, trace.Nearest[0].Method, "POST")
+	expect(t, trace.Nearest[0].Query, "page=1")
+	expect(t, len(trace.Nearest[0].Mismatches), 2)
+	expect(t, trace.Nearest[0].Mismatches[0].Field, "query parameter page")
+	// admin endpoint shows recorded values that are redacted for proxy clients
+	expect(t, trace.Nearest[0].Mismatches[0].Expected, "1")
+	expect(t, trace.Nearest[0].Mismatches[1].Field, "body.type")
+	expect(t, trace.Nearest[1].Method, "GET")
+	expect(t, trace.Nearest[1].Path, "/orders")
+}
+
+func TestTraceMatchMatchers(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.ImportPayloads([]Payload{
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com"},
+			Response: ResponseDetails{Status: 200, Body: "orders"},
+			Matchers: &RequestMatchers{Path: &FieldMatcher{Glob: "/users/*/orders"}},
+		},
+	})
+	expect(t, err, nil)
+
+	trace, err := dbClient.traceMatch(RequestDetails{Method: "GET", Destination: "api.example.com",
+		Path: "/users/7/orders"}, DefaultNearestRecords)
+	expect(t, err, nil)
+	expect(t, trace.Matched, true)
+	expect(t, trace.Candidates[0].Found, false)
+	refute(t, trace.Candidates[0].MatcherRecord, "")
+	expect(t, trace.RecordKey, trace.Candidates[0].MatcherRecord)
+	expect(t, strings.Contains(trace.MatchedBy, "matchers"), true)
+}
+
+func TestTraceMatchDoesNotServe(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.ImportPayloads([]Payload{
+		{
+			Request:          RequestDetails{Method: "POST", Destination: "api.example.com", Path: "/orders/1/pay"},
+			Response:         ResponseDetails{Status: 200, Body: "paid"},
+			RequiresState:    map[string]string{"order": "created"},
+			TransitionsState: map[string]string{"order": "paid"},
+		},
+	})
+	expect(t, err, nil)
+	dbClient.ScenarioState.Set(map[string]string{"order": "new"})
+
+	trace, err := dbClient.traceMatch(RequestDetails{Method: "POST", Destination: "api.example.com",
+		Path: "/orders/1/pay"}, DefaultNearestRecords)
+	expect(t, err, nil)
+	expect(t, trace.Matched, true)
+	expect(t, len(trace.StateMismatches), 1)
+	expect(t, trace.StateMismatches[0].Field, "state order")
+	expect(t, trace.StateMismatches[0].Expected, "created")
+	expect(t, trace.StateMismatches[0].Actual, "new")
+
+	// state didn't change and nothing was journaled
+	expect(t, dbClient.ScenarioState.Get()["order"], "new")
+	expect(t, dbClient.Journal.Len(), 0)
+}
+
+func TestTraceMatchWebserver(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.Webserver = true
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+
+	trace, err := dbClient.traceMatch(RequestDetails{Method: "GET", Destination: "localhost:8500", Path: "/users"},
+		DefaultNearestRecords)
+	expect(t, err, nil)
+	expect(t, trace.Matched, true)
+	expect(t, len(trace.Candidates), 2)
+	expect(t, trace.Candidates[0].Destination, "localhost:8500")
+	expect(t, trace.Candidates[0].Found, false)
+	expect(t, trace.Candidates[1].Destination, "api.example.com")
+	expect(t, trace.Candidates[1].Found, true)
+}
+
+func TestTraceMatchNeedsDestination(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+
+	_, err := dbClient.traceMatch(RequestDetails{Method: "GET", Path: "/users", Headers: http.Header{}}, 1)
+	refute(t, err, nil)
+}
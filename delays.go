@@ -0,0 +1,187 @@
+package hoverfly
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Latency distributions of response delays
+const (
+	// DelayFixed - always waits Delay
+	DelayFixed = "fixed"
+	// DelayUniform - waits random time between Delay and MaxDelay
+	DelayUniform = "uniform"
+	// DelayLogNormal - waits log-normally distributed time with given Median and Mean, kept between Delay and
+	// MaxDelay. Most responses are close to the median while a few take much longer, like real services do
+	DelayLogNormal = "lognormal"
+)
+
+// ResponseDelay - latency added to simulated responses of matching requests. When Distribution isn't set, delay
+// is fixed unless MaxDelay is set, then random delay between Delay and MaxDelay is used
+type ResponseDelay struct {
+	// URLPattern - regular expression matched against request host and path, i.e. "api\.example\.com/users"
+	URLPattern string `json:"urlPattern"`
+	// HTTPMethod - method of matching requests, all methods match when empty
+	HTTPMethod string `json:"httpMethod,omitempty"`
+	// Distribution - one of DelayFixed, DelayUniform or DelayLogNormal
+	Distribution string `json:"distribution,omitempty"`
+	// Delay, MaxDelay - milliseconds, minimum and maximum delay for random distributions
+	Delay    int `json:"delay"`
+	MaxDelay int `json:"maxDelay,omitempty"`
+	// Median, Mean - milliseconds, shape of log-normal distribution
+	Median int `json:"median,omitempty"`
+	Mean   int `json:"mean,omitempty"`
+
+	urlRe *regexp.Regexp
+	// mu, sigma - parameters of the normal distribution behind log-normal delays
+	mu, sigma float64
+}
+
+// compile - validates delay and compiles its URL pattern
+func (r *ResponseDelay) compile() error {
+	re, err := regexp.Compile(r.URLPattern)
+	if err != nil {
+		return fmt.Errorf("invalid URL pattern '%s': %s", r.URLPattern, err.Error())
+	}
+	if r.Delay < 0 || r.MaxDelay < 0 {
+		return fmt.Errorf("delay for '%s' can't be negative", r.URLPattern)
+	}
+	if r.MaxDelay != 0 && r.MaxDelay < r.Delay {
+		return fmt.Errorf("maximum delay for '%s' is shorter than its minimum delay", r.URLPattern)
+	}
+
+	switch r.Distribution {
+	case "":
+	case DelayFixed:
+		if r.MaxDelay != 0 {
+			return fmt.Errorf("fixed delay for '%s' can't have maximum delay", r.URLPattern)
+		}
+	case DelayUniform:
+		if r.MaxDelay == 0 {
+			return fmt.Errorf("uniform delay for '%s' needs maximum delay", r.URLPattern)
+		}
+	case DelayLogNormal:
+		if r.Median <= 0 || r.Mean <= 0 {
+			return fmt.Errorf("log-normal delay for '%s' needs positive median and mean", r.URLPattern)
+		}
+		if r.Mean < r.Median {
+			return fmt.Errorf("mean of log-normal delay for '%s' can't be lower than its median", r.URLPattern)
+		}
+		// median = e^mu, mean = e^(mu + sigma^2/2)
+		r.mu = math.Log(float64(r.Median))
+		r.sigma = math.Sqrt(2 * math.Log(float64(r.Mean)/float64(r.Median)))
+	default:
+		return fmt.Errorf("unknown delay distribution '%s', use %s, %s or %s", r.Distribution, DelayFixed,
+			DelayUniform, DelayLogNormal)
+	}
+	if r.Distribution != DelayLogNormal && (r.Median != 0 || r.Mean != 0) {
+		return fmt.Errorf("median and mean of delay for '%s' need %s distribution", r.URLPattern, DelayLogNormal)
+	}
+
+	r.urlRe = re
+	return nil
+}
+
+// matches - checks whether delay applies to given request
+func (r *ResponseDelay) matches(req *http.Request) bool {
+	if r.HTTPMethod != "" && !strings.EqualFold(r.HTTPMethod, req.Method) {
+		return false
+	}
+	return r.urlRe.MatchString(req.Host + req.URL.Path)
+}
+
+// duration - returns delay to wait before responding
+func (r *ResponseDelay) duration() time.Duration {
+	if r.Distribution == DelayLogNormal {
+		ms := math.Exp(r.mu + r.sigma*rand.NormFloat64())
+		ms = math.Max(ms, float64(r.Delay))
+		if r.MaxDelay != 0 {
+			ms = math.Min(ms, float64(r.MaxDelay))
+		}
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	ms := r.Delay
+	if r.MaxDelay > r.Delay {
+		ms += rand.Intn(r.MaxDelay - r.Delay + 1)
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// ResponseDelays - ordered delays applied to simulated responses, the first matching one is used
+type ResponseDelays struct {
+	mu     sync.RWMutex
+	delays []ResponseDelay
+}
+
+// NewResponseDelays - returns empty delay list
+func NewResponseDelays() *ResponseDelays {
+	return &ResponseDelays{}
+}
+
+// Set - validates and replaces all delays, current ones are kept when any of them is invalid
+func (d *ResponseDelays) Set(delays []ResponseDelay) error {
+	compiled := make([]ResponseDelay, len(delays))
+	for i, delay := range delays {
+		if err := delay.compile(); err != nil {
+			return err
+		}
+		compiled[i] = delay
+	}
+
+	d.mu.Lock()
+	d.delays = compiled
+	d.mu.Unlock()
+	return nil
+}
+
+// Get - returns current delays
+func (d *ResponseDelays) Get() []ResponseDelay {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]ResponseDelay{}, d.delays...)
+}
+
+// Clear - removes all delays
+func (d *ResponseDelays) Clear() {
+	d.mu.Lock()
+	d.delays = nil
+	d.mu.Unlock()
+}
+
+// For - returns delay for given request, zero when no delay matches
+func (d *ResponseDelays) For(req *http.Request) time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for i := range d.delays {
+		if d.delays[i].matches(req) {
+			return d.delays[i].duration()
+		}
+	}
+	return 0
+}
+
+// applyResponseDelay - waits before simulated response to given request is returned
+func (d *DBClient) applyResponseDelay(req *http.Request) {
+	if d.Delays == nil {
+		return
+	}
+	if delay := d.Delays.For(req); delay > 0 {
+		log.WithFields(log.Fields{
+			"delay":       delay.String(),
+			"path":        req.URL.Path,
+			"method":      req.Method,
+			"destination": req.Host,
+		}).Debug("Delaying response")
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,107 @@
+package hoverfly
+
+import (
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestResponseDelaysMatching(t *testing.T) {
+	delays := NewResponseDelays()
+	err := delays.Set([]ResponseDelay{
+		{URLPattern: `api\.example\.com/users`, HTTPMethod: "POST", Delay: 300},
+		{URLPattern: `api\.example\.com`, Delay: 100},
+	})
+	expect(t, err, nil)
+
+	post, _ := http.NewRequest("POST", "http://api.example.com/users", nil)
+	expect(t, delays.For(post), 300*time.Millisecond)
+
+	get, _ := http.NewRequest("get", "http://api.example.com/users", nil)
+	expect(t, delays.For(get), 100*time.Millisecond)
+
+	other, _ := http.NewRequest("GET", "http://other.example.com/users", nil)
+	expect(t, delays.For(other), time.Duration(0))
+}
+
+func TestResponseDelaysRandom(t *testing.T) {
+	delays := NewResponseDelays()
+	expect(t, delays.Set([]ResponseDelay{{URLPattern: ".", Delay: 10, MaxDelay: 20}}), nil)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/", nil)
+	for i := 0; i < 50; i++ {
+		delay := delays.For(req)
+		expect(t, delay >= 10*time.Millisecond && delay <= 20*time.Millisecond, true)
+	}
+}
+
+func TestResponseDelaysLogNormal(t *testing.T) {
+	delays := NewResponseDelays()
+	expect(t, delays.Set([]ResponseDelay{{URLPattern: ".", Distribution: DelayLogNormal, Median: 100, Mean: 150}}), nil)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/", nil)
+	samples := make([]float64, 5000)
+	var sum float64
+	for i := range samples {
+		samples[i] = float64(delays.For(req)) / float64(time.Millisecond)
+		sum += samples[i]
+	}
+	sort.Float64s(samples)
+
+	median := samples[len(samples)/2]
+	mean := sum / float64(len(samples))
+	expect(t, median > 90 && median < 110, true)
+	expect(t, mean > 135 && mean < 165, true)
+	// long tail
+	expect(t, samples[len(samples)*99/100] > 3*median, true)
+}
+
+func TestResponseDelaysLogNormalBounds(t *testing.T) {
+	delays := NewResponseDelays()
+	expect(t, delays.Set([]ResponseDelay{{URLPattern: ".", Distribution: DelayLogNormal, Delay: 80, MaxDelay: 120,
+		Median: 100, Mean: 200}}), nil)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/", nil)
+	for i := 0; i < 500; i++ {
+		delay := delays.For(req)
+		expect(t, delay >= 80*time.Millisecond && delay <= 120*time.Millisecond, true)
+	}
+}
+
+func TestResponseDelaysInvalid(t *testing.T) {
+	delays := NewResponseDelays()
+	expect(t, delays.Set([]ResponseDelay{{URLPattern: ".", Delay: 10}}), nil)
+
+	refute(t, delays.Set([]ResponseDelay{{URLPattern: "(", Delay: 10}}), nil)
+	refute(t, delays.Set([]ResponseDelay{{URLPattern: ".", Delay: -1}}), nil)
+	refute(t, delays.Set([]ResponseDelay{{URLPattern: ".", Delay: 20, MaxDelay: 10}}), nil)
+	refute(t, delays.Set([]ResponseDelay{{URLPattern: ".", Distribution: "gaussian"}}), nil)
+	refute(t, delays.Set([]ResponseDelay{{URLPattern: ".", Distribution: DelayFixed, Delay: 10, MaxDelay: 20}}), nil)
+	refute(t, delays.Set([]ResponseDelay{{URLPattern: ".", Distribution: DelayUniform, Delay: 10}}), nil)
+	refute(t, delays.Set([]ResponseDelay{{URLPattern: ".", Distribution: DelayLogNormal, Median: 100}}), nil)
+	refute(t, delays.Set([]ResponseDelay{{URLPattern: ".", Distribution: DelayLogNormal, Median: 100, Mean: 50}}), nil)
+	refute(t, delays.Set([]ResponseDelay{{URLPattern: ".", Delay: 10, Median: 100, Mean: 150}}), nil)
+
+	// current delays are kept
+	expect(t, len(delays.Get()), 1)
+
+	delays.Clear()
+	expect(t, len(delays.Get()), 0)
+}
+
+func TestVirtualizedResponseIsDelayed(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/slow", "", 200, "slow")
+	expect(t, dbClient.Delays.Set([]ResponseDelay{{URLPattern: "/slow$", Delay: 100}}), nil)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/slow", nil)
+	start := time.Now()
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 200)
+	expect(t, time.Since(start) >= 100*time.Millisecond, true)
+}
@@ -0,0 +1,248 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DiffMode - requests are sent to destination and live responses are compared against captured ones
+const DiffMode = "diff"
+
+// maxDiffEntries - how many discrepancies are kept in diff report, the oldest ones are dropped first
+const maxDiffEntries = 1000
+
+// FieldDiff - single difference between captured and live response
+type FieldDiff struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// ResponseDiff - differences found for single request
+type ResponseDiff struct {
+	Key         string      `json:"key"`
+	Destination string      `json:"destination"`
+	Path        string      `json:"path"`
+	Method      string      `json:"method"`
+	Query       string      `json:"query"`
+	Differences []FieldDiff `json:"differences"`
+	Time        time.Time   `json:"time"`
+}
+
+// DiffReport - keeps discrepancies found in diff mode
+type DiffReport struct {
+	mu      sync.Mutex
+	entries []ResponseDiff
+}
+
+// NewDiffReport - returns empty diff report
+func NewDiffReport() *DiffReport {
+	return &DiffReport{}
+}
+
+// Add - adds discrepancies to the report
+func (r *DiffReport) Add(diff ResponseDiff) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, diff)
+	if len(r.entries) > maxDiffEntries {
+		r.entries = r.entries[len(r.entries)-maxDiffEntries:]
+	}
+}
+
+// Entries - returns copy of all discrepancies, the oldest first
+func (r *DiffReport) Entries() []ResponseDiff {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]ResponseDiff, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+// Clear - removes all discrepancies from the report
+func (r *DiffReport) Clear() {
+	r.mu.Lock()
+	r.entries = nil
+	r.mu.Unlock()
+}
+
+// DiffOptions - parts of the responses that are not compared
+type DiffOptions struct {
+	IgnoreHeaders []string
+	IgnoreBody    bool
+}
+
+// CompareResponses - returns differences between expected (captured) and actual (live) responses. JSON bodies
+// are compared by value so formatting and key order don't matter, Content-Length header follows the body so it's
+// never compared on its own
+func CompareResponses(expected, actual ResponseDetails, opts DiffOptions) []FieldDiff {
+	var diffs []FieldDiff
+
+	if expected.Status != actual.Status {
+		diffs = append(diffs, FieldDiff{
+			Field:    "status",
+			Expected: strconv.Itoa(expected.Status),
+			Actual:   strconv.Itoa(actual.Status),
+		})
+	}
+
+	ignored := map[string]bool{"Content-Length": true}
+	for _, h := range opts.IgnoreHeaders {
+		ignored[http.CanonicalHeaderKey(h)] = true
+	}
+
+	expectedHeaders := canonicalHeaders(expected.Headers)
+	actualHeaders := canonicalHeaders(actual.Headers)
+
+	var names []string
+	for name := range expectedHeaders {
+		names = append(names, name)
+	}
+	for name := range actualHeaders {
+		if _, ok := expectedHeaders[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if ignored[name] {
+			continue
+		}
+		e, a := expectedHeaders[name], actualHeaders[name]
+		if e != a {
+			diffs = append(diffs, FieldDiff{
+				Field:    "header:" + name,
+				Expected: e,
+				Actual:   a,
+			})
+		}
+	}
+
+	if !opts.IgnoreBody && !equalBodies(expected.Body, actual.Body) {
+		diffs = append(diffs, FieldDiff{
+			Field:    "body",
+			Expected: expected.Body,
+			Actual:   actual.Body,
+		})
+	}
+
+	return diffs
+}
+
+// canonicalHeaders - joins header values and canonicalizes header names
+func canonicalHeaders(headers map[string][]string) map[string]string {
+	joined := make(map[string]string, len(headers))
+	for name, values := range headers {
+		joined[http.CanonicalHeaderKey(name)] = strings.Join(values, ", ")
+	}
+	return joined
+}
+
+// equalBodies - compares bodies by value when both of them are JSON, byte by byte otherwise
+func equalBodies(expected, actual string) bool {
+	if expected == actual {
+		return true
+	}
+
+	var e, a interface{}
+	if json.Unmarshal([]byte(expected), &e) != nil || json.Unmarshal([]byte(actual), &a) != nil {
+		return false
+	}
+	return reflect.DeepEqual(e, a)
+}
+
+// diffRequest - forwards request to destination and compares live response against captured one, discrepancies
+// are added to diff report. Live response is always returned to the client
+func (d *DBClient) diffRequest(req *http.Request) (*http.Response, error) {
+	if req.Body == nil {
+		req.Body = ioutil.NopCloser(bytes.NewBuffer([]byte("")))
+	}
+
+	reqBody, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"mode":  DiffMode,
+		}).Error("Got error when reading request body")
+	}
+
+	key := d.requestFingerprint(req, reqBody)
+	req.Body = ioutil.NopCloser(bytes.NewBuffer(reqBody))
+
+	resp, err := d.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadBts, err := d.Cache.Get([]byte(key))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"key":         key,
+			"path":        req.URL.Path,
+			"destination": req.Host,
+			"method":      req.Method,
+		}).Debug("Request not recorded, nothing to compare with")
+		return resp, nil
+	}
+
+	payload, err := decodePayload(payloadBts)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Error("Failed to decode payload")
+		return resp, nil
+	}
+
+	respBody, err := extractBody(resp)
+	if err != nil {
+		return resp, err
+	}
+
+	live := ResponseDetails{
+		Status:  resp.StatusCode,
+		Body:    string(respBody),
+		Headers: resp.Header,
+	}
+
+	diffs := CompareResponses(payload.Response, live, DiffOptions{
+		IgnoreHeaders: d.Cfg.DiffIgnoreHeaders,
+		IgnoreBody:    d.Cfg.DiffIgnoreBody,
+	})
+	if len(diffs) == 0 {
+		return resp, nil
+	}
+
+	log.WithFields(log.Fields{
+		"key":         key,
+		"path":        req.URL.Path,
+		"destination": req.Host,
+		"method":      req.Method,
+		"differences": len(diffs),
+	}).Warn("Live response differs from captured one")
+
+	d.Diffs.Add(ResponseDiff{
+		Key:         key,
+		Destination: req.Host,
+		Path:        req.URL.Path,
+		Method:      req.Method,
+		Query:       req.URL.RawQuery,
+		Differences: diffs,
+		Time:        time.Now(),
+	})
+
+	return resp, nil
+}
@@ -0,0 +1,148 @@
+package hoverfly
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCompareResponsesEqual(t *testing.T) {
+	resp := ResponseDetails{
+		Status:  200,
+		Body:    "body here",
+		Headers: map[string][]string{"Content-Type": []string{"text/plain"}},
+	}
+
+	diffs := CompareResponses(resp, resp, DiffOptions{})
+	expect(t, len(diffs), 0)
+}
+
+func TestCompareResponsesStatusAndHeaders(t *testing.T) {
+	expected := ResponseDetails{
+		Status: 200,
+		Headers: map[string][]string{
+			"content-type": []string{"application/json"},
+			"Date":         []string{"Mon, 01 Aug 2016 10:00:00 GMT"},
+		},
+	}
+	actual := ResponseDetails{
+		Status: 500,
+		Headers: map[string][]string{
+			"Content-Type": []string{"text/html"},
+			"Date":         []string{"Tue, 02 Aug 2016 10:00:00 GMT"},
+			"X-Server":     []string{"live"},
+		},
+	}
+
+	diffs := CompareResponses(expected, actual, DiffOptions{IgnoreHeaders: []string{"date"}})
+	expect(t, len(diffs), 3)
+
+	expect(t, diffs[0], FieldDiff{Field: "status", Expected: "200", Actual: "500"})
+	expect(t, diffs[1], FieldDiff{Field: "header:Content-Type", Expected: "application/json", Actual: "text/html"})
+	expect(t, diffs[2], FieldDiff{Field: "header:X-Server", Expected: "", Actual: "live"})
+}
+
+func TestCompareResponsesJSONBody(t *testing.T) {
+	expected := ResponseDetails{Status: 200, Body: `{"a": 1, "b": [1, 2]}`}
+	actual := ResponseDetails{Status: 200, Body: `{"b":[1,2],"a":1}`}
+
+	// formatting and key order don't matter
+	expect(t, len(CompareResponses(expected, actual, DiffOptions{})), 0)
+
+	actual.Body = `{"b":[2,1],"a":1}`
+	diffs := CompareResponses(expected, actual, DiffOptions{})
+	expect(t, len(diffs), 1)
+	expect(t, diffs[0].Field, "body")
+
+	expect(t, len(CompareResponses(expected, actual, DiffOptions{IgnoreBody: true})), 0)
+}
+
+func TestDiffReportLimit(t *testing.T) {
+	report := NewDiffReport()
+	for i := 0; i < maxDiffEntries+10; i++ {
+		report.Add(ResponseDiff{Key: string(rune('a' + i%26))})
+	}
+
+	entries := report.Entries()
+	expect(t, len(entries), maxDiffEntries)
+	// the oldest entries are dropped
+	expect(t, entries[0].Key, "k")
+
+	report.Clear()
+	expect(t, len(report.Entries()), 0)
+}
+
+func TestProcessDiffRequest(t *testing.T) {
+	server, dbClient := testTools(201, `{"message": "here"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	r, err := http.NewRequest("GET", "http://somehost.com/path", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode("capture")
+	dbClient.processRequest(r)
+
+	// live service now responds differently
+	changedServer, changedClient := testTools(500, `{"message": "changed"}`)
+	defer changedServer.Close()
+	defer changedClient.Cache.DeleteData()
+	dbClient.HTTP = changedClient.HTTP
+
+	r, err = http.NewRequest("GET", "http://somehost.com/path", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode("diff")
+	_, resp := dbClient.processRequest(r)
+
+	// live response is returned
+	expect(t, resp.StatusCode, 500)
+
+	entries := dbClient.Diffs.Entries()
+	expect(t, len(entries), 1)
+	expect(t, entries[0].Path, "/path")
+	expect(t, entries[0].Destination, "somehost.com")
+	expect(t, len(entries[0].Differences), 2)
+	expect(t, entries[0].Differences[0].Field, "status")
+	expect(t, entries[0].Differences[1].Field, "body")
+}
+
+func TestProcessDiffRequestNoDifferences(t *testing.T) {
+	server, dbClient := testTools(201, `{"message": "here"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	r, err := http.NewRequest("GET", "http://somehost.com/path", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode("capture")
+	dbClient.processRequest(r)
+
+	r, err = http.NewRequest("GET", "http://somehost.com/path", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode("diff")
+	_, resp := dbClient.processRequest(r)
+
+	expect(t, resp.StatusCode, 201)
+	expect(t, len(dbClient.Diffs.Entries()), 0)
+}
+
+func TestProcessDiffRequestNotRecorded(t *testing.T) {
+	server, dbClient := testTools(201, `{"message": "here"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	r, err := http.NewRequest("GET", "http://somehost.com/path", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode("diff")
+	_, resp := dbClient.processRequest(r)
+
+	// nothing to compare with, request is just passed through
+	expect(t, resp.StatusCode, 201)
+	expect(t, len(dbClient.Diffs.Entries()), 0)
+
+	count, err := dbClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 0)
+}
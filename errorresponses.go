@@ -0,0 +1,158 @@
+package hoverfly
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/elazarl/goproxy"
+)
+
+// defaultErrorStatus - status code of error responses that don't set their own
+const defaultErrorStatus = http.StatusServiceUnavailable
+
+// ErrorResponse - alternate response returned to given percentage of matching requests instead of the usual one,
+// i.e. 503 with Retry-After header to simulate flaky dependency
+type ErrorResponse struct {
+	// URLPattern - regular expression matched against request host and path, i.e. "api\.example\.com/users"
+	URLPattern string `json:"urlPattern"`
+	// HTTPMethod - method of matching requests, all methods match when empty
+	HTTPMethod string `json:"httpMethod,omitempty"`
+	// Percentage - chance of matching request getting error response, more than 0 and up to 100
+	Percentage float64 `json:"percentage"`
+
+	// Status - status code of error response, 503 when not set
+	Status  int                 `json:"status,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+
+	urlRe *regexp.Regexp
+}
+
+// compile - validates error response and compiles its URL pattern
+func (e *ErrorResponse) compile() error {
+	re, err := regexp.Compile(e.URLPattern)
+	if err != nil {
+		return fmt.Errorf("invalid URL pattern '%s': %s", e.URLPattern, err.Error())
+	}
+	if e.Percentage <= 0 || e.Percentage > 100 {
+		return fmt.Errorf("percentage of error response for '%s' has to be more than 0 and up to 100", e.URLPattern)
+	}
+	if e.Status == 0 {
+		e.Status = defaultErrorStatus
+	}
+	if e.Status < 100 || e.Status > 999 {
+		return fmt.Errorf("invalid status code %d of error response for '%s'", e.Status, e.URLPattern)
+	}
+	e.urlRe = re
+	return nil
+}
+
+// matches - checks whether error response applies to given request
+func (e *ErrorResponse) matches(req *http.Request) bool {
+	if e.HTTPMethod != "" && !strings.EqualFold(e.HTTPMethod, req.Method) {
+		return false
+	}
+	return e.urlRe.MatchString(req.Host + req.URL.Path)
+}
+
+// response - builds error response to given request
+func (e *ErrorResponse) response(req *http.Request) *http.Response {
+	resp := goproxy.NewResponse(req, goproxy.ContentTypeText, e.Status, e.Body)
+	for name, values := range e.Headers {
+		resp.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	return resp
+}
+
+// ErrorResponses - ordered error responses, only the first matching one is used. Requests are picked randomly,
+// when seed is set the same requests get error responses every time, so scenarios are reproducible
+type ErrorResponses struct {
+	mu        sync.Mutex
+	responses []ErrorResponse
+	seed      int64
+	rnd       *rand.Rand
+}
+
+// NewErrorResponses - returns empty error response list
+func NewErrorResponses() *ErrorResponses {
+	return &ErrorResponses{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Set - validates and replaces all error responses, current ones are kept when any of them is invalid. Random
+// generator is reset with given seed, 0 means random seed
+func (e *ErrorResponses) Set(responses []ErrorResponse, seed int64) error {
+	compiled := make([]ErrorResponse, len(responses))
+	for i, response := range responses {
+		if err := response.compile(); err != nil {
+			return err
+		}
+		compiled[i] = response
+	}
+
+	source := seed
+	if source == 0 {
+		source = time.Now().UnixNano()
+	}
+
+	e.mu.Lock()
+	e.responses = compiled
+	e.seed = seed
+	e.rnd = rand.New(rand.NewSource(source))
+	e.mu.Unlock()
+	return nil
+}
+
+// Get - returns current error responses and seed
+func (e *ErrorResponses) Get() ([]ErrorResponse, int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]ErrorResponse{}, e.responses...), e.seed
+}
+
+// Clear - removes all error responses
+func (e *ErrorResponses) Clear() {
+	e.mu.Lock()
+	e.responses = nil
+	e.seed = 0
+	e.mu.Unlock()
+}
+
+// For - returns error response to given request, false when usual response should be returned
+func (e *ErrorResponses) For(req *http.Request) (*http.Response, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := range e.responses {
+		if e.responses[i].matches(req) {
+			if e.rnd.Float64()*100 < e.responses[i].Percentage {
+				return e.responses[i].response(req), true
+			}
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// errorResponse - returns error response configured for given request, false when there is none
+func (d *DBClient) errorResponse(req *http.Request) (*http.Response, bool) {
+	if d.ErrorResponses == nil {
+		return nil, false
+	}
+
+	resp, ok := d.ErrorResponses.For(req)
+	if ok {
+		log.WithFields(log.Fields{
+			"status":      resp.StatusCode,
+			"path":        req.URL.Path,
+			"method":      req.Method,
+			"destination": req.Host,
+		}).Info("Returning error response")
+	}
+	return resp, ok
+}
@@ -0,0 +1,81 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestErrorResponsesSeed(t *testing.T) {
+	responses := NewErrorResponses()
+	req, _ := http.NewRequest("GET", "http://api.example.com/flaky", nil)
+
+	sequence := func() []bool {
+		expect(t, responses.Set([]ErrorResponse{{URLPattern: "/flaky$", Percentage: 50}}, 42), nil)
+		var failed []bool
+		for i := 0; i < 20; i++ {
+			_, ok := responses.For(req)
+			failed = append(failed, ok)
+		}
+		return failed
+	}
+
+	first, second := sequence(), sequence()
+	count := 0
+	for i := range first {
+		expect(t, first[i], second[i])
+		if first[i] {
+			count++
+		}
+	}
+	expect(t, count > 0 && count < 20, true)
+
+	_, seed := responses.Get()
+	expect(t, seed, int64(42))
+}
+
+func TestErrorResponseReturned(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	err := dbClient.ErrorResponses.Set([]ErrorResponse{
+		{URLPattern: "/orders", HTTPMethod: "POST", Percentage: 100, Headers: map[string][]string{"retry-after": {"120"}},
+			Body: "try again later"},
+	}, 0)
+	expect(t, err, nil)
+
+	req, _ := http.NewRequest("POST", "http://api.example.com/orders", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+	expect(t, resp.Header.Get("Retry-After"), "120")
+	body, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	expect(t, string(body), "try again later")
+
+	// other methods get the usual response, there is none captured
+	req, _ = http.NewRequest("GET", "http://api.example.com/orders", nil)
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+	body, _ = ioutil.ReadAll(resp.Body)
+	refute(t, string(body), "try again later")
+}
+
+func TestErrorResponsesInvalid(t *testing.T) {
+	responses := NewErrorResponses()
+	expect(t, responses.Set([]ErrorResponse{{URLPattern: ".", Percentage: 10, Status: 500}}, 1), nil)
+
+	refute(t, responses.Set([]ErrorResponse{{URLPattern: "(", Percentage: 10}}, 0), nil)
+	refute(t, responses.Set([]ErrorResponse{{URLPattern: "."}}, 0), nil)
+	refute(t, responses.Set([]ErrorResponse{{URLPattern: ".", Percentage: 10, Status: 42}}, 0), nil)
+
+	// current error responses are kept
+	current, seed := responses.Get()
+	expect(t, len(current), 1)
+	expect(t, current[0].Status, 500)
+	expect(t, seed, int64(1))
+
+	responses.Clear()
+	current, _ = responses.Get()
+	expect(t, len(current), 0)
+}
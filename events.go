@@ -0,0 +1,106 @@
+package hoverfly
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ActionTypeRecordDeleted - default action type for identifying deleted records
+const ActionTypeRecordDeleted = "recordDeleted"
+
+// eventsBufferSize - how many events can wait for slow subscriber before new ones are dropped
+const eventsBufferSize = 100
+
+// CacheEvent - describes captured, imported or deleted record
+type CacheEvent struct {
+	ActionType  ActionType `json:"actionType"`
+	Message     string     `json:"message"`
+	Key         string     `json:"key,omitempty"`
+	Destination string     `json:"destination,omitempty"`
+	Path        string     `json:"path,omitempty"`
+	Method      string     `json:"method,omitempty"`
+	Time        time.Time  `json:"time"`
+}
+
+// CacheEvents - hook that passes cache changes to subscribers, subscribers that don't keep up miss
+// events instead of slowing the proxy down
+type CacheEvents struct {
+	mu          sync.Mutex
+	subscribers map[chan CacheEvent]bool
+}
+
+// NewCacheEvents - returns CacheEvents without subscribers
+func NewCacheEvents() *CacheEvents {
+	return &CacheEvents{
+		subscribers: make(map[chan CacheEvent]bool),
+	}
+}
+
+// Subscribe - returns channel with cache events, returned function has to be called once events are
+// not needed anymore
+func (e *CacheEvents) Subscribe() (<-chan CacheEvent, func()) {
+	ch := make(chan CacheEvent, eventsBufferSize)
+
+	e.mu.Lock()
+	e.subscribers[ch] = true
+	e.mu.Unlock()
+
+	unsubscribe := func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.subscribers[ch] {
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// Publish - sends event to all subscribers without blocking
+func (e *CacheEvents) Publish(ev CacheEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for ch := range e.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			log.WithFields(log.Fields{
+				"actionType": ev.ActionType,
+				"key":        ev.Key,
+			}).Debug("Subscriber is too slow, dropping cache event")
+		}
+	}
+}
+
+// ActionTypes - cache changing actions
+func (e *CacheEvents) ActionTypes() []ActionType {
+	return []ActionType{ActionTypeRequestCaptured, ActionTypeRecordDeleted, ActionTypeWipeDB}
+}
+
+// Fire - converts hook entry to cache event and publishes it. Captured requests carry encoded
+// payload, deleted records carry their key
+func (e *CacheEvents) Fire(entry *Entry) error {
+	ev := CacheEvent{
+		ActionType: entry.ActionType,
+		Message:    entry.Message,
+		Time:       entry.Time,
+	}
+
+	switch entry.ActionType {
+	case ActionTypeRequestCaptured:
+		if pl, err := decodePayload(entry.Data); err == nil {
+			ev.Key = pl.ID
+			ev.Destination = pl.Request.Destination
+			ev.Path = pl.Request.Path
+			ev.Method = pl.Request.Method
+		}
+	case ActionTypeRecordDeleted:
+		ev.Key = string(entry.Data)
+	}
+
+	e.Publish(ev)
+	return nil
+}
@@ -0,0 +1,109 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// nextEvent - returns next event or fails test when nothing arrives in time
+func nextEvent(t *testing.T, events <-chan CacheEvent) CacheEvent {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("cache event was not published")
+	}
+	return CacheEvent{}
+}
+
+func TestCacheEventsCapture(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	events, unsubscribe := dbClient.Events.Subscribe()
+	defer unsubscribe()
+
+	req, err := http.NewRequest("GET", "http://example.com/path", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	ev := nextEvent(t, events)
+	expect(t, ev.ActionType, ActionType(ActionTypeRequestCaptured))
+	expect(t, ev.Destination, "example.com")
+	expect(t, ev.Path, "/path")
+	refute(t, ev.Key, "")
+}
+
+func TestCacheEventsDeleteRecord(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	dbClient.Cache.Set([]byte("key"), []byte("value"))
+
+	events, unsubscribe := dbClient.Events.Subscribe()
+	defer unsubscribe()
+
+	m := getBoneRouter(*dbClient)
+	req, err := http.NewRequest("DELETE", "/records/key", nil)
+	expect(t, err, nil)
+	m.ServeHTTP(httptest.NewRecorder(), req)
+
+	ev := nextEvent(t, events)
+	expect(t, ev.ActionType, ActionType(ActionTypeRecordDeleted))
+	expect(t, ev.Key, "key")
+}
+
+func TestCacheEventsUnsubscribe(t *testing.T) {
+	e := NewCacheEvents()
+	events, unsubscribe := e.Subscribe()
+	unsubscribe()
+	// calling it again is harmless
+	unsubscribe()
+
+	e.Publish(CacheEvent{ActionType: ActionTypeWipeDB})
+
+	_, ok := <-events
+	expect(t, ok, false)
+}
+
+func TestRecordsWSHandler(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	admin := httptest.NewServer(getBoneRouter(*dbClient))
+	defer admin.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(admin.URL, "http")+"/recordsws", nil)
+	expect(t, err, nil)
+	defer conn.Close()
+
+	// waiting for handler to subscribe
+	for i := 0; i < 100; i++ {
+		dbClient.Events.mu.Lock()
+		subscribed := len(dbClient.Events.subscribers) > 0
+		dbClient.Events.mu.Unlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	req, err := http.NewRequest("GET", "http://example.com/path", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var ev CacheEvent
+	err = conn.ReadJSON(&ev)
+	expect(t, err, nil)
+	expect(t, ev.ActionType, ActionType(ActionTypeRequestCaptured))
+	expect(t, ev.Destination, "example.com")
+}
@@ -0,0 +1,289 @@
+package hoverfly
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Faults injected into responses
+const (
+	// FaultReset - connection is aborted with TCP reset
+	FaultReset = "reset"
+	// FaultEmptyReply - connection is closed without any response
+	FaultEmptyReply = "empty"
+	// FaultTruncated - response is cut in the middle of its body, connection is closed
+	FaultTruncated = "truncated"
+	// FaultMalformed - invalid HTTP response is written, connection is closed
+	FaultMalformed = "malformed"
+	// FaultTimeout - no response is written until Timeout passes (or client gives up), connection is then closed
+	FaultTimeout = "timeout"
+)
+
+// malformedResponse - written to clients instead of response when FaultMalformed is injected
+const malformedResponse = "HTTP/1.1 OK 200\r\nContent-Length: -1\r\nTransfer-Encoding: gzip, chunked\r\n\r\n\x00\xff\xfeZZZ\r\n"
+
+// Fault - failure injected into responses to given percentage of matching requests. Every n-th matching request
+// fails, so results are repeatable, i.e. with 25 percent the fourth, eighth, twelfth... request fails
+type Fault struct {
+	// URLPattern - regular expression matched against request host and path, i.e. "api\.example\.com/users"
+	URLPattern string `json:"urlPattern"`
+	// HTTPMethod - method of matching requests, all methods match when empty
+	HTTPMethod string `json:"httpMethod,omitempty"`
+	// Type - one of FaultReset, FaultEmptyReply, FaultTruncated, FaultMalformed or FaultTimeout
+	Type string `json:"type"`
+	// Percentage - share of matching requests that fail, more than 0 and up to 100
+	Percentage float64 `json:"percentage"`
+	// Timeout - milliseconds to hold connection with FaultTimeout, until client disconnects when not set
+	Timeout int `json:"timeout,omitempty"`
+
+	urlRe *regexp.Regexp
+	// matched - number of requests that matched so far
+	matched uint64
+}
+
+// compile - validates fault and compiles its URL pattern
+func (f *Fault) compile() error {
+	re, err := regexp.Compile(f.URLPattern)
+	if err != nil {
+		return fmt.Errorf("invalid URL pattern '%s': %s", f.URLPattern, err.Error())
+	}
+
+	switch f.Type {
+	case FaultReset, FaultEmptyReply, FaultTruncated, FaultMalformed, FaultTimeout:
+	default:
+		return fmt.Errorf("unknown fault type '%s', use %s, %s, %s, %s or %s", f.Type, FaultReset,
+			FaultEmptyReply, FaultTruncated, FaultMalformed, FaultTimeout)
+	}
+	if f.Percentage <= 0 || f.Percentage > 100 {
+		return fmt.Errorf("percentage of fault for '%s' has to be more than 0 and up to 100", f.URLPattern)
+	}
+	if f.Timeout < 0 {
+		return fmt.Errorf("timeout of fault for '%s' can't be negative", f.URLPattern)
+	}
+
+	f.urlRe = re
+	f.matched = 0
+	return nil
+}
+
+// matches - checks whether fault applies to given request
+func (f *Fault) matches(req *http.Request) bool {
+	if f.HTTPMethod != "" && !strings.EqualFold(f.HTTPMethod, req.Method) {
+		return false
+	}
+	return f.urlRe.MatchString(req.Host + req.URL.Path)
+}
+
+// next - counts matching request, returns true when it should fail
+func (f *Fault) next() bool {
+	n := float64(f.matched)
+	f.matched++
+	return math.Floor((n+1)*f.Percentage/100) > math.Floor(n*f.Percentage/100)
+}
+
+// Faults - ordered faults injected into responses, only the first matching one is used
+type Faults struct {
+	mu     sync.Mutex
+	faults []Fault
+}
+
+// NewFaults - returns empty fault list
+func NewFaults() *Faults {
+	return &Faults{}
+}
+
+// Set - validates and replaces all faults, current ones are kept when any of them is invalid
+func (f *Faults) Set(faults []Fault) error {
+	compiled := make([]Fault, len(faults))
+	for i, fault := range faults {
+		if err := fault.compile(); err != nil {
+			return err
+		}
+		compiled[i] = fault
+	}
+
+	f.mu.Lock()
+	f.faults = compiled
+	f.mu.Unlock()
+	return nil
+}
+
+// Get - returns current faults
+func (f *Faults) Get() []Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Fault{}, f.faults...)
+}
+
+// Clear - removes all faults
+func (f *Faults) Clear() {
+	f.mu.Lock()
+	f.faults = nil
+	f.mu.Unlock()
+}
+
+// For - returns fault to inject into response to given request, false when request shouldn't fail
+func (f *Faults) For(req *http.Request) (Fault, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.faults {
+		if f.faults[i].matches(req) {
+			return f.faults[i], f.faults[i].next()
+		}
+	}
+	return Fault{}, false
+}
+
+// FaultHandler - wraps proxy so that configured faults are injected instead of responses, CONNECT requests are
+// passed on untouched
+func (d *DBClient) FaultHandler(proxy http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if d.Faults == nil || req.Method == "CONNECT" {
+			proxy.ServeHTTP(w, req)
+			return
+		}
+
+		fault, ok := d.Faults.For(req)
+		if !ok {
+			proxy.ServeHTTP(w, req)
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"fault":       fault.Type,
+			"path":        req.URL.Path,
+			"method":      req.Method,
+			"destination": req.Host,
+		}).Info("Injecting fault")
+
+		injectFault(w, req, fault, proxy)
+	})
+}
+
+// injectFault - writes fault to the client. Connections of HTTP/1 requests are hijacked, HTTP/2 streams are
+// reset instead as their connection is shared with other requests
+func injectFault(w http.ResponseWriter, req *http.Request, fault Fault, proxy http.Handler) {
+	var response *bufferedResponse
+	if fault.Type == FaultTruncated {
+		response = newBufferedResponse()
+		proxy.ServeHTTP(response, req)
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		abortStream(w, req, fault, response)
+		return
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to hijack connection to inject fault")
+		return
+	}
+	defer conn.Close()
+
+	switch fault.Type {
+	case FaultReset:
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.SetLinger(0)
+		}
+	case FaultTruncated:
+		response.writeTruncated(buf.Writer)
+		buf.Flush()
+	case FaultMalformed:
+		buf.WriteString(malformedResponse)
+		buf.Flush()
+	case FaultTimeout:
+		if fault.Timeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(time.Duration(fault.Timeout) * time.Millisecond))
+		}
+		// waiting until client closes connection or deadline passes
+		buf.Reader.WriteTo(ioutil.Discard)
+	}
+}
+
+// abortStream - injects fault into HTTP/2 response, all faults apart from truncated bodies and timeouts simply
+// reset the stream
+func abortStream(w http.ResponseWriter, req *http.Request, fault Fault, response *bufferedResponse) {
+	switch fault.Type {
+	case FaultTruncated:
+		for name, values := range response.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(response.body.Len()))
+		w.WriteHeader(response.status())
+		w.Write(response.body.Bytes()[:response.body.Len()/2])
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	case FaultTimeout:
+		var timeout <-chan time.Time
+		if fault.Timeout > 0 {
+			timeout = time.After(time.Duration(fault.Timeout) * time.Millisecond)
+		}
+		select {
+		case <-req.Context().Done():
+		case <-timeout:
+		}
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// bufferedResponse - response writer keeping whole response in memory
+type bufferedResponse struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header)}
+}
+
+func (r *bufferedResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *bufferedResponse) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *bufferedResponse) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(p)
+}
+
+// status - returns response status code
+func (r *bufferedResponse) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
+
+// writeTruncated - writes response announcing full body length, followed by only half of the body
+func (r *bufferedResponse) writeTruncated(w *bufio.Writer) {
+	fmt.Fprintf(w, "HTTP/1.1 %d %s\r\n", r.status(), http.StatusText(r.status()))
+	r.header.Del("Transfer-Encoding")
+	r.header.Set("Content-Length", strconv.Itoa(r.body.Len()))
+	r.header.Write(w)
+	w.WriteString("\r\n")
+	w.Write(r.body.Bytes()[:r.body.Len()/2])
+}
@@ -0,0 +1,129 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// faultServer - server greeting its clients, with given fault injected
+func faultServer(t *testing.T, fault Fault) *httptest.Server {
+	server, dbClient := testTools(200, `{}`)
+	server.Close()
+	expect(t, dbClient.Faults.Set([]Fault{fault}), nil)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello world"))
+	})
+	return httptest.NewServer(NewHTTP2Handler(dbClient.FaultHandler(handler)))
+}
+
+func TestFaultsPercentage(t *testing.T) {
+	faults := NewFaults()
+	expect(t, faults.Set([]Fault{{URLPattern: "/flaky$", Type: FaultReset, Percentage: 25}}), nil)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/flaky", nil)
+	var failed []int
+	for i := 1; i <= 12; i++ {
+		if _, ok := faults.For(req); ok {
+			failed = append(failed, i)
+		}
+	}
+	expect(t, len(failed), 3)
+	expect(t, failed[0], 4)
+	expect(t, failed[1], 8)
+	expect(t, failed[2], 12)
+
+	other, _ := http.NewRequest("GET", "http://api.example.com/stable", nil)
+	_, ok := faults.For(other)
+	expect(t, ok, false)
+
+	// counting starts again when faults are set
+	expect(t, faults.Set([]Fault{{URLPattern: ".", Type: FaultReset, Percentage: 50}}), nil)
+	_, ok = faults.For(req)
+	expect(t, ok, false)
+	_, ok = faults.For(req)
+	expect(t, ok, true)
+}
+
+func TestFaultsInvalid(t *testing.T) {
+	faults := NewFaults()
+	expect(t, faults.Set([]Fault{{URLPattern: ".", Type: FaultEmptyReply, Percentage: 100}}), nil)
+
+	refute(t, faults.Set([]Fault{{URLPattern: "(", Type: FaultReset, Percentage: 100}}), nil)
+	refute(t, faults.Set([]Fault{{URLPattern: ".", Type: "explode", Percentage: 100}}), nil)
+	refute(t, faults.Set([]Fault{{URLPattern: ".", Type: FaultReset}}), nil)
+	refute(t, faults.Set([]Fault{{URLPattern: ".", Type: FaultReset, Percentage: 101}}), nil)
+	refute(t, faults.Set([]Fault{{URLPattern: ".", Type: FaultTimeout, Percentage: 100, Timeout: -1}}), nil)
+
+	// current faults are kept
+	expect(t, len(faults.Get()), 1)
+
+	faults.Clear()
+	expect(t, len(faults.Get()), 0)
+}
+
+func TestFaultsBreakHTTPRequests(t *testing.T) {
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	for _, fault := range []string{FaultReset, FaultEmptyReply, FaultMalformed} {
+		server := faultServer(t, Fault{URLPattern: ".", Type: fault, Percentage: 100})
+		_, err := client.Get(server.URL)
+		refute(t, err, nil)
+		server.Close()
+	}
+}
+
+func TestFaultTruncatedBody(t *testing.T) {
+	server := faultServer(t, Fault{URLPattern: ".", Type: FaultTruncated, Percentage: 100})
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	expect(t, err, nil)
+	defer resp.Body.Close()
+	expect(t, resp.StatusCode, 200)
+	expect(t, resp.ContentLength, int64(len("hello world")))
+
+	body, err := ioutil.ReadAll(resp.Body)
+	refute(t, err, nil)
+	expect(t, string(body), "hello")
+}
+
+func TestFaultTimeout(t *testing.T) {
+	server := faultServer(t, Fault{URLPattern: ".", Type: FaultTimeout, Percentage: 100, Timeout: 100})
+	defer server.Close()
+
+	start := time.Now()
+	_, err := http.Get(server.URL)
+	refute(t, err, nil)
+	expect(t, time.Since(start) >= 100*time.Millisecond, true)
+
+	// without timeout connection is held until client gives up
+	server = faultServer(t, Fault{URLPattern: ".", Type: FaultTimeout, Percentage: 100})
+	defer server.Close()
+
+	client := &http.Client{Timeout: 100 * time.Millisecond}
+	_, err = client.Get(server.URL)
+	refute(t, err, nil)
+}
+
+func TestFaultsResetHTTP2Streams(t *testing.T) {
+	server := faultServer(t, Fault{URLPattern: ".", Type: FaultReset, Percentage: 50})
+	defer server.Close()
+	client := h2cClient(server)
+
+	_, err := client.Get(server.URL + "/first")
+	expect(t, err, nil)
+
+	_, err = client.Get(server.URL + "/second")
+	refute(t, err, nil)
+
+	// connection is still usable
+	resp, err := client.Get(server.URL + "/third")
+	expect(t, err, nil)
+	body, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	expect(t, string(body), "hello world")
+}
@@ -0,0 +1,95 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PayloadFilter - describes which payloads should be selected, empty fields match everything. Destination has
+// to be equal to request destination, Path is matched as a prefix and Method is compared case-insensitively.
+// From and To select records captured in [From, To) window, records without capture time don't match it.
+// BodyContains has to be part of request body or body of any of the responses
+type PayloadFilter struct {
+	Destination  string    `json:"destination"`
+	Path         string    `json:"path"`
+	Method       string    `json:"method"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	BodyContains string    `json:"bodyContains"`
+}
+
+// NewPayloadFilter - returns filter based on query parameters (destination, path, method, from, to,
+// body-contains), times are given in RFC3339 format (i.e. 2017-01-02T15:04:05Z)
+func NewPayloadFilter(query url.Values) (PayloadFilter, error) {
+	filter := PayloadFilter{
+		Destination:  query.Get("destination"),
+		Path:         query.Get("path"),
+		Method:       query.Get("method"),
+		BodyContains: query.Get("body-contains"),
+	}
+
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return filter, fmt.Errorf("invalid 'from' time '%s', expected RFC3339 format (i.e. 2017-01-02T15:04:05Z)", from)
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return filter, fmt.Errorf("invalid 'to' time '%s', expected RFC3339 format (i.e. 2017-01-02T15:04:05Z)", to)
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, fmt.Errorf("'from' time has to be before 'to' time")
+	}
+	return filter, nil
+}
+
+// IsEmpty - checks whether filter has no conditions and would match every payload
+func (f *PayloadFilter) IsEmpty() bool {
+	return f.Destination == "" && f.Path == "" && f.Method == "" && f.From.IsZero() && f.To.IsZero() &&
+		f.BodyContains == ""
+}
+
+// Match - checks whether payload request satisfies all filter conditions
+func (f *PayloadFilter) Match(p *Payload) bool {
+	if f.Destination != "" && f.Destination != p.Request.Destination {
+		return false
+	}
+	if f.Path != "" && !strings.HasPrefix(p.Request.Path, f.Path) {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(f.Method, p.Request.Method) {
+		return false
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		if p.CapturedAt == nil {
+			return false
+		}
+		if !f.From.IsZero() && p.CapturedAt.Before(f.From) {
+			return false
+		}
+		if !f.To.IsZero() && !p.CapturedAt.Before(f.To) {
+			return false
+		}
+	}
+	if f.BodyContains != "" && !bodyContains(p, f.BodyContains) {
+		return false
+	}
+	return true
+}
+
+// bodyContains - checks whether request body or body of any of the responses contains given text
+func bodyContains(p *Payload, text string) bool {
+	if strings.Contains(p.Request.Body, text) || strings.Contains(p.Response.Body, text) {
+		return true
+	}
+	for _, response := range p.Sequence {
+		if strings.Contains(response.Body, text) {
+			return true
+		}
+	}
+	return false
+}
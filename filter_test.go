@@ -0,0 +1,92 @@
+package hoverfly
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPayloadFilterMatch(t *testing.T) {
+	payload := Payload{Request: RequestDetails{Destination: "example.com", Path: "/api/users/1", Method: "GET"}}
+
+	filter := PayloadFilter{Destination: "example.com"}
+	expect(t, filter.Match(&payload), true)
+
+	filter = PayloadFilter{Destination: "example.com", Path: "/api/users", Method: "get"}
+	expect(t, filter.Match(&payload), true)
+
+	filter = PayloadFilter{Destination: "other.com"}
+	expect(t, filter.Match(&payload), false)
+
+	filter = PayloadFilter{Path: "/api/orders"}
+	expect(t, filter.Match(&payload), false)
+
+	filter = PayloadFilter{Method: "POST"}
+	expect(t, filter.Match(&payload), false)
+}
+
+func TestPayloadFilterEmpty(t *testing.T) {
+	filter, err := NewPayloadFilter(url.Values{})
+	expect(t, err, nil)
+	expect(t, filter.IsEmpty(), true)
+
+	filter, err = NewPayloadFilter(url.Values{"method": []string{"GET"}})
+	expect(t, err, nil)
+	expect(t, filter.IsEmpty(), false)
+	expect(t, filter.Method, "GET")
+}
+
+func TestPayloadFilterCaptureWindow(t *testing.T) {
+	capturedAt := time.Date(2017, 1, 2, 15, 0, 0, 0, time.UTC)
+	payload := Payload{Request: RequestDetails{Destination: "example.com"}, CapturedAt: &capturedAt}
+
+	filter := PayloadFilter{From: capturedAt.Add(-time.Hour), To: capturedAt.Add(time.Hour)}
+	expect(t, filter.Match(&payload), true)
+
+	// window includes its start but not its end
+	filter = PayloadFilter{From: capturedAt}
+	expect(t, filter.Match(&payload), true)
+	filter = PayloadFilter{To: capturedAt}
+	expect(t, filter.Match(&payload), false)
+
+	filter = PayloadFilter{From: capturedAt.Add(time.Minute)}
+	expect(t, filter.Match(&payload), false)
+
+	// records written by hand have no capture time
+	filter = PayloadFilter{From: capturedAt.Add(-time.Hour)}
+	expect(t, filter.Match(&Payload{}), false)
+}
+
+func TestNewPayloadFilterTimes(t *testing.T) {
+	filter, err := NewPayloadFilter(url.Values{"from": []string{"2017-01-02T15:04:05Z"}, "to": []string{"2017-01-03T00:00:00+01:00"}})
+	expect(t, err, nil)
+	expect(t, filter.IsEmpty(), false)
+	expect(t, filter.From.Equal(time.Date(2017, 1, 2, 15, 4, 5, 0, time.UTC)), true)
+	expect(t, filter.To.Equal(time.Date(2017, 1, 2, 23, 0, 0, 0, time.UTC)), true)
+
+	_, err = NewPayloadFilter(url.Values{"from": []string{"yesterday"}})
+	refute(t, err, nil)
+
+	_, err = NewPayloadFilter(url.Values{"from": []string{"2017-01-03T00:00:00Z"}, "to": []string{"2017-01-02T00:00:00Z"}})
+	refute(t, err, nil)
+}
+
+func TestPayloadFilterBodyContains(t *testing.T) {
+	payload := Payload{
+		Request:  RequestDetails{Body: `{"user": "alice"}`},
+		Response: ResponseDetails{Body: "created"},
+		Sequence: []ResponseDetails{{Body: "already exists"}},
+	}
+
+	for _, text := range []string{"alice", "created", "exists"} {
+		filter := PayloadFilter{BodyContains: text}
+		expect(t, filter.Match(&payload), true)
+	}
+	filter := PayloadFilter{BodyContains: "bob"}
+	expect(t, filter.Match(&payload), false)
+
+	filter, err := NewPayloadFilter(url.Values{"body-contains": []string{"alice"}})
+	expect(t, err, nil)
+	expect(t, filter.IsEmpty(), false)
+	expect(t, filter.BodyContains, "alice")
+}
@@ -0,0 +1,34 @@
+package hoverfly
+
+import (
+	"net/url"
+	"strings"
+)
+
+// canonicalForm - returns form encoded body with sorted fields and without ignored ones, values of repeated fields
+// keep their order. Field names ending with "*" are prefixes, like ignored query parameters
+func canonicalForm(body string, ignored []string) (string, error) {
+	fields, err := url.ParseQuery(body)
+	if err != nil {
+		return "", err
+	}
+	for name := range fields {
+		if isIgnoredQueryParam(name, ignored) {
+			delete(fields, name)
+		}
+	}
+	return fields.Encode(), nil
+}
+
+// compareForm - returns form fields that differ, bodies are already in canonical form (multipart bodies as well)
+func compareForm(expected, actual string) []matchMismatch {
+	mismatches := compareQuery(expected, actual)
+	for i := range mismatches {
+		if strings.HasPrefix(mismatches[i].Field, "query parameter ") {
+			mismatches[i].Field = "form field " + strings.TrimPrefix(mismatches[i].Field, "query parameter ")
+		} else {
+			mismatches[i].Field = "body"
+		}
+	}
+	return mismatches
+}
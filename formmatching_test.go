@@ -0,0 +1,68 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalForm(t *testing.T) {
+	a, err := canonicalForm("b=2&a=1&tag=x&tag=y", nil)
+	expect(t, err, nil)
+	b, _ := canonicalForm("tag=x&a=1&b=2&tag=y", nil)
+	expect(t, a, b)
+
+	// order of repeated field values matters
+	c, _ := canonicalForm("tag=y&a=1&b=2&tag=x", nil)
+	refute(t, a, c)
+
+	a, _ = canonicalForm("user=alice&csrf_token=abc&ts=1", []string{"csrf_*", "ts"})
+	b, _ = canonicalForm("ts=2&csrf_token=def&user=alice", []string{"csrf_*", "ts"})
+	expect(t, a, b)
+	expect(t, a, "user=alice")
+
+	_, err = canonicalForm("name=%zz", nil)
+	refute(t, err, nil)
+	expect(t, normalizeBody(BodyMatchForm, "name=%zz"), "name=%zz")
+}
+
+func TestFormBodyMatching(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	expect(t, dbClient.BodyMatchers.Set([]BodyMatcher{
+		{URLPattern: "/login", Mode: BodyMatchForm, IgnoreFields: []string{"csrf_token"}},
+	}), nil)
+
+	storeTestPayload(dbClient, "POST", "http://api.example.com/login", "user=alice&password=secret&csrf_token=abc", 200, "welcome")
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	login := func(body string) (int, string) {
+		req, _ := http.NewRequest("POST", "http://api.example.com/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		_, resp := dbClient.processRequest(req)
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(respBody)
+	}
+
+	status, body := login("csrf_token=xyz&password=secret&user=alice")
+	expect(t, status, 200)
+	expect(t, body, "welcome")
+
+	dbClient.Cfg.MissDebug = true
+	status, body = login("user=alice&password=wrong")
+	expect(t, status, dbClient.Cfg.GetMissStatus())
+	expect(t, strings.Contains(body, `form field password: expected "[redacted]", got "wrong"`), true)
+	expect(t, strings.Contains(body, "secret"), false)
+}
+
+func TestSettingsIgnoreFormFieldsEnv(t *testing.T) {
+	defer os.Setenv("HoverflyIgnoreFormFields", "")
+
+	os.Setenv("HoverflyIgnoreFormFields", "csrf_token, ts")
+	cfg := InitSettings()
+	expect(t, len(cfg.IgnoreFormFields), 2)
+	expect(t, cfg.IgnoreFormFields[1], "ts")
+}
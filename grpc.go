@@ -0,0 +1,414 @@
+package hoverfly
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// gRPC status codes returned by Hoverfly itself
+const (
+	grpcStatusNotFound      = 5
+	grpcStatusInternal      = 13
+	grpcStatusUnimplemented = 12
+	grpcStatusUnavailable   = 14
+)
+
+// grpcFrameHeaderSize - every message is prefixed with compressed flag and big endian message length
+const grpcFrameHeaderSize = 5
+
+// grpcSkippedHeaders - headers that are not passed on, body length is set by the proxy and trailers are
+// announced separately
+var grpcSkippedHeaders = map[string]bool{
+	"Content-Length": true,
+	"Trailer":        true,
+}
+
+// h2cTransport - used for gRPC services without TLS, they only accept HTTP/2 with prior knowledge
+var h2cTransport = &http2.Transport{
+	AllowHTTP: true,
+	DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+		return net.Dial(network, addr)
+	},
+}
+
+// GRPCDescriptors - protobuf descriptors of gRPC services, they are used to decode captured messages
+type GRPCDescriptors struct {
+	files *protoregistry.Files
+}
+
+// LoadGRPCDescriptors - loads binary FileDescriptorSet files, as produced by
+// "protoc --include_imports --descriptor_set_out", files present in several sets are loaded once
+func LoadGRPCDescriptors(paths ...string) (*GRPCDescriptors, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var s descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("%s is not a protobuf descriptor set: %s", path, err.Error())
+		}
+		for _, f := range s.File {
+			if !seen[f.GetName()] {
+				seen[f.GetName()] = true
+				set.File = append(set.File, f)
+			}
+		}
+	}
+
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCDescriptors{files: files}, nil
+}
+
+// Method - returns descriptor of method called with given path, i.e. "/helloworld.Greeter/SayHello"
+func (g *GRPCDescriptors) Method(path string) (protoreflect.MethodDescriptor, error) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("%s is not a gRPC method path", path)
+	}
+
+	desc, err := g.files.FindDescriptorByName(protoreflect.FullName(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("service %s not found in descriptors", parts[0])
+	}
+	service, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", parts[0])
+	}
+
+	method := service.Methods().ByName(protoreflect.Name(parts[1]))
+	if method == nil {
+		return nil, fmt.Errorf("method %s not found in service %s", parts[1], parts[0])
+	}
+	return method, nil
+}
+
+// isGRPCRequest - checks whether request is a gRPC call
+func isGRPCRequest(req *http.Request) bool {
+	return req.Method == "POST" && strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc")
+}
+
+// readGRPCFrames - splits body into messages, compressed messages are decompressed with given grpc-encoding
+func readGRPCFrames(body []byte, encoding string) ([][]byte, error) {
+	var messages [][]byte
+	for len(body) > 0 {
+		if len(body) < grpcFrameHeaderSize {
+			return nil, fmt.Errorf("truncated gRPC frame header")
+		}
+		compressed := body[0] == 1
+		length := binary.BigEndian.Uint32(body[1:grpcFrameHeaderSize])
+		body = body[grpcFrameHeaderSize:]
+		if uint32(len(body)) < length {
+			return nil, fmt.Errorf("truncated gRPC message")
+		}
+
+		message := body[:length]
+		body = body[length:]
+
+		if compressed {
+			if encoding != "gzip" {
+				return nil, fmt.Errorf("unsupported grpc-encoding '%s'", encoding)
+			}
+			r, err := gzip.NewReader(bytes.NewReader(message))
+			if err != nil {
+				return nil, err
+			}
+			if message, err = ioutil.ReadAll(r); err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// writeGRPCFrames - returns uncompressed messages prefixed with their lengths
+func writeGRPCFrames(messages [][]byte) []byte {
+	var buf bytes.Buffer
+	for _, message := range messages {
+		header := make([]byte, grpcFrameHeaderSize)
+		binary.BigEndian.PutUint32(header[1:], uint32(len(message)))
+		buf.Write(header)
+		buf.Write(message)
+	}
+	return buf.Bytes()
+}
+
+// grpcMessagesToJSON - decodes gRPC body into JSON, single message becomes JSON object, streams become an array
+func grpcMessagesToJSON(body []byte, encoding string, desc protoreflect.MessageDescriptor) (string, error) {
+	messages, err := readGRPCFrames(body, encoding)
+	if err != nil {
+		return "", err
+	}
+
+	decoded := make([]json.RawMessage, 0, len(messages))
+	for _, data := range messages {
+		message := dynamicpb.NewMessage(desc)
+		if err := proto.Unmarshal(data, message); err != nil {
+			return "", err
+		}
+		js, err := protojson.Marshal(message)
+		if err != nil {
+			return "", err
+		}
+		// protojson output isn't stable, compacting it keeps request fingerprints stable
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, js); err != nil {
+			return "", err
+		}
+		decoded = append(decoded, compact.Bytes())
+	}
+
+	if len(decoded) == 1 {
+		return string(decoded[0]), nil
+	}
+	js, err := json.Marshal(decoded)
+	return string(js), err
+}
+
+// grpcMessagesFromJSON - encodes JSON created by grpcMessagesToJSON back into gRPC body
+func grpcMessagesFromJSON(body string, desc protoreflect.MessageDescriptor) ([]byte, error) {
+	body = strings.TrimSpace(body)
+
+	decoded := []json.RawMessage{json.RawMessage(body)}
+	if strings.HasPrefix(body, "[") {
+		if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+			return nil, err
+		}
+	}
+
+	messages := make([][]byte, 0, len(decoded))
+	for _, js := range decoded {
+		message := dynamicpb.NewMessage(desc)
+		if err := protojson.Unmarshal(js, message); err != nil {
+			return nil, err
+		}
+		data, err := proto.Marshal(message)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, data)
+	}
+	return writeGRPCFrames(messages), nil
+}
+
+// newGRPCResponse - returns gRPC response, trailers are passed as headers with http.TrailerPrefix since proxy
+// copies only headers and body to the client
+func newGRPCResponse(req *http.Request, status int, header http.Header, body []byte, trailers http.Header) *http.Response {
+	resp := &http.Response{
+		StatusCode:    status,
+		Header:        make(http.Header),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	for name, values := range header {
+		if !grpcSkippedHeaders[http.CanonicalHeaderKey(name)] {
+			resp.Header[name] = values
+		}
+	}
+	for name, values := range trailers {
+		resp.Header[http.TrailerPrefix+name] = values
+	}
+	return resp
+}
+
+// grpcError - returns trailers-only gRPC response with given status, gRPC clients expect HTTP 200 even for errors
+func grpcError(req *http.Request, code int, msg string) *http.Response {
+	header := http.Header{
+		"Content-Type": []string{"application/grpc"},
+		"Grpc-Status":  []string{strconv.Itoa(code)},
+		"Grpc-Message": []string{"Hoverfly Error! " + msg},
+	}
+	return newGRPCResponse(req, http.StatusOK, header, nil, nil)
+}
+
+// grpcRequest - handles gRPC calls in capture, virtualize and spy modes. Messages are decoded with loaded
+// descriptors, requests are fingerprinted by service/method and request message
+func (d *DBClient) grpcRequest(req *http.Request, mode string) *http.Response {
+	method, err := d.GRPC.Method(req.URL.Path)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"path":        req.URL.Path,
+			"destination": req.Host,
+		}).Warn("Unknown gRPC method")
+		return grpcError(req, grpcStatusUnimplemented, err.Error())
+	}
+
+	var body []byte
+	if req.Body != nil {
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return grpcError(req, grpcStatusInternal, "Failed to read request")
+		}
+	}
+
+	requestJSON, err := grpcMessagesToJSON(body, req.Header.Get("Grpc-Encoding"), method.Input())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err.Error(),
+			"method": method.FullName(),
+		}).Error("Failed to decode gRPC request")
+		return grpcError(req, grpcStatusInternal, "Failed to decode request: "+err.Error())
+	}
+
+	key := d.requestFingerprint(req, []byte(requestJSON))
+
+	if mode == VirtualizeMode || mode == SpyMode {
+		if payloadBts, err := d.Cache.Get([]byte(key)); err == nil {
+			return d.grpcCachedResponse(req, key, payloadBts, method, mode)
+		}
+		if mode == VirtualizeMode {
+			log.WithFields(log.Fields{
+				"key":         key,
+				"method":      method.FullName(),
+				"destination": req.Host,
+			}).Warn("Failed to retrieve gRPC response from cache")
+			return grpcError(req, grpcStatusNotFound, "Could not find recorded request, please record it first!")
+		}
+	}
+
+	record := mode == CaptureMode || (mode == SpyMode && d.Cfg.SpyCapture)
+	return d.grpcForward(req, body, requestJSON, key, method, record)
+}
+
+// grpcForward - forwards call to the real service, call is captured when record is set
+func (d *DBClient) grpcForward(req *http.Request, body []byte, requestJSON, key string, method protoreflect.MethodDescriptor, record bool) *http.Response {
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.RequestURI = ""
+
+	var resp *http.Response
+	var err error
+	if req.URL.Scheme == "https" {
+		resp, err = d.HTTP.Do(req)
+	} else {
+		resp, err = h2cTransport.RoundTrip(req)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"method":      method.FullName(),
+			"destination": req.Host,
+		}).Error("Could not forward gRPC request")
+		return grpcError(req, grpcStatusUnavailable, "Could not reach destination: "+err.Error())
+	}
+	defer resp.Body.Close()
+
+	// trailers are available only once the whole body is read
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return grpcError(req, grpcStatusUnavailable, "Failed to read response: "+err.Error())
+	}
+
+	if record {
+		responseJSON, err := grpcMessagesToJSON(respBody, resp.Header.Get("Grpc-Encoding"), method.Output())
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":  err.Error(),
+				"method": method.FullName(),
+			}).Error("Failed to decode gRPC response, it won't be captured")
+		} else {
+			payload := Payload{
+				Request: RequestDetails{
+					Path:        req.URL.Path,
+					Method:      req.Method,
+					Destination: req.Host,
+					Scheme:      req.URL.Scheme,
+					Body:        requestJSON,
+					RemoteAddr:  req.RemoteAddr,
+					Headers:     req.Header,
+					Proto:       req.Proto,
+				},
+				Response: ResponseDetails{
+					Status:   resp.StatusCode,
+					Body:     responseJSON,
+					Headers:  resp.Header,
+					Trailers: resp.Trailer,
+					Proto:    resp.Proto,
+				},
+				ID: key,
+			}
+			d.applyCaptureHeaderRules(&payload)
+			d.storePayload(key, payload)
+			log.WithFields(log.Fields{
+				"key":         key,
+				"method":      method.FullName(),
+				"destination": req.Host,
+			}).Info("gRPC request and response captured")
+		}
+	}
+
+	// messages are passed on as they were received, compressed ones included
+	return newGRPCResponse(req, resp.StatusCode, resp.Header, respBody, resp.Trailer)
+}
+
+// grpcCachedResponse - encodes captured messages back into gRPC response, middleware gets decoded messages
+func (d *DBClient) grpcCachedResponse(req *http.Request, key string, payloadBts []byte, method protoreflect.MethodDescriptor, mode string) *http.Response {
+	payload, err := decodePayload(payloadBts)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Error("Failed to decode payload")
+		return grpcError(req, grpcStatusInternal, "Failed to virtualize")
+	}
+
+	c := NewConstructor(req, *payload)
+	if d.hasMiddleware(req) {
+		if err := d.applyReplayMiddleware(c, d.Cfg.Middleware); err != nil {
+			return grpcError(req, grpcStatusUnavailable, "Middleware failed: "+err.Error())
+		}
+	}
+	response := c.payload.Response
+
+	body, err := grpcMessagesFromJSON(response.Body, method.Output())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err.Error(),
+			"key":    key,
+			"method": method.FullName(),
+		}).Error("Failed to encode captured gRPC response")
+		return grpcError(req, grpcStatusInternal, "Failed to encode captured response: "+err.Error())
+	}
+
+	d.applyResponseDelay(req)
+
+	// captured messages are replayed uncompressed
+	header := http.Header(response.Headers)
+	header.Del("Grpc-Encoding")
+
+	log.WithFields(log.Fields{
+		"key":         key,
+		"mode":        mode,
+		"method":      method.FullName(),
+		"destination": req.Host,
+	}).Info("gRPC response found, returning")
+
+	return newGRPCResponse(req, response.Status, header, body, response.Trailers)
+}
@@ -0,0 +1,230 @@
+package hoverfly
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// writeTestDescriptors - writes descriptor set of test.Greeter service with unary SayHello and server streaming
+// SayHellos methods, returns its path
+func writeTestDescriptors(t *testing.T, dir string) string {
+	stringField := func(name string) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(1),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("greeter.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("HelloRequest"), Field: []*descriptorpb.FieldDescriptorProto{stringField("name")}},
+			{Name: proto.String("HelloReply"), Field: []*descriptorpb.FieldDescriptorProto{stringField("message")}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Greeter"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("SayHello"), InputType: proto.String(".test.HelloRequest"), OutputType: proto.String(".test.HelloReply")},
+				{Name: proto.String("SayHellos"), InputType: proto.String(".test.HelloRequest"), OutputType: proto.String(".test.HelloReply"), ServerStreaming: proto.Bool(true)},
+			},
+		}},
+	}}}
+
+	data, err := proto.Marshal(set)
+	expect(t, err, nil)
+	path := filepath.Join(dir, "greeter.pb")
+	expect(t, ioutil.WriteFile(path, data, 0644), nil)
+	return path
+}
+
+// testGRPCDescriptors - returns descriptors of test.Greeter service
+func testGRPCDescriptors(t *testing.T) *GRPCDescriptors {
+	dir, err := ioutil.TempDir("", "hoverfly-grpc")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	descriptors, err := LoadGRPCDescriptors(writeTestDescriptors(t, dir))
+	expect(t, err, nil)
+	return descriptors
+}
+
+// greeterServer - h2c gRPC server greeting the name it was sent
+func greeterServer(t *testing.T, descriptors *GRPCDescriptors) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, err := descriptors.Method(r.URL.Path)
+		expect(t, err, nil)
+		body, _ := ioutil.ReadAll(r.Body)
+		request, err := grpcMessagesToJSON(body, "", method.Input())
+		expect(t, err, nil)
+
+		var hello struct{ Name string }
+		json.Unmarshal([]byte(request), &hello)
+		reply, err := grpcMessagesFromJSON(`{"message": "hello `+hello.Name+`"}`, method.Output())
+		expect(t, err, nil)
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.Write(reply)
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set("Grpc-Message", "")
+	})
+	return httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+}
+
+// grpcCall - calls test.Greeter/SayHello with given name through the proxy
+func grpcCall(t *testing.T, proxy *httptest.Server, target, name string) (*http.Response, string) {
+	descriptors := testGRPCDescriptors(t)
+	method, err := descriptors.Method("/test.Greeter/SayHello")
+	expect(t, err, nil)
+
+	body, err := grpcMessagesFromJSON(`{"name": "`+name+`"}`, method.Input())
+	expect(t, err, nil)
+	req, err := http.NewRequest("POST", target+"/test.Greeter/SayHello", bytes.NewReader(body))
+	expect(t, err, nil)
+	req.Header.Set("Content-Type", "application/grpc")
+
+	resp, err := h2cClient(proxy).Do(req)
+	expect(t, err, nil)
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	reply, err := grpcMessagesToJSON(respBody, "", method.Output())
+	expect(t, err, nil)
+	return resp, reply
+}
+
+func TestGRPCCaptureAndVirtualize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly-grpc")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	cache := NewMemoryCache()
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	cfg.GRPCDescriptors = []string{writeTestDescriptors(t, dir)}
+	proxy, dbClient := GetNewHoverfly(cfg, cache)
+
+	upstream := greeterServer(t, dbClient.GRPC)
+	target := upstream.URL
+
+	proxyServer := httptest.NewServer(NewHTTP2Handler(proxy))
+	defer proxyServer.Close()
+
+	resp, reply := grpcCall(t, proxyServer, target, "world")
+	expect(t, resp.StatusCode, http.StatusOK)
+	expect(t, reply, `{"message":"hello world"}`)
+	expect(t, resp.Trailer.Get("Grpc-Status"), "0")
+
+	payloads, err := cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Path, "/test.Greeter/SayHello")
+	expect(t, payloads[0].Request.Body, `{"name":"world"}`)
+	expect(t, payloads[0].Response.Body, `{"message":"hello world"}`)
+	expect(t, payloads[0].Response.Trailers["Grpc-Status"][0], "0")
+
+	// service is gone, captured call is replayed
+	upstream.Close()
+	cfg.SetMode(VirtualizeMode)
+
+	resp, reply = grpcCall(t, proxyServer, target, "world")
+	expect(t, resp.StatusCode, http.StatusOK)
+	expect(t, reply, `{"message":"hello world"}`)
+	expect(t, resp.Trailer.Get("Grpc-Status"), "0")
+
+	// other request messages were not captured
+	resp, reply = grpcCall(t, proxyServer, target, "mars")
+	expect(t, reply, "[]")
+	expect(t, resp.Header.Get("Grpc-Status"), "5")
+}
+
+func TestGRPCUnknownMethod(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	dbClient.GRPC = testGRPCDescriptors(t)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, err := http.NewRequest("POST", "http://grpc.example.com/test.Greeter/Unknown", nil)
+	expect(t, err, nil)
+	req.Header.Set("Content-Type", "application/grpc")
+
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusOK)
+	expect(t, resp.Header.Get("Grpc-Status"), "12")
+}
+
+func TestGRPCStreamsAreStoredAsArrays(t *testing.T) {
+	method, err := testGRPCDescriptors(t).Method("/test.Greeter/SayHellos")
+	expect(t, err, nil)
+
+	body, err := grpcMessagesFromJSON(`[{"message": "one"}, {"message": "two"}]`, method.Output())
+	expect(t, err, nil)
+	messages, err := readGRPCFrames(body, "")
+	expect(t, err, nil)
+	expect(t, len(messages), 2)
+
+	js, err := grpcMessagesToJSON(body, "", method.Output())
+	expect(t, err, nil)
+	expect(t, js, `[{"message":"one"},{"message":"two"}]`)
+}
+
+func TestGRPCCompressedMessages(t *testing.T) {
+	method, err := testGRPCDescriptors(t).Method("/test.Greeter/SayHello")
+	expect(t, err, nil)
+
+	body, err := grpcMessagesFromJSON(`{"name": "world"}`, method.Input())
+	expect(t, err, nil)
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(body[grpcFrameHeaderSize:])
+	zw.Close()
+	frame := writeGRPCFrames([][]byte{compressed.Bytes()})
+	frame[0] = 1
+
+	js, err := grpcMessagesToJSON(frame, "gzip", method.Input())
+	expect(t, err, nil)
+	expect(t, js, `{"name":"world"}`)
+
+	_, err = grpcMessagesToJSON(frame, "snappy", method.Input())
+	refute(t, err, nil)
+}
+
+func TestLoadGRPCDescriptorsBadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly-grpc")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "greeter.proto")
+	expect(t, ioutil.WriteFile(path, []byte("syntax = \"proto3\";"), 0644), nil)
+
+	_, err = LoadGRPCDescriptors(path)
+	refute(t, err, nil)
+}
+
+func TestSettingsGRPCDescriptorsEnv(t *testing.T) {
+	defer os.Setenv("HoverflyGRPCDescriptors", "")
+	os.Setenv("HoverflyGRPCDescriptors", "a.pb, b.pb")
+
+	cfg := InitSettings()
+	expect(t, len(cfg.GRPCDescriptors), 2)
+	expect(t, cfg.GRPCDescriptors[1], "b.pb")
+}
@@ -0,0 +1,329 @@
+package hoverfly
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// HAR - HTTP Archive, format browsers (Chrome, Firefox) and proxies (Charles, Fiddler) export traffic in, see
+// http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog - root of HTTP Archive
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator - application that created the archive
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry - request with its response
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+// HARRequest - request of HAR entry
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse - response of HAR entry
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue - header, cookie, query parameter or form field
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData - request body, form fields are used when text is missing
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Text     string         `json:"text"`
+	Params   []HARNameValue `json:"params,omitempty"`
+}
+
+// HARContent - response body, Encoding is "base64" for binary content
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings - time spent in phases of the request, in milliseconds
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harHeaders - converts HAR headers, HTTP/2 pseudo headers (i.e. :authority) are left out
+func harHeaders(headers []HARNameValue) map[string][]string {
+	converted := make(map[string][]string)
+	for _, header := range headers {
+		if strings.HasPrefix(header.Name, ":") {
+			continue
+		}
+		name := http.CanonicalHeaderKey(header.Name)
+		converted[name] = append(converted[name], header.Value)
+	}
+	return converted
+}
+
+// harPayload - converts HAR entry into payload
+func harPayload(entry HAREntry) (Payload, error) {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return Payload{}, fmt.Errorf("invalid URL '%s': %s", entry.Request.URL, err.Error())
+	}
+	if u.Host == "" {
+		return Payload{}, fmt.Errorf("URL '%s' has no host", entry.Request.URL)
+	}
+	if entry.Response.Status == 0 {
+		// request was blocked or failed, there is no response to replay
+		return Payload{}, fmt.Errorf("request to '%s' has no response", entry.Request.URL)
+	}
+
+	var requestBody string
+	if postData := entry.Request.PostData; postData != nil {
+		requestBody = postData.Text
+		if requestBody == "" && len(postData.Params) > 0 {
+			form := url.Values{}
+			for _, param := range postData.Params {
+				form.Add(param.Name, param.Value)
+			}
+			requestBody = form.Encode()
+		}
+	}
+
+	responseBody := entry.Response.Content.Text
+	if entry.Response.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(responseBody)
+		if err != nil {
+			return Payload{}, fmt.Errorf("invalid base64 content of '%s': %s", entry.Request.URL, err.Error())
+		}
+		responseBody = string(decoded)
+	}
+
+	responseHeaders := harHeaders(entry.Response.Headers)
+	// HAR content is already decoded and its length may differ from the original one
+	delete(responseHeaders, "Content-Encoding")
+	delete(responseHeaders, "Content-Length")
+
+	pl := Payload{
+		Request: RequestDetails{
+			Path:        u.Path,
+			Method:      entry.Request.Method,
+			Destination: u.Host,
+			Scheme:      u.Scheme,
+			Query:       u.RawQuery,
+			Body:        requestBody,
+			Headers:     harHeaders(entry.Request.Headers),
+		},
+		Response: ResponseDetails{
+			Status:  entry.Response.Status,
+			Body:    responseBody,
+			Headers: responseHeaders,
+		},
+	}
+	if started, err := time.Parse(time.RFC3339Nano, entry.StartedDateTime); err == nil {
+		started = started.UTC()
+		pl.CapturedAt = &started
+	}
+	return pl, nil
+}
+
+// harPayloads - converts all HAR entries that can be replayed, entries that can't are logged and skipped
+func harPayloads(har HAR) []Payload {
+	payloads := make([]Payload, 0, len(har.Log.Entries))
+	for _, entry := range har.Log.Entries {
+		pl, err := harPayload(entry)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":  err.Error(),
+				"url":    entry.Request.URL,
+				"method": entry.Request.Method,
+			}).Warn("Skipping HAR entry")
+			continue
+		}
+		payloads = append(payloads, pl)
+	}
+	return payloads
+}
+
+// ImportHAR - reads HTTP Archive and imports its entries as records
+func (d *DBClient) ImportHAR(r io.Reader) error {
+	var har HAR
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return fmt.Errorf("Got error while parsing HAR file, error %s", err.Error())
+	}
+	return d.ImportPayloads(harPayloads(har))
+}
+
+// ImportHARFromDisk - imports entries of HTTP Archive file
+func (d *DBClient) ImportHARFromDisk(path string) error {
+	harFile, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Got error while opening HAR file, error %s", err.Error())
+	}
+	defer harFile.Close()
+	return d.ImportHAR(harFile)
+}
+
+// ImportHARFromURL - fetches HTTP Archive from remote server and imports its entries
+func (d *DBClient) ImportHARFromURL(url string) error {
+	resp, err := d.HTTP.Get(url)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch given URL, error %s", err.Error())
+	}
+	defer resp.Body.Close()
+	return d.ImportHAR(resp.Body)
+}
+
+// harNameValues - converts headers into HAR list sorted by name
+func harNameValues(headers map[string][]string) []HARNameValue {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]HARNameValue, 0, len(headers))
+	for _, name := range names {
+		for _, value := range headers[name] {
+			list = append(list, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return list
+}
+
+// harEntry - converts payload into HAR entry, records don't keep timing so it's all zero. Entries start when the
+// record was captured, records without capture time start when the archive is created
+func harEntry(pl Payload, started time.Time) HAREntry {
+	if pl.CapturedAt != nil {
+		started = *pl.CapturedAt
+	}
+	scheme := pl.Request.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	u := url.URL{Scheme: scheme, Host: pl.Request.Destination, Path: pl.Request.Path, RawQuery: pl.Request.Query}
+
+	queryString := []HARNameValue{}
+	if query, err := url.ParseQuery(pl.Request.Query); err == nil {
+		queryString = harNameValues(query)
+	}
+
+	httpVersion := pl.Request.Proto
+	if httpVersion == "" {
+		httpVersion = "HTTP/1.1"
+	}
+
+	request := HARRequest{
+		Method:      pl.Request.Method,
+		URL:         u.String(),
+		HTTPVersion: httpVersion,
+		Cookies:     []HARNameValue{},
+		Headers:     harNameValues(pl.Request.Headers),
+		QueryString: queryString,
+		HeadersSize: -1,
+		BodySize:    len(pl.Request.Body),
+	}
+	if pl.Request.Body != "" {
+		request.PostData = &HARPostData{
+			MimeType: strings.Join(headerValues(pl.Request.Headers, "Content-Type"), ","),
+			Text:     pl.Request.Body,
+		}
+	}
+
+	responseVersion := pl.Response.Proto
+	if responseVersion == "" {
+		responseVersion = httpVersion
+	}
+	content := HARContent{
+		Size:     len(pl.Response.Body),
+		MimeType: strings.Join(headerValues(pl.Response.Headers, "Content-Type"), ","),
+		Text:     pl.Response.Body,
+	}
+	if !utf8.ValidString(pl.Response.Body) {
+		content.Text = base64.StdEncoding.EncodeToString([]byte(pl.Response.Body))
+		content.Encoding = "base64"
+	}
+
+	return HAREntry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Request:         request,
+		Response: HARResponse{
+			Status:      pl.Response.Status,
+			StatusText:  http.StatusText(pl.Response.Status),
+			HTTPVersion: responseVersion,
+			Cookies:     []HARNameValue{},
+			Headers:     harNameValues(pl.Response.Headers),
+			Content:     content,
+			RedirectURL: strings.Join(headerValues(pl.Response.Headers, "Location"), ","),
+			HeadersSize: -1,
+			BodySize:    len(pl.Response.Body),
+		},
+	}
+}
+
+// ExportHAR - returns all records as HTTP Archive 1.2
+func (d *DBClient) ExportHAR() (HAR, error) {
+	payloads, err := d.Cache.GetAllRequests()
+	if err != nil {
+		return HAR{}, err
+	}
+
+	started := time.Now().UTC()
+	entries := make([]HAREntry, 0, len(payloads))
+	for _, pl := range payloads {
+		entries = append(entries, harEntry(pl, started))
+	}
+	return HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "Hoverfly", Version: "1"},
+		Entries: entries,
+	}}, nil
+}
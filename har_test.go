@@ -0,0 +1,106 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHARPayload(t *testing.T) {
+	pl, err := harPayload(HAREntry{
+		Request: HARRequest{
+			Method:   "POST",
+			URL:      "https://api.example.com/login?next=%2Fhome",
+			Headers:  []HARNameValue{{Name: ":method", Value: "POST"}, {Name: "content-type", Value: "application/x-www-form-urlencoded"}},
+			PostData: &HARPostData{Params: []HARNameValue{{Name: "user", Value: "alice"}}},
+		},
+		Response: HARResponse{
+			Status:  200,
+			Headers: []HARNameValue{{Name: "Content-Encoding", Value: "br"}, {Name: "Set-Cookie", Value: "a=1"}, {Name: "set-cookie", Value: "b=2"}},
+			Content: HARContent{Text: "aGVsbG8=", Encoding: "base64"},
+		},
+	})
+	expect(t, err, nil)
+	expect(t, pl.Request.Destination, "api.example.com")
+	expect(t, pl.Request.Scheme, "https")
+	expect(t, pl.Request.Path, "/login")
+	expect(t, pl.Request.Query, "next=%2Fhome")
+	expect(t, pl.Request.Body, "user=alice")
+	expect(t, len(pl.Request.Headers), 1)
+	expect(t, pl.Request.Headers["Content-Type"][0], "application/x-www-form-urlencoded")
+	expect(t, pl.Response.Body, "hello")
+	expect(t, len(pl.Response.Headers["Set-Cookie"]), 2)
+	expect(t, len(pl.Response.Headers["Content-Encoding"]), 0)
+
+	_, err = harPayload(HAREntry{Request: HARRequest{Method: "GET", URL: "https://api.example.com/"}})
+	refute(t, err, nil)
+
+	_, err = harPayload(HAREntry{Request: HARRequest{Method: "GET", URL: "/relative"}, Response: HARResponse{Status: 200}})
+	refute(t, err, nil)
+}
+
+func TestImportHARFile(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	expect(t, dbClient.Import("examples/exports/browser_session.har"), nil)
+	count, _ := dbClient.Cache.RecordsCount()
+	// blocked request isn't imported
+	expect(t, count, 3)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users?page=1", nil)
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, resp.StatusCode, 200)
+	expect(t, string(body), `[{"id": 1, "name": "alice"}]`)
+	expect(t, resp.Header.Get("Content-Encoding"), "")
+
+	req, _ = http.NewRequest("POST", "http://api.example.com/login", strings.NewReader("password=secret&user=alice"))
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 302)
+	expect(t, resp.Header.Get("Location"), "/home")
+}
+
+func TestHAREntry(t *testing.T) {
+	entry := harEntry(Payload{
+		Request: RequestDetails{
+			Method:      "POST",
+			Destination: "api.example.com",
+			Path:        "/orders",
+			Query:       "page=2&sort=name",
+			Body:        `{"id": 1}`,
+			Headers:     map[string][]string{"Content-Type": {"application/json"}, "Accept": {"*/*"}},
+		},
+		Response: ResponseDetails{
+			Status:  201,
+			Body:    "\xff\xd8\xff",
+			Headers: map[string][]string{"Content-Type": {"image/jpeg"}},
+		},
+	}, time.Date(2016, 5, 10, 9, 0, 0, 0, time.UTC))
+
+	expect(t, entry.StartedDateTime, "2016-05-10T09:00:00Z")
+	expect(t, entry.Request.URL, "http://api.example.com/orders?page=2&sort=name")
+	expect(t, entry.Request.HTTPVersion, "HTTP/1.1")
+	expect(t, len(entry.Request.QueryString), 2)
+	expect(t, entry.Request.Headers[0].Name, "Accept")
+	expect(t, entry.Request.PostData.MimeType, "application/json")
+	expect(t, entry.Response.StatusText, "Created")
+	expect(t, entry.Response.Content.Encoding, "base64")
+
+	// entry converts back to the same record
+	pl, err := harPayload(entry)
+	expect(t, err, nil)
+	expect(t, pl.Request.Body, `{"id": 1}`)
+	expect(t, pl.Request.Query, "page=2&sort=name")
+	expect(t, pl.Response.Body, "\xff\xd8\xff")
+	expect(t, pl.CapturedAt.Equal(time.Date(2016, 5, 10, 9, 0, 0, 0, time.UTC)), true)
+
+	// captured records start when they were captured
+	capturedAt := time.Date(2016, 5, 9, 8, 0, 0, 0, time.UTC)
+	entry = harEntry(Payload{Request: RequestDetails{Destination: "api.example.com"}, CapturedAt: &capturedAt}, time.Now())
+	expect(t, entry.StartedDateTime, "2016-05-09T08:00:00Z")
+}
@@ -0,0 +1,144 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HeaderMatch - request headers that are part of request fingerprint for matching destinations, so requests that
+// differ only in i.e. Authorization, Accept or API version header get different responses
+type HeaderMatch struct {
+	// Destination - regular expression matched against request host
+	Destination string `json:"destination"`
+	// Headers - names of headers, request without header only matches records without it too
+	Headers []string `json:"headers"`
+
+	destinationRe *regexp.Regexp
+}
+
+// compile - validates header match and compiles its destination
+func (m *HeaderMatch) compile() error {
+	re, err := regexp.Compile(m.Destination)
+	if err != nil {
+		return fmt.Errorf("invalid destination '%s': %s", m.Destination, err.Error())
+	}
+	if len(m.Headers) == 0 {
+		return fmt.Errorf("header match for '%s' needs at least one header", m.Destination)
+	}
+	for _, header := range m.Headers {
+		if strings.TrimSpace(header) == "" {
+			return fmt.Errorf("header match for '%s' has empty header name", m.Destination)
+		}
+	}
+	m.destinationRe = re
+	return nil
+}
+
+// ParseHeaderMatches - parses "destination=header" entries, entries with the same destination are merged
+func ParseHeaderMatches(entries []string) ([]HeaderMatch, error) {
+	var matches []HeaderMatch
+	positions := make(map[string]int)
+	for _, entry := range entries {
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("header match '%s' has to be in 'destination=header' format", entry)
+		}
+		destination, header := entry[:i], strings.TrimSpace(entry[i+1:])
+
+		if pos, ok := positions[destination]; ok {
+			matches[pos].Headers = append(matches[pos].Headers, header)
+			continue
+		}
+		positions[destination] = len(matches)
+		matches = append(matches, HeaderMatch{Destination: destination, Headers: []string{header}})
+	}
+	return matches, nil
+}
+
+// HeaderMatches - ordered header matches, headers of every match whose destination matches request host are used
+type HeaderMatches struct {
+	mu      sync.RWMutex
+	matches []HeaderMatch
+}
+
+// NewHeaderMatches - returns empty header match list, only method, destination, path, query and body are matched
+func NewHeaderMatches() *HeaderMatches {
+	return &HeaderMatches{}
+}
+
+// Set - validates and replaces all header matches, current ones are kept when any of them is invalid
+func (h *HeaderMatches) Set(matches []HeaderMatch) error {
+	compiled := make([]HeaderMatch, len(matches))
+	for i, match := range matches {
+		if err := match.compile(); err != nil {
+			return err
+		}
+		compiled[i] = match
+	}
+
+	h.mu.Lock()
+	h.matches = compiled
+	h.mu.Unlock()
+	return nil
+}
+
+// Get - returns current header matches
+func (h *HeaderMatches) Get() []HeaderMatch {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]HeaderMatch{}, h.matches...)
+}
+
+// Clear - removes all header matches
+func (h *HeaderMatches) Clear() {
+	h.mu.Lock()
+	h.matches = nil
+	h.mu.Unlock()
+}
+
+// For - returns sorted canonical names of headers matched for given destination
+func (h *HeaderMatches) For(destination string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var headers []string
+	seen := make(map[string]bool)
+	for i := range h.matches {
+		if !h.matches[i].destinationRe.MatchString(destination) {
+			continue
+		}
+		for _, header := range h.matches[i].Headers {
+			header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+			if !seen[header] {
+				seen[header] = true
+				headers = append(headers, header)
+			}
+		}
+	}
+	sort.Strings(headers)
+	return headers
+}
+
+// headerValues - returns values of header, names are compared case insensitively as imported headers might not be
+// canonical
+func headerValues(headers map[string][]string, name string) []string {
+	var values []string
+	for key, v := range headers {
+		if strings.EqualFold(key, name) {
+			values = append(values, v...)
+		}
+	}
+	return values
+}
+
+// matchHeaders - returns headers matched for given destination, nil when header matching isn't configured
+func (d *DBClient) matchHeaders(destination string) []string {
+	if d.HeaderMatches == nil {
+		return nil
+	}
+	return d.HeaderMatches.For(destination)
+}
@@ -0,0 +1,113 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestHeaderMatchesFor(t *testing.T) {
+	matches := NewHeaderMatches()
+	expect(t, matches.Set([]HeaderMatch{
+		{Destination: `api\.example\.com`, Headers: []string{"authorization", "X-Api-Version"}},
+		{Destination: `example\.com`, Headers: []string{"Accept", "Authorization"}},
+	}), nil)
+
+	expect(t, len(matches.For("api.example.com")), 3)
+	expect(t, matches.For("api.example.com")[0], "Accept")
+	expect(t, matches.For("api.example.com")[1], "Authorization")
+	expect(t, matches.For("api.example.com")[2], "X-Api-Version")
+	expect(t, len(matches.For("www.example.com")), 2)
+	expect(t, len(matches.For("other.com")), 0)
+
+	// invalid matches keep current ones
+	refute(t, matches.Set([]HeaderMatch{{Destination: "("}}), nil)
+	refute(t, matches.Set([]HeaderMatch{{Destination: "."}}), nil)
+	expect(t, len(matches.Get()), 2)
+}
+
+func TestParseHeaderMatches(t *testing.T) {
+	matches, err := ParseHeaderMatches([]string{"api.example.com=Authorization", "other.com=Accept", "api.example.com=Accept"})
+	expect(t, err, nil)
+	expect(t, len(matches), 2)
+	expect(t, matches[0].Destination, "api.example.com")
+	expect(t, len(matches[0].Headers), 2)
+	expect(t, matches[1].Headers[0], "Accept")
+
+	_, err = ParseHeaderMatches([]string{"Authorization"})
+	refute(t, err, nil)
+}
+
+func TestFingerprintWithoutHeaderMatches(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	req.Header.Set("Authorization", "Bearer alice")
+	expect(t, dbClient.requestFingerprint(req, nil), getRequestFingerprint(req, nil))
+}
+
+func TestHeaderMatchingResponses(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	expect(t, dbClient.HeaderMatches.Set([]HeaderMatch{{Destination: `api\.example\.com`, Headers: []string{"Authorization"}}}), nil)
+
+	for _, user := range []string{"alice", "bob"} {
+		req, _ := http.NewRequest("GET", "http://api.example.com/me", nil)
+		req.Header.Set("Authorization", "Bearer "+user)
+		resp := &http.Response{StatusCode: 200, Header: http.Header{"Content-Type": []string{"text/plain"}}}
+		dbClient.save(req, nil, resp, []byte(user))
+	}
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	for _, user := range []string{"alice", "bob"} {
+		req, _ := http.NewRequest("GET", "http://api.example.com/me", nil)
+		req.Header.Set("authorization", "Bearer "+user)
+		_, resp := dbClient.processRequest(req)
+		body, _ := ioutil.ReadAll(resp.Body)
+		expect(t, resp.StatusCode, 200)
+		expect(t, string(body), user)
+	}
+
+	// request without the header doesn't match
+	req, _ := http.NewRequest("GET", "http://api.example.com/me", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+}
+
+func TestHeaderMatchingImport(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	expect(t, dbClient.HeaderMatches.Set([]HeaderMatch{{Destination: ".", Headers: []string{"Accept"}}}), nil)
+
+	err := dbClient.ImportPayloads([]Payload{
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/users", Headers: map[string][]string{"accept": {"application/json"}}},
+			Response: ResponseDetails{Status: 200, Body: "json"},
+		},
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/users", Headers: map[string][]string{"Accept": {"application/xml"}}},
+			Response: ResponseDetails{Status: 200, Body: "xml"},
+		},
+	})
+	expect(t, err, nil)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	req.Header.Set("Accept", "application/xml")
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, string(body), "xml")
+}
+
+func TestSettingsMatchHeadersEnv(t *testing.T) {
+	defer os.Setenv("HoverflyMatchHeaders", "")
+
+	os.Setenv("HoverflyMatchHeaders", "api.example.com=Authorization, api.example.com=Accept")
+	cfg := InitSettings()
+	expect(t, len(cfg.MatchHeaders), 2)
+	expect(t, cfg.MatchHeaders[1], "api.example.com=Accept")
+}
@@ -0,0 +1,195 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Stages header rules are applied at
+const (
+	// HeaderRuleCapture - headers of captured requests and responses are changed before they are stored
+	HeaderRuleCapture = "capture"
+	// HeaderRuleReplay - headers of responses served from simulation are changed
+	HeaderRuleReplay = "replay"
+)
+
+// Header rule actions
+const (
+	// HeaderAdd - adds value to the header
+	HeaderAdd = "add"
+	// HeaderSet - replaces all header values with value
+	HeaderSet = "set"
+	// HeaderRemove - removes the header
+	HeaderRemove = "remove"
+	// HeaderReplace - replaces parts of header values matching pattern with value
+	HeaderReplace = "replace"
+)
+
+// HeaderRule - change of request or response header, i.e. Authorization header removed from captured requests or
+// X-Simulated header added to replayed responses
+type HeaderRule struct {
+	// URLPattern - regular expression matched against request host and path, all requests match when empty
+	URLPattern string `json:"urlPattern,omitempty"`
+	// HTTPMethod - method of matching requests, all methods match when empty
+	HTTPMethod string `json:"httpMethod,omitempty"`
+	// Stage - HeaderRuleCapture or HeaderRuleReplay
+	Stage string `json:"stage"`
+	// Request - rule changes request headers instead of response headers, only available when capturing
+	Request bool `json:"request,omitempty"`
+	// Action - one of HeaderAdd, HeaderSet, HeaderRemove or HeaderReplace
+	Action string `json:"action"`
+	Header string `json:"header"`
+	Value  string `json:"value,omitempty"`
+	// Pattern - regular expression replaced by HeaderReplace action
+	Pattern string `json:"pattern,omitempty"`
+
+	urlRe     *regexp.Regexp
+	patternRe *regexp.Regexp
+}
+
+// compile - validates rule and compiles its patterns
+func (r *HeaderRule) compile() error {
+	urlRe, err := regexp.Compile(r.URLPattern)
+	if err != nil {
+		return fmt.Errorf("invalid URL pattern '%s': %s", r.URLPattern, err.Error())
+	}
+
+	switch r.Stage {
+	case HeaderRuleCapture:
+	case HeaderRuleReplay:
+		if r.Request {
+			return fmt.Errorf("request headers of '%s' can only be changed when capturing", r.Header)
+		}
+	default:
+		return fmt.Errorf("unknown header rule stage '%s', use %s or %s", r.Stage, HeaderRuleCapture, HeaderRuleReplay)
+	}
+
+	if r.Header == "" {
+		return fmt.Errorf("header rule needs header name")
+	}
+
+	switch r.Action {
+	case HeaderAdd, HeaderSet, HeaderRemove:
+	case HeaderReplace:
+		if r.patternRe, err = regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern '%s' of header '%s': %s", r.Pattern, r.Header, err.Error())
+		}
+	default:
+		return fmt.Errorf("unknown header rule action '%s', use %s, %s, %s or %s", r.Action, HeaderAdd,
+			HeaderSet, HeaderRemove, HeaderReplace)
+	}
+
+	r.urlRe = urlRe
+	return nil
+}
+
+// matches - checks whether rule applies to request with given method, host and path
+func (r *HeaderRule) matches(method, host, path string) bool {
+	if r.HTTPMethod != "" && !strings.EqualFold(r.HTTPMethod, method) {
+		return false
+	}
+	return r.urlRe.MatchString(host + path)
+}
+
+// apply - changes headers
+func (r *HeaderRule) apply(headers http.Header) {
+	switch r.Action {
+	case HeaderAdd:
+		headers.Add(r.Header, r.Value)
+	case HeaderSet:
+		headers.Set(r.Header, r.Value)
+	case HeaderRemove:
+		headers.Del(r.Header)
+	case HeaderReplace:
+		values := headers[http.CanonicalHeaderKey(r.Header)]
+		for i, value := range values {
+			values[i] = r.patternRe.ReplaceAllString(value, r.Value)
+		}
+	}
+}
+
+// HeaderRules - ordered header rules, all matching ones are applied
+type HeaderRules struct {
+	mu    sync.RWMutex
+	rules []HeaderRule
+}
+
+// NewHeaderRules - returns empty header rule list
+func NewHeaderRules() *HeaderRules {
+	return &HeaderRules{}
+}
+
+// Set - validates and replaces all rules, current ones are kept when any of them is invalid
+func (h *HeaderRules) Set(rules []HeaderRule) error {
+	compiled := make([]HeaderRule, len(rules))
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return err
+		}
+		compiled[i] = rule
+	}
+
+	h.mu.Lock()
+	h.rules = compiled
+	h.mu.Unlock()
+	return nil
+}
+
+// Get - returns current rules
+func (h *HeaderRules) Get() []HeaderRule {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]HeaderRule{}, h.rules...)
+}
+
+// Clear - removes all rules
+func (h *HeaderRules) Clear() {
+	h.mu.Lock()
+	h.rules = nil
+	h.mu.Unlock()
+}
+
+// Apply - applies rules of given stage to request or response headers of request with given method, host and path,
+// headers are copied before they are changed
+func (h *HeaderRules) Apply(stage string, request bool, method, host, path string, headers map[string][]string) map[string][]string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	changed := false
+	result := http.Header(headers)
+	for i := range h.rules {
+		rule := &h.rules[i]
+		if rule.Stage != stage || rule.Request != request || !rule.matches(method, host, path) {
+			continue
+		}
+		if !changed {
+			result = copyHeaders(headers)
+			changed = true
+		}
+		rule.apply(result)
+	}
+	return result
+}
+
+// copyHeaders - returns deep copy of headers
+func copyHeaders(headers map[string][]string) http.Header {
+	copied := make(http.Header, len(headers))
+	for name, values := range headers {
+		copied[name] = append([]string{}, values...)
+	}
+	return copied
+}
+
+// applyCaptureHeaderRules - changes headers of captured payload before it's stored
+func (d *DBClient) applyCaptureHeaderRules(payload *Payload) {
+	if d.HeaderRules == nil {
+		return
+	}
+	r := payload.Request
+	payload.Request.Headers = d.HeaderRules.Apply(HeaderRuleCapture, true, r.Method, r.Destination, r.Path, r.Headers)
+	payload.Response.Headers = d.HeaderRules.Apply(HeaderRuleCapture, false, r.Method, r.Destination, r.Path,
+		payload.Response.Headers)
+}
@@ -0,0 +1,85 @@
+package hoverfly
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHeaderRulesApply(t *testing.T) {
+	rules := NewHeaderRules()
+	err := rules.Set([]HeaderRule{
+		{Stage: HeaderRuleCapture, Request: true, Action: HeaderRemove, Header: "Authorization"},
+		{Stage: HeaderRuleCapture, Action: HeaderReplace, Header: "Set-Cookie", Pattern: "session=[^;]+", Value: "session=redacted"},
+		{URLPattern: `/v2/`, Stage: HeaderRuleReplay, Action: HeaderSet, Header: "X-Api-Version", Value: "2"},
+		{Stage: HeaderRuleReplay, Action: HeaderAdd, Header: "X-Simulated", Value: "true"},
+	})
+	expect(t, err, nil)
+
+	original := http.Header{"Authorization": {"Bearer secret"}, "Accept": {"*/*"}}
+	headers := http.Header(rules.Apply(HeaderRuleCapture, true, "GET", "api.example.com", "/v1/users", original))
+	expect(t, headers.Get("Authorization"), "")
+	expect(t, headers.Get("Accept"), "*/*")
+	// original headers are not changed
+	expect(t, original.Get("Authorization"), "Bearer secret")
+
+	headers = http.Header(rules.Apply(HeaderRuleCapture, false, "GET", "api.example.com", "/v1/users",
+		http.Header{"Set-Cookie": {"session=abc123; Path=/"}}))
+	expect(t, headers.Get("Set-Cookie"), "session=redacted; Path=/")
+
+	headers = http.Header(rules.Apply(HeaderRuleReplay, false, "GET", "api.example.com", "/v1/users", nil))
+	expect(t, headers.Get("X-Simulated"), "true")
+	expect(t, headers.Get("X-Api-Version"), "")
+
+	headers = http.Header(rules.Apply(HeaderRuleReplay, false, "GET", "api.example.com", "/v2/users",
+		http.Header{"X-Api-Version": {"1"}}))
+	expect(t, headers.Get("X-Api-Version"), "2")
+	expect(t, len(headers["X-Api-Version"]), 1)
+}
+
+func TestHeaderRulesCaptureAndReplay(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	expect(t, dbClient.HeaderRules.Set([]HeaderRule{
+		{Stage: HeaderRuleCapture, Request: true, Action: HeaderRemove, Header: "Authorization"},
+		{Stage: HeaderRuleReplay, Action: HeaderAdd, Header: "X-Simulated", Value: "true"},
+	}), nil)
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 200)
+	expect(t, resp.Header.Get("X-Simulated"), "")
+
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	_, ok := payloads[0].Request.Headers["Authorization"]
+	expect(t, ok, false)
+
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	req, _ = http.NewRequest("GET", "http://api.example.com/users", nil)
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 200)
+	expect(t, resp.Header.Get("X-Simulated"), "true")
+}
+
+func TestHeaderRulesInvalid(t *testing.T) {
+	rules := NewHeaderRules()
+	expect(t, rules.Set([]HeaderRule{{Stage: HeaderRuleReplay, Action: HeaderRemove, Header: "Server"}}), nil)
+
+	refute(t, rules.Set([]HeaderRule{{URLPattern: "(", Stage: HeaderRuleReplay, Action: HeaderRemove, Header: "Server"}}), nil)
+	refute(t, rules.Set([]HeaderRule{{Stage: "forward", Action: HeaderRemove, Header: "Server"}}), nil)
+	refute(t, rules.Set([]HeaderRule{{Stage: HeaderRuleReplay, Request: true, Action: HeaderRemove, Header: "Server"}}), nil)
+	refute(t, rules.Set([]HeaderRule{{Stage: HeaderRuleReplay, Action: "rename", Header: "Server"}}), nil)
+	refute(t, rules.Set([]HeaderRule{{Stage: HeaderRuleReplay, Action: HeaderReplace, Header: "Server", Pattern: "("}}), nil)
+	refute(t, rules.Set([]HeaderRule{{Stage: HeaderRuleReplay, Action: HeaderRemove}}), nil)
+
+	// current rules are kept
+	expect(t, len(rules.Get()), 1)
+
+	rules.Clear()
+	expect(t, len(rules.Get()), 0)
+}
@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"regexp"
 )
 
 // VirtualizeMode - default mode when Hoverfly looks for captured requests to respond
@@ -23,6 +22,9 @@ const ModifyMode = "modify"
 // CaptureMode - requests are captured and stored in cache
 const CaptureMode = "capture"
 
+// SpyMode - captured responses are returned when found, other requests are passed through to destination
+const SpyMode = "spy"
+
 // orPanic - wrapper for logging errors
 func orPanic(err error) {
 	if err != nil {
@@ -37,23 +39,109 @@ func GetNewHoverfly(cfg *Configuration, cache Cache) (*goproxy.ProxyHttpServer,
 
 	counter := NewModeCounter()
 
+	transport, err := NewUpstreamTransport(cfg)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Fatal("Failed to configure connections to upstream services")
+	}
+
 	// getting connections
 	d := DBClient{
-		Cache:   cache,
-		HTTP:    &http.Client{},
-		Cfg:     cfg,
-		Counter: counter,
-		Hooks:   make(ActionTypeHooks),
+		Cache:            cache,
+		HTTP:             &http.Client{Transport: transport},
+		Cfg:              cfg,
+		Counter:          counter,
+		Hooks:            make(ActionTypeHooks),
+		Events:           NewCacheEvents(),
+		Traffic:          NewTrafficStream(),
+		Diffs:            NewDiffReport(),
+		Delays:           NewResponseDelays(),
+		Faults:           NewFaults(),
+		Webhooks:         NewWebhooks(),
+		MiddlewareChain:  NewMiddlewareChain(),
+		ErrorResponses:   NewErrorResponses(),
+		RateLimits:       NewRateLimits(),
+		TemplateCounters: NewTemplateCounters(),
+		Sequences:        NewSequences(),
+		ScenarioState:    NewScenarioState(),
+		HeaderRules:      NewHeaderRules(),
+		HeaderMatches:    NewHeaderMatches(),
+		BodyMatchers:     NewBodyMatchers(),
+		CustomMatchers:   NewCustomMatchers(),
+		RecordIndex:      NewRecordIndex(),
+	}
+	d.AddHook(d.Events)
+
+	// records saved by other instances to shared cache don't invalidate the index
+	if isSharedCache(cache) {
+		d.RecordIndex.TTL = sharedRecordIndexTTL
+	}
+
+	if cfg.JournalSize > 0 {
+		d.Journal = NewJournal(cfg.JournalSize)
+	}
+
+	if len(cfg.MatchHeaders) > 0 {
+		matches, err := ParseHeaderMatches(cfg.MatchHeaders)
+		if err == nil {
+			err = d.HeaderMatches.Set(matches)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":        err.Error(),
+				"matchHeaders": cfg.MatchHeaders,
+			}).Fatal("Failed to configure header matching")
+		}
+	}
+
+	if d.KeyGenerator, err = NewKeyGenerator(cfg.KeyStrategy); err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"keyStrategy": cfg.KeyStrategy,
+		}).Fatal("Failed to configure key strategy")
+	}
+
+	if len(cfg.GRPCDescriptors) > 0 {
+		if d.GRPC, err = LoadGRPCDescriptors(cfg.GRPCDescriptors...); err != nil {
+			log.WithFields(log.Fields{
+				"error":       err.Error(),
+				"descriptors": cfg.GRPCDescriptors,
+			}).Fatal("Failed to load gRPC descriptors")
+		}
 	}
 
 	// creating proxy
 	proxy := goproxy.NewProxyHttpServer()
 
-	proxy.OnRequest(goproxy.ReqHostMatches(regexp.MustCompile(d.Cfg.Destination))).
+	// connections to destinations, including passed through ones, are made through upstream proxy when it's set
+	dial := net.Dial
+	if cfg.UpstreamProxy != "" {
+		upstreamProxy, err := ParseUpstreamProxy(cfg.UpstreamProxy)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Fatal("Failed to configure upstream proxy")
+		}
+		proxy.Tr.Proxy = http.ProxyURL(upstreamProxy)
+		proxy.ConnectDial = proxy.NewConnectDialToProxyWithHandler(upstreamProxy.String(), func(req *http.Request) {
+			if auth := proxyAuthorization(upstreamProxy); auth != "" {
+				req.Header.Set("Proxy-Authorization", auth)
+			}
+		})
+		dial = proxy.ConnectDial
+	}
+
+	// destination filter and passthrough hosts can be changed at runtime, so they are checked on every request
+	matchesDestination := goproxy.ReqConditionFunc(func(req *http.Request, ctx *goproxy.ProxyCtx) bool {
+		return d.Cfg.MatchesDestination(req.Host) && !d.Cfg.IsPassthrough(req.Host)
+	})
+
+	proxy.OnRequest(matchesDestination).
 		HandleConnect(goproxy.AlwaysMitm)
 
 	// enable curl -p for all hosts on port 80
-	proxy.OnRequest(goproxy.ReqHostMatches(regexp.MustCompile(d.Cfg.Destination))).
+	proxy.OnRequest(matchesDestination).
 		HijackConnect(func(req *http.Request, client net.Conn, ctx *goproxy.ProxyCtx) {
 		defer func() {
 			if e := recover(); e != nil {
@@ -63,7 +151,7 @@ func GetNewHoverfly(cfg *Configuration, cache Cache) (*goproxy.ProxyHttpServer,
 			client.Close()
 		}()
 		clientBuf := bufio.NewReadWriter(bufio.NewReader(client), bufio.NewWriter(client))
-		remote, err := net.Dial("tcp", req.URL.Host)
+		remote, err := dial("tcp", req.URL.Host)
 		orPanic(err)
 		remoteBuf := bufio.NewReadWriter(bufio.NewReader(remote), bufio.NewWriter(remote))
 		for {
@@ -80,13 +168,17 @@ func GetNewHoverfly(cfg *Configuration, cache Cache) (*goproxy.ProxyHttpServer,
 	})
 
 	// processing connections
-	proxy.OnRequest(goproxy.ReqHostMatches(regexp.MustCompile(cfg.Destination))).DoFunc(
+	proxy.OnRequest(matchesDestination).DoFunc(
 		func(r *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
-			return d.processRequest(r)
+			return d.journalRequest(r, func(r *http.Request) (*http.Request, *http.Response) {
+				return d.observeTraffic(r, func(r *http.Request) (*http.Request, *http.Response) {
+					return d.traceRequest(r, d.processRequest)
+				})
+			})
 		})
 
 	// intercepts response
-	proxy.OnResponse(goproxy.ReqHostMatches(regexp.MustCompile(cfg.Destination))).DoFunc(
+	proxy.OnResponse(matchesDestination).DoFunc(
 		func(resp *http.Response, ctx *goproxy.ProxyCtx) *http.Response {
 			d.Counter.Count(d.Cfg.GetMode())
 			return resp
@@ -95,9 +187,9 @@ func GetNewHoverfly(cfg *Configuration, cache Cache) (*goproxy.ProxyHttpServer,
 	proxy.Verbose = d.Cfg.Verbose
 	// proxy starting message
 	log.WithFields(log.Fields{
-		"Destination": d.Cfg.Destination,
-		"ProxyPort":   d.Cfg.ProxyPort,
-		"Mode":        d.Cfg.GetMode(),
+		"Destination":  d.Cfg.GetDestination(),
+		"ProxyAddress": d.Cfg.ProxyListenAddress(),
+		"Mode":         d.Cfg.GetMode(),
 	}).Info("Proxy prepared...")
 
 	return proxy, d
@@ -113,8 +205,31 @@ func hoverflyError(req *http.Request, err error, msg string, statusCode int) *ht
 // returns HTTP response.
 func (d *DBClient) processRequest(req *http.Request) (*http.Request, *http.Response) {
 
+	// websocket upgrades inside intercepted TLS connections can't be handled by Hoverfly, proxy relays them as they are
+	if isWebSocketRequest(req) {
+		return req, nil
+	}
+
+	if response, ok := d.rateLimitResponse(req); ok {
+		return req, response
+	}
+
+	if response, ok := d.errorResponse(req); ok {
+		return req, response
+	}
+
 	mode := d.Cfg.GetMode()
 
+	if mode == VirtualizeMode {
+		if response, ok := d.corsPreflightResponse(req); ok {
+			return req, response
+		}
+	}
+
+	if d.GRPC != nil && isGRPCRequest(req) && (mode == CaptureMode || mode == VirtualizeMode || mode == SpyMode) {
+		return req, d.grpcRequest(req, mode)
+	}
+
 	if mode == CaptureMode {
 		newResponse, err := d.captureRequest(req)
 
@@ -123,7 +238,7 @@ func (d *DBClient) processRequest(req *http.Request) (*http.Request, *http.Respo
 		}
 		log.WithFields(log.Fields{
 			"mode":        mode,
-			"middleware":  d.Cfg.Middleware,
+			"middleware":  d.middlewareName(),
 			"path":        req.URL.Path,
 			"rawQuery":    req.URL.RawQuery,
 			"method":      req.Method,
@@ -133,15 +248,19 @@ func (d *DBClient) processRequest(req *http.Request) (*http.Request, *http.Respo
 		return req, newResponse
 
 	} else if mode == SynthesizeMode {
-		response, err := SynthesizeResponse(req, d.Cfg.Middleware)
+		response, err := synthesizeResponse(req, d.middlewareName(), func(c *Constructor) error {
+			return d.applyMiddleware(c, d.Cfg.Middleware)
+		})
 
 		if err != nil {
 			return req, hoverflyError(req, err, "Could not create synthetic response!", http.StatusServiceUnavailable)
 		}
 
+		d.applyResponseDelay(req)
+
 		log.WithFields(log.Fields{
 			"mode":        mode,
-			"middleware":  d.Cfg.Middleware,
+			"middleware":  d.middlewareName(),
 			"path":        req.URL.Path,
 			"rawQuery":    req.URL.RawQuery,
 			"method":      req.Method,
@@ -150,18 +269,34 @@ func (d *DBClient) processRequest(req *http.Request) (*http.Request, *http.Respo
 
 		return req, response
 
+	} else if mode == SpyMode {
+		response, err := d.spyRequest(req)
+
+		if err != nil {
+			return req, hoverflyError(req, err, "Could not pass request through", http.StatusServiceUnavailable)
+		}
+		return req, response
+
+	} else if mode == DiffMode {
+		response, err := d.diffRequest(req)
+
+		if err != nil {
+			return req, hoverflyError(req, err, "Could not compare responses", http.StatusServiceUnavailable)
+		}
+		return req, response
+
 	} else if mode == ModifyMode {
 		response, err := d.modifyRequestResponse(req, d.Cfg.Middleware)
 
 		if err != nil {
 			log.WithFields(log.Fields{
 				"error":      err.Error(),
-				"middleware": d.Cfg.Middleware,
+				"middleware": d.middlewareName(),
 			}).Error("Got error when performing request modification")
 			return req, hoverflyError(
 				req,
 				err,
-				fmt.Sprintf("Middleware (%s) failed or something else happened!", d.Cfg.Middleware),
+				fmt.Sprintf("Middleware (%s) failed or something else happened!", d.middlewareName()),
 				http.StatusServiceUnavailable)
 		}
 		// returning modified response
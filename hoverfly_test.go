@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -42,6 +45,33 @@ func TestProcessCaptureRequest(t *testing.T) {
 	expect(t, resp.StatusCode, 201)
 }
 
+func TestProcessCaptureRequestStoresPayload(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	r, err := http.NewRequest("GET", "http://somehost.com/path?q=1", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode("capture")
+	dbClient.processRequest(r)
+
+	// captured payload is stored under request fingerprint
+	key := getRequestFingerprint(r, []byte(""))
+	bts, err := dbClient.Cache.Get([]byte(key))
+	expect(t, err, nil)
+
+	payload, err := decodePayload(bts)
+	expect(t, err, nil)
+	expect(t, payload.ID, key)
+	expect(t, payload.Request.Destination, "somehost.com")
+	expect(t, payload.Request.Path, "/path")
+	expect(t, payload.Request.Query, "q=1")
+	expect(t, payload.Request.Method, "GET")
+	expect(t, payload.Response.Status, 201)
+	expect(t, payload.Response.Body, "{'message': 'here'}\n")
+}
+
 func TestProcessVirtualizeRequest(t *testing.T) {
 	server, dbClient := testTools(201, `{'message': 'here'}`)
 	defer server.Close()
@@ -109,3 +139,102 @@ func TestProcessModifyRequest(t *testing.T) {
 
 	expect(t, newResp.StatusCode, 202)
 }
+
+func TestProcessSynthesizeRequestSkipsCache(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	r, err := http.NewRequest("GET", "http://somehost.com", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.Middleware = "./examples/middleware/synthetic_service/synthetic.py"
+	dbClient.Cfg.SetMode("synthesize")
+	_, resp := dbClient.processRequest(r)
+
+	// response comes from middleware, upstream server would have returned 201
+	expect(t, resp.StatusCode, http.StatusOK)
+	body, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	expect(t, strings.Contains(string(body), "I am synthethic service"), true)
+
+	// cache was neither searched nor filled
+	stats := dbClient.Cache.Stats()
+	expect(t, stats.Gets, int64(0))
+	expect(t, stats.Sets, int64(0))
+}
+
+func TestProcessSynthesizeRequestWOMiddleware(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	r, err := http.NewRequest("GET", "http://somehost.com", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode("synthesize")
+	_, resp := dbClient.processRequest(r)
+
+	expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+}
+
+func TestProxyPassesThroughOtherDestinations(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+	}))
+	defer upstream.Close()
+
+	cfg := InitSettings()
+	cfg.SetMode(VirtualizeMode)
+	proxy, _ := GetNewHoverfly(cfg, NewMemoryCache())
+	proxyServer := httptest.NewServer(proxy)
+	defer proxyServer.Close()
+
+	proxyURL, err := url.Parse(proxyServer.URL)
+	expect(t, err, nil)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	// matching destination is virtualized, nothing was captured yet
+	expect(t, cfg.SetDestination("127.0.0.1"), nil)
+	resp, err := client.Get(upstream.URL)
+	expect(t, err, nil)
+	resp.Body.Close()
+	expect(t, resp.StatusCode, DefaultMissStatus)
+
+	// other destinations reach the real service
+	expect(t, cfg.SetDestination(`^api\.example\.com$`), nil)
+	resp, err = client.Get(upstream.URL)
+	expect(t, err, nil)
+	resp.Body.Close()
+	expect(t, resp.StatusCode, 201)
+}
+
+func TestProxyPassesThroughPassthroughHosts(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+	}))
+	defer upstream.Close()
+
+	cfg := InitSettings()
+	cfg.SetMode(VirtualizeMode)
+	proxy, _ := GetNewHoverfly(cfg, NewMemoryCache())
+	proxyServer := httptest.NewServer(proxy)
+	defer proxyServer.Close()
+
+	proxyURL, err := url.Parse(proxyServer.URL)
+	expect(t, err, nil)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	// passthrough hosts reach the real service even though they match destination
+	cfg.AddPassthrough("127.0.0.1")
+	resp, err := client.Get(upstream.URL)
+	expect(t, err, nil)
+	resp.Body.Close()
+	expect(t, resp.StatusCode, 201)
+
+	cfg.RemovePassthrough("127.0.0.1")
+	resp, err = client.Get(upstream.URL)
+	expect(t, err, nil)
+	resp.Body.Close()
+	expect(t, resp.StatusCode, DefaultMissStatus)
+}
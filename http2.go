@@ -0,0 +1,23 @@
+package hoverfly
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// NewHTTP2Handler - wraps proxy so that it also accepts cleartext HTTP/2 (h2c) connections, both with prior
+// knowledge and through HTTP/1.1 upgrade. HTTP/2 requests carry target in :scheme and :authority
+// pseudo-headers instead of absolute URL, so URL is rebuilt for the proxy to recognise them
+func NewHTTP2Handler(proxy http.Handler) http.Handler {
+	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.ProtoMajor == 2 && !req.URL.IsAbs() && req.Host != "" {
+			if req.URL.Scheme == "" {
+				req.URL.Scheme = "http"
+			}
+			req.URL.Host = req.Host
+		}
+		proxy.ServeHTTP(w, req)
+	}), &http2.Server{})
+}
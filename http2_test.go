@@ -0,0 +1,108 @@
+package hoverfly
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+// h2cClient - returns client speaking cleartext HTTP/2 with prior knowledge to given server
+func h2cClient(server *httptest.Server) *http.Client {
+	addr := server.Listener.Addr().String()
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, _ string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+}
+
+func TestHTTP2ClientIsProxied(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+		w.Write([]byte("hello " + r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	cache := NewMemoryCache()
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	proxy, _ := GetNewHoverfly(cfg, cache)
+	proxyServer := httptest.NewServer(NewHTTP2Handler(proxy))
+	defer proxyServer.Close()
+
+	resp, err := h2cClient(proxyServer).Get(upstream.URL + "/h2")
+	expect(t, err, nil)
+	defer resp.Body.Close()
+	expect(t, resp.ProtoMajor, 2)
+	expect(t, resp.StatusCode, 201)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	expect(t, string(body), "hello /h2")
+
+	payloads, err := cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+
+	payload := payloads[0]
+	expect(t, payload.Request.Proto, "HTTP/2.0")
+	expect(t, payload.Request.Path, "/h2")
+	expect(t, payload.Response.Proto, "HTTP/1.1")
+}
+
+func TestHTTP1ClientStillProxied(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+	}))
+	defer upstream.Close()
+
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	proxy, _ := GetNewHoverfly(cfg, NewMemoryCache())
+	proxyServer := httptest.NewServer(NewHTTP2Handler(proxy))
+	defer proxyServer.Close()
+
+	proxyURL, err := url.Parse(proxyServer.URL)
+	expect(t, err, nil)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get(upstream.URL)
+	expect(t, err, nil)
+	resp.Body.Close()
+	expect(t, resp.ProtoMajor, 1)
+	expect(t, resp.StatusCode, 201)
+}
+
+func TestCaptureFromHTTP2Upstream(t *testing.T) {
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(201)
+	}))
+	upstream.EnableHTTP2 = true
+	upstream.StartTLS()
+	defer upstream.Close()
+
+	cache := NewMemoryCache()
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	cfg.UpstreamInsecure = true
+	_, dbClient := GetNewHoverfly(cfg, cache)
+
+	r, err := http.NewRequest("GET", upstream.URL, nil)
+	expect(t, err, nil)
+	_, resp := dbClient.processRequest(r)
+	expect(t, resp.StatusCode, 201)
+
+	payloads, err := cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+
+	payload := payloads[0]
+	expect(t, payload.Response.Proto, "HTTP/2.0")
+}
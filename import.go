@@ -1,8 +1,9 @@
 package hoverfly
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"path"
@@ -24,20 +25,35 @@ func (d *DBClient) Import(uri string) error {
 			"isURL":      isURL(uri),
 			"importFrom": uri,
 		}).Info("URL")
+		if u, err := url.Parse(uri); err == nil && path.Ext(u.Path) == ".har" {
+			return d.ImportHARFromURL(uri)
+		}
 		return d.ImportFromURL(uri)
 	}
-	// assuming file URI is disk location
+	// assuming file URI is disk location, directories are WireMock roots
+	if info, err := os.Stat(uri); err == nil && info.IsDir() {
+		return d.ImportWireMockFromDisk(uri, "")
+	}
 	ext := path.Ext(uri)
-	if ext != ".json" {
-		return fmt.Errorf("Failed to import payloads, only JSON files are acceppted. Given file: %s", uri)
+	if ext != ".json" && ext != ".har" && ext != ".pcap" && ext != ".pcapng" && ext != ".curl" && !isYAMLPath(uri) {
+		return fmt.Errorf("Failed to import payloads, only JSON, YAML, HAR, pcap and curl files are acceppted. Given file: %s", uri)
 	}
 	// checking whether it exists
 	exists, err := exists(uri)
 	if err != nil {
 		return fmt.Errorf("Failed to import payloads from %s. Got error: %s", uri, err.Error())
 	}
+	if exists && ext == ".har" {
+		return d.ImportHARFromDisk(uri)
+	}
+	if exists && (ext == ".pcap" || ext == ".pcapng") {
+		return d.ImportPcapFromDisk(uri)
+	}
+	if exists && ext == ".curl" {
+		return d.ImportCurlFromDisk(uri)
+	}
 	if exists {
-		// file is JSON and it exist
+		// file is JSON or YAML and it exist
 		return d.ImportFromDisk(uri)
 	}
 	return fmt.Errorf("Failed to import payloads, given file '%s' does not exist", uri)
@@ -79,92 +95,212 @@ func exists(path string) (bool, error) {
 }
 
 // ImportFromDisk - takes one string value and tries to open a file, then parse it into recordedRequests structure
-// (which is default format in which Hoverfly exports captured requests) and imports those requests into the database
+// (which is default format in which Hoverfly exports captured requests) and imports those requests into the database.
+// Files with .yaml or .yml extension are read as YAML. OpenAPI documents and WireMock mappings are turned into stub
+// records
 func (d *DBClient) ImportFromDisk(path string) error {
-	payloadsFile, err := os.Open(path)
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("Got error while opening payloads file, error %s", err.Error())
 	}
+	yaml := isYAMLPath(path)
+	if yaml {
+		if data, err = yamlToJSON(data); err != nil {
+			return fmt.Errorf("Got error while parsing payloads file, error %s", err.Error())
+		}
+	}
+	if isOpenAPIDocument(data) {
+		return d.ImportOpenAPI(bytes.NewReader(data), "")
+	}
+	if !yaml && isWireMockMapping(data) {
+		return d.ImportWireMockFromDisk(path, "")
+	}
 
-	var requests recordedRequests
-
-	jsonParser := json.NewDecoder(payloadsFile)
-	if err = jsonParser.Decode(&requests); err != nil {
+	payloads, err := decodeSimulation(data)
+	if err != nil {
 		return fmt.Errorf("Got error while parsing payloads file, error %s", err.Error())
 	}
 
-	return d.ImportPayloads(requests.Data)
+	return d.ImportPayloads(payloads)
 }
 
 // ImportFromURL - takes one string value and tries connect to a remote server, then parse response body into
 // recordedRequests structure (which is default format in which Hoverfly exports captured requests) and
-// imports those requests into the database
+// imports those requests into the database. YAML is recognized by .yaml or .yml extension or by content type.
+// OpenAPI documents and WireMock mappings are turned into stub records
 func (d *DBClient) ImportFromURL(url string) error {
 
 	resp, err := d.HTTP.Get(url)
 	if err != nil {
 		return fmt.Errorf("Failed to fetch given URL, error %s", err.Error())
 	}
+	defer resp.Body.Close()
 
-	var requests recordedRequests
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch given URL, error %s", err.Error())
+	}
+	if isYAMLPath(resp.Request.URL.Path) || isYAMLContentType(resp.Header.Get("Content-Type")) {
+		if data, err = yamlToJSON(data); err != nil {
+			return fmt.Errorf("Got error while parsing payloads, error %s", err.Error())
+		}
+	}
+	if isOpenAPIDocument(data) {
+		return d.ImportOpenAPI(bytes.NewReader(data), "")
+	}
+	if isWireMockMapping(data) {
+		// body files can't be fetched
+		return d.ImportWireMock(bytes.NewReader(data), "", "")
+	}
 
-	jsonParser := json.NewDecoder(resp.Body)
-	if err = jsonParser.Decode(&requests); err != nil {
+	payloads, err := decodeSimulation(data)
+	if err != nil {
 		return fmt.Errorf("Got error while parsing payloads, error %s", err.Error())
 	}
 
-	return d.ImportPayloads(requests.Data)
+	return d.ImportPayloads(payloads)
 }
 
-// ImportPayloads - a function to save given payloads into the database.
+// ImportPayloads - a function to save given payloads into the database. Records with the same ID as records already
+// in the database replace them
 func (d *DBClient) ImportPayloads(payloads []Payload) error {
-	if len(payloads) > 0 {
-		success := 0
-		failed := 0
-		for _, pl := range payloads {
-			// recalculating request hash and storing it in database
-			r := RequestContainer{Details: pl.Request}
-			key := r.Hash()
-
-			// regenerating key
-			pl.ID = key
-
-			bts, err := pl.Encode()
-			if err != nil {
-				log.WithFields(log.Fields{
-					"error": err.Error(),
-				}).Error("Failed to encode payload")
-				failed++
-			} else {
-				// hook
-				var en Entry
-				en.ActionType = ActionTypeRequestCaptured
-				en.Message = "imported"
-				en.Time = time.Now()
-				en.Data = bts
-
-				if err := d.Hooks.Fire(ActionTypeRequestCaptured, &en); err != nil {
-					log.WithFields(log.Fields{
-						"error":      err.Error(),
-						"message":    en.Message,
-						"actionType": ActionTypeRequestCaptured,
-					}).Error("failed to fire hook")
-				}
-
-				d.Cache.Set([]byte(key), bts)
-				if err == nil {
-					success++
-				} else {
-					failed++
-				}
+	// existing records are simply replaced, so they don't have to be looked up
+	_, err := d.mergePayloads(payloads, MergeOverwrite, false)
+	return err
+}
+
+// MergePayloads - saves given payloads into the database next to records that are already there, resolution
+// decides what happens to payloads with the same ID as existing records or other payloads of the batch (see
+// MergeSkip, MergeOverwrite and MergeError). With MergeError nothing is saved when there are conflicts and
+// ErrMergeConflict is returned
+func (d *DBClient) MergePayloads(payloads []Payload, resolution string) (result MergeResult, err error) {
+	return d.mergePayloads(payloads, resolution, true)
+}
+
+// mergePayloads - saves payloads as MergePayloads does, existing records are looked up only when resolution needs
+// them or when overwritten records have to be counted
+func (d *DBClient) mergePayloads(payloads []Payload, resolution string, countOverwritten bool) (result MergeResult, err error) {
+	if len(payloads) == 0 {
+		return result, fmt.Errorf("Bad request. Nothing to import!")
+	}
+	if !IsMergeResolution(resolution) {
+		return result, fmt.Errorf("Unknown conflict resolution '%s', use skip, overwrite or error", resolution)
+	}
+
+	existing := make(map[string]bool)
+	if resolution != MergeOverwrite || countOverwritten {
+		if existing, err = d.Cache.GetAllKeys(); err != nil {
+			return result, fmt.Errorf("Failed to get existing records, error %s", err.Error())
+		}
+	}
+
+	// all records are saved in a single transaction, batch holds index of the pair of each key
+	pairs := make([]KeyValue, 0, len(payloads))
+	batch := make(map[string]int)
+	for _, pl := range payloads {
+		// recalculating request hash and storing it in database
+		key := d.recordKey(pl)
+
+		// regenerating key
+		pl.ID = key
+
+		if pl.Selection != "" && !IsResponseSelection(pl.Selection) {
+			log.WithFields(log.Fields{
+				"selection":   pl.Selection,
+				"path":        pl.Request.Path,
+				"destination": pl.Request.Destination,
+			}).Error("Unknown response selection strategy")
+			result.Failed++
+			continue
+		}
+
+		err := pl.validateMatchers()
+		if err == nil {
+			err = d.validateCustomMatchers(pl)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":       err.Error(),
+				"path":        pl.Request.Path,
+				"destination": pl.Request.Destination,
+			}).Error("Invalid matcher")
+			result.Failed++
+			continue
+		}
+
+		bts, err := pl.Encode()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Failed to encode payload")
+			result.Failed++
+			continue
+		}
+
+		i, duplicate := batch[key]
+		if existing[key] || duplicate {
+			if resolution != MergeOverwrite {
+				result.Conflicts = append(result.Conflicts, key)
+			}
+			if resolution == MergeSkip {
+				result.Skipped++
+				continue
 			}
+			result.Overwritten++
+		} else {
+			result.Added++
 		}
+		if duplicate {
+			// the last payload of the batch wins
+			pairs[i].Value = bts
+			continue
+		}
+		batch[key] = len(pairs)
+		pairs = append(pairs, KeyValue{Key: []byte(key), Value: bts})
+	}
+
+	if resolution == MergeError && len(result.Conflicts) > 0 {
+		log.WithFields(log.Fields{
+			"total":     len(payloads),
+			"conflicts": len(result.Conflicts),
+		}).Warn("Payloads conflict with existing records, nothing imported")
+		result.Added, result.Overwritten = 0, 0
+		return result, ErrMergeConflict
+	}
+
+	err = d.Cache.SetMulti(pairs)
+	d.recordsChanged()
+	if err != nil {
 		log.WithFields(log.Fields{
-			"total":      len(payloads),
-			"successful": success,
-			"failed":     failed,
-		}).Info("payloads imported")
-		return nil
+			"error": err.Error(),
+			"total": len(payloads),
+		}).Error("Failed to save imported payloads")
+		return MergeResult{Failed: len(payloads)}, fmt.Errorf("Failed to save imported payloads, error %s", err.Error())
+	}
+
+	// hooks fire only for saved records
+	for _, pair := range pairs {
+		var en Entry
+		en.ActionType = ActionTypeRequestCaptured
+		en.Message = "imported"
+		en.Time = time.Now()
+		en.Data = pair.Value
+
+		if err := d.Hooks.Fire(ActionTypeRequestCaptured, &en); err != nil {
+			log.WithFields(log.Fields{
+				"error":      err.Error(),
+				"message":    en.Message,
+				"actionType": ActionTypeRequestCaptured,
+			}).Error("failed to fire hook")
+		}
 	}
-	return fmt.Errorf("Bad request. Nothing to import!")
+
+	log.WithFields(log.Fields{
+		"total":       len(payloads),
+		"successful":  len(pairs),
+		"failed":      result.Failed,
+		"overwritten": result.Overwritten,
+		"skipped":     result.Skipped,
+	}).Info("payloads imported")
+	return result, nil
 }
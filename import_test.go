@@ -68,6 +68,27 @@ func TestImportFromDisk(t *testing.T) {
 	expect(t, recordsCount, 5)
 }
 
+func TestImportFromDiskYAML(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.Import("examples/exports/simulation.yaml")
+	expect(t, err, nil)
+	records, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(records), 2)
+
+	byPath := map[string]Payload{}
+	for _, pl := range records {
+		byPath[pl.Request.Path] = pl
+	}
+	expect(t, byPath["/users/1"].Request.Headers["Accept"][0], "application/json")
+	expect(t, byPath["/users/1"].Response.Body, "{\n  \"id\": 1,\n  \"name\": \"hoverfly\"\n}\n")
+	expect(t, byPath["/users"].Request.Body, `{"name": "bee"}`)
+	expect(t, byPath["/users"].Response.Status, 201)
+}
+
 func TestImportFromDiskBlankPath(t *testing.T) {
 	server, dbClient := testTools(201, `{'message': 'here'}`)
 	defer server.Close()
@@ -129,3 +150,109 @@ func TestImportFromURLMalformedJSON(t *testing.T) {
 	// we should get error
 	refute(t, err, nil)
 }
+
+func TestMergePayloads(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	record := func(path, body string) Payload {
+		return Payload{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com", Path: path},
+			Response: ResponseDetails{Status: 200, Body: body},
+		}
+	}
+	expect(t, dbClient.ImportPayloads([]Payload{record("/users", "users"), record("/orders", "orders")}), nil)
+
+	incoming := []Payload{record("/users", "other users"), record("/items", "items")}
+
+	// nothing is imported when records conflict
+	result, err := dbClient.MergePayloads(incoming, MergeError)
+	expect(t, err, ErrMergeConflict)
+	expect(t, len(result.Conflicts), 1)
+	expect(t, result.Conflicts[0], dbClient.recordKey(incoming[0]))
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 2)
+
+	result, err = dbClient.MergePayloads(incoming, MergeSkip)
+	expect(t, err, nil)
+	expect(t, result.Added, 1)
+	expect(t, result.Skipped, 1)
+	count, _ = dbClient.Cache.RecordsCount()
+	expect(t, count, 3)
+	bts, _ := dbClient.Cache.Get([]byte(dbClient.recordKey(incoming[0])))
+	pl, _ := decodePayload(bts)
+	expect(t, pl.Response.Body, "users")
+
+	result, err = dbClient.MergePayloads(incoming, MergeOverwrite)
+	expect(t, err, nil)
+	expect(t, result.Overwritten, 2)
+	expect(t, len(result.Conflicts), 0)
+	bts, _ = dbClient.Cache.Get([]byte(dbClient.recordKey(incoming[0])))
+	pl, _ = decodePayload(bts)
+	expect(t, pl.Response.Body, "other users")
+
+	_, err = dbClient.MergePayloads(incoming, "replace")
+	refute(t, err, nil)
+}
+
+func TestMergePayloadsDuplicatesWithinBatch(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	record := func(body string) Payload {
+		return Payload{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/users"},
+			Response: ResponseDetails{Status: 200, Body: body},
+		}
+	}
+	batch := []Payload{record("first"), record("second")}
+
+	result, err := dbClient.MergePayloads(batch, MergeError)
+	expect(t, err, ErrMergeConflict)
+	expect(t, len(result.Conflicts), 1)
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 0)
+
+	result, err = dbClient.MergePayloads(batch, MergeSkip)
+	expect(t, err, nil)
+	expect(t, result.Added, 1)
+	expect(t, result.Skipped, 1)
+	bts, _ := dbClient.Cache.Get([]byte(dbClient.recordKey(batch[0])))
+	pl, _ := decodePayload(bts)
+	expect(t, pl.Response.Body, "first")
+
+	// the last one wins
+	expect(t, dbClient.Cache.DeleteData(), nil)
+	result, err = dbClient.MergePayloads(batch, MergeOverwrite)
+	expect(t, err, nil)
+	expect(t, result.Added, 1)
+	expect(t, result.Overwritten, 1)
+	bts, _ = dbClient.Cache.Get([]byte(dbClient.recordKey(batch[0])))
+	pl, _ = decodePayload(bts)
+	expect(t, pl.Response.Body, "second")
+}
+
+func TestImportPayloadsFiresHooksOnlyForSavedRecords(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	events, unsubscribe := dbClient.Events.Subscribe()
+	defer unsubscribe()
+
+	payloads := []Payload{{
+		Request:  RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/users"},
+		Response: ResponseDetails{Status: 200, Body: "users"},
+	}}
+
+	raw := dbClient.Cache
+	dbClient.Cache = NewReadOnlyCache(raw)
+	refute(t, dbClient.ImportPayloads(payloads), nil)
+	expect(t, len(events), 0)
+
+	dbClient.Cache = raw
+	expect(t, dbClient.ImportPayloads(payloads), nil)
+	expect(t, len(events), 1)
+}
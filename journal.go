@@ -0,0 +1,228 @@
+package hoverfly
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultJournalSize - how many requests are kept in request journal, the oldest ones are dropped first
+const DefaultJournalSize = 1000
+
+// JournalEntry - request handled by the proxy (or webserver). Matched is only set for requests Hoverfly looked
+// up a record for (virtualize and spy modes), Latency covers time until response was ready to be sent
+type JournalEntry struct {
+	ID          uint64    `json:"id"`
+	Time        time.Time `json:"time"`
+	Mode        string    `json:"mode"`
+	Method      string    `json:"method"`
+	Destination string    `json:"destination"`
+	Path        string    `json:"path"`
+	Query       string    `json:"query,omitempty"`
+	Status      int       `json:"status"`
+	Matched     *bool     `json:"matched,omitempty"`
+	// RecordKey - key of the record that answered the request
+	RecordKey string  `json:"recordKey,omitempty"`
+	LatencyMs float64 `json:"latencyMs"`
+	// Headers - request headers, they are only used to verify requests and aren't returned by the API
+	Headers map[string][]string `json:"-"`
+}
+
+// Journal - bounded log of handled requests, it's kept independently of the simulation and only in memory
+type Journal struct {
+	mu      sync.Mutex
+	entries []JournalEntry
+	// start - index of the oldest entry once the buffer is full
+	start  int
+	lastID uint64
+}
+
+// NewJournal - returns empty journal keeping at most size entries
+func NewJournal(size int) *Journal {
+	if size <= 0 {
+		size = DefaultJournalSize
+	}
+	return &Journal{entries: make([]JournalEntry, 0, size)}
+}
+
+// Add - appends entry to the journal, overwriting the oldest one when journal is full, entry gets the next ID
+func (j *Journal) Add(entry JournalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.lastID++
+	entry.ID = j.lastID
+	if len(j.entries) < cap(j.entries) {
+		j.entries = append(j.entries, entry)
+		return
+	}
+	j.entries[j.start] = entry
+	j.start = (j.start + 1) % len(j.entries)
+}
+
+// Entries - returns page of entries satisfying the filter, the oldest first, and how many entries satisfy it.
+// Limit 0 returns all entries from offset on
+func (j *Journal) Entries(filter JournalFilter, offset, limit int) (entries []JournalEntry, total int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries = []JournalEntry{}
+	for i := range j.entries {
+		entry := j.entries[(j.start+i)%len(j.entries)]
+		if !filter.Match(entry) {
+			continue
+		}
+		if total >= offset && (limit == 0 || len(entries) < limit) {
+			entries = append(entries, entry)
+		}
+		total++
+	}
+	return entries, total
+}
+
+// Len - returns number of entries in the journal
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.entries)
+}
+
+// Clear - removes all entries, IDs keep increasing so clients polling with 'since' don't miss anything
+func (j *Journal) Clear() {
+	j.mu.Lock()
+	j.entries = j.entries[:0]
+	j.start = 0
+	j.mu.Unlock()
+}
+
+// JournalFilter - describes which journal entries should be selected, empty fields match everything. Destination
+// and mode have to be equal, Path is matched as a prefix and Method is compared case-insensitively. From and To
+// select entries of requests received in [From, To) window, Since selects entries added after entry with given ID
+type JournalFilter struct {
+	Mode        string
+	Destination string
+	Path        string
+	Method      string
+	Status      int
+	Matched     *bool
+	From        time.Time
+	To          time.Time
+	Since       uint64
+}
+
+// NewJournalFilter - returns filter based on query parameters (mode, destination, path, method, status, matched,
+// from, to, since), times are given in RFC3339 format (i.e. 2017-01-02T15:04:05Z)
+func NewJournalFilter(query url.Values) (JournalFilter, error) {
+	filter := JournalFilter{
+		Mode:        query.Get("mode"),
+		Destination: query.Get("destination"),
+		Path:        query.Get("path"),
+		Method:      query.Get("method"),
+	}
+
+	var err error
+	if status := query.Get("status"); status != "" {
+		if filter.Status, err = strconv.Atoi(status); err != nil {
+			return filter, fmt.Errorf("invalid 'status' '%s', expected HTTP status code", status)
+		}
+	}
+	if matched := query.Get("matched"); matched != "" {
+		m, err := strconv.ParseBool(matched)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'matched' '%s', expected true or false", matched)
+		}
+		filter.Matched = &m
+	}
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return filter, fmt.Errorf("invalid 'from' time '%s', expected RFC3339 format (i.e. 2017-01-02T15:04:05Z)", from)
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return filter, fmt.Errorf("invalid 'to' time '%s', expected RFC3339 format (i.e. 2017-01-02T15:04:05Z)", to)
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, fmt.Errorf("'from' time has to be before 'to' time")
+	}
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = strconv.ParseUint(since, 10, 64); err != nil {
+			return filter, fmt.Errorf("invalid 'since' '%s', expected entry ID", since)
+		}
+	}
+	return filter, nil
+}
+
+// Match - checks whether entry satisfies all filter conditions
+func (f *JournalFilter) Match(entry JournalEntry) bool {
+	if f.Mode != "" && f.Mode != entry.Mode {
+		return false
+	}
+	if f.Destination != "" && f.Destination != entry.Destination {
+		return false
+	}
+	if f.Path != "" && !strings.HasPrefix(entry.Path, f.Path) {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(f.Method, entry.Method) {
+		return false
+	}
+	if f.Status != 0 && f.Status != entry.Status {
+		return false
+	}
+	if f.Matched != nil && (entry.Matched == nil || *entry.Matched != *f.Matched) {
+		return false
+	}
+	if !f.From.IsZero() && entry.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !entry.Time.Before(f.To) {
+		return false
+	}
+	return entry.ID > f.Since
+}
+
+// journalEntryKey - context key of journal entry of the request being handled
+type journalEntryKey struct{}
+
+// markJournalMatch - notes in journal entry of the request whether a record was found for it
+func markJournalMatch(req *http.Request, matched bool, key string) {
+	if entry, ok := req.Context().Value(journalEntryKey{}).(*JournalEntry); ok {
+		entry.Matched = &matched
+		entry.RecordKey = key
+	}
+}
+
+// journalRequest - handles request with given function and adds it to the request journal
+func (d *DBClient) journalRequest(req *http.Request, handle func(*http.Request) (*http.Request, *http.Response)) (*http.Request, *http.Response) {
+	if d.Journal == nil {
+		return handle(req)
+	}
+
+	entry := &JournalEntry{
+		Time:        time.Now(),
+		Mode:        d.Cfg.GetMode(),
+		Method:      req.Method,
+		Destination: req.Host,
+		Path:        req.URL.Path,
+		Query:       req.URL.RawQuery,
+		Headers:     copyHeaders(req.Header),
+	}
+
+	req, resp := handle(req.WithContext(context.WithValue(req.Context(), journalEntryKey{}, entry)))
+	if resp == nil {
+		// relayed as it is (i.e. websocket upgrade)
+		return req, resp
+	}
+
+	entry.Status = resp.StatusCode
+	entry.LatencyMs = float64(time.Since(entry.Time)) / float64(time.Millisecond)
+	d.Journal.Add(*entry)
+	return req, resp
+}
@@ -0,0 +1,172 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// entriesOf - returns all journal entries
+func entriesOf(journal *Journal) []JournalEntry {
+	return entriesWhere(journal, JournalFilter{})
+}
+
+// entriesWhere - returns all journal entries satisfying the filter
+func entriesWhere(journal *Journal, filter JournalFilter) []JournalEntry {
+	entries, _ := journal.Entries(filter, 0, 0)
+	return entries
+}
+
+func TestJournalKeepsNewestEntries(t *testing.T) {
+	journal := NewJournal(3)
+	for _, path := range []string{"/1", "/2", "/3", "/4", "/5"} {
+		journal.Add(JournalEntry{Path: path})
+	}
+
+	entries := entriesOf(journal)
+	expect(t, len(entries), 3)
+	expect(t, entries[0].Path, "/3")
+	expect(t, entries[0].ID, uint64(3))
+	expect(t, entries[2].Path, "/5")
+	expect(t, entries[2].ID, uint64(5))
+}
+
+func TestJournalClear(t *testing.T) {
+	journal := NewJournal(3)
+	journal.Add(JournalEntry{Path: "/1"})
+	journal.Add(JournalEntry{Path: "/2"})
+	journal.Clear()
+	expect(t, journal.Len(), 0)
+
+	// IDs are not reused
+	journal.Add(JournalEntry{Path: "/3"})
+	entries := entriesOf(journal)
+	expect(t, len(entries), 1)
+	expect(t, entries[0].ID, uint64(3))
+}
+
+func TestJournalEntriesFilter(t *testing.T) {
+	matched, missed := true, false
+	journal := NewJournal(10)
+	journal.Add(JournalEntry{Mode: VirtualizeMode, Method: "GET", Destination: "a.com", Path: "/orders/1", Status: 200, Matched: &matched})
+	journal.Add(JournalEntry{Mode: VirtualizeMode, Method: "POST", Destination: "a.com", Path: "/orders", Status: 412, Matched: &missed})
+	journal.Add(JournalEntry{Mode: CaptureMode, Method: "GET", Destination: "b.com", Path: "/users", Status: 200})
+
+	filter, err := NewJournalFilter(url.Values{"matched": {"false"}})
+	expect(t, err, nil)
+	entries := entriesWhere(journal, filter)
+	expect(t, len(entries), 1)
+	expect(t, entries[0].Status, 412)
+
+	filter, err = NewJournalFilter(url.Values{"path": {"/orders"}, "method": {"get"}})
+	expect(t, err, nil)
+	entries = entriesWhere(journal, filter)
+	expect(t, len(entries), 1)
+	expect(t, entries[0].Path, "/orders/1")
+
+	filter, err = NewJournalFilter(url.Values{"mode": {CaptureMode}, "status": {"200"}})
+	expect(t, err, nil)
+	expect(t, len(entriesWhere(journal, filter)), 1)
+
+	filter, err = NewJournalFilter(url.Values{"since": {"2"}})
+	expect(t, err, nil)
+	entries = entriesWhere(journal, filter)
+	expect(t, len(entries), 1)
+	expect(t, entries[0].ID, uint64(3))
+}
+
+func TestJournalEntriesTimeRange(t *testing.T) {
+	journal := NewJournal(10)
+	start := time.Date(2017, 1, 2, 15, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		journal.Add(JournalEntry{Time: start.Add(time.Duration(i) * time.Minute)})
+	}
+
+	filter, err := NewJournalFilter(url.Values{"from": {"2017-01-02T15:01:00Z"}, "to": {"2017-01-02T15:03:00Z"}})
+	expect(t, err, nil)
+	entries := entriesWhere(journal, filter)
+	expect(t, len(entries), 2)
+	expect(t, entries[0].ID, uint64(2))
+	expect(t, entries[1].ID, uint64(3))
+}
+
+func TestJournalEntriesPage(t *testing.T) {
+	journal := NewJournal(10)
+	for i := 0; i < 5; i++ {
+		journal.Add(JournalEntry{Method: "GET"})
+	}
+	journal.Add(JournalEntry{Method: "POST"})
+
+	entries, total := journal.Entries(JournalFilter{Method: "GET"}, 1, 2)
+	expect(t, total, 5)
+	expect(t, len(entries), 2)
+	expect(t, entries[0].ID, uint64(2))
+	expect(t, entries[1].ID, uint64(3))
+
+	entries, total = journal.Entries(JournalFilter{Method: "GET"}, 4, 0)
+	expect(t, total, 5)
+	expect(t, len(entries), 1)
+	expect(t, entries[0].ID, uint64(5))
+}
+
+func TestNewJournalFilterInvalid(t *testing.T) {
+	for _, query := range []url.Values{
+		{"status": {"ok"}},
+		{"matched": {"maybe"}},
+		{"since": {"-1"}},
+		{"from": {"yesterday"}},
+		{"from": {"2017-01-02T15:00:00Z"}, "to": {"2017-01-02T14:00:00Z"}},
+	} {
+		_, err := NewJournalFilter(query)
+		refute(t, err, nil)
+	}
+}
+
+func TestJournalRequestMatched(t *testing.T) {
+	server, dbClient := testTools(201, `{"message": "here"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	req, _ := http.NewRequest("GET", "http://example.com/journal", nil)
+	_, resp := dbClient.journalRequest(req, dbClient.processRequest)
+	expect(t, resp.StatusCode, 201)
+
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	req, _ = http.NewRequest("GET", "http://example.com/journal", nil)
+	dbClient.journalRequest(req, dbClient.processRequest)
+	req, _ = http.NewRequest("GET", "http://example.com/unknown", nil)
+	dbClient.journalRequest(req, dbClient.processRequest)
+
+	entries := entriesOf(dbClient.Journal)
+	expect(t, len(entries), 3)
+
+	expect(t, entries[0].Mode, CaptureMode)
+	expect(t, entries[0].Status, 201)
+	expect(t, entries[0].Destination, "example.com")
+	expect(t, entries[0].Path, "/journal")
+	// nothing is looked up in capture mode
+	expect(t, entries[0].Matched == nil, true)
+
+	expect(t, entries[1].Mode, VirtualizeMode)
+	expect(t, entries[1].Status, 201)
+	expect(t, *entries[1].Matched, true)
+	refute(t, entries[1].RecordKey, "")
+
+	expect(t, entries[2].Status, dbClient.Cfg.GetMissStatus())
+	expect(t, *entries[2].Matched, false)
+	expect(t, entries[2].RecordKey, "")
+}
+
+func TestJournalRequestDisabled(t *testing.T) {
+	server, dbClient := testTools(201, `{"message": "here"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Journal = nil
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	req, _ := http.NewRequest("GET", "http://example.com/journal", nil)
+	_, resp := dbClient.journalRequest(req, dbClient.processRequest)
+	expect(t, resp.StatusCode, 201)
+}
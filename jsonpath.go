@@ -0,0 +1,302 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Comparison operators of body matcher expressions
+var matcherOperators = []string{"==", "!=", "<=", ">=", "=~", "<", ">"}
+
+// splitMatcherExpression - splits expression into path, operator and value, operators inside quotes and brackets
+// are part of the path. Expression without operator only checks that path exists
+func splitMatcherExpression(expression string) (path, operator, value string) {
+	var quote rune
+	depth := 0
+	for i, c := range expression {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case depth == 0:
+			for _, op := range matcherOperators {
+				if strings.HasPrefix(expression[i:], op) {
+					return strings.TrimSpace(expression[:i]), op, strings.TrimSpace(expression[i+len(op):])
+				}
+			}
+		}
+	}
+	return strings.TrimSpace(expression), "", ""
+}
+
+// matcherComparison - operator and expected value of body matcher expression
+type matcherComparison struct {
+	operator string
+	// expected - decoded JSON literal
+	expected interface{}
+	re       *regexp.Regexp
+}
+
+// parseMatcherComparison - parses operator and JSON literal value, =~ needs string with regular expression
+func parseMatcherComparison(operator, value string) (matcherComparison, error) {
+	comparison := matcherComparison{operator: operator}
+	if operator == "" {
+		return comparison, nil
+	}
+
+	if err := json.Unmarshal([]byte(value), &comparison.expected); err != nil {
+		return comparison, fmt.Errorf("invalid value '%s', use JSON literal i.e. \"express\", 10 or true", value)
+	}
+
+	switch operator {
+	case "=~":
+		pattern, ok := comparison.expected.(string)
+		if !ok {
+			return comparison, fmt.Errorf("%s needs regular expression in quotes, got '%s'", operator, value)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return comparison, fmt.Errorf("invalid regular expression '%s': %s", pattern, err.Error())
+		}
+		comparison.re = re
+	case "<", "<=", ">", ">=":
+		switch comparison.expected.(type) {
+		case float64, string:
+		default:
+			return comparison, fmt.Errorf("%s needs number or string, got '%s'", operator, value)
+		}
+	}
+	return comparison, nil
+}
+
+// satisfiedBy - checks whether any of the values satisfies comparison, without operator any value is enough
+func (c matcherComparison) satisfiedBy(values []interface{}) bool {
+	for _, value := range values {
+		if c.satisfies(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// satisfies - compares single value, numbers are compared with numbers and strings with strings
+func (c matcherComparison) satisfies(actual interface{}) bool {
+	switch c.operator {
+	case "":
+		return true
+	case "==":
+		return reflect.DeepEqual(actual, c.expected)
+	case "!=":
+		return !reflect.DeepEqual(actual, c.expected)
+	case "=~":
+		s, ok := actual.(string)
+		return ok && c.re.MatchString(s)
+	}
+
+	var cmp int
+	switch expected := c.expected.(type) {
+	case float64:
+		a, ok := actual.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case a < expected:
+			cmp = -1
+		case a > expected:
+			cmp = 1
+		}
+	case string:
+		a, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(a, expected)
+	}
+
+	switch c.operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// jsonPathStep - one step of JSONPath, object key, array index or wildcard
+type jsonPathStep struct {
+	key       string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool
+}
+
+// JSONPathMatcher - JSONPath expression incoming request body has to satisfy, i.e. $.order.type == "express",
+// $.items[*].sku =~ "^A" or just $.order.id when the field only has to exist. Dot and bracket notation, array
+// indexes (negative ones count from the end), wildcards and recursive descent (..name) are supported
+type JSONPathMatcher struct {
+	steps      []jsonPathStep
+	comparison matcherComparison
+}
+
+// ParseJSONPathMatcher - parses JSONPath matcher expression
+func ParseJSONPathMatcher(expression string) (*JSONPathMatcher, error) {
+	path, operator, value := splitMatcherExpression(expression)
+
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath '%s': %s", path, err.Error())
+	}
+	comparison, err := parseMatcherComparison(operator, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath matcher '%s': %s", expression, err.Error())
+	}
+	return &JSONPathMatcher{steps: steps, comparison: comparison}, nil
+}
+
+// parseJSONPath - parses path starting with $
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path has to start with $")
+	}
+
+	var steps []jsonPathStep
+	rest := path[1:]
+	for rest != "" {
+		var step jsonPathStep
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			rest = rest[2:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			step.key, rest = rest[:end], rest[end:]
+			if step.key == "" || step.key == "*" {
+				return nil, fmt.Errorf("recursive descent needs key name")
+			}
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			step.key, rest = rest[:end], rest[end:]
+			if step.key == "" {
+				return nil, fmt.Errorf("empty key")
+			}
+			step.wildcard = step.key == "*"
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket")
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case selector == "*":
+				step.wildcard = true
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				step.key = selector[1 : len(selector)-1]
+			default:
+				i, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("invalid selector [%s]", selector)
+				}
+				step.index, step.isIndex = i, true
+			}
+		default:
+			return nil, fmt.Errorf("unexpected '%s'", rest)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// Select - returns values path selects in decoded JSON document
+func (m *JSONPathMatcher) Select(document interface{}) []interface{} {
+	values := []interface{}{document}
+	for _, step := range m.steps {
+		var next []interface{}
+		for _, value := range values {
+			next = append(next, step.apply(value)...)
+		}
+		values = next
+	}
+	return values
+}
+
+// apply - returns values step selects in given value
+func (s jsonPathStep) apply(value interface{}) []interface{} {
+	if s.recursive {
+		return descendants(value, s.key)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if s.wildcard {
+			selected := make([]interface{}, 0, len(v))
+			for _, child := range v {
+				selected = append(selected, child)
+			}
+			return selected
+		}
+		if child, ok := v[s.key]; ok && !s.isIndex {
+			return []interface{}{child}
+		}
+	case []interface{}:
+		if s.wildcard {
+			return v
+		}
+		if s.isIndex {
+			i := s.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				return []interface{}{v[i]}
+			}
+		}
+	}
+	return nil
+}
+
+// descendants - returns values of key in value and all its descendants
+func descendants(value interface{}, key string) []interface{} {
+	var found []interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if child, ok := v[key]; ok {
+			found = append(found, child)
+		}
+		for _, child := range v {
+			found = append(found, descendants(child, key)...)
+		}
+	case []interface{}:
+		for _, child := range v {
+			found = append(found, descendants(child, key)...)
+		}
+	}
+	return found
+}
+
+// Matches - checks whether JSON body satisfies the matcher
+func (m *JSONPathMatcher) Matches(document interface{}) bool {
+	return m.comparison.satisfiedBy(m.Select(document))
+}
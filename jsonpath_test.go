@@ -0,0 +1,61 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const jsonPathDocument = `{
+	"order": {"id": 7, "type": "express", "total": 49.5, "gift": false},
+	"items": [{"sku": "A-1", "qty": 2}, {"sku": "B-2", "qty": 1}],
+	"customer": {"address": {"city": "London"}}
+}`
+
+func jsonPathMatches(t *testing.T, expression string) bool {
+	var document interface{}
+	expect(t, json.Unmarshal([]byte(jsonPathDocument), &document), nil)
+	matcher, err := ParseJSONPathMatcher(expression)
+	expect(t, err, nil)
+	return matcher.Matches(document)
+}
+
+func TestJSONPathMatcherComparisons(t *testing.T) {
+	expect(t, jsonPathMatches(t, `$.order.type == "express"`), true)
+	expect(t, jsonPathMatches(t, `$.order.type == "standard"`), false)
+	expect(t, jsonPathMatches(t, `$.order.type != "standard"`), true)
+	expect(t, jsonPathMatches(t, `$['order']['id'] == 7`), true)
+	expect(t, jsonPathMatches(t, `$.order.total > 40`), true)
+	expect(t, jsonPathMatches(t, `$.order.total <= 40`), false)
+	expect(t, jsonPathMatches(t, `$.order.gift == false`), true)
+	expect(t, jsonPathMatches(t, `$.order.type =~ "^exp"`), true)
+	// numbers aren't compared with strings
+	expect(t, jsonPathMatches(t, `$.order.id == "7"`), false)
+}
+
+func TestJSONPathMatcherPaths(t *testing.T) {
+	expect(t, jsonPathMatches(t, `$.order.id`), true)
+	expect(t, jsonPathMatches(t, `$.order.coupon`), false)
+	expect(t, jsonPathMatches(t, `$.items[1].sku == "B-2"`), true)
+	expect(t, jsonPathMatches(t, `$.items[-1].qty == 1`), true)
+	expect(t, jsonPathMatches(t, `$.items[5]`), false)
+	expect(t, jsonPathMatches(t, `$.items[*].sku == "A-1"`), true)
+	expect(t, jsonPathMatches(t, `$.items.*.qty > 1`), true)
+	expect(t, jsonPathMatches(t, `$..city == "London"`), true)
+	expect(t, jsonPathMatches(t, `$..sku == "C-3"`), false)
+}
+
+func TestJSONPathMatcherInvalid(t *testing.T) {
+	for _, expression := range []string{
+		`order.type == "express"`,
+		`$.order.type == express`,
+		`$.items[x]`,
+		`$.items[0`,
+		`$.order.type =~ 10`,
+		`$.order.type =~ "("`,
+		`$.order.total > true`,
+		`$..`,
+	} {
+		_, err := ParseJSONPathMatcher(expression)
+		refute(t, err, nil)
+	}
+}
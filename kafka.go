@@ -0,0 +1,161 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// kafka batching, records are published when batch is full or interval passes, whichever comes first
+const (
+	kafkaBatchSize     = 100
+	kafkaQueueSize     = 1000
+	kafkaFlushInterval = time.Second
+)
+
+// kafkaContentType - JSON embedded format of Kafka REST Proxy v2 API
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// kafkaRecord - record produced to Kafka, payload ID is the key so captures of one request land in one partition
+type kafkaRecord struct {
+	Key   string  `json:"key"`
+	Value Payload `json:"value"`
+}
+
+// KafkaPublisher - hook that publishes captured payloads as JSON to Kafka topic through Kafka REST Proxy (or any
+// service speaking its v2 API, i.e. Redpanda's HTTP proxy). Payloads that can't be published are dropped, publishing
+// never slows the proxy down
+type KafkaPublisher struct {
+	// URL - topic endpoint of REST Proxy, i.e. "http://localhost:8082/topics/hoverfly-captures"
+	URL  string
+	HTTP *http.Client
+
+	records   chan kafkaRecord
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewKafkaPublisher - returns publisher producing to given topic through REST Proxy at given address, it has to be
+// closed to publish remaining payloads
+func NewKafkaPublisher(restURL, topic string) (*KafkaPublisher, error) {
+	u, err := url.Parse(restURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Kafka REST Proxy URL '%s', expected absolute http or https URL", restURL)
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("topic for captured payloads not specified")
+	}
+
+	p := &KafkaPublisher{
+		URL:     strings.TrimSuffix(restURL, "/") + "/topics/" + url.PathEscape(topic),
+		HTTP:    &http.Client{Timeout: 10 * time.Second},
+		records: make(chan kafkaRecord, kafkaQueueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go p.run(kafkaFlushInterval)
+	return p, nil
+}
+
+// ActionTypes - publisher is only interested in captured requests
+func (p *KafkaPublisher) ActionTypes() []ActionType {
+	return []ActionType{ActionTypeRequestCaptured}
+}
+
+// Fire - queues captured payload, imported records are not traffic so they are skipped
+func (p *KafkaPublisher) Fire(entry *Entry) error {
+	if entry.Message != "captured" {
+		return nil
+	}
+	payload, err := decodePayload(entry.Data)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case p.records <- kafkaRecord{Key: payload.ID, Value: *payload}:
+	default:
+		log.WithFields(log.Fields{
+			"destination": payload.Request.Destination,
+			"path":        payload.Request.Path,
+		}).Warn("Kafka queue is full, dropping captured payload")
+	}
+	return nil
+}
+
+// run - publishes queued records in batches until publisher is closed
+func (p *KafkaPublisher) run(interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var batch []kafkaRecord
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := p.publish(batch); err != nil {
+			log.WithFields(log.Fields{
+				"error":   err.Error(),
+				"url":     p.URL,
+				"records": len(batch),
+			}).Warn("Failed to publish captured payloads to Kafka")
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case r := <-p.records:
+			batch = append(batch, r)
+			if len(batch) >= kafkaBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-p.stop:
+			for {
+				select {
+				case r := <-p.records:
+					batch = append(batch, r)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close - publishes remaining payloads and stops the publisher
+func (p *KafkaPublisher) Close() {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+	})
+	<-p.done
+}
+
+// publish - produces records to the topic
+func (p *KafkaPublisher) publish(records []kafkaRecord) error {
+	body, err := json.Marshal(map[string][]kafkaRecord{"records": records})
+	if err != nil {
+		return err
+	}
+	resp, err := p.HTTP.Post(p.URL, kafkaContentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("REST Proxy responded with %s", resp.Status)
+	}
+	return nil
+}
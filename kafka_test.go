@@ -0,0 +1,77 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// restProxy - collects records produced through it
+type restProxy struct {
+	mu          sync.Mutex
+	paths       []string
+	contentType string
+	records     []kafkaRecord
+}
+
+func (p *restProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Records []kafkaRecord `json:"records"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paths = append(p.paths, r.URL.Path)
+	p.contentType = r.Header.Get("Content-Type")
+	p.records = append(p.records, body.Records...)
+}
+
+func TestNewKafkaPublisherInvalid(t *testing.T) {
+	_, err := NewKafkaPublisher("localhost:8082", "captures")
+	refute(t, err, nil)
+
+	_, err = NewKafkaPublisher("http://localhost:8082", "")
+	refute(t, err, nil)
+}
+
+func TestKafkaPublisherPublishesCaptures(t *testing.T) {
+	server, dbClient := testTools(201, `{"message": "here"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	proxy := &restProxy{}
+	rest := httptest.NewServer(proxy)
+	defer rest.Close()
+
+	publisher, err := NewKafkaPublisher(rest.URL+"/", "hoverfly-captures")
+	expect(t, err, nil)
+	dbClient.AddHook(publisher)
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	req, _ := http.NewRequest("POST", "http://example.com/orders", strings.NewReader("hello"))
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 201)
+
+	// imported records are not captured traffic
+	err = dbClient.ImportPayloads([]Payload{{
+		Request:  RequestDetails{Destination: "example.com", Path: "/imported", Method: "GET"},
+		Response: ResponseDetails{Status: 200},
+	}})
+	expect(t, err, nil)
+
+	publisher.Close()
+
+	expect(t, len(proxy.paths), 1)
+	expect(t, proxy.paths[0], "/topics/hoverfly-captures")
+	expect(t, proxy.contentType, kafkaContentType)
+	expect(t, len(proxy.records), 1)
+	expect(t, proxy.records[0].Value.Request.Path, "/orders")
+	expect(t, proxy.records[0].Value.Request.Body, "hello")
+	expect(t, proxy.records[0].Value.Response.Status, 201)
+	expect(t, proxy.records[0].Key, proxy.records[0].Value.ID)
+	refute(t, proxy.records[0].Key, "")
+}
@@ -0,0 +1,90 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Key strategies, they decide which parts of request make its cache key
+const (
+	// KeyMethodURL - method, destination, path and query, requests differing only in body get the same response
+	KeyMethodURL = "method-url"
+	// KeyMethodURLBody - method, destination, path, query and body (plus matched headers), the default
+	KeyMethodURLBody = "method-url-body"
+	// KeyFullRequest - method, destination, path, query, body and all request headers
+	KeyFullRequest = "full-request"
+)
+
+// KeyGenerator - turns request into cache key, requests with the same key are answered with the same record. Request
+// is already normalized, ignored query parameters are removed and body is in the form it's matched in
+type KeyGenerator interface {
+	Key(request RequestContainer) string
+}
+
+// KeyGeneratorFunc - adapter that allows using ordinary functions as key generators
+type KeyGeneratorFunc func(request RequestContainer) string
+
+// Key - calls f(request)
+func (f KeyGeneratorFunc) Key(request RequestContainer) string {
+	return f(request)
+}
+
+// IsKeyStrategy - checks whether key strategy is known
+func IsKeyStrategy(strategy string) bool {
+	switch strategy {
+	case KeyMethodURL, KeyMethodURLBody, KeyFullRequest:
+		return true
+	}
+	return false
+}
+
+// NewKeyGenerator - returns built-in key generator of given strategy
+func NewKeyGenerator(strategy string) (KeyGenerator, error) {
+	switch strategy {
+	case KeyMethodURL:
+		return KeyGeneratorFunc(methodURLKey), nil
+	case KeyMethodURLBody, "":
+		return KeyGeneratorFunc(methodURLBodyKey), nil
+	case KeyFullRequest:
+		return KeyGeneratorFunc(fullRequestKey), nil
+	}
+	return nil, fmt.Errorf("unknown key strategy '%s', use %s, %s or %s", strategy, KeyMethodURL, KeyMethodURLBody,
+		KeyFullRequest)
+}
+
+// methodURLBodyKey - hash of request fingerprint, keys are the same as the ones of records captured before key
+// strategies were added
+func methodURLBodyKey(request RequestContainer) string {
+	return request.Hash()
+}
+
+// methodURLKey - hash of request fingerprint without body
+func methodURLKey(request RequestContainer) string {
+	request.Details.Body = ""
+	return request.Hash()
+}
+
+// fullRequestKey - hash of request fingerprint with every request header
+func fullRequestKey(request RequestContainer) string {
+	names := make([]string, 0, len(request.Details.Headers))
+	seen := make(map[string]bool)
+	for name := range request.Details.Headers {
+		canonical := http.CanonicalHeaderKey(name)
+		if !seen[canonical] {
+			seen[canonical] = true
+			names = append(names, canonical)
+		}
+	}
+	sort.Strings(names)
+	request.MatchHeaders = names
+	return request.Hash()
+}
+
+// keyGenerator - returns key generator of the client, default strategy is used when none is set
+func (d *DBClient) keyGenerator() KeyGenerator {
+	if d.KeyGenerator != nil {
+		return d.KeyGenerator
+	}
+	return KeyGeneratorFunc(methodURLBodyKey)
+}
@@ -0,0 +1,81 @@
+package hoverfly
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestKeyStrategies(t *testing.T) {
+	request := RequestContainer{Details: RequestDetails{
+		Method:      "POST",
+		Destination: "api.example.com",
+		Path:        "/orders",
+		Body:        `{"id": 1}`,
+		Headers:     map[string][]string{"Accept": {"application/json"}},
+	}}
+	withOtherBody := request
+	withOtherBody.Details.Body = `{"id": 2}`
+	withOtherHeader := request
+	withOtherHeader.Details.Headers = map[string][]string{"Accept": {"text/html"}}
+
+	generator, err := NewKeyGenerator(KeyMethodURLBody)
+	expect(t, err, nil)
+	expect(t, generator.Key(request), request.Hash())
+	refute(t, generator.Key(request), generator.Key(withOtherBody))
+	expect(t, generator.Key(request), generator.Key(withOtherHeader))
+
+	generator, _ = NewKeyGenerator(KeyMethodURL)
+	expect(t, generator.Key(request), generator.Key(withOtherBody))
+
+	generator, _ = NewKeyGenerator(KeyFullRequest)
+	refute(t, generator.Key(request), generator.Key(withOtherBody))
+	refute(t, generator.Key(request), generator.Key(withOtherHeader))
+
+	_, err = NewKeyGenerator("random")
+	refute(t, err, nil)
+}
+
+func TestMethodURLKeyStrategy(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.KeyGenerator, _ = NewKeyGenerator(KeyMethodURL)
+
+	storeTestPayload(dbClient, "POST", "http://api.example.com/events", `{"ts": 1}`, 202, "accepted")
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("POST", "http://api.example.com/events", strings.NewReader(`{"ts": 2}`))
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 202)
+}
+
+func TestCustomKeyGenerator(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	// version prefix of the path doesn't matter
+	dbClient.KeyGenerator = KeyGeneratorFunc(func(request RequestContainer) string {
+		request.Details.Path = strings.TrimPrefix(strings.TrimPrefix(request.Details.Path, "/v1"), "/v2")
+		return request.Hash()
+	})
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/v1/users", "", 200, "users")
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/v2/users", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 200)
+}
+
+func TestSettingsKeyStrategyEnv(t *testing.T) {
+	defer os.Setenv("HoverflyKeyStrategy", "")
+
+	os.Setenv("HoverflyKeyStrategy", KeyFullRequest)
+	expect(t, InitSettings().KeyStrategy, KeyFullRequest)
+
+	os.Setenv("HoverflyKeyStrategy", "random")
+	expect(t, InitSettings().KeyStrategy, "")
+}
@@ -0,0 +1,406 @@
+package hoverfly
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Subsystems logs come from, each of them can have its own log level
+const (
+	LogSubsystemProxy      = "proxy"
+	LogSubsystemCache      = "cache"
+	LogSubsystemAdmin      = "admin"
+	LogSubsystemMiddleware = "middleware"
+)
+
+// LogSubsystems - all subsystems, in the order they are listed in
+var LogSubsystems = []string{LogSubsystemProxy, LogSubsystemCache, LogSubsystemAdmin, LogSubsystemMiddleware}
+
+// Log sink types and formats
+const (
+	LogSinkStderr = "stderr"
+	LogSinkFile   = "file"
+	LogSinkSyslog = "syslog"
+
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// Defaults of file sink rotation
+const (
+	DefaultLogFileMaxSize    = 100
+	DefaultLogFileMaxBackups = 3
+)
+
+// logSubsystem - returns subsystem source file belongs to, anything that isn't cache, admin interface or
+// middleware is counted as proxy
+func logSubsystem(file string) string {
+	name := filepath.Base(file)
+	switch {
+	case strings.HasPrefix(name, "cache"):
+		return LogSubsystemCache
+	case strings.HasPrefix(name, "admin"), name == "auth.go":
+		return LogSubsystemAdmin
+	case strings.HasPrefix(name, "middleware"), name == "manipulation.go":
+		return LogSubsystemMiddleware
+	}
+	return LogSubsystemProxy
+}
+
+// callerSubsystem - returns subsystem of the code that logged the entry, the first caller outside of logrus and
+// this file
+func callerSubsystem() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.Function, "Sirupsen/logrus.") && !strings.HasSuffix(frame.File, "logging.go") {
+			return logSubsystem(frame.File)
+		}
+		if !more {
+			return LogSubsystemProxy
+		}
+	}
+}
+
+// LogSinkConfig - where logs are written to. File sinks are rotated once they grow over MaxSize megabytes, MaxBackups
+// rotated files are kept. Syslog sink sends logs to Address (i.e. "udp://logs.example.com:514"), local syslog daemon
+// is used when it's empty
+type LogSinkConfig struct {
+	Type       string `json:"type"`
+	Format     string `json:"format"`
+	Path       string `json:"path,omitempty"`
+	MaxSize    int    `json:"maxSize,omitempty"`
+	MaxBackups int    `json:"maxBackups,omitempty"`
+	Address    string `json:"address,omitempty"`
+}
+
+// logWriter - writes formatted log entry, level is needed by syslog
+type logWriter interface {
+	write(level log.Level, line []byte) error
+	Close() error
+}
+
+// streamWriter - writes entries to a stream
+type streamWriter struct {
+	w io.Writer
+}
+
+func (s streamWriter) write(level log.Level, line []byte) error {
+	_, err := s.w.Write(line)
+	return err
+}
+
+func (s streamWriter) Close() error {
+	return nil
+}
+
+// logSink - configured sink with its formatter and writer
+type logSink struct {
+	config    LogSinkConfig
+	formatter log.Formatter
+	writer    logWriter
+}
+
+// newLogSink - opens sink described by config
+func newLogSink(config LogSinkConfig) (*logSink, error) {
+	sink := &logSink{config: config}
+	switch config.Format {
+	case "", LogFormatJSON:
+		sink.config.Format = LogFormatJSON
+		sink.formatter = &log.JSONFormatter{}
+	case LogFormatText:
+		sink.formatter = &log.TextFormatter{DisableColors: true}
+	default:
+		return nil, fmt.Errorf("unknown log format '%s', use %s or %s", config.Format, LogFormatJSON, LogFormatText)
+	}
+
+	var err error
+	switch config.Type {
+	case LogSinkStderr:
+		sink.writer = streamWriter{w: os.Stderr}
+	case LogSinkFile:
+		if config.Path == "" {
+			return nil, fmt.Errorf("file log sink needs path")
+		}
+		if sink.config.MaxSize <= 0 {
+			sink.config.MaxSize = DefaultLogFileMaxSize
+		}
+		if sink.config.MaxBackups < 0 {
+			sink.config.MaxBackups = DefaultLogFileMaxBackups
+		}
+		sink.writer, err = newRotatingFile(config.Path, int64(sink.config.MaxSize)*1024*1024, sink.config.MaxBackups)
+	case LogSinkSyslog:
+		sink.writer, err = newSyslogWriter(config.Address)
+	default:
+		return nil, fmt.Errorf("unknown log sink '%s', use %s, %s or %s", config.Type, LogSinkStderr, LogSinkFile,
+			LogSinkSyslog)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// Logging - routes logs to configured sinks, entries of each subsystem are only written when they are at least as
+// severe as level of that subsystem. It's installed as a hook of the standard logger, which then writes nothing
+// itself
+type Logging struct {
+	mu     sync.RWMutex
+	levels map[string]log.Level
+	sinks  []*logSink
+	// logger - the logger whose level follows the most verbose subsystem
+	logger *log.Logger
+}
+
+// NewLogging - opens given sinks, all subsystems start with given level
+func NewLogging(sinks []LogSinkConfig, level log.Level) (*Logging, error) {
+	l := &Logging{levels: make(map[string]log.Level), logger: log.StandardLogger()}
+	for _, subsystem := range LogSubsystems {
+		l.levels[subsystem] = level
+	}
+	for _, config := range sinks {
+		sink, err := newLogSink(config)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		l.sinks = append(l.sinks, sink)
+	}
+	return l, nil
+}
+
+// Install - makes the standard logger write through the sinks
+func (l *Logging) Install() {
+	l.logger.Hooks.Add(l)
+	l.logger.Out = ioutil.Discard
+	l.logger.Formatter = discardFormatter{}
+	l.updateLoggerLevel()
+}
+
+// discardFormatter - formats nothing, entries are formatted by sinks
+type discardFormatter struct{}
+
+func (discardFormatter) Format(*log.Entry) ([]byte, error) {
+	return nil, nil
+}
+
+// Close - closes all sinks
+func (l *Logging) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, sink := range l.sinks {
+		sink.writer.Close()
+	}
+}
+
+// Levels - hook gets entries of all levels, they are filtered by subsystem levels in Fire
+func (l *Logging) Levels() []log.Level {
+	return []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel, log.WarnLevel, log.InfoLevel, log.DebugLevel}
+}
+
+// SubsystemLevels - returns level of every subsystem by its name
+func (l *Logging) SubsystemLevels() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	levels := make(map[string]string, len(l.levels))
+	for subsystem, level := range l.levels {
+		levels[subsystem] = level.String()
+	}
+	return levels
+}
+
+// SetLevels - changes levels of given subsystems (i.e. {"cache": "debug"}), other subsystems keep their levels.
+// Nothing is changed when any of the subsystems or levels is unknown
+func (l *Logging) SetLevels(levels map[string]string) error {
+	parsed := make(map[string]log.Level, len(levels))
+	for subsystem, name := range levels {
+		if !isLogSubsystem(subsystem) {
+			return fmt.Errorf("unknown subsystem '%s', use one of %s", subsystem, strings.Join(LogSubsystems, ", "))
+		}
+		level, err := log.ParseLevel(name)
+		if err != nil {
+			return fmt.Errorf("unknown level '%s' of subsystem '%s'", name, subsystem)
+		}
+		parsed[subsystem] = level
+	}
+
+	l.mu.Lock()
+	for subsystem, level := range parsed {
+		l.levels[subsystem] = level
+	}
+	l.mu.Unlock()
+	l.updateLoggerLevel()
+	return nil
+}
+
+// Sinks - returns configuration of sinks logs are written to
+func (l *Logging) Sinks() []LogSinkConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	configs := make([]LogSinkConfig, len(l.sinks))
+	for i, sink := range l.sinks {
+		configs[i] = sink.config
+	}
+	return configs
+}
+
+// updateLoggerLevel - lets through entries of the most verbose subsystem, the rest is filtered by Fire
+func (l *Logging) updateLoggerLevel() {
+	l.mu.RLock()
+	level := log.PanicLevel
+	for _, subsystemLevel := range l.levels {
+		if subsystemLevel > level {
+			level = subsystemLevel
+		}
+	}
+	l.mu.RUnlock()
+	l.logger.Level = level
+}
+
+// Fire - writes entry to all sinks when its subsystem logs at its level
+func (l *Logging) Fire(entry *log.Entry) error {
+	subsystem := callerSubsystem()
+
+	// sinks are written to one entry at a time
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry.Level > l.levels[subsystem] {
+		return nil
+	}
+
+	// copy so that subsystem field doesn't leak into fields of the caller
+	fields := make(log.Fields, len(entry.Data)+1)
+	for key, value := range entry.Data {
+		fields[key] = value
+	}
+	fields["subsystem"] = subsystem
+	withSubsystem := &log.Entry{Logger: entry.Logger, Data: fields, Time: entry.Time, Level: entry.Level,
+		Message: entry.Message}
+
+	for _, sink := range l.sinks {
+		line, err := sink.formatter.Format(withSubsystem)
+		if err != nil {
+			return err
+		}
+		if err := sink.writer.write(entry.Level, line); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write log to %s sink: %v\n", sink.config.Type, err)
+		}
+	}
+	return nil
+}
+
+// isLogSubsystem - checks whether subsystem is known
+func isLogSubsystem(subsystem string) bool {
+	for _, s := range LogSubsystems {
+		if s == subsystem {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLogLevels - parses levels of subsystems given as comma separated list, i.e. "cache=debug,admin=warn"
+func ParseLogLevels(value string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid log level '%s', expected subsystem=level", pair)
+		}
+		levels[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return levels, nil
+}
+
+// rotatingFile - log file that is rotated once it grows over maximum size, path.1 is the newest rotated file
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// newRotatingFile - opens log file for appending
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open - opens log file, writes continue at its end
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate - shifts rotated files by one, current file becomes path.1 and the oldest one is removed
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	if f.maxBackups == 0 {
+		os.Remove(f.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) write(level log.Level, line []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+// Close - closes log file
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// trimNewline - syslog adds its own line endings
+func trimNewline(line []byte) string {
+	return string(bytes.TrimRight(line, "\n"))
+}
@@ -0,0 +1,140 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// testLogging - returns logging writing to a file in temporary directory through its own logger
+func testLogging(t *testing.T, config LogSinkConfig) (*Logging, *log.Logger, string) {
+	dir, err := ioutil.TempDir("", "hoverfly-logs")
+	expect(t, err, nil)
+
+	config.Type = LogSinkFile
+	config.Path = filepath.Join(dir, "hoverfly.log")
+	logging, err := NewLogging([]LogSinkConfig{config}, log.InfoLevel)
+	expect(t, err, nil)
+
+	logging.logger = log.New()
+	logging.Install()
+	return logging, logging.logger, dir
+}
+
+func TestLogSubsystem(t *testing.T) {
+	expect(t, logSubsystem("/go/src/github.com/SpectoLabs/hoverfly/cache.go"), LogSubsystemCache)
+	expect(t, logSubsystem("cache_redis.go"), LogSubsystemCache)
+	expect(t, logSubsystem("admin.go"), LogSubsystemAdmin)
+	expect(t, logSubsystem("auth.go"), LogSubsystemAdmin)
+	expect(t, logSubsystem("middleware.go"), LogSubsystemMiddleware)
+	expect(t, logSubsystem("middlewarechain.go"), LogSubsystemMiddleware)
+	expect(t, logSubsystem("manipulation.go"), LogSubsystemMiddleware)
+	expect(t, logSubsystem("hoverfly_funcs.go"), LogSubsystemProxy)
+}
+
+func TestParseLogLevels(t *testing.T) {
+	levels, err := ParseLogLevels("cache=debug, admin=warn")
+	expect(t, err, nil)
+	expect(t, levels["cache"], "debug")
+	expect(t, levels["admin"], "warn")
+
+	levels, err = ParseLogLevels("")
+	expect(t, err, nil)
+	expect(t, len(levels), 0)
+
+	_, err = ParseLogLevels("cache")
+	refute(t, err, nil)
+}
+
+func TestLoggingSetLevels(t *testing.T) {
+	logging, err := NewLogging(nil, log.InfoLevel)
+	expect(t, err, nil)
+
+	expect(t, logging.SetLevels(map[string]string{"cache": "debug"}), nil)
+	expect(t, logging.SubsystemLevels()["cache"], "debug")
+	expect(t, logging.SubsystemLevels()["proxy"], "info")
+
+	// nothing changes when any level is invalid
+	refute(t, logging.SetLevels(map[string]string{"proxy": "error", "cache": "loud"}), nil)
+	expect(t, logging.SubsystemLevels()["proxy"], "info")
+	refute(t, logging.SetLevels(map[string]string{"database": "debug"}), nil)
+}
+
+func TestLoggingFiltersBySubsystemLevel(t *testing.T) {
+	logging, logger, dir := testLogging(t, LogSinkConfig{})
+	defer os.RemoveAll(dir)
+	defer logging.Close()
+
+	// logger lets through entries of the most verbose subsystem
+	expect(t, logging.SetLevels(map[string]string{"proxy": "warning", "cache": "debug"}), nil)
+	expect(t, logger.Level, log.DebugLevel)
+
+	logger.WithFields(log.Fields{"destination": "example.com"}).Info("filtered out")
+	logger.WithFields(log.Fields{"destination": "example.com"}).Warn("written")
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "hoverfly.log"))
+	expect(t, err, nil)
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	expect(t, len(lines), 1)
+
+	var entry map[string]interface{}
+	expect(t, json.Unmarshal([]byte(lines[0]), &entry), nil)
+	expect(t, entry["msg"], "written")
+	expect(t, entry["subsystem"], LogSubsystemProxy)
+	expect(t, entry["destination"], "example.com")
+}
+
+func TestLoggingTextFormat(t *testing.T) {
+	logging, logger, dir := testLogging(t, LogSinkConfig{Format: LogFormatText})
+	defer os.RemoveAll(dir)
+	defer logging.Close()
+
+	logger.Info("started")
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "hoverfly.log"))
+	expect(t, err, nil)
+	expect(t, strings.Contains(string(contents), `msg=started`), true)
+	expect(t, strings.Contains(string(contents), `subsystem=proxy`), true)
+}
+
+func TestNewLogSinkInvalid(t *testing.T) {
+	_, err := newLogSink(LogSinkConfig{Type: "kafka"})
+	refute(t, err, nil)
+
+	_, err = newLogSink(LogSinkConfig{Type: LogSinkStderr, Format: "xml"})
+	refute(t, err, nil)
+
+	_, err = newLogSink(LogSinkConfig{Type: LogSinkFile})
+	refute(t, err, nil)
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly-logs")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "hoverfly.log")
+	f, err := newRotatingFile(path, 10, 2)
+	expect(t, err, nil)
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		expect(t, f.write(log.InfoLevel, []byte(line)), nil)
+	}
+
+	current, _ := ioutil.ReadFile(path)
+	expect(t, string(current), "fourth\n")
+	newest, _ := ioutil.ReadFile(path + ".1")
+	expect(t, string(newest), "third\n")
+	oldest, _ := ioutil.ReadFile(path + ".2")
+	expect(t, string(oldest), "second\n")
+
+	// only two rotated files are kept
+	_, err = os.Stat(path + ".3")
+	expect(t, os.IsNotExist(err), true)
+}
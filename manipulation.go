@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 )
@@ -25,10 +26,22 @@ func NewConstructor(req *http.Request, payload Payload) *Constructor {
 // ApplyMiddleware - activates given middleware, middleware should be passed as string to executable, can be
 // full path.
 func (c *Constructor) ApplyMiddleware(middleware string) error {
+	return c.applyMiddleware(middleware, 0)
+}
+
+// applyMiddleware - activates given middleware, it fails when it doesn't finish within timeout (0 means no limit)
+func (c *Constructor) applyMiddleware(middleware string, timeout time.Duration) error {
+	var span *Span
+	if c.request != nil {
+		span = startChildSpan(c.request.Context(), "middleware", SpanKindInternal)
+		span.SetAttribute("hoverfly.middleware", middleware)
+		defer span.Finish()
+	}
 
-	newPayload, err := ExecuteMiddleware(middleware, c.payload)
+	newPayload, err := executeMiddleware(middleware, c.payload, timeout)
 
 	if err != nil {
+		span.SetError(err)
 		log.WithFields(log.Fields{
 			"error":      err.Error(),
 			"middleware": middleware,
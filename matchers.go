@@ -0,0 +1,188 @@
+package hoverfly
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// FieldMatcher - pattern request field has to match, either glob (i.e. /users/*/orders) or regular expression
+type FieldMatcher struct {
+	// Glob - * matches anything except /, ** matches anything and ? matches single character except /
+	Glob  string `json:"glob,omitempty"`
+	Regex string `json:"regex,omitempty"`
+
+	// re, foldedRe - compiled pattern and its case insensitive version, set when matchers are validated
+	re       *regexp.Regexp
+	foldedRe *regexp.Regexp
+}
+
+// globToRegex - translates glob pattern into anchored regular expression
+func globToRegex(glob string) string {
+	var buf bytes.Buffer
+	buf.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			// bodies can have more lines
+			buf.WriteString("(?s:.*)")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	buf.WriteString("$")
+	return buf.String()
+}
+
+// compile - returns regular expression of the matcher
+func (m FieldMatcher) compile() (*regexp.Regexp, error) {
+	switch {
+	case m.Glob != "" && m.Regex != "":
+		return nil, fmt.Errorf("matcher can't have both glob '%s' and regex '%s'", m.Glob, m.Regex)
+	case m.Glob != "":
+		return regexp.Compile(globToRegex(m.Glob))
+	case m.Regex != "":
+		re, err := regexp.Compile(m.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex '%s': %s", m.Regex, err.Error())
+		}
+		return re, nil
+	}
+	return nil, fmt.Errorf("matcher needs glob or regex")
+}
+
+// prepare - compiles pattern of the matcher and its case insensitive version once, so they aren't compiled on
+// every match
+func (m *FieldMatcher) prepare() error {
+	re, err := m.compile()
+	if err != nil {
+		return err
+	}
+	foldedRe, err := m.foldCase().compile()
+	if err != nil {
+		return err
+	}
+	m.re, m.foldedRe = re, foldedRe
+	return nil
+}
+
+// matchesAny - checks whether any of the values matches, invalid matchers match nothing
+func (m FieldMatcher) matchesAny(values []string) bool {
+	re := m.re
+	if re == nil {
+		// matcher that wasn't validated
+		var err error
+		if re, err = m.compile(); err != nil {
+			return false
+		}
+	}
+	for _, value := range values {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestMatchers - patterns request path, query parameters and headers are matched against instead of comparing
+// them with recorded values, i.e. {"path": {"glob": "/users/*/orders"}, "query": {"page": {"regex": "^[0-9]+$"}}}
+type RequestMatchers struct {
+	// Path - recorded path is ignored when it's set
+	Path *FieldMatcher `json:"path,omitempty"`
+	// Query - every listed parameter has to match, recorded query is ignored when it's set
+	Query map[string]FieldMatcher `json:"query,omitempty"`
+	// Headers - every listed header has to match, names are case insensitive
+	Headers map[string]FieldMatcher `json:"headers,omitempty"`
+	// RawQuery - pattern whole query string has to match, recorded query is ignored when it's set
+	RawQuery *FieldMatcher `json:"rawQuery,omitempty"`
+	// Body - pattern whole request body has to match, recorded body is ignored when it's set
+	Body *FieldMatcher `json:"body,omitempty"`
+}
+
+// validate - checks that all patterns can be compiled and keeps them compiled
+func (m *RequestMatchers) validate() error {
+	if m.Path != nil {
+		if err := m.Path.prepare(); err != nil {
+			return fmt.Errorf("path: %s", err.Error())
+		}
+	}
+	for name, matcher := range m.Query {
+		if err := matcher.prepare(); err != nil {
+			return fmt.Errorf("query parameter '%s': %s", name, err.Error())
+		}
+		m.Query[name] = matcher
+	}
+	if m.RawQuery != nil {
+		if err := m.RawQuery.prepare(); err != nil {
+			return fmt.Errorf("query: %s", err.Error())
+		}
+	}
+	if m.Body != nil {
+		if err := m.Body.prepare(); err != nil {
+			return fmt.Errorf("body: %s", err.Error())
+		}
+	}
+	for name, matcher := range m.Headers {
+		if err := matcher.prepare(); err != nil {
+			return fmt.Errorf("header '%s': %s", name, err.Error())
+		}
+		m.Headers[name] = matcher
+	}
+	return nil
+}
+
+// count - number of patterns, records with more of them are more specific
+func (m *RequestMatchers) count() int {
+	count := len(m.Query) + len(m.Headers)
+	for _, matcher := range []*FieldMatcher{m.Path, m.RawQuery, m.Body} {
+		if matcher != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// replacesQuery - checks whether recorded query is replaced by patterns
+func (m *RequestMatchers) replacesQuery() bool {
+	return m != nil && (len(m.Query) > 0 || m.RawQuery != nil)
+}
+
+// replacesBody - checks whether recorded body is replaced by pattern
+func (m *RequestMatchers) replacesBody() bool {
+	return m != nil && m.Body != nil
+}
+
+// matches - checks whether request path, query and headers match all patterns, body is checked on its own
+func (m *RequestMatchers) matches(path, rawQuery string, headers map[string][]string) bool {
+	if m.Path != nil && !m.Path.matchesAny([]string{path}) {
+		return false
+	}
+	if m.RawQuery != nil && !m.RawQuery.matchesAny([]string{rawQuery}) {
+		return false
+	}
+
+	if len(m.Query) > 0 {
+		query, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return false
+		}
+		for name, matcher := range m.Query {
+			if !matcher.matchesAny(query[name]) {
+				return false
+			}
+		}
+	}
+
+	for name, matcher := range m.Headers {
+		if !matcher.matchesAny(headerValues(headers, name)) {
+			return false
+		}
+	}
+	return true
+}
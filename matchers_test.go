@@ -0,0 +1,232 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGlobMatcher(t *testing.T) {
+	matcher := FieldMatcher{Glob: "/users/*/orders"}
+	expect(t, matcher.matchesAny([]string{"/users/42/orders"}), true)
+	expect(t, matcher.matchesAny([]string{"/users/42/7/orders"}), false)
+	expect(t, matcher.matchesAny([]string{"/users/42/orders/1"}), false)
+
+	matcher = FieldMatcher{Glob: "/static/**.js"}
+	expect(t, matcher.matchesAny([]string{"/static/app/main.js"}), true)
+	expect(t, matcher.matchesAny([]string{"/static/app/main.css"}), false)
+
+	matcher = FieldMatcher{Glob: "v?.(beta)"}
+	expect(t, matcher.matchesAny([]string{"v2.(beta)"}), true)
+	expect(t, matcher.matchesAny([]string{"v2.beta"}), false)
+}
+
+func TestRequestMatchersValidate(t *testing.T) {
+	matchers := RequestMatchers{Path: &FieldMatcher{Regex: "^/users/[0-9]+$"}}
+	expect(t, matchers.validate(), nil)
+
+	for _, invalid := range []RequestMatchers{
+		{Path: &FieldMatcher{}},
+		{Path: &FieldMatcher{Glob: "/users/*", Regex: "^/users"}},
+		{Query: map[string]FieldMatcher{"page": {Regex: "("}}},
+		{Headers: map[string]FieldMatcher{"Accept": {}}},
+	} {
+		refute(t, invalid.validate(), nil)
+	}
+}
+
+func TestRequestMatchersValidateCompilesPatterns(t *testing.T) {
+	matchers := RequestMatchers{
+		Path:    &FieldMatcher{Glob: "/users/*"},
+		Headers: map[string]FieldMatcher{"Accept": {Glob: "application/*"}},
+	}
+	expect(t, matchers.validate(), nil)
+	refute(t, matchers.Path.re, nil)
+	refute(t, matchers.Headers["Accept"].re, nil)
+
+	// case insensitive version is compiled as well
+	folded := matchers.foldCase(true, true)
+	expect(t, folded.Path.re, matchers.Path.foldedRe)
+	expect(t, folded.Path.matchesAny([]string{"/USERS/42"}), true)
+	expect(t, folded.Headers["Accept"].matchesAny([]string{"Application/JSON"}), true)
+}
+
+func TestRequestMatchersMatch(t *testing.T) {
+	matchers := RequestMatchers{
+		Path:    &FieldMatcher{Glob: "/users/*"},
+		Query:   map[string]FieldMatcher{"page": {Regex: "^[0-9]+$"}},
+		Headers: map[string]FieldMatcher{"accept": {Glob: "application/*"}},
+	}
+	headers := map[string][]string{"Accept": {"application/json"}}
+
+	expect(t, matchers.matches("/users/42", "page=2&sort=name", headers), true)
+	expect(t, matchers.matches("/users/42", "page=two", headers), false)
+	expect(t, matchers.matches("/users/42", "sort=name", headers), false)
+	expect(t, matchers.matches("/orders/42", "page=2", headers), false)
+	expect(t, matchers.matches("/users/42", "page=2", map[string][]string{"Accept": {"text/html"}}), false)
+}
+
+func TestRecordsWithRequestMatchers(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.ImportPayloads([]Payload{
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com"},
+			Response: ResponseDetails{Status: 200, Body: "orders"},
+			Matchers: &RequestMatchers{Path: &FieldMatcher{Glob: "/users/*/orders"}},
+		},
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com"},
+			Response: ResponseDetails{Status: 200, Body: "v2 orders"},
+			Matchers: &RequestMatchers{
+				Path:    &FieldMatcher{Glob: "/users/*/orders"},
+				Headers: map[string]FieldMatcher{"X-Api-Version": {Regex: "^2"}},
+			},
+		},
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/search"},
+			Response: ResponseDetails{Status: 200, Body: "results"},
+			Matchers: &RequestMatchers{Query: map[string]FieldMatcher{"q": {Glob: "*"}}},
+		},
+	})
+	expect(t, err, nil)
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 3)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	get := func(url, version string) (int, string) {
+		req, _ := http.NewRequest("GET", url, nil)
+		if version != "" {
+			req.Header.Set("X-Api-Version", version)
+		}
+		_, resp := dbClient.processRequest(req)
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get("http://api.example.com/users/42/orders", "")
+	expect(t, status, 200)
+	expect(t, body, "orders")
+
+	// the most specific record wins
+	status, body = get("http://api.example.com/users/7/orders", "2.1")
+	expect(t, status, 200)
+	expect(t, body, "v2 orders")
+
+	status, body = get("http://api.example.com/search?q=shoes&page=3", "")
+	expect(t, status, 200)
+	expect(t, body, "results")
+
+	status, _ = get("http://api.example.com/search", "")
+	expect(t, status, dbClient.Cfg.GetMissStatus())
+
+	status, _ = get("http://other.example.com/users/42/orders", "")
+	expect(t, status, dbClient.Cfg.GetMissStatus())
+
+	req, _ := http.NewRequest("DELETE", "http://api.example.com/users/42/orders", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+}
+
+func TestMatcherPriorityAndSpecificity(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.ImportPayloads([]Payload{
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com"},
+			Response: ResponseDetails{Status: 200, Body: "catch all"},
+			Matchers: &RequestMatchers{Path: &FieldMatcher{Glob: "/users/**"}},
+			Priority: -1,
+		},
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/users/admin"},
+			Response: ResponseDetails{Status: 200, Body: "admin"},
+			Matchers: &RequestMatchers{Headers: map[string]FieldMatcher{"Accept": {Glob: "text/*"}}},
+		},
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com"},
+			Response: ResponseDetails{Status: 200, Body: "user"},
+			Matchers: &RequestMatchers{Path: &FieldMatcher{Glob: "/users/*"}},
+		},
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "api.example.com"},
+			Response: ResponseDetails{Status: 200, Body: "maintenance"},
+			Matchers: &RequestMatchers{Path: &FieldMatcher{Regex: "^/users/"}, Headers: map[string]FieldMatcher{
+				"X-Maintenance": {Glob: "on"}}},
+			Priority: 10,
+		},
+	})
+	expect(t, err, nil)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	dbClient.Cfg.MatchDebug = true
+
+	get := func(path string, headers map[string]string) (string, string) {
+		req, _ := http.NewRequest("GET", "http://api.example.com"+path, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		_, resp := dbClient.processRequest(req)
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body), resp.Header.Get("Hoverfly-Match")
+	}
+
+	body, match := get("/users/42/orders", nil)
+	expect(t, body, "catch all")
+	expect(t, strings.Contains(match, "; matchers; priority=-1; specificity=1"), true)
+
+	body, _ = get("/users/42", nil)
+	expect(t, body, "user")
+
+	// exact path and header matcher are more specific than path glob
+	body, match = get("/users/admin", map[string]string{"Accept": "text/plain"})
+	expect(t, body, "admin")
+	expect(t, strings.Contains(match, "specificity=2"), true)
+
+	body, match = get("/users/admin", map[string]string{"Accept": "text/plain", "X-Maintenance": "on"})
+	expect(t, body, "maintenance")
+	expect(t, strings.Contains(match, "priority=10"), true)
+}
+
+func TestPreferredRecordTieBreak(t *testing.T) {
+	first := Payload{ID: "a", Matchers: &RequestMatchers{Path: &FieldMatcher{Glob: "/*"}}}
+	second := Payload{ID: "b", Matchers: &RequestMatchers{Path: &FieldMatcher{Regex: "^/"}}}
+	expect(t, first.preferredTo(&second), true)
+	expect(t, second.preferredTo(&first), false)
+
+	second.Priority = 1
+	expect(t, second.preferredTo(&first), true)
+}
+
+func TestRawQueryAndBodyMatchers(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.ImportPayloads([]Payload{
+		{
+			Request:  RequestDetails{Method: "POST", Destination: "api.example.com", Path: "/search"},
+			Response: ResponseDetails{Status: 200, Body: "results"},
+			Matchers: &RequestMatchers{
+				RawQuery: &FieldMatcher{Regex: "(^|&)lang=en(&|$)"},
+				Body:     &FieldMatcher{Glob: "**shoes**"},
+			},
+		},
+	})
+	expect(t, err, nil)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	search := func(query, body string) int {
+		req, _ := http.NewRequest("POST", "http://api.example.com/search?"+query, strings.NewReader(body))
+		_, resp := dbClient.processRequest(req)
+		return resp.StatusCode
+	}
+
+	expect(t, search("page=2&lang=en", "{\n  \"q\": \"red shoes\"\n}"), 200)
+	expect(t, search("lang=de", `{"q": "red shoes"}`), dbClient.Cfg.GetMissStatus())
+	expect(t, search("lang=en", `{"q": "hats"}`), dbClient.Cfg.GetMissStatus())
+}
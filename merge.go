@@ -0,0 +1,38 @@
+package hoverfly
+
+import (
+	"errors"
+)
+
+// Conflict resolutions of merged imports, records conflict when they have the same ID (request hash)
+const (
+	// MergeSkip - existing records are kept, conflicting imported ones are skipped
+	MergeSkip = "skip"
+	// MergeOverwrite - conflicting imported records replace existing ones
+	MergeOverwrite = "overwrite"
+	// MergeError - nothing is imported when any of the records conflicts
+	MergeError = "error"
+)
+
+// ErrMergeConflict - returned when merged records conflict with existing ones and conflicts are resolved with
+// MergeError
+var ErrMergeConflict = errors.New("imported records conflict with existing records")
+
+// MergeResult - outcome of merged import, Conflicts holds IDs of conflicting records (they aren't listed when
+// conflicts are resolved by overwriting)
+type MergeResult struct {
+	Added       int      `json:"added"`
+	Overwritten int      `json:"overwritten"`
+	Skipped     int      `json:"skipped"`
+	Failed      int      `json:"failed"`
+	Conflicts   []string `json:"conflicts,omitempty"`
+}
+
+// IsMergeResolution - checks whether conflict resolution is known
+func IsMergeResolution(resolution string) bool {
+	switch resolution {
+	case MergeSkip, MergeOverwrite, MergeError:
+		return true
+	}
+	return false
+}
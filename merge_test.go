@@ -0,0 +1,13 @@
+package hoverfly
+
+import (
+	"testing"
+)
+
+func TestIsMergeResolution(t *testing.T) {
+	expect(t, IsMergeResolution(MergeSkip), true)
+	expect(t, IsMergeResolution(MergeOverwrite), true)
+	expect(t, IsMergeResolution(MergeError), true)
+	expect(t, IsMergeResolution(""), false)
+	expect(t, IsMergeResolution("replace"), false)
+}
@@ -9,9 +9,9 @@ import (
 
 // CounterByMode - container for mode counters, registry and flush interval
 type CounterByMode struct {
-	counterVirtualize, counterCapture, counterModify, counterSynthesize metrics.Counter
-	registry                                                            metrics.Registry
-	flushInterval                                                       time.Duration
+	counterVirtualize, counterCapture, counterModify, counterSynthesize, counterSpy, counterDiff metrics.Counter
+	registry                                                                                     metrics.Registry
+	flushInterval                                                                                time.Duration
 }
 
 // NewModeCounter - returns new counter instance
@@ -24,6 +24,8 @@ func NewModeCounter() *CounterByMode {
 		counterCapture:    metrics.NewCounter(),
 		counterModify:     metrics.NewCounter(),
 		counterSynthesize: metrics.NewCounter(),
+		counterSpy:        metrics.NewCounter(),
+		counterDiff:       metrics.NewCounter(),
 		registry:          registry,
 		flushInterval:     5 * time.Second,
 	}
@@ -32,6 +34,8 @@ func NewModeCounter() *CounterByMode {
 	c.registry.GetOrRegister(CaptureMode, c.counterCapture)
 	c.registry.GetOrRegister(ModifyMode, c.counterModify)
 	c.registry.GetOrRegister(SynthesizeMode, c.counterSynthesize)
+	c.registry.GetOrRegister(SpyMode, c.counterSpy)
+	c.registry.GetOrRegister(DiffMode, c.counterDiff)
 
 	log.Debug("new counter created, registration successful")
 
@@ -48,6 +52,10 @@ func (c *CounterByMode) Count(mode string) {
 		c.counterModify.Inc(1)
 	} else if mode == SynthesizeMode {
 		c.counterSynthesize.Inc(1)
+	} else if mode == SpyMode {
+		c.counterSpy.Inc(1)
+	} else if mode == DiffMode {
+		c.counterDiff.Inc(1)
 	}
 }
 
@@ -2,13 +2,24 @@ package hoverfly
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"os/exec"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 )
 
+// Middleware failure policies, they decide what happens to the request when middleware fails or times out
+const (
+	// MiddlewareFailOpen - payload is used as it was before middleware, failure is only logged
+	MiddlewareFailOpen = "open"
+	// MiddlewareFailClosed - request is answered with an error
+	MiddlewareFailClosed = "closed"
+)
+
 // Pipeline - to provide input to the pipeline, assign an io.Reader to the first's Stdin.
 func Pipeline(cmds ...*exec.Cmd) (pipeLineOutput, collectedStandardError []byte, pipeLineError error) {
 	// Require at least one command
@@ -54,6 +65,12 @@ func Pipeline(cmds ...*exec.Cmd) (pipeLineOutput, collectedStandardError []byte,
 
 // ExecuteMiddleware - takes command (middleware string) and payload, which is passed to middleware
 func ExecuteMiddleware(command string, payload Payload) (Payload, error) {
+	return executeMiddleware(command, payload, 0)
+}
+
+// executeMiddleware - runs middleware with payload on its stdin, middleware is killed when it doesn't finish within
+// timeout (0 means no limit)
+func executeMiddleware(command string, payload Payload, timeout time.Duration) (Payload, error) {
 	commands := strings.Split(command, " ")
 
 	log.WithFields(log.Fields{
@@ -61,7 +78,13 @@ func ExecuteMiddleware(command string, payload Payload) (Payload, error) {
 		"no":       len(commands),
 	}).Debug("Found commands")
 
-	cmds := exec.Command(commands[0], commands[1:]...)
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmds := exec.CommandContext(ctx, commands[0], commands[1:]...)
 
 	// getting payload
 	bts, err := json.Marshal(payload)
@@ -74,8 +97,28 @@ func ExecuteMiddleware(command string, payload Payload) (Payload, error) {
 	}
 	cmds.Stdin = bytes.NewReader(bts)
 
-	// Run the pipeline
-	mwOutput, stderr, err := Pipeline(cmds)
+	// Run the pipeline, processes middleware started can keep its output open after it's killed, so it isn't
+	// waited for past the timeout
+	type pipelineResult struct {
+		output, stderr []byte
+		err            error
+	}
+	done := make(chan pipelineResult, 1)
+	go func() {
+		output, stderr, err := Pipeline(cmds)
+		done <- pipelineResult{output, stderr, err}
+	}()
+
+	var result pipelineResult
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result.err = ctx.Err()
+	}
+	mwOutput, stderr, err := result.output, result.stderr, result.err
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("middleware didn't finish within %s", timeout)
+	}
 
 	if err != nil {
 		log.WithFields(log.Fields{
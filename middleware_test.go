@@ -1,7 +1,10 @@
 package hoverfly
 
 import (
+	"io/ioutil"
+	"net/http"
 	"testing"
+	"time"
 )
 
 func TestChangeBodyMiddleware(t *testing.T) {
@@ -62,3 +65,87 @@ func TestReflectBody(t *testing.T) {
 	expect(t, newPayload.Request.Method, req.Method)
 	expect(t, newPayload.Request.Destination, req.Destination)
 }
+
+func TestExecuteMiddlewareTimeout(t *testing.T) {
+	payload := Payload{Response: ResponseDetails{Status: 201, Body: "original body"}}
+
+	started := time.Now()
+	newPayload, err := executeMiddleware("sleep 5", payload, 100*time.Millisecond)
+	refute(t, err, nil)
+	expect(t, newPayload.Response.Body, "original body")
+	expect(t, time.Since(started) < 2*time.Second, true)
+}
+
+func TestMiddlewareFailClosed(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+	dbClient.Cfg.Middleware = "./should/not/exist.py"
+	dbClient.Cfg.MiddlewareFailure = MiddlewareFailClosed
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	req, _ = http.NewRequest("GET", "http://api.example.com/orders", nil)
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+}
+
+func TestMiddlewareFailureDefault(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+	dbClient.Cfg.Middleware = "./should/not/exist.py"
+	dbClient.Cfg.MiddlewareFailure = ""
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	// recorded responses are served unmodified
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusOK)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, string(body), "users")
+
+	// forwarded requests fail
+	dbClient.Cfg.SetMode(CaptureMode)
+	req, _ = http.NewRequest("GET", "http://api.example.com/orders", nil)
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+}
+
+func TestMiddlewareFailOpen(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+	dbClient.Cfg.Middleware = "./should/not/exist.py"
+	dbClient.Cfg.MiddlewareFailure = MiddlewareFailOpen
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	// recorded response is served as it was captured
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusOK)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, string(body), "users")
+
+	// request is captured without modification
+	dbClient.Cfg.SetMode(CaptureMode)
+	req, _ = http.NewRequest("GET", "http://api.example.com/orders", nil)
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 201)
+
+	// there is no response without middleware
+	dbClient.Cfg.SetMode(SynthesizeMode)
+	req, _ = http.NewRequest("GET", "http://api.example.com/orders", nil)
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+}
@@ -0,0 +1,290 @@
+package hoverfly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// MiddlewareStage - one middleware of the chain, either executable getting payload on stdin (Binary) or HTTP
+// service getting it in POST request body (Remote). Both return the payload, possibly modified, empty output
+// leaves payload as it was
+type MiddlewareStage struct {
+	// Binary - command running the middleware, i.e. "./examples/middleware/modify_response/modify_response.py"
+	Binary string `json:"binary,omitempty"`
+	// Remote - URL of middleware service, i.e. "http://localhost:8090/process"
+	Remote string `json:"remote,omitempty"`
+	// Timeout - milliseconds the stage has to finish in, -middleware-timeout applies when it's 0
+	Timeout int `json:"timeout,omitempty"`
+	// Failure - failure policy of the stage (open or closed), -middleware-failure applies when it's empty
+	Failure string `json:"failure,omitempty"`
+	// URLPattern - regular expression matched against request host and path, requests that don't match bypass the
+	// stage, all requests go through it when empty
+	URLPattern string `json:"urlPattern,omitempty"`
+	// HTTPMethod - method of requests going through the stage, all methods when empty
+	HTTPMethod string `json:"httpMethod,omitempty"`
+
+	urlRe *regexp.Regexp
+}
+
+// name - returns command or URL of the stage
+func (s *MiddlewareStage) name() string {
+	if s.Binary != "" {
+		return s.Binary
+	}
+	return s.Remote
+}
+
+// validate - checks that stage runs exactly one middleware and its settings make sense, compiles its URL pattern
+func (s *MiddlewareStage) validate() error {
+	if (s.Binary == "") == (s.Remote == "") {
+		return fmt.Errorf("middleware stage needs either 'binary' or 'remote'")
+	}
+	if s.Remote != "" {
+		u, err := url.Parse(s.Remote)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("remote middleware needs absolute http or https URL, got '%s'", s.Remote)
+		}
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout of middleware '%s' can't be negative", s.name())
+	}
+	if s.Failure != "" && s.Failure != MiddlewareFailOpen && s.Failure != MiddlewareFailClosed {
+		return fmt.Errorf("unknown failure policy '%s' of middleware '%s', use %s or %s", s.Failure, s.name(),
+			MiddlewareFailOpen, MiddlewareFailClosed)
+	}
+	if s.URLPattern != "" {
+		re, err := regexp.Compile(s.URLPattern)
+		if err != nil {
+			return fmt.Errorf("invalid URL pattern '%s' of middleware '%s': %s", s.URLPattern, s.name(), err.Error())
+		}
+		s.urlRe = re
+	}
+	return nil
+}
+
+// matches - checks whether request with given method, host and path goes through the stage
+func (s *MiddlewareStage) matches(method, host, path string) bool {
+	if s.HTTPMethod != "" && !strings.EqualFold(s.HTTPMethod, method) {
+		return false
+	}
+	return s.urlRe == nil || s.urlRe.MatchString(host+path)
+}
+
+// MiddlewareChain - middleware stages payloads go through in order, output of one stage is input of the next one.
+// When chain is set it's used instead of -middleware
+type MiddlewareChain struct {
+	mu     sync.RWMutex
+	stages []MiddlewareStage
+	// HTTP - client remote stages are called with, stage timeout applies to each call
+	HTTP *http.Client
+}
+
+// NewMiddlewareChain - returns empty chain
+func NewMiddlewareChain() *MiddlewareChain {
+	return &MiddlewareChain{HTTP: &http.Client{}}
+}
+
+// Set - validates and replaces all stages, current ones are kept when any of them is invalid
+func (m *MiddlewareChain) Set(stages []MiddlewareStage) error {
+	for i := range stages {
+		if err := stages[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	m.stages = append([]MiddlewareStage{}, stages...)
+	m.mu.Unlock()
+	return nil
+}
+
+// Get - returns current stages
+func (m *MiddlewareChain) Get() []MiddlewareStage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]MiddlewareStage{}, m.stages...)
+}
+
+// Clear - removes all stages, -middleware is used again
+func (m *MiddlewareChain) Clear() {
+	m.mu.Lock()
+	m.stages = nil
+	m.mu.Unlock()
+}
+
+// callRemote - sends payload to middleware service and returns payload it responded with
+func (m *MiddlewareChain) callRemote(stage MiddlewareStage, payload Payload, timeout time.Duration) (Payload, error) {
+	bts, err := json.Marshal(payload)
+	if err != nil {
+		return payload, err
+	}
+	req, err := http.NewRequest("POST", stage.Remote, bytes.NewReader(bts))
+	if err != nil {
+		return payload, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := m.HTTP.Do(req)
+	if err != nil {
+		if ctx := req.Context(); ctx.Err() == context.DeadlineExceeded {
+			return payload, fmt.Errorf("middleware didn't finish within %s", timeout)
+		}
+		return payload, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return payload, err
+	}
+	if resp.StatusCode >= 300 {
+		return payload, fmt.Errorf("middleware responded with %s", resp.Status)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return payload, nil
+	}
+
+	var newPayload Payload
+	if err := json.Unmarshal(body, &newPayload); err != nil {
+		return payload, fmt.Errorf("middleware responded with invalid payload: %s", err.Error())
+	}
+	return newPayload, nil
+}
+
+// middlewareStages - returns stages payloads go through, configured chain or the single given middleware
+func (d *DBClient) middlewareStages(middleware string) []MiddlewareStage {
+	if d.MiddlewareChain != nil {
+		if stages := d.MiddlewareChain.Get(); len(stages) > 0 {
+			return stages
+		}
+	}
+	if middleware == "" {
+		return nil
+	}
+	stage := MiddlewareStage{Binary: middleware}
+	if d.Cfg.MiddlewarePattern != "" {
+		re, ok := d.Cfg.middlewarePattern()
+		if !ok {
+			// pattern is checked on startup, invalid one scopes middleware to no request rather than to all of them
+			return nil
+		}
+		stage.URLPattern, stage.urlRe = d.Cfg.MiddlewarePattern, re
+	}
+	return []MiddlewareStage{stage}
+}
+
+// hasMiddleware - checks whether request goes through any middleware, requests to other destinations than the
+// stages are scoped to bypass middleware completely
+func (d *DBClient) hasMiddleware(req *http.Request) bool {
+	for _, stage := range d.middlewareStages(d.Cfg.Middleware) {
+		if stage.matches(req.Method, req.Host, req.URL.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// middlewareName - describes middleware requests go through, for logs and error messages
+func (d *DBClient) middlewareName() string {
+	stages := d.middlewareStages(d.Cfg.Middleware)
+	names := make([]string, len(stages))
+	for i := range stages {
+		names[i] = stages[i].name()
+	}
+	return strings.Join(names, " | ")
+}
+
+// applyMiddleware - runs constructor's payload through middleware stages in order. When a stage fails, its failure
+// policy decides whether the error is returned or the next stage gets the payload as it was, stages fail closed
+// unless a policy was configured
+func (d *DBClient) applyMiddleware(c *Constructor, middleware string) error {
+	return d.applyMiddlewareStages(c, middleware, MiddlewareFailClosed)
+}
+
+// applyReplayMiddleware - runs recorded response through middleware stages, recorded response is served as it was
+// when a stage fails and no failure policy was configured
+func (d *DBClient) applyReplayMiddleware(c *Constructor, middleware string) error {
+	return d.applyMiddlewareStages(c, middleware, MiddlewareFailOpen)
+}
+
+// applyMiddlewareStages - runs constructor's payload through middleware stages, failure policy of the stage wins
+// over -middleware-failure, fallback applies when neither is set
+func (d *DBClient) applyMiddlewareStages(c *Constructor, middleware, fallback string) error {
+	stages := d.middlewareStages(middleware)
+	if len(stages) == 0 {
+		return fmt.Errorf("middleware not supplied")
+	}
+
+	method, host, path := c.payload.Request.Method, c.payload.Request.Destination, c.payload.Request.Path
+	if c.request != nil {
+		method, host, path = c.request.Method, c.request.Host, c.request.URL.Path
+	}
+	for _, stage := range stages {
+		if !stage.matches(method, host, path) {
+			continue
+		}
+		err := d.runStage(c, stage)
+		if err == nil {
+			continue
+		}
+		policy := stage.Failure
+		if policy == "" {
+			policy = d.Cfg.MiddlewareFailure
+		}
+		if policy == "" {
+			policy = fallback
+		}
+		if policy != MiddlewareFailOpen {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"error":      err.Error(),
+			"middleware": stage.name(),
+		}).Warn("Middleware failed, continuing with unmodified payload")
+	}
+	return nil
+}
+
+// runStage - runs one stage of the chain on constructor's payload
+func (d *DBClient) runStage(c *Constructor, stage MiddlewareStage) error {
+	timeout := d.Cfg.MiddlewareTimeout
+	if stage.Timeout > 0 {
+		timeout = time.Duration(stage.Timeout) * time.Millisecond
+	}
+	if stage.Binary != "" {
+		return c.applyMiddleware(stage.Binary, timeout)
+	}
+
+	var span *Span
+	if c.request != nil {
+		span = startChildSpan(c.request.Context(), "middleware", SpanKindClient)
+		span.SetAttribute("hoverfly.middleware", stage.Remote)
+		defer span.Finish()
+	}
+	payload, err := d.MiddlewareChain.callRemote(stage, c.payload, timeout)
+	if err != nil {
+		span.SetError(err)
+		log.WithFields(log.Fields{
+			"error":      err.Error(),
+			"middleware": stage.Remote,
+		}).Error("Error during middleware transformation, not modifying payload!")
+		return err
+	}
+	c.payload = payload
+	return nil
+}
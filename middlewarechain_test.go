@@ -0,0 +1,217 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// remoteMiddleware - returns middleware service appending suffix to response body
+func remoteMiddleware(suffix string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		payload.Response.Body += suffix
+		json.NewEncoder(w).Encode(payload)
+	}))
+}
+
+func TestMiddlewareStageValidate(t *testing.T) {
+	expect(t, (&MiddlewareStage{Binary: "./middleware.py"}).validate(), nil)
+	expect(t, (&MiddlewareStage{Remote: "http://localhost:8090/process", Failure: "open"}).validate(), nil)
+
+	refute(t, (&MiddlewareStage{}).validate(), nil)
+	refute(t, (&MiddlewareStage{Binary: "./middleware.py", Remote: "http://localhost:8090"}).validate(), nil)
+	refute(t, (&MiddlewareStage{Remote: "/process"}).validate(), nil)
+	refute(t, (&MiddlewareStage{Binary: "./middleware.py", Timeout: -1}).validate(), nil)
+	refute(t, (&MiddlewareStage{Binary: "./middleware.py", Failure: "sometimes"}).validate(), nil)
+	refute(t, (&MiddlewareStage{Binary: "./middleware.py", URLPattern: "payments.example.com/(api"}).validate(), nil)
+}
+
+func TestMiddlewareStageMatches(t *testing.T) {
+	stage := MiddlewareStage{Binary: "cat", URLPattern: `^payments\.example\.com/api/`, HTTPMethod: "post"}
+	expect(t, stage.validate(), nil)
+
+	expect(t, stage.matches("POST", "payments.example.com", "/api/charges"), true)
+	expect(t, stage.matches("GET", "payments.example.com", "/api/charges"), false)
+	expect(t, stage.matches("POST", "users.example.com", "/api/charges"), false)
+	expect(t, stage.matches("POST", "payments.example.com", "/health"), false)
+
+	// stage without pattern and method gets all requests
+	expect(t, (&MiddlewareStage{Binary: "cat"}).matches("GET", "users.example.com", "/"), true)
+}
+
+func TestMiddlewareChainSetKeepsStagesWhenInvalid(t *testing.T) {
+	chain := NewMiddlewareChain()
+	expect(t, chain.Set([]MiddlewareStage{{Binary: "cat"}}), nil)
+	refute(t, chain.Set([]MiddlewareStage{{Binary: "cat"}, {Remote: "not a URL"}}), nil)
+	expect(t, len(chain.Get()), 1)
+
+	chain.Clear()
+	expect(t, len(chain.Get()), 0)
+}
+
+func TestMiddlewareChainRunsStagesInOrder(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	remote := remoteMiddleware(" and remote")
+	defer remote.Close()
+
+	// -middleware is replaced by the chain
+	dbClient.Cfg.Middleware = "./should/not/exist.py"
+	err := dbClient.MiddlewareChain.Set([]MiddlewareStage{
+		{Binary: "./examples/middleware/modify_response/modify_response.py"},
+		{Remote: remote.URL},
+		{Binary: "cat"},
+	})
+	expect(t, err, nil)
+	expect(t, dbClient.middlewareName(), "./examples/middleware/modify_response/modify_response.py | "+remote.URL+" | cat")
+
+	c := NewConstructor(nil, Payload{Response: ResponseDetails{Status: 200, Body: "original body"}})
+	expect(t, dbClient.applyMiddleware(c, dbClient.Cfg.Middleware), nil)
+	expect(t, c.payload.Response.Status, 201)
+	expect(t, c.payload.Response.Body, "body was replaced by middleware\n and remote")
+}
+
+func TestMiddlewareChainStageFailurePolicy(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	remote := remoteMiddleware(" and remote")
+	defer remote.Close()
+
+	// failing stage is skipped, the next one gets payload as it was
+	dbClient.MiddlewareChain.Set([]MiddlewareStage{
+		{Remote: failing.URL, Failure: MiddlewareFailOpen},
+		{Remote: remote.URL},
+	})
+	c := NewConstructor(nil, Payload{Response: ResponseDetails{Status: 200, Body: "original body"}})
+	expect(t, dbClient.applyMiddleware(c, ""), nil)
+	expect(t, c.payload.Response.Body, "original body and remote")
+
+	// stage policy overrides the default one
+	dbClient.Cfg.MiddlewareFailure = MiddlewareFailOpen
+	dbClient.MiddlewareChain.Set([]MiddlewareStage{
+		{Remote: failing.URL, Failure: MiddlewareFailClosed},
+		{Remote: remote.URL},
+	})
+	c = NewConstructor(nil, Payload{Response: ResponseDetails{Status: 200, Body: "original body"}})
+	refute(t, dbClient.applyMiddleware(c, ""), nil)
+	expect(t, c.payload.Response.Body, "original body")
+}
+
+func TestMiddlewareChainStageTimeout(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	dbClient.MiddlewareChain.Set([]MiddlewareStage{{Remote: slow.URL, Timeout: 50}})
+
+	started := time.Now()
+	c := NewConstructor(nil, Payload{Response: ResponseDetails{Status: 200, Body: "original body"}})
+	refute(t, dbClient.applyMiddleware(c, ""), nil)
+	expect(t, time.Since(started) < 400*time.Millisecond, true)
+}
+
+func TestMiddlewareChainInVirtualizeMode(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	remote := remoteMiddleware(" and remote")
+	defer remote.Close()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+	dbClient.MiddlewareChain.Set([]MiddlewareStage{{Remote: remote.URL}})
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusOK)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, string(body), "users and remote")
+}
+
+func TestMiddlewareChainScopedToDestination(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	payments := remoteMiddleware(" and payments")
+	defer payments.Close()
+	all := remoteMiddleware(" and all")
+	defer all.Close()
+
+	storeTestPayload(dbClient, "GET", "http://payments.example.com/api/charges", "", 200, "charges")
+	storeTestPayload(dbClient, "GET", "http://users.example.com/api/users", "", 200, "users")
+	dbClient.MiddlewareChain.Set([]MiddlewareStage{
+		{Remote: payments.URL, URLPattern: "payments.example.com"},
+		{Remote: all.URL},
+	})
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://payments.example.com/api/charges", nil)
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, string(body), "charges and payments and all")
+
+	req, _ = http.NewRequest("GET", "http://users.example.com/api/users", nil)
+	_, resp = dbClient.processRequest(req)
+	body, _ = ioutil.ReadAll(resp.Body)
+	expect(t, string(body), "users and all")
+}
+
+func TestMiddlewarePatternBypassesOtherDestinations(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://users.example.com/api/users", "", 200, "users")
+	dbClient.Cfg.Middleware = "./should/not/exist.py"
+	dbClient.Cfg.MiddlewarePattern = "payments.example.com"
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://users.example.com/api/users", nil)
+	expect(t, dbClient.hasMiddleware(req), false)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusOK)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, string(body), "users")
+
+	req, _ = http.NewRequest("GET", "http://payments.example.com/api/charges", nil)
+	expect(t, dbClient.hasMiddleware(req), true)
+
+	// pattern is compiled once
+	first, _ := dbClient.Cfg.middlewarePattern()
+	second, _ := dbClient.Cfg.middlewarePattern()
+	expect(t, first == second, true)
+}
+
+func TestInvalidMiddlewarePatternMatchesNoRequest(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	refute(t, dbClient.Cfg.SetMiddlewarePattern("payments.example.com/(api"), nil)
+
+	// i.e. invalid HoverflyMiddlewarePattern, middleware isn't widened to all requests
+	dbClient.Cfg.Middleware = "./should/not/exist.py"
+	dbClient.Cfg.MiddlewarePattern = "payments.example.com/(api"
+	req, _ := http.NewRequest("GET", "http://users.example.com/api/users", nil)
+	expect(t, dbClient.hasMiddleware(req), false)
+	req, _ = http.NewRequest("GET", "http://payments.example.com/(api", nil)
+	expect(t, dbClient.hasMiddleware(req), false)
+
+	expect(t, dbClient.Cfg.SetMiddlewarePattern("payments.example.com"), nil)
+	req, _ = http.NewRequest("GET", "http://payments.example.com/api/charges", nil)
+	expect(t, dbClient.hasMiddleware(req), true)
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"crypto/md5"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -20,6 +22,50 @@ type DBClient struct {
 	Cfg     *Configuration
 	Counter *CounterByMode
 	Hooks   ActionTypeHooks
+	Events  *CacheEvents
+	// Traffic - live traffic passed to admin interface subscribers
+	Traffic *TrafficStream
+	Diffs   *DiffReport
+	// Delays - latency added to simulated responses
+	Delays *ResponseDelays
+	// Faults - failures injected instead of responses
+	Faults *Faults
+	// Webhooks - callbacks fired after simulated responses
+	Webhooks *Webhooks
+	// MiddlewareChain - middleware stages set through admin API, they replace -middleware
+	MiddlewareChain *MiddlewareChain
+	// ErrorResponses - alternate responses returned to some requests
+	ErrorResponses *ErrorResponses
+	// RateLimits - limits of requests to destinations
+	RateLimits *RateLimits
+	// TemplateCounters - sequences used by response templates
+	TemplateCounters *TemplateCounters
+	// Sequences - number of calls of requests with response sequences
+	Sequences *Sequences
+	// ScenarioState - state required and changed by records
+	ScenarioState *ScenarioState
+	// HeaderRules - changes of captured and replayed headers
+	HeaderRules *HeaderRules
+	// HeaderMatches - request headers that are part of request fingerprint
+	HeaderMatches *HeaderMatches
+	// BodyMatchers - body matching modes of requests
+	BodyMatchers *BodyMatchers
+	// GRPC - descriptors of gRPC services, gRPC calls are handled as plain HTTP requests when not set
+	GRPC *GRPCDescriptors
+	// CustomMatchers - request matchers registered by applications embedding Hoverfly
+	CustomMatchers *CustomMatchers
+	// RecordIndex - records with matchers, they are scanned when request isn't found by its fingerprint
+	RecordIndex *RecordIndex
+	// KeyGenerator - turns requests into cache keys, applications embedding Hoverfly can set their own
+	KeyGenerator KeyGenerator
+	// Auth - users of admin interface, required when admin authentication is enabled
+	Auth *AuthStore
+	// Tracer - exports spans of handled requests, nil when tracing is disabled
+	Tracer *Tracer
+	// Journal - recently handled requests, nil when the journal is disabled
+	Journal *Journal
+	// Logging - log sinks and subsystem levels, nil when logs go straight to stderr
+	Logging *Logging
 }
 
 // AddHook - adds a hook to DBClient
@@ -30,6 +76,8 @@ func (d *DBClient) AddHook(hook Hook) {
 // RequestContainer holds structure for request
 type RequestContainer struct {
 	Details RequestDetails
+	// MatchHeaders - canonical names of request headers included in hash, sorted
+	MatchHeaders []string
 }
 
 var emptyResp = &http.Response{}
@@ -44,6 +92,10 @@ type RequestDetails struct {
 	Body        string              `json:"body"`
 	RemoteAddr  string              `json:"remoteAddr"`
 	Headers     map[string][]string `json:"headers"`
+	// Proto - protocol client used, e.g. HTTP/1.1 or HTTP/2.0, it is not part of request fingerprint
+	Proto string `json:"proto,omitempty"`
+	// BodyEncoding - encoding of body in exported simulations, see BodyEncodingBase64
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
 }
 
 func (r *RequestContainer) concatenate() string {
@@ -54,6 +106,12 @@ func (r *RequestContainer) concatenate() string {
 	buffer.WriteString(r.Details.Method)
 	buffer.WriteString(r.Details.Query)
 	buffer.WriteString(r.Details.Body)
+	for _, name := range r.MatchHeaders {
+		buffer.WriteString("\n")
+		buffer.WriteString(name)
+		buffer.WriteString(":")
+		buffer.WriteString(strings.Join(headerValues(r.Details.Headers, name), ","))
+	}
 
 	return buffer.String()
 }
@@ -72,6 +130,20 @@ type ResponseDetails struct {
 	Status  int                 `json:"status"`
 	Body    string              `json:"body"`
 	Headers map[string][]string `json:"headers"`
+	// Trailers - headers sent after the body, currently recorded for gRPC responses
+	Trailers map[string][]string `json:"trailers,omitempty"`
+	// Proto - protocol destination responded with
+	Proto string `json:"proto,omitempty"`
+	// Events - server-sent events of text/event-stream responses with their timing, they make up the body
+	Events []ServerSentEvent `json:"events,omitempty"`
+	// Truncated - body was bigger than maximum capture size, only its beginning was captured
+	Truncated bool `json:"truncated,omitempty"`
+	// Templated - body and header values are templates executed with request data when response is replayed
+	Templated bool `json:"templated,omitempty"`
+	// Weight - relative chance of response being chosen by SelectWeighted strategy, 1 when not set
+	Weight int `json:"weight,omitempty"`
+	// BodyEncoding - encoding of body in exported simulations, see BodyEncodingBase64
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
 }
 
 // Payload structure holds request and response structure
@@ -79,6 +151,32 @@ type Payload struct {
 	Response ResponseDetails `json:"response"`
 	Request  RequestDetails  `json:"request"`
 	ID       string          `json:"id"`
+	// Frames - messages exchanged through captured websocket connection, empty for plain HTTP requests
+	Frames []WebSocketFrame `json:"frames,omitempty"`
+	// Sequence - responses returned to following calls, first call gets Response, second one gets first response
+	// of the sequence and so on, the last response is then returned to all other calls. Other Selection strategies
+	// choose from Response and the sequence differently
+	Sequence []ResponseDetails `json:"sequence,omitempty"`
+	// Selection - strategy of choosing response, configured default is used when empty
+	Selection string `json:"selection,omitempty"`
+	// RequiresState - scenario state values needed to serve this record, i.e. {"order": "paid"}
+	RequiresState map[string]string `json:"requiresState,omitempty"`
+	// TransitionsState - scenario state values set once this record is served
+	TransitionsState map[string]string `json:"transitionsState,omitempty"`
+	// JSONPathMatchers - expressions request body has to satisfy instead of being equal to recorded body, i.e.
+	// $.order.type == "express"
+	JSONPathMatchers []string `json:"jsonPathMatchers,omitempty"`
+	// XPathMatchers - expressions XML request body has to satisfy, i.e. //GetQuote/symbol == "ACME"
+	XPathMatchers []string `json:"xpathMatchers,omitempty"`
+	// Matchers - patterns request path, query and headers are matched against, i.e. /users/*/orders
+	Matchers *RequestMatchers `json:"matchers,omitempty"`
+	// CustomMatchers - config of registered custom matchers by their name, all of them have to match the request
+	// and the body is matched only by them and body matchers
+	CustomMatchers map[string]json.RawMessage `json:"customMatchers,omitempty"`
+	// Priority - records with matchers and higher priority are preferred when more of them match the request
+	Priority int `json:"priority,omitempty"`
+	// CapturedAt - when the record was first captured, empty for records written by hand
+	CapturedAt *time.Time `json:"capturedAt,omitempty"`
 }
 
 // Encode method encodes all exported Payload fields to bytes
@@ -133,7 +231,8 @@ func (d *DBClient) captureRequest(req *http.Request) (*http.Response, error) {
 	resp, err := d.doRequest(req)
 
 	if err == nil {
-		respBody, err := extractBody(resp)
+		// event streams are captured together with timing of their events, big bodies are truncated
+		body, err := extractCapturedBody(resp, d.Cfg.MaxCaptureSize)
 
 		if err != nil {
 
@@ -146,7 +245,7 @@ func (d *DBClient) captureRequest(req *http.Request) (*http.Response, error) {
 		}
 
 		// saving response body with request/response meta to cache
-		d.save(req, reqBody, resp, respBody)
+		d.saveCaptured(req, reqBody, resp, body)
 	}
 
 	// return new response or error here
@@ -229,6 +328,7 @@ func getRequestDetails(req *http.Request) (requestObj RequestDetails, err error)
 		Body:        string(reqBody),
 		RemoteAddr:  req.RemoteAddr,
 		Headers:     req.Header,
+		Proto:       req.Proto,
 	}
 	return
 }
@@ -238,8 +338,10 @@ func (d *DBClient) doRequest(request *http.Request) (*http.Response, error) {
 
 	// We can't have this set. And it only contains "/pkg/net/http/" anyway
 	request.RequestURI = ""
+	// reconstructed request doesn't carry the context over
+	ctx := request.Context()
 
-	if d.Cfg.Middleware != "" {
+	if d.hasMiddleware(request) {
 		// middleware is provided, modifying request
 		var payload Payload
 
@@ -250,7 +352,7 @@ func (d *DBClient) doRequest(request *http.Request) (*http.Response, error) {
 		payload.Request = rd
 
 		c := NewConstructor(request, payload)
-		err = c.ApplyMiddleware(d.Cfg.Middleware)
+		err = d.applyMiddleware(c, d.Cfg.Middleware)
 
 		if err != nil {
 			log.WithFields(log.Fields{
@@ -269,9 +371,21 @@ func (d *DBClient) doRequest(request *http.Request) (*http.Response, error) {
 		}
 	}
 
+	span := startChildSpan(ctx, "upstream", SpanKindClient)
+	span.SetAttribute("http.method", request.Method)
+	span.SetAttribute("http.url", request.URL.String())
+	if span != nil {
+		// recorded request keeps headers it came with
+		request = request.WithContext(ctx)
+		request.Header = copyHeaders(request.Header)
+		span.Inject(request.Header)
+	}
+	defer span.Finish()
+
 	resp, err := d.HTTP.Do(request)
 
 	if err != nil {
+		span.SetError(err)
 		log.WithFields(log.Fields{
 			"mode":   d.Cfg.Mode,
 			"error":  err.Error(),
@@ -281,6 +395,7 @@ func (d *DBClient) doRequest(request *http.Request) (*http.Response, error) {
 		}).Error("could not forward request, failed to do an HTTP request.")
 		return nil, err
 	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
 
 	log.WithFields(log.Fields{
 		"mode":   d.Cfg.Mode,
@@ -296,16 +411,41 @@ func (d *DBClient) doRequest(request *http.Request) (*http.Response, error) {
 
 // save gets request fingerprint, extracts request body, status code and headers, then saves it to cache
 func (d *DBClient) save(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte) {
+	d.saveCaptured(req, reqBody, resp, capturedBody{data: respBody})
+}
+
+// saveCaptured - saves request with captured response body, events of event streams and truncation marker
+func (d *DBClient) saveCaptured(req *http.Request, reqBody []byte, resp *http.Response, body capturedBody) {
 	// record request here
-	key := getRequestFingerprint(req, reqBody)
+	key := d.requestFingerprint(req, reqBody)
 
 	if resp == nil {
 		resp = emptyResp
 	} else {
 		responseObj := ResponseDetails{
-			Status:  resp.StatusCode,
-			Body:    string(respBody),
-			Headers: resp.Header,
+			Status:    resp.StatusCode,
+			Body:      string(body.data),
+			Headers:   resp.Header,
+			Proto:     resp.Proto,
+			Events:    body.events,
+			Truncated: body.truncated,
+		}
+
+		if body.truncated {
+			// stored body is shorter than the original one
+			headers := make(http.Header)
+			for name, values := range resp.Header {
+				if name != "Content-Length" {
+					headers[name] = values
+				}
+			}
+			responseObj.Headers = headers
+
+			log.WithFields(log.Fields{
+				"path":        req.URL.Path,
+				"destination": req.Host,
+				"capturedLen": len(body.data),
+			}).Warn("Response body is bigger than maximum capture size, captured body is truncated")
 		}
 
 		log.WithFields(log.Fields{
@@ -326,38 +466,64 @@ func (d *DBClient) save(req *http.Request, reqBody []byte, resp *http.Response,
 			Body:        string(reqBody),
 			RemoteAddr:  req.RemoteAddr,
 			Headers:     req.Header,
+			Proto:       req.Proto,
 		}
 
+		capturedAt := time.Now().UTC()
 		payload := Payload{
-			Response: responseObj,
-			Request:  requestObj,
-			ID:       key,
+			Response:   responseObj,
+			Request:    requestObj,
+			ID:         key,
+			CapturedAt: &capturedAt,
 		}
 
-		bts, err := payload.Encode()
+		d.applyCaptureHeaderRules(&payload)
 
-		// hook
-		var en Entry
-		en.ActionType = ActionTypeRequestCaptured
-		en.Message = "captured"
-		en.Time = time.Now()
-		en.Data = bts
-
-		if err := d.Hooks.Fire(ActionTypeRequestCaptured, &en); err != nil {
-			log.WithFields(log.Fields{
-				"error":      err.Error(),
-				"message":    en.Message,
-				"actionType": ActionTypeRequestCaptured,
-			}).Error("failed to fire hook")
+		if d.Cfg.CaptureSequences {
+			payload = d.appendToSequence(key, payload)
 		}
 
-		if err != nil {
-			log.WithFields(log.Fields{
-				"error": err.Error(),
-			}).Error("Failed to serialize payload")
-		} else {
-			d.Cache.Set([]byte(key), bts)
-		}
+		d.storePayload(key, payload)
+	}
+}
+
+// storePayload - encodes captured payload, fires capture hooks and saves payload to cache
+func (d *DBClient) storePayload(key string, payload Payload) {
+	bts, err := payload.Encode()
+
+	// hook
+	var en Entry
+	en.ActionType = ActionTypeRequestCaptured
+	en.Message = "captured"
+	en.Time = time.Now()
+	en.Data = bts
+
+	if err := d.Hooks.Fire(ActionTypeRequestCaptured, &en); err != nil {
+		log.WithFields(log.Fields{
+			"error":      err.Error(),
+			"message":    en.Message,
+			"actionType": ActionTypeRequestCaptured,
+		}).Error("failed to fire hook")
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to serialize payload")
+		return
+	}
+
+	if d.Cfg.RecordTTL > 0 {
+		err = d.Cache.SetWithExpiry([]byte(key), bts, d.Cfg.RecordTTL)
+	} else {
+		err = d.Cache.Set([]byte(key), bts)
+	}
+	d.recordsChanged()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":   err.Error(),
+			"hashKey": key,
+		}).Error("Failed to save captured request")
 	}
 }
 
@@ -375,6 +541,32 @@ func getRequestFingerprint(req *http.Request, requestBody []byte) string {
 	return r.Hash()
 }
 
+// requestFingerprint - returns request hash with configured matching applied, i.e. matched headers of request
+// destination are included, ignored query parameters are left out and body is compared in its matching mode
+func (d *DBClient) requestFingerprint(req *http.Request, requestBody []byte) string {
+	return d.payloadKey(RequestDetails{
+		Path:        req.URL.Path,
+		Method:      req.Method,
+		Destination: req.Host,
+		Query:       req.URL.RawQuery,
+		Body:        string(requestBody),
+		Headers:     req.Header,
+	})
+}
+
+// payloadKey - returns key of recorded request, the same one its live request gets from requestFingerprint
+func (d *DBClient) payloadKey(request RequestDetails) string {
+	request.Query = stripQueryParams(request.Query, d.Cfg.IgnoreQueryParams)
+	request.Body = d.matchedBody(request)
+	if d.Cfg.CaseInsensitivePaths {
+		request.Path = strings.ToLower(request.Path)
+	}
+	if d.Cfg.CaseInsensitiveHeaders {
+		request.Headers = lowerHeaderValues(request.Headers)
+	}
+	return d.keyGenerator().Key(RequestContainer{Details: request, MatchHeaders: d.matchHeaders(request.Destination)})
+}
+
 // getResponse returns stored response from cache
 func (d *DBClient) getResponse(req *http.Request) *http.Response {
 
@@ -390,56 +582,141 @@ func (d *DBClient) getResponse(req *http.Request) *http.Response {
 		}).Error("Got error when reading request body")
 	}
 
-	key := getRequestFingerprint(req, reqBody)
+	key := d.requestFingerprint(req, reqBody)
+	req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
 
+	span := startChildSpan(req.Context(), "match", SpanKindInternal)
 	payloadBts, err := d.Cache.Get([]byte(key))
 
 	if err == nil {
-		// getting cache response
-		payload, err := decodePayload(payloadBts)
-		if err != nil {
+		span.SetAttribute("hoverfly.matched", true)
+		span.Finish()
+		return d.cachedResponse(req, key, payloadBts, VirtualizeMode)
+	}
+	if matchedReq, matchedKey, payloadBts, ok := d.matcherRecord(req, reqBody); ok {
+		span.SetAttribute("hoverfly.matched", true)
+		span.SetAttribute("hoverfly.partial_match", true)
+		span.Finish()
+		return d.cachedResponse(matchedReq, matchedKey, payloadBts, VirtualizeMode)
+	}
+	span.SetAttribute("hoverfly.matched", false)
+	span.Finish()
+
+	// return error? if we return nil - proxy forwards request to original destination
+	return d.missResponse(req, reqBody, err, false)
+}
+
+// cachedResponse - reconstructs response from payload found in cache, middleware is applied when configured
+func (d *DBClient) cachedResponse(req *http.Request, key string, payloadBts []byte, mode string) *http.Response {
+	payload, err := decodePayload(payloadBts)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"value": string(payloadBts),
+			"key":   key,
+		}).Error("Failed to decode payload")
+		return hoverflyError(req, err, "Failed to virtualize", http.StatusInternalServerError)
+	}
+
+	// state changes only once the response is built, so a failed template or middleware doesn't advance the flow
+	scenario := (len(payload.RequiresState) > 0 || len(payload.TransitionsState) > 0) && d.ScenarioState != nil
+	if scenario && !d.ScenarioState.Satisfies(payload.RequiresState) {
+		return d.scenarioStateMiss(req, key, payload)
+	}
+
+	markJournalMatch(req, true, key)
+
+	if len(payload.Sequence) > 0 && d.Sequences != nil {
+		selection := payload.Selection
+		if selection == "" {
+			selection = d.Cfg.GetResponseSelection()
+		}
+		payload.Response = payload.selectResponse(selection, d.Sequences.Next(key))
+	}
+
+	if payload.Response.Templated {
+		var body []byte
+		if req.Body != nil {
+			body, _ = ioutil.ReadAll(req.Body)
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		if err := renderResponseTemplate(&payload.Response, req, body, d.TemplateCounters); err != nil {
 			log.WithFields(log.Fields{
 				"error": err.Error(),
-				"value": string(payloadBts),
 				"key":   key,
-			}).Error("Failed to decode payload")
-			return hoverflyError(req, err, "Failed to virtualize", http.StatusInternalServerError)
+			}).Error("Failed to render response template")
+			return hoverflyError(req, err, "Failed to render response template", http.StatusInternalServerError)
 		}
+	}
 
-		c := NewConstructor(req, *payload)
+	if scheme, host := d.rewriteBase(req); host != "" {
+		rewriteResponseURLs(&payload.Response, payload.Request.Destination, scheme, host)
+	}
+
+	c := NewConstructor(req, *payload)
 
-		if d.Cfg.Middleware != "" {
-			_ = c.ApplyMiddleware(d.Cfg.Middleware)
+	if d.hasMiddleware(req) {
+		if err := d.applyReplayMiddleware(c, d.Cfg.Middleware); err != nil {
+			return hoverflyError(req, err, fmt.Sprintf("Middleware (%s) failed", d.middlewareName()),
+				http.StatusServiceUnavailable)
 		}
+	}
 
-		response := c.ReconstructResponse()
+	response := c.ReconstructResponse()
 
-		log.WithFields(log.Fields{
-			"key":         key,
-			"mode":        "virtualize",
-			"middleware":  d.Cfg.Middleware,
-			"path":        req.URL.Path,
-			"rawQuery":    req.URL.RawQuery,
-			"method":      req.Method,
-			"destination": req.Host,
-			"status":      payload.Response.Status,
-			"bodyLength":  response.ContentLength,
-		}).Info("Response found, returning")
+	// state is checked again as it might have changed while the response was built
+	if scenario && !d.ScenarioState.Transition(payload.RequiresState, payload.TransitionsState) {
+		return d.scenarioStateMiss(req, key, payload)
+	}
 
-		return response
+	if d.HeaderRules != nil {
+		response.Header = d.HeaderRules.Apply(HeaderRuleReplay, false, req.Method, req.Host, req.URL.Path, response.Header)
+	}
+	d.addCORSHeaders(req, response)
 
+	if d.Cfg.MatchDebug {
+		response.Header.Set("Hoverfly-Match", matchedBy(req, key))
 	}
 
+	d.applyResponseDelay(req)
+
+	// event streams are replayed event by event instead of all at once
+	if isEventStream(response.Header) {
+		if body, ok := eventStreamBody(c.payload.Response, d.Cfg.GetSSESpeed()); ok {
+			response.Body = body
+			response.ContentLength = -1
+			response.Header.Del("Content-Length")
+		}
+	}
+
+	d.fireWebhooks(req, c.payload.Response)
+
 	log.WithFields(log.Fields{
 		"key":         key,
-		"error":       err.Error(),
-		"query":       req.URL.RawQuery,
-		"path":        req.URL.RawPath,
-		"destination": req.Host,
+		"mode":        mode,
+		"middleware":  d.middlewareName(),
+		"path":        req.URL.Path,
+		"rawQuery":    req.URL.RawQuery,
 		"method":      req.Method,
-	}).Warn("Failed to retrieve response from cache")
-	// return error? if we return nil - proxy forwards request to original destination
-	return hoverflyError(req, err, "Could not find recorded request, please record it first!", http.StatusPreconditionFailed)
+		"destination": req.Host,
+		"status":      payload.Response.Status,
+		"bodyLength":  response.ContentLength,
+		"truncated":   payload.Response.Truncated,
+	}).Info("Response found, returning")
+
+	return response
+}
+
+// scenarioStateMiss - builds response to request whose record requires different scenario state
+func (d *DBClient) scenarioStateMiss(req *http.Request, key string, payload *Payload) *http.Response {
+	log.WithFields(log.Fields{
+		"key":           key,
+		"path":          req.URL.Path,
+		"destination":   req.Host,
+		"requiresState": payload.RequiresState,
+	}).Warn("Recorded request requires different scenario state")
+	return hoverflyError(req, fmt.Errorf("state %v required", payload.RequiresState),
+		"Recorded request requires different scenario state", d.Cfg.GetMissStatus())
 }
 
 // modifyRequestResponse modifies outgoing request and then modifies incoming response, neither request nor response
@@ -463,8 +740,9 @@ func (d *DBClient) modifyRequestResponse(req *http.Request, middleware string) (
 		return nil, err
 	}
 
-	// preparing payload
+	// preparing payload, upstream response is replaced by the one constructed from middleware output
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
 
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -478,13 +756,14 @@ func (d *DBClient) modifyRequestResponse(req *http.Request, middleware string) (
 		Status:  resp.StatusCode,
 		Body:    string(bodyBytes),
 		Headers: resp.Header,
+		Proto:   resp.Proto,
 	}
 
 	payload := Payload{Response: r, Request: rd}
 
 	c := NewConstructor(req, payload)
 	// applying middleware to modify response
-	err = c.ApplyMiddleware(middleware)
+	err = d.applyMiddleware(c, middleware)
 
 	if err != nil {
 		return nil, err
@@ -144,6 +144,19 @@ func TestGetNotRecordedRequest(t *testing.T) {
 	expect(t, response.StatusCode, http.StatusPreconditionFailed)
 }
 
+func TestGetNotRecordedRequestMissStatus(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.MissStatus = http.StatusNotFound
+
+	request, _ := http.NewRequest("POST", "http://capture_body.com", nil)
+
+	response := dbClient.getResponse(request)
+
+	expect(t, response.StatusCode, http.StatusNotFound)
+}
+
 // TestRequestFingerprint tests whether we get correct request ID
 func TestRequestFingerprint(t *testing.T) {
 
@@ -246,6 +259,26 @@ func TestModifyRequest(t *testing.T) {
 
 }
 
+func TestModifyResponseBody(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.Middleware = "./examples/middleware/modify_response/modify_response.py"
+
+	req, err := http.NewRequest("GET", "http://very-interesting-website.com/q=123", nil)
+	expect(t, err, nil)
+
+	response, err := dbClient.modifyRequestResponse(req, dbClient.Cfg.Middleware)
+	expect(t, err, nil)
+
+	// live response status and body are replaced by middleware
+	expect(t, response.StatusCode, 201)
+
+	body, err := ioutil.ReadAll(response.Body)
+	expect(t, err, nil)
+	expect(t, string(body), "body was replaced by middleware\n")
+}
+
 func TestModifyRequestWODestination(t *testing.T) {
 	// tests modify mode but uses different middleware to not supply destination
 	server, dbClient := testTools(201, `{'message': 'here'}`)
@@ -0,0 +1,85 @@
+package hoverfly
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"strings"
+)
+
+// multipartBoundary - returns boundary of multipart body, it's taken from the first delimiter line so bodies of
+// imported records without Content-Type header can be parsed as well
+func multipartBoundary(body string) (string, error) {
+	line, err := bufio.NewReader(strings.NewReader(body)).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n ")
+	if !strings.HasPrefix(line, "--") || len(line) == 2 {
+		return "", fmt.Errorf("body doesn't start with multipart boundary")
+	}
+	return line[2:], nil
+}
+
+// canonicalMultipart - returns multipart body as form encoded part descriptions, boundary doesn't matter. Each part
+// is described by its content hash, file parts by file name and content hash, or only by file name when file
+// contents are ignored. Parts of ignored fields are left out
+func canonicalMultipart(body string, ignored []string, ignoreFileContents bool) (string, error) {
+	boundary, err := multipartBoundary(body)
+	if err != nil {
+		return "", err
+	}
+
+	parts := url.Values{}
+	reader := multipart.NewReader(strings.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		name := part.FormName()
+		if isIgnoredQueryParam(name, ignored) {
+			continue
+		}
+
+		var description string
+		if filename := part.FileName(); filename != "" {
+			description = "filename=" + filename
+			if !ignoreFileContents {
+				hash, err := partHash(part)
+				if err != nil {
+					return "", err
+				}
+				description += "; sha256=" + hash
+			}
+		} else {
+			hash, err := partHash(part)
+			if err != nil {
+				return "", err
+			}
+			description = "sha256=" + hash
+		}
+		parts.Add(name, description)
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("multipart body has no parts")
+	}
+	return parts.Encode(), nil
+}
+
+// partHash - returns hex encoded SHA-256 of part content
+func partHash(part *multipart.Part) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, part); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
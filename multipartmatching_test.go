@@ -0,0 +1,90 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// multipartBody - returns multipart body with description field and uploaded file, boundary is random
+func multipartBody(description, filename, content string) (string, string) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("description", description)
+	file, _ := writer.CreateFormFile("upload", filename)
+	file.Write([]byte(content))
+	writer.Close()
+	return body.String(), writer.FormDataContentType()
+}
+
+func TestCanonicalMultipart(t *testing.T) {
+	first, _ := multipartBody("invoice", "invoice.pdf", "%PDF-1.4")
+	second, _ := multipartBody("invoice", "invoice.pdf", "%PDF-1.4")
+	refute(t, first, second)
+
+	a, err := canonicalMultipart(first, nil, false)
+	expect(t, err, nil)
+	b, _ := canonicalMultipart(second, nil, false)
+	expect(t, a, b)
+	expect(t, strings.Contains(a, "filename%3Dinvoice.pdf"), true)
+
+	other, _ := multipartBody("invoice", "invoice.pdf", "%PDF-1.5")
+	b, _ = canonicalMultipart(other, nil, false)
+	refute(t, a, b)
+
+	a, _ = canonicalMultipart(first, nil, true)
+	b, _ = canonicalMultipart(other, nil, true)
+	expect(t, a, b)
+
+	a, _ = canonicalMultipart(first, []string{"upload"}, false)
+	expect(t, strings.Contains(a, "upload"), false)
+
+	// bodies that aren't multipart are matched exactly
+	_, err = canonicalMultipart("name=alice", nil, false)
+	refute(t, err, nil)
+	expect(t, normalizeBody(BodyMatchMultipart, "name=alice"), "name=alice")
+}
+
+func TestMultipartBodyMatching(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	expect(t, dbClient.BodyMatchers.Set([]BodyMatcher{
+		{URLPattern: "/documents", Mode: BodyMatchMultipart, IgnoreFileContents: true},
+		{URLPattern: "/avatars", Mode: BodyMatchMultipart},
+	}), nil)
+
+	recorded, _ := multipartBody("invoice", "invoice.pdf", "%PDF-1.4")
+	storeTestPayload(dbClient, "POST", "http://api.example.com/documents", recorded, 201, "stored")
+	recorded, _ = multipartBody("me", "me.png", "PNG")
+	storeTestPayload(dbClient, "POST", "http://api.example.com/avatars", recorded, 201, "uploaded")
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	upload := func(path, description, filename, content string) (int, string) {
+		body, contentType := multipartBody(description, filename, content)
+		req, _ := http.NewRequest("POST", "http://api.example.com"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		_, resp := dbClient.processRequest(req)
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(respBody)
+	}
+
+	status, body := upload("/documents", "invoice", "invoice.pdf", "%PDF-1.7 different file")
+	expect(t, status, 201)
+	expect(t, body, "stored")
+
+	status, _ = upload("/documents", "receipt", "invoice.pdf", "%PDF-1.4")
+	expect(t, status, dbClient.Cfg.GetMissStatus())
+
+	status, body = upload("/avatars", "me", "me.png", "PNG")
+	expect(t, status, 201)
+	expect(t, body, "uploaded")
+
+	dbClient.Cfg.MissDebug = true
+	status, body = upload("/avatars", "me", "me.png", "GIF")
+	expect(t, status, dbClient.Cfg.GetMissStatus())
+	expect(t, strings.Contains(body, "form field upload: expected \"[redacted]\""), true)
+}
@@ -0,0 +1,364 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// openAPIMethods - operations of OpenAPI path item, in the order records are generated
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// maxExampleDepth - nesting of generated examples, recursive schemas stop there
+const maxExampleDepth = 8
+
+// openAPIPathParam - templated path segment, i.e. {id}
+var openAPIPathParam = regexp.MustCompile(`\{[^}/]*\}`)
+
+// openAPIDocument - OpenAPI 3 or Swagger 2 document, kept generic so both versions and local references can be read
+type openAPIDocument map[string]interface{}
+
+// isOpenAPIDocument - checks whether JSON document is OpenAPI 3 or Swagger 2 specification
+func isOpenAPIDocument(data []byte) bool {
+	var header struct {
+		OpenAPI string `json:"openapi"`
+		Swagger string `json:"swagger"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return false
+	}
+	return header.OpenAPI != "" || header.Swagger != ""
+}
+
+// object - returns value as JSON object, empty when it isn't one
+func object(value interface{}) map[string]interface{} {
+	if o, ok := value.(map[string]interface{}); ok {
+		return o
+	}
+	return map[string]interface{}{}
+}
+
+// sortedKeys - returns sorted keys of JSON object, so generated records don't depend on map order
+func sortedKeys(o map[string]interface{}) []string {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// resolve - follows local reference (i.e. {"$ref": "#/components/schemas/User"}), other values are returned as
+// they are
+func (doc openAPIDocument) resolve(value interface{}) interface{} {
+	for i := 0; i < maxExampleDepth; i++ {
+		ref, ok := object(value)["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return value
+		}
+		var current interface{} = map[string]interface{}(doc)
+		for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+			current = object(current)[token]
+		}
+		value = current
+	}
+	return value
+}
+
+// server - returns scheme, destination and base path of the API, destination is empty when document doesn't have
+// an absolute server URL
+func (doc openAPIDocument) server() (string, string, string) {
+	if swagger, _ := doc["swagger"].(string); swagger != "" {
+		scheme := "http"
+		if schemes, ok := doc["schemes"].([]interface{}); ok && len(schemes) > 0 {
+			scheme, _ = schemes[0].(string)
+		}
+		host, _ := doc["host"].(string)
+		basePath, _ := doc["basePath"].(string)
+		return scheme, host, strings.TrimSuffix(basePath, "/")
+	}
+
+	servers, _ := doc["servers"].([]interface{})
+	if len(servers) == 0 {
+		return "http", "", ""
+	}
+	server := object(servers[0])
+	serverURL, _ := server["url"].(string)
+	// server variables are replaced with their defaults
+	for name, variable := range object(server["variables"]) {
+		if value, ok := object(variable)["default"].(string); ok {
+			serverURL = strings.Replace(serverURL, "{"+name+"}", value, -1)
+		}
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "http", "", ""
+	}
+	scheme := u.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme, u.Host, strings.TrimSuffix(u.Path, "/")
+}
+
+// exampleValue - returns example of schema, the one given by the schema or generated from its type
+func (doc openAPIDocument) exampleValue(schemaValue interface{}, depth int) interface{} {
+	schema := object(doc.resolve(schemaValue))
+	if example, ok := schema["example"]; ok {
+		return example
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	if def, ok := schema["default"]; ok {
+		return def
+	}
+	if depth >= maxExampleDepth {
+		return nil
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		merged := map[string]interface{}{}
+		for _, part := range allOf {
+			for key, value := range object(doc.exampleValue(part, depth+1)) {
+				merged[key] = value
+			}
+		}
+		return merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alternatives, ok := schema[key].([]interface{}); ok && len(alternatives) > 0 {
+			return doc.exampleValue(alternatives[0], depth+1)
+		}
+	}
+
+	schemaType, _ := schema["type"].(string)
+	if schemaType == "" && schema["properties"] != nil {
+		schemaType = "object"
+	}
+	switch schemaType {
+	case "object":
+		example := map[string]interface{}{}
+		properties := object(schema["properties"])
+		for _, name := range sortedKeys(properties) {
+			example[name] = doc.exampleValue(properties[name], depth+1)
+		}
+		return example
+	case "array":
+		return []interface{}{doc.exampleValue(schema["items"], depth+1)}
+	case "integer", "number":
+		if minimum, ok := schema["minimum"].(float64); ok {
+			return minimum
+		}
+		return 0
+	case "boolean":
+		return true
+	case "string":
+		switch schema["format"] {
+		case "date-time":
+			return "2016-01-01T00:00:00Z"
+		case "date":
+			return "2016-01-01"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		case "uri", "url":
+			return "http://example.com"
+		}
+		return "string"
+	}
+	return nil
+}
+
+// exampleBody - returns example value serialized for given media type
+func exampleBody(example interface{}, mediaType string) string {
+	if text, ok := example.(string); ok && !strings.Contains(mediaType, "json") {
+		return text
+	}
+	body, err := json.Marshal(example)
+	if err != nil {
+		return ""
+	}
+	return string(body)
+}
+
+// preferredMediaType - returns JSON media type when there is one, otherwise the first one
+func preferredMediaType(mediaTypes []string) string {
+	if len(mediaTypes) == 0 {
+		return ""
+	}
+	sort.Strings(mediaTypes)
+	for _, mediaType := range mediaTypes {
+		if strings.Contains(mediaType, "json") {
+			return mediaType
+		}
+	}
+	return mediaTypes[0]
+}
+
+// successResponse - returns status code and description of the response that is simulated, the lowest 2xx one,
+// then "default", then the lowest other one
+func successResponse(responses map[string]interface{}) (int, interface{}, bool) {
+	codes := sortedKeys(responses)
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			if status, err := strconv.Atoi(code); err == nil {
+				return status, responses[code], true
+			}
+		}
+	}
+	if response, ok := responses["default"]; ok {
+		return 200, response, true
+	}
+	for _, code := range codes {
+		if status, err := strconv.Atoi(code); err == nil {
+			return status, responses[code], true
+		}
+	}
+	return 0, nil, false
+}
+
+// operationResponse - returns response of operation with example body
+func (doc openAPIDocument) operationResponse(operation map[string]interface{}) (ResponseDetails, bool) {
+	status, responseValue, ok := successResponse(object(operation["responses"]))
+	if !ok {
+		return ResponseDetails{}, false
+	}
+	response := object(doc.resolve(responseValue))
+	details := ResponseDetails{Status: status, Headers: map[string][]string{}}
+
+	var mediaType string
+	var example interface{}
+	hasExample := false
+	if content := object(response["content"]); len(content) > 0 {
+		// OpenAPI 3
+		mediaType = preferredMediaType(sortedKeys(content))
+		media := object(content[mediaType])
+		if value, ok := media["example"]; ok {
+			example, hasExample = value, true
+		} else if examples := object(media["examples"]); len(examples) > 0 {
+			example, hasExample = object(doc.resolve(examples[sortedKeys(examples)[0]]))["value"], true
+		} else if media["schema"] != nil {
+			example, hasExample = doc.exampleValue(media["schema"], 0), true
+		}
+	} else {
+		// Swagger 2
+		examples := object(response["examples"])
+		if len(examples) > 0 {
+			mediaType = preferredMediaType(sortedKeys(examples))
+			example, hasExample = examples[mediaType], true
+		} else if response["schema"] != nil {
+			var produces []string
+			for _, value := range append(toSlice(doc["produces"]), toSlice(operation["produces"])...) {
+				if s, ok := value.(string); ok {
+					produces = append(produces, s)
+				}
+			}
+			mediaType = preferredMediaType(produces)
+			if mediaType == "" {
+				mediaType = "application/json"
+			}
+			example, hasExample = doc.exampleValue(response["schema"], 0), true
+		}
+	}
+
+	if hasExample {
+		details.Body = exampleBody(example, mediaType)
+	}
+	if mediaType != "" {
+		details.Headers["Content-Type"] = []string{mediaType}
+	}
+	return details, true
+}
+
+// toSlice - returns value as JSON array, empty when it isn't one
+func toSlice(value interface{}) []interface{} {
+	if s, ok := value.([]interface{}); ok {
+		return s
+	}
+	return nil
+}
+
+// openAPIPayloads - generates record for every operation of the document, destination is used when document doesn't
+// have an absolute server URL (or to override it)
+func openAPIPayloads(doc openAPIDocument, destination string) []Payload {
+	scheme, host, basePath := doc.server()
+	if destination != "" {
+		host = destination
+	}
+	if host == "" {
+		host = "localhost"
+	}
+
+	var payloads []Payload
+	paths := object(doc["paths"])
+	for _, path := range sortedKeys(paths) {
+		item := object(doc.resolve(paths[path]))
+		for _, method := range openAPIMethods {
+			operation, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			response, ok := doc.operationResponse(operation)
+			if !ok {
+				log.WithFields(log.Fields{
+					"path":   path,
+					"method": method,
+				}).Warn("Skipping OpenAPI operation without responses")
+				continue
+			}
+
+			fullPath := basePath + path
+			matchers := &RequestMatchers{
+				// any query and body are fine, the stub answers every call of the operation
+				RawQuery: &FieldMatcher{Glob: "**"},
+				Body:     &FieldMatcher{Glob: "**"},
+			}
+			request := RequestDetails{Method: strings.ToUpper(method), Destination: host, Scheme: scheme}
+			params := len(openAPIPathParam.FindAllString(fullPath, -1))
+			if params > 0 {
+				matchers.Path = &FieldMatcher{Glob: openAPIPathParam.ReplaceAllString(fullPath, "*")}
+			} else {
+				request.Path = fullPath
+			}
+
+			payloads = append(payloads, Payload{
+				Request:  request,
+				Response: response,
+				Matchers: matchers,
+				// /users/me is preferred to /users/{id}
+				Priority: -params,
+			})
+		}
+	}
+	return payloads
+}
+
+// ImportOpenAPI - reads OpenAPI 3 or Swagger 2 JSON document and imports stub record for every operation, so API
+// can be simulated before it was ever called. Destination overrides server of the document, it's needed when
+// document has only relative server URL
+func (d *DBClient) ImportOpenAPI(r io.Reader, destination string) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("Got error while reading OpenAPI document, error %s", err.Error())
+	}
+	if !isOpenAPIDocument(data) {
+		return fmt.Errorf("Got error while parsing OpenAPI document, only JSON documents with 'openapi' or 'swagger' version are accepted")
+	}
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("Got error while parsing OpenAPI document, error %s", err.Error())
+	}
+	return d.ImportPayloads(openAPIPayloads(doc, destination))
+}
@@ -0,0 +1,415 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPIIDSegment - path segments that look like identifiers (numbers, UUIDs, long hex strings), they are turned
+// into path parameters
+var openAPIIDSegment = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+// openAPIOperation - records of one path template and method
+type openAPIOperation struct {
+	params   []string
+	payloads []Payload
+}
+
+// templatePath - returns path with identifier segments replaced by parameters and names of the parameters, i.e.
+// /users/42/orders becomes /users/{userId}/orders
+func templatePath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if !openAPIIDSegment.MatchString(segment) {
+			continue
+		}
+		name := "id"
+		if i > 0 && segments[i-1] != "" && !strings.HasPrefix(segments[i-1], "{") {
+			name = strings.TrimSuffix(segments[i-1], "s") + "Id"
+		}
+		for _, param := range params {
+			if param == name {
+				name = fmt.Sprintf("%s%d", name, len(params)+1)
+			}
+		}
+		params = append(params, name)
+		segments[i] = "{" + name + "}"
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// inferSchema - returns JSON schema of decoded JSON value
+func inferSchema(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties := map[string]interface{}{}
+		required := make([]string, 0, len(v))
+		for name, property := range v {
+			properties[name] = inferSchema(property)
+			required = append(required, name)
+		}
+		sort.Strings(required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	case []interface{}:
+		var items map[string]interface{}
+		for i, item := range v {
+			if i == 0 {
+				items = inferSchema(item)
+			} else {
+				items = mergeSchemas(items, inferSchema(item))
+			}
+		}
+		if items == nil {
+			// empty array says nothing about its items, any schema will do
+			items = map[string]interface{}{}
+		}
+		return map[string]interface{}{"type": "array", "items": items}
+	case string:
+		if _, err := time.Parse(time.RFC3339, v); err == nil {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		return map[string]interface{}{"type": "string"}
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return map[string]interface{}{"type": "integer"}
+		}
+		return map[string]interface{}{"type": "number"}
+	case float64:
+		if v == float64(int64(v)) {
+			return map[string]interface{}{"type": "integer"}
+		}
+		return map[string]interface{}{"type": "number"}
+	case bool:
+		return map[string]interface{}{"type": "boolean"}
+	}
+	// null says nothing about the type
+	return map[string]interface{}{"nullable": true}
+}
+
+// mergeSchemas - returns schema both values satisfy, object properties missing in one of them aren't required,
+// values of different types get schema without type
+func mergeSchemas(a, b map[string]interface{}) map[string]interface{} {
+	if a["nullable"] == true && a["type"] == nil {
+		merged := copySchema(b)
+		merged["nullable"] = true
+		return merged
+	}
+	if b["nullable"] == true && b["type"] == nil {
+		merged := copySchema(a)
+		merged["nullable"] = true
+		return merged
+	}
+
+	typeA, typeB := a["type"], b["type"]
+	if typeA != typeB {
+		if (typeA == "integer" && typeB == "number") || (typeA == "number" && typeB == "integer") {
+			return map[string]interface{}{"type": "number"}
+		}
+		return map[string]interface{}{}
+	}
+
+	merged := copySchema(a)
+	if b["nullable"] == true {
+		merged["nullable"] = true
+	}
+	if a["format"] != b["format"] {
+		delete(merged, "format")
+	}
+	switch typeA {
+	case "object":
+		propertiesA, propertiesB := object(a["properties"]), object(b["properties"])
+		properties := map[string]interface{}{}
+		for name, property := range propertiesA {
+			if other, ok := propertiesB[name]; ok {
+				properties[name] = mergeSchemas(object(property), object(other))
+			} else {
+				properties[name] = property
+			}
+		}
+		for name, property := range propertiesB {
+			if _, ok := propertiesA[name]; !ok {
+				properties[name] = property
+			}
+		}
+		merged["properties"] = properties
+
+		var required []string
+		requiredB := map[string]bool{}
+		for _, name := range toStrings(b["required"]) {
+			requiredB[name] = true
+		}
+		for _, name := range toStrings(a["required"]) {
+			if requiredB[name] {
+				required = append(required, name)
+			}
+		}
+		delete(merged, "required")
+		if len(required) > 0 {
+			merged["required"] = required
+		}
+	case "array":
+		switch {
+		case len(object(a["items"])) == 0:
+			merged["items"] = b["items"]
+		case len(object(b["items"])) > 0:
+			merged["items"] = mergeSchemas(object(a["items"]), object(b["items"]))
+		}
+	}
+	return merged
+}
+
+// copySchema - returns shallow copy of schema
+func copySchema(schema map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		copied[key] = value
+	}
+	return copied
+}
+
+// toStrings - returns value as list of strings
+func toStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// valueSchema - returns schema of path or query parameter value
+func valueSchema(values []string) map[string]interface{} {
+	integers := len(values) > 0
+	for _, value := range values {
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			integers = false
+		}
+	}
+	if integers {
+		return map[string]interface{}{"type": "integer"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+// bodyContent - returns OpenAPI content object of bodies with their media type, JSON bodies get inferred schema and
+// example, others are described as strings
+func bodyContent(bodies []string, headers []map[string][]string) map[string]interface{} {
+	content := map[string]interface{}{}
+	for i, body := range bodies {
+		mediaType := "application/octet-stream"
+		if contentType := headerValues(headers[i], "Content-Type"); len(contentType) > 0 {
+			if parsed, _, err := mime.ParseMediaType(contentType[0]); err == nil {
+				mediaType = parsed
+			}
+		}
+
+		var schema map[string]interface{}
+		var example interface{}
+		decoder := json.NewDecoder(strings.NewReader(body))
+		decoder.UseNumber()
+		if strings.Contains(mediaType, "json") && decoder.Decode(&example) == nil {
+			schema = inferSchema(example)
+		} else {
+			schema = map[string]interface{}{"type": "string"}
+			example = body
+		}
+
+		if existing, ok := content[mediaType]; ok {
+			media := object(existing)
+			media["schema"] = mergeSchemas(object(media["schema"]), schema)
+			continue
+		}
+		content[mediaType] = map[string]interface{}{"schema": schema, "example": example}
+	}
+	return content
+}
+
+// operationDocument - returns OpenAPI operation object describing records
+func operationDocument(operation *openAPIOperation) map[string]interface{} {
+	var parameters []interface{}
+
+	// path parameters, values are taken from concrete paths
+	pathValues := make(map[string][]string)
+	for _, pl := range operation.payloads {
+		segments := strings.Split(pl.Request.Path, "/")
+		param := 0
+		for _, segment := range segments {
+			if openAPIIDSegment.MatchString(segment) && param < len(operation.params) {
+				pathValues[operation.params[param]] = append(pathValues[operation.params[param]], segment)
+				param++
+			}
+		}
+	}
+	for _, name := range operation.params {
+		parameters = append(parameters, map[string]interface{}{
+			"name": name, "in": "path", "required": true, "schema": valueSchema(pathValues[name]),
+		})
+	}
+
+	// query parameters present in every record are required
+	queryValues := make(map[string][]string)
+	queryCounts := make(map[string]int)
+	for _, pl := range operation.payloads {
+		query, _ := url.ParseQuery(pl.Request.Query)
+		for name, values := range query {
+			queryValues[name] = append(queryValues[name], values...)
+			queryCounts[name]++
+		}
+	}
+	names := make([]string, 0, len(queryValues))
+	for name := range queryValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parameters = append(parameters, map[string]interface{}{
+			"name":     name,
+			"in":       "query",
+			"required": queryCounts[name] == len(operation.payloads),
+			"schema":   valueSchema(queryValues[name]),
+		})
+	}
+
+	doc := map[string]interface{}{}
+	if len(parameters) > 0 {
+		doc["parameters"] = parameters
+	}
+
+	var requestBodies []string
+	var requestHeaders []map[string][]string
+	for _, pl := range operation.payloads {
+		if pl.Request.Body != "" {
+			requestBodies = append(requestBodies, pl.Request.Body)
+			requestHeaders = append(requestHeaders, pl.Request.Headers)
+		}
+	}
+	if len(requestBodies) > 0 {
+		doc["requestBody"] = map[string]interface{}{"content": bodyContent(requestBodies, requestHeaders)}
+	}
+
+	responseBodies := make(map[int][]string)
+	responseHeaders := make(map[int][]map[string][]string)
+	for _, pl := range operation.payloads {
+		status := pl.Response.Status
+		if _, ok := responseBodies[status]; !ok {
+			responseBodies[status] = nil
+		}
+		if pl.Response.Body != "" {
+			responseBodies[status] = append(responseBodies[status], pl.Response.Body)
+			responseHeaders[status] = append(responseHeaders[status], pl.Response.Headers)
+		}
+	}
+	responses := map[string]interface{}{}
+	for status, bodies := range responseBodies {
+		description := http.StatusText(status)
+		if description == "" {
+			description = "Response"
+		}
+		response := map[string]interface{}{"description": description}
+		if len(bodies) > 0 {
+			response["content"] = bodyContent(bodies, responseHeaders[status])
+		}
+		responses[strconv.Itoa(status)] = response
+	}
+	doc["responses"] = responses
+	return doc
+}
+
+// openAPIDocumentOf - returns OpenAPI 3 document inferred from records, records matched with path patterns aren't
+// described as they don't have concrete path
+func openAPIDocumentOf(payloads []Payload, title string) map[string]interface{} {
+	operations := make(map[string]map[string]*openAPIOperation)
+	servers := make(map[string]bool)
+	for _, pl := range payloads {
+		if pl.Request.Path == "" || pl.Request.Method == "" {
+			continue
+		}
+		scheme := pl.Request.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		servers[scheme+"://"+pl.Request.Destination] = true
+
+		path, params := templatePath(pl.Request.Path)
+		method := strings.ToLower(pl.Request.Method)
+		if operations[path] == nil {
+			operations[path] = make(map[string]*openAPIOperation)
+		}
+		if operations[path][method] == nil {
+			operations[path][method] = &openAPIOperation{params: params}
+		}
+		operations[path][method].payloads = append(operations[path][method].payloads, pl)
+	}
+
+	paths := map[string]interface{}{}
+	for path, methods := range operations {
+		item := map[string]interface{}{}
+		for method, operation := range methods {
+			item[method] = operationDocument(operation)
+		}
+		paths[path] = item
+	}
+
+	serverURLs := make([]string, 0, len(servers))
+	for server := range servers {
+		serverURLs = append(serverURLs, server)
+	}
+	sort.Strings(serverURLs)
+	serverList := make([]interface{}, 0, len(serverURLs))
+	for _, server := range serverURLs {
+		serverList = append(serverList, map[string]interface{}{"url": server})
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":       title,
+			"version":     "1.0.0",
+			"description": "Generated by Hoverfly from captured traffic",
+		},
+		"servers": serverList,
+		"paths":   paths,
+	}
+}
+
+// ExportOpenAPI - returns OpenAPI 3 document describing records, only records of given destination are described
+// when it's set
+func (d *DBClient) ExportOpenAPI(destination string) (map[string]interface{}, error) {
+	payloads, err := d.Cache.GetAllRequests()
+	if err != nil {
+		return nil, err
+	}
+
+	title := "Captured API"
+	if destination != "" {
+		title = destination
+		selected := payloads[:0]
+		for _, pl := range payloads {
+			if pl.Request.Destination == destination {
+				selected = append(selected, pl)
+			}
+		}
+		payloads = selected
+	}
+	return openAPIDocumentOf(payloads, title), nil
+}
@@ -0,0 +1,110 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTemplatePath(t *testing.T) {
+	path, params := templatePath("/users/42/orders/7f3c1e2a-4b5d-4c6e-8f90-a1b2c3d4e5f6")
+	expect(t, path, "/users/{userId}/orders/{orderId}")
+	expect(t, len(params), 2)
+
+	path, params = templatePath("/42/items")
+	expect(t, path, "/{id}/items")
+	expect(t, params[0], "id")
+
+	path, params = templatePath("/v1/status")
+	expect(t, path, "/v1/status")
+	expect(t, len(params), 0)
+}
+
+func TestInferAndMergeSchemas(t *testing.T) {
+	var first, second interface{}
+	json.Unmarshal([]byte(`{"id": 1, "name": "a", "tags": ["x"], "created": "2016-01-02T15:04:05Z"}`), &first)
+	json.Unmarshal([]byte(`{"id": 1.5, "name": null, "tags": []}`), &second)
+
+	schema := mergeSchemas(inferSchema(first), inferSchema(second))
+	expect(t, schema["type"], "object")
+	properties := object(schema["properties"])
+	expect(t, object(properties["id"])["type"], "number")
+	expect(t, object(properties["name"])["type"], "string")
+	expect(t, object(properties["name"])["nullable"], true)
+	expect(t, object(object(properties["tags"])["items"])["type"], "string")
+	expect(t, object(properties["created"])["format"], "date-time")
+
+	// created is missing in the second body
+	required := toStrings(schema["required"])
+	expect(t, len(required), 3)
+	for _, name := range required {
+		refute(t, name, "created")
+	}
+
+	var mixed interface{}
+	json.Unmarshal([]byte(`[1, "a"]`), &mixed)
+	expect(t, len(object(inferSchema(mixed))["items"].(map[string]interface{})), 0)
+}
+
+func TestExportOpenAPI(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	dbClient.ImportPayloads([]Payload{
+		{
+			Request: RequestDetails{Method: "GET", Scheme: "https", Destination: "api.example.com", Path: "/users/1",
+				Query: "fields=name"},
+			Response: ResponseDetails{Status: 200, Body: `{"id": 1, "name": "John"}`,
+				Headers: map[string][]string{"Content-Type": {"application/json; charset=utf-8"}}},
+		},
+		{
+			Request:  RequestDetails{Method: "GET", Scheme: "https", Destination: "api.example.com", Path: "/users/2"},
+			Response: ResponseDetails{Status: 404, Body: "not found"},
+		},
+		{
+			Request: RequestDetails{Method: "POST", Scheme: "https", Destination: "api.example.com", Path: "/users",
+				Body: `{"name": "Jane"}`, Headers: map[string][]string{"Content-Type": {"application/json"}}},
+			Response: ResponseDetails{Status: 201},
+		},
+		{
+			Request:  RequestDetails{Method: "GET", Destination: "other.example.com", Path: "/status"},
+			Response: ResponseDetails{Status: 200},
+		},
+	})
+
+	doc, err := dbClient.ExportOpenAPI("api.example.com")
+	expect(t, err, nil)
+	expect(t, doc["openapi"], "3.0.0")
+	servers := doc["servers"].([]interface{})
+	expect(t, len(servers), 1)
+	expect(t, object(servers[0])["url"], "https://api.example.com")
+
+	paths := object(doc["paths"])
+	expect(t, len(paths), 2)
+
+	get := object(object(paths["/users/{userId}"])["get"])
+	parameters := get["parameters"].([]interface{})
+	expect(t, len(parameters), 2)
+	expect(t, object(parameters[0])["in"], "path")
+	expect(t, object(object(parameters[0])["schema"])["type"], "integer")
+	expect(t, object(parameters[1])["name"], "fields")
+	// query parameter wasn't sent with every request
+	expect(t, object(parameters[1])["required"], false)
+
+	responses := object(get["responses"])
+	expect(t, len(responses), 2)
+	ok := object(object(object(responses["200"])["content"])["application/json"])
+	expect(t, object(object(object(ok["schema"])["properties"])["name"])["type"], "string")
+	notFound := object(object(object(responses["404"])["content"])["application/octet-stream"])
+	expect(t, notFound["example"], "not found")
+
+	post := object(object(paths["/users"])["post"])
+	requestBody := object(object(post["requestBody"])["content"])
+	expect(t, object(object(requestBody["application/json"])["schema"])["type"], "object")
+	expect(t, object(object(post["responses"])["201"])["description"], "Created")
+
+	doc, err = dbClient.ExportOpenAPI("")
+	expect(t, err, nil)
+	expect(t, len(doc["servers"].([]interface{})), 2)
+	expect(t, len(object(doc["paths"])), 3)
+}
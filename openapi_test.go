@@ -0,0 +1,95 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIExampleValue(t *testing.T) {
+	var doc openAPIDocument
+	err := json.Unmarshal([]byte(`{"openapi": "3.0.0", "components": {"schemas": {
+		"User": {"type": "object", "properties": {
+			"id": {"type": "string", "format": "uuid"},
+			"age": {"type": "integer", "minimum": 18},
+			"active": {"type": "boolean", "default": false},
+			"friends": {"type": "array", "items": {"$ref": "#/components/schemas/User"}}
+		}}
+	}}}`), &doc)
+	expect(t, err, nil)
+
+	example, _ := json.Marshal(doc.exampleValue(map[string]interface{}{"$ref": "#/components/schemas/User"}, 0))
+	// recursive schema stops at maximum depth
+	expect(t, strings.HasPrefix(string(example), `{"active":false,"age":18,"friends":[{"active":false,"age":18,"friends":[`), true)
+	expect(t, strings.Contains(string(example), `"id":"00000000-0000-0000-0000-000000000000"`), true)
+	expect(t, strings.Contains(string(example), `null`), true)
+}
+
+func TestImportOpenAPIDocument(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	expect(t, dbClient.Import("examples/exports/petstore.openapi.json"), nil)
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 5)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	call := func(method, url, body string) (*http.Response, string) {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		_, resp := dbClient.processRequest(req)
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(respBody)
+	}
+
+	resp, body := call("GET", "https://petstore.example.com/v1/pets?limit=5", "")
+	expect(t, resp.StatusCode, 200)
+	expect(t, resp.Header.Get("Content-Type"), "application/json")
+	var pets []map[string]interface{}
+	expect(t, json.Unmarshal([]byte(body), &pets), nil)
+	expect(t, len(pets), 1)
+	expect(t, pets[0]["tag"], "dog")
+	expect(t, pets[0]["born"], "2016-01-01")
+	expect(t, pets[0]["owner"].(map[string]interface{})["email"], "user@example.com")
+
+	resp, body = call("POST", "https://petstore.example.com/v1/pets", `{"name": "rex"}`)
+	expect(t, resp.StatusCode, 201)
+	expect(t, body, `{"id":10,"name":"rex","tag":"dog"}`)
+
+	resp, body = call("GET", "https://petstore.example.com/v1/pets/42", "")
+	expect(t, resp.StatusCode, 200)
+	expect(t, body, `{"id":1,"name":"tom","tag":"cat"}`)
+
+	// literal path wins over templated one
+	resp, body = call("GET", "https://petstore.example.com/v1/pets/mine", "")
+	expect(t, resp.StatusCode, 200)
+	expect(t, body, "no pets")
+
+	resp, _ = call("DELETE", "https://petstore.example.com/v1/pets/42", "")
+	expect(t, resp.StatusCode, 204)
+
+	resp, _ = call("PUT", "https://petstore.example.com/v1/pets/42", "")
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+}
+
+func TestImportSwaggerDocument(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	swagger := `{"swagger": "2.0", "basePath": "/api", "produces": ["application/json"],
+		"paths": {"/orders/{id}": {"get": {"responses": {"200": {"schema": {"$ref": "#/definitions/Order"}}}}}},
+		"definitions": {"Order": {"properties": {"total": {"type": "number"}, "paid": {"type": "boolean"}}}}}`
+	expect(t, dbClient.ImportOpenAPI(strings.NewReader(swagger), "shop.example.com"), nil)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://shop.example.com/api/orders/7", nil)
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, resp.StatusCode, 200)
+	expect(t, string(body), `{"paid":true,"total":0}`)
+
+	refute(t, dbClient.ImportOpenAPI(strings.NewReader(`{"data": []}`), ""), nil)
+}
@@ -0,0 +1,261 @@
+package hoverfly
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// hasBodyMatchers - checks whether record matches request bodies with expressions instead of comparing them
+func (p *Payload) hasBodyMatchers() bool {
+	return len(p.JSONPathMatchers) > 0 || len(p.XPathMatchers) > 0
+}
+
+// bodyMatchers - parsed body matcher expressions of a record
+type bodyMatchers struct {
+	jsonPath []*JSONPathMatcher
+	xpath    []*XPathMatcher
+}
+
+// compileBodyMatchers - parses all body matcher expressions of the record
+func (p *Payload) compileBodyMatchers() (bodyMatchers, error) {
+	var compiled bodyMatchers
+	for _, expression := range p.JSONPathMatchers {
+		matcher, err := ParseJSONPathMatcher(expression)
+		if err != nil {
+			return bodyMatchers{}, err
+		}
+		compiled.jsonPath = append(compiled.jsonPath, matcher)
+	}
+	for _, expression := range p.XPathMatchers {
+		matcher, err := ParseXPathMatcher(expression)
+		if err != nil {
+			return bodyMatchers{}, err
+		}
+		compiled.xpath = append(compiled.xpath, matcher)
+	}
+	return compiled, nil
+}
+
+// validateBodyMatchers - checks that all body matcher expressions can be parsed
+func (p *Payload) validateBodyMatchers() error {
+	_, err := p.compileBodyMatchers()
+	return err
+}
+
+// matchesBody - checks whether request body satisfies all body matchers of the record
+func (p *Payload) matchesBody(body []byte) bool {
+	compiled, err := p.compileBodyMatchers()
+	return err == nil && compiled.matches(body)
+}
+
+// matches - checks whether request body satisfies all expressions
+func (b bodyMatchers) matches(body []byte) bool {
+	if len(b.jsonPath) > 0 {
+		var document interface{}
+		if err := json.Unmarshal(body, &document); err != nil {
+			return false
+		}
+		for _, matcher := range b.jsonPath {
+			if !matcher.Matches(document) {
+				return false
+			}
+		}
+	}
+
+	if len(b.xpath) > 0 {
+		root, err := parseXMLDocument(body)
+		if err != nil {
+			return false
+		}
+		for _, matcher := range b.xpath {
+			if !matcher.Matches(root) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// hasMatchers - checks whether record is matched with patterns or expressions, it can't be found by fingerprint
+func (p *Payload) hasMatchers() bool {
+	return p.hasBodyMatchers() || p.Matchers != nil || len(p.CustomMatchers) > 0
+}
+
+// validateMatchers - checks that all request patterns and body expressions are valid
+func (p *Payload) validateMatchers() error {
+	if p.Matchers != nil {
+		if err := p.Matchers.validate(); err != nil {
+			return err
+		}
+	}
+	return p.validateBodyMatchers()
+}
+
+// matchersCount - number of patterns and expressions, records with more of them are more specific
+func (p *Payload) matchersCount() int {
+	count := len(p.JSONPathMatchers) + len(p.XPathMatchers) + len(p.CustomMatchers)
+	if p.Matchers != nil {
+		count += p.Matchers.count()
+	}
+	return count
+}
+
+// recordKey - returns key payload is stored under, records with matchers are keyed by them as well, so records that
+// differ only in matchers don't replace each other
+func (d *DBClient) recordKey(pl Payload) string {
+	request := pl.Request
+	if pl.hasBodyMatchers() {
+		request.Body = ""
+		if len(pl.JSONPathMatchers) > 0 {
+			request.Body = "jsonpath:" + strings.Join(pl.JSONPathMatchers, "\n")
+		}
+		if len(pl.XPathMatchers) > 0 {
+			request.Body += "xpath:" + strings.Join(pl.XPathMatchers, "\n")
+		}
+	}
+	if pl.Matchers != nil {
+		// map keys are sorted, so the same matchers always give the same key
+		matchers, _ := json.Marshal(pl.Matchers)
+		request.Body = "matchers:" + string(matchers) + request.Body
+	}
+	if len(pl.CustomMatchers) > 0 {
+		custom, _ := json.Marshal(pl.CustomMatchers)
+		request.Body = "custom:" + string(custom) + request.Body
+	}
+	return d.payloadKey(request)
+}
+
+// matchesRequest - checks whether record with matchers matches request, fields without matchers are compared
+// the same way fingerprints compare them
+func (d *DBClient) matchesRequest(entry matcherEntry, live RequestDetails, fingerprint, withoutBody string) bool {
+	pl := entry.payload
+	request := pl.Request
+	if pl.Matchers != nil {
+		if !d.requestMatchers(pl).matches(live.Path, live.Query, live.Headers) {
+			return false
+		}
+		if pl.Matchers.Path != nil {
+			request.Path = live.Path
+		}
+		if pl.Matchers.replacesQuery() {
+			request.Query = live.Query
+		}
+		if pl.Matchers.replacesBody() && !pl.Matchers.Body.matchesAny([]string{live.Body}) {
+			return false
+		}
+	}
+
+	if pl.hasBodyMatchers() || len(pl.CustomMatchers) > 0 || pl.Matchers.replacesBody() {
+		// custom matchers usually look at the body, so it isn't compared either
+		request.Body = ""
+		return d.payloadKey(request) == withoutBody && entry.body.matches([]byte(live.Body)) &&
+			len(d.failedCustomMatchers(pl, live)) == 0
+	}
+	return d.payloadKey(request) == fingerprint
+}
+
+// specificity - number of request fields record constrains, patterns and expressions count as well as exact
+// path, query and body
+func (p *Payload) specificity() int {
+	specificity := p.matchersCount()
+	if (p.Matchers == nil || p.Matchers.Path == nil) && p.Request.Path != "" {
+		specificity++
+	}
+	if !p.Matchers.replacesQuery() && p.Request.Query != "" {
+		specificity++
+	}
+	if !p.hasBodyMatchers() && len(p.CustomMatchers) == 0 && !p.Matchers.replacesBody() && p.Request.Body != "" {
+		specificity++
+	}
+	return specificity
+}
+
+// preferredTo - checks whether record should be used instead of other matching one, higher priority wins, then
+// higher specificity and then lower ID, so the choice is always the same
+func (p *Payload) preferredTo(other *Payload) bool {
+	if p.Priority != other.Priority {
+		return p.Priority > other.Priority
+	}
+	if p.specificity() != other.specificity() {
+		return p.specificity() > other.specificity()
+	}
+	return p.ID < other.ID
+}
+
+// matchedByKey - context key of description how request was matched to its record
+type matchedByKey struct{}
+
+// matchedBy - returns description of how record was matched, shown in Hoverfly-Match header
+func matchedBy(req *http.Request, key string) string {
+	if description, ok := req.Context().Value(matchedByKey{}).(string); ok {
+		return description
+	}
+	return fmt.Sprintf("record=%s; fingerprint", key)
+}
+
+// matcherRecord - looks for record with matchers that match request, i.e. path glob or JSONPath expressions.
+// When more records match, priority and specificity decide. Records with matchers are scanned (from the record
+// index), so this is only done for requests that weren't found by their fingerprint. Returned request remembers how
+// it was matched
+func (d *DBClient) matcherRecord(req *http.Request, body []byte) (*http.Request, string, []byte, bool) {
+	live := RequestDetails{
+		Path:        req.URL.Path,
+		Method:      req.Method,
+		Destination: req.Host,
+		Query:       req.URL.RawQuery,
+		Body:        string(body),
+		Headers:     req.Header,
+	}
+	// header matching, ignored query parameters and body matching mode still apply
+	fingerprint := d.requestFingerprint(req, body)
+	withoutBody := d.requestFingerprint(req, nil)
+
+	var found *Payload
+	var payloadBts []byte
+	// index can still hold a record that was evicted or expired since, it's rebuilt and searched once more then
+	for attempt := 0; attempt < 2; attempt++ {
+		entries, err := d.matcherRecords()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Failed to look for records with matchers")
+			return req, "", nil, false
+		}
+
+		found = nil
+		for i := range entries {
+			if !d.matchesRequest(entries[i], live, fingerprint, withoutBody) {
+				continue
+			}
+			if found == nil || entries[i].payload.preferredTo(found) {
+				found = &entries[i].payload
+			}
+		}
+		if found == nil {
+			return req, "", nil, false
+		}
+
+		if payloadBts, err = d.Cache.Get([]byte(found.ID)); err == nil {
+			break
+		}
+		d.recordsChanged()
+	}
+	if payloadBts == nil {
+		return req, "", nil, false
+	}
+
+	description := fmt.Sprintf("record=%s; matchers; priority=%d; specificity=%d", found.ID, found.Priority,
+		found.specificity())
+	log.WithFields(log.Fields{
+		"key":         found.ID,
+		"path":        req.URL.Path,
+		"destination": req.Host,
+		"match":       description,
+	}).Debug("Request matched by record matchers")
+	return req.WithContext(context.WithValue(req.Context(), matchedByKey{}, description)), found.ID, payloadBts, true
+}
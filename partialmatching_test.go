@@ -0,0 +1,138 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// importShippingRecords - imports records answering shipping quotes by order type
+func importShippingRecords(dbClient *DBClient) error {
+	request := RequestDetails{Method: "POST", Destination: "api.example.com", Path: "/shipping"}
+	return dbClient.ImportPayloads([]Payload{
+		{
+			Request:          request,
+			Response:         ResponseDetails{Status: 200, Body: "express"},
+			JSONPathMatchers: []string{`$.order.type == "express"`},
+		},
+		{
+			Request:          request,
+			Response:         ResponseDetails{Status: 200, Body: "express to London"},
+			JSONPathMatchers: []string{`$.order.type == "express"`, `$..city == "London"`},
+		},
+		{
+			Request:          request,
+			Response:         ResponseDetails{Status: 200, Body: "standard"},
+			JSONPathMatchers: []string{`$.order.type == "standard"`},
+		},
+	})
+}
+
+func shippingQuote(dbClient *DBClient, body string) (int, string) {
+	req, _ := http.NewRequest("POST", "http://api.example.com/shipping", strings.NewReader(body))
+	_, resp := dbClient.processRequest(req)
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(respBody)
+}
+
+func TestJSONPathMatchersSelectRecord(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	expect(t, importShippingRecords(dbClient), nil)
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 3)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	status, body := shippingQuote(dbClient, `{"order": {"type": "standard", "id": 1}}`)
+	expect(t, status, 200)
+	expect(t, body, "standard")
+
+	status, body = shippingQuote(dbClient, `{"order": {"type": "express", "id": 2}, "address": {"city": "Leeds"}}`)
+	expect(t, status, 200)
+	expect(t, body, "express")
+
+	// the most specific record wins
+	status, body = shippingQuote(dbClient, `{"order": {"type": "express", "id": 3}, "address": {"city": "London"}}`)
+	expect(t, status, 200)
+	expect(t, body, "express to London")
+
+	status, _ = shippingQuote(dbClient, `{"order": {"type": "overnight"}}`)
+	expect(t, status, dbClient.Cfg.GetMissStatus())
+
+	status, _ = shippingQuote(dbClient, `not json`)
+	expect(t, status, dbClient.Cfg.GetMissStatus())
+}
+
+func TestJSONPathMatchersRestOfRequestMatches(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	expect(t, importShippingRecords(dbClient), nil)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("POST", "http://api.example.com/returns", strings.NewReader(`{"order": {"type": "standard"}}`))
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+
+	req, _ = http.NewRequest("PUT", "http://api.example.com/shipping", strings.NewReader(`{"order": {"type": "standard"}}`))
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+}
+
+func TestImportSkipsInvalidJSONPathMatchers(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.ImportPayloads([]Payload{
+		{
+			Request:          RequestDetails{Method: "POST", Destination: "api.example.com", Path: "/shipping"},
+			Response:         ResponseDetails{Status: 200},
+			JSONPathMatchers: []string{`order.type == "express"`},
+		},
+	})
+	expect(t, err, nil)
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 0)
+}
+
+func TestXPathMatchersSelectRecord(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	request := RequestDetails{Method: "POST", Destination: "soap.example.com", Path: "/stock"}
+	err := dbClient.ImportPayloads([]Payload{
+		{
+			Request:       request,
+			Response:      ResponseDetails{Status: 200, Body: "<price>12.5</price>"},
+			XPathMatchers: []string{`//GetQuote/symbol == "ACME"`},
+		},
+		{
+			Request:       request,
+			Response:      ResponseDetails{Status: 200, Body: "<price>99</price>"},
+			XPathMatchers: []string{`//GetQuote/symbol == "INITECH"`},
+		},
+	})
+	expect(t, err, nil)
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 2)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	envelope := `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>
+		<GetQuote xmlns="http://example.com/stock"><symbol>INITECH</symbol></GetQuote>
+	</s:Body></s:Envelope>`
+	req, _ := http.NewRequest("POST", "http://soap.example.com/stock", strings.NewReader(envelope))
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, resp.StatusCode, 200)
+	expect(t, string(body), "<price>99</price>")
+
+	req, _ = http.NewRequest("POST", "http://soap.example.com/stock", strings.NewReader(`{"symbol": "ACME"}`))
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+}
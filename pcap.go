@@ -0,0 +1,562 @@
+package hoverfly
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Link types of captured packets, see http://www.tcpdump.org/linktypes.html
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+)
+
+// File and block markers of pcap and pcapng formats
+const (
+	pcapMagicMicros      = 0xa1b2c3d4
+	pcapMagicNanos       = 0xa1b23c4d
+	pcapngSectionHeader  = 0x0a0d0d0a
+	pcapngByteOrderMagic = 0x1a2b3c4d
+	pcapngInterface      = 1
+	pcapngSimplePacket   = 3
+	pcapngEnhancedPacket = 6
+)
+
+// maxPcapBlock - biggest packet or block accepted, bigger ones mean the file is corrupted
+const maxPcapBlock = 16 << 20
+
+// TCP flags
+const (
+	tcpSYN = 0x02
+	tcpACK = 0x10
+)
+
+// capturedPacket - link layer frame read from capture file
+type capturedPacket struct {
+	linkType uint32
+	data     []byte
+}
+
+// tcpSegment - TCP segment of captured packet, endpoints are "ip:port"
+type tcpSegment struct {
+	src, dst string
+	seq      uint32
+	flags    byte
+	payload  []byte
+}
+
+// tcpFlow - segments sent in one direction of TCP connection
+type tcpFlow struct {
+	// isn - sequence number of the first data byte, known when SYN was captured
+	isn      uint32
+	hasISN   bool
+	segments []tcpSegment
+}
+
+// tcpConversation - both directions of TCP connection
+type tcpConversation struct {
+	// client - endpoint that opened the connection, empty when SYN wasn't captured
+	client string
+	flows  map[string]*tcpFlow
+}
+
+// readCapture - reads packets of pcap or pcapng file, packets of capture cut short (i.e. tcpdump was killed) are
+// returned up to the broken one
+func readCapture(r io.Reader) ([]capturedPacket, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("file is too short")
+	}
+	if binary.BigEndian.Uint32(magic) == pcapngSectionHeader {
+		return readPcapng(br)
+	}
+	return readPcap(br)
+}
+
+// readPcap - reads packets of libpcap file, both byte orders and both timestamp precisions are accepted
+func readPcap(r io.Reader) ([]capturedPacket, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("file is too short")
+	}
+
+	var order binary.ByteOrder
+	switch {
+	case isPcapMagic(binary.LittleEndian.Uint32(header)):
+		order = binary.LittleEndian
+	case isPcapMagic(binary.BigEndian.Uint32(header)):
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("file is neither pcap nor pcapng")
+	}
+	linkType := order.Uint32(header[20:24]) & 0xffff
+
+	var packets []capturedPacket
+	record := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, record); err != nil {
+			if err != io.EOF {
+				log.Warn("Capture file is cut short, last packet is skipped")
+			}
+			return packets, nil
+		}
+		length := order.Uint32(record[8:12])
+		if length > maxPcapBlock {
+			return nil, fmt.Errorf("packet of %d bytes is too big, file is corrupted", length)
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			log.Warn("Capture file is cut short, last packet is skipped")
+			return packets, nil
+		}
+		packets = append(packets, capturedPacket{linkType: linkType, data: data})
+	}
+}
+
+// isPcapMagic - checks whether value is magic number of pcap file
+func isPcapMagic(magic uint32) bool {
+	return magic == pcapMagicMicros || magic == pcapMagicNanos
+}
+
+// readPcapng - reads packets of pcapng file (Wireshark's default format), packets of enhanced and simple packet
+// blocks are read, other blocks are skipped
+func readPcapng(r io.Reader) ([]capturedPacket, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	var linkTypes []uint32
+	var packets []capturedPacket
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err != io.EOF {
+				log.Warn("Capture file is cut short, last block is skipped")
+			}
+			return packets, nil
+		}
+
+		blockType := binary.BigEndian.Uint32(header[0:4])
+		if blockType == pcapngSectionHeader {
+			// byte order of the section is given by the magic that follows block length
+			bom := make([]byte, 4)
+			if _, err := io.ReadFull(r, bom); err != nil {
+				return nil, fmt.Errorf("section header is cut short")
+			}
+			switch {
+			case binary.LittleEndian.Uint32(bom) == pcapngByteOrderMagic:
+				order = binary.LittleEndian
+			case binary.BigEndian.Uint32(bom) == pcapngByteOrderMagic:
+				order = binary.BigEndian
+			default:
+				return nil, fmt.Errorf("section header has invalid byte order magic")
+			}
+			length := order.Uint32(header[4:8])
+			if length < 16 || length > maxPcapBlock {
+				return nil, fmt.Errorf("section header of %d bytes is invalid", length)
+			}
+			if _, err := io.CopyN(ioutil.Discard, r, int64(length-12)); err != nil {
+				return nil, fmt.Errorf("section header is cut short")
+			}
+			// interfaces are numbered per section
+			linkTypes = nil
+			continue
+		}
+
+		blockType = order.Uint32(header[0:4])
+		length := order.Uint32(header[4:8])
+		if length < 12 || length%4 != 0 || length > maxPcapBlock {
+			return nil, fmt.Errorf("block of %d bytes is invalid, file is corrupted", length)
+		}
+		block := make([]byte, length-8)
+		if _, err := io.ReadFull(r, block); err != nil {
+			log.Warn("Capture file is cut short, last block is skipped")
+			return packets, nil
+		}
+		// trailing copy of block length isn't part of the body
+		body := block[:len(block)-4]
+
+		switch blockType {
+		case pcapngInterface:
+			if len(body) < 2 {
+				return nil, fmt.Errorf("interface description block is too short")
+			}
+			linkTypes = append(linkTypes, uint32(order.Uint16(body[0:2])))
+		case pcapngEnhancedPacket:
+			if len(body) < 20 {
+				return nil, fmt.Errorf("enhanced packet block is too short")
+			}
+			iface := order.Uint32(body[0:4])
+			captured := order.Uint32(body[12:16])
+			if int(iface) >= len(linkTypes) || int(captured) > len(body)-20 {
+				return nil, fmt.Errorf("enhanced packet block refers to unknown interface or is too short")
+			}
+			packets = append(packets, capturedPacket{linkType: linkTypes[iface], data: body[20 : 20+captured]})
+		case pcapngSimplePacket:
+			if len(body) < 4 || len(linkTypes) == 0 {
+				return nil, fmt.Errorf("simple packet block is too short or there is no interface")
+			}
+			data := body[4:]
+			// block is padded, original length says how much of it is the packet
+			if original := order.Uint32(body[0:4]); int(original) < len(data) {
+				data = data[:original]
+			}
+			packets = append(packets, capturedPacket{linkType: linkTypes[0], data: data})
+		}
+	}
+}
+
+// decodeTCP - returns TCP segment of IPv4 or IPv6 packet, false for other packets. Fragmented IPv4 packets aren't
+// reassembled, HTTP traffic rarely has them
+func decodeTCP(packet capturedPacket) (tcpSegment, bool) {
+	data := packet.data
+	var etherType uint16
+	switch packet.linkType {
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return tcpSegment{}, false
+		}
+		etherType, data = binary.BigEndian.Uint16(data[12:14]), data[14:]
+		// 802.1Q VLAN tags
+		for etherType == 0x8100 && len(data) >= 4 {
+			etherType, data = binary.BigEndian.Uint16(data[2:4]), data[4:]
+		}
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return tcpSegment{}, false
+		}
+		etherType, data = binary.BigEndian.Uint16(data[14:16]), data[16:]
+	case linkTypeNull:
+		if len(data) < 4 {
+			return tcpSegment{}, false
+		}
+		// address family in byte order of the capturing host
+		family := binary.LittleEndian.Uint32(data[0:4])
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(data[0:4])
+		}
+		etherType, data = 0x0800, data[4:]
+		if family != 2 {
+			etherType = 0x86dd
+		}
+	case linkTypeRaw:
+		if len(data) < 1 {
+			return tcpSegment{}, false
+		}
+		etherType = 0x0800
+		if data[0]>>4 == 6 {
+			etherType = 0x86dd
+		}
+	default:
+		return tcpSegment{}, false
+	}
+
+	var src, dst net.IP
+	switch etherType {
+	case 0x0800:
+		if len(data) < 20 || data[0]>>4 != 4 || data[9] != 6 {
+			return tcpSegment{}, false
+		}
+		// more fragments flag or fragment offset
+		if binary.BigEndian.Uint16(data[6:8])&0x3fff != 0 {
+			return tcpSegment{}, false
+		}
+		headerLen := int(data[0]&0x0f) * 4
+		// Ethernet frames can be padded past the end of IP packet
+		total := int(binary.BigEndian.Uint16(data[2:4]))
+		if headerLen < 20 || total < headerLen || total > len(data) {
+			return tcpSegment{}, false
+		}
+		src, dst, data = net.IP(data[12:16]), net.IP(data[16:20]), data[headerLen:total]
+	case 0x86dd:
+		// extension headers aren't followed
+		if len(data) < 40 || data[6] != 6 {
+			return tcpSegment{}, false
+		}
+		end := 40 + int(binary.BigEndian.Uint16(data[4:6]))
+		if end > len(data) {
+			return tcpSegment{}, false
+		}
+		src, dst, data = net.IP(data[8:24]), net.IP(data[24:40]), data[40:end]
+	default:
+		return tcpSegment{}, false
+	}
+
+	if len(data) < 20 {
+		return tcpSegment{}, false
+	}
+	offset := int(data[12]>>4) * 4
+	if offset < 20 || offset > len(data) {
+		return tcpSegment{}, false
+	}
+	return tcpSegment{
+		src:     net.JoinHostPort(src.String(), strconv.Itoa(int(binary.BigEndian.Uint16(data[0:2])))),
+		dst:     net.JoinHostPort(dst.String(), strconv.Itoa(int(binary.BigEndian.Uint16(data[2:4])))),
+		seq:     binary.BigEndian.Uint32(data[4:8]),
+		flags:   data[13],
+		payload: data[offset:],
+	}, true
+}
+
+// conversationKey - key of TCP connection, the same for both directions
+func conversationKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + " " + b
+}
+
+// tcpConversations - groups TCP segments of packets into connections in the order they were opened, new SYN on the
+// same ports starts new connection
+func tcpConversations(packets []capturedPacket) []*tcpConversation {
+	var conversations []*tcpConversation
+	active := make(map[string]*tcpConversation)
+	for _, packet := range packets {
+		segment, ok := decodeTCP(packet)
+		if !ok {
+			continue
+		}
+
+		key := conversationKey(segment.src, segment.dst)
+		opening := segment.flags&tcpSYN != 0 && segment.flags&tcpACK == 0
+		conversation := active[key]
+		if conversation == nil || (opening && conversation.hasData()) {
+			conversation = &tcpConversation{flows: make(map[string]*tcpFlow)}
+			conversations = append(conversations, conversation)
+			active[key] = conversation
+		}
+		if opening {
+			conversation.client = segment.src
+		}
+
+		flow := conversation.flows[segment.src]
+		if flow == nil {
+			flow = &tcpFlow{}
+			conversation.flows[segment.src] = flow
+		}
+		if segment.flags&tcpSYN != 0 {
+			flow.isn, flow.hasISN = segment.seq+1, true
+		}
+		if len(segment.payload) > 0 {
+			flow.segments = append(flow.segments, segment)
+		}
+	}
+	return conversations
+}
+
+// hasData - checks whether any data was sent through the connection
+func (c *tcpConversation) hasData() bool {
+	for _, flow := range c.flows {
+		if len(flow.segments) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// bySequence - sorts segments by their position in the stream, sequence numbers can wrap around
+type bySequence struct {
+	base     uint32
+	segments []tcpSegment
+}
+
+func (s bySequence) Len() int      { return len(s.segments) }
+func (s bySequence) Swap(i, j int) { s.segments[i], s.segments[j] = s.segments[j], s.segments[i] }
+func (s bySequence) Less(i, j int) bool {
+	return int32(s.segments[i].seq-s.base) < int32(s.segments[j].seq-s.base)
+}
+
+// stream - returns data sent in flow direction, segments are ordered by sequence number and retransmitted data is
+// left out. Data after a gap (segment that wasn't captured) is dropped
+func (f *tcpFlow) stream() []byte {
+	if len(f.segments) == 0 {
+		return nil
+	}
+	base := f.isn
+	if !f.hasISN {
+		// without SYN the stream starts at the lowest sequence number
+		base = f.segments[0].seq
+		for _, segment := range f.segments {
+			if int32(segment.seq-base) < 0 {
+				base = segment.seq
+			}
+		}
+	}
+
+	segments := bySequence{base: base, segments: make([]tcpSegment, len(f.segments))}
+	copy(segments.segments, f.segments)
+	sort.Stable(segments)
+
+	var buf bytes.Buffer
+	next := int64(0)
+	for _, segment := range segments.segments {
+		offset := int64(int32(segment.seq - base))
+		end := offset + int64(len(segment.payload))
+		if end <= next {
+			continue
+		}
+		if offset > next {
+			log.WithFields(log.Fields{
+				"source":      segment.src,
+				"destination": segment.dst,
+				"missing":     offset - next,
+			}).Warn("TCP stream has a gap, data after it is skipped")
+			break
+		}
+		if offset < 0 {
+			offset = 0
+		}
+		buf.Write(segment.payload[next-offset:])
+		next = end
+	}
+	return buf.Bytes()
+}
+
+// isHTTPRequestStart - checks whether data starts with HTTP/1.x request line
+func isHTTPRequestStart(data []byte) bool {
+	line := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line = data[:i]
+	}
+	space := bytes.IndexByte(line, ' ')
+	if space <= 0 {
+		return false
+	}
+	for _, c := range line[:space] {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return bytes.Contains(line, []byte(" HTTP/1."))
+}
+
+// httpPayloads - parses HTTP/1.x requests and responses exchanged through the connection, connections that don't
+// carry plain HTTP (i.e. TLS) give no payloads
+func (c *tcpConversation) httpPayloads() []Payload {
+	var client, server string
+	for endpoint, flow := range c.flows {
+		if endpoint == c.client || (c.client == "" && len(flow.segments) > 0 && isHTTPRequestStart(flow.stream())) {
+			client = endpoint
+		}
+	}
+	for endpoint := range c.flows {
+		if endpoint != client {
+			server = endpoint
+		}
+	}
+	if client == "" || server == "" {
+		return nil
+	}
+
+	requestData := c.flows[client].stream()
+	if !isHTTPRequestStart(requestData) {
+		return nil
+	}
+	requests := bufio.NewReader(bytes.NewReader(requestData))
+	responses := bufio.NewReader(bytes.NewReader(c.flows[server].stream()))
+
+	var payloads []Payload
+	for {
+		req, err := http.ReadRequest(requests)
+		if err != nil {
+			return payloads
+		}
+		requestBody, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return payloads
+		}
+
+		resp, err := http.ReadResponse(responses, req)
+		// interim responses precede the final one
+		for err == nil && resp.StatusCode == http.StatusContinue {
+			resp, err = http.ReadResponse(responses, req)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"method":      req.Method,
+				"path":        req.URL.Path,
+				"destination": req.Host,
+			}).Warn("Captured request has no response, skipping it")
+			return payloads
+		}
+		responseBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return payloads
+		}
+
+		destination := req.Host
+		if destination == "" {
+			destination = server
+		}
+		payloads = append(payloads, Payload{
+			Request: RequestDetails{
+				Path:        req.URL.Path,
+				Method:      req.Method,
+				Destination: destination,
+				Scheme:      "http",
+				Query:       req.URL.RawQuery,
+				Body:        string(requestBody),
+				RemoteAddr:  client,
+				Headers:     req.Header,
+				Proto:       req.Proto,
+			},
+			Response: ResponseDetails{
+				Status:  resp.StatusCode,
+				Body:    string(responseBody),
+				Headers: resp.Header,
+				Proto:   resp.Proto,
+			},
+		})
+
+		// protocol changes after upgrade, i.e. to websocket
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			return payloads
+		}
+	}
+}
+
+// capturePayloads - reassembles HTTP exchanges of captured packets
+func capturePayloads(packets []capturedPacket) []Payload {
+	var payloads []Payload
+	for _, conversation := range tcpConversations(packets) {
+		payloads = append(payloads, conversation.httpPayloads()...)
+	}
+	return payloads
+}
+
+// ImportPcap - reads pcap or pcapng capture (i.e. from tcpdump or Wireshark), reassembles HTTP/1.x exchanges of its
+// TCP connections and imports them as records. Encrypted (HTTPS) and HTTP/2 traffic can't be read and is skipped
+func (d *DBClient) ImportPcap(r io.Reader) error {
+	packets, err := readCapture(r)
+	if err != nil {
+		return fmt.Errorf("Got error while parsing capture file, error %s", err.Error())
+	}
+	payloads := capturePayloads(packets)
+	log.WithFields(log.Fields{
+		"packets":  len(packets),
+		"requests": len(payloads),
+	}).Info("Reassembled HTTP requests of captured traffic")
+	return d.ImportPayloads(payloads)
+}
+
+// ImportPcapFromDisk - imports HTTP exchanges of capture file
+func (d *DBClient) ImportPcapFromDisk(path string) error {
+	captureFile, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Got error while opening capture file, error %s", err.Error())
+	}
+	defer captureFile.Close()
+	return d.ImportPcap(captureFile)
+}
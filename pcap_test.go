@@ -0,0 +1,245 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// testTCPPacket - Ethernet frame with IPv4 TCP segment, endpoints are "ip:port"
+func testTCPPacket(src, dst string, seq uint32, flags byte, payload string) []byte {
+	srcHost, srcPort, _ := net.SplitHostPort(src)
+	dstHost, dstPort, _ := net.SplitHostPort(dst)
+	port := func(p string) uint16 {
+		n, _ := strconv.Atoi(p)
+		return uint16(n)
+	}
+
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:2], port(srcPort))
+	binary.BigEndian.PutUint16(tcp[2:4], port(dstPort))
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	tcp = append(tcp, payload...)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+	ip[8] = 64
+	ip[9] = 6
+	copy(ip[12:16], net.ParseIP(srcHost).To4())
+	copy(ip[16:20], net.ParseIP(dstHost).To4())
+
+	frame := make([]byte, 14)
+	binary.BigEndian.PutUint16(frame[12:14], 0x0800)
+	frame = append(frame, ip...)
+	frame = append(frame, tcp...)
+	// Ethernet frames are padded to 60 bytes
+	for len(frame) < 60 {
+		frame = append(frame, 0)
+	}
+	return frame
+}
+
+// testPcap - libpcap file of Ethernet frames
+func testPcap(order binary.ByteOrder, frames ...[]byte) []byte {
+	var buf bytes.Buffer
+	header := make([]byte, 24)
+	order.PutUint32(header[0:4], pcapMagicMicros)
+	order.PutUint16(header[4:6], 2)
+	order.PutUint16(header[6:8], 4)
+	order.PutUint32(header[16:20], 65535)
+	order.PutUint32(header[20:24], linkTypeEthernet)
+	buf.Write(header)
+	for _, frame := range frames {
+		record := make([]byte, 16)
+		order.PutUint32(record[8:12], uint32(len(frame)))
+		order.PutUint32(record[12:16], uint32(len(frame)))
+		buf.Write(record)
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
+
+// testPcapng - pcapng file with one Ethernet interface and enhanced packet blocks
+func testPcapng(frames ...[]byte) []byte {
+	var buf bytes.Buffer
+	block := func(blockType uint32, body []byte) {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		length := uint32(12 + len(body))
+		binary.Write(&buf, binary.LittleEndian, blockType)
+		binary.Write(&buf, binary.LittleEndian, length)
+		buf.Write(body)
+		binary.Write(&buf, binary.LittleEndian, length)
+	}
+
+	section := make([]byte, 16)
+	binary.LittleEndian.PutUint32(section[0:4], pcapngByteOrderMagic)
+	binary.LittleEndian.PutUint16(section[4:6], 1)
+	binary.LittleEndian.PutUint64(section[8:16], 0xffffffffffffffff)
+	block(pcapngSectionHeader, section)
+
+	iface := make([]byte, 8)
+	binary.LittleEndian.PutUint16(iface[0:2], linkTypeEthernet)
+	block(pcapngInterface, iface)
+
+	for _, frame := range frames {
+		packet := make([]byte, 20)
+		binary.LittleEndian.PutUint32(packet[12:16], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(packet[16:20], uint32(len(frame)))
+		block(pcapngEnhancedPacket, append(packet, frame...))
+	}
+	return buf.Bytes()
+}
+
+const (
+	testClient = "10.0.0.1:51000"
+	testServer = "10.0.0.2:80"
+)
+
+// testHTTPConversation - keep-alive connection with GET and chunked POST, server segments are out of order and one
+// of them is retransmitted
+func testHTTPConversation() [][]byte {
+	get := "GET /users?page=2 HTTP/1.1\r\nHost: api.example.com\r\nAccept: application/json\r\n\r\n"
+	post := "POST /users HTTP/1.1\r\nHost: api.example.com\r\nContent-Length: 13\r\n\r\n{\"name\":\"jo\"}"
+	first := "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 2\r\n\r\n[]"
+	secondStart := "HTTP/1.1 201 Created\r\nTransfer-Encoding: chunked\r\n\r\n"
+	secondEnd := "7\r\ncreated\r\n0\r\n\r\n"
+
+	clientSeq, serverSeq := uint32(1000), uint32(0xfffffff0)
+	return [][]byte{
+		testTCPPacket(testClient, testServer, clientSeq, tcpSYN, ""),
+		testTCPPacket(testServer, testClient, serverSeq, tcpSYN|tcpACK, ""),
+		testTCPPacket(testClient, testServer, clientSeq+1, tcpACK, get),
+		testTCPPacket(testServer, testClient, serverSeq+1, tcpACK, first),
+		testTCPPacket(testClient, testServer, clientSeq+1+uint32(len(get)), tcpACK, post),
+		// sequence numbers wrap around
+		testTCPPacket(testServer, testClient, serverSeq+1+uint32(len(first)+len(secondStart)), tcpACK, secondEnd),
+		testTCPPacket(testServer, testClient, serverSeq+1+uint32(len(first)), tcpACK, secondStart),
+		testTCPPacket(testServer, testClient, serverSeq+1+uint32(len(first)), tcpACK, secondStart),
+	}
+}
+
+func TestReadCaptureFormats(t *testing.T) {
+	frame := testTCPPacket(testClient, testServer, 1, tcpACK, "data")
+
+	for _, data := range [][]byte{
+		testPcap(binary.LittleEndian, frame),
+		testPcap(binary.BigEndian, frame),
+		testPcapng(frame),
+	} {
+		packets, err := readCapture(bytes.NewReader(data))
+		expect(t, err, nil)
+		expect(t, len(packets), 1)
+		expect(t, packets[0].linkType, uint32(linkTypeEthernet))
+		expect(t, bytes.Equal(packets[0].data, frame), true)
+	}
+
+	// capture cut short keeps complete packets
+	data := testPcap(binary.LittleEndian, frame, frame)
+	packets, err := readCapture(bytes.NewReader(data[:len(data)-10]))
+	expect(t, err, nil)
+	expect(t, len(packets), 1)
+
+	_, err = readCapture(bytes.NewReader([]byte(`{"data": []}`)))
+	refute(t, err, nil)
+}
+
+func TestDecodeTCP(t *testing.T) {
+	segment, ok := decodeTCP(capturedPacket{linkType: linkTypeEthernet,
+		data: testTCPPacket(testClient, testServer, 7, tcpSYN, "")})
+	expect(t, ok, true)
+	expect(t, segment.src, testClient)
+	expect(t, segment.dst, testServer)
+	expect(t, segment.seq, uint32(7))
+	expect(t, segment.flags, byte(tcpSYN))
+	// Ethernet padding isn't payload
+	expect(t, len(segment.payload), 0)
+
+	// raw IP without link layer header
+	raw := testTCPPacket(testClient, testServer, 7, tcpACK, "hello")[14:]
+	segment, ok = decodeTCP(capturedPacket{linkType: linkTypeRaw, data: raw})
+	expect(t, ok, true)
+	expect(t, string(segment.payload), "hello")
+
+	// ARP
+	arp := make([]byte, 42)
+	binary.BigEndian.PutUint16(arp[12:14], 0x0806)
+	_, ok = decodeTCP(capturedPacket{linkType: linkTypeEthernet, data: arp})
+	expect(t, ok, false)
+}
+
+func TestTCPFlowStream(t *testing.T) {
+	flow := &tcpFlow{segments: []tcpSegment{
+		{seq: 107, payload: []byte("world")},
+		{seq: 100, payload: []byte("hello, ")},
+		// retransmission overlapping both segments
+		{seq: 105, payload: []byte(", wor")},
+	}}
+	expect(t, string(flow.stream()), "hello, world")
+
+	// data after missing segment is dropped
+	flow = &tcpFlow{isn: 100, hasISN: true, segments: []tcpSegment{
+		{seq: 100, payload: []byte("hello")},
+		{seq: 110, payload: []byte("world")},
+	}}
+	expect(t, string(flow.stream()), "hello")
+}
+
+func TestCapturePayloads(t *testing.T) {
+	tls := []byte{0x16, 0x03, 0x01, 0x00, 0x05, 'h', 'e', 'l', 'l', 'o'}
+	frames := append(testHTTPConversation(),
+		testTCPPacket("10.0.0.1:51001", "10.0.0.2:443", 1, tcpACK, string(tls)),
+		testTCPPacket("10.0.0.2:443", "10.0.0.1:51001", 1, tcpACK, string(tls)),
+	)
+	packets, err := readCapture(bytes.NewReader(testPcap(binary.LittleEndian, frames...)))
+	expect(t, err, nil)
+
+	payloads := capturePayloads(packets)
+	expect(t, len(payloads), 2)
+
+	expect(t, payloads[0].Request.Method, "GET")
+	expect(t, payloads[0].Request.Destination, "api.example.com")
+	expect(t, payloads[0].Request.Path, "/users")
+	expect(t, payloads[0].Request.Query, "page=2")
+	expect(t, payloads[0].Request.RemoteAddr, testClient)
+	expect(t, payloads[0].Response.Status, 200)
+	expect(t, payloads[0].Response.Body, "[]")
+
+	expect(t, payloads[1].Request.Method, "POST")
+	expect(t, payloads[1].Request.Body, `{"name":"jo"}`)
+	expect(t, payloads[1].Response.Status, 201)
+	expect(t, payloads[1].Response.Body, "created")
+}
+
+func TestCapturePayloadsWithoutHandshake(t *testing.T) {
+	// capture started after connection was opened
+	frames := testHTTPConversation()[2:]
+	packets, err := readCapture(bytes.NewReader(testPcapng(frames...)))
+	expect(t, err, nil)
+	expect(t, len(capturePayloads(packets)), 2)
+}
+
+func TestImportPcapFile(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	dir, err := ioutil.TempDir("", "hoverfly-pcap")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "traffic.pcap")
+	expect(t, ioutil.WriteFile(path, testPcap(binary.LittleEndian, testHTTPConversation()...), 0644), nil)
+
+	expect(t, dbClient.Import(path), nil)
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 2)
+}
@@ -0,0 +1,71 @@
+package hoverfly
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// proxyAuthRealm - realm announced to clients that have to authenticate
+const proxyAuthRealm = "Hoverfly"
+
+// ProxyAuthEnabled - checks whether clients have to authenticate to use the proxy
+func (c *Configuration) ProxyAuthEnabled() bool {
+	return c.ProxyUsername != "" || c.ProxyToken != ""
+}
+
+// proxyAuthorized - checks Proxy-Authorization header against configured basic credentials and token
+func (c *Configuration) proxyAuthorized(header string) bool {
+	scheme, credentials := header, ""
+	if i := strings.IndexByte(header, ' '); i >= 0 {
+		scheme, credentials = header[:i], strings.TrimSpace(header[i+1:])
+	}
+
+	switch {
+	case strings.EqualFold(scheme, "Basic") && c.ProxyUsername != "":
+		decoded, err := base64.StdEncoding.DecodeString(credentials)
+		if err != nil {
+			return false
+		}
+		expected := c.ProxyUsername + ":" + c.ProxyPassword
+		return subtle.ConstantTimeCompare(decoded, []byte(expected)) == 1
+	case strings.EqualFold(scheme, "Bearer") && c.ProxyToken != "":
+		return subtle.ConstantTimeCompare([]byte(credentials), []byte(c.ProxyToken)) == 1
+	}
+	return false
+}
+
+// ProxyAuthHandler - wraps proxy so that only clients with valid Proxy-Authorization (basic credentials or bearer
+// token) can use it, CONNECT requests are checked too so intercepted HTTPS traffic is covered. Header is removed
+// before request is handled so it's neither forwarded nor captured
+func (d *DBClient) ProxyAuthHandler(proxy http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !d.Cfg.ProxyAuthEnabled() {
+			proxy.ServeHTTP(w, req)
+			return
+		}
+
+		if !d.Cfg.proxyAuthorized(req.Header.Get("Proxy-Authorization")) {
+			log.WithFields(log.Fields{
+				"remoteAddr":  req.RemoteAddr,
+				"method":      req.Method,
+				"destination": req.Host,
+			}).Warn("Proxy authentication failed")
+
+			if d.Cfg.ProxyUsername != "" {
+				w.Header().Add("Proxy-Authenticate", `Basic realm="`+proxyAuthRealm+`"`)
+			}
+			if d.Cfg.ProxyToken != "" {
+				w.Header().Add("Proxy-Authenticate", `Bearer realm="`+proxyAuthRealm+`"`)
+			}
+			http.Error(w, "Hoverfly Error! Proxy authentication required.", http.StatusProxyAuthRequired)
+			return
+		}
+
+		req.Header.Del("Proxy-Authorization")
+		proxy.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,123 @@
+package hoverfly
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+// authTestProxy - returns server behind ProxyAuthHandler that reports Proxy-Authorization header it got
+func authTestProxy(cfg *Configuration) *httptest.Server {
+	dbClient := &DBClient{Cfg: cfg}
+	return httptest.NewServer(dbClient.ProxyAuthHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Got-Proxy-Authorization", r.Header.Get("Proxy-Authorization"))
+		w.WriteHeader(201)
+	})))
+}
+
+// proxyGet - sends request through given proxy with given Proxy-Authorization header
+func proxyGet(t *testing.T, proxy *httptest.Server, auth string) *http.Response {
+	req, err := http.NewRequest("GET", proxy.URL+"/users", nil)
+	expect(t, err, nil)
+	if auth != "" {
+		req.Header.Set("Proxy-Authorization", auth)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	expect(t, err, nil)
+	resp.Body.Close()
+	return resp
+}
+
+func TestProxyAuthDisabled(t *testing.T) {
+	proxy := authTestProxy(&Configuration{})
+	defer proxy.Close()
+
+	resp := proxyGet(t, proxy, "")
+	expect(t, resp.StatusCode, 201)
+}
+
+func TestProxyAuthBasic(t *testing.T) {
+	proxy := authTestProxy(&Configuration{ProxyUsername: "agent", ProxyPassword: "secret"})
+	defer proxy.Close()
+
+	resp := proxyGet(t, proxy, "")
+	expect(t, resp.StatusCode, http.StatusProxyAuthRequired)
+	expect(t, resp.Header.Get("Proxy-Authenticate"), `Basic realm="Hoverfly"`)
+
+	// "agent:wrong" encoded
+	resp = proxyGet(t, proxy, "Basic YWdlbnQ6d3Jvbmc=")
+	expect(t, resp.StatusCode, http.StatusProxyAuthRequired)
+
+	// "agent:secret" encoded, header isn't passed on
+	resp = proxyGet(t, proxy, "Basic YWdlbnQ6c2VjcmV0")
+	expect(t, resp.StatusCode, 201)
+	expect(t, resp.Header.Get("Got-Proxy-Authorization"), "")
+
+	// token wasn't configured
+	resp = proxyGet(t, proxy, "Bearer secret")
+	expect(t, resp.StatusCode, http.StatusProxyAuthRequired)
+}
+
+func TestProxyAuthToken(t *testing.T) {
+	proxy := authTestProxy(&Configuration{ProxyToken: "t0ken"})
+	defer proxy.Close()
+
+	resp := proxyGet(t, proxy, "Bearer wrong")
+	expect(t, resp.StatusCode, http.StatusProxyAuthRequired)
+	expect(t, resp.Header.Get("Proxy-Authenticate"), `Bearer realm="Hoverfly"`)
+
+	resp = proxyGet(t, proxy, "bearer t0ken")
+	expect(t, resp.StatusCode, 201)
+}
+
+func TestProxyAuthCoversConnect(t *testing.T) {
+	cfg := InitSettings()
+	cfg.ProxyUsername = "agent"
+	cfg.ProxyPassword = "secret"
+	proxy, dbClient := GetNewHoverfly(cfg, NewMemoryCache())
+	proxyServer := httptest.NewServer(dbClient.ProxyAuthHandler(proxy))
+	defer proxyServer.Close()
+
+	connect := func(auth string) int {
+		conn, err := net.Dial("tcp", proxyServer.Listener.Addr().String())
+		expect(t, err, nil)
+		defer conn.Close()
+
+		req := &http.Request{
+			Method: "CONNECT",
+			URL:    &url.URL{Opaque: "api.example.com:443"},
+			Host:   "api.example.com:443",
+			Header: make(http.Header),
+		}
+		if auth != "" {
+			req.Header.Set("Proxy-Authorization", auth)
+		}
+		expect(t, req.Write(conn), nil)
+
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		expect(t, err, nil)
+		return resp.StatusCode
+	}
+
+	expect(t, connect(""), http.StatusProxyAuthRequired)
+	expect(t, connect("Basic YWdlbnQ6c2VjcmV0"), http.StatusOK)
+}
+
+func TestSettingsProxyAuthEnv(t *testing.T) {
+	defer os.Setenv("HoverflyProxyUsername", "")
+	defer os.Setenv("HoverflyProxyPassword", "")
+	defer os.Setenv("HoverflyProxyToken", "")
+	os.Setenv("HoverflyProxyUsername", "agent")
+	os.Setenv("HoverflyProxyPassword", "secret")
+	os.Setenv("HoverflyProxyToken", "t0ken")
+
+	cfg := InitSettings()
+	expect(t, cfg.ProxyUsername, "agent")
+	expect(t, cfg.ProxyPassword, "secret")
+	expect(t, cfg.ProxyToken, "t0ken")
+	expect(t, cfg.ProxyAuthEnabled(), true)
+}
@@ -0,0 +1,42 @@
+package hoverfly
+
+import (
+	"net/url"
+	"strings"
+)
+
+// isIgnoredQueryParam - checks whether query parameter is on ignore list, names ending with "*" match every
+// parameter with given prefix, i.e. "utm_*"
+func isIgnoredQueryParam(name string, ignored []string) bool {
+	for _, pattern := range ignored {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// stripQueryParams - removes ignored parameters from raw query, order and encoding of the others is kept so
+// requests without ignored parameters keep their fingerprint
+func stripQueryParams(rawQuery string, ignored []string) string {
+	if len(ignored) == 0 || rawQuery == "" {
+		return rawQuery
+	}
+
+	params := strings.Split(rawQuery, "&")
+	kept := params[:0]
+	for _, param := range params {
+		name := strings.SplitN(param, "=", 2)[0]
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !isIgnoredQueryParam(name, ignored) {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
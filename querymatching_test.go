@@ -0,0 +1,51 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestStripQueryParams(t *testing.T) {
+	ignored := []string{"_", "nonce", "utm_*"}
+
+	expect(t, stripQueryParams("page=2&_=1476453912&sort=name", ignored), "page=2&sort=name")
+	expect(t, stripQueryParams("nonce=abc&utm_source=mail&utm_campaign=x", ignored), "")
+	expect(t, stripQueryParams("q=a%26b&%6Eonce=abc", ignored), "q=a%26b")
+	// parameters with ignored prefix but different name are kept
+	expect(t, stripQueryParams("nonces=1", ignored), "nonces=1")
+	expect(t, stripQueryParams("_=1", nil), "_=1")
+}
+
+func TestIgnoredQueryParamsShareResponse(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.IgnoreQueryParams = []string{"_", "utm_*"}
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users?page=2&_=1476453912", "", 200, "users")
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	for _, query := range []string{"page=2&_=1476453999", "page=2", "utm_source=mail&page=2"} {
+		req, _ := http.NewRequest("GET", "http://api.example.com/users?"+query, nil)
+		_, resp := dbClient.processRequest(req)
+		body, _ := ioutil.ReadAll(resp.Body)
+		expect(t, resp.StatusCode, 200)
+		expect(t, string(body), "users")
+	}
+
+	// parameters that aren't ignored still have to match
+	req, _ := http.NewRequest("GET", "http://api.example.com/users?page=3&_=1476453912", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+}
+
+func TestSettingsIgnoreQueryParamsEnv(t *testing.T) {
+	defer os.Setenv("HoverflyIgnoreQueryParams", "")
+
+	os.Setenv("HoverflyIgnoreQueryParams", "_, nonce")
+	cfg := InitSettings()
+	expect(t, len(cfg.IgnoreQueryParams), 2)
+	expect(t, cfg.IgnoreQueryParams[1], "nonce")
+}
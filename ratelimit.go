@@ -0,0 +1,180 @@
+package hoverfly
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/elazarl/goproxy"
+)
+
+// RateLimit - allows given number of requests per window to every matching destination, requests over the limit
+// get 429 response. Tokens are refilled continuously, so clients can't send the whole limit again right after the
+// window of their first request ends
+type RateLimit struct {
+	// Destination - regular expression matched against request host, every matching host has its own limit
+	Destination string `json:"destination"`
+	// Requests - number of requests allowed per window
+	Requests int `json:"requests"`
+	// Window - seconds
+	Window int `json:"window"`
+
+	destinationRe *regexp.Regexp
+}
+
+// compile - validates rate limit and compiles its destination
+func (r *RateLimit) compile() error {
+	re, err := regexp.Compile(r.Destination)
+	if err != nil {
+		return fmt.Errorf("invalid destination '%s': %s", r.Destination, err.Error())
+	}
+	if r.Requests <= 0 || r.Window <= 0 {
+		return fmt.Errorf("rate limit for '%s' needs positive number of requests and window", r.Destination)
+	}
+	r.destinationRe = re
+	return nil
+}
+
+// rate - tokens added per second
+func (r *RateLimit) rate() float64 {
+	return float64(r.Requests) / float64(r.Window)
+}
+
+// rateBucket - tokens left to destination
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimitState - result of counting request against rate limit
+type rateLimitState struct {
+	allowed   bool
+	limit     int
+	remaining int
+	// reset - time until all tokens are refilled
+	reset time.Duration
+	// retryAfter - time until next request is allowed
+	retryAfter time.Duration
+}
+
+// ceilSeconds - returns duration in whole seconds, rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// response - builds 429 response with rate limit headers
+func (s rateLimitState) response(req *http.Request) *http.Response {
+	resp := goproxy.NewResponse(req, goproxy.ContentTypeText, http.StatusTooManyRequests,
+		"Hoverfly Error! Rate limit exceeded.\n")
+	resp.Header.Set("X-RateLimit-Limit", strconv.Itoa(s.limit))
+	resp.Header.Set("X-RateLimit-Remaining", strconv.Itoa(s.remaining))
+	resp.Header.Set("X-RateLimit-Reset", ceilSeconds(s.reset))
+	resp.Header.Set("Retry-After", ceilSeconds(s.retryAfter))
+	return resp
+}
+
+// RateLimits - ordered rate limits, only the first one matching request destination is used
+type RateLimits struct {
+	mu      sync.Mutex
+	limits  []RateLimit
+	buckets map[string]*rateBucket
+	now     func() time.Time
+}
+
+// NewRateLimits - returns empty rate limit list
+func NewRateLimits() *RateLimits {
+	return &RateLimits{buckets: make(map[string]*rateBucket), now: time.Now}
+}
+
+// Set - validates and replaces all rate limits, current ones are kept when any of them is invalid. All
+// destinations get full limit again
+func (r *RateLimits) Set(limits []RateLimit) error {
+	compiled := make([]RateLimit, len(limits))
+	for i, limit := range limits {
+		if err := limit.compile(); err != nil {
+			return err
+		}
+		compiled[i] = limit
+	}
+
+	r.mu.Lock()
+	r.limits = compiled
+	r.buckets = make(map[string]*rateBucket)
+	r.mu.Unlock()
+	return nil
+}
+
+// Get - returns current rate limits
+func (r *RateLimits) Get() []RateLimit {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RateLimit{}, r.limits...)
+}
+
+// Clear - removes all rate limits
+func (r *RateLimits) Clear() {
+	r.mu.Lock()
+	r.limits = nil
+	r.buckets = make(map[string]*rateBucket)
+	r.mu.Unlock()
+}
+
+// Take - counts request against rate limit of its destination, false when destination isn't limited
+func (r *RateLimits) Take(req *http.Request) (rateLimitState, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.limits {
+		limit := &r.limits[i]
+		if !limit.destinationRe.MatchString(req.Host) {
+			continue
+		}
+
+		now := r.now()
+		key := strconv.Itoa(i) + " " + req.Host
+		bucket, ok := r.buckets[key]
+		if !ok {
+			bucket = &rateBucket{tokens: float64(limit.Requests), updated: now}
+			r.buckets[key] = bucket
+		}
+		bucket.tokens = math.Min(float64(limit.Requests), bucket.tokens+now.Sub(bucket.updated).Seconds()*limit.rate())
+		bucket.updated = now
+
+		state := rateLimitState{limit: limit.Requests}
+		if bucket.tokens >= 1 {
+			bucket.tokens--
+			state.allowed = true
+		} else {
+			state.retryAfter = time.Duration((1 - bucket.tokens) / limit.rate() * float64(time.Second))
+		}
+		state.remaining = int(bucket.tokens)
+		state.reset = time.Duration((float64(limit.Requests) - bucket.tokens) / limit.rate() * float64(time.Second))
+		return state, true
+	}
+	return rateLimitState{}, false
+}
+
+// rateLimitResponse - returns 429 response when request destination is over its rate limit
+func (d *DBClient) rateLimitResponse(req *http.Request) (*http.Response, bool) {
+	if d.RateLimits == nil {
+		return nil, false
+	}
+
+	state, ok := d.RateLimits.Take(req)
+	if !ok || state.allowed {
+		return nil, false
+	}
+
+	log.WithFields(log.Fields{
+		"path":        req.URL.Path,
+		"method":      req.Method,
+		"destination": req.Host,
+		"limit":       state.limit,
+	}).Info("Rate limit exceeded")
+	return state.response(req), true
+}
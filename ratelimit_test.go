@@ -0,0 +1,98 @@
+package hoverfly
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeClock - returns time moved forward with advance
+type fakeClock struct {
+	current time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.current
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.current = c.current.Add(d)
+}
+
+func TestRateLimitExceeded(t *testing.T) {
+	clock := &fakeClock{current: time.Now()}
+	limits := NewRateLimits()
+	limits.now = clock.now
+	expect(t, limits.Set([]RateLimit{{Destination: `\.example\.com$`, Requests: 2, Window: 10}}), nil)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+
+	state, ok := limits.Take(req)
+	expect(t, ok, true)
+	expect(t, state.allowed, true)
+	expect(t, state.remaining, 1)
+
+	state, _ = limits.Take(req)
+	expect(t, state.allowed, true)
+	expect(t, state.remaining, 0)
+
+	state, _ = limits.Take(req)
+	expect(t, state.allowed, false)
+	expect(t, state.retryAfter, 5*time.Second)
+	expect(t, state.reset, 10*time.Second)
+
+	resp := state.response(req)
+	expect(t, resp.StatusCode, http.StatusTooManyRequests)
+	expect(t, resp.Header.Get("X-RateLimit-Limit"), "2")
+	expect(t, resp.Header.Get("X-RateLimit-Remaining"), "0")
+	expect(t, resp.Header.Get("X-RateLimit-Reset"), "10")
+	expect(t, resp.Header.Get("Retry-After"), "5")
+
+	// every destination has its own limit
+	other, _ := http.NewRequest("GET", "http://auth.example.com/token", nil)
+	state, _ = limits.Take(other)
+	expect(t, state.allowed, true)
+
+	// token is back after half of the window
+	clock.advance(5 * time.Second)
+	state, _ = limits.Take(req)
+	expect(t, state.allowed, true)
+	state, _ = limits.Take(req)
+	expect(t, state.allowed, false)
+
+	unlimited, _ := http.NewRequest("GET", "http://example.org/", nil)
+	_, ok = limits.Take(unlimited)
+	expect(t, ok, false)
+}
+
+func TestRateLimitResponse(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+	expect(t, dbClient.RateLimits.Set([]RateLimit{{Destination: "api.example.com", Requests: 1, Window: 60}}), nil)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 200)
+
+	req, _ = http.NewRequest("GET", "http://api.example.com/users", nil)
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusTooManyRequests)
+	expect(t, resp.Header.Get("Retry-After"), "60")
+}
+
+func TestRateLimitsInvalid(t *testing.T) {
+	limits := NewRateLimits()
+	expect(t, limits.Set([]RateLimit{{Destination: ".", Requests: 1, Window: 1}}), nil)
+
+	refute(t, limits.Set([]RateLimit{{Destination: "(", Requests: 1, Window: 1}}), nil)
+	refute(t, limits.Set([]RateLimit{{Destination: ".", Window: 1}}), nil)
+	refute(t, limits.Set([]RateLimit{{Destination: ".", Requests: 1}}), nil)
+
+	// current rate limits are kept
+	expect(t, len(limits.Get()), 1)
+
+	limits.Clear()
+	expect(t, len(limits.Get()), 0)
+}
@@ -0,0 +1,180 @@
+package hoverfly
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// sharedRecordIndexTTL - how long index of shared cache is used, other Hoverfly instances write to Redis and
+// PostgreSQL caches without invalidating it
+const sharedRecordIndexTTL = time.Second
+
+// matcherEntry - record with matchers, its patterns and body expressions are compiled
+type matcherEntry struct {
+	payload Payload
+	body    bodyMatchers
+}
+
+// RecordIndex - records with matchers and recorded destinations kept in memory, so requests that aren't found by
+// their fingerprint don't decode the whole cache. They are rebuilt on the first lookup after records are saved or
+// deleted, or after TTL when it's set
+type RecordIndex struct {
+	// TTL - how long index is used before it's rebuilt even though records weren't changed by this instance, zero
+	// keeps it until records change
+	TTL time.Duration
+
+	mu sync.Mutex
+	// version - bumped on every change of records, index is up to date when it was built from the current version
+	version      uint64
+	builtVersion uint64
+	builtAt      time.Time
+	built        bool
+	matchers     []matcherEntry
+
+	destinationsVersion uint64
+	destinationsBuiltAt time.Time
+	destinationsBuilt   bool
+	destinations        []string
+}
+
+// NewRecordIndex - returns empty index, it's built on first lookup
+func NewRecordIndex() *RecordIndex {
+	return &RecordIndex{}
+}
+
+// Invalidate - marks index as out of date
+func (i *RecordIndex) Invalidate() {
+	i.mu.Lock()
+	i.version++
+	i.mu.Unlock()
+}
+
+// matcherRecords - returns records with matchers, they are read from the cache when index is out of date
+func (i *RecordIndex) matcherRecords(cache Cache) ([]matcherEntry, error) {
+	i.mu.Lock()
+	if i.built && i.builtVersion == i.version && i.fresh(i.builtAt) {
+		entries := i.matchers
+		i.mu.Unlock()
+		return entries, nil
+	}
+	version := i.version
+	i.mu.Unlock()
+
+	// cache is scanned without holding the lock, so records can be saved in the meantime
+	builtAt := time.Now()
+	entries, err := indexMatcherRecords(cache)
+	if err != nil {
+		return nil, err
+	}
+
+	i.mu.Lock()
+	if version == i.version {
+		i.matchers, i.builtVersion, i.builtAt, i.built = entries, version, builtAt, true
+	}
+	i.mu.Unlock()
+	return entries, nil
+}
+
+// recordedDestinations - returns sorted destinations of records, they are counted by the cache when index is out
+// of date
+func (i *RecordIndex) recordedDestinations(cache Cache) ([]string, error) {
+	i.mu.Lock()
+	if i.destinationsBuilt && i.destinationsVersion == i.version && i.fresh(i.destinationsBuiltAt) {
+		destinations := i.destinations
+		i.mu.Unlock()
+		return destinations, nil
+	}
+	version := i.version
+	i.mu.Unlock()
+
+	builtAt := time.Now()
+	destinations, err := indexDestinations(cache)
+	if err != nil {
+		return nil, err
+	}
+
+	i.mu.Lock()
+	if version == i.version {
+		i.destinations, i.destinationsVersion, i.destinationsBuiltAt, i.destinationsBuilt = destinations, version,
+			builtAt, true
+	}
+	i.mu.Unlock()
+	return destinations, nil
+}
+
+// fresh - checks whether index built at given time hasn't outlived its TTL
+func (i *RecordIndex) fresh(builtAt time.Time) bool {
+	return i.TTL <= 0 || time.Since(builtAt) < i.TTL
+}
+
+// isSharedCache - checks whether other Hoverfly instances can write to the cache, wrappers are looked through
+func isSharedCache(cache Cache) bool {
+	switch c := cache.(type) {
+	case *ReadOnlyCache:
+		return isSharedCache(c.Cache)
+	case *TieredCache:
+		return isSharedCache(c.back)
+	case *RedisCache, *PostgresCache:
+		return true
+	}
+	return false
+}
+
+// indexDestinations - reads destinations of records from the cache, sorted so they are tried in the same order
+func indexDestinations(cache Cache) ([]string, error) {
+	counts, err := cache.RecordsCountByDestination()
+	if err != nil {
+		return nil, err
+	}
+	destinations := make([]string, 0, len(counts))
+	for destination := range counts {
+		destinations = append(destinations, destination)
+	}
+	sort.Strings(destinations)
+	return destinations, nil
+}
+
+// indexMatcherRecords - reads records with matchers from the cache and compiles them, invalid records can't match
+// any request, so they are left out
+func indexMatcherRecords(cache Cache) ([]matcherEntry, error) {
+	var entries []matcherEntry
+	err := cache.ForEachRequest(func(pl Payload) error {
+		if !pl.hasMatchers() {
+			return nil
+		}
+		if pl.Matchers != nil && pl.Matchers.validate() != nil {
+			return nil
+		}
+		body, err := pl.compileBodyMatchers()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, matcherEntry{payload: pl, body: body})
+		return nil
+	})
+	return entries, err
+}
+
+// matcherRecords - returns records with matchers, from the index when there is one
+func (d *DBClient) matcherRecords() ([]matcherEntry, error) {
+	if d.RecordIndex == nil {
+		return indexMatcherRecords(d.Cache)
+	}
+	return d.RecordIndex.matcherRecords(d.Cache)
+}
+
+// recordedDestinations - returns destinations of records, from the index when there is one
+func (d *DBClient) recordedDestinations() ([]string, error) {
+	if d.RecordIndex == nil {
+		return indexDestinations(d.Cache)
+	}
+	return d.RecordIndex.recordedDestinations(d.Cache)
+}
+
+// recordsChanged - marks in-memory indexes of records as out of date, called after records are saved or deleted
+func (d *DBClient) recordsChanged() {
+	if d.RecordIndex != nil {
+		d.RecordIndex.Invalidate()
+	}
+}
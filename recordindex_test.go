@@ -0,0 +1,108 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRecordIndexIsRebuiltWhenRecordsChange(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.ImportPayloads([]Payload{{
+		Request:          RequestDetails{Method: "POST", Destination: "api.example.com", Path: "/orders"},
+		Response:         ResponseDetails{Status: 201, Body: "express"},
+		JSONPathMatchers: []string{`$.type == "express"`},
+	}, {
+		Request:  RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/health"},
+		Response: ResponseDetails{Status: 200, Body: "ok"},
+	}})
+	expect(t, err, nil)
+
+	entries, err := dbClient.matcherRecords()
+	expect(t, err, nil)
+	expect(t, len(entries), 1)
+	expect(t, len(entries[0].body.jsonPath), 1)
+
+	// records changed outside of Hoverfly aren't seen until index is invalidated
+	pl := Payload{
+		Request:  RequestDetails{Method: "GET", Destination: "api.example.com"},
+		Response: ResponseDetails{Status: 200, Body: "user"},
+		Matchers: &RequestMatchers{Path: &FieldMatcher{Glob: "/users/*"}},
+	}
+	pl.ID = dbClient.recordKey(pl)
+	bts, _ := pl.Encode()
+	expect(t, dbClient.Cache.Set([]byte(pl.ID), bts), nil)
+	entries, _ = dbClient.matcherRecords()
+	expect(t, len(entries), 1)
+
+	dbClient.recordsChanged()
+	entries, _ = dbClient.matcherRecords()
+	expect(t, len(entries), 2)
+}
+
+func TestMatcherRecordSkipsRecordRemovedFromCache(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	err := dbClient.ImportPayloads([]Payload{{
+		Request:  RequestDetails{Method: "GET", Destination: "api.example.com"},
+		Response: ResponseDetails{Status: 200, Body: "user"},
+		Matchers: &RequestMatchers{Path: &FieldMatcher{Glob: "/users/*"}},
+	}})
+	expect(t, err, nil)
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users/42", nil)
+	_, resp := dbClient.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, string(body), "user")
+
+	// record is gone (i.e. evicted or expired), but it's still in the index
+	entries, _ := dbClient.matcherRecords()
+	expect(t, dbClient.Cache.DeleteKey([]byte(entries[0].payload.ID)), nil)
+
+	req, _ = http.NewRequest("GET", "http://api.example.com/users/42", nil)
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+	entries, _ = dbClient.matcherRecords()
+	expect(t, len(entries), 0)
+}
+
+func TestRecordIndexIsRebuiltAfterTTL(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.RecordIndex.TTL = 10 * time.Millisecond
+
+	entries, err := dbClient.matcherRecords()
+	expect(t, err, nil)
+	expect(t, len(entries), 0)
+
+	// record saved by another instance sharing the cache
+	pl := Payload{
+		Request:  RequestDetails{Method: "GET", Destination: "api.example.com"},
+		Response: ResponseDetails{Status: 200, Body: "user"},
+		Matchers: &RequestMatchers{Path: &FieldMatcher{Glob: "/users/*"}},
+	}
+	pl.ID = dbClient.recordKey(pl)
+	bts, _ := pl.Encode()
+	expect(t, dbClient.Cache.Set([]byte(pl.ID), bts), nil)
+	entries, _ = dbClient.matcherRecords()
+	expect(t, len(entries), 0)
+
+	time.Sleep(20 * time.Millisecond)
+	entries, _ = dbClient.matcherRecords()
+	expect(t, len(entries), 1)
+}
+
+func TestIsSharedCache(t *testing.T) {
+	expect(t, isSharedCache(NewMemoryCache()), false)
+	expect(t, isSharedCache(&RedisCache{}), true)
+	expect(t, isSharedCache(NewReadOnlyCache(&PostgresCache{})), true)
+	expect(t, isSharedCache(NewReadOnlyCache(NewMemoryCache())), false)
+}
@@ -0,0 +1,116 @@
+package hoverfly
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// clientHostKey - context key of the host client sent its request to, set when it differs from the destination
+type clientHostKey struct{}
+
+// withClientHost - returns context remembering host client sent its request to
+func withClientHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, clientHostKey{}, host)
+}
+
+// rewriteBase - returns scheme and host that replace destination in replayed responses, configured rewrite host
+// is used first, then the host client sent its request to (i.e. Hoverfly itself in webserver mode). Empty host
+// means nothing is rewritten
+func (d *DBClient) rewriteBase(req *http.Request) (scheme, host string) {
+	if !d.Cfg.RewriteURLs {
+		return "", ""
+	}
+
+	if base := d.Cfg.RewriteHost; base != "" {
+		if u, err := url.Parse(base); err == nil && u.Host != "" {
+			return u.Scheme, u.Host
+		}
+		return "http", base
+	}
+
+	if host, ok := req.Context().Value(clientHostKey{}).(string); ok && host != req.Host {
+		return "http", host
+	}
+	return "", ""
+}
+
+// isRewritableBody - checks whether body of given content type can contain links
+func isRewritableBody(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return strings.HasPrefix(contentType, "text/html") || strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "xml")
+}
+
+// rewriteResponseURLs - replaces destination with given scheme and host in Location headers and links inside HTML,
+// JSON and XML bodies, cookies lose their destination domain
+func rewriteResponseURLs(response *ResponseDetails, destination, scheme, host string) {
+	headers := http.Header(copyHeaders(response.Headers))
+
+	for _, name := range []string{"Location", "Content-Location"} {
+		if location := headers.Get(name); location != "" {
+			if u, err := url.Parse(location); err == nil && u.Host == destination {
+				u.Scheme, u.Host = scheme, host
+				headers.Set(name, u.String())
+			}
+		}
+	}
+
+	if cookies := headers["Set-Cookie"]; len(cookies) > 0 {
+		for i, cookie := range cookies {
+			cookies[i] = rewriteCookie(cookie, destination, scheme)
+		}
+	}
+
+	if isRewritableBody(headers.Get("Content-Type")) && headers.Get("Content-Encoding") == "" &&
+		len(response.Events) == 0 {
+		body := response.Body
+		for _, prefix := range []string{"https://", "http://", "//"} {
+			body = strings.Replace(body, prefix+destination, scheme+"://"+host, -1)
+		}
+		// JSON encoders might escape slashes
+		for _, prefix := range []string{`https:\/\/`, `http:\/\/`} {
+			body = strings.Replace(body, prefix+destination, scheme+`:\/\/`+host, -1)
+		}
+		if body != response.Body {
+			response.Body = body
+			headers.Del("Content-Length")
+		}
+	}
+
+	response.Headers = headers
+}
+
+// rewriteCookie - removes destination domain from cookie, so browser keeps it for the host it sent request to.
+// Secure attribute is removed too when new scheme is plain HTTP
+func rewriteCookie(cookie, destination, scheme string) string {
+	destinationName := hostname(destination)
+
+	attributes := strings.Split(cookie, ";")
+	rewritten := []string{attributes[0]}
+	for _, attribute := range attributes[1:] {
+		parts := strings.SplitN(attribute, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		switch {
+		case name == "domain" && len(parts) == 2:
+			domain := strings.TrimPrefix(strings.TrimSpace(parts[1]), ".")
+			if domain == destinationName || strings.HasSuffix(destinationName, "."+domain) {
+				continue
+			}
+		case name == "secure" && scheme == "http":
+			continue
+		}
+		rewritten = append(rewritten, attribute)
+	}
+	return strings.Join(rewritten, ";")
+}
+
+// hostname - returns host without port
+func hostname(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	return host
+}
@@ -0,0 +1,135 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestRewriteResponseURLs(t *testing.T) {
+	response := ResponseDetails{
+		Status: 302,
+		Headers: map[string][]string{
+			"Location":       {"https://api.example.com/login?next=%2Fusers"},
+			"Content-Type":   {"application/json"},
+			"Content-Length": {"80"},
+			"Set-Cookie":     {"session=abc; Domain=.example.com; Path=/; Secure; HttpOnly", "other=1; Domain=other.com"},
+		},
+		Body: `{"self":"https://api.example.com/users","next":"https:\/\/api.example.com\/users?page=2"}`,
+	}
+
+	rewriteResponseURLs(&response, "api.example.com", "http", "localhost:8500")
+
+	headers := http.Header(response.Headers)
+	expect(t, headers.Get("Location"), "http://localhost:8500/login?next=%2Fusers")
+	expect(t, headers["Set-Cookie"][0], "session=abc; Path=/; HttpOnly")
+	expect(t, headers["Set-Cookie"][1], "other=1; Domain=other.com")
+	expect(t, response.Body, `{"self":"http://localhost:8500/users","next":"http:\/\/localhost:8500\/users?page=2"}`)
+	expect(t, headers.Get("Content-Length"), "")
+}
+
+func TestRewriteResponseURLsKeepsOtherHosts(t *testing.T) {
+	response := ResponseDetails{
+		Status: 200,
+		Headers: map[string][]string{
+			"Location":     {"https://cdn.example.com/logo.png"},
+			"Content-Type": {"text/html; charset=utf-8"},
+		},
+		Body: `<a href="//api.example.com/users">users</a><img src="https://cdn.example.com/logo.png">`,
+	}
+
+	rewriteResponseURLs(&response, "api.example.com", "http", "localhost:8500")
+
+	expect(t, http.Header(response.Headers).Get("Location"), "https://cdn.example.com/logo.png")
+	expect(t, response.Body, `<a href="http://localhost:8500/users">users</a><img src="https://cdn.example.com/logo.png">`)
+}
+
+func TestRewriteResponseURLsSkipsEncodedBodies(t *testing.T) {
+	body := `{"self":"https://api.example.com/users"}`
+	response := ResponseDetails{
+		Status: 200,
+		Headers: map[string][]string{
+			"Content-Type":     {"application/json"},
+			"Content-Encoding": {"gzip"},
+		},
+		Body: body,
+	}
+
+	rewriteResponseURLs(&response, "api.example.com", "http", "localhost:8500")
+	expect(t, response.Body, body)
+}
+
+func TestRewriteBase(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/users", nil)
+	req = req.WithContext(withClientHost(req.Context(), "localhost:8500"))
+
+	// disabled
+	_, host := dbClient.rewriteBase(req)
+	expect(t, host, "")
+
+	dbClient.Cfg.RewriteURLs = true
+	scheme, host := dbClient.rewriteBase(req)
+	expect(t, scheme, "http")
+	expect(t, host, "localhost:8500")
+
+	dbClient.Cfg.RewriteHost = "https://mocks.example.com"
+	scheme, host = dbClient.rewriteBase(req)
+	expect(t, scheme, "https")
+	expect(t, host, "mocks.example.com")
+
+	dbClient.Cfg.RewriteHost = "mocks.example.com:8080"
+	scheme, host = dbClient.rewriteBase(req)
+	expect(t, scheme, "http")
+	expect(t, host, "mocks.example.com:8080")
+
+	// proxied requests keep their destination when there is no rewrite host
+	dbClient.Cfg.RewriteHost = ""
+	req, _ = http.NewRequest("GET", "http://api.example.com/users", nil)
+	_, host = dbClient.rewriteBase(req)
+	expect(t, host, "")
+}
+
+func TestWebserverRewritesRedirects(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.RewriteURLs = true
+
+	req, _ := http.NewRequest("POST", "http://api.example.com/users", nil)
+	resp := &http.Response{StatusCode: 303, Header: http.Header{
+		"Location":     []string{"http://api.example.com/users/1"},
+		"Content-Type": []string{"application/json"},
+	}}
+	dbClient.save(req, nil, resp, []byte(`{"self":"http://api.example.com/users/1"}`))
+
+	webserver := httptest.NewServer(dbClient.WebserverHandler())
+	defer webserver.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Post(webserver.URL+"/users", "application/json", nil)
+	expect(t, err, nil)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	expect(t, resp.StatusCode, 303)
+	expect(t, resp.Header.Get("Location"), webserver.URL+"/users/1")
+	expect(t, string(body), `{"self":"`+webserver.URL+`/users/1"}`)
+}
+
+func TestSettingsRewriteEnv(t *testing.T) {
+	defer os.Setenv("HoverflyRewriteURLs", "")
+	defer os.Setenv("HoverflyRewriteHost", "")
+
+	os.Setenv("HoverflyRewriteURLs", "true")
+	os.Setenv("HoverflyRewriteHost", "localhost:8500")
+	cfg := InitSettings()
+	expect(t, cfg.RewriteURLs, true)
+	expect(t, cfg.RewriteHost, "localhost:8500")
+}
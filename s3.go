@@ -0,0 +1,131 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// S3Persister - stores serialized simulation (same format as records export) as a single S3 object
+type S3Persister struct {
+	Client s3iface.S3API
+	Bucket string
+	Key    string
+}
+
+// NewS3Persister - returns S3Persister, credentials are taken from the environment, shared credentials
+// file or instance role
+func NewS3Persister(region, bucket, key string) (*S3Persister, error) {
+	cfg := aws.NewConfig()
+	if region != "" {
+		cfg = cfg.WithRegion(region)
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Persister{
+		Client: s3.New(sess),
+		Bucket: bucket,
+		Key:    key,
+	}, nil
+}
+
+// Upload - replaces stored simulation with given payloads
+func (p *S3Persister) Upload(payloads []Payload) error {
+	body, err := json.Marshal(recordedRequests{Meta: currentSimulationMeta(), Data: payloads})
+	if err != nil {
+		return err
+	}
+
+	_, err = p.Client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(p.Bucket),
+		Key:         aws.String(p.Key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+// Download - returns stored simulation, nothing is returned when it wasn't uploaded yet
+func (p *S3Persister) Download() ([]Payload, error) {
+	out, err := p.Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(p.Bucket),
+		Key:    aws.String(p.Key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	data, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Got error while reading simulation from S3, error %s", err.Error())
+	}
+	payloads, err := decodeSimulation(data)
+	if err != nil {
+		return nil, fmt.Errorf("Got error while parsing simulation from S3, error %s", err.Error())
+	}
+	return payloads, nil
+}
+
+// PersistToS3 - uploads all captured requests to S3
+func (d *DBClient) PersistToS3(p *S3Persister) error {
+	payloads, err := d.Cache.GetAllRequests()
+	if err != nil {
+		return err
+	}
+	if err := p.Upload(payloads); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"bucket":  p.Bucket,
+		"key":     p.Key,
+		"records": len(payloads),
+	}).Debug("Simulation synced to S3")
+	return nil
+}
+
+// BootstrapFromS3 - imports simulation stored in S3, missing simulation is not an error so that the
+// first instance can start with an empty cache
+func (d *DBClient) BootstrapFromS3(p *S3Persister) error {
+	payloads, err := p.Download()
+	if err != nil {
+		return err
+	}
+	if len(payloads) == 0 {
+		log.WithFields(log.Fields{
+			"bucket": p.Bucket,
+			"key":    p.Key,
+		}).Info("No simulation found in S3, starting with current cache")
+		return nil
+	}
+	return d.ImportPayloads(payloads)
+}
+
+// StartS3Sync - periodically uploads captured requests to S3 until stop channel is closed
+func (d *DBClient) StartS3Sync(p *S3Persister, interval time.Duration, stop <-chan struct{}) {
+	runEvery(interval, stop, func() {
+		if err := d.PersistToS3(p); err != nil {
+			log.WithFields(log.Fields{
+				"error":  err.Error(),
+				"bucket": p.Bucket,
+				"key":    p.Key,
+			}).Error("Failed to sync simulation to S3")
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// fakeS3 - keeps objects in memory, only methods used by S3Persister are implemented
+type fakeS3 struct {
+	s3iface.S3API
+	objects map[string][]byte
+}
+
+func (f *fakeS3) PutObject(in *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	body, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[*in.Bucket+"/"+*in.Key] = body
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) GetObject(in *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	body, ok := f.objects[*in.Bucket+"/"+*in.Key]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "The specified key does not exist.", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func newFakeS3Persister() *S3Persister {
+	return &S3Persister{
+		Client: &fakeS3{objects: make(map[string][]byte)},
+		Bucket: "bucket",
+		Key:    DefaultS3Key,
+	}
+}
+
+func TestPersistToS3AndBootstrap(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	req, err := http.NewRequest("GET", "http://example.com/q=1", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	persister := newFakeS3Persister()
+
+	err = dbClient.PersistToS3(persister)
+	expect(t, err, nil)
+
+	// starting another instance with an empty cache
+	otherServer, otherClient := testTools(201, `{'message': 'here'}`)
+	defer otherServer.Close()
+	defer otherClient.Cache.DeleteData()
+
+	err = otherClient.BootstrapFromS3(persister)
+	expect(t, err, nil)
+
+	count, err := otherClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 1)
+}
+
+func TestBootstrapFromS3NoSimulation(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	err := dbClient.BootstrapFromS3(newFakeS3Persister())
+	expect(t, err, nil)
+}
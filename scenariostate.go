@@ -0,0 +1,79 @@
+package hoverfly
+
+import (
+	"sync"
+)
+
+// ScenarioState - simulation-wide key/value state. Records can require some state to be served and change it once
+// they are, so multi-step flows (i.e. create, pay and ship an order) are simulated in the right order
+type ScenarioState struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// NewScenarioState - returns empty state
+func NewScenarioState() *ScenarioState {
+	return &ScenarioState{values: make(map[string]string)}
+}
+
+// Get - returns current state
+func (s *ScenarioState) Get() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make(map[string]string, len(s.values))
+	for key, value := range s.values {
+		values[key] = value
+	}
+	return values
+}
+
+// Set - replaces current state
+func (s *ScenarioState) Set(values map[string]string) {
+	s.mu.Lock()
+	s.values = make(map[string]string, len(values))
+	for key, value := range values {
+		if value != "" {
+			s.values[key] = value
+		}
+	}
+	s.mu.Unlock()
+}
+
+// Reset - removes all state
+func (s *ScenarioState) Reset() {
+	s.Set(nil)
+}
+
+// Satisfies - checks that state has all required values, missing keys are required with empty value
+func (s *ScenarioState) Satisfies(requires map[string]string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, value := range requires {
+		if s.values[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Transition - checks that state has all required values and applies transitions, false when requirements are not
+// met and nothing changes. Missing keys are required with empty value, transitions to empty value remove keys
+func (s *ScenarioState) Transition(requires, transitions map[string]string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, value := range requires {
+		if s.values[key] != value {
+			return false
+		}
+	}
+	for key, value := range transitions {
+		if value == "" {
+			delete(s.values, key)
+		} else {
+			s.values[key] = value
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package hoverfly
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestScenarioStateTransition(t *testing.T) {
+	state := NewScenarioState()
+
+	expect(t, state.Transition(map[string]string{"order": "paid"}, map[string]string{"order": "shipped"}), false)
+	expect(t, len(state.Get()), 0)
+
+	expect(t, state.Transition(nil, map[string]string{"order": "paid", "basket": "full"}), true)
+	expect(t, state.Transition(map[string]string{"order": "paid"}, map[string]string{"order": "shipped", "basket": ""}), true)
+
+	values := state.Get()
+	expect(t, len(values), 1)
+	expect(t, values["order"], "shipped")
+
+	// empty value requires key to be missing
+	expect(t, state.Transition(map[string]string{"basket": ""}, nil), true)
+
+	state.Reset()
+	expect(t, len(state.Get()), 0)
+}
+
+func TestScenarioStateFlow(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	step := func(path string, requires, transitions map[string]string) Payload {
+		return Payload{
+			Request:          RequestDetails{Method: "POST", Scheme: "http", Destination: "shop.example.com", Path: path},
+			Response:         ResponseDetails{Status: 201, Body: path},
+			RequiresState:    requires,
+			TransitionsState: transitions,
+		}
+	}
+	expect(t, dbClient.ImportPayloads([]Payload{
+		step("/orders", nil, map[string]string{"order": "created"}),
+		step("/orders/1/payment", map[string]string{"order": "created"}, map[string]string{"order": "paid"}),
+		step("/orders/1/shipment", map[string]string{"order": "paid"}, map[string]string{"order": "shipped"}),
+	}), nil)
+
+	call := func(path string) int {
+		req, _ := http.NewRequest("POST", "http://shop.example.com"+path, nil)
+		_, resp := dbClient.processRequest(req)
+		return resp.StatusCode
+	}
+
+	// order can't be shipped before it's paid
+	expect(t, call("/orders/1/shipment"), dbClient.Cfg.GetMissStatus())
+	expect(t, call("/orders"), 201)
+	expect(t, call("/orders/1/shipment"), dbClient.Cfg.GetMissStatus())
+	expect(t, call("/orders/1/payment"), 201)
+	expect(t, call("/orders/1/payment"), dbClient.Cfg.GetMissStatus())
+	expect(t, call("/orders/1/shipment"), 201)
+	expect(t, dbClient.ScenarioState.Get()["order"], "shipped")
+}
+
+func TestScenarioStateNotChangedWhenResponseFails(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	expect(t, dbClient.ImportPayloads([]Payload{{
+		Request:          RequestDetails{Method: "POST", Scheme: "http", Destination: "shop.example.com", Path: "/orders"},
+		Response:         ResponseDetails{Status: 201, Body: "created"},
+		TransitionsState: map[string]string{"order": "created"},
+	}}), nil)
+
+	dbClient.Cfg.Middleware = "./should/not/exist.py"
+	dbClient.Cfg.MiddlewareFailure = MiddlewareFailClosed
+	req, _ := http.NewRequest("POST", "http://shop.example.com/orders", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+	expect(t, len(dbClient.ScenarioState.Get()), 0)
+
+	dbClient.Cfg.Middleware = ""
+	req, _ = http.NewRequest("POST", "http://shop.example.com/orders", nil)
+	_, resp = dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 201)
+	expect(t, dbClient.ScenarioState.Get()["order"], "created")
+}
@@ -0,0 +1,147 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SimulationSchemaVersion - version of exported simulations, it's raised whenever records change in a way older
+// exports have to be migrated for. Exports without version are version 1
+const SimulationSchemaVersion = 2
+
+// simulationMeta - information about exported simulation
+type simulationMeta struct {
+	SchemaVersion int `json:"schemaVersion"`
+}
+
+// currentSimulationMeta - meta of simulations exported by this version
+func currentSimulationMeta() *simulationMeta {
+	return &simulationMeta{SchemaVersion: SimulationSchemaVersion}
+}
+
+// simulationPrefix - beginning of streamed simulation export, records and "]}" follow
+func simulationPrefix() string {
+	return fmt.Sprintf(`{"meta":{"schemaVersion":%d},"data":[`, SimulationSchemaVersion)
+}
+
+// simulationMigration - upgrades simulation decoded into generic JSON to the next schema version
+type simulationMigration func(simulation map[string]interface{}) error
+
+// simulationMigrations - migrations by the version they upgrade from, every version below the current one needs
+// its migration
+var simulationMigrations = map[int]simulationMigration{
+	1: migrateSimulationV1,
+}
+
+// decodeSimulation - decodes exported simulation, older schema versions are migrated first. Simulations of newer
+// versions are refused instead of losing what this version doesn't know
+func decodeSimulation(data []byte) ([]Payload, error) {
+	var header struct {
+		Meta *simulationMeta `json:"meta"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	version := 1
+	if header.Meta != nil {
+		version = header.Meta.SchemaVersion
+	}
+
+	switch {
+	case version == SimulationSchemaVersion:
+		var requests recordedRequests
+		err := json.Unmarshal(data, &requests)
+		return requests.Data, err
+	case version > SimulationSchemaVersion:
+		return nil, fmt.Errorf("simulation schema version %d is newer than version %d supported by this Hoverfly, upgrade Hoverfly to import it",
+			version, SimulationSchemaVersion)
+	case version < 1:
+		return nil, fmt.Errorf("invalid simulation schema version %d", version)
+	}
+
+	var simulation map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// numbers are kept as they were written
+	decoder.UseNumber()
+	if err := decoder.Decode(&simulation); err != nil {
+		return nil, err
+	}
+	for ; version < SimulationSchemaVersion; version++ {
+		if err := simulationMigrations[version](simulation); err != nil {
+			return nil, fmt.Errorf("failed to migrate simulation from schema version %d: %s", version, err.Error())
+		}
+	}
+	simulation["meta"] = currentSimulationMeta()
+
+	migrated, err := json.Marshal(simulation)
+	if err != nil {
+		return nil, err
+	}
+	var requests recordedRequests
+	err = json.Unmarshal(migrated, &requests)
+	return requests.Data, err
+}
+
+// migrateSimulationV1 - upgrades unversioned simulations. They were often written by hand or by other tools, so
+// values Hoverfly never exported but which are meant the same way are accepted: header values given as a single
+// string, status codes given as strings and queries starting with "?"
+func migrateSimulationV1(simulation map[string]interface{}) error {
+	records, ok := simulation["data"].([]interface{})
+	if !ok {
+		if simulation["data"] != nil {
+			return fmt.Errorf("'data' is not a list of records")
+		}
+		return nil
+	}
+
+	for i, value := range records {
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("record %d is not an object", i)
+		}
+
+		if request, ok := record["request"].(map[string]interface{}); ok {
+			listHeaderValues(request, "headers")
+			if query, ok := request["query"].(string); ok {
+				request["query"] = strings.TrimPrefix(query, "?")
+			}
+		}
+
+		responses := []interface{}{record["response"]}
+		if sequence, ok := record["sequence"].([]interface{}); ok {
+			responses = append(responses, sequence...)
+		}
+		for _, value := range responses {
+			response, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			listHeaderValues(response, "headers")
+			listHeaderValues(response, "trailers")
+			if status, ok := response["status"].(string); ok {
+				code, err := strconv.Atoi(status)
+				if err != nil {
+					return fmt.Errorf("record %d has invalid status '%s'", i, status)
+				}
+				response["status"] = code
+			}
+		}
+	}
+	return nil
+}
+
+// listHeaderValues - replaces single string header values of given field with lists
+func listHeaderValues(fields map[string]interface{}, field string) {
+	headers, ok := fields[field].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for name, value := range headers {
+		if s, ok := value.(string); ok {
+			headers[name] = []string{s}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCurrentSimulation(t *testing.T) {
+	data, _ := json.Marshal(recordedRequests{Meta: currentSimulationMeta(), Data: []Payload{
+		{Request: RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/users"},
+			Response: ResponseDetails{Status: 200, Body: "users"}},
+	}})
+
+	payloads, err := decodeSimulation(data)
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Response.Body, "users")
+}
+
+func TestDecodeUnversionedSimulation(t *testing.T) {
+	simulation := `{"data": [{
+		"request": {"method": "GET", "destination": "api.example.com", "path": "/users", "query": "?page=2",
+			"headers": {"Accept": "application/json"}},
+		"response": {"status": "201", "body": "users", "headers": {"Content-Type": "application/json",
+			"Set-Cookie": ["a=1", "b=2"]}},
+		"sequence": [{"status": 200, "body": "again", "headers": {"X-Call": "2"}}]
+	}]}`
+
+	payloads, err := decodeSimulation([]byte(simulation))
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Query, "page=2")
+	expect(t, payloads[0].Request.Headers["Accept"][0], "application/json")
+	expect(t, payloads[0].Response.Status, 201)
+	expect(t, payloads[0].Response.Headers["Content-Type"][0], "application/json")
+	expect(t, len(payloads[0].Response.Headers["Set-Cookie"]), 2)
+	expect(t, payloads[0].Sequence[0].Headers["X-Call"][0], "2")
+
+	// exports of previous versions decode as they are
+	payloads, err = decodeSimulation([]byte(`{"data": [{"request": {"path": "/"}, "response": {"status": 200}}]}`))
+	expect(t, err, nil)
+	expect(t, payloads[0].Response.Status, 200)
+
+	_, err = decodeSimulation([]byte(`{"data": [{"request": {}, "response": {"status": "OK"}}]}`))
+	refute(t, err, nil)
+}
+
+func TestDecodeNewerSimulation(t *testing.T) {
+	_, err := decodeSimulation([]byte(`{"meta": {"schemaVersion": 99}, "data": []}`))
+	refute(t, err, nil)
+	expect(t, strings.Contains(err.Error(), "upgrade Hoverfly"), true)
+
+	_, err = decodeSimulation([]byte(`{"meta": {"schemaVersion": 0}, "data": []}`))
+	refute(t, err, nil)
+}
+
+func TestSimulationMigrationsCoverAllVersions(t *testing.T) {
+	for version := 1; version < SimulationSchemaVersion; version++ {
+		_, ok := simulationMigrations[version]
+		expect(t, ok, true)
+	}
+}
@@ -0,0 +1,136 @@
+package hoverfly
+
+import (
+	"math/rand"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Strategies of choosing one of several responses to the same request
+const (
+	// SelectSequence - responses are returned in order, the last one is repeated
+	SelectSequence = "sequence"
+	// SelectRandom - every response has the same chance
+	SelectRandom = "random"
+	// SelectRoundRobin - responses are returned in order, starting from the first one again after the last one
+	SelectRoundRobin = "round-robin"
+	// SelectWeighted - responses are chosen randomly by their weights
+	SelectWeighted = "weighted"
+)
+
+// IsResponseSelection - checks whether strategy of choosing responses is known
+func IsResponseSelection(selection string) bool {
+	switch selection {
+	case SelectSequence, SelectRandom, SelectRoundRobin, SelectWeighted:
+		return true
+	}
+	return false
+}
+
+// Sequences - counts calls of requests with response sequences, so every call gets the next response
+type Sequences struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// NewSequences - returns sequences with no calls made
+func NewSequences() *Sequences {
+	return &Sequences{calls: make(map[string]int)}
+}
+
+// Next - counts call of request with given key, returns number of calls made before this one
+func (s *Sequences) Next(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.calls[key]
+	s.calls[key]++
+	return n
+}
+
+// Get - returns number of calls made to every request with response sequence
+func (s *Sequences) Get() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := make(map[string]int, len(s.calls))
+	for key, n := range s.calls {
+		calls[key] = n
+	}
+	return calls
+}
+
+// Reset - starts all sequences from their first response again
+func (s *Sequences) Reset() {
+	s.mu.Lock()
+	s.calls = make(map[string]int)
+	s.mu.Unlock()
+}
+
+// sequenceResponse - returns response to call after given number of previous calls
+func (p *Payload) sequenceResponse(calls int) ResponseDetails {
+	if calls == 0 || len(p.Sequence) == 0 {
+		return p.Response
+	}
+	if calls > len(p.Sequence) {
+		calls = len(p.Sequence)
+	}
+	return p.Sequence[calls-1]
+}
+
+// selectResponse - returns response chosen by given strategy to call after given number of previous calls
+func (p *Payload) selectResponse(selection string, calls int) ResponseDetails {
+	responses := append([]ResponseDetails{p.Response}, p.Sequence...)
+
+	switch selection {
+	case SelectRandom:
+		return responses[rand.Intn(len(responses))]
+	case SelectRoundRobin:
+		return responses[calls%len(responses)]
+	case SelectWeighted:
+		total := 0
+		for _, response := range responses {
+			total += response.weight()
+		}
+		n := rand.Intn(total)
+		for _, response := range responses {
+			if n -= response.weight(); n < 0 {
+				return response
+			}
+		}
+	}
+	return p.sequenceResponse(calls)
+}
+
+// weight - returns weight of response for SelectWeighted strategy
+func (r ResponseDetails) weight() int {
+	if r.Weight <= 0 {
+		return 1
+	}
+	return r.Weight
+}
+
+// appendToSequence - adds captured response to the sequence of already captured request, payload is returned
+// unchanged when request wasn't captured yet
+func (d *DBClient) appendToSequence(key string, payload Payload) Payload {
+	payloadBts, err := d.Cache.Get([]byte(key))
+	if err != nil {
+		return payload
+	}
+
+	existing, err := decodePayload(payloadBts)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Error("Failed to decode captured payload, replacing it")
+		return payload
+	}
+
+	existing.Sequence = append(existing.Sequence, payload.Response)
+	log.WithFields(log.Fields{
+		"key":      key,
+		"path":     payload.Request.Path,
+		"sequence": len(existing.Sequence) + 1,
+	}).Debug("Response added to sequence")
+	return *existing
+}
@@ -0,0 +1,185 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// pollingPayload - job status request answered with 202 first and with 200 afterwards
+func pollingPayload() Payload {
+	return Payload{
+		Request:  RequestDetails{Method: "GET", Scheme: "http", Destination: "api.example.com", Path: "/jobs/1"},
+		Response: ResponseDetails{Status: 202, Body: "pending"},
+		Sequence: []ResponseDetails{
+			{Status: 202, Body: "running"},
+			{Status: 200, Body: "done"},
+		},
+	}
+}
+
+// pollJob - returns status and body of job status response
+func pollJob(t *testing.T, dbClient *DBClient) (int, string) {
+	req, _ := http.NewRequest("GET", "http://api.example.com/jobs/1", nil)
+	_, resp := dbClient.processRequest(req)
+	body, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	return resp.StatusCode, string(body)
+}
+
+func TestSequenceReplayedInOrder(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	expect(t, dbClient.ImportPayloads([]Payload{pollingPayload()}), nil)
+
+	status, body := pollJob(t, dbClient)
+	expect(t, status, 202)
+	expect(t, body, "pending")
+
+	status, body = pollJob(t, dbClient)
+	expect(t, status, 202)
+	expect(t, body, "running")
+
+	// last response is repeated
+	for i := 0; i < 2; i++ {
+		status, body = pollJob(t, dbClient)
+		expect(t, status, 200)
+		expect(t, body, "done")
+	}
+
+	calls := dbClient.Sequences.Get()
+	expect(t, len(calls), 1)
+	for _, n := range calls {
+		expect(t, n, 4)
+	}
+
+	dbClient.Sequences.Reset()
+	status, body = pollJob(t, dbClient)
+	expect(t, status, 202)
+	expect(t, body, "pending")
+}
+
+func TestCaptureSequences(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.CaptureSequences = true
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/jobs/1", "", 202, "pending")
+	storeTestPayload(dbClient, "GET", "http://api.example.com/jobs/1", "", 200, "done")
+
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Response.Status, 202)
+	expect(t, len(payloads[0].Sequence), 1)
+	expect(t, payloads[0].Sequence[0].Body, "done")
+
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	status, _ := pollJob(t, dbClient)
+	expect(t, status, 202)
+	status, body := pollJob(t, dbClient)
+	expect(t, status, 200)
+	expect(t, body, "done")
+}
+
+func TestCaptureReplacesResponseWithoutSequences(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/jobs/1", "", 202, "pending")
+	storeTestPayload(dbClient, "GET", "http://api.example.com/jobs/1", "", 200, "done")
+
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Response.Status, 200)
+	expect(t, len(payloads[0].Sequence), 0)
+}
+
+func TestSettingsCaptureSequencesEnv(t *testing.T) {
+	defer os.Setenv("HoverflyCaptureSequences", "")
+	os.Setenv("HoverflyCaptureSequences", "true")
+	expect(t, InitSettings().CaptureSequences, true)
+}
+
+func TestRoundRobinSelection(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	payload := pollingPayload()
+	payload.Selection = SelectRoundRobin
+	expect(t, dbClient.ImportPayloads([]Payload{payload}), nil)
+
+	for _, expected := range []string{"pending", "running", "done", "pending", "running"} {
+		_, body := pollJob(t, dbClient)
+		expect(t, body, expected)
+	}
+}
+
+func TestDefaultResponseSelection(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	dbClient.Cfg.ResponseSelection = SelectRoundRobin
+
+	expect(t, dbClient.ImportPayloads([]Payload{pollingPayload()}), nil)
+	for _, expected := range []string{"pending", "running", "done", "pending"} {
+		_, body := pollJob(t, dbClient)
+		expect(t, body, expected)
+	}
+}
+
+func TestRandomSelection(t *testing.T) {
+	payload := pollingPayload()
+	seen := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		seen[payload.selectResponse(SelectRandom, i).Body]++
+	}
+	expect(t, len(seen), 3)
+}
+
+func TestWeightedSelection(t *testing.T) {
+	payload := Payload{
+		Response: ResponseDetails{Status: 200, Body: "ok", Weight: 9},
+		Sequence: []ResponseDetails{{Status: 500, Body: "error"}},
+	}
+
+	seen := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		seen[payload.selectResponse(SelectWeighted, i).Body]++
+	}
+	expect(t, seen["ok"]+seen["error"], 1000)
+	expect(t, seen["ok"] > 800 && seen["ok"] < 980, true)
+}
+
+func TestImportRejectsUnknownSelection(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	payload := pollingPayload()
+	payload.Selection = "best"
+	dbClient.ImportPayloads([]Payload{payload})
+
+	count, err := dbClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 0)
+}
+
+func TestSettingsResponseSelectionEnv(t *testing.T) {
+	defer os.Setenv("HoverflyResponseSelection", "")
+
+	os.Setenv("HoverflyResponseSelection", "weighted")
+	expect(t, InitSettings().GetResponseSelection(), SelectWeighted)
+
+	os.Setenv("HoverflyResponseSelection", "best")
+	expect(t, InitSettings().GetResponseSelection(), SelectSequence)
+}
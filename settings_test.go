@@ -3,6 +3,7 @@ package hoverfly
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestSettingsAdminPortEnv(t *testing.T) {
@@ -35,6 +36,34 @@ func TestSettingsDefaultProxyPort(t *testing.T) {
 	expect(t, cfg.ProxyPort, DefaultPort)
 }
 
+func TestSettingsListenAddresses(t *testing.T) {
+	cfg := InitSettings()
+	expect(t, cfg.AdminListenAddress(), ":"+DefaultAdminPort)
+	expect(t, cfg.ProxyListenAddress(), ":"+DefaultPort)
+
+	cfg.AdminBindAddress = "127.0.0.1"
+	cfg.ProxyBindAddress = "::1"
+	expect(t, cfg.AdminListenAddress(), "127.0.0.1:"+DefaultAdminPort)
+	expect(t, cfg.ProxyListenAddress(), "[::1]:"+DefaultPort)
+}
+
+func TestSettingsListenersEnv(t *testing.T) {
+	defer os.Setenv("HoverflyAdminAddress", "")
+	defer os.Setenv("HoverflyProxyAddress", "")
+	defer os.Setenv("HoverflyDisableAdmin", "")
+	defer os.Setenv("HoverflyDisableProxy", "")
+
+	os.Setenv("HoverflyAdminAddress", "localhost")
+	os.Setenv("HoverflyProxyAddress", "0.0.0.0")
+	os.Setenv("HoverflyDisableAdmin", "true")
+	cfg := InitSettings()
+
+	expect(t, cfg.AdminListenAddress(), "localhost:"+DefaultAdminPort)
+	expect(t, cfg.ProxyListenAddress(), "0.0.0.0:"+DefaultPort)
+	expect(t, cfg.AdminDisabled, true)
+	expect(t, cfg.ProxyDisabled, false)
+}
+
 func TestSettingsDatabaseEnv(t *testing.T) {
 	defer os.Setenv("HoverflyDB", "")
 
@@ -44,6 +73,43 @@ func TestSettingsDatabaseEnv(t *testing.T) {
 	expect(t, cfg.DatabaseName, "testingX.db")
 }
 
+func TestSettingsDefaultDatabaseType(t *testing.T) {
+	os.Setenv("HoverflyDBType", "")
+	cfg := InitSettings()
+
+	expect(t, cfg.DatabaseType, BoltDBBackend)
+}
+
+func TestSettingsRedisEnv(t *testing.T) {
+	defer os.Setenv("HoverflyDBType", "")
+	defer os.Setenv("HoverflyRedisAddress", "")
+
+	os.Setenv("HoverflyDBType", "redis")
+	os.Setenv("HoverflyRedisAddress", "10.0.0.1:6379")
+	cfg := InitSettings()
+
+	expect(t, cfg.DatabaseType, RedisBackend)
+	expect(t, cfg.RedisAddress, "10.0.0.1:6379")
+}
+
+func TestSettingsRecordTTLEnv(t *testing.T) {
+	defer os.Setenv("HoverflyRecordTTL", "")
+
+	os.Setenv("HoverflyRecordTTL", "90m")
+	cfg := InitSettings()
+
+	expect(t, cfg.RecordTTL, 90*time.Minute)
+}
+
+func TestSettingsMaxRecordsEnv(t *testing.T) {
+	defer os.Setenv("HoverflyMaxRecords", "")
+
+	os.Setenv("HoverflyMaxRecords", "1000")
+	cfg := InitSettings()
+
+	expect(t, cfg.MaxRecords, 1000)
+}
+
 func TestSettingsMiddlewareEnv(t *testing.T) {
 	defer os.Setenv("HoverflyMiddleware", "")
 
@@ -69,3 +135,206 @@ func TestGetMode(t *testing.T) {
 
 	expect(t, cfg.GetMode(), "capture")
 }
+
+func TestSettingsAutosaveEnv(t *testing.T) {
+	defer os.Setenv("HoverflyAutosave", "")
+	defer os.Setenv("HoverflyAutosaveInterval", "")
+
+	os.Setenv("HoverflyAutosave", "simulation.json")
+	os.Setenv("HoverflyAutosaveInterval", "10s")
+	cfg := InitSettings()
+	expect(t, cfg.AutosaveFile, "simulation.json")
+	expect(t, cfg.AutosaveInterval, 10*time.Second)
+
+	// ticker can't be created with interval that isn't positive
+	for _, interval := range []string{"0s", "-1s"} {
+		os.Setenv("HoverflyAutosaveInterval", interval)
+		expect(t, InitSettings().AutosaveInterval, DefaultAutosaveInterval)
+	}
+}
+
+func TestSettingsS3Env(t *testing.T) {
+	defer os.Setenv("HoverflyS3Bucket", "")
+	defer os.Setenv("HoverflyS3SyncInterval", "")
+
+	os.Setenv("HoverflyS3Bucket", "simulations")
+	os.Setenv("HoverflyS3SyncInterval", "30s")
+
+	cfg := InitSettings()
+	expect(t, cfg.S3Bucket, "simulations")
+	expect(t, cfg.S3Key, DefaultS3Key)
+	expect(t, cfg.S3SyncInterval, 30*time.Second)
+
+	os.Setenv("HoverflyS3SyncInterval", "0s")
+	expect(t, InitSettings().S3SyncInterval, DefaultS3SyncInterval)
+}
+
+func TestSettingsImportEnv(t *testing.T) {
+	defer os.Setenv("HoverflyImport", "")
+
+	os.Setenv("HoverflyImport", "first.json, http://example.com/second.json,")
+
+	cfg := InitSettings()
+	expect(t, len(cfg.Imports), 2)
+	expect(t, cfg.Imports[0], "first.json")
+	expect(t, cfg.Imports[1], "http://example.com/second.json")
+}
+
+func TestSettingsMissStatusEnv(t *testing.T) {
+	defer os.Setenv("HoverflyMissStatus", "")
+
+	os.Setenv("HoverflyMissStatus", "502")
+	expect(t, InitSettings().GetMissStatus(), 502)
+
+	// only client and server errors are accepted
+	os.Setenv("HoverflyMissStatus", "200")
+	expect(t, InitSettings().GetMissStatus(), DefaultMissStatus)
+}
+
+func TestSettingsMatchDebugEnv(t *testing.T) {
+	defer os.Setenv("HoverflyMatchDebug", "")
+
+	expect(t, InitSettings().MatchDebug, false)
+
+	os.Setenv("HoverflyMatchDebug", "true")
+	expect(t, InitSettings().MatchDebug, true)
+}
+
+func TestSettingsMissDebugEnv(t *testing.T) {
+	defer os.Setenv("HoverflyMissDebug", "")
+
+	expect(t, InitSettings().MissDebug, false)
+
+	os.Setenv("HoverflyMissDebug", "true")
+	expect(t, InitSettings().MissDebug, true)
+}
+
+func TestSettingsDiffEnv(t *testing.T) {
+	defer os.Setenv("HoverflyDiffIgnoreHeaders", "")
+	defer os.Setenv("HoverflyDiffIgnoreBody", "")
+
+	cfg := InitSettings()
+	expect(t, len(cfg.DiffIgnoreHeaders), 1)
+	expect(t, cfg.DiffIgnoreHeaders[0], "Date")
+	expect(t, cfg.DiffIgnoreBody, false)
+
+	os.Setenv("HoverflyDiffIgnoreHeaders", "Date, X-Request-Id")
+	os.Setenv("HoverflyDiffIgnoreBody", "true")
+
+	cfg = InitSettings()
+	expect(t, len(cfg.DiffIgnoreHeaders), 2)
+	expect(t, cfg.DiffIgnoreHeaders[1], "X-Request-Id")
+	expect(t, cfg.DiffIgnoreBody, true)
+}
+
+func TestSetDestination(t *testing.T) {
+	cfg := InitSettings()
+
+	expect(t, cfg.SetDestination(`^api\.example\.com$`), nil)
+	expect(t, cfg.GetDestination(), `^api\.example\.com$`)
+	expect(t, cfg.MatchesDestination("api.example.com"), true)
+	expect(t, cfg.MatchesDestination("cdn.example.com"), false)
+
+	// invalid expression leaves current destination in place
+	refute(t, cfg.SetDestination("[invalid"), nil)
+	expect(t, cfg.GetDestination(), `^api\.example\.com$`)
+}
+
+func TestMatchesDestinationAssigned(t *testing.T) {
+	cfg := InitSettings()
+
+	cfg.Destination = "example.com"
+	expect(t, cfg.MatchesDestination("example.com"), true)
+
+	cfg.Destination = "other.com"
+	expect(t, cfg.MatchesDestination("example.com"), false)
+}
+
+func TestPassthrough(t *testing.T) {
+	cfg := InitSettings()
+
+	cfg.AddPassthrough("Auth.Example.com", "cdn.example.com:443", " ")
+	expect(t, len(cfg.GetPassthrough()), 2)
+	expect(t, cfg.GetPassthrough()[0], "auth.example.com")
+
+	// ports are ignored
+	expect(t, cfg.IsPassthrough("auth.example.com:443"), true)
+	expect(t, cfg.IsPassthrough("cdn.example.com"), true)
+	expect(t, cfg.IsPassthrough("api.example.com"), false)
+
+	expect(t, cfg.RemovePassthrough("cdn.example.com"), true)
+	expect(t, cfg.RemovePassthrough("cdn.example.com"), false)
+	expect(t, cfg.IsPassthrough("cdn.example.com"), false)
+}
+
+func TestSettingsPassthroughEnv(t *testing.T) {
+	defer os.Setenv("HoverflyPassthrough", "")
+
+	os.Setenv("HoverflyPassthrough", "auth.example.com, cdn.example.com")
+
+	cfg := InitSettings()
+	expect(t, cfg.IsPassthrough("auth.example.com"), true)
+	expect(t, cfg.IsPassthrough("cdn.example.com"), true)
+}
+
+func TestSettingsMiddlewareFailureEnv(t *testing.T) {
+	defer os.Setenv("HoverflyMiddlewareFailure", "")
+	defer os.Setenv("HoverflyMiddlewareTimeout", "")
+	defer os.Setenv("HoverflyMiddlewarePattern", "")
+
+	cfg := InitSettings()
+	expect(t, cfg.MiddlewareFailure, "")
+	expect(t, cfg.MiddlewareTimeout, time.Duration(0))
+	expect(t, cfg.MiddlewarePattern, "")
+
+	os.Setenv("HoverflyMiddlewareFailure", "open")
+	os.Setenv("HoverflyMiddlewareTimeout", "2s")
+	os.Setenv("HoverflyMiddlewarePattern", "payments.example.com")
+	cfg = InitSettings()
+	expect(t, cfg.MiddlewareFailure, MiddlewareFailOpen)
+	expect(t, cfg.MiddlewareTimeout, 2*time.Second)
+	expect(t, cfg.MiddlewarePattern, "payments.example.com")
+
+	os.Setenv("HoverflyMiddlewareFailure", "closed")
+	expect(t, InitSettings().MiddlewareFailure, MiddlewareFailClosed)
+}
+
+func TestSettingsJournalSizeEnv(t *testing.T) {
+	defer os.Setenv("HoverflyJournalSize", "")
+
+	expect(t, InitSettings().JournalSize, DefaultJournalSize)
+
+	os.Setenv("HoverflyJournalSize", "50")
+	expect(t, InitSettings().JournalSize, 50)
+
+	// 0 disables the journal
+	os.Setenv("HoverflyJournalSize", "0")
+	expect(t, InitSettings().JournalSize, 0)
+}
+
+func TestSettingsLogSinksEnv(t *testing.T) {
+	defer os.Setenv("HoverflyLogFile", "")
+	defer os.Setenv("HoverflyLogFileMaxBackups", "")
+	defer os.Setenv("HoverflySyslog", "")
+	defer os.Setenv("HoverflyLogLevels", "")
+
+	cfg := InitSettings()
+	expect(t, cfg.LogFormat, LogFormatJSON)
+	expect(t, len(cfg.LogSinks()), 1)
+	expect(t, cfg.LogSinks()[0].Type, LogSinkStderr)
+
+	os.Setenv("HoverflyLogFile", "/var/log/hoverfly.log")
+	os.Setenv("HoverflyLogFileMaxBackups", "0")
+	os.Setenv("HoverflySyslog", "true")
+	os.Setenv("HoverflyLogLevels", "cache=debug")
+
+	cfg = InitSettings()
+	expect(t, cfg.LogLevels, "cache=debug")
+	sinks := cfg.LogSinks()
+	expect(t, len(sinks), 3)
+	expect(t, sinks[1].Type, LogSinkFile)
+	expect(t, sinks[1].Path, "/var/log/hoverfly.log")
+	expect(t, sinks[1].MaxSize, DefaultLogFileMaxSize)
+	expect(t, sinks[1].MaxBackups, 0)
+	expect(t, sinks[2].Type, LogSinkSyslog)
+}
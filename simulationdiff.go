@@ -0,0 +1,197 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// SimulationDiff - differences between current and other simulation, records are paired by their ID (request hash).
+// Added records are only in the other simulation, removed ones only in the current one
+type SimulationDiff struct {
+	Added     []Payload    `json:"added"`
+	Removed   []Payload    `json:"removed"`
+	Changed   []RecordDiff `json:"changed"`
+	Unchanged int          `json:"unchanged"`
+}
+
+// RecordDiff - differences between current and other version of the same record, expected values are the current
+// ones and actual values the other ones
+type RecordDiff struct {
+	Key         string      `json:"key"`
+	Destination string      `json:"destination"`
+	Path        string      `json:"path"`
+	Method      string      `json:"method"`
+	Query       string      `json:"query"`
+	Differences []FieldDiff `json:"differences"`
+}
+
+// DiffSimulations - compares current simulation with other one and reports added, removed and changed records.
+// Responses are compared like in diff mode (JSON bodies by value), the rest of the records field by field. Request
+// details that aren't part of the ID (i.e. remote address) and capture time are not compared
+func (d *DBClient) DiffSimulations(current, other []Payload) SimulationDiff {
+	diff := SimulationDiff{Added: []Payload{}, Removed: []Payload{}, Changed: []RecordDiff{}}
+
+	others := make(map[string]Payload, len(other))
+	for _, pl := range other {
+		others[d.recordKey(pl)] = pl
+	}
+
+	seen := make(map[string]bool, len(current))
+	for _, pl := range current {
+		key := d.recordKey(pl)
+		seen[key] = true
+
+		changed, ok := others[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, pl)
+			continue
+		}
+		differences := compareRecords(pl, changed)
+		if len(differences) == 0 {
+			diff.Unchanged++
+			continue
+		}
+		diff.Changed = append(diff.Changed, RecordDiff{
+			Key:         key,
+			Destination: pl.Request.Destination,
+			Path:        pl.Request.Path,
+			Method:      pl.Request.Method,
+			Query:       pl.Request.Query,
+			Differences: differences,
+		})
+	}
+
+	for _, pl := range other {
+		key := d.recordKey(pl)
+		if !seen[key] {
+			// the same record may be in the other simulation more than once, the last one wins on import
+			seen[key] = true
+			diff.Added = append(diff.Added, others[key])
+		}
+	}
+	return diff
+}
+
+// DiffCachedSimulation - compares records in the cache with given simulation
+func (d *DBClient) DiffCachedSimulation(other []Payload) (SimulationDiff, error) {
+	current, err := d.Cache.GetAllRequests()
+	if err != nil {
+		return SimulationDiff{}, err
+	}
+	return d.DiffSimulations(current, other), nil
+}
+
+// compareRecords - returns differences between two versions of the same record
+func compareRecords(current, other Payload) []FieldDiff {
+	diffs := compareResponseDetails("", current.Response, other.Response)
+
+	for i := 0; i < len(current.Sequence) || i < len(other.Sequence); i++ {
+		field := fmt.Sprintf("sequence[%d]", i)
+		switch {
+		case i >= len(current.Sequence):
+			diffs = append(diffs, FieldDiff{Field: field, Actual: diffValue(other.Sequence[i])})
+		case i >= len(other.Sequence):
+			diffs = append(diffs, FieldDiff{Field: field, Expected: diffValue(current.Sequence[i])})
+		default:
+			diffs = append(diffs, compareResponseDetails(field+".", current.Sequence[i], other.Sequence[i])...)
+		}
+	}
+
+	// fields compared above or not compared at all
+	for _, pl := range []*Payload{&current, &other} {
+		pl.Request, pl.Response, pl.Sequence = RequestDetails{}, ResponseDetails{}, nil
+		pl.ID, pl.CapturedAt = "", nil
+	}
+	return append(diffs, compareFields("", current, other)...)
+}
+
+// compareResponseDetails - returns differences between responses, status, headers and body are compared like in
+// diff mode, other fields by value. Field names get given prefix
+func compareResponseDetails(prefix string, current, other ResponseDetails) []FieldDiff {
+	diffs := CompareResponses(current, other, DiffOptions{})
+	for i := range diffs {
+		diffs[i].Field = prefix + diffs[i].Field
+	}
+
+	for _, response := range []*ResponseDetails{&current, &other} {
+		response.Status, response.Body, response.Headers = 0, "", nil
+	}
+	return append(diffs, compareFields(prefix, current, other)...)
+}
+
+// compareFields - compares values field by field by their JSON representation, field names are JSON ones with given
+// prefix
+func compareFields(prefix string, current, other interface{}) []FieldDiff {
+	currentFields, otherFields := jsonFields(current), jsonFields(other)
+
+	names := make([]string, 0, len(currentFields)+len(otherFields))
+	for name := range currentFields {
+		names = append(names, name)
+	}
+	for name := range otherFields {
+		if _, ok := currentFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []FieldDiff
+	for _, name := range names {
+		if !reflect.DeepEqual(currentFields[name], otherFields[name]) {
+			diffs = append(diffs, FieldDiff{
+				Field:    prefix + name,
+				Expected: diffValue(currentFields[name]),
+				Actual:   diffValue(otherFields[name]),
+			})
+		}
+	}
+	return diffs
+}
+
+// jsonFields - returns fields of JSON representation of value, empty fields are left out
+func jsonFields(v interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if b, err := json.Marshal(v); err == nil {
+		json.Unmarshal(b, &fields)
+	}
+	for name, value := range fields {
+		if isEmptyJSON(value) {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+// isEmptyJSON - checks whether decoded JSON value is null, zero, false or empty
+func isEmptyJSON(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !value
+	case float64:
+		return value == 0
+	case string:
+		return value == ""
+	case []interface{}:
+		return len(value) == 0
+	case map[string]interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+// diffValue - returns value as it's shown in differences, strings as they are and other values as JSON, missing
+// values are empty
+func diffValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,78 @@
+package hoverfly
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffSimulations(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+
+	record := func(path, body string) Payload {
+		return Payload{
+			Request: RequestDetails{Method: "GET", Destination: "api.example.com", Path: path},
+			Response: ResponseDetails{Status: 200, Body: body,
+				Headers: map[string][]string{"Content-Type": {"application/json"}}},
+		}
+	}
+
+	capturedAt := time.Now()
+	unchanged := record("/health", `{"ok": true}`)
+	unchanged.CapturedAt = &capturedAt
+	// formatting of JSON bodies and remote address don't matter
+	reformatted := record("/health", `{ "ok":true }`)
+	reformatted.Request.RemoteAddr = "10.0.0.1:5000"
+
+	changed := record("/users", `[{"id": 1}]`)
+	changed.Sequence = []ResponseDetails{{Status: 200, Body: "second"}}
+	changedOther := record("/users", `[{"id": 2}]`)
+	changedOther.Response.Status = 201
+	changedOther.Sequence = []ResponseDetails{{Status: 500, Body: "second"}, {Status: 200, Body: "third"}}
+	changedOther.Selection = SelectRoundRobin
+
+	diff := dbClient.DiffSimulations(
+		[]Payload{unchanged, changed, record("/orders", "orders")},
+		[]Payload{reformatted, changedOther, record("/items", "items")},
+	)
+
+	expect(t, diff.Unchanged, 1)
+	expect(t, len(diff.Removed), 1)
+	expect(t, diff.Removed[0].Request.Path, "/orders")
+	expect(t, len(diff.Added), 1)
+	expect(t, diff.Added[0].Request.Path, "/items")
+
+	expect(t, len(diff.Changed), 1)
+	expect(t, diff.Changed[0].Path, "/users")
+	expect(t, diff.Changed[0].Key, dbClient.recordKey(changed))
+
+	fields := make(map[string]FieldDiff)
+	for _, difference := range diff.Changed[0].Differences {
+		fields[difference.Field] = difference
+	}
+	expect(t, len(fields), 5)
+	expect(t, fields["status"].Expected, "200")
+	expect(t, fields["status"].Actual, "201")
+	expect(t, fields["body"].Actual, `[{"id": 2}]`)
+	expect(t, fields["sequence[0].status"].Actual, "500")
+	expect(t, fields["sequence[1]"].Expected, "")
+	expect(t, fields["selection"].Actual, SelectRoundRobin)
+}
+
+func TestDiffCachedSimulation(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "users")
+	cached, _ := dbClient.Cache.GetAllRequests()
+
+	diff, err := dbClient.DiffCachedSimulation(cached)
+	expect(t, err, nil)
+	expect(t, diff.Unchanged, 1)
+	expect(t, len(diff.Changed)+len(diff.Added)+len(diff.Removed), 0)
+
+	diff, err = dbClient.DiffCachedSimulation(nil)
+	expect(t, err, nil)
+	expect(t, len(diff.Removed), 1)
+}
@@ -0,0 +1,52 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// spyRequest - serves captured response when request was recorded, otherwise request is passed through to the
+// real destination and, when SpyCapture is enabled, the new request/response pair is captured
+func (d *DBClient) spyRequest(req *http.Request) (*http.Response, error) {
+	if req.Body == nil {
+		req.Body = ioutil.NopCloser(bytes.NewBuffer([]byte("")))
+	}
+
+	reqBody, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"mode":  SpyMode,
+		}).Error("Got error when reading request body")
+	}
+
+	key := d.requestFingerprint(req, reqBody)
+
+	if payloadBts, err := d.Cache.Get([]byte(key)); err == nil {
+		req.Body = ioutil.NopCloser(bytes.NewBuffer(reqBody))
+		return d.cachedResponse(req, key, payloadBts, SpyMode), nil
+	}
+	if matchedReq, matchedKey, payloadBts, ok := d.matcherRecord(req, reqBody); ok {
+		matchedReq.Body = ioutil.NopCloser(bytes.NewBuffer(reqBody))
+		return d.cachedResponse(matchedReq, matchedKey, payloadBts, SpyMode), nil
+	}
+
+	log.WithFields(log.Fields{
+		"key":         key,
+		"path":        req.URL.Path,
+		"destination": req.Host,
+		"method":      req.Method,
+		"capture":     d.Cfg.SpyCapture,
+	}).Debug("Request not recorded, passing it through")
+	markJournalMatch(req, false, "")
+
+	req.Body = ioutil.NopCloser(bytes.NewBuffer(reqBody))
+
+	if d.Cfg.SpyCapture {
+		return d.captureRequest(req)
+	}
+	return d.doRequest(req)
+}
@@ -0,0 +1,80 @@
+package hoverfly
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSpyRequestServesRecorded(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer dbClient.Cache.DeleteData()
+
+	r, err := http.NewRequest("GET", "http://somehost.com", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode("capture")
+	dbClient.processRequest(r)
+
+	// destination goes down, recorded response should still be returned
+	server.Close()
+
+	r, err = http.NewRequest("GET", "http://somehost.com", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode("spy")
+	_, resp := dbClient.processRequest(r)
+
+	expect(t, resp.StatusCode, 201)
+}
+
+func TestSpyRequestPassesThrough(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	r, err := http.NewRequest("GET", "http://somehost.com", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode("spy")
+	_, resp := dbClient.processRequest(r)
+
+	expect(t, resp.StatusCode, 201)
+
+	// nothing should be captured by default
+	count, err := dbClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 0)
+}
+
+func TestSpyRequestCapturesPassedThrough(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	r, err := http.NewRequest("GET", "http://somehost.com", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SpyCapture = true
+	dbClient.Cfg.SetMode("spy")
+	_, resp := dbClient.processRequest(r)
+
+	expect(t, resp.StatusCode, 201)
+
+	count, err := dbClient.Cache.RecordsCount()
+	expect(t, err, nil)
+	expect(t, count, 1)
+}
+
+func TestSpyRequestDestinationDown(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer dbClient.Cache.DeleteData()
+	server.Close()
+
+	r, err := http.NewRequest("GET", "http://somehost.com", nil)
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode("spy")
+	_, resp := dbClient.processRequest(r)
+
+	expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+}
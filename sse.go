@@ -0,0 +1,113 @@
+package hoverfly
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ServerSentEvent - single event of captured text/event-stream response
+type ServerSentEvent struct {
+	// Delay - milliseconds between previous event (or start of the response) and this event
+	Delay int64 `json:"delay"`
+	// Data - raw event, including the blank line that terminates it
+	Data string `json:"data"`
+}
+
+// isEventStream - checks whether response is a stream of server-sent events
+func isEventStream(header http.Header) bool {
+	return strings.HasPrefix(header.Get("Content-Type"), "text/event-stream")
+}
+
+// extractEventStream - reads event stream until destination closes it, noting when every event arrived. Once
+// maxSize bytes are read (0 means no limit), capturing stops and the rest of the stream is passed on as it arrives.
+// Response body is replaced so it can still be read
+func extractEventStream(resp *http.Response, maxSize int64) (capturedBody, error) {
+	var captured capturedBody
+	var body, event bytes.Buffer
+
+	reader := bufio.NewReader(resp.Body)
+	last := time.Now()
+
+	addEvent := func() {
+		now := time.Now()
+		captured.events = append(captured.events, ServerSentEvent{
+			Delay: int64(now.Sub(last) / time.Millisecond),
+			Data:  event.String(),
+		})
+		event.Reset()
+		last = now
+	}
+
+	for {
+		if maxSize > 0 && int64(body.Len()) >= maxSize {
+			captured.truncated = true
+			break
+		}
+
+		line, err := reader.ReadString('\n')
+		event.WriteString(line)
+		body.WriteString(line)
+
+		if line == "\n" || line == "\r\n" {
+			addEvent()
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			resp.Body.Close()
+			return captured, err
+		}
+	}
+	// stream closed (or capturing stopped) in the middle of an event
+	if event.Len() > 0 {
+		addEvent()
+	}
+	captured.data = body.Bytes()
+
+	if captured.truncated {
+		resp.Body = &streamingBody{
+			Reader: io.MultiReader(bytes.NewReader(captured.data), reader),
+			closer: resp.Body,
+		}
+		return captured, nil
+	}
+
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(captured.data))
+	return captured, nil
+}
+
+// eventStreamBody - returns body that writes captured events with their original delays divided by speed.
+// Events are only used when they still match the response body, i.e. middleware didn't change it
+func eventStreamBody(response ResponseDetails, speed float64) (io.ReadCloser, bool) {
+	if len(response.Events) == 0 {
+		return nil, false
+	}
+
+	var data bytes.Buffer
+	for _, e := range response.Events {
+		data.WriteString(e.Data)
+	}
+	if data.String() != response.Body {
+		return nil, false
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		for _, e := range response.Events {
+			time.Sleep(time.Duration(float64(e.Delay) * float64(time.Millisecond) / speed))
+			if _, err := io.WriteString(w, e.Data); err != nil {
+				// client went away
+				return
+			}
+		}
+		w.Close()
+	}()
+	return r, true
+}
@@ -0,0 +1,124 @@
+package hoverfly
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// eventStreamServer - sends given number of events, each one after given delay
+func eventStreamServer(events int, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(200)
+		for i := 0; i < events; i++ {
+			time.Sleep(delay)
+			fmt.Fprintf(w, "id: %d\ndata: event %d\n\n", i, i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+}
+
+func TestExtractEventStream(t *testing.T) {
+	upstream := eventStreamServer(3, 50*time.Millisecond)
+	defer upstream.Close()
+
+	resp, err := http.Get(upstream.URL)
+	expect(t, err, nil)
+
+	captured, err := extractEventStream(resp, 0)
+	expect(t, err, nil)
+	events, body := captured.events, captured.data
+	expect(t, captured.truncated, false)
+	expect(t, len(events), 3)
+	expect(t, events[1].Data, "id: 1\ndata: event 1\n\n")
+	expect(t, events[1].Delay >= 40, true)
+	expect(t, string(body), "id: 0\ndata: event 0\n\nid: 1\ndata: event 1\n\nid: 2\ndata: event 2\n\n")
+
+	// body can still be read
+	again, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	expect(t, string(again), string(body))
+}
+
+func TestEventStreamReplayedWithTiming(t *testing.T) {
+	upstream := eventStreamServer(2, 200*time.Millisecond)
+
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	proxy, _ := GetNewHoverfly(cfg, NewMemoryCache())
+	proxyServer := httptest.NewServer(proxy)
+	defer proxyServer.Close()
+
+	proxyURL, err := url.Parse(proxyServer.URL)
+	expect(t, err, nil)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get(upstream.URL)
+	expect(t, err, nil)
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	upstream.Close()
+
+	// replaying twice as fast, second event comes about 100ms after the first one
+	cfg.SetMode(VirtualizeMode)
+	cfg.SSESpeed = 2
+
+	resp, err = client.Get(upstream.URL)
+	expect(t, err, nil)
+	defer resp.Body.Close()
+	expect(t, resp.StatusCode, 200)
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var event string
+		for {
+			line, err := reader.ReadString('\n')
+			expect(t, err, nil)
+			event += line
+			if line == "\n" {
+				return event
+			}
+		}
+	}
+
+	expect(t, readEvent(), "id: 0\ndata: event 0\n\n")
+	start := time.Now()
+	expect(t, readEvent(), "id: 1\ndata: event 1\n\n")
+	elapsed := time.Since(start)
+	expect(t, elapsed >= 70*time.Millisecond && elapsed < 190*time.Millisecond, true)
+}
+
+func TestEventStreamBodyChangedByMiddleware(t *testing.T) {
+	response := ResponseDetails{
+		Body:   "data: modified\n\n",
+		Events: []ServerSentEvent{{Delay: 10, Data: "data: original\n\n"}},
+	}
+	_, ok := eventStreamBody(response, 1)
+	expect(t, ok, false)
+
+	response.Body = "data: original\n\n"
+	body, ok := eventStreamBody(response, 1)
+	expect(t, ok, true)
+	data, err := ioutil.ReadAll(body)
+	expect(t, err, nil)
+	expect(t, string(data), response.Body)
+}
+
+func TestSettingsSSESpeedEnv(t *testing.T) {
+	defer os.Setenv("HoverflySSESpeed", "")
+
+	os.Setenv("HoverflySSESpeed", "0.5")
+	expect(t, InitSettings().GetSSESpeed(), 0.5)
+
+	os.Setenv("HoverflySSESpeed", "-1")
+	expect(t, InitSettings().GetSSESpeed(), DefaultSSESpeed)
+
+	expect(t, (&Configuration{}).GetSSESpeed(), DefaultSSESpeed)
+}
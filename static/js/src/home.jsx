@@ -7,6 +7,8 @@ const VirtualizeMode = "virtualize";
 const CaptureMode = "capture";
 const SynthesizeMode = "synthesize";
 const ModifyMode = "modify";
+const SpyMode = "spy";
+const DiffMode = "diff";
 
 
 let ModeInfoComponent = React.createClass({
@@ -59,6 +61,25 @@ let ModeInfoComponent = React.createClass({
                     </p>
                 </div>
             )
+        } else if (mode == SpyMode) {
+            return (
+                <div>
+                    <p>
+                        Spy mode returns captured responses when requests were recorded and passes all other requests
+                        through to their real destination. Requests passed through are captured only when Hoverfly was
+                        started with the -spy-capture flag.
+                    </p>
+                </div>
+            )
+        } else if (mode == DiffMode) {
+            return (
+                <div>
+                    <p>
+                        Diff mode passes requests through to their real destination and compares live responses against
+                        captured ones. Discrepancies are available from the /diff API endpoint.
+                    </p>
+                </div>
+            )
         } else {
             return (
                 <div></div>
@@ -119,6 +140,8 @@ let StateChangeComponent = React.createClass({
         let modifyClass = defaultBtn;
         let captureClass = defaultBtn;
         let synthesizeClass = defaultBtn;
+        let spyClass = defaultBtn;
+        let diffClass = defaultBtn;
 
 
         if (this.state.mode == VirtualizeMode) {
@@ -129,6 +152,10 @@ let StateChangeComponent = React.createClass({
             captureClass = primaryBtn;
         } else if (this.state.mode == SynthesizeMode) {
             synthesizeClass = primaryBtn;
+        } else if (this.state.mode == SpyMode) {
+            spyClass = primaryBtn;
+        } else if (this.state.mode == DiffMode) {
+            diffClass = primaryBtn;
         }
 
         let data = {
@@ -149,6 +176,10 @@ let StateChangeComponent = React.createClass({
                         {' '}
                         <button className={synthesizeClass} onClick={this.changeMode} value="synthesize">Synthesize
                         </button>
+                        {' '}
+                        <button className={spyClass} onClick={this.changeMode} value="spy">Spy</button>
+                        {' '}
+                        <button className={diffClass} onClick={this.changeMode} value="diff">Diff</button>
                     </div>
                     <div className="one-third column">
                         <ModeInfoComponent data={data}/>
@@ -0,0 +1,136 @@
+package hoverfly
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultStatsDPrefix - prefix of metric names sent to StatsD
+const DefaultStatsDPrefix = "hoverfly"
+
+// DefaultStatsDInterval - how often metrics are sent to StatsD
+const DefaultStatsDInterval = 10 * time.Second
+
+// statsDPacketSize - metrics are batched into packets of at most this size so they aren't fragmented
+const statsDPacketSize = 1432
+
+// StatsDEmitter - sends Hoverfly metrics to StatsD server (or any agent speaking its protocol, i.e. Datadog agent
+// or Graphite with StatsD in front of it) over UDP. Counters are sent as increments since the previous emit
+type StatsDEmitter struct {
+	conn   net.Conn
+	prefix string
+	// last - counter values sent previously
+	last map[string]int64
+}
+
+// NewStatsDEmitter - returns emitter sending metrics with given prefix to StatsD server at given address
+// (i.e. "localhost:8125")
+func NewStatsDEmitter(address, prefix string) (*StatsDEmitter, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsDEmitter{conn: conn, prefix: strings.TrimSuffix(prefix, "."), last: make(map[string]int64)}, nil
+}
+
+// Close - closes connection to StatsD server
+func (e *StatsDEmitter) Close() error {
+	return e.conn.Close()
+}
+
+// Emit - sends given counters (increments since previous call) and gauges
+func (e *StatsDEmitter) Emit(counters map[string]int64, gauges map[string]float64) error {
+	var lines []string
+	for name, value := range counters {
+		delta := value - e.last[name]
+		e.last[name] = value
+		if delta < 0 {
+			// counter was reset
+			delta = value
+		}
+		if delta > 0 {
+			lines = append(lines, fmt.Sprintf("%s:%d|c", e.metricName(name), delta))
+		}
+	}
+	for name, value := range gauges {
+		lines = append(lines, fmt.Sprintf("%s:%s|g", e.metricName(name), formatStatsDValue(value)))
+	}
+	sort.Strings(lines)
+
+	var packet bytes.Buffer
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsDPacketSize {
+			if _, err := e.conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		_, err := e.conn.Write(packet.Bytes())
+		return err
+	}
+	return nil
+}
+
+// metricName - returns metric name with prefix
+func (e *StatsDEmitter) metricName(name string) string {
+	if e.prefix == "" {
+		return name
+	}
+	return e.prefix + "." + name
+}
+
+// formatStatsDValue - formats gauge value without exponent, StatsD doesn't understand it
+func formatStatsDValue(value float64) string {
+	s := fmt.Sprintf("%f", value)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// statsDMetrics - returns current counters and gauges, requests are counted by mode
+func (d *DBClient) statsDMetrics() (map[string]int64, map[string]float64) {
+	counters := make(map[string]int64)
+	for mode, count := range d.Counter.Flush().Counters {
+		counters["requests."+mode] = count
+	}
+
+	stats := d.Cache.Stats()
+	counters["cache.sets"] = stats.Sets
+	counters["cache.gets"] = stats.Gets
+	counters["cache.hits"] = stats.Hits
+	counters["cache.misses"] = stats.Misses
+
+	gauges := map[string]float64{"cache.hit_ratio": stats.HitRatio}
+	if count, err := d.Cache.RecordsCount(); err == nil {
+		gauges["records"] = float64(count)
+	}
+	return counters, gauges
+}
+
+// EmitStatsD - sends current metrics to StatsD
+func (d *DBClient) EmitStatsD(emitter *StatsDEmitter) {
+	counters, gauges := d.statsDMetrics()
+	if err := emitter.Emit(counters, gauges); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to send metrics to StatsD")
+	}
+}
+
+// StartStatsD - periodically sends metrics to StatsD until stop channel is closed
+func (d *DBClient) StartStatsD(emitter *StatsDEmitter, interval time.Duration, stop <-chan struct{}) {
+	runEvery(interval, stop, func() {
+		d.EmitStatsD(emitter)
+	})
+}
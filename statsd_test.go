@@ -0,0 +1,100 @@
+package hoverfly
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statsDServer - listens for StatsD packets, returned function reads metric lines of the next packet
+func statsDServer(t *testing.T) (*net.UDPConn, func() []string) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	expect(t, err, nil)
+
+	read := func() []string {
+		buf := make([]byte, 65536)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("no StatsD packet received: %s", err.Error())
+		}
+		lines := strings.Split(string(buf[:n]), "\n")
+		sort.Strings(lines)
+		return lines
+	}
+	return conn, read
+}
+
+func TestStatsDEmitterCounterIncrements(t *testing.T) {
+	server, read := statsDServer(t)
+	defer server.Close()
+
+	emitter, err := NewStatsDEmitter(server.LocalAddr().String(), "hoverfly.")
+	expect(t, err, nil)
+	defer emitter.Close()
+
+	expect(t, emitter.Emit(map[string]int64{"requests.capture": 3}, map[string]float64{"records": 10, "cache.hit_ratio": 0.25}), nil)
+	expect(t, strings.Join(read(), ","), "hoverfly.cache.hit_ratio:0.25|g,hoverfly.records:10|g,hoverfly.requests.capture:3|c")
+
+	// only increments are sent, counters that didn't change are left out
+	expect(t, emitter.Emit(map[string]int64{"requests.capture": 5, "requests.spy": 0}, map[string]float64{"records": 12}), nil)
+	expect(t, strings.Join(read(), ","), "hoverfly.records:12|g,hoverfly.requests.capture:2|c")
+}
+
+func TestStatsDEmitterSplitsPackets(t *testing.T) {
+	server, read := statsDServer(t)
+	defer server.Close()
+
+	emitter, err := NewStatsDEmitter(server.LocalAddr().String(), "")
+	expect(t, err, nil)
+	defer emitter.Close()
+
+	gauges := make(map[string]float64)
+	for i := 0; i < 200; i++ {
+		gauges[strings.Repeat("g", 10)+string(rune('a'+i%26))+strings.Repeat("x", i/26)] = float64(i)
+	}
+	expect(t, emitter.Emit(nil, gauges), nil)
+
+	received := 0
+	for received < len(gauges) {
+		lines := read()
+		expect(t, len(strings.Join(lines, "\n")) <= statsDPacketSize, true)
+		received += len(lines)
+	}
+	expect(t, received, 200)
+}
+
+func TestEmitStatsD(t *testing.T) {
+	server, read := statsDServer(t)
+	defer server.Close()
+
+	srv, dbClient := testTools(201, `{"message": "here"}`)
+	defer srv.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	req, err := http.NewRequest("GET", "http://example.com/path", nil)
+	expect(t, err, nil)
+	dbClient.captureRequest(req)
+	dbClient.Counter.Count(CaptureMode)
+
+	emitter, err := NewStatsDEmitter(server.LocalAddr().String(), DefaultStatsDPrefix)
+	expect(t, err, nil)
+	defer emitter.Close()
+	dbClient.EmitStatsD(emitter)
+
+	lines := strings.Join(read(), ",")
+	expect(t, strings.Contains(lines, "hoverfly.requests.capture:1|c"), true)
+	expect(t, strings.Contains(lines, "hoverfly.cache.sets:1|c"), true)
+	expect(t, strings.Contains(lines, "hoverfly.records:1|g"), true)
+}
+
+func TestFormatStatsDValue(t *testing.T) {
+	expect(t, formatStatsDValue(10), "10")
+	expect(t, formatStatsDValue(0.5), "0.5")
+	expect(t, formatStatsDValue(1e9), "1000000000")
+	expect(t, formatStatsDValue(0), "0")
+}
@@ -11,6 +11,14 @@ import (
 
 // SynthesizeResponse calls middleware to populate response data, nothing gets pass proxy
 func SynthesizeResponse(req *http.Request, middleware string) (*http.Response, error) {
+	return synthesizeResponse(req, middleware, func(c *Constructor) error {
+		return c.ApplyMiddleware(middleware)
+	})
+}
+
+// synthesizeResponse - populates response data with given function, middleware describes what it runs. Request
+// fails when no response was created, whatever the failure policy is
+func synthesizeResponse(req *http.Request, middleware string, apply func(*Constructor) error) (*http.Response, error) {
 
 	// this is mainly for testing, since when you create a request during tests
 	// its body will be nil, that results in bad things during read
@@ -53,10 +61,13 @@ func SynthesizeResponse(req *http.Request, middleware string) (*http.Response, e
 	c := NewConstructor(req, payload)
 
 	if middleware != "" {
-		err := c.ApplyMiddleware(middleware)
+		err := apply(c)
 		if err != nil {
 			return nil, fmt.Errorf("Synthesize failed, middleware error - %s", err.Error())
 		}
+		if c.payload.Response.Status == 0 {
+			return nil, fmt.Errorf("Synthesize failed, middleware didn't create a response")
+		}
 	} else {
 		return nil, fmt.Errorf("Synthesize failed, middleware not provided")
 
@@ -0,0 +1,54 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package hoverfly
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// syslogWriter - sends entries to syslog with priority matching their level
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+// newSyslogWriter - connects to syslog at given address (i.e. "udp://logs.example.com:514"), local syslog daemon is
+// used when address is empty
+func newSyslogWriter(address string) (*syslogWriter, error) {
+	var network, raddr string
+	if address != "" {
+		u, err := url.Parse(address)
+		if err != nil || u.Host == "" || (u.Scheme != "udp" && u.Scheme != "tcp") {
+			return nil, fmt.Errorf("invalid syslog address '%s', expected udp://host:port or tcp://host:port", address)
+		}
+		network, raddr = u.Scheme, u.Host
+	}
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_DAEMON, "hoverfly")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogWriter{w: w}, nil
+}
+
+func (s *syslogWriter) write(level log.Level, line []byte) error {
+	msg := trimNewline(line)
+	switch level {
+	case log.PanicLevel, log.FatalLevel:
+		return s.w.Crit(msg)
+	case log.ErrorLevel:
+		return s.w.Err(msg)
+	case log.WarnLevel:
+		return s.w.Warning(msg)
+	case log.InfoLevel:
+		return s.w.Info(msg)
+	}
+	return s.w.Debug(msg)
+}
+
+func (s *syslogWriter) Close() error {
+	return s.w.Close()
+}
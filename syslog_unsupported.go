@@ -0,0 +1,26 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package hoverfly
+
+import (
+	"fmt"
+	"runtime"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// syslogWriter - syslog isn't available on this platform
+type syslogWriter struct{}
+
+func newSyslogWriter(address string) (*syslogWriter, error) {
+	return nil, fmt.Errorf("syslog log sink is not supported on %s", runtime.GOOS)
+}
+
+func (s *syslogWriter) write(level log.Level, line []byte) error {
+	return nil
+}
+
+func (s *syslogWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package hoverfly
+
+import (
+	"crypto/rand"
+	"fmt"
+	mathrand "math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// randomStringChars - characters of strings generated by templates
+const randomStringChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// TemplateCounters - named sequences incremented every time template uses them
+type TemplateCounters struct {
+	mu       sync.Mutex
+	counters map[string]int64
+}
+
+// NewTemplateCounters - returns counters, every counter starts at 1
+func NewTemplateCounters() *TemplateCounters {
+	return &TemplateCounters{counters: make(map[string]int64)}
+}
+
+// Next - returns next value of named counter
+func (c *TemplateCounters) Next(name string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[name]++
+	return c.counters[name]
+}
+
+// templateFuncs - helper functions available in response templates, counters are shared by all templates
+func templateFuncs(counters *TemplateCounters) template.FuncMap {
+	return template.FuncMap{
+		"now":          func(layout string) string { return formatTime(time.Now(), layout) },
+		"nowOffset":    nowOffset,
+		"randomInt":    randomInt,
+		"randomString": randomString,
+		"uuid":         newUUID,
+		"counter": func(name string) int64 {
+			if counters == nil {
+				return 0
+			}
+			return counters.Next(name)
+		},
+	}
+}
+
+// formatTime - formats time with Go layout, i.e. "2006-01-02", or as "unix" / "unixMillis" timestamp. RFC3339 is
+// used when layout is empty
+func formatTime(t time.Time, layout string) string {
+	switch layout {
+	case "":
+		return t.Format(time.RFC3339)
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixMillis":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return t.Format(layout)
+}
+
+// nowOffset - returns current time moved by offset, i.e. "-1h30m" or "7d", formatted like in formatTime
+func nowOffset(offset, layout string) (string, error) {
+	var d time.Duration
+	if strings.HasSuffix(offset, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(offset, "d"))
+		if err != nil {
+			return "", fmt.Errorf("invalid offset '%s'", offset)
+		}
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(offset); err != nil {
+			return "", fmt.Errorf("invalid offset '%s'", offset)
+		}
+	}
+	return formatTime(time.Now().Add(d), layout), nil
+}
+
+// randomInt - returns random integer between min and max, both included
+func randomInt(min, max int) (int, error) {
+	if max < min {
+		return 0, fmt.Errorf("randomInt maximum %d is lower than minimum %d", max, min)
+	}
+	return min + mathrand.Intn(max-min+1), nil
+}
+
+// randomString - returns random alphanumeric string of given length
+func randomString(length int) string {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = randomStringChars[mathrand.Intn(len(randomStringChars))]
+	}
+	return string(b)
+}
+
+// newUUID - returns random (version 4) UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,75 @@
+package hoverfly
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// renderTestTemplate - renders template for GET request with given counters
+func renderTestTemplate(t *testing.T, text string, counters *TemplateCounters) (string, error) {
+	req, _ := http.NewRequest("GET", "http://api.example.com/", nil)
+	data := templateData{Request: newTemplateRequest(req, nil)}
+	return renderTemplate("test", text, data, templateFuncs(counters))
+}
+
+func TestTemplateTimeHelpers(t *testing.T) {
+	out, err := renderTestTemplate(t, `{{ now "2006-01-02" }}`, nil)
+	expect(t, err, nil)
+	expect(t, out, time.Now().Format("2006-01-02"))
+
+	out, err = renderTestTemplate(t, `{{ now "" }}`, nil)
+	expect(t, err, nil)
+	_, err = time.Parse(time.RFC3339, out)
+	expect(t, err, nil)
+
+	out, err = renderTestTemplate(t, `{{ nowOffset "1d" "2006-01-02" }}`, nil)
+	expect(t, err, nil)
+	expect(t, out, time.Now().Add(24*time.Hour).Format("2006-01-02"))
+
+	out, err = renderTestTemplate(t, `{{ nowOffset "-1h" "unix" }}`, nil)
+	expect(t, err, nil)
+	unix, err := strconv.ParseInt(out, 10, 64)
+	expect(t, err, nil)
+	expect(t, time.Now().Add(-time.Hour).Unix()-unix <= 1, true)
+
+	_, err = renderTestTemplate(t, `{{ nowOffset "yesterday" "" }}`, nil)
+	refute(t, err, nil)
+}
+
+func TestTemplateRandomHelpers(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		out, err := renderTestTemplate(t, `{{ randomInt 5 7 }}`, nil)
+		expect(t, err, nil)
+		n, _ := strconv.Atoi(out)
+		expect(t, n >= 5 && n <= 7, true)
+	}
+
+	_, err := renderTestTemplate(t, `{{ randomInt 7 5 }}`, nil)
+	refute(t, err, nil)
+
+	out, err := renderTestTemplate(t, `{{ randomString 12 }}`, nil)
+	expect(t, err, nil)
+	expect(t, regexp.MustCompile(`^[a-zA-Z0-9]{12}$`).MatchString(out), true)
+
+	first, err := renderTestTemplate(t, `{{ uuid }}`, nil)
+	expect(t, err, nil)
+	expect(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(first), true)
+	second, _ := renderTestTemplate(t, `{{ uuid }}`, nil)
+	refute(t, first, second)
+}
+
+func TestTemplateCounters(t *testing.T) {
+	counters := NewTemplateCounters()
+
+	out, err := renderTestTemplate(t, `{{ counter "orders" }},{{ counter "orders" }},{{ counter "users" }}`, counters)
+	expect(t, err, nil)
+	expect(t, out, "1,2,1")
+
+	// counters are kept between responses
+	out, err = renderTestTemplate(t, `{{ counter "orders" }}`, counters)
+	expect(t, err, nil)
+	expect(t, out, "3")
+}
@@ -0,0 +1,143 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateRequest - request data available to response templates as .Request, i.e.
+// {{ .Request.Segment 1 }}, {{ .Request.QueryParam "page" }} or {{ .Request.Field "user.name" }}
+type TemplateRequest struct {
+	Method  string
+	Scheme  string
+	Host    string
+	Path    string
+	Query   url.Values
+	Headers http.Header
+	Body    string
+}
+
+// templateData - data response templates are executed with
+type templateData struct {
+	Request TemplateRequest
+}
+
+// newTemplateRequest - returns template data of given request
+func newTemplateRequest(req *http.Request, body []byte) TemplateRequest {
+	return TemplateRequest{
+		Method:  req.Method,
+		Scheme:  req.URL.Scheme,
+		Host:    req.Host,
+		Path:    req.URL.Path,
+		Query:   req.URL.Query(),
+		Headers: req.Header,
+		Body:    string(body),
+	}
+}
+
+// Segment - returns path segment with given index, starting with 0, empty string when there is no such segment
+func (r TemplateRequest) Segment(i int) string {
+	segments := strings.Split(strings.Trim(r.Path, "/"), "/")
+	if i < 0 || i >= len(segments) {
+		return ""
+	}
+	return segments[i]
+}
+
+// QueryParam - returns first value of query parameter
+func (r TemplateRequest) QueryParam(name string) string {
+	return r.Query.Get(name)
+}
+
+// Header - returns first value of request header
+func (r TemplateRequest) Header(name string) string {
+	return r.Headers.Get(name)
+}
+
+// Field - returns field of JSON body, path is dot separated list of object keys and array indexes, i.e.
+// "items.0.id". Objects and arrays are returned as JSON, empty string when body has no such field
+func (r TemplateRequest) Field(path string) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(r.Body), &value); err != nil {
+		return ""
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return ""
+			}
+			value = v[i]
+		default:
+			return ""
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		js, _ := json.Marshal(v)
+		return string(js)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// renderTemplate - executes template text with given data and helper functions
+func renderTemplate(name, text string, data templateData, funcs template.FuncMap) (string, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// renderResponseTemplate - replaces templated response body and header values with their output for given request
+func renderResponseTemplate(response *ResponseDetails, req *http.Request, body []byte, counters *TemplateCounters) error {
+	data := templateData{Request: newTemplateRequest(req, body)}
+	funcs := templateFuncs(counters)
+
+	rendered, err := renderTemplate("body", response.Body, data, funcs)
+	if err != nil {
+		return err
+	}
+	response.Body = rendered
+
+	headers := make(map[string][]string, len(response.Headers))
+	for name, values := range response.Headers {
+		for _, value := range values {
+			rendered, err := renderTemplate(name, value, data, funcs)
+			if err != nil {
+				return err
+			}
+			headers[name] = append(headers[name], rendered)
+		}
+	}
+	response.Headers = headers
+
+	// rendered body has different length
+	for name := range response.Headers {
+		if http.CanonicalHeaderKey(name) == "Content-Length" {
+			delete(response.Headers, name)
+		}
+	}
+	return nil
+}
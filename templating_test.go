@@ -0,0 +1,81 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTemplateRequest(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://api.example.com/users/42/orders?page=3", nil)
+	req.Header.Set("X-Api-Version", "2")
+	r := newTemplateRequest(req, []byte(`{"user": {"name": "Jane", "age": 41}, "items": [{"id": 7}, {"id": 8}]}`))
+
+	expect(t, r.Segment(0), "users")
+	expect(t, r.Segment(1), "42")
+	expect(t, r.Segment(3), "")
+	expect(t, r.QueryParam("page"), "3")
+	expect(t, r.Header("x-api-version"), "2")
+
+	expect(t, r.Field("user.name"), "Jane")
+	expect(t, r.Field("user.age"), "41")
+	expect(t, r.Field("items.1.id"), "8")
+	expect(t, r.Field("items.0"), `{"id":7}`)
+	expect(t, r.Field("items.5.id"), "")
+	expect(t, r.Field("user.missing"), "")
+
+	r.Body = "not json"
+	expect(t, r.Field("user.name"), "")
+}
+
+func TestTemplatedResponse(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	body := `{"user": {"name": "Jane"}}`
+	err := dbClient.ImportPayloads([]Payload{{
+		Request: RequestDetails{Method: "POST", Scheme: "http", Destination: "api.example.com",
+			Path: "/users/42", Query: "greeting=hello", Body: body},
+		Response: ResponseDetails{
+			Status: 201,
+			Headers: map[string][]string{
+				"Location":       {"/users/{{ .Request.Segment 1 }}"},
+				"Content-Length": {"64"},
+			},
+			Body:      `{"id": "{{ .Request.Segment 1 }}", "message": "{{ .Request.QueryParam "greeting" }} {{ .Request.Field "user.name" }}"}`,
+			Templated: true,
+		},
+	}})
+	expect(t, err, nil)
+
+	req, _ := http.NewRequest("POST", "http://api.example.com/users/42?greeting=hello", strings.NewReader(body))
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 201)
+	expect(t, resp.Header.Get("Location"), "/users/42")
+	expect(t, resp.Header.Get("Content-Length"), "")
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	expect(t, string(respBody), `{"id": "42", "message": "hello Jane"}`)
+	expect(t, resp.ContentLength, int64(len(respBody)))
+}
+
+func TestTemplatedResponseInvalidTemplate(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	err := dbClient.ImportPayloads([]Payload{{
+		Request:  RequestDetails{Method: "GET", Scheme: "http", Destination: "api.example.com", Path: "/broken"},
+		Response: ResponseDetails{Status: 200, Body: "{{ .Request.Segment", Templated: true},
+	}})
+	expect(t, err, nil)
+
+	req, _ := http.NewRequest("GET", "http://api.example.com/broken", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, http.StatusInternalServerError)
+}
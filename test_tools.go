@@ -66,11 +66,31 @@ func testTools(code int, body string) (*httptest.Server, *DBClient) {
 	counter := NewModeCounter()
 	// preparing client
 	dbClient := &DBClient{
-		HTTP:    &http.Client{Transport: tr},
-		Cache:   cache,
-		Cfg:     cfg,
-		Counter: counter,
+		HTTP:             &http.Client{Transport: tr},
+		Cache:            cache,
+		Cfg:              cfg,
+		Counter:          counter,
+		Hooks:            make(ActionTypeHooks),
+		Events:           NewCacheEvents(),
+		Traffic:          NewTrafficStream(),
+		Journal:          NewJournal(DefaultJournalSize),
+		Diffs:            NewDiffReport(),
+		Delays:           NewResponseDelays(),
+		Faults:           NewFaults(),
+		Webhooks:         NewWebhooks(),
+		MiddlewareChain:  NewMiddlewareChain(),
+		ErrorResponses:   NewErrorResponses(),
+		RateLimits:       NewRateLimits(),
+		TemplateCounters: NewTemplateCounters(),
+		Sequences:        NewSequences(),
+		ScenarioState:    NewScenarioState(),
+		HeaderRules:      NewHeaderRules(),
+		HeaderMatches:    NewHeaderMatches(),
+		BodyMatchers:     NewBodyMatchers(),
+		CustomMatchers:   NewCustomMatchers(),
+		RecordIndex:      NewRecordIndex(),
 	}
+	dbClient.AddHook(dbClient.Events)
 	return server, dbClient
 }
 
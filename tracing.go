@@ -0,0 +1,447 @@
+package hoverfly
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultTracingServiceName - service name Hoverfly spans are reported under
+const DefaultTracingServiceName = "hoverfly"
+
+// Span kinds, as defined by OpenTelemetry
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+)
+
+// tracing batching, spans are exported when batch is full or interval passes, whichever comes first
+const (
+	tracingBatchSize     = 100
+	tracingQueueSize     = 1000
+	tracingFlushInterval = 5 * time.Second
+)
+
+// SpanContext - identifies span across process boundaries (W3C trace context or B3 headers)
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// IsValid - checks whether span context identifies a span
+func (sc SpanContext) IsValid() bool {
+	return isTraceHex(sc.TraceID, 32) && isTraceHex(sc.SpanID, 16)
+}
+
+// isTraceHex - checks whether s is lowercase hex of given length and isn't all zeros
+func isTraceHex(s string, length int) bool {
+	if len(s) != length || strings.Trim(s, "0") == "" {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// extractSpanContext - reads span context of the caller from traceparent, b3 or X-B3-* headers
+func extractSpanContext(header http.Header) (SpanContext, bool) {
+	if tp := header.Get("traceparent"); tp != "" {
+		// version-traceid-spanid-flags
+		parts := strings.Split(strings.TrimSpace(tp), "-")
+		if len(parts) >= 4 && len(parts[0]) == 2 && parts[0] != "ff" && len(parts[3]) == 2 {
+			flags, err := strconv.ParseUint(parts[3], 16, 8)
+			sc := SpanContext{TraceID: parts[1], SpanID: parts[2], Sampled: flags&1 == 1}
+			if err == nil && sc.IsValid() {
+				return sc, true
+			}
+		}
+	}
+
+	if b3 := header.Get("b3"); b3 != "" {
+		// traceid-spanid-sampled-parentspanid, sampled and parent are optional
+		parts := strings.Split(strings.TrimSpace(b3), "-")
+		if len(parts) >= 2 {
+			sc := SpanContext{TraceID: padTraceID(parts[0]), SpanID: parts[1], Sampled: true}
+			if len(parts) >= 3 {
+				sc.Sampled = parts[2] == "1" || parts[2] == "d"
+			}
+			if sc.IsValid() {
+				return sc, true
+			}
+		}
+	}
+
+	if traceID := header.Get("X-B3-TraceId"); traceID != "" {
+		sc := SpanContext{TraceID: padTraceID(traceID), SpanID: header.Get("X-B3-SpanId"), Sampled: true}
+		if sampled := header.Get("X-B3-Sampled"); sampled != "" {
+			sc.Sampled = sampled == "1" || sampled == "true"
+		}
+		if header.Get("X-B3-Flags") == "1" {
+			sc.Sampled = true
+		}
+		if sc.IsValid() {
+			return sc, true
+		}
+	}
+
+	return SpanContext{}, false
+}
+
+// padTraceID - returns 128 bit trace ID, 64 bit B3 trace IDs are left padded with zeros
+func padTraceID(traceID string) string {
+	traceID = strings.ToLower(strings.TrimSpace(traceID))
+	if len(traceID) == 16 {
+		return strings.Repeat("0", 16) + traceID
+	}
+	return traceID
+}
+
+// injectSpanContext - sets traceparent header for the callee, B3 headers are updated too when the caller used them
+func injectSpanContext(header http.Header, sc SpanContext) {
+	flags, sampled := "00", "0"
+	if sc.Sampled {
+		flags, sampled = "01", "1"
+	}
+	header.Set("traceparent", fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags))
+
+	if header.Get("b3") != "" {
+		header.Set("b3", fmt.Sprintf("%s-%s-%s", sc.TraceID, sc.SpanID, sampled))
+	}
+	if header.Get("X-B3-TraceId") != "" {
+		header.Set("X-B3-TraceId", sc.TraceID)
+		header.Set("X-B3-SpanId", sc.SpanID)
+		header.Set("X-B3-Sampled", sampled)
+		header.Del("X-B3-ParentSpanId")
+		header.Del("X-B3-Flags")
+	}
+}
+
+// randomTraceHex - returns random ID of given size in bytes as hex
+func randomTraceHex(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Span - timed phase of request handling, methods can be called on nil span so code doesn't have to check whether
+// tracing is enabled
+type Span struct {
+	tracer       *Tracer
+	Name         string
+	Kind         int
+	Context      SpanContext
+	ParentSpanID string
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]interface{}
+	Error        string
+}
+
+// SetAttribute - sets attribute of the span, values are strings, integers or booleans
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s != nil {
+		s.Attributes[key] = value
+	}
+}
+
+// SetError - marks span as failed
+func (s *Span) SetError(err error) {
+	if s != nil && err != nil {
+		s.Error = err.Error()
+	}
+}
+
+// Finish - ends the span and hands it over for export when it's sampled
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.End = time.Now()
+	if s.Context.Sampled {
+		s.tracer.queue(s)
+	}
+}
+
+// Inject - passes span context to the callee in request headers
+func (s *Span) Inject(header http.Header) {
+	if s != nil {
+		injectSpanContext(header, s.Context)
+	}
+}
+
+// spanKey - context key of current span
+type spanKey struct{}
+
+// withSpan - returns context with given current span
+func withSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// startChildSpan - starts span that is child of current span of the context, nil when the context has no span
+// (tracing is disabled)
+func startChildSpan(ctx context.Context, name string, kind int) *Span {
+	parent, ok := ctx.Value(spanKey{}).(*Span)
+	if !ok || parent == nil {
+		return nil
+	}
+	return parent.tracer.startSpan(name, kind, parent.Context.TraceID, parent.Context.SpanID, parent.Context.Sampled)
+}
+
+// Tracer - creates spans and exports them in batches to OpenTelemetry collector (OTLP/HTTP with JSON encoding).
+// Spans that can't be exported are dropped, tracing never slows the proxy down
+type Tracer struct {
+	// Endpoint - OTLP traces endpoint, i.e. "http://localhost:4318/v1/traces"
+	Endpoint    string
+	ServiceName string
+	HTTP        *http.Client
+
+	spans     chan *Span
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTracer - returns tracer exporting spans to given endpoint, it has to be closed to export remaining spans
+func NewTracer(endpoint, serviceName string) *Tracer {
+	if serviceName == "" {
+		serviceName = DefaultTracingServiceName
+	}
+	t := &Tracer{
+		Endpoint:    endpoint,
+		ServiceName: serviceName,
+		HTTP:        &http.Client{Timeout: 10 * time.Second},
+		spans:       make(chan *Span, tracingQueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go t.run(tracingFlushInterval)
+	return t
+}
+
+// StartSpan - starts span that continues trace of the caller, new trace is started when there is no caller span.
+// Root spans are always sampled, continued ones follow sampling decision of the caller
+func (t *Tracer) StartSpan(name string, kind int, caller SpanContext) *Span {
+	if caller.IsValid() {
+		return t.startSpan(name, kind, caller.TraceID, caller.SpanID, caller.Sampled)
+	}
+	return t.startSpan(name, kind, randomTraceHex(16), "", true)
+}
+
+// startSpan - starts span with given parent
+func (t *Tracer) startSpan(name string, kind int, traceID, parentSpanID string, sampled bool) *Span {
+	return &Span{
+		tracer:       t,
+		Name:         name,
+		Kind:         kind,
+		Context:      SpanContext{TraceID: traceID, SpanID: randomTraceHex(8), Sampled: sampled},
+		ParentSpanID: parentSpanID,
+		Start:        time.Now(),
+		Attributes:   make(map[string]interface{}),
+	}
+}
+
+// queue - passes finished span to exporter without blocking
+func (t *Tracer) queue(s *Span) {
+	select {
+	case t.spans <- s:
+	default:
+		log.WithFields(log.Fields{
+			"span": s.Name,
+		}).Debug("Tracing queue is full, dropping span")
+	}
+}
+
+// run - exports queued spans in batches until tracer is closed
+func (t *Tracer) run(interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			log.WithFields(log.Fields{
+				"error":    err.Error(),
+				"endpoint": t.Endpoint,
+				"spans":    len(batch),
+			}).Warn("Failed to export spans")
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case s := <-t.spans:
+			batch = append(batch, s)
+			if len(batch) >= tracingBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.stop:
+			for {
+				select {
+				case s := <-t.spans:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close - exports remaining spans and stops the tracer
+func (t *Tracer) Close() {
+	t.closeOnce.Do(func() {
+		close(t.stop)
+	})
+	<-t.done
+}
+
+// otlpAttribute - key and value of OTLP attribute
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// otlpAttributes - converts attributes to OTLP representation, sorted by key
+func otlpAttributes(attributes map[string]interface{}) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	converted := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attributes[key].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		converted = append(converted, otlpAttribute{Key: key, Value: value})
+	}
+	return converted
+}
+
+// otlpSpan - span in OTLP JSON encoding
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+// otlpStatus - span status, code 2 means error
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpRequest - builds OTLP export request body for given spans
+func (t *Tracer) otlpRequest(spans []*Span) map[string]interface{} {
+	converted := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.Context.TraceID,
+			SpanID:            s.Context.SpanID,
+			ParentSpanID:      s.ParentSpanID,
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if s.Error != "" {
+			span.Status = &otlpStatus{Code: 2, Message: s.Error}
+		}
+		converted = append(converted, span)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": t.ServiceName}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "hoverfly"},
+				"spans": converted,
+			}},
+		}},
+	}
+}
+
+// export - sends spans to the collector
+func (t *Tracer) export(spans []*Span) error {
+	body, err := json.Marshal(t.otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+	resp, err := t.HTTP.Post(t.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// traceRequest - handles request with given function within server span that continues trace of the caller, phases
+// of request handling (matching, middleware, upstream request) become its children
+func (d *DBClient) traceRequest(req *http.Request, handle func(*http.Request) (*http.Request, *http.Response)) (*http.Request, *http.Response) {
+	if d.Tracer == nil {
+		return handle(req)
+	}
+
+	caller, _ := extractSpanContext(req.Header)
+	mode := d.Cfg.GetMode()
+	span := d.Tracer.StartSpan("hoverfly "+mode, SpanKindServer, caller)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.host", req.Host)
+	span.SetAttribute("http.target", req.URL.RequestURI())
+	span.SetAttribute("hoverfly.mode", mode)
+
+	req, resp := handle(req.WithContext(withSpan(req.Context(), span)))
+	if resp != nil {
+		span.SetAttribute("http.status_code", resp.StatusCode)
+		if resp.StatusCode >= 500 {
+			span.SetError(fmt.Errorf("response status %d", resp.StatusCode))
+		}
+	}
+	span.Finish()
+	return req, resp
+}
@@ -0,0 +1,256 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// tracingUpstream - points client at upstream that remembers headers of the last request it got
+func tracingUpstream(dbClient *DBClient) (*httptest.Server, *http.Header) {
+	received := &http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*received = r.Header
+		w.Write([]byte("traced"))
+	}))
+	dbClient.HTTP = &http.Client{Transport: &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return url.Parse(server.URL)
+		},
+	}}
+	return server, received
+}
+
+// exportedSpans - collects spans from OTLP export requests
+type exportedSpans struct {
+	services []string
+	spans    []otlpSpan
+}
+
+func (e *exportedSpans) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []otlpAttribute `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	for _, rs := range body.ResourceSpans {
+		for _, attr := range rs.Resource.Attributes {
+			e.services = append(e.services, attr.Value["stringValue"].(string))
+		}
+		for _, ss := range rs.ScopeSpans {
+			e.spans = append(e.spans, ss.Spans...)
+		}
+	}
+}
+
+func (e *exportedSpans) byName(name string) (otlpSpan, bool) {
+	for _, s := range e.spans {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return otlpSpan{}, false
+}
+
+func TestExtractTraceparent(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	sc, ok := extractSpanContext(header)
+	expect(t, ok, true)
+	expect(t, sc.TraceID, "4bf92f3577b34da6a3ce929d0e0e4736")
+	expect(t, sc.SpanID, "00f067aa0ba902b7")
+	expect(t, sc.Sampled, true)
+}
+
+func TestExtractTraceparentNotSampled(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	sc, ok := extractSpanContext(header)
+	expect(t, ok, true)
+	expect(t, sc.Sampled, false)
+}
+
+func TestExtractInvalidTraceparent(t *testing.T) {
+	for _, tp := range []string{
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"garbage",
+	} {
+		header := http.Header{}
+		header.Set("traceparent", tp)
+		_, ok := extractSpanContext(header)
+		expect(t, ok, false)
+	}
+}
+
+func TestExtractB3Single(t *testing.T) {
+	header := http.Header{}
+	header.Set("b3", "a3ce929d0e0e4736-00f067aa0ba902b7-1-05e3ac9a4f6e3b90")
+
+	sc, ok := extractSpanContext(header)
+	expect(t, ok, true)
+	expect(t, sc.TraceID, "0000000000000000a3ce929d0e0e4736")
+	expect(t, sc.SpanID, "00f067aa0ba902b7")
+	expect(t, sc.Sampled, true)
+}
+
+func TestExtractB3Multi(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-B3-TraceId", "4bf92f3577b34da6a3ce929d0e0e4736")
+	header.Set("X-B3-SpanId", "00f067aa0ba902b7")
+	header.Set("X-B3-Sampled", "0")
+
+	sc, ok := extractSpanContext(header)
+	expect(t, ok, true)
+	expect(t, sc.TraceID, "4bf92f3577b34da6a3ce929d0e0e4736")
+	expect(t, sc.Sampled, false)
+}
+
+func TestExtractPrefersTraceparent(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set("b3", "a3ce929d0e0e4736-05e3ac9a4f6e3b90-1")
+
+	sc, ok := extractSpanContext(header)
+	expect(t, ok, true)
+	expect(t, sc.SpanID, "00f067aa0ba902b7")
+}
+
+func TestInjectSpanContext(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-B3-TraceId", "a3ce929d0e0e4736")
+	header.Set("X-B3-SpanId", "05e3ac9a4f6e3b90")
+	header.Set("X-B3-ParentSpanId", "15e3ac9a4f6e3b90")
+
+	injectSpanContext(header, SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true})
+	expect(t, header.Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	expect(t, header.Get("X-B3-TraceId"), "4bf92f3577b34da6a3ce929d0e0e4736")
+	expect(t, header.Get("X-B3-SpanId"), "00f067aa0ba902b7")
+	expect(t, header.Get("X-B3-Sampled"), "1")
+	expect(t, header.Get("X-B3-ParentSpanId"), "")
+	// b3 single header is only set when caller used it
+	expect(t, header.Get("b3"), "")
+}
+
+func TestCapturePropagatesTraceWithoutTracer(t *testing.T) {
+	server, dbClient := testTools(200, "")
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(CaptureMode)
+	upstream, received := tracingUpstream(dbClient)
+	defer upstream.Close()
+
+	req, _ := http.NewRequest("GET", "http://example.com/traced", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, resp := dbClient.traceRequest(req, dbClient.processRequest)
+	expect(t, resp.StatusCode, 200)
+
+	expect(t, received.Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+}
+
+func TestCaptureExportsSpans(t *testing.T) {
+	server, dbClient := testTools(200, "")
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(CaptureMode)
+	upstream, received := tracingUpstream(dbClient)
+	defer upstream.Close()
+
+	collected := &exportedSpans{}
+	collector := httptest.NewServer(collected)
+	defer collector.Close()
+	dbClient.Tracer = NewTracer(collector.URL+"/v1/traces", "tests")
+
+	req, _ := http.NewRequest("GET", "http://example.com/traced", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, resp := dbClient.traceRequest(req, dbClient.processRequest)
+	body, _ := ioutil.ReadAll(resp.Body)
+	expect(t, string(body), "traced")
+	dbClient.Tracer.Close()
+
+	expect(t, len(collected.services) > 0, true)
+	expect(t, collected.services[0], "tests")
+
+	serverSpan, ok := collected.byName("hoverfly capture")
+	expect(t, ok, true)
+	expect(t, serverSpan.Kind, SpanKindServer)
+	expect(t, serverSpan.TraceID, "4bf92f3577b34da6a3ce929d0e0e4736")
+	expect(t, serverSpan.ParentSpanID, "00f067aa0ba902b7")
+
+	upstreamSpan, ok := collected.byName("upstream")
+	expect(t, ok, true)
+	expect(t, upstreamSpan.Kind, SpanKindClient)
+	expect(t, upstreamSpan.TraceID, "4bf92f3577b34da6a3ce929d0e0e4736")
+	expect(t, upstreamSpan.ParentSpanID, serverSpan.SpanID)
+
+	// upstream continues the trace as a child of the upstream span
+	expect(t, received.Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-"+upstreamSpan.SpanID+"-01")
+
+	// captured request keeps headers it came with
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Headers["Traceparent"][0], "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+}
+
+func TestVirtualizeExportsMatchSpan(t *testing.T) {
+	server, dbClient := testTools(200, "")
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	collected := &exportedSpans{}
+	collector := httptest.NewServer(collected)
+	defer collector.Close()
+	dbClient.Tracer = NewTracer(collector.URL, "")
+
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	req, _ := http.NewRequest("GET", "http://example.com/unknown", nil)
+	dbClient.traceRequest(req, dbClient.processRequest)
+	dbClient.Tracer.Close()
+
+	expect(t, collected.services[0], DefaultTracingServiceName)
+	serverSpan, ok := collected.byName("hoverfly virtualize")
+	expect(t, ok, true)
+	// new trace is started for requests without trace context
+	expect(t, serverSpan.ParentSpanID, "")
+	expect(t, len(serverSpan.TraceID), 32)
+
+	matchSpan, ok := collected.byName("match")
+	expect(t, ok, true)
+	expect(t, matchSpan.TraceID, serverSpan.TraceID)
+	expect(t, matchSpan.ParentSpanID, serverSpan.SpanID)
+	var matched interface{}
+	for _, attr := range matchSpan.Attributes {
+		if attr.Key == "hoverfly.matched" {
+			matched = attr.Value["boolValue"]
+		}
+	}
+	expect(t, matched, false)
+}
+
+func TestNotSampledSpansAreNotExported(t *testing.T) {
+	collected := &exportedSpans{}
+	collector := httptest.NewServer(collected)
+	defer collector.Close()
+	tracer := NewTracer(collector.URL, "")
+
+	span := tracer.StartSpan("hoverfly capture", SpanKindServer, SpanContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: false})
+	span.Finish()
+	tracer.Close()
+
+	expect(t, len(collected.spans), 0)
+}
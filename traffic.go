@@ -0,0 +1,197 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// trafficBodyLimit - how much of each body is sent to subscribers that asked for bodies, the rest is left out
+const trafficBodyLimit = 64 * 1024
+
+// TrafficEvent - summary of request handled by the proxy (or webserver) and response it got, request and response
+// details are only sent to subscribers that asked for bodies
+type TrafficEvent struct {
+	Time        time.Time       `json:"time"`
+	Mode        string          `json:"mode"`
+	Method      string          `json:"method"`
+	Destination string          `json:"destination"`
+	Path        string          `json:"path"`
+	Query       string          `json:"query,omitempty"`
+	Status      int             `json:"status"`
+	DurationMs  float64         `json:"durationMs"`
+	Request     *TrafficMessage `json:"request,omitempty"`
+	Response    *TrafficMessage `json:"response,omitempty"`
+}
+
+// TrafficMessage - headers and body of request or response, body which isn't valid UTF-8 is base64 encoded and
+// bodies bigger than trafficBodyLimit are truncated
+type TrafficMessage struct {
+	Headers      map[string][]string `json:"headers"`
+	Body         string              `json:"body"`
+	BodyEncoding string              `json:"bodyEncoding,omitempty"`
+	Truncated    bool                `json:"truncated,omitempty"`
+}
+
+// trafficSubscription - what subscriber wants to get, empty destination means all of them
+type trafficSubscription struct {
+	bodies      bool
+	destination string
+}
+
+// TrafficStream - passes live traffic to subscribers, subscribers that don't keep up miss events instead of slowing
+// the proxy down
+type TrafficStream struct {
+	mu          sync.Mutex
+	subscribers map[chan TrafficEvent]trafficSubscription
+}
+
+// NewTrafficStream - returns TrafficStream without subscribers
+func NewTrafficStream() *TrafficStream {
+	return &TrafficStream{
+		subscribers: make(map[chan TrafficEvent]trafficSubscription),
+	}
+}
+
+// Subscribe - returns channel with traffic of given destination (all destinations when empty), request and
+// response details are included when bodies are requested. Returned function has to be called once events are not
+// needed anymore
+func (s *TrafficStream) Subscribe(bodies bool, destination string) (<-chan TrafficEvent, func()) {
+	ch := make(chan TrafficEvent, eventsBufferSize)
+
+	s.mu.Lock()
+	s.subscribers[ch] = trafficSubscription{bodies: bodies, destination: destination}
+	s.mu.Unlock()
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subscribers[ch]; ok {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// subscribed - reports whether anybody listens and whether anybody wants bodies
+func (s *TrafficStream) subscribed() (listening, bodies bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sub := range s.subscribers {
+		listening = true
+		bodies = bodies || sub.bodies
+	}
+	return listening, bodies
+}
+
+// Publish - sends event to all interested subscribers without blocking
+func (s *TrafficStream) Publish(ev TrafficEvent) {
+	summary := ev
+	summary.Request, summary.Response = nil, nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch, sub := range s.subscribers {
+		if sub.destination != "" && sub.destination != ev.Destination {
+			continue
+		}
+		out := summary
+		if sub.bodies {
+			out = ev
+		}
+		select {
+		case ch <- out:
+		default:
+			log.WithFields(log.Fields{
+				"destination": ev.Destination,
+				"path":        ev.Path,
+			}).Debug("Subscriber is too slow, dropping traffic event")
+		}
+	}
+}
+
+// observeTraffic - handles request with given function and publishes the exchange to traffic subscribers, bodies
+// are only read when somebody asked for them and are given back to the request and response untouched
+func (d *DBClient) observeTraffic(req *http.Request, handle func(*http.Request) (*http.Request, *http.Response)) (*http.Request, *http.Response) {
+	if d.Traffic == nil {
+		return handle(req)
+	}
+	listening, bodies := d.Traffic.subscribed()
+	if !listening {
+		return handle(req)
+	}
+
+	ev := TrafficEvent{
+		Time:        time.Now(),
+		Mode:        d.Cfg.GetMode(),
+		Method:      req.Method,
+		Destination: req.Host,
+		Path:        req.URL.Path,
+		Query:       req.URL.RawQuery,
+	}
+	if bodies {
+		var msg TrafficMessage
+		req.Body, msg = peekTrafficBody(req.Header, req.Body)
+		ev.Request = &msg
+	}
+
+	req, resp := handle(req)
+	if resp == nil {
+		// relayed as it is (i.e. websocket upgrade)
+		return req, resp
+	}
+
+	ev.Status = resp.StatusCode
+	ev.DurationMs = float64(time.Since(ev.Time)) / float64(time.Millisecond)
+	if bodies {
+		var msg TrafficMessage
+		if isEventStream(resp.Header) {
+			// reading ahead would hold events back from the client
+			msg = TrafficMessage{Headers: copyHeaders(resp.Header), Truncated: true}
+		} else {
+			resp.Body, msg = peekTrafficBody(resp.Header, resp.Body)
+		}
+		ev.Response = &msg
+	}
+
+	d.Traffic.Publish(ev)
+	return req, resp
+}
+
+// peekTrafficBody - reads up to trafficBodyLimit bytes of the body and returns body that still yields everything
+func peekTrafficBody(header http.Header, body io.ReadCloser) (io.ReadCloser, TrafficMessage) {
+	msg := TrafficMessage{Headers: copyHeaders(header)}
+	if body == nil {
+		return body, msg
+	}
+
+	prefix, err := ioutil.ReadAll(io.LimitReader(body, trafficBodyLimit+1))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Debug("Failed to read body for traffic stream")
+	}
+	shown := prefix
+	if len(prefix) > trafficBodyLimit {
+		msg.Truncated = true
+		shown = prefix[:trafficBodyLimit]
+		// text cut in the middle of a character would be sent base64 encoded
+		for i := 0; i < utf8.UTFMax && !utf8.Valid(shown) && len(shown) > 0; i++ {
+			shown = shown[:len(shown)-1]
+		}
+	}
+	msg.Body, msg.BodyEncoding = encodeBody(string(shown))
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), body), body}, msg
+}
@@ -0,0 +1,143 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// nextTrafficEvent - returns next traffic event or fails test when nothing arrives in time
+func nextTrafficEvent(t *testing.T, events <-chan TrafficEvent) TrafficEvent {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("traffic event was not published")
+	}
+	return TrafficEvent{}
+}
+
+func TestObserveTrafficSummary(t *testing.T) {
+	server, dbClient := testTools(201, `{"message": "here"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	events, unsubscribe := dbClient.Traffic.Subscribe(false, "")
+	defer unsubscribe()
+
+	req, err := http.NewRequest("POST", "http://example.com/path?q=1", strings.NewReader("hello"))
+	expect(t, err, nil)
+	_, resp := dbClient.observeTraffic(req, dbClient.processRequest)
+	expect(t, resp.StatusCode, 201)
+
+	ev := nextTrafficEvent(t, events)
+	expect(t, ev.Mode, CaptureMode)
+	expect(t, ev.Method, "POST")
+	expect(t, ev.Destination, "example.com")
+	expect(t, ev.Path, "/path")
+	expect(t, ev.Query, "q=1")
+	expect(t, ev.Status, 201)
+	expect(t, ev.Request == nil, true)
+	expect(t, ev.Response == nil, true)
+}
+
+func TestObserveTrafficBodies(t *testing.T) {
+	server, dbClient := testTools(201, `{"message": "here"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	events, unsubscribe := dbClient.Traffic.Subscribe(true, "")
+	defer unsubscribe()
+	summaries, unsubscribeSummaries := dbClient.Traffic.Subscribe(false, "")
+	defer unsubscribeSummaries()
+	other, unsubscribeOther := dbClient.Traffic.Subscribe(true, "other.com")
+	defer unsubscribeOther()
+
+	req, err := http.NewRequest("POST", "http://example.com/path", strings.NewReader("hello"))
+	expect(t, err, nil)
+	req.Header.Set("Content-Type", "text/plain")
+	_, resp := dbClient.observeTraffic(req, dbClient.processRequest)
+
+	// bodies are still there for the client
+	body, err := ioutil.ReadAll(resp.Body)
+	expect(t, err, nil)
+	expect(t, strings.TrimSpace(string(body)), `{"message": "here"}`)
+
+	ev := nextTrafficEvent(t, events)
+	expect(t, ev.Request.Body, "hello")
+	expect(t, ev.Request.Headers["Content-Type"][0], "text/plain")
+	expect(t, strings.TrimSpace(ev.Response.Body), `{"message": "here"}`)
+	expect(t, ev.Response.Truncated, false)
+
+	// request body reached the destination and was captured
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Body, "hello")
+
+	ev = nextTrafficEvent(t, summaries)
+	expect(t, ev.Request == nil, true)
+
+	select {
+	case <-other:
+		t.Error("traffic of other destination was published")
+	default:
+	}
+}
+
+func TestPeekTrafficBody(t *testing.T) {
+	long := strings.Repeat("a", trafficBodyLimit-1) + "é" + "tail"
+	body, msg := peekTrafficBody(http.Header{}, ioutil.NopCloser(strings.NewReader(long)))
+	expect(t, msg.Truncated, true)
+	expect(t, msg.BodyEncoding, "")
+	expect(t, len(msg.Body), trafficBodyLimit-1)
+
+	rest, err := ioutil.ReadAll(body)
+	expect(t, err, nil)
+	expect(t, string(rest), long)
+
+	_, msg = peekTrafficBody(http.Header{}, ioutil.NopCloser(strings.NewReader("\xff\xfe")))
+	expect(t, msg.Body, "//4=")
+	expect(t, msg.BodyEncoding, BodyEncodingBase64)
+}
+
+func TestTrafficWSHandler(t *testing.T) {
+	server, dbClient := testTools(201, `{"message": "here"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	admin := httptest.NewServer(getBoneRouter(*dbClient))
+	defer admin.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(admin.URL, "http")+"/api/ws?bodies=true", nil)
+	expect(t, err, nil)
+	defer conn.Close()
+
+	// waiting for handler to subscribe
+	for i := 0; i < 100; i++ {
+		if listening, _ := dbClient.Traffic.subscribed(); listening {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	req, err := http.NewRequest("GET", "http://example.com/path", nil)
+	expect(t, err, nil)
+	dbClient.observeTraffic(req, dbClient.processRequest)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var ev TrafficEvent
+	err = conn.ReadJSON(&ev)
+	expect(t, err, nil)
+	expect(t, ev.Destination, "example.com")
+	expect(t, ev.Status, 201)
+	expect(t, strings.TrimSpace(ev.Response.Body), `{"message": "here"}`)
+}
@@ -0,0 +1,107 @@
+package hoverfly
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RequestVerification - describes requests expected in request journal, empty fields match every request. Path,
+// query and headers are matched by patterns, request bodies aren't kept in the journal so they can't be verified.
+// Count, AtLeast and AtMost say how many matching requests are expected, any number is fine when none is set
+type RequestVerification struct {
+	Method      string           `json:"method,omitempty"`
+	Destination string           `json:"destination,omitempty"`
+	Matchers    *RequestMatchers `json:"matchers,omitempty"`
+	// Since - only requests journaled after entry with this ID are counted, so a test can ignore earlier traffic
+	Since   uint64 `json:"since,omitempty"`
+	Count   *int   `json:"count,omitempty"`
+	AtLeast *int   `json:"atLeast,omitempty"`
+	AtMost  *int   `json:"atMost,omitempty"`
+}
+
+// VerificationResult - number of matching requests and whether it is what was expected
+type VerificationResult struct {
+	Count    int            `json:"count"`
+	Verified bool           `json:"verified"`
+	Message  string         `json:"message,omitempty"`
+	Entries  []JournalEntry `json:"entries"`
+}
+
+// validate - checks that patterns can be compiled and expectations make sense
+func (v *RequestVerification) validate() error {
+	if v.Matchers != nil {
+		if v.Matchers.Body != nil {
+			return fmt.Errorf("request bodies are not kept in the journal, body can't be verified")
+		}
+		if err := v.Matchers.validate(); err != nil {
+			return err
+		}
+	}
+	if v.Count != nil && (v.AtLeast != nil || v.AtMost != nil) {
+		return fmt.Errorf("'count' can't be combined with 'atLeast' or 'atMost'")
+	}
+	for name, expected := range map[string]*int{"count": v.Count, "atLeast": v.AtLeast, "atMost": v.AtMost} {
+		if expected != nil && *expected < 0 {
+			return fmt.Errorf("'%s' can't be negative", name)
+		}
+	}
+	if v.AtLeast != nil && v.AtMost != nil && *v.AtLeast > *v.AtMost {
+		return fmt.Errorf("'atLeast' can't be greater than 'atMost'")
+	}
+	return nil
+}
+
+// matches - checks whether journaled request satisfies the verification
+func (v *RequestVerification) matches(entry JournalEntry) bool {
+	if entry.ID <= v.Since {
+		return false
+	}
+	if v.Method != "" && !strings.EqualFold(v.Method, entry.Method) {
+		return false
+	}
+	if v.Destination != "" && v.Destination != entry.Destination {
+		return false
+	}
+	return v.Matchers == nil || v.Matchers.matches(entry.Path, entry.Query, entry.Headers)
+}
+
+// expectation - describes expected number of requests and checks whether count satisfies it
+func (v *RequestVerification) expectation(count int) (string, bool) {
+	switch {
+	case v.Count != nil:
+		return fmt.Sprintf("exactly %d", *v.Count), count == *v.Count
+	case v.AtLeast != nil && v.AtMost != nil:
+		return fmt.Sprintf("between %d and %d", *v.AtLeast, *v.AtMost), count >= *v.AtLeast && count <= *v.AtMost
+	case v.AtLeast != nil:
+		return fmt.Sprintf("at least %d", *v.AtLeast), count >= *v.AtLeast
+	case v.AtMost != nil:
+		return fmt.Sprintf("at most %d", *v.AtMost), count <= *v.AtMost
+	}
+	return "", true
+}
+
+// Verify - counts journaled requests satisfying the verification and checks the count against expectation, only
+// requests still kept in the journal are counted
+func (j *Journal) Verify(v RequestVerification) (VerificationResult, error) {
+	if err := v.validate(); err != nil {
+		return VerificationResult{}, err
+	}
+
+	j.mu.Lock()
+	result := VerificationResult{Entries: []JournalEntry{}}
+	for i := range j.entries {
+		entry := j.entries[(j.start+i)%len(j.entries)]
+		if v.matches(entry) {
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+	j.mu.Unlock()
+
+	result.Count = len(result.Entries)
+	expected, ok := v.expectation(result.Count)
+	result.Verified = ok
+	if !ok {
+		result.Message = fmt.Sprintf("expected %s matching requests, found %d", expected, result.Count)
+	}
+	return result, nil
+}
@@ -0,0 +1,131 @@
+package hoverfly
+
+import (
+	"net/http"
+	"testing"
+)
+
+// paymentsJournal - journal with two charges and one refund of payments API and one request elsewhere
+func paymentsJournal() *Journal {
+	journal := NewJournal(10)
+	journal.Add(JournalEntry{Method: "POST", Destination: "payments.example.com", Path: "/v1/charges",
+		Headers: map[string][]string{"Idempotency-Key": {"abc"}}})
+	journal.Add(JournalEntry{Method: "POST", Destination: "payments.example.com", Path: "/v1/charges",
+		Headers: map[string][]string{"Idempotency-Key": {"def"}}})
+	journal.Add(JournalEntry{Method: "POST", Destination: "payments.example.com", Path: "/v1/refunds/ch_1",
+		Query: "reason=duplicate"})
+	journal.Add(JournalEntry{Method: "GET", Destination: "users.example.com", Path: "/v1/users/1"})
+	return journal
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestVerifyCount(t *testing.T) {
+	journal := paymentsJournal()
+
+	result, err := journal.Verify(RequestVerification{
+		Method:      "post",
+		Destination: "payments.example.com",
+		Matchers:    &RequestMatchers{Path: &FieldMatcher{Glob: "/v1/charges"}},
+		Count:       intPtr(2),
+	})
+	expect(t, err, nil)
+	expect(t, result.Count, 2)
+	expect(t, result.Verified, true)
+	expect(t, result.Message, "")
+	expect(t, len(result.Entries), 2)
+
+	result, err = journal.Verify(RequestVerification{
+		Destination: "payments.example.com",
+		Matchers:    &RequestMatchers{Path: &FieldMatcher{Glob: "/v1/charges"}},
+		Count:       intPtr(1),
+	})
+	expect(t, err, nil)
+	expect(t, result.Verified, false)
+	expect(t, result.Message, "expected exactly 1 matching requests, found 2")
+}
+
+func TestVerifyMatchers(t *testing.T) {
+	journal := paymentsJournal()
+
+	result, err := journal.Verify(RequestVerification{
+		Matchers: &RequestMatchers{Headers: map[string]FieldMatcher{"idempotency-key": {Glob: "abc"}}},
+	})
+	expect(t, err, nil)
+	expect(t, result.Count, 1)
+
+	result, err = journal.Verify(RequestVerification{
+		Matchers: &RequestMatchers{
+			Path:  &FieldMatcher{Glob: "/v1/refunds/*"},
+			Query: map[string]FieldMatcher{"reason": {Regex: "^dup"}},
+		},
+	})
+	expect(t, err, nil)
+	expect(t, result.Count, 1)
+	expect(t, result.Entries[0].Path, "/v1/refunds/ch_1")
+}
+
+func TestVerifyRange(t *testing.T) {
+	journal := paymentsJournal()
+
+	result, err := journal.Verify(RequestVerification{Destination: "payments.example.com", AtLeast: intPtr(1), AtMost: intPtr(3)})
+	expect(t, err, nil)
+	expect(t, result.Count, 3)
+	expect(t, result.Verified, true)
+
+	result, err = journal.Verify(RequestVerification{Destination: "payments.example.com", AtMost: intPtr(2)})
+	expect(t, err, nil)
+	expect(t, result.Verified, false)
+	expect(t, result.Message, "expected at most 2 matching requests, found 3")
+
+	// request was never made
+	result, err = journal.Verify(RequestVerification{Destination: "orders.example.com", Count: intPtr(0)})
+	expect(t, err, nil)
+	expect(t, result.Count, 0)
+	expect(t, result.Verified, true)
+}
+
+func TestVerifySince(t *testing.T) {
+	journal := paymentsJournal()
+
+	result, err := journal.Verify(RequestVerification{Destination: "payments.example.com", Since: 2})
+	expect(t, err, nil)
+	expect(t, result.Count, 1)
+	expect(t, result.Entries[0].ID, uint64(3))
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	journal := paymentsJournal()
+
+	for _, v := range []RequestVerification{
+		{Matchers: &RequestMatchers{Body: &FieldMatcher{Glob: "*"}}},
+		{Matchers: &RequestMatchers{Path: &FieldMatcher{Regex: "("}}},
+		{Count: intPtr(1), AtLeast: intPtr(1)},
+		{AtLeast: intPtr(-1)},
+		{AtLeast: intPtr(3), AtMost: intPtr(2)},
+	} {
+		_, err := journal.Verify(v)
+		refute(t, err, nil)
+	}
+}
+
+func TestVerifyJournaledRequestHeaders(t *testing.T) {
+	server, dbClient := testTools(201, `{"message": "here"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	req, _ := http.NewRequest("POST", "http://payments.example.com/v1/charges", nil)
+	req.Header.Set("Idempotency-Key", "abc")
+	dbClient.journalRequest(req, dbClient.processRequest)
+
+	result, err := dbClient.Journal.Verify(RequestVerification{
+		Method:   "POST",
+		Matchers: &RequestMatchers{Headers: map[string]FieldMatcher{"Idempotency-Key": {Glob: "abc"}}},
+		Count:    intPtr(1),
+	})
+	expect(t, err, nil)
+	expect(t, result.Verified, true)
+}
@@ -0,0 +1,187 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// webhookTimeout - how long Hoverfly waits for webhook receiver to respond
+const webhookTimeout = 10 * time.Second
+
+// Webhook - HTTP callback made after simulated response to matching request is served, so simulated asynchronous
+// flows (i.e. payment confirmations) can push follow-up events to the system under test. Callback body is JSON
+// payload with the request Hoverfly got and the response it returned
+type Webhook struct {
+	// URLPattern - regular expression matched against request host and path, i.e. "api\.example\.com/payments"
+	URLPattern string `json:"urlPattern"`
+	// HTTPMethod - method of matching requests, all methods match when empty
+	HTTPMethod string `json:"httpMethod,omitempty"`
+	// URL - where the callback is sent
+	URL string `json:"url"`
+	// Method - method of the callback, POST when empty
+	Method string `json:"method,omitempty"`
+	// Headers - additional headers of the callback, i.e. signature expected by the receiver
+	Headers map[string]string `json:"headers,omitempty"`
+	// Delay - milliseconds to wait after the response before callback is sent
+	Delay int `json:"delay,omitempty"`
+
+	urlRe *regexp.Regexp
+}
+
+// compile - validates webhook and compiles its URL pattern
+func (h *Webhook) compile() error {
+	re, err := regexp.Compile(h.URLPattern)
+	if err != nil {
+		return fmt.Errorf("invalid URL pattern '%s': %s", h.URLPattern, err.Error())
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook for '%s' needs absolute http or https URL, got '%s'", h.URLPattern, h.URL)
+	}
+	if h.Delay < 0 {
+		return fmt.Errorf("delay of webhook for '%s' can't be negative", h.URLPattern)
+	}
+	h.urlRe = re
+	return nil
+}
+
+// matches - checks whether webhook is fired for given request
+func (h *Webhook) matches(req *http.Request) bool {
+	if h.HTTPMethod != "" && !strings.EqualFold(h.HTTPMethod, req.Method) {
+		return false
+	}
+	return h.urlRe.MatchString(req.Host + req.URL.Path)
+}
+
+// Webhooks - callbacks fired after simulated responses, all matching ones are fired
+type Webhooks struct {
+	mu       sync.RWMutex
+	webhooks []Webhook
+	// HTTP - client callbacks are sent with
+	HTTP *http.Client
+}
+
+// NewWebhooks - returns empty webhook list
+func NewWebhooks() *Webhooks {
+	return &Webhooks{HTTP: &http.Client{Timeout: webhookTimeout}}
+}
+
+// Set - validates and replaces all webhooks, current ones are kept when any of them is invalid
+func (w *Webhooks) Set(webhooks []Webhook) error {
+	compiled := make([]Webhook, len(webhooks))
+	for i, webhook := range webhooks {
+		if err := webhook.compile(); err != nil {
+			return err
+		}
+		compiled[i] = webhook
+	}
+
+	w.mu.Lock()
+	w.webhooks = compiled
+	w.mu.Unlock()
+	return nil
+}
+
+// Get - returns current webhooks
+func (w *Webhooks) Get() []Webhook {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return append([]Webhook{}, w.webhooks...)
+}
+
+// Clear - removes all webhooks
+func (w *Webhooks) Clear() {
+	w.mu.Lock()
+	w.webhooks = nil
+	w.mu.Unlock()
+}
+
+// For - returns webhooks fired for given request
+func (w *Webhooks) For(req *http.Request) []Webhook {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var matching []Webhook
+	for i := range w.webhooks {
+		if w.webhooks[i].matches(req) {
+			matching = append(matching, w.webhooks[i])
+		}
+	}
+	return matching
+}
+
+// send - sends callback with given body
+func (w *Webhooks) send(webhook Webhook, body []byte) error {
+	method := webhook.Method
+	if method == "" {
+		method = "POST"
+	}
+	req, err := http.NewRequest(method, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook receiver responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// fireWebhooks - sends callbacks of webhooks matching the request in the background, callbacks carry the request
+// and the response served to it
+func (d *DBClient) fireWebhooks(req *http.Request, response ResponseDetails) {
+	if d.Webhooks == nil {
+		return
+	}
+	webhooks := d.Webhooks.For(req)
+	if len(webhooks) == 0 {
+		return
+	}
+
+	request, err := getRequestDetails(req)
+	if err != nil {
+		return
+	}
+	body, err := json.Marshal(Payload{Request: request, Response: response})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal webhook payload")
+		return
+	}
+
+	for _, webhook := range webhooks {
+		go func(webhook Webhook) {
+			time.Sleep(time.Duration(webhook.Delay) * time.Millisecond)
+			fields := log.Fields{
+				"url":         webhook.URL,
+				"path":        req.URL.Path,
+				"destination": req.Host,
+			}
+			if err := d.Webhooks.send(webhook, body); err != nil {
+				fields["error"] = err.Error()
+				log.WithFields(fields).Warn("Failed to send webhook")
+				return
+			}
+			log.WithFields(fields).Debug("Webhook sent")
+		}(webhook)
+	}
+}
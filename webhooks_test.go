@@ -0,0 +1,137 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webhookCall - callback received by test receiver
+type webhookCall struct {
+	method string
+	header http.Header
+	body   []byte
+	at     time.Time
+}
+
+// webhookReceiver - returns server passing received callbacks to the channel
+func webhookReceiver() (*httptest.Server, chan webhookCall) {
+	calls := make(chan webhookCall, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		calls <- webhookCall{method: r.Method, header: r.Header, body: body, at: time.Now()}
+	}))
+	return server, calls
+}
+
+// nextWebhookCall - returns next callback or fails test when nothing arrives in time
+func nextWebhookCall(t *testing.T, calls <-chan webhookCall) webhookCall {
+	select {
+	case call := <-calls:
+		return call
+	case <-time.After(time.Second):
+		t.Fatal("webhook was not sent")
+	}
+	return webhookCall{}
+}
+
+func TestWebhookCompile(t *testing.T) {
+	for _, webhook := range []Webhook{
+		{URLPattern: "(", URL: "http://localhost/events"},
+		{URLPattern: "/orders", URL: "/events"},
+		{URLPattern: "/orders", URL: "ftp://localhost/events"},
+		{URLPattern: "/orders", URL: "http://localhost/events", Delay: -1},
+	} {
+		refute(t, webhook.compile(), nil)
+	}
+
+	webhook := Webhook{URLPattern: "/orders", URL: "https://localhost/events"}
+	expect(t, webhook.compile(), nil)
+}
+
+func TestWebhooksFor(t *testing.T) {
+	webhooks := NewWebhooks()
+	err := webhooks.Set([]Webhook{
+		{URLPattern: `example\.com/orders`, HTTPMethod: "POST", URL: "http://localhost/orders"},
+		{URLPattern: `example\.com/`, URL: "http://localhost/all"},
+	})
+	expect(t, err, nil)
+
+	req, _ := http.NewRequest("POST", "http://example.com/orders", nil)
+	expect(t, len(webhooks.For(req)), 2)
+
+	req, _ = http.NewRequest("GET", "http://example.com/orders", nil)
+	matching := webhooks.For(req)
+	expect(t, len(matching), 1)
+	expect(t, matching[0].URL, "http://localhost/all")
+
+	req, _ = http.NewRequest("GET", "http://other.com/orders", nil)
+	expect(t, len(webhooks.For(req)), 0)
+}
+
+func TestWebhookFiredAfterSimulatedResponse(t *testing.T) {
+	server, dbClient := testTools(201, `{"id": "ch_1"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	receiver, calls := webhookReceiver()
+	defer receiver.Close()
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	req, _ := http.NewRequest("POST", "http://payments.example.com/v1/charges", strings.NewReader("amount=10"))
+	dbClient.processRequest(req)
+
+	err := dbClient.Webhooks.Set([]Webhook{{
+		URLPattern: `payments\.example\.com/v1/charges`,
+		URL:        receiver.URL + "/events",
+		Method:     "PUT",
+		Headers:    map[string]string{"X-Signature": "secret"},
+		Delay:      50,
+	}})
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	req, _ = http.NewRequest("POST", "http://payments.example.com/v1/charges", strings.NewReader("amount=10"))
+	served := time.Now()
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, 201)
+
+	call := nextWebhookCall(t, calls)
+	expect(t, call.method, "PUT")
+	expect(t, call.header.Get("X-Signature"), "secret")
+	expect(t, call.header.Get("Content-Type"), "application/json")
+	expect(t, call.at.Sub(served) >= 50*time.Millisecond, true)
+
+	var payload Payload
+	expect(t, json.Unmarshal(call.body, &payload), nil)
+	expect(t, payload.Request.Destination, "payments.example.com")
+	expect(t, payload.Request.Path, "/v1/charges")
+	expect(t, payload.Request.Body, "amount=10")
+	expect(t, payload.Response.Status, 201)
+	expect(t, strings.TrimSpace(payload.Response.Body), `{"id": "ch_1"}`)
+}
+
+func TestWebhookNotFiredForMissedRequest(t *testing.T) {
+	server, dbClient := testTools(201, `{"id": "ch_1"}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+	receiver, calls := webhookReceiver()
+	defer receiver.Close()
+
+	err := dbClient.Webhooks.Set([]Webhook{{URLPattern: `payments\.example\.com`, URL: receiver.URL}})
+	expect(t, err, nil)
+
+	dbClient.Cfg.SetMode(VirtualizeMode)
+	req, _ := http.NewRequest("POST", "http://payments.example.com/v1/charges", nil)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+
+	select {
+	case <-calls:
+		t.Error("webhook was sent for request without record")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
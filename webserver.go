@@ -0,0 +1,132 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// WebserverHandler - serves captured responses directly, for clients that can't be configured to use a proxy and
+// send requests to Hoverfly itself. Only virtualize mode is available, nothing is forwarded
+func (d *DBClient) WebserverHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		d.Counter.Count(VirtualizeMode)
+
+		_, resp := d.journalRequest(req, func(req *http.Request) (*http.Request, *http.Response) {
+			resp, ok := d.rateLimitResponse(req)
+			if !ok {
+				resp, ok = d.errorResponse(req)
+			}
+			if !ok {
+				resp, ok = d.corsPreflightResponse(req)
+			}
+			if !ok {
+				_, resp = d.observeTraffic(req, func(req *http.Request) (*http.Request, *http.Response) {
+					return d.traceRequest(req, func(req *http.Request) (*http.Request, *http.Response) {
+						return req, d.webserverResponse(req)
+					})
+				})
+			}
+			return req, resp
+		})
+		defer resp.Body.Close()
+		writeResponse(w, resp)
+	})
+}
+
+// webserverDestinations - returns destinations request to the webserver is looked up for, its own Host first and
+// then every recorded destination
+func (d *DBClient) webserverDestinations(host string) []string {
+	destinations, err := d.recordedDestinations()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get recorded destinations")
+	}
+	candidates := []string{host}
+	for _, destination := range destinations {
+		if destination != host {
+			candidates = append(candidates, destination)
+		}
+	}
+	return candidates
+}
+
+// webserverRecord - looks for record of the request as if it was sent to the destination
+func (d *DBClient) webserverRecord(req *http.Request, body []byte, destination string) (*http.Request, string, []byte, error) {
+	req.Host = destination
+	key := d.requestFingerprint(req, body)
+	payloadBts, err := d.Cache.Get([]byte(key))
+	if err != nil {
+		if matchedReq, matchedKey, matchedBts, ok := d.matcherRecord(req, body); ok {
+			return matchedReq, matchedKey, matchedBts, nil
+		}
+	}
+	return req, key, payloadBts, err
+}
+
+// webserverResponse - looks for captured response to the request, Host header usually points to Hoverfly, so
+// request is matched against every recorded destination when it wasn't captured for its own Host
+func (d *DBClient) webserverResponse(req *http.Request) *http.Response {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return hoverflyError(req, err, "Failed to read request body", http.StatusBadRequest)
+		}
+	}
+	req.URL.Scheme = "http"
+
+	host := req.Host
+	matchedReq, key, payloadBts, err := d.webserverRecord(req, body, host)
+	destination := host
+	if err != nil {
+		// recorded destinations are only listed when request wasn't captured for its own Host
+		for _, destination = range d.webserverDestinations(host)[1:] {
+			if matchedReq, key, payloadBts, err = d.webserverRecord(req, body, destination); err == nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		matchedReq.URL.Host = destination
+		matchedReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		matchedReq = matchedReq.WithContext(withClientHost(matchedReq.Context(), host))
+		return d.cachedResponse(matchedReq, key, payloadBts, VirtualizeMode)
+	}
+	req.Host = host
+
+	// Host header points to Hoverfly, so records of every destination are compared
+	return d.missResponse(req, body, err, true)
+}
+
+// writeResponse - writes response to the client, event streams are flushed as their events are read
+func writeResponse(w http.ResponseWriter, resp *http.Response) {
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok || !isEventStream(resp.Header) {
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// storeTestPayload - saves captured response to given request
+func storeTestPayload(dbClient *DBClient, method, rawURL, body string, status int, respBody string) {
+	req, _ := http.NewRequest(method, rawURL, strings.NewReader(body))
+	resp := &http.Response{StatusCode: status, Header: http.Header{"Content-Type": []string{"text/plain"}}}
+	dbClient.save(req, []byte(body), resp, []byte(respBody))
+}
+
+func TestWebserverServesCapturedResponses(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users?page=2", "", 201, "users")
+	storeTestPayload(dbClient, "POST", "http://billing.example.com/invoices", "amount=10", 202, "invoice")
+
+	webserver := httptest.NewServer(dbClient.WebserverHandler())
+	defer webserver.Close()
+
+	resp, err := http.Get(webserver.URL + "/users?page=2")
+	expect(t, err, nil)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	expect(t, resp.StatusCode, 201)
+	expect(t, string(body), "users")
+
+	resp, err = http.Post(webserver.URL+"/invoices", "application/x-www-form-urlencoded", strings.NewReader("amount=10"))
+	expect(t, err, nil)
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	expect(t, resp.StatusCode, 202)
+	expect(t, string(body), "invoice")
+
+	// request wasn't captured
+	resp, err = http.Get(webserver.URL + "/users?page=3")
+	expect(t, err, nil)
+	resp.Body.Close()
+	expect(t, resp.StatusCode, DefaultMissStatus)
+}
+
+func TestWebserverPrefersRequestHost(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://a.example.com/status", "", 200, "a")
+	storeTestPayload(dbClient, "GET", "http://b.example.com/status", "", 200, "b")
+
+	webserver := httptest.NewServer(dbClient.WebserverHandler())
+	defer webserver.Close()
+
+	req, err := http.NewRequest("GET", webserver.URL+"/status", nil)
+	expect(t, err, nil)
+	req.Host = "b.example.com"
+
+	resp, err := http.DefaultClient.Do(req)
+	expect(t, err, nil)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	expect(t, string(body), "b")
+}
+
+func TestWebserverListsDestinationsOnlyOnMiss(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://a.example.com/status", "", 200, "a")
+
+	webserver := httptest.NewServer(dbClient.WebserverHandler())
+	defer webserver.Close()
+
+	req, _ := http.NewRequest("GET", webserver.URL+"/status", nil)
+	req.Host = "a.example.com"
+	resp, err := http.DefaultClient.Do(req)
+	expect(t, err, nil)
+	resp.Body.Close()
+	expect(t, resp.StatusCode, 200)
+	expect(t, dbClient.RecordIndex.destinationsBuilt, false)
+
+	resp, err = http.Get(webserver.URL + "/status")
+	expect(t, err, nil)
+	resp.Body.Close()
+	expect(t, resp.StatusCode, 200)
+	expect(t, dbClient.RecordIndex.destinationsBuilt, true)
+
+	// saved record invalidates the list, so its destination is tried on the next miss
+	storeTestPayload(dbClient, "GET", "http://b.example.com/orders", "", 200, "b")
+	resp, err = http.Get(webserver.URL + "/orders")
+	expect(t, err, nil)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	expect(t, string(body), "b")
+}
+
+func TestWebserverAllowsOnlyVirtualizeState(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+	dbClient.Cfg.Webserver = true
+
+	req, err := http.NewRequest("POST", "/state", strings.NewReader(`{"mode": "capture"}`))
+	expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusBadRequest)
+
+	req, err = http.NewRequest("POST", "/state", strings.NewReader(`{"mode": "virtualize"}`))
+	expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	expect(t, rec.Code, http.StatusOK)
+}
+
+func TestSettingsWebserverEnv(t *testing.T) {
+	defer os.Setenv("HoverflyWebserver", "")
+	os.Setenv("HoverflyWebserver", "true")
+
+	expect(t, InitSettings().Webserver, true)
+}
@@ -0,0 +1,345 @@
+package hoverfly
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketMethod - pseudo method websocket sessions are stored under, so they never collide with plain GET
+// requests to the same URL
+const WebSocketMethod = "WEBSOCKET"
+
+// webSocketCloseWait - how long client has to acknowledge closing of replayed connection
+const webSocketCloseWait = time.Second
+
+// WebSocketFrame - single message sent through captured websocket connection, binary messages are base64 encoded
+type WebSocketFrame struct {
+	// FromClient - message was sent by client, otherwise it was sent by destination
+	FromClient bool   `json:"fromClient"`
+	Binary     bool   `json:"binary,omitempty"`
+	Data       string `json:"data"`
+}
+
+// newWebSocketFrame - returns frame for given websocket message
+func newWebSocketFrame(messageType int, data []byte, fromClient bool) WebSocketFrame {
+	if messageType == websocket.BinaryMessage {
+		return WebSocketFrame{FromClient: fromClient, Binary: true, Data: base64.StdEncoding.EncodeToString(data)}
+	}
+	return WebSocketFrame{FromClient: fromClient, Data: string(data)}
+}
+
+// message - returns websocket message type and data of the frame
+func (f WebSocketFrame) message() (int, []byte, error) {
+	if f.Binary {
+		data, err := base64.StdEncoding.DecodeString(f.Data)
+		return websocket.BinaryMessage, data, err
+	}
+	return websocket.TextMessage, []byte(f.Data), nil
+}
+
+// isWebSocketRequest - checks whether request asks for websocket upgrade
+func isWebSocketRequest(req *http.Request) bool {
+	return headerContains(req.Header, "Connection", "upgrade") && headerContains(req.Header, "Upgrade", "websocket")
+}
+
+// headerContains - checks whether comma separated header values contain given value, case is ignored
+func headerContains(header http.Header, name, value string) bool {
+	for _, v := range header[name] {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getWebSocketFingerprint - returns key websocket session to given URL is stored under
+func getWebSocketFingerprint(req *http.Request) string {
+	r := RequestContainer{Details: RequestDetails{
+		Path:        req.URL.Path,
+		Method:      WebSocketMethod,
+		Destination: req.Host,
+		Query:       req.URL.RawQuery,
+	}}
+	return r.Hash()
+}
+
+// WebSocketHandler - wraps proxy so that websocket upgrades to matching destinations are captured and virtualized,
+// all other requests are handled by the proxy itself. Upgrades are accepted both in proxy (absolute URL) form
+// and in origin form, where destination is taken from Host header
+func (d *DBClient) WebSocketHandler(proxy http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !isWebSocketRequest(req) || req.Host == "" ||
+			!d.Cfg.MatchesDestination(req.Host) || d.Cfg.IsPassthrough(req.Host) {
+			proxy.ServeHTTP(w, req)
+			return
+		}
+		if !req.URL.IsAbs() {
+			req.URL.Scheme = "http"
+			req.URL.Host = req.Host
+		}
+		d.serveWebSocket(w, req)
+	})
+}
+
+// serveWebSocket - replays captured websocket session in virtualize mode (and spy mode when session was
+// captured), other modes relay messages to destination and capture mode records them
+func (d *DBClient) serveWebSocket(w http.ResponseWriter, req *http.Request) {
+	mode := d.Cfg.GetMode()
+	d.Counter.Count(mode)
+
+	key := getWebSocketFingerprint(req)
+
+	if mode == VirtualizeMode || mode == SpyMode {
+		payloadBts, err := d.Cache.Get([]byte(key))
+		if err == nil {
+			d.replayWebSocket(w, req, key, payloadBts)
+			return
+		}
+		if mode == VirtualizeMode {
+			log.WithFields(log.Fields{
+				"key":         key,
+				"path":        req.URL.Path,
+				"destination": req.Host,
+			}).Warn("Failed to retrieve websocket session from cache")
+			http.Error(w, "Hoverfly Error! Could not find recorded websocket session, please record it first!",
+				d.Cfg.GetMissStatus())
+			return
+		}
+	}
+
+	record := mode == CaptureMode || (mode == SpyMode && d.Cfg.SpyCapture)
+	d.relayWebSocket(w, req, key, record)
+}
+
+// replayWebSocket - sends captured destination messages to the client, captured client messages are awaited
+// (but not compared) so the conversation keeps its order
+func (d *DBClient) replayWebSocket(w http.ResponseWriter, req *http.Request, key string, payloadBts []byte) {
+	payload, err := decodePayload(payloadBts)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Error("Failed to decode payload")
+		http.Error(w, "Hoverfly Error! Failed to virtualize websocket session.", http.StatusInternalServerError)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, req, protocolHeader(payload.Response.Headers))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Error("Failed to upgrade websocket connection")
+		return
+	}
+	defer conn.Close()
+
+	for _, frame := range payload.Frames {
+		if frame.FromClient {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			continue
+		}
+
+		messageType, data, err := frame.message()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"key":   key,
+			}).Error("Failed to decode websocket frame")
+			return
+		}
+		if err := conn.WriteMessage(messageType, data); err != nil {
+			return
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"key":         key,
+		"path":        req.URL.Path,
+		"destination": req.Host,
+		"frames":      len(payload.Frames),
+	}).Info("Websocket session replayed")
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	// waiting for client to acknowledge closing
+	conn.SetReadDeadline(time.Now().Add(webSocketCloseWait))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// protocolHeader - returns header with subprotocol chosen by destination, nil when none was chosen
+func protocolHeader(headers map[string][]string) http.Header {
+	protocol := http.Header(headers).Get("Sec-Websocket-Protocol")
+	if protocol == "" {
+		return nil
+	}
+	return http.Header{"Sec-Websocket-Protocol": []string{protocol}}
+}
+
+// webSocketHandshakeHeaders - headers that are set by websocket dialer itself
+var webSocketHandshakeHeaders = map[string]bool{
+	"Upgrade":                  true,
+	"Connection":               true,
+	"Sec-Websocket-Key":        true,
+	"Sec-Websocket-Version":    true,
+	"Sec-Websocket-Extensions": true,
+	"Proxy-Connection":         true,
+	"Proxy-Authorization":      true,
+}
+
+// relayWebSocket - connects to destination and relays messages in both directions until either side closes
+// the connection, exchanged messages are captured when record is set
+func (d *DBClient) relayWebSocket(w http.ResponseWriter, req *http.Request, key string, record bool) {
+	target := *req.URL
+	target.Scheme = "ws"
+	if req.URL.Scheme == "https" || req.URL.Scheme == "wss" {
+		target.Scheme = "wss"
+	}
+
+	header := make(http.Header)
+	for name, values := range req.Header {
+		if !webSocketHandshakeHeaders[http.CanonicalHeaderKey(name)] {
+			header[name] = values
+		}
+	}
+
+	tlsConfig, err := NewUpstreamTLSConfig(d.Cfg)
+	if err != nil {
+		http.Error(w, "Hoverfly Error! Failed to configure TLS for destination.", http.StatusServiceUnavailable)
+		return
+	}
+	proxy, err := NewUpstreamProxyFunc(d.Cfg)
+	if err != nil {
+		http.Error(w, "Hoverfly Error! Failed to configure upstream proxy.", http.StatusServiceUnavailable)
+		return
+	}
+	dialer := websocket.Dialer{
+		Proxy:           proxy,
+		TLSClientConfig: tlsConfig,
+	}
+
+	upstream, resp, err := dialer.Dial(target.String(), header)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"destination": redactedURL(&target),
+		}).Error("Failed to open websocket connection to destination")
+		status := http.StatusServiceUnavailable
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		http.Error(w, "Hoverfly Error! Could not open websocket connection to destination.", status)
+		return
+	}
+	defer upstream.Close()
+
+	client, err := upgrader.Upgrade(w, req, protocolHeader(resp.Header))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Error("Failed to upgrade websocket connection")
+		return
+	}
+	defer client.Close()
+
+	frames := pipeWebSockets(client, upstream)
+
+	log.WithFields(log.Fields{
+		"key":         key,
+		"path":        req.URL.Path,
+		"destination": req.Host,
+		"frames":      len(frames),
+		"captured":    record,
+	}).Info("Websocket connection closed")
+
+	if !record {
+		return
+	}
+
+	payload := Payload{
+		Request: RequestDetails{
+			Path:        req.URL.Path,
+			Method:      WebSocketMethod,
+			Destination: req.Host,
+			Scheme:      req.URL.Scheme,
+			Query:       req.URL.RawQuery,
+			RemoteAddr:  req.RemoteAddr,
+			Headers:     req.Header,
+		},
+		Response: ResponseDetails{
+			Status:  resp.StatusCode,
+			Headers: resp.Header,
+		},
+		ID:     key,
+		Frames: frames,
+	}
+	d.applyCaptureHeaderRules(&payload)
+	d.storePayload(key, payload)
+}
+
+// pipeWebSockets - copies messages between client and destination until either side closes the connection,
+// returns messages in the order they were relayed
+func pipeWebSockets(client, upstream *websocket.Conn) []WebSocketFrame {
+	var mu sync.Mutex
+	var frames []WebSocketFrame
+	done := make(chan struct{}, 2)
+
+	pipe := func(src, dst *websocket.Conn, fromClient bool) {
+		defer func() { done <- struct{}{} }()
+		for {
+			messageType, data, err := src.ReadMessage()
+			if err != nil {
+				dst.WriteMessage(websocket.CloseMessage, closeMessage(err))
+				return
+			}
+			if err := dst.WriteMessage(messageType, data); err != nil {
+				return
+			}
+			mu.Lock()
+			frames = append(frames, newWebSocketFrame(messageType, data, fromClient))
+			mu.Unlock()
+		}
+	}
+
+	go pipe(client, upstream, true)
+	go pipe(upstream, client, false)
+
+	// once one side is done, the other one is unblocked by closing both connections
+	<-done
+	client.Close()
+	upstream.Close()
+	<-done
+
+	return frames
+}
+
+// closeMessage - returns close message passed on to the other side of relayed connection
+func closeMessage(err error) []byte {
+	if ce, ok := err.(*websocket.CloseError); ok && ce.Code != websocket.CloseNoStatusReceived &&
+		ce.Code != websocket.CloseAbnormalClosure {
+		return websocket.FormatCloseMessage(ce.Code, ce.Text)
+	}
+	return websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+}
+
+// redactedURL - returns URL without user credentials so it can be logged
+func redactedURL(u *url.URL) string {
+	c := *u
+	c.User = nil
+	return c.String()
+}
@@ -0,0 +1,146 @@
+package hoverfly
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// webSocketEchoServer - returns server that answers every message with "echo: " prefixed copy
+func webSocketEchoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append([]byte("echo: "), data...))
+		}
+	}))
+}
+
+// dialThroughHoverfly - opens websocket connection to given URL, TCP connection goes to Hoverfly instead
+func dialThroughHoverfly(hoverfly *httptest.Server, target string) (*websocket.Conn, *http.Response, error) {
+	dialer := websocket.Dialer{
+		NetDial: func(network, addr string) (net.Conn, error) {
+			return net.Dial(network, strings.TrimPrefix(hoverfly.URL, "http://"))
+		},
+	}
+	return dialer.Dial(target, nil)
+}
+
+// waitForRecords - waits until cache contains given number of records, websocket sessions are saved once the
+// connection is closed
+func waitForRecords(t *testing.T, cache Cache, count int) {
+	for i := 0; i < 100; i++ {
+		if c, _ := cache.RecordsCount(); c == count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("cache doesn't contain %d records", count)
+}
+
+func TestWebSocketCaptureAndVirtualize(t *testing.T) {
+	upstream := webSocketEchoServer()
+	target := "ws" + strings.TrimPrefix(upstream.URL, "http") + "/echo"
+
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	proxy, dbClient := GetNewHoverfly(cfg, NewMemoryCache())
+	hoverfly := httptest.NewServer(dbClient.WebSocketHandler(proxy))
+	defer hoverfly.Close()
+
+	conn, _, err := dialThroughHoverfly(hoverfly, target)
+	expect(t, err, nil)
+
+	expect(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")), nil)
+	_, data, err := conn.ReadMessage()
+	expect(t, err, nil)
+	expect(t, string(data), "echo: hello")
+
+	expect(t, conn.WriteMessage(websocket.BinaryMessage, []byte{1, 2}), nil)
+	messageType, data, err := conn.ReadMessage()
+	expect(t, err, nil)
+	expect(t, messageType, websocket.BinaryMessage)
+	expect(t, string(data), "echo: \x01\x02")
+	conn.Close()
+
+	waitForRecords(t, dbClient.Cache, 1)
+
+	payloads, err := dbClient.Cache.GetAllRequests()
+	expect(t, err, nil)
+	expect(t, payloads[0].Request.Method, WebSocketMethod)
+	expect(t, payloads[0].Request.Path, "/echo")
+	expect(t, payloads[0].Response.Status, http.StatusSwitchingProtocols)
+	expect(t, len(payloads[0].Frames), 4)
+	expect(t, payloads[0].Frames[0], WebSocketFrame{FromClient: true, Data: "hello"})
+	expect(t, payloads[0].Frames[1], WebSocketFrame{FromClient: false, Data: "echo: hello"})
+	expect(t, payloads[0].Frames[3].Binary, true)
+
+	// destination goes away, session is replayed from cache
+	upstream.Close()
+	cfg.SetMode(VirtualizeMode)
+
+	conn, _, err = dialThroughHoverfly(hoverfly, target)
+	expect(t, err, nil)
+	defer conn.Close()
+
+	expect(t, conn.WriteMessage(websocket.TextMessage, []byte("anything")), nil)
+	_, data, err = conn.ReadMessage()
+	expect(t, err, nil)
+	expect(t, string(data), "echo: hello")
+
+	expect(t, conn.WriteMessage(websocket.BinaryMessage, []byte{3}), nil)
+	messageType, data, err = conn.ReadMessage()
+	expect(t, err, nil)
+	expect(t, messageType, websocket.BinaryMessage)
+	expect(t, string(data), "echo: \x01\x02")
+
+	_, _, err = conn.ReadMessage()
+	expect(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), true)
+}
+
+func TestWebSocketVirtualizeNotRecorded(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(VirtualizeMode)
+	proxy, dbClient := GetNewHoverfly(cfg, NewMemoryCache())
+	hoverfly := httptest.NewServer(dbClient.WebSocketHandler(proxy))
+	defer hoverfly.Close()
+
+	_, resp, err := dialThroughHoverfly(hoverfly, "ws://somehost.com/echo")
+	expect(t, err, websocket.ErrBadHandshake)
+	expect(t, resp.StatusCode, DefaultMissStatus)
+}
+
+func TestWebSocketFingerprintDiffersFromGet(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://somehost.com/echo", nil)
+	expect(t, err, nil)
+
+	refute(t, getWebSocketFingerprint(req), getRequestFingerprint(req, nil))
+}
+
+func TestProcessRequestLeavesWebSocketToProxy(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	req, err := http.NewRequest("GET", "https://somehost.com/echo", nil)
+	expect(t, err, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	_, resp := dbClient.processRequest(req)
+	expect(t, resp == nil, true)
+}
@@ -0,0 +1,505 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// wireMockDefaultPriority - priority of WireMock mappings that don't set one, lower numbers win in WireMock
+const wireMockDefaultPriority = 5
+
+// wireMockStartedState - initial state of WireMock scenarios, in Hoverfly it's the state without the key
+const wireMockStartedState = "Started"
+
+// wireMockAnyMethods - methods mappings with "ANY" method are imported for
+var wireMockAnyMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+
+// WireMockMapping - WireMock stub mapping, see http://wiremock.org/docs/stubbing/
+type WireMockMapping struct {
+	Request               WireMockRequest  `json:"request"`
+	Response              WireMockResponse `json:"response"`
+	Priority              int              `json:"priority,omitempty"`
+	ScenarioName          string           `json:"scenarioName,omitempty"`
+	RequiredScenarioState string           `json:"requiredScenarioState,omitempty"`
+	NewScenarioState      string           `json:"newScenarioState,omitempty"`
+}
+
+// WireMockRequest - request pattern of WireMock mapping, only one of the URL fields is set
+type WireMockRequest struct {
+	Method          string                     `json:"method,omitempty"`
+	URL             string                     `json:"url,omitempty"`
+	URLPath         string                     `json:"urlPath,omitempty"`
+	URLPattern      string                     `json:"urlPattern,omitempty"`
+	URLPathPattern  string                     `json:"urlPathPattern,omitempty"`
+	QueryParameters map[string]WireMockPattern `json:"queryParameters,omitempty"`
+	Headers         map[string]WireMockPattern `json:"headers,omitempty"`
+	BodyPatterns    []WireMockPattern          `json:"bodyPatterns,omitempty"`
+}
+
+// WireMockPattern - value pattern of WireMock, i.e. {"equalTo": "json"} or {"matchesJsonPath": "$.order.id"}
+type WireMockPattern struct {
+	EqualTo         *string         `json:"equalTo,omitempty"`
+	Contains        *string         `json:"contains,omitempty"`
+	Matches         *string         `json:"matches,omitempty"`
+	DoesNotMatch    *string         `json:"doesNotMatch,omitempty"`
+	Absent          bool            `json:"absent,omitempty"`
+	CaseInsensitive bool            `json:"caseInsensitive,omitempty"`
+	EqualToJSON     json.RawMessage `json:"equalToJson,omitempty"`
+	MatchesJSONPath json.RawMessage `json:"matchesJsonPath,omitempty"`
+	EqualToXML      *string         `json:"equalToXml,omitempty"`
+	MatchesXPath    *string         `json:"matchesXPath,omitempty"`
+}
+
+// WireMockResponse - response of WireMock mapping, body is given by Body, JSONBody, Base64Body or BodyFileName
+type WireMockResponse struct {
+	Status                 int                        `json:"status,omitempty"`
+	Body                   string                     `json:"body,omitempty"`
+	JSONBody               json.RawMessage            `json:"jsonBody,omitempty"`
+	Base64Body             string                     `json:"base64Body,omitempty"`
+	BodyFileName           string                     `json:"bodyFileName,omitempty"`
+	Headers                map[string]json.RawMessage `json:"headers,omitempty"`
+	FixedDelayMilliseconds int                        `json:"fixedDelayMilliseconds,omitempty"`
+	Fault                  string                     `json:"fault,omitempty"`
+	Transformers           []string                   `json:"transformers,omitempty"`
+}
+
+// isWireMockMapping - checks whether JSON document is WireMock mapping file, either single mapping or
+// {"mappings": [...]} as WireMock exports them
+func isWireMockMapping(data []byte) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	if _, ok := fields["mappings"]; ok {
+		return true
+	}
+	_, hasRequest := fields["request"]
+	_, hasResponse := fields["response"]
+	return hasRequest && hasResponse
+}
+
+// parseWireMockMappings - reads mappings of WireMock mapping file
+func parseWireMockMappings(data []byte) ([]WireMockMapping, error) {
+	if !isWireMockMapping(data) {
+		return nil, fmt.Errorf("Got error while parsing WireMock mappings, document has neither 'mappings' nor 'request' and 'response'")
+	}
+
+	var file struct {
+		Mappings []WireMockMapping `json:"mappings"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Got error while parsing WireMock mappings, error %s", err.Error())
+	}
+	if file.Mappings != nil {
+		return file.Mappings, nil
+	}
+
+	var mapping WireMockMapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("Got error while parsing WireMock mapping, error %s", err.Error())
+	}
+	return []WireMockMapping{mapping}, nil
+}
+
+// regex - returns regular expression of text pattern, equalTo and matches have to match the whole value
+func (p WireMockPattern) regex() (string, error) {
+	flags := ""
+	if p.CaseInsensitive {
+		flags = "(?i)"
+	}
+	switch {
+	case p.EqualTo != nil:
+		return flags + "^" + regexp.QuoteMeta(*p.EqualTo) + "$", nil
+	case p.Contains != nil:
+		return flags + regexp.QuoteMeta(*p.Contains), nil
+	case p.Matches != nil:
+		return flags + "^(?:" + *p.Matches + ")$", nil
+	case p.DoesNotMatch != nil || p.Absent:
+		return "", fmt.Errorf("'doesNotMatch' and 'absent' patterns are not supported")
+	}
+	return "", fmt.Errorf("only 'equalTo', 'contains' and 'matches' patterns are supported")
+}
+
+// jsonLeafMatchers - returns JSONPath matchers comparing every leaf value of JSON document, extra fields of
+// request bodies are ignored
+func jsonLeafMatchers(path string, value interface{}) []string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return []string{path + " == {}"}
+		}
+		var matchers []string
+		for _, key := range sortedKeys(v) {
+			matchers = append(matchers, jsonLeafMatchers(fmt.Sprintf("%s[\"%s\"]", path, key), v[key])...)
+		}
+		return matchers
+	case []interface{}:
+		if len(v) == 0 {
+			return []string{path + " == []"}
+		}
+		var matchers []string
+		for i, item := range v {
+			matchers = append(matchers, jsonLeafMatchers(fmt.Sprintf("%s[%d]", path, i), item)...)
+		}
+		return matchers
+	}
+	literal, _ := json.Marshal(value)
+	return []string{path + " == " + string(literal)}
+}
+
+// jsonPathMatcher - converts matchesJsonPath pattern, either expression or {"expression": ..., "equalTo": ...}
+func jsonPathMatcher(raw json.RawMessage) (string, error) {
+	var expression string
+	if err := json.Unmarshal(raw, &expression); err == nil {
+		return expression, nil
+	}
+
+	var pattern struct {
+		Expression string `json:"expression"`
+		WireMockPattern
+	}
+	if err := json.Unmarshal(raw, &pattern); err != nil {
+		return "", fmt.Errorf("invalid 'matchesJsonPath': %s", err.Error())
+	}
+	if pattern.EqualTo != nil {
+		// numbers and booleans are compared as JSON values, everything else as string
+		var value interface{}
+		if err := json.Unmarshal([]byte(*pattern.EqualTo), &value); err == nil {
+			switch value.(type) {
+			case float64, bool:
+				return pattern.Expression + " == " + *pattern.EqualTo, nil
+			}
+		}
+		literal, _ := json.Marshal(*pattern.EqualTo)
+		return pattern.Expression + " == " + string(literal), nil
+	}
+	re, err := pattern.regex()
+	if err != nil {
+		return "", err
+	}
+	literal, _ := json.Marshal(re)
+	return pattern.Expression + " =~ " + string(literal), nil
+}
+
+// headerList - returns header value of WireMock response, either string or list of strings
+func headerList(raw json.RawMessage) ([]string, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return []string{value}, nil
+	}
+	var values []string
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, fmt.Errorf("invalid header value %s", string(raw))
+	}
+	return values, nil
+}
+
+// wireMockResponse - converts WireMock response, body files are read from filesDir
+func wireMockResponse(response WireMockResponse, filesDir string) (ResponseDetails, error) {
+	if response.Fault != "" {
+		return ResponseDetails{}, fmt.Errorf("fault '%s' is not supported", response.Fault)
+	}
+
+	details := ResponseDetails{Status: response.Status, Headers: map[string][]string{}}
+	if details.Status == 0 {
+		details.Status = 200
+	}
+
+	switch {
+	case response.Body != "":
+		details.Body = response.Body
+	case len(response.JSONBody) > 0:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, response.JSONBody); err != nil {
+			return ResponseDetails{}, fmt.Errorf("invalid 'jsonBody': %s", err.Error())
+		}
+		details.Body = buf.String()
+	case response.Base64Body != "":
+		body, err := base64.StdEncoding.DecodeString(response.Base64Body)
+		if err != nil {
+			return ResponseDetails{}, fmt.Errorf("invalid 'base64Body': %s", err.Error())
+		}
+		details.Body = string(body)
+	case response.BodyFileName != "":
+		if filesDir == "" {
+			return ResponseDetails{}, fmt.Errorf("body file '%s' can't be read, __files directory is unknown", response.BodyFileName)
+		}
+		body, err := ioutil.ReadFile(filepath.Join(filesDir, filepath.FromSlash(response.BodyFileName)))
+		if err != nil {
+			return ResponseDetails{}, fmt.Errorf("failed to read body file: %s", err.Error())
+		}
+		details.Body = string(body)
+	}
+
+	for name, raw := range response.Headers {
+		values, err := headerList(raw)
+		if err != nil {
+			return ResponseDetails{}, fmt.Errorf("header '%s': %s", name, err.Error())
+		}
+		details.Headers[name] = values
+	}
+	return details, nil
+}
+
+// wireMockPayloads - converts WireMock mapping into records (one for each method of "ANY" mappings) and returns
+// regular expression of its path, used to delay responses
+func wireMockPayloads(mapping WireMockMapping, filesDir, destination string) ([]Payload, string, error) {
+	response, err := wireMockResponse(mapping.Response, filesDir)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, transformer := range mapping.Response.Transformers {
+		if transformer == "response-template" {
+			log.WithFields(log.Fields{
+				"url": mapping.Request.URL + mapping.Request.URLPath + mapping.Request.URLPattern + mapping.Request.URLPathPattern,
+			}).Warn("WireMock response templates are not converted, response is returned as it is")
+		}
+	}
+
+	request := RequestDetails{Destination: destination, Scheme: "http"}
+	matchers := &RequestMatchers{}
+	pathRe := ".*"
+	hasQuery := false
+	switch r := mapping.Request; {
+	case r.URL != "":
+		parts := strings.SplitN(r.URL, "?", 2)
+		request.Path = parts[0]
+		if len(parts) == 2 {
+			request.Query = parts[1]
+		}
+		pathRe, hasQuery = regexp.QuoteMeta(request.Path), true
+	case r.URLPath != "":
+		request.Path = r.URLPath
+		pathRe = regexp.QuoteMeta(request.Path)
+	case r.URLPathPattern != "":
+		pathRe = r.URLPathPattern
+		matchers.Path = &FieldMatcher{Regex: "^(?:" + pathRe + ")$"}
+	case r.URLPattern != "":
+		// pattern matches path with query, it's split where it matches the question mark
+		pathRe = r.URLPattern
+		if i := strings.Index(r.URLPattern, `\?`); i >= 0 {
+			pathRe = r.URLPattern[:i]
+			matchers.RawQuery = &FieldMatcher{Regex: "^(?:" + r.URLPattern[i+2:] + ")$"}
+			hasQuery = true
+		}
+		matchers.Path = &FieldMatcher{Regex: "^(?:" + pathRe + ")$"}
+	default:
+		matchers.Path = &FieldMatcher{Glob: "**"}
+	}
+
+	for name, pattern := range mapping.Request.QueryParameters {
+		re, err := pattern.regex()
+		if err != nil {
+			return nil, "", fmt.Errorf("query parameter '%s': %s", name, err.Error())
+		}
+		if matchers.Query == nil {
+			matchers.Query = make(map[string]FieldMatcher)
+		}
+		matchers.Query[name] = FieldMatcher{Regex: re}
+		hasQuery = true
+	}
+	if !hasQuery {
+		// WireMock ignores query unless it's matched
+		matchers.RawQuery = &FieldMatcher{Glob: "**"}
+	}
+
+	for name, pattern := range mapping.Request.Headers {
+		re, err := pattern.regex()
+		if err != nil {
+			return nil, "", fmt.Errorf("header '%s': %s", name, err.Error())
+		}
+		if matchers.Headers == nil {
+			matchers.Headers = make(map[string]FieldMatcher)
+		}
+		matchers.Headers[name] = FieldMatcher{Regex: re}
+	}
+
+	var jsonPathMatchers, xpathMatchers []string
+	for _, pattern := range mapping.Request.BodyPatterns {
+		switch {
+		case len(pattern.EqualToJSON) > 0:
+			var value interface{}
+			raw := pattern.EqualToJSON
+			// JSON is given either as value or as string
+			var text string
+			if json.Unmarshal(raw, &text) == nil {
+				raw = json.RawMessage(text)
+			}
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, "", fmt.Errorf("invalid 'equalToJson': %s", err.Error())
+			}
+			jsonPathMatchers = append(jsonPathMatchers, jsonLeafMatchers("$", value)...)
+		case len(pattern.MatchesJSONPath) > 0:
+			expression, err := jsonPathMatcher(pattern.MatchesJSONPath)
+			if err != nil {
+				return nil, "", err
+			}
+			jsonPathMatchers = append(jsonPathMatchers, expression)
+		case pattern.MatchesXPath != nil:
+			xpathMatchers = append(xpathMatchers, *pattern.MatchesXPath)
+		case pattern.EqualToXML != nil:
+			return nil, "", fmt.Errorf("'equalToXml' body pattern is not supported, use 'matchesXPath'")
+		default:
+			if matchers.Body != nil {
+				return nil, "", fmt.Errorf("only one text body pattern is supported")
+			}
+			re, err := pattern.regex()
+			if err != nil {
+				return nil, "", fmt.Errorf("body: %s", err.Error())
+			}
+			// bodies can have more lines
+			matchers.Body = &FieldMatcher{Regex: "(?s)" + re}
+		}
+	}
+	if len(mapping.Request.BodyPatterns) == 0 {
+		matchers.Body = &FieldMatcher{Glob: "**"}
+	}
+
+	var requiresState, transitionsState map[string]string
+	if mapping.ScenarioName != "" {
+		if mapping.RequiredScenarioState != "" {
+			requiresState = map[string]string{mapping.ScenarioName: scenarioValue(mapping.RequiredScenarioState)}
+		}
+		if mapping.NewScenarioState != "" {
+			transitionsState = map[string]string{mapping.ScenarioName: scenarioValue(mapping.NewScenarioState)}
+		}
+	}
+
+	priority := 0
+	if mapping.Priority > 0 {
+		priority = wireMockDefaultPriority - mapping.Priority
+	}
+
+	methods := []string{strings.ToUpper(mapping.Request.Method)}
+	if methods[0] == "" || methods[0] == "ANY" {
+		methods = wireMockAnyMethods
+	}
+	payloads := make([]Payload, 0, len(methods))
+	for _, method := range methods {
+		methodRequest := request
+		methodRequest.Method = method
+		payloads = append(payloads, Payload{
+			Request:          methodRequest,
+			Response:         response,
+			Matchers:         matchers,
+			JSONPathMatchers: jsonPathMatchers,
+			XPathMatchers:    xpathMatchers,
+			RequiresState:    requiresState,
+			TransitionsState: transitionsState,
+			Priority:         priority,
+		})
+	}
+	return payloads, pathRe, nil
+}
+
+// scenarioValue - returns Hoverfly state value of WireMock scenario state
+func scenarioValue(state string) string {
+	if state == wireMockStartedState {
+		return ""
+	}
+	return state
+}
+
+// ImportWireMock - reads WireMock mapping file and imports its mappings as records. Body files are read from
+// filesDir (the __files directory), destination is the host records are imported for, "localhost" when empty.
+// Mappings that can't be converted are logged and skipped
+func (d *DBClient) ImportWireMock(r io.Reader, filesDir, destination string) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("Got error while reading WireMock mappings, error %s", err.Error())
+	}
+	mappings, err := parseWireMockMappings(data)
+	if err != nil {
+		return err
+	}
+	return d.importWireMockMappings(mappings, filesDir, destination)
+}
+
+// importWireMockMappings - converts mappings and imports them, fixed delays are added to response delays
+func (d *DBClient) importWireMockMappings(mappings []WireMockMapping, filesDir, destination string) error {
+	if destination == "" {
+		destination = "localhost"
+	}
+
+	var payloads []Payload
+	var delays []ResponseDelay
+	for _, mapping := range mappings {
+		converted, pathRe, err := wireMockPayloads(mapping, filesDir, destination)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":  err.Error(),
+				"method": mapping.Request.Method,
+				"url":    mapping.Request.URL + mapping.Request.URLPath + mapping.Request.URLPattern + mapping.Request.URLPathPattern,
+			}).Warn("Skipping WireMock mapping")
+			continue
+		}
+		payloads = append(payloads, converted...)
+
+		if mapping.Response.FixedDelayMilliseconds > 0 {
+			method := strings.ToUpper(mapping.Request.Method)
+			if method == "ANY" {
+				method = ""
+			}
+			delays = append(delays, ResponseDelay{
+				URLPattern: "^" + regexp.QuoteMeta(destination) + "(?:" + pathRe + ")$",
+				HTTPMethod: method,
+				Delay:      mapping.Response.FixedDelayMilliseconds,
+			})
+		}
+	}
+
+	if len(delays) > 0 && d.Delays != nil {
+		if err := d.Delays.Set(append(d.Delays.Get(), delays...)); err != nil {
+			return fmt.Errorf("Got error while adding WireMock delays, error %s", err.Error())
+		}
+	}
+	return d.ImportPayloads(payloads)
+}
+
+// ImportWireMockFromDisk - imports WireMock mappings from root directory (with mappings and __files directories) or
+// from single mapping file, body files of mapping file are read from ../__files
+func (d *DBClient) ImportWireMockFromDisk(path, destination string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("Got error while opening WireMock mappings, error %s", err.Error())
+	}
+
+	files := []string{path}
+	filesDir := filepath.Join(filepath.Dir(path), "..", "__files")
+	if info.IsDir() {
+		filesDir = filepath.Join(path, "__files")
+		files, err = filepath.Glob(filepath.Join(path, "mappings", "*.json"))
+		if err != nil {
+			return fmt.Errorf("Got error while listing WireMock mappings, error %s", err.Error())
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("Failed to import WireMock mappings, directory '%s' has no mappings/*.json files", path)
+		}
+		sort.Strings(files)
+	}
+
+	var mappings []WireMockMapping
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("Got error while opening WireMock mappings, error %s", err.Error())
+		}
+		fileMappings, err := parseWireMockMappings(data)
+		if err != nil {
+			return fmt.Errorf("%s: %s", file, err.Error())
+		}
+		mappings = append(mappings, fileMappings...)
+	}
+	return d.importWireMockMappings(mappings, filesDir, destination)
+}
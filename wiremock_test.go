@@ -0,0 +1,159 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWireMockPatternRegex(t *testing.T) {
+	value := "a.b"
+	re, err := WireMockPattern{EqualTo: &value}.regex()
+	expect(t, err, nil)
+	expect(t, re, `^a\.b$`)
+
+	re, err = WireMockPattern{Contains: &value, CaseInsensitive: true}.regex()
+	expect(t, err, nil)
+	expect(t, re, `(?i)a\.b`)
+
+	re, err = WireMockPattern{Matches: &value}.regex()
+	expect(t, err, nil)
+	expect(t, re, `^(?:a.b)$`)
+
+	_, err = WireMockPattern{Absent: true}.regex()
+	refute(t, err, nil)
+}
+
+func TestJSONLeafMatchers(t *testing.T) {
+	var value interface{}
+	json.Unmarshal([]byte(`{"name": "Jane", "roles": ["admin"], "tags": {}, "age": 30}`), &value)
+
+	matchers := jsonLeafMatchers("$", value)
+	expect(t, strings.Join(matchers, "\n"), `$["age"] == 30
+$["name"] == "Jane"
+$["roles"][0] == "admin"
+$["tags"] == {}`)
+	for _, expression := range matchers {
+		_, err := ParseJSONPathMatcher(expression)
+		expect(t, err, nil)
+	}
+}
+
+func TestJSONPathMatcherOfWireMockPattern(t *testing.T) {
+	expression, err := jsonPathMatcher(json.RawMessage(`"$.order.id"`))
+	expect(t, err, nil)
+	expect(t, expression, "$.order.id")
+
+	expression, err = jsonPathMatcher(json.RawMessage(`{"expression": "$.count", "equalTo": "10"}`))
+	expect(t, err, nil)
+	expect(t, expression, "$.count == 10")
+
+	expression, err = jsonPathMatcher(json.RawMessage(`{"expression": "$.carrier", "equalTo": "ups"}`))
+	expect(t, err, nil)
+	expect(t, expression, `$.carrier == "ups"`)
+
+	expression, err = jsonPathMatcher(json.RawMessage(`{"expression": "$.carrier", "contains": "u"}`))
+	expect(t, err, nil)
+	expect(t, expression, `$.carrier =~ "u"`)
+}
+
+func TestIsWireMockMapping(t *testing.T) {
+	expect(t, isWireMockMapping([]byte(`{"mappings": []}`)), true)
+	expect(t, isWireMockMapping([]byte(`{"request": {}, "response": {}}`)), true)
+	expect(t, isWireMockMapping([]byte(`{"data": []}`)), false)
+	expect(t, isWireMockMapping([]byte(`[]`)), false)
+}
+
+func TestImportWireMockDirectory(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	expect(t, dbClient.Import("examples/wiremock"), nil)
+	count, _ := dbClient.Cache.RecordsCount()
+	// ANY method mapping is imported for every method
+	expect(t, count, 6+len(wireMockAnyMethods))
+	dbClient.Cfg.SetMode(VirtualizeMode)
+
+	call := func(method, url, body string, headers map[string]string) (*http.Response, string) {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		_, resp := dbClient.processRequest(req)
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(respBody)
+	}
+
+	resp, body := call("GET", "http://localhost/users?page=2", "", nil)
+	expect(t, resp.StatusCode, 200)
+	expect(t, body, `[{"id":1,"name":"John"}]`)
+
+	resp, _ = call("GET", "http://localhost/users?page=last", "", nil)
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+
+	// body file, any query is accepted
+	resp, body = call("GET", "http://localhost/users/7?expand=roles", "", nil)
+	expect(t, resp.StatusCode, 200)
+	expect(t, resp.Header.Get("Content-Type"), "application/json")
+	expect(t, strings.TrimSpace(body), `{"id": 7, "name": "Jane"}`)
+
+	// mapping with higher priority wins
+	resp, body = call("GET", "http://localhost/users/0", "", nil)
+	expect(t, resp.StatusCode, 404)
+	expect(t, body, "no such user")
+
+	// extra fields are ignored
+	resp, body = call("POST", "http://localhost/users", `{"name": "Jane", "roles": ["admin"], "age": 30}`,
+		map[string]string{"Content-Type": "application/json"})
+	expect(t, resp.StatusCode, 201)
+	expect(t, body, "created")
+	delays := dbClient.Delays.Get()
+	expect(t, len(delays), 1)
+	expect(t, delays[0].Delay, 10)
+	expect(t, delays[0].HTTPMethod, "POST")
+
+	resp, _ = call("POST", "http://localhost/users", `{"name": "John", "roles": ["admin"]}`,
+		map[string]string{"Content-Type": "application/json"})
+	expect(t, resp.StatusCode, dbClient.Cfg.GetMissStatus())
+
+	resp, body = call("DELETE", "http://localhost/health", "", nil)
+	expect(t, resp.StatusCode, 200)
+	expect(t, body, "ok")
+	expect(t, len(resp.Header["Cache-Control"]), 2)
+
+	// scenario starts in "Started" state
+	resp, _ = call("POST", "http://localhost/orders/1/shipment", `{"carrier": "ups"}`, nil)
+	refute(t, resp.StatusCode, 201)
+	resp, body = call("POST", "http://localhost/orders/1/payment", "", nil)
+	expect(t, resp.StatusCode, 201)
+	expect(t, body, "paid")
+	resp, body = call("POST", "http://localhost/orders/1/shipment", `{"carrier": "ups"}`, nil)
+	expect(t, resp.StatusCode, 201)
+	expect(t, body, "shipped")
+	expect(t, dbClient.ScenarioState.Get()["order"], "shipped")
+}
+
+func TestImportWireMockSkipsUnsupportedMappings(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	defer dbClient.Cache.DeleteData()
+
+	mappings := `{"mappings": [
+		{"request": {"method": "GET", "url": "/a", "headers": {"X-Debug": {"absent": true}}}, "response": {"body": "a"}},
+		{"request": {"method": "GET", "url": "/b"}, "response": {"fault": "CONNECTION_RESET_BY_PEER"}},
+		{"request": {"method": "GET", "url": "/c"}, "response": {"bodyFileName": "c.json"}},
+		{"request": {"method": "GET", "url": "/d"}, "response": {"status": 204}}
+	]}`
+	expect(t, dbClient.ImportWireMock(strings.NewReader(mappings), "", "api.example.com"), nil)
+
+	payloads, _ := dbClient.Cache.GetAllRequests()
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Destination, "api.example.com")
+	expect(t, payloads[0].Request.Path, "/d")
+	expect(t, payloads[0].Response.Status, 204)
+
+	refute(t, dbClient.ImportWireMock(strings.NewReader(`{"data": []}`), "", ""), nil)
+}
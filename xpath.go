@@ -0,0 +1,371 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode - element or text of parsed XML document, names are local ones so namespace prefixes don't matter
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	// text - content of text node, text nodes have no name
+	text   string
+	isText bool
+}
+
+// value - string value of node, text of all descendants in document order
+func (n *xmlNode) value() string {
+	if n.isText {
+		return n.text
+	}
+	var buf bytes.Buffer
+	for _, child := range n.children {
+		buf.WriteString(child.value())
+	}
+	return buf.String()
+}
+
+// parseXMLDocument - parses XML body into node tree, returned node is document root with top level element as
+// its child
+func parseXMLDocument(body []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(strings.NewReader(string(body)))
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	elements := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		parent := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			elements++
+			node := &xmlNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				node.attrs[attr.Name.Local] = attr.Value
+			}
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if parent != root {
+				parent.children = append(parent.children, &xmlNode{text: string(t), isText: true})
+			}
+		}
+	}
+	if elements == 0 {
+		return nil, fmt.Errorf("body has no XML elements")
+	}
+	return root, nil
+}
+
+// xpathPredicate - condition in brackets, position or child/attribute existence or value
+type xpathPredicate struct {
+	position int
+	// attribute - predicate tests attribute instead of child element
+	attribute bool
+	name      string
+	value     string
+	hasValue  bool
+}
+
+// xpathStep - location step, child or descendant element, attribute or text
+type xpathStep struct {
+	descendant bool
+	attribute  bool
+	text       bool
+	// name - local name, * matches any
+	name       string
+	predicates []xpathPredicate
+}
+
+// XPathMatcher - XPath expression incoming XML body has to satisfy, i.e.
+// //Body/GetQuote/symbol == "ACME" or //order[@type='express']/id when the element only has to exist. Child (/) and
+// descendant (//) steps, wildcards, @attributes, text() and predicates with position or attribute and child values
+// are supported. Elements are matched by local name, so namespace prefixes can be left out
+type XPathMatcher struct {
+	steps      []xpathStep
+	comparison matcherComparison
+}
+
+// ParseXPathMatcher - parses XPath matcher expression
+func ParseXPathMatcher(expression string) (*XPathMatcher, error) {
+	path, operator, value := splitMatcherExpression(expression)
+
+	steps, err := parseXPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XPath '%s': %s", path, err.Error())
+	}
+	comparison, err := parseMatcherComparison(operator, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XPath matcher '%s': %s", expression, err.Error())
+	}
+	return &XPathMatcher{steps: steps, comparison: comparison}, nil
+}
+
+// splitXPath - splits path into steps on slashes outside brackets and quotes, empty step marks descendant axis
+func splitXPath(path string) []string {
+	var parts []string
+	var quote rune
+	depth, start := 0, 0
+	for i, c := range path {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '/' && depth == 0:
+			parts = append(parts, path[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, path[start:])
+}
+
+// localName - name without namespace prefix
+func localName(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// parseXPath - parses absolute path
+func parseXPath(path string) ([]xpathStep, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path has to start with /")
+	}
+
+	parts := splitXPath(path[1:])
+	var steps []xpathStep
+	descendant := false
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			if descendant || i == len(parts)-1 {
+				return nil, fmt.Errorf("empty step")
+			}
+			descendant = true
+			continue
+		}
+
+		step, err := parseXPathStep(part)
+		if err != nil {
+			return nil, err
+		}
+		if (step.attribute || step.text) && i != len(parts)-1 {
+			return nil, fmt.Errorf("%s has to be the last step", part)
+		}
+		step.descendant = descendant
+		descendant = false
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// parseXPathStep - parses step with its predicates
+func parseXPathStep(part string) (xpathStep, error) {
+	var step xpathStep
+	test := part
+	if i := strings.Index(part, "["); i >= 0 {
+		test = part[:i]
+		rest := part[i:]
+		for rest != "" {
+			if !strings.HasPrefix(rest, "[") {
+				return step, fmt.Errorf("unexpected '%s'", rest)
+			}
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return step, fmt.Errorf("unclosed bracket")
+			}
+			predicate, err := parseXPathPredicate(strings.TrimSpace(rest[1:end]))
+			if err != nil {
+				return step, err
+			}
+			step.predicates = append(step.predicates, predicate)
+			rest = rest[end+1:]
+		}
+	}
+
+	switch {
+	case test == "text()":
+		step.text = true
+	case strings.HasPrefix(test, "@"):
+		step.attribute = true
+		step.name = localName(test[1:])
+	default:
+		step.name = localName(test)
+	}
+	if step.name == "" && !step.text {
+		return step, fmt.Errorf("step '%s' needs name", part)
+	}
+	if (step.attribute || step.text) && len(step.predicates) > 0 {
+		return step, fmt.Errorf("predicates of '%s' aren't supported", part)
+	}
+	return step, nil
+}
+
+// parseXPathPredicate - parses position, [name], [@name] or [name='value'] predicate
+func parseXPathPredicate(predicate string) (xpathPredicate, error) {
+	if position, err := strconv.Atoi(predicate); err == nil {
+		if position < 1 {
+			return xpathPredicate{}, fmt.Errorf("positions start with 1")
+		}
+		return xpathPredicate{position: position}, nil
+	}
+
+	var p xpathPredicate
+	name := predicate
+	if i := strings.Index(predicate, "="); i >= 0 {
+		name = strings.TrimSpace(predicate[:i])
+		value := strings.TrimSpace(predicate[i+1:])
+		if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+			return p, fmt.Errorf("value of predicate [%s] has to be quoted", predicate)
+		}
+		p.value, p.hasValue = value[1:len(value)-1], true
+	}
+	if strings.HasPrefix(name, "@") {
+		p.attribute = true
+		name = name[1:]
+	}
+	p.name = localName(name)
+	if p.name == "" {
+		return p, fmt.Errorf("invalid predicate [%s]", predicate)
+	}
+	return p, nil
+}
+
+// matches - checks whether element satisfies predicate, position is 1-based index among selected siblings
+func (p xpathPredicate) matches(node *xmlNode, position int) bool {
+	if p.position > 0 {
+		return p.position == position
+	}
+	if p.attribute {
+		value, ok := node.attrs[p.name]
+		return ok && (!p.hasValue || value == p.value)
+	}
+	for _, child := range node.children {
+		if !child.isText && (p.name == "*" || child.name == p.name) && (!p.hasValue || child.value() == p.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// descendantsOrSelf - returns node and all its descendant elements
+func descendantsOrSelf(node *xmlNode) []*xmlNode {
+	nodes := []*xmlNode{node}
+	for _, child := range node.children {
+		if !child.isText {
+			nodes = append(nodes, descendantsOrSelf(child)...)
+		}
+	}
+	return nodes
+}
+
+// Select - returns string values of nodes, attributes or texts path selects in document
+func (m *XPathMatcher) Select(root *xmlNode) []string {
+	nodes := []*xmlNode{root}
+	for _, step := range m.steps {
+		if step.descendant {
+			var all []*xmlNode
+			for _, node := range nodes {
+				all = append(all, descendantsOrSelf(node)...)
+			}
+			nodes = all
+		}
+
+		if step.attribute || step.text {
+			var values []string
+			for _, node := range nodes {
+				if step.text {
+					for _, child := range node.children {
+						if child.isText {
+							values = append(values, child.text)
+						}
+					}
+					continue
+				}
+				for name, value := range node.attrs {
+					if step.name == "*" || name == step.name {
+						values = append(values, value)
+					}
+				}
+			}
+			return values
+		}
+
+		var next []*xmlNode
+		for _, node := range nodes {
+			var children []*xmlNode
+			for _, child := range node.children {
+				if !child.isText && (step.name == "*" || child.name == step.name) {
+					children = append(children, child)
+				}
+			}
+			// every predicate filters children the previous one selected, positions are counted among them
+			for _, predicate := range step.predicates {
+				var filtered []*xmlNode
+				for i, child := range children {
+					if predicate.matches(child, i+1) {
+						filtered = append(filtered, child)
+					}
+				}
+				children = filtered
+			}
+			next = append(next, children...)
+		}
+		nodes = next
+	}
+
+	values := make([]string, len(nodes))
+	for i, node := range nodes {
+		values[i] = node.value()
+	}
+	return values
+}
+
+// Matches - checks whether XML document satisfies the matcher, selected values are compared as numbers or
+// booleans when expected value is one
+func (m *XPathMatcher) Matches(root *xmlNode) bool {
+	selected := m.Select(root)
+	values := make([]interface{}, 0, len(selected))
+	for _, value := range selected {
+		value = strings.TrimSpace(value)
+		switch m.comparison.expected.(type) {
+		case float64:
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				values = append(values, f)
+				continue
+			}
+		case bool:
+			if b, err := strconv.ParseBool(value); err == nil {
+				values = append(values, b)
+				continue
+			}
+		}
+		values = append(values, value)
+	}
+	return m.comparison.satisfiedBy(values)
+}
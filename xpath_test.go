@@ -0,0 +1,73 @@
+package hoverfly
+
+import (
+	"testing"
+)
+
+const xpathDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="http://example.com/stock">
+  <soap:Header/>
+  <soap:Body>
+    <m:GetQuote currency="USD">
+      <m:symbol>ACME</m:symbol>
+      <m:quantity>150</m:quantity>
+      <m:item type="share">first</m:item>
+      <m:item type="option">second</m:item>
+    </m:GetQuote>
+  </soap:Body>
+</soap:Envelope>`
+
+func xpathMatches(t *testing.T, expression string) bool {
+	root, err := parseXMLDocument([]byte(xpathDocument))
+	expect(t, err, nil)
+	matcher, err := ParseXPathMatcher(expression)
+	expect(t, err, nil)
+	return matcher.Matches(root)
+}
+
+func TestXPathMatcherPaths(t *testing.T) {
+	expect(t, xpathMatches(t, `/Envelope/Body/GetQuote/symbol == "ACME"`), true)
+	// prefixes are ignored
+	expect(t, xpathMatches(t, `/soap:Envelope/soap:Body/m:GetQuote/m:symbol == "ACME"`), true)
+	expect(t, xpathMatches(t, `//symbol == "ACME"`), true)
+	expect(t, xpathMatches(t, `//symbol == "INITECH"`), false)
+	expect(t, xpathMatches(t, `//GetQuote/@currency == "USD"`), true)
+	expect(t, xpathMatches(t, `//GetQuote/symbol/text() == "ACME"`), true)
+	expect(t, xpathMatches(t, `/Envelope/*/GetQuote`), true)
+	expect(t, xpathMatches(t, `//GetQuote/discount`), false)
+	expect(t, xpathMatches(t, `//Header`), true)
+}
+
+func TestXPathMatcherPredicates(t *testing.T) {
+	expect(t, xpathMatches(t, `//item[2] == "second"`), true)
+	expect(t, xpathMatches(t, `//item[@type='option'] == "second"`), true)
+	expect(t, xpathMatches(t, `//item[@type='option'][1] == "second"`), true)
+	expect(t, xpathMatches(t, `//item[@type='future']`), false)
+	expect(t, xpathMatches(t, `//GetQuote[symbol='ACME']/quantity == 150`), true)
+	expect(t, xpathMatches(t, `//GetQuote[symbol="INITECH"]`), false)
+}
+
+func TestXPathMatcherComparisons(t *testing.T) {
+	expect(t, xpathMatches(t, `//quantity > 100`), true)
+	expect(t, xpathMatches(t, `//quantity < 100`), false)
+	expect(t, xpathMatches(t, `//symbol =~ "^AC"`), true)
+	expect(t, xpathMatches(t, `//symbol != "ACME"`), false)
+}
+
+func TestXPathMatcherInvalid(t *testing.T) {
+	for _, expression := range []string{
+		`Envelope/Body`,
+		`/`,
+		`//item[0]`,
+		`//item[@type=option]`,
+		`//item[1`,
+		`//@type/item`,
+		`//symbol == ACME`,
+	} {
+		_, err := ParseXPathMatcher(expression)
+		refute(t, err, nil)
+	}
+
+	_, err := parseXMLDocument([]byte("not xml"))
+	refute(t, err, nil)
+}
@@ -0,0 +1,110 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Simulations can be written as YAML, which is much nicer to edit by hand than JSON with escaped multi-line bodies.
+// YAML is converted to JSON and back, so simulations are decoded and migrated by the same code and JSON field names
+// apply to both formats.
+
+// isYAMLPath - checks whether file name has YAML extension
+func isYAMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// isYAMLContentType - checks whether content type is one of YAML media types (application/x-yaml, text/yaml, ...)
+func isYAMLContentType(contentType string) bool {
+	return strings.Contains(strings.ToLower(contentType), "yaml")
+}
+
+// wantsYAML - checks whether YAML response was asked for with "format=yaml" query parameter or Accept header
+func wantsYAML(req *http.Request) bool {
+	format := req.URL.Query().Get("format")
+	if format != "" {
+		return format == "yaml"
+	}
+	return isYAMLContentType(req.Header.Get("Accept"))
+}
+
+// writeYAML - writes value as YAML response
+func writeYAML(w http.ResponseWriter, v interface{}) {
+	b, err := marshalYAML(v)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write(b)
+}
+
+// decodeYAMLSimulation - decodes simulation written as YAML, it's migrated like JSON simulations are
+func decodeYAMLSimulation(data []byte) ([]Payload, error) {
+	converted, err := yamlToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSimulation(converted)
+}
+
+// marshalYAML - encodes value as YAML, value is first encoded as JSON so JSON field names and omitempty apply.
+// Mapping keys are sorted
+func marshalYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(generic)
+}
+
+// yamlToJSON - converts YAML document into JSON
+func yamlToJSON(data []byte) ([]byte, error) {
+	v, err := unmarshalYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// unmarshalYAML - decodes YAML document into maps, slices and scalars, mappings are decoded with string keys so
+// they can be encoded as JSON objects. Duplicate keys are rejected rather than silently overwritten
+func unmarshalYAML(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := yaml.UnmarshalStrict(data, &v); err != nil {
+		return nil, err
+	}
+	return jsonCompatible(v), nil
+}
+
+// jsonCompatible - converts mappings decoded by YAML library, which can have keys of any type, into JSON objects
+func jsonCompatible(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			m[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range value {
+			value[i] = jsonCompatible(item)
+		}
+	}
+	return v
+}
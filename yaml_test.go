@@ -0,0 +1,192 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLRoundTrip(t *testing.T) {
+	values := []interface{}{
+		"plain",
+		"",
+		"null",
+		"123",
+		"1.5",
+		"true",
+		"yes",
+		" padded ",
+		"- dash",
+		"key: value",
+		"comment # here",
+		"ends with:",
+		"http://example.com:8080/path?q=1#frag",
+		"tab\tinside",
+		"line\nbreak",
+		"line\nbreak\n",
+		"line\nbreak\n\n",
+		"\n\nleading breaks",
+		"  indented\nblock",
+		"windows\r\nline",
+		"unicode ünïcödé ☃",
+		float64(42),
+		1.25,
+		true,
+		nil,
+		[]interface{}{},
+		map[string]interface{}{},
+	}
+	for _, value := range values {
+		document := map[string]interface{}{
+			"value": value,
+			"list":  []interface{}{value, map[string]interface{}{"nested": value}, []interface{}{value}},
+		}
+		data, err := marshalYAML(document)
+		expect(t, err, nil)
+
+		decoded, err := unmarshalYAML(data)
+		expect(t, err, nil)
+
+		want, _ := json.Marshal(document)
+		got, _ := json.Marshal(decoded)
+		if string(want) != string(got) {
+			t.Errorf("%q didn't survive round trip, YAML:\n%s\ngot %s", value, data, got)
+		}
+	}
+}
+
+func TestMarshalYAMLUsesBlockScalars(t *testing.T) {
+	data, err := marshalYAML(map[string]interface{}{
+		"body": "{\n  \"name\": \"hoverfly\"\n}\n",
+		"tags": []string{"a", "b"},
+	})
+	expect(t, err, nil)
+	expect(t, string(data), "body: |\n  {\n    \"name\": \"hoverfly\"\n  }\ntags:\n- a\n- b\n")
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	document := `
+# simulation written by hand
+---
+meta: {schemaVersion: 2}
+data:
+- request:
+    method: GET   # comment
+    path: "/users/1"
+    headers:
+      Accept: [application/json, 'text/plain']
+  response:
+    status: 200
+    body: >
+      folded
+      text
+
+      new paragraph
+    literal: |2
+        indented
+      text
+    stripped: |-
+      no break
+    kept: |+
+      breaks
+
+    plain: multi
+      line
+    quoted: "tab\there \u263A"
+    single: 'it''s'
+    empty:
+    tilde: ~
+- - nested
+  - list
+...
+ignored: after document end
+`
+	v, err := unmarshalYAML([]byte(document))
+	expect(t, err, nil)
+
+	got, _ := json.Marshal(v)
+	want := `{"data":[{"request":{"headers":{"Accept":["application/json","text/plain"]},"method":"GET","path":"/users/1"},` +
+		`"response":{"body":"folded text\nnew paragraph\n","empty":null,"kept":"breaks\n\n","literal":"  indented\ntext\n",` +
+		`"plain":"multi line","quoted":"tab\there ☺","single":"it's","status":200,"stripped":"no break","tilde":null}},` +
+		`["nested","list"]],"meta":{"schemaVersion":2}}`
+	expect(t, string(got), want)
+}
+
+func TestUnmarshalYAMLErrors(t *testing.T) {
+	documents := map[string]string{
+		"duplicate key":   "a: 1\na: 2\n",
+		"tab indentation": "a:\n\tb: 1\n",
+		"unknown alias":   "a: *anchor\n",
+		"unclosed quote":  "a: \"open\n",
+		"bad indentation": "a: 1\n  b: 2\n",
+		"unclosed flow":   "a: [1, 2\n",
+		"sequence in map": "a: 1\n- b\n",
+	}
+	for name, document := range documents {
+		_, err := unmarshalYAML([]byte(document))
+		if err == nil {
+			t.Errorf("expected error for %s", name)
+		}
+	}
+
+	_, err := unmarshalYAML([]byte("a: 1\nb: 2\na: 3\n"))
+	expect(t, strings.Contains(err.Error(), "line 3"), true)
+}
+
+func TestDecodeYAMLSimulation(t *testing.T) {
+	simulation := `
+data:
+  - request:
+      method: POST
+      destination: api.example.com
+      path: /users
+      headers:
+        Content-Type: application/json
+    response:
+      status: "201"
+      body: |
+        {"id": 1}
+`
+	// unversioned simulations are migrated just like JSON ones
+	payloads, err := decodeYAMLSimulation([]byte(simulation))
+	expect(t, err, nil)
+	expect(t, len(payloads), 1)
+	expect(t, payloads[0].Request.Headers["Content-Type"][0], "application/json")
+	expect(t, payloads[0].Response.Status, 201)
+	expect(t, payloads[0].Response.Body, "{\"id\": 1}\n")
+}
+
+func TestAutosaveAsYAML(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	defer dbClient.Cache.DeleteData()
+
+	storeTestPayload(dbClient, "GET", "http://api.example.com/users", "", 200, "line one\nline two\n")
+
+	dir, err := ioutil.TempDir("", "hoverfly-yaml")
+	expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "simulation.yaml")
+
+	written, err := dbClient.ExportToFile(path)
+	expect(t, err, nil)
+	expect(t, written, 1)
+
+	data, _ := ioutil.ReadFile(path)
+	expect(t, strings.Contains(string(data), "body: |\n"), true)
+
+	payloads, err := decodeYAMLSimulation(data)
+	expect(t, err, nil)
+	records, _ := dbClient.Cache.GetAllRequests()
+	expect(t, reflect.DeepEqual(payloads[0].Response, records[0].Response), true)
+
+	dbClient.Cache.DeleteData()
+	expect(t, dbClient.LoadAutosave(path), nil)
+	count, _ := dbClient.Cache.RecordsCount()
+	expect(t, count, 1)
+}